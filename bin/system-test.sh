@@ -18,20 +18,7 @@ if which psql >/dev/null 2>&1; then
 EOF
 fi
 
-files=(
-  ./tests/system/main_test.go
-  ./tests/system/vendor_test.go
-  ./tests/system/ingredient_test.go
-  ./tests/system/substrate_test.go
-  ./tests/system/substrateingredient_test.go
-  ./tests/system/strain_test.go
-  ./tests/system/strainattribute_test.go
-  ./tests/system/lifecycle_test.go
-  ./tests/system/generation_test.go
-  ./tests/system/event_test.go
-  ./tests/system/source_test.go
-)
-
 go get net/http
 
-go test "${files[@]}"
+# the server in docker-compose, rather than one the tests start for themselves
+go test -count=1 ./tests/system/ -args -live
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type (
+	runner func(e *env, args []string) error
+
+	// command is either a group of subcommands, like lifecycle, or
+	// something that runs, like lifecycle list
+	command struct {
+		name string
+		// args is how the positional arguments look in usage
+		args string
+		desc string
+		// min and max are how many positional arguments there can be; max
+		// is -1 for as many as you like
+		min, max int
+		// setup adds the command's own flags to fs, and returns what runs it
+		setup func(fs *flag.FlagSet) runner
+		subs  []*command
+		// hidden commands are left out of usage
+		hidden bool
+	}
+)
+
+// globalFlags aren't repeated in every command's usage
+var globalFlags = map[string]bool{
+	"config":  true,
+	"profile": true,
+	"server":  true,
+	"token":   true,
+	"format":  true,
+	"f":       true,
+}
+
+func (c *command) exec(e *env, path []string, args []string) error {
+	path = append(path, c.name)
+
+	fs := flag.NewFlagSet(strings.Join(path, " "), flag.ContinueOnError)
+	fs.SetOutput(e.err)
+	fs.Usage = func() { c.usage(e.err, path, fs) }
+
+	if len(c.subs) == 0 {
+		run := c.setup(fs)
+		e.globals.bind(fs)
+
+		args, err := parse(fs, args)
+		if err != nil {
+			return flagErr(err)
+		} else if len(args) < c.min || (c.max >= 0 && len(args) > c.max) {
+			fs.Usage()
+			return errUsage
+		}
+		return run(e, args)
+	}
+
+	// global flags can come before the subcommand too
+	e.globals.bind(fs)
+	if err := fs.Parse(args); err != nil {
+		return flagErr(err)
+	} else if args = fs.Args(); len(args) == 0 {
+		fs.Usage()
+		return errUsage
+	} else if args[0] == "help" {
+		fs.Usage()
+		return flag.ErrHelp
+	} else if sub := c.sub(args[0]); sub == nil {
+		fmt.Fprintf(e.err, "%s: unknown command %q\n", fs.Name(), args[0])
+		fs.Usage()
+		return errUsage
+	} else {
+		return sub.exec(e, path, args[1:])
+	}
+}
+
+func (c *command) sub(name string) *command {
+	for _, sub := range c.subs {
+		if sub.name == name {
+			return sub
+		}
+	}
+	return nil
+}
+
+func (c *command) usage(w io.Writer, path []string, fs *flag.FlagSet) {
+	if len(c.subs) > 0 {
+		fmt.Fprintf(w, "usage: %s <command> [flags]\n\n", strings.Join(path, " "))
+		if c.desc != "" {
+			fmt.Fprintf(w, "%s\n\n", c.desc)
+		}
+		fmt.Fprintln(w, "commands:")
+		for _, sub := range c.subs {
+			if !sub.hidden {
+				// only the part before any details
+				desc, _, _ := strings.Cut(sub.desc, "; ")
+				fmt.Fprintf(w, "  %-12s %s\n", sub.name, desc)
+			}
+		}
+	} else {
+		fmt.Fprintf(w, "usage: %s %s [flags]\n\n%s\n", strings.Join(path, " "), c.args, c.desc)
+
+		var own strings.Builder
+		fs.VisitAll(func(f *flag.Flag) {
+			if globalFlags[f.Name] {
+				return
+			}
+			name, usage := flag.UnquoteUsage(f)
+			fmt.Fprintf(&own, "  --%s %s\n    \t%s", f.Name, name, usage)
+			if f.DefValue != "" && f.DefValue != "false" && f.DefValue != "0" && f.DefValue != "[]" {
+				fmt.Fprintf(&own, " (default %s)", f.DefValue)
+			}
+			own.WriteString("\n")
+		})
+		if own.Len() > 0 {
+			fmt.Fprintf(w, "\nflags:\n%s", own.String())
+		}
+	}
+	fmt.Fprintln(w, "\nglobal flags: --config, --profile, --server, --token, --format (-f)")
+}
+
+// flagErr is what to return when fs.Parse failed; the flag package has
+// already said what was wrong
+func flagErr(err error) error {
+	if errors.Is(err, flag.ErrHelp) {
+		return err
+	}
+	return errUsage
+}
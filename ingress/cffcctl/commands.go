@@ -0,0 +1,998 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jsmit257/centerforfunguscontrol/shared/client"
+	"github.com/jsmit257/huautla/types"
+)
+
+type (
+	apiRunner func(e *env, api *client.Client, args []string) error
+
+	// strs is a flag that can be repeated
+	strs []string
+)
+
+var factorRE = regexp.MustCompile(`^([+-]?\d+)\s*(hour|day|week|month|year)s?$`)
+
+func root() *command {
+	return &command{
+		name: "cffcctl",
+		desc: "the center for fungus control, from a terminal",
+		subs: []*command{
+			vendorCmd(),
+			strainCmd(),
+			substrateCmd(),
+			eventTypeCmd(),
+			lifecycleCmd(),
+			generationCmd(),
+			eventCmd(),
+			photoCmd(),
+			noteCmd(),
+			reportCmd(),
+			resolveCmd(),
+			tsCmd(),
+			undelCmd(),
+			profileCmd(),
+			completionCmd(),
+			completeCmd(),
+		},
+	}
+}
+
+// withAPI is a command that talks to the server
+func withAPI(run apiRunner) func(*flag.FlagSet) runner {
+	return func(*flag.FlagSet) runner {
+		return func(e *env, args []string) error {
+			api, err := e.client()
+			if err != nil {
+				return err
+			}
+			return run(e, api, args)
+		}
+	}
+}
+
+func vendorCmd() *command {
+	return &command{
+		name: "vendor",
+		desc: "where strains and substrates come from",
+		subs: []*command{{
+			name: "list",
+			desc: "lists vendors",
+			setup: withAPI(func(e *env, api *client.Client, _ []string) error {
+				vendors, err := api.Vendors.All(e.ctx)
+				if err != nil {
+					return err
+				}
+				return e.print(vendors, vendorTable(vendors...))
+			}),
+		}, {
+			name: "get",
+			args: "ID",
+			desc: "shows a vendor",
+			min:  1, max: 1,
+			setup: withAPI(func(e *env, api *client.Client, args []string) error {
+				v, err := api.Vendors.Get(e.ctx, types.UUID(args[0]))
+				if err != nil {
+					return err
+				}
+				return e.print(v, vendorTable(v))
+			}),
+		}},
+	}
+}
+
+func strainCmd() *command {
+	return &command{
+		name: "strain",
+		desc: "what's growing",
+		subs: []*command{{
+			name: "list",
+			desc: "lists strains",
+			setup: withAPI(func(e *env, api *client.Client, _ []string) error {
+				strains, err := api.Strains.All(e.ctx)
+				if err != nil {
+					return err
+				}
+				return e.print(strains, strainTable(strains...))
+			}),
+		}, {
+			name: "get",
+			args: "STRAIN",
+			desc: "shows a strain, by code, id or name",
+			min:  1, max: 1,
+			setup: withAPI(func(e *env, api *client.Client, args []string) error {
+				s, err := e.strain(api, args[0])
+				if err != nil {
+					return err
+				}
+				return e.print(s, strainTable(s))
+			}),
+		}},
+	}
+}
+
+func substrateCmd() *command {
+	return &command{
+		name: "substrate",
+		desc: "what strains grow on",
+		subs: []*command{{
+			name: "list",
+			desc: "lists substrates",
+			setup: withAPI(func(e *env, api *client.Client, _ []string) error {
+				substrates, err := api.Substrates.All(e.ctx)
+				if err != nil {
+					return err
+				}
+				return e.print(substrates, substrateTable(substrates...))
+			}),
+		}},
+	}
+}
+
+func eventTypeCmd() *command {
+	return &command{
+		name: "eventtype",
+		desc: "the kinds of things that happen to lifecycles and generations",
+		subs: []*command{{
+			name: "list",
+			desc: "lists event types",
+			setup: withAPI(func(e *env, api *client.Client, _ []string) error {
+				ets, err := api.EventTypes.All(e.ctx)
+				if err != nil {
+					return err
+				}
+
+				t := table{header: []string{"ID", "NAME", "SEVERITY", "STAGE"}}
+				for _, et := range ets {
+					t.rows = append(t.rows, []string{string(et.UUID), et.Name, et.Severity, et.Stage.Name})
+				}
+				return e.print(ets, t)
+			}),
+		}},
+	}
+}
+
+func lifecycleCmd() *command {
+	return &command{
+		name: "lifecycle",
+		desc: "a strain from grain to harvest",
+		subs: []*command{{
+			name: "list",
+			desc: "lists lifecycles",
+			setup: func(fs *flag.FlagSet) runner {
+				strain := fs.String("strain", "", "only lifecycles of the strain with this code, id or name")
+				location := fs.String("location", "", "only lifecycles whose location has this in it")
+
+				return withAPI(func(e *env, api *client.Client, _ []string) error {
+					var s types.Strain
+					if *strain != "" {
+						var err error
+						if s, err = e.strain(api, *strain); err != nil {
+							return err
+						}
+					}
+
+					all, err := api.Lifecycles.All(e.ctx)
+					if err != nil {
+						return err
+					}
+
+					lcs := []types.Lifecycle{}
+					for _, l := range all {
+						if s.UUID != "" && l.Strain.UUID != s.UUID {
+							continue
+						} else if !strings.Contains(strings.ToLower(l.Location), strings.ToLower(*location)) {
+							continue
+						}
+						lcs = append(lcs, l)
+					}
+					return e.print(lcs, lifecycleTable(lcs...))
+				})(fs)
+			},
+		}, {
+			name: "get",
+			args: "LIFECYCLE",
+			desc: "shows a lifecycle, by code or id",
+			min:  1, max: 1,
+			setup: withAPI(func(e *env, api *client.Client, args []string) error {
+				ref, err := e.ref(api, args[0], "lifecycle")
+				if err != nil {
+					return err
+				}
+
+				l, err := api.Lifecycles.Get(e.ctx, ref.ID)
+				if err != nil {
+					return err
+				}
+				return e.print(l, lifecycleTable(l))
+			}),
+		}, {
+			name: "add",
+			desc: "starts a lifecycle",
+			setup: func(fs *flag.FlagSet) runner {
+				strain := fs.String("strain", "", "the strain's code, id or name (required)")
+				grain := fs.String("grain", "", "the grain substrate's id or name (required)")
+				bulk := fs.String("bulk", "", "the bulk substrate's id or name (required)")
+				location := fs.String("location", "", "where it is (required)")
+				strainCost := fs.Float64("strain-cost", 0, "what the strain cost")
+				grainCost := fs.Float64("grain-cost", 0, "what the grain cost")
+				bulkCost := fs.Float64("bulk-cost", 0, "what the bulk substrate cost")
+
+				return withAPI(func(e *env, api *client.Client, _ []string) error {
+					if *strain == "" || *grain == "" || *bulk == "" || *location == "" {
+						return fmt.Errorf("--strain, --grain, --bulk and --location are required")
+					}
+
+					l := types.Lifecycle{
+						Location:   *location,
+						StrainCost: float32(*strainCost),
+						GrainCost:  float32(*grainCost),
+						BulkCost:   float32(*bulkCost),
+					}
+
+					var err error
+					if l.Strain, err = e.strain(api, *strain); err != nil {
+						return err
+					} else if l.GrainSubstrate, err = e.substrate(api, *grain, types.GrainType); err != nil {
+						return err
+					} else if l.BulkSubstrate, err = e.substrate(api, *bulk, types.BulkType); err != nil {
+						return err
+					} else if l, err = api.Lifecycles.Create(e.ctx, l); err != nil {
+						return err
+					}
+					return e.print(l, lifecycleTable(l))
+				})(fs)
+			},
+		}, {
+			name: "rm",
+			args: "LIFECYCLE",
+			desc: "deletes a lifecycle, by code or id",
+			min:  1, max: 1,
+			setup: withAPI(func(e *env, api *client.Client, args []string) error {
+				ref, err := e.ref(api, args[0], "lifecycle")
+				if err != nil {
+					return err
+				}
+				return api.Lifecycles.Delete(e.ctx, ref.ID)
+			}),
+		}},
+	}
+}
+
+func generationCmd() *command {
+	return &command{
+		name: "generation",
+		desc: "plates and liquid cultures, and what they came from",
+		subs: []*command{{
+			name: "list",
+			desc: "lists generations",
+			setup: withAPI(func(e *env, api *client.Client, _ []string) error {
+				gens, err := api.Generations.All(e.ctx)
+				if err != nil {
+					return err
+				}
+				return e.print(gens, generationTable(gens...))
+			}),
+		}, {
+			name: "get",
+			args: "GENERATION",
+			desc: "shows a generation, by code or id",
+			min:  1, max: 1,
+			setup: withAPI(func(e *env, api *client.Client, args []string) error {
+				ref, err := e.ref(api, args[0], "generation")
+				if err != nil {
+					return err
+				}
+
+				g, err := api.Generations.Get(e.ctx, ref.ID)
+				if err != nil {
+					return err
+				}
+				return e.print(g, generationTable(g))
+			}),
+		}},
+	}
+}
+
+func eventCmd() *command {
+	return &command{
+		name: "event",
+		desc: "what happened to a lifecycle or generation",
+		subs: []*command{{
+			name: "list",
+			args: "OWNER",
+			desc: "lists a lifecycle's or generation's events, by its code or id",
+			min:  1, max: 1,
+			setup: withAPI(func(e *env, api *client.Client, args []string) error {
+				events, err := e.events(api, args[0], func(ref client.Ref) ([]types.Event, error) {
+					if ref.Kind == "lifecycle" {
+						l, err := api.Lifecycles.Get(e.ctx, ref.ID)
+						return l.Events, err
+					}
+					g, err := api.Generations.Get(e.ctx, ref.ID)
+					return g.Events, err
+				})
+				if err != nil {
+					return err
+				}
+				return e.print(events, eventTable(events...))
+			}),
+		}, {
+			name: "add",
+			args: "OWNER",
+			desc: "adds an event to a lifecycle or generation, by its code or id",
+			min:  1, max: 1,
+			setup: func(fs *flag.FlagSet) runner {
+				typ := fs.String("type", "", "the event type's name or id (required)")
+				stage := fs.String("stage", "", "the event type's stage, when more than one has the name")
+				temp := fs.Float64("temp", 0, "the temperature")
+				humidity := fs.Int("humidity", 0, "the relative humidity")
+
+				return withAPI(func(e *env, api *client.Client, args []string) error {
+					if *typ == "" {
+						return fmt.Errorf("--type is required")
+					} else if *humidity < 0 || *humidity > 100 {
+						return fmt.Errorf("--humidity is a percentage, not %d", *humidity)
+					}
+
+					et, err := e.eventType(api, *typ, *stage)
+					if err != nil {
+						return err
+					}
+
+					ev := types.Event{EventType: et, Temperature: float32(*temp), Humidity: int8(*humidity)}
+					events, err := e.events(api, args[0], func(ref client.Ref) ([]types.Event, error) {
+						if ref.Kind == "lifecycle" {
+							l, err := api.Lifecycles.AddEvent(e.ctx, ref.ID, ev)
+							return l.Events, err
+						}
+						g, err := api.Generations.AddEvent(e.ctx, ref.ID, ev)
+						return g.Events, err
+					})
+					if err != nil {
+						return err
+					}
+					return e.print(events, eventTable(events...))
+				})(fs)
+			},
+		}, {
+			name: "rm",
+			args: "OWNER EVENT",
+			desc: "removes an event from a lifecycle or generation, by its code or id",
+			min:  2, max: 2,
+			setup: withAPI(func(e *env, api *client.Client, args []string) error {
+				evID := types.UUID(args[1])
+				events, err := e.events(api, args[0], func(ref client.Ref) ([]types.Event, error) {
+					if ref.Kind == "lifecycle" {
+						l, err := api.Lifecycles.RemoveEvent(e.ctx, ref.ID, evID)
+						return l.Events, err
+					}
+					g, err := api.Generations.RemoveEvent(e.ctx, ref.ID, evID)
+					return g.Events, err
+				})
+				if err != nil {
+					return err
+				}
+				return e.print(events, eventTable(events...))
+			}),
+		}},
+	}
+}
+
+func photoCmd() *command {
+	return &command{
+		name: "photo",
+		desc: "pictures of strains, lifecycles and events",
+		subs: []*command{{
+			name: "list",
+			args: "OWNER",
+			desc: "lists the photos of something, by its code or id",
+			min:  1, max: 1,
+			setup: withAPI(func(e *env, api *client.Client, args []string) error {
+				id, err := e.owner(api, args[0])
+				if err != nil {
+					return err
+				}
+
+				photos, err := api.Photos.List(e.ctx, id)
+				if err != nil {
+					return err
+				}
+				return e.print(photos, photoTable(photos...))
+			}),
+		}, {
+			name: "upload",
+			args: "OWNER FILE...",
+			desc: "adds photos to something, by its code or id",
+			min:  2, max: -1,
+			setup: withAPI(func(e *env, api *client.Client, args []string) error {
+				id, err := e.owner(api, args[0])
+				if err != nil {
+					return err
+				}
+
+				var photos []client.Photo
+				for _, path := range args[1:] {
+					f, err := readFile(path)
+					if err != nil {
+						return err
+					} else if photos, err = api.Photos.Add(e.ctx, id, f); err != nil {
+						return fmt.Errorf("%s: %w", path, err)
+					}
+				}
+				return e.print(photos, photoTable(photos...))
+			}),
+		}, {
+			name: "rm",
+			args: "OWNER PHOTO",
+			desc: "removes a photo from something, by its code or id",
+			min:  2, max: 2,
+			setup: withAPI(func(e *env, api *client.Client, args []string) error {
+				id, err := e.owner(api, args[0])
+				if err != nil {
+					return err
+				}
+
+				photos, err := api.Photos.Remove(e.ctx, id, types.UUID(args[1]))
+				if err != nil {
+					return err
+				}
+				return e.print(photos, photoTable(photos...))
+			}),
+		}},
+	}
+}
+
+func noteCmd() *command {
+	return &command{
+		name: "note",
+		desc: "notes on anything",
+		subs: []*command{{
+			name: "list",
+			args: "OWNER",
+			desc: "lists the notes on something, by its code or id",
+			min:  1, max: 1,
+			setup: withAPI(func(e *env, api *client.Client, args []string) error {
+				id, err := e.owner(api, args[0])
+				if err != nil {
+					return err
+				}
+
+				notes, err := api.Notes.List(e.ctx, id)
+				if err != nil {
+					return err
+				}
+				return e.print(notes, noteTable(notes...))
+			}),
+		}, {
+			name: "add",
+			args: "OWNER TEXT...",
+			desc: "adds a note to something, by its code or id; - reads the note from stdin",
+			min:  2, max: -1,
+			setup: withAPI(func(e *env, api *client.Client, args []string) error {
+				id, err := e.owner(api, args[0])
+				if err != nil {
+					return err
+				}
+
+				text := strings.Join(args[1:], " ")
+				if text == "-" {
+					b, err := io.ReadAll(e.in)
+					if err != nil {
+						return err
+					}
+					text = strings.TrimSpace(string(b))
+				}
+
+				notes, err := api.Notes.Add(e.ctx, id, types.Note{Note: text})
+				if err != nil {
+					return err
+				}
+				return e.print(notes, noteTable(notes...))
+			}),
+		}},
+	}
+}
+
+func reportCmd() *command {
+	kinds := []string{"vendor", "eventtype", "substrate", "strain", "lifecycle", "generation"}
+
+	return &command{
+		name: "report",
+		args: "KIND ID",
+		desc: "everything about something, with a field on each row; KIND is one of " + strings.Join(kinds, ", "),
+		min:  2, max: 2,
+		setup: withAPI(func(e *env, api *client.Client, args []string) error {
+			kind := args[0]
+			if !slices.Contains(kinds, kind) {
+				return fmt.Errorf("can't report on %q, only %s", kind, strings.Join(kinds, ", "))
+			}
+
+			id, err := e.owner(api, args[1])
+			if err != nil {
+				return err
+			}
+
+			var report types.Entity
+			if kind == "vendor" {
+				report, err = api.Vendors.Report(e.ctx, id)
+			} else if kind == "eventtype" {
+				report, err = api.EventTypes.Report(e.ctx, id)
+			} else if kind == "substrate" {
+				report, err = api.Substrates.Report(e.ctx, id)
+			} else if kind == "strain" {
+				report, err = api.Strains.Report(e.ctx, id)
+			} else if kind == "lifecycle" {
+				report, err = api.Lifecycles.Report(e.ctx, id)
+			} else {
+				report, err = api.Generations.Report(e.ctx, id)
+			}
+			if err != nil {
+				return err
+			}
+
+			return e.print(report, table{
+				header: []string{"FIELD", "VALUE"},
+				rows:   flatten("", map[string]any(report), nil),
+			})
+		}),
+	}
+}
+
+func resolveCmd() *command {
+	return &command{
+		name: "resolve",
+		args: "CODE",
+		desc: "what a short code, label url or id stands for",
+		min:  1, max: 1,
+		setup: withAPI(func(e *env, api *client.Client, args []string) error {
+			ref, err := e.ref(api, args[0])
+			if err != nil {
+				return err
+			}
+			return e.print(ref, table{
+				header: []string{"KIND", "ID"},
+				rows:   [][]string{{ref.Kind, string(ref.ID)}},
+			})
+		}),
+	}
+}
+
+func tsCmd() *command {
+	return &command{
+		name: "ts",
+		desc: "when things happened",
+		subs: []*command{{
+			name: "shift",
+			args: "TABLE ID",
+			desc: "sets a row's timestamps to --from, moved along by each --by, for things that were written down late; TABLE is the database's, like lifecycles or events",
+			min:  2, max: 2,
+			setup: func(fs *flag.FlagSet) runner {
+				var by strs
+				fields := fs.String("fields", "", "which timestamps, some of mtime, ctime and dtime, comma separated (required)")
+				from := fs.String("from", "", "when to start from, as 2006-01-02 or RFC 3339 (default now)")
+				fs.Var(&by, "by", "how far to move, like -2day or '3 weeks'; can be repeated")
+
+				return withAPI(func(e *env, api *client.Client, args []string) error {
+					ts, err := timestamp(*fields, *from, by)
+					if err != nil {
+						return err
+					}
+
+					id, err := e.owner(api, args[1])
+					if err != nil {
+						return err
+					}
+					return api.Retime(e.ctx, args[0], id, ts)
+				})(fs)
+			},
+		}},
+	}
+}
+
+func undelCmd() *command {
+	return &command{
+		name: "undel",
+		args: "TABLE ID",
+		desc: "brings back something that was deleted; TABLE is the database's, like strains or generations",
+		min:  2, max: 2,
+		setup: withAPI(func(e *env, api *client.Client, args []string) error {
+			// deleted things can't be scanned, so it has to be the id
+			return api.Undelete(e.ctx, args[0], types.UUID(args[1]))
+		}),
+	}
+}
+
+func profileCmd() *command {
+	return &command{
+		name: "profile",
+		desc: "the servers cffcctl knows about",
+		subs: []*command{{
+			name: "list",
+			desc: "lists profiles; the one in use has a *",
+			setup: func(*flag.FlagSet) runner {
+				return func(e *env, _ []string) error {
+					cfg, err := e.loadConfig()
+					if err != nil {
+						return err
+					}
+
+					current := first(e.globals.profile, e.getenv("CFFC_PROFILE"), cfg.Profile)
+					t := table{header: []string{"NAME", "SERVER", "TOKEN", "CURRENT"}}
+					for _, name := range cfg.names() {
+						p := cfg.Profiles[name]
+						t.rows = append(t.rows, []string{name, p.Server, yes(p.Token != ""), yes(name == current)})
+					}
+
+					// tokens stay in the file
+					redacted := map[string]string{}
+					for name, p := range cfg.Profiles {
+						redacted[name] = p.Server
+					}
+					return e.print(redacted, t)
+				}
+			},
+		}, {
+			name: "set",
+			args: "NAME",
+			desc: "adds a profile, or changes it, with the --server and --token flags; --token - reads it from stdin",
+			min:  1, max: 1,
+			setup: func(fs *flag.FlagSet) runner {
+				use := fs.Bool("use", false, "use it from now on")
+
+				return func(e *env, args []string) error {
+					cfg, err := e.loadConfig()
+					if err != nil {
+						return err
+					}
+
+					p := cfg.Profiles[args[0]]
+					if server := e.globals.server; server != "" {
+						if _, err = client.New(server); err != nil {
+							return err
+						}
+						p.Server = server
+					}
+					if token := e.globals.token; token == "-" {
+						b, err := io.ReadAll(e.in)
+						if err != nil {
+							return err
+						}
+						p.Token = strings.TrimSpace(string(b))
+					} else if token != "" {
+						p.Token = token
+					}
+					if p.Server == "" {
+						return fmt.Errorf("profile %q needs a --server", args[0])
+					}
+
+					cfg.Profiles[args[0]] = p
+					if *use || len(cfg.Profiles) == 1 {
+						cfg.Profile = args[0]
+					}
+					return cfg.save()
+				}
+			},
+		}, {
+			name: "use",
+			args: "NAME",
+			desc: "uses a profile from now on",
+			min:  1, max: 1,
+			setup: func(*flag.FlagSet) runner {
+				return func(e *env, args []string) error {
+					cfg, err := e.loadConfig()
+					if err != nil {
+						return err
+					} else if _, ok := cfg.Profiles[args[0]]; !ok {
+						return fmt.Errorf("no profile named %q in %s", args[0], cfg.path)
+					}
+					cfg.Profile = args[0]
+					return cfg.save()
+				}
+			},
+		}, {
+			name: "rm",
+			args: "NAME",
+			desc: "forgets a profile",
+			min:  1, max: 1,
+			setup: func(*flag.FlagSet) runner {
+				return func(e *env, args []string) error {
+					cfg, err := e.loadConfig()
+					if err != nil {
+						return err
+					} else if _, ok := cfg.Profiles[args[0]]; !ok {
+						return fmt.Errorf("no profile named %q in %s", args[0], cfg.path)
+					}
+					delete(cfg.Profiles, args[0])
+					if cfg.Profile == args[0] {
+						cfg.Profile = ""
+					}
+					return cfg.save()
+				}
+			},
+		}},
+	}
+}
+
+// ref is what a short code, label url or id stands for; kinds are what it
+// has to be, if it matters
+func (e *env) ref(api *client.Client, s string, kinds ...string) (client.Ref, error) {
+	ref, err := api.Scan(e.ctx, s)
+	if err != nil {
+		return ref, fmt.Errorf("%s: %w", s, err)
+	} else if len(kinds) > 0 && !slices.Contains(kinds, ref.Kind) {
+		return ref, fmt.Errorf("%s is a %s, not a %s", s, ref.Kind, strings.Join(kinds, " or "))
+	}
+	return ref, nil
+}
+
+// owner is the id of whatever s stands for, or s itself, since photos and
+// notes can belong to things that don't have codes
+func (e *env) owner(api *client.Client, s string) (types.UUID, error) {
+	if ref, err := api.Scan(e.ctx, s); err == nil {
+		return ref.ID, nil
+	} else if errors.Is(err, client.ErrNotFound) {
+		return types.UUID(s), nil
+	} else {
+		return "", err
+	}
+}
+
+// events calls fn with the lifecycle or generation s stands for
+func (e *env) events(api *client.Client, s string, fn func(client.Ref) ([]types.Event, error)) ([]types.Event, error) {
+	ref, err := e.ref(api, s, "lifecycle", "generation")
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := fn(ref)
+	if events == nil {
+		events = []types.Event{}
+	}
+	return events, err
+}
+
+// strain is the strain with the code, id or name s
+func (e *env) strain(api *client.Client, s string) (types.Strain, error) {
+	all, err := api.Strains.All(e.ctx)
+	if err != nil {
+		return types.Strain{}, err
+	}
+
+	if result, err := match("strain", s, all, func(s types.Strain) (types.UUID, string) {
+		return s.UUID, s.Name
+	}); !errors.Is(err, client.ErrNotFound) {
+		return result, err
+	}
+
+	// it might be a code
+	ref, err := e.ref(api, s, "strain")
+	if err != nil {
+		return types.Strain{}, err
+	}
+	return api.Strains.Get(e.ctx, ref.ID)
+}
+
+// substrate is the substrate of type typ with the id or name s
+func (e *env) substrate(api *client.Client, s string, typ types.SubstrateType) (types.Substrate, error) {
+	all, err := api.Substrates.All(e.ctx)
+	if err != nil {
+		return types.Substrate{}, err
+	}
+
+	return match(string(typ)+" substrate", s, slices.DeleteFunc(all, func(s types.Substrate) bool {
+		return s.Type != typ
+	}), func(s types.Substrate) (types.UUID, string) {
+		return s.UUID, s.Name
+	})
+}
+
+// eventType is the event type with the id or name s, in stage if there's
+// more than one
+func (e *env) eventType(api *client.Client, s, stage string) (types.EventType, error) {
+	all, err := api.EventTypes.All(e.ctx)
+	if err != nil {
+		return types.EventType{}, err
+	}
+
+	if stage != "" {
+		all = slices.DeleteFunc(all, func(et types.EventType) bool {
+			return !strings.EqualFold(et.Stage.Name, stage) && et.Stage.UUID != types.UUID(stage)
+		})
+	}
+
+	return match("event type", s, all, func(et types.EventType) (types.UUID, string) {
+		return et.UUID, et.Name
+	})
+}
+
+// match is the one thing in all with the id s, or else the only one named
+// s, ignoring case
+func match[T any](kind, s string, all []T, key func(T) (types.UUID, string)) (T, error) {
+	var found []T
+	for _, v := range all {
+		if id, name := key(v); id == types.UUID(s) {
+			return v, nil
+		} else if strings.EqualFold(name, strings.TrimSpace(s)) {
+			found = append(found, v)
+		}
+	}
+
+	var zero T
+	if len(found) == 0 {
+		return zero, fmt.Errorf("no %s %q: %w", kind, s, client.ErrNotFound)
+	} else if len(found) > 1 {
+		return zero, fmt.Errorf("%d of the %ss are named %q; use an id", len(found), kind, s)
+	}
+	return found[0], nil
+}
+
+// timestamp is fields set to from, moved by each of by
+func timestamp(fields, from string, by []string) (types.Timestamp, error) {
+	var result types.Timestamp
+
+	for _, f := range strings.Split(fields, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			result.Fields = append(result.Fields, f)
+		}
+	}
+	if len(result.Fields) == 0 {
+		return result, fmt.Errorf("--fields is required")
+	}
+
+	origin := time.Now().UTC()
+	if from == "" {
+	} else if t, err := time.Parse(time.RFC3339, from); err == nil {
+		origin = t
+	} else if t, err = time.ParseInLocation(time.DateOnly, from, time.Local); err == nil {
+		origin = t
+	} else {
+		return result, fmt.Errorf("--from %q isn't 2006-01-02 or RFC 3339", from)
+	}
+	result.Origin = &origin
+
+	for _, b := range by {
+		m := factorRE.FindStringSubmatch(strings.ToLower(strings.TrimSpace(b)))
+		if m == nil {
+			return result, fmt.Errorf("--by %q isn't a number of hours, days, weeks, months or years", b)
+		}
+		n, _ := strconv.Atoi(m[1])
+		result.Factor = append(result.Factor, struct {
+			Delta    int    `json:"delta,omitempty"`
+			Interval string `json:"interval,omitempty"`
+		}{n, m[2]})
+	}
+
+	return result, nil
+}
+
+func readFile(path string) (client.File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return client.File{}, err
+	}
+
+	ct := mime.TypeByExtension(filepath.Ext(path))
+	if ct == "" {
+		ct = http.DetectContentType(data)
+	}
+	return client.File{Name: filepath.Base(path), ContentType: ct, Data: data}, nil
+}
+
+func (s *strs) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *strs) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+func yes(b bool) string {
+	if b {
+		return "*"
+	}
+	return ""
+}
+
+func vendorTable(vendors ...types.Vendor) table {
+	t := table{header: []string{"ID", "NAME", "WEBSITE"}}
+	for _, v := range vendors {
+		t.rows = append(t.rows, []string{string(v.UUID), v.Name, v.Website})
+	}
+	return t
+}
+
+func strainTable(strains ...types.Strain) table {
+	t := table{header: []string{"ID", "NAME", "SPECIES", "VENDOR", "CREATED"}}
+	for _, s := range strains {
+		t.rows = append(t.rows, []string{string(s.UUID), s.Name, s.Species, s.Vendor.Name, when(s.CTime)})
+	}
+	return t
+}
+
+func substrateTable(substrates ...types.Substrate) table {
+	t := table{header: []string{"ID", "NAME", "TYPE", "VENDOR"}}
+	for _, s := range substrates {
+		t.rows = append(t.rows, []string{string(s.UUID), s.Name, string(s.Type), s.Vendor.Name})
+	}
+	return t
+}
+
+func lifecycleTable(lcs ...types.Lifecycle) table {
+	t := table{header: []string{"ID", "LOCATION", "STRAIN", "GRAIN", "BULK", "EVENTS", "YIELD", "CREATED"}}
+	for _, l := range lcs {
+		t.rows = append(t.rows, []string{
+			string(l.UUID),
+			l.Location,
+			l.Strain.Name,
+			l.GrainSubstrate.Name,
+			l.BulkSubstrate.Name,
+			strconv.Itoa(len(l.Events)),
+			float(l.Yield),
+			when(l.CTime),
+		})
+	}
+	return t
+}
+
+func generationTable(gens ...types.Generation) table {
+	t := table{header: []string{"ID", "PLATING", "LIQUID", "SOURCES", "EVENTS", "CREATED"}}
+	for _, g := range gens {
+		t.rows = append(t.rows, []string{
+			string(g.UUID),
+			g.PlatingSubstrate.Name,
+			g.LiquidSubstrate.Name,
+			strconv.Itoa(len(g.Sources)),
+			strconv.Itoa(len(g.Events)),
+			when(g.CTime),
+		})
+	}
+	return t
+}
+
+func eventTable(events ...types.Event) table {
+	t := table{header: []string{"ID", "TYPE", "STAGE", "TEMP", "HUMIDITY", "CREATED"}}
+	for _, ev := range events {
+		t.rows = append(t.rows, []string{
+			string(ev.UUID),
+			ev.EventType.Name,
+			ev.EventType.Stage.Name,
+			float(ev.Temperature),
+			strconv.Itoa(int(ev.Humidity)),
+			when(ev.CTime),
+		})
+	}
+	return t
+}
+
+func photoTable(photos ...client.Photo) table {
+	t := table{header: []string{"ID", "IMAGE", "NOTES", "CREATED"}}
+	for _, p := range photos {
+		t.rows = append(t.rows, []string{string(p.UUID), p.Filename, strconv.Itoa(len(p.Notes)), when(p.CTime)})
+	}
+	return t
+}
+
+func noteTable(notes ...types.Note) table {
+	t := table{header: []string{"ID", "NOTE", "CREATED"}}
+	for _, n := range notes {
+		t.rows = append(t.rows, []string{string(n.UUID), n.Note, when(n.CTime)})
+	}
+	return t
+}
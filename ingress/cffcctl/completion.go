@@ -0,0 +1,122 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// bashCompletion asks cffcctl what comes next, so it's never out of date;
+// files are completed when there's nothing else, for photo upload
+const bashCompletion = `# bash completion for cffcctl: source <(cffcctl completion bash)
+_cffcctl() {
+	local IFS=$'\n'
+	COMPREPLY=($(compgen -W "$("${COMP_WORDS[0]}" __complete "${COMP_WORDS[@]:1:COMP_CWORD-1}" 2>/dev/null)" -- "${COMP_WORDS[COMP_CWORD]}"))
+}
+complete -o default -F _cffcctl cffcctl
+`
+
+const zshCompletion = `#compdef cffcctl
+# zsh completion for cffcctl: source <(cffcctl completion zsh)
+autoload -U +X bashcompinit && bashcompinit
+` + bashCompletion
+
+var formats = []string{"table", "json", "csv"}
+
+func completionCmd() *command {
+	return &command{
+		name: "completion",
+		args: "SHELL",
+		desc: "prints the completion script for bash or zsh",
+		min:  1, max: 1,
+		setup: func(*flag.FlagSet) runner {
+			return func(e *env, args []string) error {
+				if args[0] == "bash" {
+					_, err := io.WriteString(e.out, bashCompletion)
+					return err
+				} else if args[0] == "zsh" {
+					_, err := io.WriteString(e.out, zshCompletion)
+					return err
+				}
+				return fmt.Errorf("no completion for %q, only bash and zsh", args[0])
+			}
+		},
+	}
+}
+
+// completeCmd is what the completion scripts call with the words so far
+func completeCmd() *command {
+	return &command{
+		name:   "__complete",
+		max:    -1,
+		hidden: true,
+		setup: func(fs *flag.FlagSet) runner {
+			return func(e *env, args []string) error {
+				for _, word := range complete(e, args) {
+					fmt.Fprintln(e.out, word)
+				}
+				return nil
+			}
+		},
+	}
+}
+
+// complete is what could come after words
+func complete(e *env, words []string) []string {
+	if n := len(words); n > 0 {
+		if last := words[n-1]; last == "--format" || last == "-f" {
+			return formats
+		} else if last == "--profile" {
+			return profiles(e)
+		}
+	}
+
+	c, path := root(), []string{}
+	for i := 0; i < len(words) && len(c.subs) > 0; i++ {
+		if w := words[i]; strings.HasPrefix(w, "-") {
+			// every global flag has a value
+			if !strings.Contains(w, "=") {
+				i++
+			}
+		} else if c = c.sub(w); c == nil {
+			return nil
+		} else {
+			path = append(path, w)
+		}
+	}
+
+	var result []string
+	if len(c.subs) > 0 {
+		for _, sub := range c.subs {
+			if !sub.hidden {
+				result = append(result, sub.name)
+			}
+		}
+		return result
+	}
+
+	if p := strings.Join(path, " "); p == "completion" {
+		result = append(result, "bash", "zsh")
+	} else if p == "profile use" || p == "profile rm" || p == "profile set" {
+		result = append(result, profiles(e)...)
+	}
+
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	c.setup(fs)
+	(&globals{}).bind(fs)
+	fs.VisitAll(func(f *flag.Flag) {
+		if len(f.Name) > 1 {
+			result = append(result, "--"+f.Name)
+		}
+	})
+	return result
+}
+
+func profiles(e *env) []string {
+	cfg, err := e.loadConfig()
+	if err != nil {
+		return nil
+	}
+	return cfg.names()
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+type (
+	// settings is the profiles file; it has tokens in it, so only its owner
+	// can read it
+	settings struct {
+		// Profile is the one to use when nothing says otherwise
+		Profile  string             `json:"profile,omitempty"`
+		Profiles map[string]profile `json:"profiles"`
+
+		path string
+	}
+
+	// profile is a server and how to get in to it
+	profile struct {
+		Server string `json:"server"`
+		Token  string `json:"token,omitempty"`
+	}
+)
+
+// configPath is --config, $CFFC_CONFIG or cffcctl/config.json in the user's
+// config directory
+func (e *env) configPath() (string, error) {
+	if path := first(e.globals.config, e.getenv("CFFC_CONFIG")); path != "" {
+		return path, nil
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("no --config, and %w", err)
+	}
+	return filepath.Join(dir, "cffcctl", "config.json"), nil
+}
+
+// loadConfig is the profiles file, or an empty one if there isn't one yet
+func (e *env) loadConfig() (*settings, error) {
+	path, err := e.configPath()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &settings{Profiles: map[string]profile{}, path: path}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return result, nil
+	} else if err != nil {
+		return nil, err
+	} else if err = json.Unmarshal(b, result); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	} else if result.Profiles == nil {
+		result.Profiles = map[string]profile{}
+	}
+	return result, nil
+}
+
+func (cfg *settings) save() error {
+	b, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	} else if err = os.MkdirAll(filepath.Dir(cfg.path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(cfg.path, append(b, '\n'), 0600)
+}
+
+// current is the first of names that's set, or the default profile; it's
+// the zero profile when none of them are set, so flags and the environment
+// can make up for it, and an error when the one that's set isn't there
+func (cfg *settings) current(names ...string) (profile, error) {
+	name := first(append(names, cfg.Profile)...)
+	if name == "" {
+		return profile{}, nil
+	} else if p, ok := cfg.Profiles[name]; ok {
+		return p, nil
+	}
+	return profile{}, fmt.Errorf("no profile named %q in %s", name, cfg.path)
+}
+
+func (cfg *settings) names() []string {
+	result := make([]string, 0, len(cfg.Profiles))
+	for name := range cfg.Profiles {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
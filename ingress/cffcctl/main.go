@@ -0,0 +1,141 @@
+// cffcctl is the api from a terminal, for the things that are tedious to
+// click through and for cron; see README.md#cffcctl
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/jsmit257/centerforfunguscontrol/shared/client"
+)
+
+// errUsage is a command that was called wrong; usage has already been
+// printed, so it exits without saying anything else
+var errUsage = errors.New("usage")
+
+type (
+	// env is everything a command needs that isn't its own flags
+	env struct {
+		ctx     context.Context
+		in      io.Reader
+		out     io.Writer
+		err     io.Writer
+		getenv  func(string) string
+		globals globals
+		api     *client.Client
+	}
+
+	// globals are the flags every command takes
+	globals struct {
+		config  string
+		profile string
+		server  string
+		token   string
+		format  string
+	}
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	os.Exit(run(ctx, os.Args[1:], &env{
+		in:     os.Stdin,
+		out:    os.Stdout,
+		err:    os.Stderr,
+		getenv: os.Getenv,
+	}))
+}
+
+// run is main without the os, so tests can call it; the result is the
+// exit code
+func run(ctx context.Context, args []string, e *env) int {
+	e.ctx = ctx
+
+	if err := root().exec(e, nil, args); errors.Is(err, errUsage) {
+		return 2
+	} else if errors.Is(err, flag.ErrHelp) {
+		return 0
+	} else if err != nil {
+		fmt.Fprintf(e.err, "cffcctl: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func (g *globals) bind(fs *flag.FlagSet) {
+	fs.StringVar(&g.config, "config", g.config, "the file profiles are kept in (default $CFFC_CONFIG, or cffcctl/config.json in the user's config directory)")
+	fs.StringVar(&g.profile, "profile", g.profile, "the profile to use (default $CFFC_PROFILE, or the one set with `profile use`)")
+	fs.StringVar(&g.server, "server", g.server, "the server's url, instead of the profile's (default $CFFC_SERVER)")
+	fs.StringVar(&g.token, "token", g.token, "the us-authn cookie's value, instead of the profile's (default $CFFC_TOKEN)")
+	fs.StringVar(&g.format, "format", g.format, "table, json or csv (default table)")
+	fs.StringVar(&g.format, "f", g.format, "short for --format")
+}
+
+// client is the api for the server the flags, environment or profile say,
+// in that order
+func (e *env) client() (*client.Client, error) {
+	if e.api != nil {
+		return e.api, nil
+	}
+
+	cfg, err := e.loadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := cfg.current(e.globals.profile, e.getenv("CFFC_PROFILE"))
+	if err != nil {
+		return nil, err
+	}
+
+	server := first(e.globals.server, e.getenv("CFFC_SERVER"), p.Server)
+	token := first(e.globals.token, e.getenv("CFFC_TOKEN"), p.Token)
+	if server == "" {
+		return nil, fmt.Errorf("no server; use --server, $CFFC_SERVER or `cffcctl profile set`")
+	}
+
+	var opts []client.Option
+	if token != "" {
+		opts = append(opts, client.WithToken(token))
+	}
+
+	e.api, err = client.New(server, opts...)
+	return e.api, err
+}
+
+// parse is flag.Parse, except flags can come after positional arguments,
+// like `event add LC-2026-0142 --type Harvesting`
+func parse(fs *flag.FlagSet, args []string) ([]string, error) {
+	var result []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+
+		rest := fs.Args()
+		if len(rest) == 0 {
+			return result, nil
+		} else if n := len(args) - len(rest); n > 0 && args[n-1] == "--" {
+			// everything after -- is positional, even if it looks like a flag
+			return append(result, rest...), nil
+		}
+		result, args = append(result, rest[0]), rest[1:]
+	}
+}
+
+func first(vals ...string) string {
+	for _, v := range vals {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
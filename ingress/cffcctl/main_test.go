@@ -0,0 +1,408 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/config"
+	"github.com/jsmit257/centerforfunguscontrol/internal/data/huautla"
+	"github.com/jsmit257/centerforfunguscontrol/internal/router"
+)
+
+var (
+	demoOnce sync.Once
+	demoURL  string
+	demoErr  error
+)
+
+// demo is a server on the demo data, shared by every test that doesn't
+// care what the others do to it
+func demo(t *testing.T) string {
+	demoOnce.Do(func() {
+		dir, err := os.MkdirTemp("", "cffcctl-")
+		if demoErr = err; err != nil {
+			return
+		}
+
+		cfg := config.NewConfig()
+		cfg.Demo = true
+		cfg.AuthnHost, cfg.AuthnPort = "", 0
+		cfg.AlbumDir = filepath.Join(dir, "album")
+		cfg.StoreDir = filepath.Join(dir, "store")
+		cfg.AttachmentDir = filepath.Join(dir, "attachments")
+		for _, d := range []string{cfg.AlbumDir, cfg.AttachmentDir} {
+			if demoErr = os.Mkdir(d, 0755); demoErr != nil {
+				return
+			}
+		}
+
+		logger := logrus.New()
+		logger.SetLevel(logrus.ErrorLevel)
+		log := logger.WithField("app", "cffcctl-test")
+
+		ha, err := huautla.New(cfg, log)
+		if demoErr = err; err != nil {
+			return
+		}
+		go ha.Run(context.Background())
+
+		demoURL = httptest.NewServer(router.NewHuautla(cfg, ha, log)).URL
+	})
+	require.Nil(t, demoErr)
+	return demoURL
+}
+
+func newEnv(t *testing.T, vars map[string]string) (*env, *bytes.Buffer, *bytes.Buffer) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	if _, ok := vars["CFFC_CONFIG"]; !ok {
+		vars["CFFC_CONFIG"] = filepath.Join(t.TempDir(), "config.json")
+	}
+	return &env{
+		in:     strings.NewReader(""),
+		out:    stdout,
+		err:    stderr,
+		getenv: func(k string) string { return vars[k] },
+	}, stdout, stderr
+}
+
+func Test_run(t *testing.T) {
+	t.Parallel()
+
+	server := demo(t)
+
+	tcs := map[string]struct {
+		args   []string
+		server string
+		code   int
+		out    []string
+		lines  int
+		err    string
+	}{
+		"vendors": {
+			args:   []string{"vendor", "list", "-f", "csv"},
+			server: server,
+			out:    []string{"ID,NAME,WEBSITE", "North Spore,https://northspore.com"},
+			lines:  5,
+		},
+		"lifecycles_of_a_strain": {
+			args:   []string{"lifecycle", "list", "--strain", "lion's mane", "--format=csv"},
+			server: server,
+			out:    []string{"Tent B,Lion's Mane"},
+			lines:  2,
+		},
+		"strain_by_code": {
+			args:   []string{"strain", "get", "STR-0001"},
+			server: server,
+			out:    []string{"Blue Oyster", "Pleurotus ostreatus"},
+			lines:  2,
+		},
+		"add_event": {
+			args:   []string{"event", "add", "LC-2026-0003", "--type", "Harvesting", "--temp", "74", "--humidity", "88", "-f", "csv"},
+			server: server,
+			out:    []string{",Harvesting,Majority,74,88,"},
+		},
+		"ambiguous_flags_after_args": {
+			args:   []string{"event", "add", "LC-2026-0003", "--type", "clone", "--stage", "any", "-f", "json"},
+			server: server,
+			out:    []string{`"name": "Clone"`},
+		},
+		"report": {
+			args:   []string{"report", "strain", "STR-0003", "-f", "csv"},
+			server: server,
+			out:    []string{"FIELD,VALUE", "name,Lion's Mane"},
+		},
+		"resolve": {
+			args:   []string{"resolve", "GEN-0001", "-f", "csv"},
+			server: server,
+			out:    []string{"KIND,ID", "generation,"},
+		},
+		"wrong_kind": {
+			args:   []string{"lifecycle", "get", "STR-0001"},
+			server: server,
+			code:   1,
+			err:    "cffcctl: STR-0001 is a strain, not a lifecycle",
+		},
+		"no_such_strain": {
+			args:   []string{"lifecycle", "list", "--strain", "morel"},
+			server: server,
+			code:   1,
+			err:    "404",
+		},
+		"no_such_event_type": {
+			args:   []string{"event", "add", "LC-2026-0003", "--type", "dancing"},
+			server: server,
+			code:   1,
+			err:    `no event type "dancing"`,
+		},
+		"bad_format": {
+			args:   []string{"vendor", "list", "-f", "yaml"},
+			server: server,
+			code:   1,
+			err:    `unsupported format: "yaml"`,
+		},
+		"no_server": {
+			args: []string{"vendor", "list"},
+			code: 1,
+			err:  "no server",
+		},
+		"no_command": {
+			code: 2,
+			err:  "usage: cffcctl <command>",
+		},
+		"unknown_command": {
+			args: []string{"lifecycle", "grow"},
+			code: 2,
+			err:  `unknown command "grow"`,
+		},
+		"unknown_flag": {
+			args: []string{"lifecycle", "list", "--nope"},
+			code: 2,
+			err:  "flag provided but not defined: -nope",
+		},
+		"too_many_args": {
+			args: []string{"lifecycle", "get", "LC-2026-0001", "LC-2026-0002"},
+			code: 2,
+			err:  "usage: cffcctl lifecycle get LIFECYCLE",
+		},
+		"help": {
+			args: []string{"event", "help"},
+			err:  "add ",
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			e, stdout, stderr := newEnv(t, map[string]string{"CFFC_SERVER": tc.server})
+			require.Equal(t, tc.code, run(context.Background(), tc.args, e), stderr.String())
+			for _, out := range tc.out {
+				require.Contains(t, stdout.String(), out)
+			}
+			if tc.lines > 0 {
+				require.Equal(t, tc.lines, strings.Count(stdout.String(), "\n"), stdout.String())
+			}
+			require.Contains(t, stderr.String(), tc.err)
+		})
+	}
+}
+
+func Test_profile(t *testing.T) {
+	t.Parallel()
+
+	server := demo(t)
+	path := filepath.Join(t.TempDir(), "cffcctl", "config.json")
+
+	for _, step := range []struct {
+		args []string
+		in   string
+		code int
+		out  string
+	}{
+		{args: []string{"profile", "set", "demo", "--server", server}},
+		{args: []string{"profile", "set", "prod", "--server", "http://prod.example.com", "--token", "-"}, in: "sekrit\n"},
+		{args: []string{"profile", "list", "-f", "csv"}, out: "NAME,SERVER,TOKEN,CURRENT\ndemo," + server + ",,*\nprod,http://prod.example.com,*,\n"},
+		{args: []string{"vendor", "list", "-f", "csv"}, out: "In house"},
+		{args: []string{"--profile", "nope", "vendor", "list"}, code: 1},
+		{args: []string{"profile", "use", "prod"}},
+		{args: []string{"profile", "list", "-f", "json"}, out: `"prod": "http://prod.example.com"`},
+		{args: []string{"profile", "rm", "prod"}},
+		{args: []string{"profile", "use", "prod"}, code: 1},
+		{args: []string{"profile", "set", "empty"}, code: 1},
+	} {
+		e, stdout, stderr := newEnv(t, map[string]string{"CFFC_CONFIG": path})
+		e.in = strings.NewReader(step.in)
+		require.Equal(t, step.code, run(context.Background(), step.args, e), "%v: %s", step.args, stderr.String())
+		require.Contains(t, stdout.String(), step.out, step.args)
+		require.NotContains(t, stdout.String(), "sekrit")
+	}
+
+	info, err := os.Stat(path)
+	require.Nil(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	b, err := os.ReadFile(path)
+	require.Nil(t, err)
+	require.Contains(t, string(b), `"demo"`)
+	require.NotContains(t, string(b), "prod")
+}
+
+func Test_parse(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		args []string
+		pos  []string
+		typ  string
+		temp float64
+	}{
+		"flags_first": {
+			args: []string{"--type", "Binning", "--temp", "70", "LC-1"},
+			pos:  []string{"LC-1"},
+			typ:  "Binning",
+			temp: 70,
+		},
+		"flags_last": {
+			args: []string{"LC-1", "--type=Binning", "--temp", "70"},
+			pos:  []string{"LC-1"},
+			typ:  "Binning",
+			temp: 70,
+		},
+		"mixed": {
+			args: []string{"LC-1", "--type", "Binning", "LC-2", "--temp", "70", "LC-3"},
+			pos:  []string{"LC-1", "LC-2", "LC-3"},
+			typ:  "Binning",
+			temp: 70,
+		},
+		"dashes": {
+			args: []string{"LC-1", "--type", "Binning", "--", "--temp", "70"},
+			pos:  []string{"LC-1", "--temp", "70"},
+			typ:  "Binning",
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			fs := flag.NewFlagSet(name, flag.ContinueOnError)
+			typ := fs.String("type", "", "")
+			temp := fs.Float64("temp", 0, "")
+
+			pos, err := parse(fs, tc.args)
+			require.Nil(t, err)
+			require.Equal(t, tc.pos, pos)
+			require.Equal(t, tc.typ, *typ)
+			require.Equal(t, tc.temp, *temp)
+		})
+	}
+}
+
+func Test_timestamp(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		fields, from string
+		by           []string
+		result       []string
+		origin       time.Time
+		err          string
+	}{
+		"happy_path": {
+			fields: "mtime, ctime",
+			from:   "2026-03-01T12:00:00Z",
+			by:     []string{"-2day", "1 week", "+3 Hours"},
+			result: []string{"-2 day", "1 week", "3 hour"},
+			origin: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+		},
+		"date_only": {
+			fields: "ctime",
+			from:   "2026-03-01",
+			origin: time.Date(2026, 3, 1, 0, 0, 0, 0, time.Local),
+		},
+		"no_fields": {
+			err: "--fields is required",
+		},
+		"bad_from": {
+			fields: "ctime",
+			from:   "yesterday",
+			err:    `--from "yesterday"`,
+		},
+		"bad_by": {
+			fields: "ctime",
+			by:     []string{"2 fortnights"},
+			err:    `--by "2 fortnights"`,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ts, err := timestamp(tc.fields, tc.from, tc.by)
+			if tc.err != "" {
+				require.ErrorContains(t, err, tc.err)
+				return
+			}
+			require.Nil(t, err)
+			require.NotNil(t, ts.Origin)
+			require.True(t, tc.origin.Equal(*ts.Origin), "%v", ts.Origin)
+
+			var result []string
+			for _, f := range ts.Factor {
+				result = append(result, fmt.Sprintf("%d %s", f.Delta, f.Interval))
+			}
+			require.Equal(t, tc.result, result)
+			require.Nil(t, ts.Validate())
+		})
+	}
+}
+
+func Test_complete(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		words []string
+		has   []string
+		not   []string
+	}{
+		"commands": {
+			has: []string{"lifecycle", "event", "completion"},
+			not: []string{"__complete"},
+		},
+		"subcommands": {
+			words: []string{"--profile", "home", "event"},
+			has:   []string{"add", "list", "rm"},
+		},
+		"flags": {
+			words: []string{"event", "add", "LC-1"},
+			has:   []string{"--type", "--temp", "--humidity", "--format", "--server"},
+			not:   []string{"--f"},
+		},
+		"formats": {
+			words: []string{"vendor", "list", "-f"},
+			has:   []string{"table", "json", "csv"},
+		},
+		"shells": {
+			words: []string{"completion"},
+			has:   []string{"bash", "zsh"},
+		},
+		"nothing": {
+			words: []string{"grow"},
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			e, _, _ := newEnv(t, map[string]string{})
+			result := complete(e, tc.words)
+			for _, w := range tc.has {
+				require.Contains(t, result, w)
+			}
+			for _, w := range tc.not {
+				require.NotContains(t, result, w)
+			}
+			if len(tc.has) == 0 {
+				require.Empty(t, result)
+			}
+		})
+	}
+}
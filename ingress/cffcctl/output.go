@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// table is how a result looks in the table and csv formats; json is the
+// result itself, so nothing is lost
+type table struct {
+	header []string
+	rows   [][]string
+}
+
+// print writes v or t to stdout, depending on --format
+func (e *env) print(v any, t table) error {
+	if format := first(e.globals.format, "table"); format == "json" {
+		enc := json.NewEncoder(e.out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	} else if format == "csv" {
+		w := csv.NewWriter(e.out)
+		if err := w.Write(t.header); err != nil {
+			return err
+		} else if err = w.WriteAll(t.rows); err != nil {
+			return err
+		}
+		return w.Error()
+	} else if format == "table" {
+		w := tabwriter.NewWriter(e.out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, strings.Join(t.header, "\t"))
+		for _, row := range t.rows {
+			fmt.Fprintln(w, strings.Join(row, "\t"))
+		}
+		return w.Flush()
+	} else {
+		return fmt.Errorf("unsupported format: %q, use table, json or csv", format)
+	}
+}
+
+// flatten is a report as a field and its value on each row, with nested
+// fields joined by dots, like events.0.event_type.name
+func flatten(prefix string, v any, rows [][]string) [][]string {
+	join := func(k string) string {
+		if prefix == "" {
+			return k
+		}
+		return prefix + "." + k
+	}
+
+	if m, ok := v.(map[string]any); ok {
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			rows = flatten(join(k), m[k], rows)
+		}
+	} else if s, ok := v.([]any); ok {
+		for i, v := range s {
+			rows = flatten(join(strconv.Itoa(i)), v, rows)
+		}
+	} else if v == nil {
+		rows = append(rows, []string{prefix, ""})
+	} else if f, ok := v.(float64); ok {
+		rows = append(rows, []string{prefix, strconv.FormatFloat(f, 'f', -1, 64)})
+	} else {
+		rows = append(rows, []string{prefix, fmt.Sprint(v)})
+	}
+	return rows
+}
+
+func when(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+func float(f float32) string {
+	return strconv.FormatFloat(float64(f), 'f', -1, 32)
+}
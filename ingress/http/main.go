@@ -1,13 +1,15 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"os"
 	"sync"
 	"syscall"
 
 	"github.com/jsmit257/centerforfunguscontrol/internal/config"
 	"github.com/jsmit257/centerforfunguscontrol/internal/data/huautla"
-	"github.com/jsmit257/huautla/types"
+	"github.com/jsmit257/centerforfunguscontrol/internal/router"
 
 	"github.com/sirupsen/logrus"
 )
@@ -22,6 +24,9 @@ var traps = []os.Signal{
 
 func main() {
 	cfg := config.NewConfig()
+	flag.BoolVar(&cfg.Demo, "demo", cfg.Demo, "run on an in-memory database with made up data in it")
+	flag.Parse()
+
 	logger := logrus.New()
 	logger.SetLevel(logrus.DebugLevel) // TODO: grab this from the config
 	logger.SetFormatter(&logrus.JSONFormatter{})
@@ -31,25 +36,22 @@ func main() {
 		"ingress": "http",
 	})
 
-	ha, err := huautla.New(&types.Config{
-		PGHost: cfg.HuautlaHost,
-		PGPort: cfg.HuautlaPort,
-		PGUser: cfg.HuautlaUser,
-		PGPass: cfg.HuautlaPass,
-		PGSSL:  cfg.HuautlaSSL,
-	},
-		log)
+	ha, err := huautla.New(cfg, log)
 	if err != nil {
 		panic(err)
 	}
 
-	r := newHuautla(cfg, ha, log)
+	r := router.NewHuautla(cfg, ha, log)
 	newHC(r)
 
+	ctx, cancel := context.WithCancel(context.Background())
+	go ha.Run(ctx)
+
 	wg := &sync.WaitGroup{}
 	wg.Add(1)
 	go startServer(cfg, r, wg, log)
 	wg.Wait()
+	cancel()
 
 	log.Info("done, really?")
 
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -10,6 +11,7 @@ import (
 	"time"
 
 	"github.com/jsmit257/centerforfunguscontrol/internal/config"
+	"github.com/jsmit257/centerforfunguscontrol/shared/metrics"
 
 	"github.com/go-chi/chi/v5"
 
@@ -19,10 +21,19 @@ import (
 func startServer(cfg *config.Config, r *chi.Mux, wg *sync.WaitGroup, log *log.Entry) *sync.WaitGroup {
 	defer wg.Done()
 
+	// long-lived requests, like /stream, have to be told to finish or
+	// Shutdown waits for them until it times out
+	stopping, stop := context.WithCancel(context.Background())
+	defer stop()
+
 	srv := &http.Server{
 		Addr:    fmt.Sprintf("%s:%d", cfg.HTTPHost, cfg.HTTPPort),
 		Handler: r,
+		BaseContext: func(net.Listener) context.Context {
+			return context.WithValue(context.Background(), metrics.Stopping, stopping.Done())
+		},
 	}
+	srv.RegisterOnShutdown(stop)
 
 	go func(srv *http.Server) {
 		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+)
+
+type (
+	Message struct {
+		Topic     string
+		Partition int
+		Offset    int64
+		Key       []byte
+		Value     []byte
+	}
+
+	// Broker is the little bit of kafka the consumer needs: reading the
+	// command topic, committing what's been handled and publishing replies
+	Broker interface {
+		// Fetch blocks until there's a message on the command topic after the
+		// last one fetched, or ctx is done
+		Fetch(ctx context.Context) (Message, error)
+		// Commit marks m and everything before it on its partition as handled;
+		// after a restart, fetching resumes after the last commit
+		Commit(ctx context.Context, m Message) error
+		Publish(ctx context.Context, topic string, key, value []byte) error
+		Close() error
+	}
+
+	// memBroker is a single-partition Broker that lives and dies with the
+	// process
+	memBroker struct {
+		mtx sync.Mutex
+		// closed and replaced every time something is published, so fetchers
+		// know to look again
+		published chan struct{}
+		topic     string
+		topics    map[string][]Message
+		position  int64
+		committed int64
+		closed    bool
+	}
+)
+
+var errBrokerClosed = errors.New("broker is closed")
+
+func newMemBroker(topic string) *memBroker {
+	return &memBroker{
+		published: make(chan struct{}),
+		topic:     topic,
+		topics:    map[string][]Message{},
+	}
+}
+
+func (mb *memBroker) Fetch(ctx context.Context) (Message, error) {
+	for {
+		mb.mtx.Lock()
+		if mb.closed {
+			mb.mtx.Unlock()
+			return Message{}, errBrokerClosed
+		} else if msgs := mb.topics[mb.topic]; mb.position < int64(len(msgs)) {
+			m := msgs[mb.position]
+			mb.position++
+			mb.mtx.Unlock()
+			return m, nil
+		}
+		published := mb.published
+		mb.mtx.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return Message{}, ctx.Err()
+		case <-published:
+		}
+	}
+}
+
+func (mb *memBroker) Commit(_ context.Context, m Message) error {
+	mb.mtx.Lock()
+	defer mb.mtx.Unlock()
+
+	if mb.closed {
+		return errBrokerClosed
+	}
+	mb.committed = max(mb.committed, m.Offset+1)
+	return nil
+}
+
+func (mb *memBroker) Publish(_ context.Context, topic string, key, value []byte) error {
+	mb.mtx.Lock()
+	defer mb.mtx.Unlock()
+
+	if mb.closed {
+		return errBrokerClosed
+	}
+
+	mb.topics[topic] = append(mb.topics[topic], Message{
+		Topic:  topic,
+		Offset: int64(len(mb.topics[topic])),
+		Key:    slices.Clone(key),
+		Value:  slices.Clone(value),
+	})
+
+	close(mb.published)
+	mb.published = make(chan struct{})
+
+	return nil
+}
+
+func (mb *memBroker) Close() error {
+	mb.mtx.Lock()
+	defer mb.mtx.Unlock()
+
+	if !mb.closed {
+		mb.closed = true
+		close(mb.published)
+	}
+	return nil
+}
+
+// rewind goes back to the last commit, the way a consumer group does when
+// its consumer restarts
+func (mb *memBroker) rewind() {
+	mb.mtx.Lock()
+	defer mb.mtx.Unlock()
+	mb.position = mb.committed
+}
+
+// messages is a copy of everything published to topic so far
+func (mb *memBroker) messages(topic string) []Message {
+	mb.mtx.Lock()
+	defer mb.mtx.Unlock()
+	return slices.Clone(mb.topics[topic])
+}
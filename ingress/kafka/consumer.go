@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/jsmit257/huautla/types"
+)
+
+type (
+	// command is an http request in a kafka message
+	command struct {
+		Method  string            `json:"method"`
+		Route   string            `json:"route"`
+		Headers map[string]string `json:"headers,omitempty"`
+		Body    json.RawMessage   `json:"body,omitempty"`
+		ReplyTo string            `json:"reply_to,omitempty"`
+		Cid     types.CID         `json:"cid,omitempty"`
+	}
+
+	// reply is the response to a command, published with the command's cid as
+	// its key
+	reply struct {
+		Cid         types.CID       `json:"cid"`
+		Status      int             `json:"status"`
+		ContentType string          `json:"content_type,omitempty"`
+		Body        json.RawMessage `json:"body,omitempty"`
+		// response bodies that aren't json, like timelapses, are base64 here
+		Data []byte `json:"data,omitempty"`
+	}
+
+	consumer struct {
+		broker  Broker
+		handler http.Handler
+		// where replies go when a command doesn't say
+		replies string
+		log     *logrus.Entry
+	}
+)
+
+// run handles commands until ctx is done; a message is only committed once its
+// reply is published, so anything in flight when run stops or fails is
+// handled again on the next start
+func (c *consumer) run(ctx context.Context) error {
+	for {
+		m, err := c.broker.Fetch(ctx)
+		if ctx.Err() != nil {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("fetching command: %w", err)
+		} else if err = c.handle(ctx, m); err != nil {
+			return err
+		} else if err = c.broker.Commit(ctx, m); err != nil {
+			return fmt.Errorf("committing offset %d: %w", m.Offset, err)
+		}
+	}
+}
+
+func (c *consumer) handle(ctx context.Context, m Message) error {
+	// let a command that's already started finish, even when we're stopping
+	ctx = context.WithoutCancel(ctx)
+
+	l := c.log.WithFields(logrus.Fields{
+		"partition": m.Partition,
+		"offset":    m.Offset,
+	})
+
+	var cmd command
+	if err := json.Unmarshal(m.Value, &cmd); err != nil {
+		// there's nobody to reply to, and it won't parse any better next time
+		l.WithError(err).Error("skipping malformed command")
+		return nil
+	} else if cmd.Cid == "" {
+		cmd.Cid = types.CID(uuid.NewString())
+	}
+
+	l = l.WithField("cid", cmd.Cid)
+
+	r, err := cmd.request(ctx)
+	if err != nil {
+		l.WithError(err).Warn("invalid command")
+	}
+
+	data, err := json.Marshal(c.dispatch(r, cmd.Cid, err))
+	if err != nil {
+		return fmt.Errorf("marshaling reply: %w", err)
+	}
+
+	topic := cmd.ReplyTo
+	if topic == "" {
+		topic = c.replies
+	}
+
+	if err = c.broker.Publish(ctx, topic, []byte(cmd.Cid), data); err != nil {
+		return fmt.Errorf("publishing reply to %s: %w", topic, err)
+	}
+
+	l.WithField("topic", topic).Info("replied")
+
+	return nil
+}
+
+func (cmd command) request(ctx context.Context) (*http.Request, error) {
+	if cmd.Method == "" {
+		return nil, fmt.Errorf("missing method")
+	} else if !strings.HasPrefix(cmd.Route, "/") {
+		return nil, fmt.Errorf("route must start with a slash: %q", cmd.Route)
+	}
+
+	r, err := http.NewRequestWithContext(ctx, strings.ToUpper(cmd.Method), cmd.Route, bytes.NewReader(cmd.Body))
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range cmd.Headers {
+		r.Header.Set(k, v)
+	}
+	if len(cmd.Body) > 0 && r.Header.Get("Content-Type") == "" {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	r.Header.Set("Cid", string(cmd.Cid))
+	r.RemoteAddr = "kafka"
+	r.RequestURI = cmd.Route
+
+	return r, nil
+}
+
+// dispatch runs the request through the router and captures the response;
+// requests that couldn't be built get the same 400 the router would give
+func (c *consumer) dispatch(r *http.Request, cid types.CID, err error) reply {
+	result := reply{Cid: cid}
+	if err != nil {
+		result.Status = http.StatusBadRequest
+		result.ContentType = "text/plain"
+		result.Data = []byte(err.Error())
+		return result
+	}
+
+	w := httptest.NewRecorder()
+	c.handler.ServeHTTP(w, r)
+
+	result.Status = w.Code
+	result.ContentType = w.Header().Get("Content-Type")
+	if w.Body.Len() == 0 {
+	} else if mt, _, _ := mime.ParseMediaType(result.ContentType); mt == "application/json" && json.Valid(w.Body.Bytes()) {
+		result.Body = w.Body.Bytes()
+	} else {
+		result.Data = w.Body.Bytes()
+	}
+
+	return result
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/centerforfunguscontrol/shared/metrics"
+	"github.com/jsmit257/huautla/types"
+)
+
+// flakyBroker fails to publish the first few replies
+type flakyBroker struct {
+	*memBroker
+	failures int
+}
+
+func (fb *flakyBroker) Publish(ctx context.Context, topic string, key, value []byte) error {
+	if fb.failures > 0 {
+		fb.failures--
+		return fmt.Errorf("broker is having a bad day")
+	}
+	return fb.memBroker.Publish(ctx, topic, key, value)
+}
+
+func testRouter() http.Handler {
+	r := chi.NewRouter()
+	r.Use(metrics.WrapContext(logrus.WithField("test", "kafka")))
+	r.Get("/vendor/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"id":%q,"cid":%q}`, chi.URLParam(r, "id"), metrics.GetContextCID(r.Context()))
+	})
+	r.Post("/vendor", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write(body)
+	})
+	r.Get("/hc", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("OK"))
+	})
+	return r
+}
+
+func Test_consumer(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		cmd     string
+		topic   string
+		replies int
+		reply   reply
+	}{
+		"happy_path": {
+			cmd:     `{"method":"get","route":"/vendor/0","cid":"happy_path"}`,
+			topic:   "replies",
+			replies: 1,
+			reply: reply{
+				Cid:         "happy_path",
+				Status:      http.StatusOK,
+				ContentType: "application/json",
+				Body:        json.RawMessage(`{"id":"0","cid":"happy_path"}`),
+			},
+		},
+		"reply_to": {
+			cmd:     `{"method":"POST","route":"/vendor","body":{"name":"vendor"},"reply_to":"mine","cid":"reply_to"}`,
+			topic:   "mine",
+			replies: 1,
+			reply: reply{
+				Cid:         "reply_to",
+				Status:      http.StatusCreated,
+				ContentType: "application/json",
+				Body:        json.RawMessage(`{"name":"vendor"}`),
+			},
+		},
+		"not_json": {
+			cmd:     `{"method":"GET","route":"/hc","cid":"not_json"}`,
+			topic:   "replies",
+			replies: 1,
+			reply: reply{
+				Cid:         "not_json",
+				Status:      http.StatusOK,
+				ContentType: "text/plain; charset=utf-8",
+				Data:        []byte("OK"),
+			},
+		},
+		"not_found": {
+			cmd:     `{"method":"GET","route":"/nothing","cid":"not_found"}`,
+			topic:   "replies",
+			replies: 1,
+			reply: reply{
+				Cid:         "not_found",
+				Status:      http.StatusNotFound,
+				ContentType: "text/plain; charset=utf-8",
+				Data:        []byte("404 page not found\n"),
+			},
+		},
+		"invalid_route": {
+			cmd:     `{"method":"GET","route":"vendor/0","cid":"invalid_route"}`,
+			topic:   "replies",
+			replies: 1,
+			reply: reply{
+				Cid:         "invalid_route",
+				Status:      http.StatusBadRequest,
+				ContentType: "text/plain",
+				Data:        []byte(`route must start with a slash: "vendor/0"`),
+			},
+		},
+		"malformed_command": {
+			cmd:   `{"method":`,
+			topic: "replies",
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			b := newMemBroker("commands")
+			c := &consumer{
+				broker:  b,
+				handler: testRouter(),
+				replies: "replies",
+				log:     logrus.WithField("test", name),
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error)
+			go func() { done <- c.run(ctx) }()
+
+			require.Nil(t, b.Publish(ctx, "commands", nil, []byte(tc.cmd)))
+			require.Eventually(t, func() bool {
+				b.mtx.Lock()
+				defer b.mtx.Unlock()
+				return b.committed == 1
+			}, time.Second, time.Millisecond)
+
+			cancel()
+			require.Nil(t, <-done)
+
+			msgs := b.messages(tc.topic)
+			require.Len(t, msgs, tc.replies)
+			if tc.replies == 0 {
+				return
+			}
+
+			var r reply
+			require.Nil(t, json.Unmarshal(msgs[0].Value, &r))
+			require.Equal(t, tc.reply, r)
+			require.Equal(t, string(tc.reply.Cid), string(msgs[0].Key))
+		})
+	}
+}
+
+func Test_consumerCommitsAfterSuccess(t *testing.T) {
+	t.Parallel()
+
+	b := &flakyBroker{memBroker: newMemBroker("commands"), failures: 1}
+	c := &consumer{
+		broker:  b,
+		handler: testRouter(),
+		replies: "replies",
+		log:     logrus.WithField("test", "Test_consumerCommitsAfterSuccess"),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	require.Nil(t, b.memBroker.Publish(ctx, "commands", nil, []byte(`{"method":"GET","route":"/vendor/1","cid":"first"}`)))
+	require.Nil(t, b.memBroker.Publish(ctx, "commands", nil, []byte(`{"method":"GET","route":"/vendor/2","cid":"second"}`)))
+
+	// the first reply can't be published, so nothing is committed...
+	require.ErrorContains(t, c.run(ctx), "bad day")
+	require.Equal(t, int64(0), b.committed)
+	require.Empty(t, b.messages("replies"))
+
+	// ...and after a restart the first command is handled again
+	b.rewind()
+	done := make(chan error)
+	go func() { done <- c.run(ctx) }()
+
+	require.Eventually(t, func() bool {
+		return len(b.messages("replies")) == 2
+	}, time.Second, time.Millisecond)
+	cancel()
+	require.Nil(t, <-done)
+
+	var cids []types.CID
+	for _, m := range b.messages("replies") {
+		cids = append(cids, types.CID(m.Key))
+	}
+	require.Equal(t, []types.CID{"first", "second"}, cids)
+	require.Equal(t, int64(2), b.committed)
+}
+
+func Test_memBroker(t *testing.T) {
+	t.Parallel()
+
+	b := newMemBroker("topic")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := b.Fetch(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	fetched := make(chan Message)
+	go func() {
+		m, _ := b.Fetch(context.Background())
+		fetched <- m
+	}()
+	require.Nil(t, b.Publish(context.Background(), "other", nil, []byte("ignored")))
+	require.Nil(t, b.Publish(context.Background(), "topic", []byte("key"), []byte("value")))
+	m := <-fetched
+	require.Equal(t, Message{Topic: "topic", Key: []byte("key"), Value: []byte("value")}, m)
+
+	require.Nil(t, b.Close())
+	_, err = b.Fetch(context.Background())
+	require.ErrorIs(t, err, errBrokerClosed)
+	require.ErrorIs(t, b.Publish(context.Background(), "topic", nil, nil), errBrokerClosed)
+	require.ErrorIs(t, b.Commit(context.Background(), m), errBrokerClosed)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/config"
+)
+
+// kafkaBroker is a Broker for a real cluster; reads belong to the consumer
+// group, so offsets are tracked per partition by kafka and commits are only
+// ever explicit
+type kafkaBroker struct {
+	r *kafka.Reader
+	w *kafka.Writer
+}
+
+func dial(cfg *config.Config) (Broker, error) {
+	if len(cfg.KafkaBrokers) == 0 {
+		return nil, fmt.Errorf("no kafka brokers configured")
+	}
+
+	return &kafkaBroker{
+		r: kafka.NewReader(kafka.ReaderConfig{
+			Brokers: cfg.KafkaBrokers,
+			GroupID: cfg.KafkaGroup,
+			Topic:   cfg.KafkaTopic,
+		}),
+		w: &kafka.Writer{
+			Addr: kafka.TCP(cfg.KafkaBrokers...),
+			// replies for the same cid always land on the same partition
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+		},
+	}, nil
+}
+
+func (kb *kafkaBroker) Fetch(ctx context.Context) (Message, error) {
+	m, err := kb.r.FetchMessage(ctx)
+	if err != nil {
+		return Message{}, err
+	}
+	return Message{
+		Topic:     m.Topic,
+		Partition: m.Partition,
+		Offset:    m.Offset,
+		Key:       m.Key,
+		Value:     m.Value,
+	}, nil
+}
+
+func (kb *kafkaBroker) Commit(ctx context.Context, m Message) error {
+	return kb.r.CommitMessages(ctx, kafka.Message{
+		Topic:     m.Topic,
+		Partition: m.Partition,
+		Offset:    m.Offset,
+	})
+}
+
+func (kb *kafkaBroker) Publish(ctx context.Context, topic string, key, value []byte) error {
+	return kb.w.WriteMessages(ctx, kafka.Message{
+		Topic: topic,
+		Key:   key,
+		Value: value,
+	})
+}
+
+func (kb *kafkaBroker) Close() error {
+	return errors.Join(kb.r.Close(), kb.w.Close())
+}
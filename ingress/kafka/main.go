@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/config"
+	"github.com/jsmit257/centerforfunguscontrol/internal/data/huautla"
+	"github.com/jsmit257/centerforfunguscontrol/internal/router"
+)
+
+var traps = []os.Signal{
+	syscall.SIGINT,
+	syscall.SIGHUP,
+	syscall.SIGTERM,
+	syscall.SIGQUIT,
+}
+
+func main() {
+	cfg := config.NewConfig()
+	logger := logrus.New()
+	logger.SetLevel(logrus.DebugLevel) // TODO: grab this from the config
+	logger.SetFormatter(&logrus.JSONFormatter{})
+
+	log := logger.WithFields(logrus.Fields{
+		"app":     "cffc",
+		"ingress": "kafka",
+	})
+
+	if err := run(cfg, log); err != nil {
+		log.WithError(err).Fatal("consumer stopped")
+	}
+
+	log.Info("done, really?")
+}
+
+// run is everything that has to be closed before main exits, so a failure
+// gets to close it once on the way out
+func run(cfg *config.Config, log *logrus.Entry) error {
+	ha, err := huautla.New(cfg, log)
+	if err != nil {
+		return err
+	}
+
+	b, err := dial(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to connect to kafka: %w", err)
+	}
+	defer b.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), traps...)
+	defer stop()
+
+	c := &consumer{
+		broker:  b,
+		handler: router.NewHuautla(cfg, ha, log),
+		replies: cfg.KafkaReplyTopic,
+		log:     log.WithField("topic", cfg.KafkaTopic),
+	}
+
+	log.Info("consuming commands, waiting for traps")
+
+	return c.run(ctx)
+}
@@ -0,0 +1,311 @@
+// Package archive is what an export looks like: a gzipped tarball with the
+// manifest first, then everything in the database as json, then cffc's own
+// data from the store, then the album and the attachments. Files come last
+// so a restore knows everything it's restoring before it has to put files
+// anywhere
+package archive
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/jsmit257/huautla/types"
+)
+
+type (
+	Manifest struct {
+		Format  string    `json:"format"`
+		Version int       `json:"version"`
+		Created time.Time `json:"created"`
+		// which kind of database it came from
+		Database string         `json:"database,omitempty"`
+		Counts   map[string]int `json:"counts"`
+		// files something referred to that weren't there to export
+		Missing []string `json:"missing,omitempty"`
+	}
+
+	// Snapshot is everything there is to restore, apart from files; rows
+	// refer to each other by id, the way they do in the database
+	Snapshot struct {
+		Stages      []types.Stage
+		EventTypes  []types.EventType
+		Ingredients []types.Ingredient
+		Vendors     []types.Vendor
+		// with their ingredients
+		Substrates []types.Substrate
+		// with their attributes
+		Strains []types.Strain
+		// with their events and sources
+		Generations []types.Generation
+		// with their events
+		Lifecycles []types.Lifecycle
+		// generation => the strain it made
+		Generated map[types.UUID]types.UUID
+		// owner => its notes and photos, newest first
+		Notes  map[types.UUID][]types.Note
+		Photos map[types.UUID][]types.Photo
+		// table => key => value
+		Store map[string]map[string]json.RawMessage
+	}
+
+	// File is something on disk that goes in the archive, under dir
+	File struct {
+		Dir, Name, Path string
+	}
+
+	// Sink is where a restore puts each file
+	Sink func(dir, name string, r io.Reader) error
+
+	part struct {
+		name string
+		v    any
+	}
+)
+
+const (
+	Format  = "cffc-export"
+	Version = 1
+
+	manifestName = "manifest.json"
+	entitiesDir  = "entities"
+	storeDir     = "store"
+)
+
+// Dirs are where files can go
+var Dirs = []string{"album", "attachments"}
+
+var (
+	ErrFormat  = errors.New("not an export")
+	ErrVersion = errors.New("unsupported export version")
+)
+
+// parts are the snapshot's entities, in the order they're written
+func (s *Snapshot) parts() []part {
+	return []part{
+		{"stages", &s.Stages},
+		{"eventtypes", &s.EventTypes},
+		{"ingredients", &s.Ingredients},
+		{"vendors", &s.Vendors},
+		{"substrates", &s.Substrates},
+		{"strains", &s.Strains},
+		{"generations", &s.Generations},
+		{"lifecycles", &s.Lifecycles},
+		{"generated", &s.Generated},
+		{"notes", &s.Notes},
+		{"photos", &s.Photos},
+	}
+}
+
+// Counts is how many of everything there is
+func (s *Snapshot) Counts() map[string]int {
+	result := map[string]int{
+		"stages":      len(s.Stages),
+		"eventtypes":  len(s.EventTypes),
+		"ingredients": len(s.Ingredients),
+		"vendors":     len(s.Vendors),
+		"substrates":  len(s.Substrates),
+		"strains":     len(s.Strains),
+		"generations": len(s.Generations),
+		"lifecycles":  len(s.Lifecycles),
+	}
+	for _, ns := range s.Notes {
+		result["notes"] += len(ns)
+	}
+	for _, ps := range s.Photos {
+		result["photos"] += len(ps)
+	}
+	for _, g := range s.Generations {
+		result["events"] += len(g.Events)
+	}
+	for _, l := range s.Lifecycles {
+		result["events"] += len(l.Events)
+	}
+	return result
+}
+
+// Write writes m, s and whichever files are there to w; files that aren't
+// there are listed in the manifest rather than failing the whole thing
+func Write(w io.Writer, m Manifest, s Snapshot, files []File) error {
+	m.Format, m.Version = Format, Version
+	if m.Created.IsZero() {
+		m.Created = time.Now().UTC()
+	}
+	m.Counts = s.Counts()
+
+	present := make([]File, 0, len(files))
+	for _, f := range files {
+		if fi, err := os.Stat(f.Path); err != nil || !fi.Mode().IsRegular() {
+			m.Missing = append(m.Missing, path.Join(f.Dir, f.Name))
+		} else {
+			present = append(present, f)
+		}
+	}
+	m.Counts["files"] = len(present)
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	if err := writeJSON(tw, manifestName, m, m.Created); err != nil {
+		return err
+	}
+	for _, p := range s.parts() {
+		if err := writeJSON(tw, path.Join(entitiesDir, p.name+".json"), p.v, m.Created); err != nil {
+			return err
+		}
+	}
+	for table, rows := range s.Store {
+		if err := writeJSON(tw, path.Join(storeDir, table+".json"), rows, m.Created); err != nil {
+			return err
+		}
+	}
+	for _, f := range present {
+		if err := writeFile(tw, f); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func writeJSON(tw *tar.Writer, name string, v any, mtime time.Time) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	} else if err = tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: mtime,
+	}); err != nil {
+		return err
+	}
+	_, err = tw.Write(data)
+	return err
+}
+
+func writeFile(tw *tar.Writer, f File) error {
+	r, err := os.Open(f.Path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	fi, err := r.Stat()
+	if err != nil {
+		return err
+	} else if err = tw.WriteHeader(&tar.Header{
+		Name:    path.Join(f.Dir, f.Name),
+		Mode:    0644,
+		Size:    fi.Size(),
+		ModTime: fi.ModTime(),
+	}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, r)
+	return err
+}
+
+// Read reads an archive Write wrote; restore gets the manifest and the
+// snapshot as soon as they've been read, and says where the files go. A nil
+// sink skips them, which is still enough to know the archive is whole
+func Read(r io.Reader, restore func(Manifest, Snapshot) (Sink, error)) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrFormat, err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+
+	var m Manifest
+	if h, err := tr.Next(); err != nil {
+		return fmt.Errorf("%w: %w", ErrFormat, err)
+	} else if h.Name != manifestName {
+		return fmt.Errorf("%w: %s comes before the manifest", ErrFormat, h.Name)
+	} else if err = json.NewDecoder(tr).Decode(&m); err != nil {
+		return fmt.Errorf("%w: manifest: %w", ErrFormat, err)
+	} else if m.Format != Format {
+		return fmt.Errorf("%w: format is %q", ErrFormat, m.Format)
+	} else if m.Version < 1 || m.Version > Version {
+		return fmt.Errorf("%w: %d", ErrVersion, m.Version)
+	}
+
+	s := Snapshot{Store: map[string]map[string]json.RawMessage{}}
+	parts := map[string]any{}
+	for _, p := range s.parts() {
+		parts[path.Join(entitiesDir, p.name+".json")] = p.v
+	}
+
+	var sink Sink
+	restored := false
+	for {
+		h, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return fmt.Errorf("%w: %w", ErrFormat, err)
+		}
+
+		dir, name := path.Split(h.Name)
+		dir = strings.TrimSuffix(dir, "/")
+
+		if v, ok := parts[h.Name]; ok && !restored {
+			if err = json.NewDecoder(tr).Decode(v); err != nil {
+				return fmt.Errorf("%w: %s: %w", ErrFormat, h.Name, err)
+			}
+			continue
+		} else if table, ok := strings.CutSuffix(name, ".json"); ok && dir == storeDir && !restored {
+			var rows map[string]json.RawMessage
+			if err = json.NewDecoder(tr).Decode(&rows); err != nil {
+				return fmt.Errorf("%w: %s: %w", ErrFormat, h.Name, err)
+			}
+			s.Store[table] = rows
+			continue
+		} else if !isFile(dir, name) {
+			return fmt.Errorf("%w: unexpected %s", ErrFormat, h.Name)
+		}
+
+		if !restored {
+			if sink, err = restore(m, s); err != nil {
+				return err
+			}
+			restored = true
+		}
+		if sink == nil {
+			if _, err = io.Copy(io.Discard, tr); err != nil {
+				return fmt.Errorf("%w: %s: %w", ErrFormat, h.Name, err)
+			}
+		} else if err = sink(dir, name, tr); err != nil {
+			return fmt.Errorf("%s: %w", h.Name, err)
+		}
+	}
+
+	if !restored {
+		if _, err = restore(m, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isFile is whether dir and name are somewhere a file can go; anything
+// with more to its path than that is refused, rather than written who
+// knows where
+func isFile(dir, name string) bool {
+	for _, d := range Dirs {
+		if d == dir {
+			return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+		}
+	}
+	return false
+}
@@ -0,0 +1,156 @@
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/huautla/types"
+)
+
+func Test_roundtrip(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "photo.png"), []byte("png"), 0644))
+
+	s := Snapshot{
+		Stages:  []types.Stage{{UUID: "any", Name: "Any"}},
+		Vendors: []types.Vendor{{UUID: "v0", Name: "North Spore"}},
+		Lifecycles: []types.Lifecycle{{
+			UUID:   "lc0",
+			Events: []types.Event{{UUID: "e0"}, {UUID: "e1"}},
+		}},
+		Generated: map[types.UUID]types.UUID{"g0": "s0"},
+		Notes:     map[types.UUID][]types.Note{"lc0": {{UUID: "n0", Note: "looks good"}}},
+		Photos:    map[types.UUID][]types.Photo{"e0": {{UUID: "p0", Filename: "photo.png"}}},
+		Store: map[string]map[string]json.RawMessage{
+			"photos": {"p0": json.RawMessage(`{"owner":"e0"}`)},
+		},
+	}
+	created := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	buf := &bytes.Buffer{}
+	require.Nil(t, Write(buf, Manifest{Created: created, Database: "memory"}, s, []File{
+		{Dir: "album", Name: "photo.png", Path: filepath.Join(dir, "photo.png")},
+		{Dir: "album", Name: "gone.png", Path: filepath.Join(dir, "gone.png")},
+	}))
+
+	files := map[string]string{}
+	require.Nil(t, Read(buf, func(m Manifest, got Snapshot) (Sink, error) {
+		require.Equal(t, Format, m.Format)
+		require.Equal(t, Version, m.Version)
+		require.Equal(t, created, m.Created)
+		require.Equal(t, "memory", m.Database)
+		require.Equal(t, []string{"album/gone.png"}, m.Missing)
+		require.Equal(t, 1, m.Counts["lifecycles"])
+		require.Equal(t, 2, m.Counts["events"])
+		require.Equal(t, 1, m.Counts["files"])
+		require.Equal(t, s, got)
+
+		return func(dir, name string, r io.Reader) error {
+			data, err := io.ReadAll(r)
+			files[dir+"/"+name] = string(data)
+			return err
+		}, nil
+	}))
+	require.Equal(t, map[string]string{"album/photo.png": "png"}, files)
+}
+
+func Test_Read(t *testing.T) {
+	t.Parallel()
+
+	manifest := func(format string, version int) string {
+		data, _ := json.Marshal(Manifest{Format: format, Version: version})
+		return string(data)
+	}
+
+	type entry struct{ name, body string }
+
+	tcs := map[string]struct {
+		entries []entry
+		err     error
+	}{
+		"happy_path": {
+			entries: []entry{
+				{manifestName, manifest(Format, Version)},
+				{"entities/vendors.json", `[{"id":"v0","name":"In house"}]`},
+				{"album/photo.png", "png"},
+			},
+		},
+		"no_manifest": {
+			entries: []entry{{"entities/vendors.json", `[]`}},
+			err:     ErrFormat,
+		},
+		"wrong_format": {
+			entries: []entry{{manifestName, manifest("tarball", Version)}},
+			err:     ErrFormat,
+		},
+		"newer_version": {
+			entries: []entry{{manifestName, manifest(Format, Version+1)}},
+			err:     ErrVersion,
+		},
+		"escaping_path": {
+			entries: []entry{
+				{manifestName, manifest(Format, Version)},
+				{"album/../../etc/passwd", "root"},
+			},
+			err: ErrFormat,
+		},
+		"unknown_dir": {
+			entries: []entry{
+				{manifestName, manifest(Format, Version)},
+				{"elsewhere/photo.png", "png"},
+			},
+			err: ErrFormat,
+		},
+		"entities_after_files": {
+			entries: []entry{
+				{manifestName, manifest(Format, Version)},
+				{"album/photo.png", "png"},
+				{"entities/vendors.json", `[]`},
+			},
+			err: ErrFormat,
+		},
+		"malformed_entities": {
+			entries: []entry{
+				{manifestName, manifest(Format, Version)},
+				{"entities/vendors.json", `{`},
+			},
+			err: ErrFormat,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			buf := &bytes.Buffer{}
+			gz := gzip.NewWriter(buf)
+			tw := tar.NewWriter(gz)
+			for _, e := range tc.entries {
+				require.Nil(t, tw.WriteHeader(&tar.Header{Name: e.name, Mode: 0644, Size: int64(len(e.body))}))
+				_, err := tw.Write([]byte(e.body))
+				require.Nil(t, err)
+			}
+			require.Nil(t, tw.Close())
+			require.Nil(t, gz.Close())
+
+			err := Read(buf, func(Manifest, Snapshot) (Sink, error) { return nil, nil })
+			if tc.err == nil {
+				require.Nil(t, err)
+			} else {
+				require.ErrorIs(t, err, tc.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,458 @@
+// Package backup takes exports on a schedule, so nobody has to remember to;
+// each one has a sha256 next to it, is read back and checked as soon as it's
+// written, and old ones are thinned out to a few a day, a week and a month
+package backup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/archive"
+	"github.com/jsmit257/centerforfunguscontrol/shared/metrics"
+)
+
+type (
+	Config struct {
+		// Every is how often to take a backup
+		Every time.Duration
+		// At is a time of day, like 03:00, that backups are taken at and
+		// every Every from; without it, they're taken Every after the last
+		// one
+		At   string
+		Keep Policy
+	}
+
+	// Policy is how many backups to keep: the newest one from each of the
+	// last Daily days, Weekly weeks and Monthly months that have one. A
+	// backup only has to be wanted by one of them to be kept, the newest is
+	// always kept, and nothing is ever removed if they're all zero
+	Policy struct {
+		Daily, Weekly, Monthly int
+	}
+
+	Backup struct {
+		Name    string    `json:"name"`
+		Created time.Time `json:"created"`
+		Size    int64     `json:"size"`
+		SHA256  string    `json:"sha256"`
+	}
+
+	Status struct {
+		Running     bool       `json:"running"`
+		LastAttempt *time.Time `json:"last_attempt,omitempty"`
+		LastSuccess *time.Time `json:"last_success,omitempty"`
+		LastError   string     `json:"last_error,omitempty"`
+		Next        *time.Time `json:"next,omitempty"`
+		Backups     []Backup   `json:"backups"`
+	}
+
+	// Exporter writes an export to w
+	Exporter func(ctx context.Context, w io.Writer) error
+
+	Scheduler struct {
+		dest   Destination
+		export Exporter
+		cfg    Config
+		log    *logrus.Entry
+		now    func() time.Time
+
+		// only one backup at a time, whether it's scheduled or asked for
+		running sync.Mutex
+		wake    chan struct{}
+
+		mtx    sync.Mutex
+		status Status
+	}
+)
+
+const (
+	prefix = "cffc-backup-"
+	suffix = ".tar.gz"
+	// sums are in sha256sum's format, so `sha256sum -c` works on them too
+	sumSuffix = ".sha256"
+	// names sort in the order they were taken
+	stampFormat = "20060102T150405Z"
+
+	// how long to wait after a backup fails before trying again, unless
+	// backups are more often than that
+	retryAfter = 10 * time.Minute
+)
+
+var (
+	ErrRunning  = errors.New("a backup is already running")
+	ErrChecksum = errors.New("backup doesn't match its checksum")
+	ErrNotFound = errors.New("no such backup")
+)
+
+func NewScheduler(dest Destination, export Exporter, cfg Config, log *logrus.Entry) (*Scheduler, error) {
+	if cfg.Every <= 0 {
+		return nil, fmt.Errorf("backups need to be taken every so often: %s", cfg.Every)
+	} else if _, err := parseAt(cfg.At); err != nil {
+		return nil, err
+	} else if cfg.Keep.Daily < 0 || cfg.Keep.Weekly < 0 || cfg.Keep.Monthly < 0 {
+		return nil, fmt.Errorf("can't keep fewer than no backups: %+v", cfg.Keep)
+	}
+
+	return &Scheduler{
+		dest:   dest,
+		export: export,
+		cfg:    cfg,
+		log:    log.WithField("component", "backup"),
+		now:    time.Now,
+		wake:   make(chan struct{}, 1),
+	}, nil
+}
+
+// parseAt is how far into the day at is; it's -1 if there isn't one
+func parseAt(at string) (time.Duration, error) {
+	if at == "" {
+		return -1, nil
+	} else if t, err := time.Parse("15:04", at); err != nil {
+		return 0, fmt.Errorf("backups are taken at a time like 03:00: %q", at)
+	} else {
+		return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+	}
+}
+
+// Run takes backups when they're due until ctx is done
+func (s *Scheduler) Run(ctx context.Context) {
+	for {
+		next := s.next()
+
+		s.mtx.Lock()
+		s.status.Next = &next
+		s.mtx.Unlock()
+
+		t := time.NewTimer(next.Sub(s.now()))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-s.wake:
+			// a backup was taken in the meantime, so the next one is later
+			t.Stop()
+			continue
+		case <-t.C:
+		}
+
+		if _, err := s.backup(ctx); err != nil && !errors.Is(err, ErrRunning) {
+			s.log.WithError(err).Error("failed to take scheduled backup")
+		}
+	}
+}
+
+// next is when the next backup is due; a backup that was missed while the
+// server was down is due now, and one that failed is tried again soon
+func (s *Scheduler) next() time.Time {
+	s.mtx.Lock()
+	var last, attempt time.Time
+	if s.status.LastSuccess != nil {
+		last = *s.status.LastSuccess
+	}
+	if s.status.LastAttempt != nil {
+		attempt = *s.status.LastAttempt
+	}
+	s.mtx.Unlock()
+
+	now := s.now()
+	if last.IsZero() {
+		if backups, err := s.List(context.Background()); err == nil && len(backups) > 0 {
+			last = backups[0].Created
+		}
+	}
+
+	var result time.Time
+	if at, _ := parseAt(s.cfg.At); at < 0 && last.IsZero() {
+		result = now
+	} else if at < 0 {
+		result = last.Add(s.cfg.Every)
+	} else {
+		// the first slot after the last backup, or after now if there's
+		// never been one
+		from := last
+		if from.IsZero() {
+			from = now
+		}
+		y, m, d := from.Date()
+		anchor := time.Date(y, m, d, 0, 0, 0, 0, from.Location()).Add(at)
+		k := from.Sub(anchor) / s.cfg.Every
+		if result = anchor.Add(k * s.cfg.Every); !result.After(from) {
+			result = result.Add(s.cfg.Every)
+		}
+	}
+
+	if attempt.After(last) {
+		retry := attempt.Add(min(retryAfter, s.cfg.Every))
+		if retry.After(result) {
+			result = retry
+		}
+	}
+	return result
+}
+
+// Backup takes a backup now, unless one is already being taken
+func (s *Scheduler) Backup(ctx context.Context) (Backup, error) {
+	result, err := s.backup(ctx)
+	if !errors.Is(err, ErrRunning) {
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+	return result, err
+}
+
+func (s *Scheduler) backup(ctx context.Context) (Backup, error) {
+	if !s.running.TryLock() {
+		return Backup{}, ErrRunning
+	}
+	defer s.running.Unlock()
+
+	start := s.now().UTC()
+	s.mtx.Lock()
+	s.status.Running = true
+	s.status.LastAttempt = &start
+	s.mtx.Unlock()
+
+	result, err := s.take(ctx, start)
+
+	s.mtx.Lock()
+	s.status.Running = false
+	if err != nil {
+		s.status.LastError = err.Error()
+	} else {
+		s.status.LastError = ""
+		s.status.LastSuccess = &start
+	}
+	s.mtx.Unlock()
+
+	if err != nil {
+		metrics.BackupRuns.WithLabelValues("failed").Inc()
+		return result, err
+	}
+
+	metrics.BackupRuns.WithLabelValues("ok").Inc()
+	metrics.BackupStatus.WithLabelValues("last_success").Set(float64(start.Unix()))
+	metrics.BackupStatus.WithLabelValues("size_bytes").Set(float64(result.Size))
+	metrics.BackupStatus.WithLabelValues("duration_seconds").Set(s.now().Sub(start).Seconds())
+	s.log.WithFields(logrus.Fields{"name": result.Name, "size": result.Size}).Info("took backup")
+
+	if err = s.prune(ctx); err != nil {
+		// the backup's fine; the old ones go next time
+		s.log.WithError(err).Error("failed to remove old backups")
+	}
+	return result, nil
+}
+
+// take writes the export, then its sum, then reads it back to make sure
+// they match and that it's an export all the way through; a backup that
+// doesn't pass is removed
+func (s *Scheduler) take(ctx context.Context, start time.Time) (Backup, error) {
+	result := Backup{Name: prefix + start.Format(stampFormat) + suffix, Created: start.Truncate(time.Second)}
+	metrics.BackupStatus.WithLabelValues("last_attempt").Set(float64(start.Unix()))
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(s.export(ctx, pw))
+	}()
+
+	h := sha256.New()
+	counter := &counter{w: h}
+	if err := s.dest.Put(ctx, result.Name, io.TeeReader(pr, counter)); err != nil {
+		pr.CloseWithError(err)
+		return result, fmt.Errorf("writing %s: %w", result.Name, err)
+	}
+	result.SHA256, result.Size = hex.EncodeToString(h.Sum(nil)), counter.n
+
+	sum := fmt.Sprintf("%s  %s\n", result.SHA256, result.Name)
+	if err := s.dest.Put(ctx, result.Name+sumSuffix, strings.NewReader(sum)); err != nil {
+		_ = s.dest.Delete(ctx, result.Name)
+		return result, fmt.Errorf("writing sum of %s: %w", result.Name, err)
+	} else if err = s.Verify(ctx, result.Name); err != nil {
+		_ = s.dest.Delete(ctx, result.Name)
+		_ = s.dest.Delete(ctx, result.Name+sumSuffix)
+		return result, err
+	}
+	return result, nil
+}
+
+// Verify reads name back and checks it against its sum, and that it's an
+// export this server could import
+func (s *Scheduler) Verify(ctx context.Context, name string) error {
+	want, err := s.sum(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	r, err := s.dest.Get(ctx, name)
+	if errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	} else if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	h := sha256.New()
+	tee := io.TeeReader(r, h)
+	readErr := archive.Read(tee, func(archive.Manifest, archive.Snapshot) (archive.Sink, error) {
+		return nil, nil
+	})
+	// whatever's after the end of the archive counts too
+	if _, err = io.Copy(io.Discard, tee); err != nil {
+		return err
+	}
+
+	if got := hex.EncodeToString(h.Sum(nil)); got != want {
+		return fmt.Errorf("%w: %s is %s, not %s", ErrChecksum, name, got, want)
+	} else if readErr != nil {
+		return fmt.Errorf("%s: %w", name, readErr)
+	}
+	return nil
+}
+
+// sum is the sha256 kept next to name
+func (s *Scheduler) sum(ctx context.Context, name string) (string, error) {
+	r, err := s.dest.Get(ctx, name+sumSuffix)
+	if errors.Is(err, ErrNotFound) {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, name)
+	} else if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(io.LimitReader(r, 1<<10))
+	if err != nil {
+		return "", err
+	} else if sum, file, ok := strings.Cut(strings.TrimSpace(string(data)), "  "); !ok || file != name || len(sum) != sha256.Size*2 {
+		return "", fmt.Errorf("%w: malformed sum for %s", ErrChecksum, name)
+	} else {
+		return sum, nil
+	}
+}
+
+// List is every backup there is, newest first; anything in the destination
+// that isn't one is left out
+func (s *Scheduler) List(ctx context.Context) ([]Backup, error) {
+	objects, err := s.dest.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []Backup
+	for _, o := range objects {
+		stamp, ok := strings.CutPrefix(o.Name, prefix)
+		if !ok {
+			continue
+		} else if stamp, ok = strings.CutSuffix(stamp, suffix); !ok {
+			continue
+		}
+		created, err := time.Parse(stampFormat, stamp)
+		if err != nil {
+			continue
+		}
+
+		b := Backup{Name: o.Name, Created: created, Size: o.Size}
+		if b.SHA256, err = s.sum(ctx, o.Name); err != nil {
+			s.log.WithError(err).WithField("name", o.Name).Warn("backup has no usable sum")
+		}
+		result = append(result, b)
+	}
+
+	slices.SortFunc(result, func(a, b Backup) int { return b.Created.Compare(a.Created) })
+	return result, nil
+}
+
+// Status is how backups are going
+func (s *Scheduler) Status(ctx context.Context) (Status, error) {
+	backups, err := s.List(ctx)
+	if err != nil {
+		return Status{}, err
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	result := s.status
+	result.Backups = backups
+	return result, nil
+}
+
+// prune removes whatever the policy doesn't keep
+func (s *Scheduler) prune(ctx context.Context) error {
+	backups, err := s.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	keep := s.cfg.Keep.keep(backups)
+	for _, b := range backups {
+		if keep[b.Name] {
+			continue
+		} else if err = s.dest.Delete(ctx, b.Name); err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		} else if err = s.dest.Delete(ctx, b.Name+sumSuffix); err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+		s.log.WithField("name", b.Name).Info("removed old backup")
+	}
+
+	metrics.BackupStatus.WithLabelValues("kept").Set(float64(len(keep)))
+	return nil
+}
+
+// keep is which of backups, newest first, p wants to keep
+func (p Policy) keep(backups []Backup) map[string]bool {
+	result := map[string]bool{}
+	if len(backups) == 0 {
+		return result
+	}
+
+	if p.Daily == 0 && p.Weekly == 0 && p.Monthly == 0 {
+		for _, b := range backups {
+			result[b.Name] = true
+		}
+		return result
+	}
+
+	result[backups[0].Name] = true
+	for _, rule := range []struct {
+		n      int
+		period func(time.Time) string
+	}{
+		{p.Daily, func(t time.Time) string { return t.Format("2006-01-02") }},
+		{p.Weekly, func(t time.Time) string { y, w := t.ISOWeek(); return fmt.Sprintf("%d-W%02d", y, w) }},
+		{p.Monthly, func(t time.Time) string { return t.Format("2006-01") }},
+	} {
+		seen := map[string]bool{}
+		for _, b := range backups {
+			if len(seen) == rule.n {
+				break
+			} else if period := rule.period(b.Created.UTC()); !seen[period] {
+				seen[period] = true
+				result[b.Name] = true
+			}
+		}
+	}
+	return result
+}
+
+// counter is how many bytes went through it to w
+type counter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *counter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
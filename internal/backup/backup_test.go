@@ -0,0 +1,344 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/archive"
+)
+
+func exporter(_ context.Context, w io.Writer) error {
+	return archive.Write(w, archive.Manifest{Created: time.Now().UTC(), Database: "memory"}, archive.Snapshot{}, nil)
+}
+
+func newScheduler(t *testing.T, export Exporter, cfg Config, now time.Time) (*Scheduler, Dir) {
+	dir := Dir(t.TempDir())
+	s, err := NewScheduler(dir, export, cfg, logrus.WithField("test", t.Name()))
+	require.Nil(t, err)
+	s.now = func() time.Time { return now }
+	return s, dir
+}
+
+func Test_Backup(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 10, 19, 3, 0, 0, 0, time.UTC)
+
+	tcs := map[string]struct {
+		export Exporter
+		// done to the backup after it's taken
+		mangle func(t *testing.T, path string)
+		files  []string
+		err    error
+		verify error
+	}{
+		"happy_path": {
+			export: exporter,
+			files:  []string{"cffc-backup-20261019T030000Z.tar.gz", "cffc-backup-20261019T030000Z.tar.gz.sha256"},
+		},
+		"export_fails": {
+			export: func(_ context.Context, w io.Writer) error {
+				_, _ = w.Write([]byte("half an export"))
+				return fmt.Errorf("some error")
+			},
+			err: fmt.Errorf("writing cffc-backup-20261019T030000Z.tar.gz: some error"),
+		},
+		"not_an_export": {
+			export: func(_ context.Context, w io.Writer) error {
+				_, err := w.Write([]byte("hello"))
+				return err
+			},
+			err: archive.ErrFormat,
+		},
+		"corrupted_later": {
+			export: exporter,
+			mangle: func(t *testing.T, path string) {
+				f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+				require.Nil(t, err)
+				_, err = f.Write([]byte("bitrot"))
+				require.Nil(t, err)
+				require.Nil(t, f.Close())
+			},
+			files:  []string{"cffc-backup-20261019T030000Z.tar.gz", "cffc-backup-20261019T030000Z.tar.gz.sha256"},
+			verify: ErrChecksum,
+		},
+		"sum_removed": {
+			export: exporter,
+			mangle: func(t *testing.T, path string) {
+				require.Nil(t, os.Remove(path+sumSuffix))
+			},
+			files:  []string{"cffc-backup-20261019T030000Z.tar.gz"},
+			verify: ErrNotFound,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			s, dir := newScheduler(t, tc.export, Config{Every: time.Hour}, now)
+
+			b, err := s.Backup(ctx)
+			status, _ := s.Status(ctx)
+			require.False(t, status.Running)
+			require.Equal(t, now, *status.LastAttempt)
+			if tc.err != nil {
+				require.ErrorContains(t, err, tc.err.Error())
+				require.Nil(t, status.LastSuccess)
+				require.Equal(t, err.Error(), status.LastError)
+			} else {
+				require.Nil(t, err)
+				require.Equal(t, now, *status.LastSuccess)
+				require.Equal(t, []Backup{b}, status.Backups)
+				require.Len(t, b.SHA256, 64)
+				require.NotZero(t, b.Size)
+			}
+
+			if tc.mangle != nil {
+				tc.mangle(t, filepath.Join(string(dir), b.Name))
+			}
+
+			objects, err := dir.List(ctx)
+			require.Nil(t, err)
+			var files []string
+			for _, o := range objects {
+				files = append(files, o.Name)
+			}
+			require.Equal(t, tc.files, files)
+
+			if tc.err == nil {
+				require.ErrorIs(t, s.Verify(ctx, b.Name), tc.verify)
+			}
+		})
+	}
+}
+
+func Test_prune(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	// one a day at 03:00 for 90 days, then the policy changes
+	start := time.Date(2026, 7, 21, 3, 0, 0, 0, time.UTC)
+	s, dir := newScheduler(t, exporter, Config{Every: 24 * time.Hour}, start)
+	for i := 0; i < 90; i++ {
+		now := start.AddDate(0, 0, i)
+		s.now = func() time.Time { return now }
+		_, err := s.Backup(ctx)
+		require.Nil(t, err)
+	}
+
+	backups, err := s.List(ctx)
+	require.Nil(t, err)
+	require.Len(t, backups, 90)
+
+	s.cfg.Keep = Policy{Daily: 3, Weekly: 2, Monthly: 3}
+	require.Nil(t, s.prune(ctx))
+
+	backups, err = s.List(ctx)
+	require.Nil(t, err)
+	var got []string
+	for _, b := range backups {
+		got = append(got, b.Created.Format("2006-01-02"))
+	}
+	require.Equal(t, []string{
+		"2026-10-18", // newest, today, and this week and month's
+		"2026-10-17",
+		"2026-10-16",
+		"2026-10-11", // last week's
+		"2026-09-30", // last month's
+		"2026-08-31", // and the month before's
+	}, got)
+
+	// the sums went with them
+	objects, err := dir.List(ctx)
+	require.Nil(t, err)
+	require.Len(t, objects, 2*len(got))
+}
+
+func Test_keep(t *testing.T) {
+	t.Parallel()
+
+	day := func(d int) Backup {
+		created := time.Date(2026, 10, d, 3, 0, 0, 0, time.UTC)
+		return Backup{Name: created.Format("Jan 2"), Created: created}
+	}
+	// newest first, a day apart
+	backups := []Backup{day(19), day(18), day(12), day(5), day(4)}
+
+	tcs := map[string]struct {
+		policy Policy
+		kept   []string
+	}{
+		"keep_everything": {
+			kept: []string{"Oct 19", "Oct 18", "Oct 12", "Oct 5", "Oct 4"},
+		},
+		"daily": {
+			policy: Policy{Daily: 2},
+			kept:   []string{"Oct 19", "Oct 18"},
+		},
+		"weekly": {
+			policy: Policy{Weekly: 3},
+			kept:   []string{"Oct 19", "Oct 18", "Oct 5"},
+		},
+		"monthly": {
+			policy: Policy{Monthly: 12},
+			kept:   []string{"Oct 19"},
+		},
+		"overlapping": {
+			policy: Policy{Daily: 1, Weekly: 2},
+			kept:   []string{"Oct 19", "Oct 18"},
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			want := map[string]bool{}
+			for _, k := range tc.kept {
+				want[k] = true
+			}
+			require.Equal(t, want, tc.policy.keep(backups))
+		})
+	}
+}
+
+func Test_next(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+	tcs := map[string]struct {
+		cfg     Config
+		last    time.Time
+		attempt time.Time
+		next    time.Time
+	}{
+		"first_ever": {
+			cfg:  Config{Every: 24 * time.Hour},
+			next: now,
+		},
+		"after_the_last": {
+			cfg:  Config{Every: 24 * time.Hour},
+			last: now.Add(-time.Hour),
+			next: now.Add(23 * time.Hour),
+		},
+		"missed_one": {
+			cfg:  Config{Every: 24 * time.Hour},
+			last: now.Add(-48 * time.Hour),
+			next: now.Add(-24 * time.Hour),
+		},
+		"at_first_ever": {
+			cfg:  Config{Every: 24 * time.Hour, At: "03:00"},
+			next: time.Date(2026, 10, 20, 3, 0, 0, 0, time.UTC),
+		},
+		"at_after_the_last": {
+			cfg:  Config{Every: 24 * time.Hour, At: "03:00"},
+			last: time.Date(2026, 10, 19, 3, 0, 0, 0, time.UTC),
+			next: time.Date(2026, 10, 20, 3, 0, 0, 0, time.UTC),
+		},
+		"at_missed_one": {
+			cfg:  Config{Every: 24 * time.Hour, At: "03:00"},
+			last: time.Date(2026, 10, 17, 3, 0, 0, 0, time.UTC),
+			next: time.Date(2026, 10, 18, 3, 0, 0, 0, time.UTC),
+		},
+		"at_several_a_day": {
+			cfg:  Config{Every: 6 * time.Hour, At: "03:00"},
+			last: time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC),
+			next: time.Date(2026, 10, 19, 15, 0, 0, 0, time.UTC),
+		},
+		"at_before_the_anchor": {
+			cfg:  Config{Every: 6 * time.Hour, At: "21:00"},
+			last: time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC),
+			next: time.Date(2026, 10, 19, 15, 0, 0, 0, time.UTC),
+		},
+		"failed_retries_soon": {
+			cfg:     Config{Every: 24 * time.Hour},
+			last:    now.Add(-48 * time.Hour),
+			attempt: now.Add(-time.Minute),
+			next:    now.Add(retryAfter - time.Minute),
+		},
+		"failed_retries_on_schedule": {
+			cfg:     Config{Every: time.Minute},
+			last:    now.Add(-2 * time.Minute),
+			attempt: now.Add(-30 * time.Second),
+			next:    now.Add(30 * time.Second),
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			s, _ := newScheduler(t, exporter, tc.cfg, now)
+			if !tc.last.IsZero() {
+				s.status.LastSuccess = &tc.last
+			}
+			if !tc.attempt.IsZero() {
+				s.status.LastAttempt = &tc.attempt
+			}
+			require.Equal(t, tc.next, s.next())
+		})
+	}
+}
+
+func Test_NewScheduler(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		cfg Config
+		ok  bool
+	}{
+		"happy_path":    {cfg: Config{Every: time.Hour, At: "03:30", Keep: Policy{Daily: 7}}, ok: true},
+		"never":         {cfg: Config{}},
+		"bad_at":        {cfg: Config{Every: time.Hour, At: "3am"}},
+		"negative_keep": {cfg: Config{Every: time.Hour, Keep: Policy{Weekly: -1}}},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewScheduler(Dir(t.TempDir()), exporter, tc.cfg, logrus.WithField("test", t.Name()))
+			require.Equal(t, tc.ok, err == nil, err)
+		})
+	}
+}
+
+func Test_Run(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the first one's due right away
+	s, _ := newScheduler(t, exporter, Config{Every: time.Hour}, time.Now())
+	s.now = time.Now
+	go s.Run(ctx)
+
+	require.Eventually(t, func() bool {
+		backups, err := s.List(ctx)
+		return err == nil && len(backups) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	status, err := s.Status(ctx)
+	require.Nil(t, err)
+	require.Empty(t, status.LastError)
+	require.Eventually(t, func() bool {
+		status, _ := s.Status(ctx)
+		return status.Next != nil && status.Next.After(time.Now().Add(50*time.Minute))
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,306 @@
+package backup
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+type (
+	// Bucket keeps backups in an s3 bucket, or anything that talks like one
+	// (minio, r2, b2, ...), addressed path-style, so there's no sdk to drag
+	// in for four requests
+	Bucket struct {
+		// scheme and host of the endpoint, like https://s3.us-east-1.amazonaws.com
+		endpoint *url.URL
+		bucket   string
+		// everything is kept under this, which is empty or ends with a /
+		prefix string
+
+		region, accessKey, secretKey string
+
+		client *http.Client
+		now    func() time.Time
+	}
+
+	listResult struct {
+		Contents []struct {
+			Key  string `xml:"Key"`
+			Size int64  `xml:"Size"`
+		} `xml:"Contents"`
+		IsTruncated           bool   `xml:"IsTruncated"`
+		NextContinuationToken string `xml:"NextContinuationToken"`
+	}
+
+	bucketError struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+)
+
+var _ Destination = (*Bucket)(nil)
+
+// NewBucket is the bucket at rawurl, which is the endpoint, the bucket and
+// optionally a prefix to keep backups under, like
+// https://s3.us-east-1.amazonaws.com/cffc/backups or http://minio:9000/cffc
+func NewBucket(rawurl, region, accessKey, secretKey string) (*Bucket, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, fmt.Errorf("backup bucket: %w", err)
+	} else if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("backup bucket needs to be an http or https url: %q", rawurl)
+	} else if region == "" {
+		return nil, fmt.Errorf("backup bucket needs a region")
+	} else if accessKey == "" || secretKey == "" {
+		return nil, fmt.Errorf("backup bucket needs an access key and a secret key")
+	}
+
+	bucket, prefix, _ := strings.Cut(strings.Trim(u.Path, "/"), "/")
+	if bucket == "" {
+		return nil, fmt.Errorf("backup bucket url doesn't name a bucket: %q", rawurl)
+	} else if prefix != "" {
+		prefix += "/"
+	}
+
+	return &Bucket{
+		endpoint:  &url.URL{Scheme: u.Scheme, Host: u.Host},
+		bucket:    bucket,
+		prefix:    prefix,
+		region:    region,
+		accessKey: accessKey,
+		secretKey: secretKey,
+		client:    http.DefaultClient,
+		now:       time.Now,
+	}, nil
+}
+
+// Put spools r to a temp file first, since a bucket wants to know how big
+// something is and what its sum is before it'll take it; a put either
+// replaces the whole object or doesn't happen, so there's never a partial
+// one to see
+func (b *Bucket) Put(ctx context.Context, name string, r io.Reader) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp("", "cffc-backup-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(f, h), r)
+	if err != nil {
+		return err
+	} else if _, err = f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	// a body of unknown length goes chunked, which buckets won't take
+	var body io.ReadCloser = http.NoBody
+	if size > 0 {
+		body = io.NopCloser(f)
+	}
+	resp, err := b.do(ctx, http.MethodPut, b.prefix+name, nil, body, size, hex.EncodeToString(h.Sum(nil)))
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (b *Bucket) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	if err := checkName(name); err != nil {
+		return nil, err
+	}
+
+	resp, err := b.do(ctx, http.MethodGet, b.prefix+name, nil, nil, 0, emptySHA256)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// List is only what's directly under the prefix, so other things kept in
+// the same bucket are left alone as long as they're somewhere else
+func (b *Bucket) List(ctx context.Context) ([]Object, error) {
+	var result []Object
+
+	query := url.Values{
+		"list-type": {"2"},
+		"delimiter": {"/"},
+		"prefix":    {b.prefix},
+	}
+	for {
+		resp, err := b.do(ctx, http.MethodGet, "", query, nil, 0, emptySHA256)
+		if err != nil {
+			return nil, err
+		}
+
+		page := listResult{}
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("listing backup bucket: %w", err)
+		}
+
+		for _, c := range page.Contents {
+			if name := strings.TrimPrefix(c.Key, b.prefix); checkName(name) == nil {
+				result = append(result, Object{Name: name, Size: c.Size})
+			}
+		}
+
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return result, nil
+		}
+		query.Set("continuation-token", page.NextContinuationToken)
+	}
+}
+
+// Delete doesn't say when there wasn't anything to delete, because buckets
+// don't either
+func (b *Bucket) Delete(ctx context.Context, name string) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+
+	resp, err := b.do(ctx, http.MethodDelete, b.prefix+name, nil, nil, 0, emptySHA256)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// do sends a signed request for key in the bucket, or for the bucket itself
+// if key is empty, and turns anything but a 2xx into an error
+func (b *Bucket) do(ctx context.Context, method, key string, query url.Values, body io.ReadCloser, size int64, sum string) (*http.Response, error) {
+	u := *b.endpoint
+	u.Path = "/" + b.bucket
+	if key != "" {
+		u.Path += "/" + key
+	}
+	u.RawPath = uriEncode(u.Path, false)
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = size
+	b.sign(req, sum)
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	} else if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	what := strings.TrimPrefix(key, b.prefix)
+	if what == "" {
+		what = b.bucket
+	}
+	if resp.StatusCode == http.StatusNotFound && key != "" {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, what)
+	}
+
+	e := bucketError{}
+	_ = xml.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&e)
+	return nil, fmt.Errorf("%s %s: %s %s %s", method, what, resp.Status, e.Code, e.Message)
+}
+
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// sign adds aws signature version 4 headers to req, covering host and
+// whatever headers it already has; sum is the hex sha256 of the body
+func (b *Bucket) sign(req *http.Request, sum string) {
+	now := b.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	scope := strings.Join([]string{now.Format("20060102"), b.region, "s3", "aws4_request"}, "/")
+
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", sum)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	canonical := &strings.Builder{}
+	fmt.Fprintf(canonical, "%s\n%s\n%s\n", req.Method, req.URL.EscapedPath(), req.URL.RawQuery)
+	for _, k := range names {
+		fmt.Fprintf(canonical, "%s:%s\n", k, headers[k])
+	}
+	signed := strings.Join(names, ";")
+	fmt.Fprintf(canonical, "\n%s\n%s", signed, sum)
+
+	hashed := sha256.Sum256([]byte(canonical.String()))
+	toSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hex.EncodeToString(hashed[:])}, "\n")
+
+	key := []byte("AWS4" + b.secretKey)
+	for _, part := range strings.Split(scope, "/") {
+		key = hmacSHA256(key, part)
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		b.accessKey, scope, signed, hex.EncodeToString(hmacSHA256(key, toSign))))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// canonicalQuery is query sorted and escaped the way a signature needs it,
+// which is stricter than url.Values.Encode
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var pairs []string
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			pairs = append(pairs, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode escapes everything but letters, digits and -_.~, and slashes
+// too if slash is true
+func uriEncode(s string, slash bool) string {
+	result := &strings.Builder{}
+	for _, c := range []byte(s) {
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' && !slash {
+			result.WriteByte(c)
+		} else {
+			fmt.Fprintf(result, "%%%02X", c)
+		}
+	}
+	return result.String()
+}
@@ -0,0 +1,121 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type (
+	// Destination is somewhere backups are kept, like a directory or a
+	// bucket
+	Destination interface {
+		// Put shouldn't leave a partial name behind if r fails
+		Put(ctx context.Context, name string, r io.Reader) error
+		Get(ctx context.Context, name string) (io.ReadCloser, error)
+		List(ctx context.Context) ([]Object, error)
+		Delete(ctx context.Context, name string) error
+	}
+
+	Object struct {
+		Name string
+		Size int64
+	}
+
+	// Dir keeps backups in a directory on local disk, which is created if
+	// it needs to be
+	Dir string
+)
+
+var _ Destination = Dir("")
+
+// checkName is whether name could be a backup: a plain file name that isn't
+// hidden, since that's where a half-written one would be
+func checkName(name string) error {
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("%w: %q", ErrNotFound, name)
+	}
+	return nil
+}
+
+func (d Dir) path(name string) (string, error) {
+	if err := checkName(name); err != nil {
+		return "", err
+	}
+	return filepath.Join(string(d), name), nil
+}
+
+func (d Dir) Put(_ context.Context, name string, r io.Reader) error {
+	p, err := d.path(name)
+	if err != nil {
+		return err
+	} else if err = os.MkdirAll(string(d), 0755); err != nil {
+		return err
+	}
+
+	// dotfiles aren't listed, so a half-written backup never shows up
+	f, err := os.CreateTemp(string(d), ".tmp-"+name+"-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err = io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	} else if err = f.Sync(); err != nil {
+		f.Close()
+		return err
+	} else if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), p)
+}
+
+func (d Dir) Get(_ context.Context, name string) (io.ReadCloser, error) {
+	p, err := d.path(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return f, err
+}
+
+func (d Dir) List(context.Context) ([]Object, error) {
+	entries, err := os.ReadDir(string(d))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var result []Object
+	for _, e := range entries {
+		if !e.Type().IsRegular() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		} else if info, err := e.Info(); err != nil {
+			return nil, err
+		} else {
+			result = append(result, Object{Name: e.Name(), Size: info.Size()})
+		}
+	}
+	return result, nil
+}
+
+func (d Dir) Delete(_ context.Context, name string) error {
+	p, err := d.path(name)
+	if err != nil {
+		return err
+	} else if err = os.Remove(p); errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return err
+}
@@ -0,0 +1,231 @@
+// Package codes gives lifecycles, generations and strains short codes, like
+// LC-2026-0142 or GEN-0031, that are easy to write on a jar; uuids aren't
+package codes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/store"
+	"github.com/jsmit257/huautla/types"
+)
+
+type (
+	Kind string
+
+	// Ref is what a code stands for
+	Ref struct {
+		Kind Kind       `json:"kind"`
+		ID   types.UUID `json:"id"`
+	}
+
+	// Registry hands out codes and remembers them; it's all in the store,
+	// and handing one out holds the store's lock on the codes, so two
+	// servers sharing a store never hand out the same one
+	Registry struct {
+		store store.Store
+	}
+)
+
+const (
+	Lifecycle  Kind = "lifecycle"
+	Generation Kind = "generation"
+	Strain     Kind = "strain"
+
+	// code => Ref
+	table = "codes"
+	// id => code
+	idsTable = "code_ids"
+	// prefix => the last number handed out with it
+	countersTable = "code_counters"
+)
+
+var prefixes = map[Kind]string{
+	Lifecycle:  "LC",
+	Generation: "GEN",
+	Strain:     "STR",
+}
+
+func New(s store.Store) *Registry {
+	return &Registry{store: s}
+}
+
+// Assign gives id the next code for its kind, unless it already has one;
+// lifecycle codes start over every year, and when says which year it is
+func (reg *Registry) Assign(ctx context.Context, kind Kind, id types.UUID, when time.Time) (string, error) {
+	prefix, ok := prefixes[kind]
+	if !ok {
+		return "", fmt.Errorf("nothing of kind %q gets a code", kind)
+	} else if id == "" {
+		return "", fmt.Errorf("missing id")
+	} else if kind == Lifecycle {
+		prefix = fmt.Sprintf("%s-%04d", prefix, when.UTC().Year())
+	}
+
+	unlock, err := reg.store.Lock(ctx, table)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	if code, err := reg.Code(ctx, id); err == nil {
+		return code, nil
+	} else if !errors.Is(err, store.ErrNotFound) {
+		return "", err
+	}
+
+	var n int
+	if err := reg.store.Get(ctx, countersTable, prefix, &n); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return "", err
+	}
+
+	// the counter is saved first; if the code isn't, that number is skipped
+	// rather than handed out twice
+	n++
+	code := fmt.Sprintf("%s-%04d", prefix, n)
+	if err := reg.store.Put(ctx, countersTable, prefix, n); err != nil {
+		return "", err
+	} else if err := reg.put(ctx, code, Ref{Kind: kind, ID: id}); err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// Adopt gives ref a code it was given somewhere else, like the database an
+// export came from, unless the code or ref is already spoken for; the
+// counter for the code's prefix is moved past it, so it's never handed out
+// again
+func (reg *Registry) Adopt(ctx context.Context, code string, ref Ref) (bool, error) {
+	code = Normalize(code)
+	i := strings.LastIndex(code, "-")
+	if _, ok := prefixes[ref.Kind]; !ok {
+		return false, fmt.Errorf("nothing of kind %q gets a code", ref.Kind)
+	} else if ref.ID == "" {
+		return false, fmt.Errorf("missing id")
+	} else if i <= 0 {
+		return false, fmt.Errorf("malformed code: %q", code)
+	}
+
+	prefix := code[:i]
+	n, err := strconv.Atoi(code[i+1:])
+	if err != nil {
+		return false, fmt.Errorf("malformed code: %q", code)
+	}
+
+	unlock, err := reg.store.Lock(ctx, table)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	if _, err := reg.Code(ctx, ref.ID); err == nil {
+		return false, nil
+	} else if !errors.Is(err, store.ErrNotFound) {
+		return false, err
+	} else if _, err = reg.Resolve(ctx, code); err == nil {
+		return false, nil
+	} else if !errors.Is(err, store.ErrNotFound) {
+		return false, err
+	}
+
+	var last int
+	if err := reg.store.Get(ctx, countersTable, prefix, &last); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return false, err
+	} else if last < n {
+		if err = reg.store.Put(ctx, countersTable, prefix, n); err != nil {
+			return false, err
+		}
+	}
+	if err := reg.put(ctx, code, ref); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// put saves code both ways round; the code goes first, so if the id
+// doesn't make it the id just gets another code next time, and the first
+// one is never handed out again
+func (reg *Registry) put(ctx context.Context, code string, ref Ref) error {
+	if err := reg.store.Put(ctx, table, code, ref); err != nil {
+		return err
+	}
+	return reg.store.Put(ctx, idsTable, string(ref.ID), code)
+}
+
+// Forget takes id's code away once whatever it stood for is gone; the
+// counter stays where it is, so the code is never handed out again
+func (reg *Registry) Forget(ctx context.Context, id types.UUID) error {
+	unlock, err := reg.store.Lock(ctx, table)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	code, err := reg.Code(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// the code goes first, the same as put; an id left pointing at a code
+	// that's gone just gets that code back if anyone asks again
+	if err := reg.store.Delete(ctx, table, code); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return err
+	} else if err := reg.store.Delete(ctx, idsTable, string(id)); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+// Code is id's code; it's store.ErrNotFound if it doesn't have one
+func (reg *Registry) Code(ctx context.Context, id types.UUID) (string, error) {
+	var code string
+	if id == "" {
+		return "", fmt.Errorf("missing id: %w", store.ErrNotFound)
+	} else if err := reg.store.Get(ctx, idsTable, string(id), &code); err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// All is every code there is, and what it stands for
+func (reg *Registry) All(ctx context.Context) (map[string]Ref, error) {
+	keys, err := reg.store.Keys(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]Ref, len(keys))
+	for _, code := range keys {
+		var ref Ref
+		if err := reg.store.Get(ctx, table, code, &ref); errors.Is(err, store.ErrNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		result[code] = ref
+	}
+	return result, nil
+}
+
+// Resolve is what code stands for; it doesn't care about case or
+// surrounding whitespace, since codes are mostly copied off of labels.
+// It's store.ErrNotFound if nothing has code
+func (reg *Registry) Resolve(ctx context.Context, code string) (Ref, error) {
+	var ref Ref
+	if code = Normalize(code); code == "" {
+		return ref, fmt.Errorf("missing code: %w", store.ErrNotFound)
+	} else if err := reg.store.Get(ctx, table, code, &ref); err != nil {
+		return ref, err
+	}
+	return ref, nil
+}
+
+func Normalize(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
@@ -0,0 +1,258 @@
+package codes
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/store"
+	"github.com/jsmit257/huautla/types"
+)
+
+func Test_Assign(t *testing.T) {
+	t.Parallel()
+
+	this := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	next := this.AddDate(1, 0, 0)
+
+	type assign struct {
+		kind Kind
+		id   types.UUID
+		when time.Time
+		code string
+		err  bool
+	}
+
+	tcs := map[string][]assign{
+		"sequential": {
+			{kind: Lifecycle, id: "lc0", when: this, code: "LC-2026-0001"},
+			{kind: Lifecycle, id: "lc1", when: this, code: "LC-2026-0002"},
+			{kind: Generation, id: "g0", when: this, code: "GEN-0001"},
+			{kind: Strain, id: "s0", when: this, code: "STR-0001"},
+			{kind: Generation, id: "g1", when: next, code: "GEN-0002"},
+		},
+		"lifecycles_start_over_every_year": {
+			{kind: Lifecycle, id: "lc0", when: this, code: "LC-2026-0001"},
+			{kind: Lifecycle, id: "lc1", when: next, code: "LC-2027-0001"},
+			{kind: Lifecycle, id: "lc2", when: this, code: "LC-2026-0002"},
+		},
+		"already_has_one": {
+			{kind: Strain, id: "s0", when: this, code: "STR-0001"},
+			{kind: Strain, id: "s0", when: this, code: "STR-0001"},
+			{kind: Strain, id: "s1", when: this, code: "STR-0002"},
+		},
+		"past_four_digits": {
+			{kind: Generation, id: "g0", when: this, code: "GEN-10000"},
+		},
+		"bad_kind": {
+			{kind: "vendor", id: "v0", when: this, err: true},
+		},
+		"no_id": {
+			{kind: Strain, when: this, err: true},
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			s := store.NewMem()
+			if name == "past_four_digits" {
+				require.Nil(t, s.Put(context.Background(), countersTable, "GEN", 9999))
+			}
+			reg := New(s)
+
+			for _, a := range tc {
+				code, err := reg.Assign(context.Background(), a.kind, a.id, a.when)
+				if a.err {
+					require.NotNil(t, err)
+					continue
+				}
+				require.Nil(t, err)
+				require.Equal(t, a.code, code)
+
+				ref, err := reg.Resolve(context.Background(), code)
+				require.Nil(t, err)
+				require.Equal(t, Ref{Kind: a.kind, ID: a.id}, ref)
+			}
+
+			// and it's all still there after a restart
+			reloaded := New(s)
+			for _, a := range tc {
+				if !a.err {
+					code, err := reloaded.Code(context.Background(), a.id)
+					require.Nil(t, err)
+					require.Equal(t, a.code, code)
+				}
+			}
+		})
+	}
+}
+
+func Test_AssignConcurrently(t *testing.T) {
+	t.Parallel()
+
+	// each registry has its own store, like two servers sharing a
+	// directory would, and they still never hand out the same code
+	dir := t.TempDir()
+	const n = 50
+	codes := make([]string, n)
+	wg := sync.WaitGroup{}
+	for r := 0; r < 2; r++ {
+		s, err := store.NewFile(dir)
+		require.Nil(t, err)
+		reg := New(s)
+
+		for i := r; i < n; i += 2 {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				code, err := reg.Assign(context.Background(), Generation, types.UUID(fmt.Sprintf("g%d", i)), time.Now())
+				require.Nil(t, err)
+				codes[i] = code
+			}(i)
+		}
+	}
+	wg.Wait()
+
+	seen := map[string]bool{}
+	for _, code := range codes {
+		require.False(t, seen[code], "%s was handed out twice", code)
+		seen[code] = true
+	}
+	require.True(t, seen["GEN-0001"])
+	require.True(t, seen[fmt.Sprintf("GEN-%04d", n)])
+}
+
+func Test_Adopt(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		code    string
+		ref     Ref
+		adopted bool
+		next    string
+		err     bool
+	}{
+		"happy_path": {
+			code:    "gen-0007",
+			ref:     Ref{Kind: Generation, ID: "g1"},
+			adopted: true,
+			next:    "GEN-0008",
+		},
+		"code_is_taken": {
+			code: "GEN-0002",
+			ref:  Ref{Kind: Generation, ID: "g1"},
+			next: "GEN-0003",
+		},
+		"id_has_one": {
+			code: "GEN-0009",
+			ref:  Ref{Kind: Generation, ID: "g0"},
+			next: "GEN-0003",
+		},
+		"lifecycle": {
+			code:    "LC-2026-0042",
+			ref:     Ref{Kind: Lifecycle, ID: "lc0"},
+			adopted: true,
+		},
+		"malformed": {
+			code: "GEN-seven",
+			ref:  Ref{Kind: Generation, ID: "g1"},
+			err:  true,
+		},
+		"bad_kind": {
+			code: "V-0001",
+			ref:  Ref{Kind: "vendor", ID: "v0"},
+			err:  true,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			reg := New(store.NewMem())
+			for _, id := range []types.UUID{"g-1", "g0"} {
+				_, err := reg.Assign(ctx, Generation, id, time.Now())
+				require.Nil(t, err)
+			}
+
+			adopted, err := reg.Adopt(ctx, tc.code, tc.ref)
+			if tc.err {
+				require.NotNil(t, err)
+				return
+			}
+			require.Nil(t, err)
+			require.Equal(t, tc.adopted, adopted)
+
+			if ref, err := reg.Resolve(ctx, tc.code); tc.adopted {
+				require.Nil(t, err)
+				require.Equal(t, tc.ref, ref)
+			}
+			if tc.next != "" {
+				code, err := reg.Assign(ctx, Generation, "g2", time.Now())
+				require.Nil(t, err)
+				require.Equal(t, tc.next, code)
+			}
+		})
+	}
+}
+
+func Test_Resolve(t *testing.T) {
+	t.Parallel()
+
+	reg := New(store.NewMem())
+	_, err := reg.Assign(context.Background(), Lifecycle, "lc0", time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	require.Nil(t, err)
+
+	tcs := map[string]struct {
+		code string
+		ok   bool
+	}{
+		"exact":      {code: "LC-2026-0001", ok: true},
+		"lower_case": {code: "lc-2026-0001", ok: true},
+		"whitespace": {code: " LC-2026-0001\n", ok: true},
+		"unknown":    {code: "LC-2026-0002"},
+		"empty":      {},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			ref, err := reg.Resolve(context.Background(), tc.code)
+			require.Equal(t, tc.ok, err == nil, err)
+			if tc.ok {
+				require.Equal(t, Ref{Kind: Lifecycle, ID: "lc0"}, ref)
+			}
+		})
+	}
+}
+
+func Test_Forget(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	reg := New(store.NewMem())
+	code, err := reg.Assign(ctx, Generation, "g0", time.Now())
+	require.Nil(t, err)
+
+	require.Nil(t, reg.Forget(ctx, "g0"))
+	_, err = reg.Code(ctx, "g0")
+	require.ErrorIs(t, err, store.ErrNotFound)
+	_, err = reg.Resolve(ctx, code)
+	require.ErrorIs(t, err, store.ErrNotFound)
+
+	// forgetting twice is fine, and the code isn't handed out again
+	require.Nil(t, reg.Forget(ctx, "g0"))
+	next, err := reg.Assign(ctx, Generation, "g1", time.Now())
+	require.Nil(t, err)
+	require.Equal(t, "GEN-0002", next)
+}
@@ -1,6 +1,10 @@
 package config
 
-import "github.com/kelseyhightower/envconfig"
+import (
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+)
 
 type Config struct {
 	HuautlaHost string `envconfig:"HUAUTLA_HOST" default:"localhost"`
@@ -9,6 +13,15 @@ type Config struct {
 	HuautlaPass string `envconfig:"HUAUTLA_PASS" default:"root"`
 	HuautlaSSL  string `envconfig:"HUAUTLA_SSL" default:"disable"`
 
+	// which database to use: huautla is the postgres one above, sqlite is a
+	// single file at SQLitePath, for running without postgres at all, and
+	// memory keeps everything in memory until the server stops
+	Database   string `envconfig:"DATABASE" default:"huautla"`
+	SQLitePath string `envconfig:"SQLITE_PATH" default:"cffc.db"`
+	// run on the memory database, with a few months of made up grows in it
+	// to look around with; the --demo flag does the same thing
+	Demo bool `envconfig:"DEMO" default:"false"`
+
 	AuthnHost string `envconfig:"AUTHN_HOST"`
 	AuthnPort uint16 `envconfig:"AUTHN_PORT"`
 
@@ -16,6 +29,88 @@ type Config struct {
 	HTTPPort int    `envconfig:"HTTP_PORT" default:"8080"`
 
 	LogLevel string `envconfig:"LOG_LEVEL" default:"INFO"`
+
+	AlbumDir string `envconfig:"ALBUM_DIR" default:"album"`
+	StoreDir string `envconfig:"STORE_DIR" default:"store"`
+	// remove gps coordinates from uploaded photos before they're written
+	StripGPS bool `envconfig:"STRIP_GPS" default:"false"`
+	// what to do when a photo looks like another one with the same owner,
+	// either warn or reject
+	DuplicatePhotos string `envconfig:"DUPLICATE_PHOTOS" default:"warn"`
+	// how many bits two perceptual hashes can differ by and still be
+	// considered duplicates
+	DuplicateDistance int `envconfig:"DUPLICATE_DISTANCE" default:"6"`
+
+	// how much memory rendered timelapses can take up, in bytes, 64MiB by
+	// default
+	TimelapseCacheSize int64 `envconfig:"TIMELAPSE_CACHE_SIZE" default:"67108864"`
+
+	AttachmentDir string `envconfig:"ATTACHMENT_DIR" default:"attachments"`
+	// in bytes, 10MiB by default
+	AttachmentMaxSize int64    `envconfig:"ATTACHMENT_MAX_SIZE" default:"10485760"`
+	AttachmentTypes   []string `envconfig:"ATTACHMENT_TYPES" default:"application/pdf,text/csv,text/plain"`
+
+	// domain events are always kept in the outbox in StoreDir; set this to
+	// also append them to a file, one json object per line
+	EventsFile string `envconfig:"EVENTS_FILE"`
+	// how long events stay in the outbox, at least; they're kept longer if
+	// webhooks haven't caught up to them, and zero keeps them forever
+	OutboxRetention time.Duration `envconfig:"OUTBOX_RETENTION" default:"168h"`
+
+	// a failed webhook delivery is retried after WebhookBackoff, then twice
+	// that, and so on up to WebhookMaxBackoff, until it's been tried
+	// WebhookAttempts times
+	WebhookAttempts   int           `envconfig:"WEBHOOK_ATTEMPTS" default:"8"`
+	WebhookBackoff    time.Duration `envconfig:"WEBHOOK_BACKOFF" default:"1s"`
+	WebhookMaxBackoff time.Duration `envconfig:"WEBHOOK_MAX_BACKOFF" default:"1h"`
+	WebhookTimeout    time.Duration `envconfig:"WEBHOOK_TIMEOUT" default:"10s"`
+	WebhookPoll       time.Duration `envconfig:"WEBHOOK_POLL" default:"1s"`
+	// 64 hex characters to encrypt webhook secrets with; they're kept in
+	// plain text under StoreDir without it
+	WebhookSecretKey string `envconfig:"WEBHOOK_SECRET_KEY"`
+	// lets webhooks post to loopback and link-local addresses, like a
+	// receiver running next to the server
+	WebhookAllowLocal bool `envconfig:"WEBHOOK_ALLOW_LOCAL"`
+
+	// how long the response to a POST with an Idempotency-Key is kept, to
+	// be replayed if the client sends it again
+	IdempotencyTTL time.Duration `envconfig:"IDEMPOTENCY_TTL" default:"24h"`
+	// the biggest POST with an Idempotency-Key, since it's kept in memory to
+	// be compared with the first one
+	IdempotencyMaxBody int64 `envconfig:"IDEMPOTENCY_MAX_BODY" default:"16777216"`
+
+	// the most requests a POST /batch can have
+	BatchMax int `envconfig:"BATCH_MAX" default:"100"`
+
+	// where the server can be reached from a phone that scans a label, like
+	// https://cffc.example.com; the qr codes on labels use whatever host the
+	// request for them came in on if this isn't set
+	PublicURL string `envconfig:"PUBLIC_URL"`
+
+	// where to keep scheduled backups, a directory or an s3-style bucket
+	// url like https://s3.us-east-1.amazonaws.com/cffc/backups, but not
+	// both; there aren't any unless one is set. one is taken every
+	// BackupEvery, at BackupAt (like 03:00) and every BackupEvery from then
+	// if that's set, and after thinning out, the newest from each of the
+	// last so many days, weeks and months are kept
+	BackupDir             string `envconfig:"BACKUP_DIR"`
+	BackupBucket          string `envconfig:"BACKUP_BUCKET"`
+	BackupBucketRegion    string `envconfig:"BACKUP_BUCKET_REGION" default:"us-east-1"`
+	BackupBucketAccessKey string `envconfig:"BACKUP_BUCKET_ACCESS_KEY"`
+	BackupBucketSecretKey string `envconfig:"BACKUP_BUCKET_SECRET_KEY"`
+
+	BackupEvery       time.Duration `envconfig:"BACKUP_EVERY" default:"24h"`
+	BackupAt          string        `envconfig:"BACKUP_AT"`
+	BackupKeepDaily   int           `envconfig:"BACKUP_KEEP_DAILY" default:"7"`
+	BackupKeepWeekly  int           `envconfig:"BACKUP_KEEP_WEEKLY" default:"4"`
+	BackupKeepMonthly int           `envconfig:"BACKUP_KEEP_MONTHLY" default:"6"`
+
+	KafkaBrokers []string `envconfig:"KAFKA_BROKERS" default:"localhost:9092"`
+	KafkaGroup   string   `envconfig:"KAFKA_GROUP" default:"cffc"`
+	// commands are read from KafkaTopic, and replies go to the topic named in
+	// each command, or KafkaReplyTopic if it doesn't name one
+	KafkaTopic      string `envconfig:"KAFKA_TOPIC" default:"cffc-commands"`
+	KafkaReplyTopic string `envconfig:"KAFKA_REPLY_TOPIC" default:"cffc-replies"`
 }
 
 func NewConfig() *Config {
@@ -0,0 +1,148 @@
+// Package dbtest is a conformance suite for types.DB: every implementation
+// runs it, so they all behave the way huautla's postgres one does, down to
+// which errors come back, what order lists are in, what a soft delete
+// leaves visible and what a report looks like.
+//
+// It's ordinary test code that happens to live outside a _test file, so any
+// package can call Run from one of its own tests:
+//
+//	func Test_conformance(t *testing.T) {
+//		dbtest.Run(t, func(*testing.T) types.DB { return New() })
+//	}
+//
+// The tests assume nothing about what's already in the database, apart from
+// the stages and event types every database starts with, and they run in
+// parallel, so newDB can hand out the same database every time
+package dbtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/centerforfunguscontrol/shared/metrics"
+	"github.com/jsmit257/huautla/types"
+)
+
+// Factory is the database under test; it's called once per test
+type Factory func(t *testing.T) types.DB
+
+var ctx = metrics.MockServiceContext
+
+// Run runs every conformance test against whatever newDB returns, each in
+// its own subtest named after the interface it covers
+func Run(t *testing.T, newDB Factory) {
+	tests := map[string]func(*testing.T, types.DB){
+		"EventTyper":       eventTyper,
+		"Generationer":     generationer,
+		"Ingredienter":     ingredienter,
+		"Lifecycler":       lifecycler,
+		"Noter":            noter,
+		"Observer":         observer,
+		"Photoer":          photoer,
+		"Stager":           stager,
+		"StrainAttributer": strainAttributer,
+		"Strainer":         strainer,
+		"Substrater":       substrater,
+		"Timestamper":      timestamper,
+		"Vendorer":         vendorer,
+	}
+
+	for name, test := range tests {
+		name, test := name, test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			test(t, newDB(t))
+		})
+	}
+}
+
+// missing is an id nothing has
+func missing() types.UUID {
+	return types.UUID(uuid.NewString())
+}
+
+// cid is where the test is, for the logs
+func cid(t *testing.T) types.CID {
+	return types.CID(t.Name())
+}
+
+// stamp sets the fields of the row in table with id to when
+func stamp(t *testing.T, db types.DB, table string, id types.UUID, when time.Time, fields ...string) {
+	require.Nil(t, db.UpdateTimestamps(ctx, table, id, types.Timestamp{Fields: fields, Origin: &when}))
+}
+
+// ago is n days before now, to the second, so nothing is lost to a
+// database that keeps less precision than go does
+func ago(n int) time.Time {
+	return time.Now().UTC().AddDate(0, 0, -n).Truncate(time.Second)
+}
+
+type world struct {
+	vendor                       types.Vendor
+	plating, liquid, grain, bulk types.Substrate
+	strain                       types.Strain
+}
+
+// newWorld is a vendor with one of every kind of substrate, and a strain;
+// enough to start lifecycles and generations from
+func newWorld(t *testing.T, db types.DB) world {
+	var w world
+	var err error
+
+	w.vendor, err = db.InsertVendor(ctx, types.Vendor{Name: "dbtest " + t.Name()}, cid(t))
+	require.Nil(t, err)
+
+	for _, s := range []struct {
+		sub  *types.Substrate
+		kind types.SubstrateType
+	}{
+		{&w.plating, types.PlatingType},
+		{&w.liquid, types.LiquidType},
+		{&w.grain, types.GrainType},
+		{&w.bulk, types.BulkType},
+	} {
+		*s.sub, err = db.InsertSubstrate(ctx, types.Substrate{Name: string(s.kind), Type: s.kind, Vendor: w.vendor}, cid(t))
+		require.Nil(t, err)
+	}
+
+	w.strain, err = db.InsertStrain(ctx, types.Strain{Name: "dbtest", Species: "P. ostreatus", Vendor: w.vendor}, cid(t))
+	require.Nil(t, err)
+
+	return w
+}
+
+func (w world) lifecycle(t *testing.T, db types.DB) types.Lifecycle {
+	lc, err := db.InsertLifecycle(ctx, types.Lifecycle{
+		Location:       "dbtest",
+		Strain:         w.strain,
+		GrainSubstrate: w.grain,
+		BulkSubstrate:  w.bulk,
+	}, cid(t))
+	require.Nil(t, err)
+	return lc
+}
+
+func (w world) generation(t *testing.T, db types.DB) types.Generation {
+	g, err := db.InsertGeneration(ctx, types.Generation{PlatingSubstrate: w.plating, LiquidSubstrate: w.liquid}, cid(t))
+	require.Nil(t, err)
+	return g
+}
+
+// entities is a report's list of children under key
+func entities(t *testing.T, rpt types.Entity, key string) []types.Entity {
+	result, ok := rpt[key].([]types.Entity)
+	require.True(t, ok, "report has no %q: %#v", key, rpt)
+	return result
+}
+
+// ids is the ids of es, in order
+func ids(es []types.Entity) []any {
+	result := make([]any, 0, len(es))
+	for _, e := range es {
+		result = append(result, e["id"])
+	}
+	return result
+}
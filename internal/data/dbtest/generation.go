@@ -0,0 +1,134 @@
+package dbtest
+
+import (
+	"database/sql"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/huautla/types"
+)
+
+// generationer covers GenerationEventer and Sourcer too
+func generationer(t *testing.T, db types.DB) {
+	w := newWorld(t, db)
+	lc := w.lifecycle(t, db)
+	require.Nil(t, db.AddLifecycleEvent(ctx, &lc, types.Event{EventType: types.EventType{UUID: "clone"}}, cid(t)))
+
+	// plating and liquid can't be swapped
+	_, err := db.InsertGeneration(ctx, types.Generation{PlatingSubstrate: w.liquid, LiquidSubstrate: w.plating}, cid(t))
+	require.NotNil(t, err)
+	g, err := db.InsertGeneration(ctx, types.Generation{PlatingSubstrate: w.plating, LiquidSubstrate: w.liquid}, cid(t))
+	require.Nil(t, err)
+	require.NotEmpty(t, g.UUID)
+	require.Empty(t, g.Sources)
+	require.Nil(t, g.DTime)
+
+	_, err = db.SelectGeneration(ctx, missing(), cid(t))
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	// a generation comes from a strain, or an event in a lifecycle of one
+	spore, err := db.InsertSource(ctx, g.UUID, "strain", types.Source{Type: "Spore", Strain: w.strain}, cid(t))
+	require.Nil(t, err)
+	require.NotEmpty(t, spore.UUID)
+	clone, err := db.InsertSource(ctx, g.UUID, "event", types.Source{Type: "Clone", Lifecycle: &lc}, cid(t))
+	require.Nil(t, err)
+	_, err = db.InsertSource(ctx, g.UUID, "vibes", types.Source{Type: "Spore", Strain: w.strain}, cid(t))
+	require.NotNil(t, err)
+
+	g, err = db.SelectGeneration(ctx, g.UUID, cid(t))
+	require.Nil(t, err)
+	require.Equal(t, w.plating.UUID, g.PlatingSubstrate.UUID)
+	require.Equal(t, w.liquid.UUID, g.LiquidSubstrate.UUID)
+	require.Len(t, g.Sources, 2)
+	for _, s := range g.Sources {
+		require.Equal(t, w.strain.UUID, s.Strain.UUID)
+		if s.UUID == clone.UUID {
+			require.Equal(t, "Clone", s.Type)
+			require.Equal(t, lc.UUID, s.Lifecycle.UUID)
+			require.Equal(t, []types.UUID{lc.Events[0].UUID}, eventIDs(s.Lifecycle.Events))
+		} else {
+			require.Equal(t, spore.UUID, s.UUID)
+			require.Nil(t, s.Lifecycle)
+		}
+	}
+
+	spore.Type = "Syringe"
+	require.Nil(t, db.UpdateSource(ctx, "strain", spore, cid(t)))
+	require.NotNil(t, db.UpdateSource(ctx, "vibes", spore, cid(t)))
+	require.NotNil(t, db.UpdateSource(ctx, "strain", types.Source{UUID: missing(), Type: "Spore"}, cid(t)))
+	got, err := db.SelectGeneration(ctx, g.UUID, cid(t))
+	require.Nil(t, err)
+	require.True(t, slices.ContainsFunc(got.Sources, func(s types.Source) bool { return s.UUID == spore.UUID && s.Type == "Syringe" }))
+
+	ndx, err := db.SelectGenerationIndex(ctx, cid(t))
+	require.Nil(t, err)
+	i := slices.IndexFunc(ndx, func(gen types.Generation) bool { return gen.UUID == g.UUID })
+	require.NotEqual(t, -1, i, "generation isn't in the index")
+	require.Len(t, ndx[i].Sources, 2)
+
+	g.PlatingSubstrate, g.LiquidSubstrate = w.liquid, w.plating
+	_, err = db.UpdateGeneration(ctx, g, cid(t))
+	require.NotNil(t, err)
+	g.PlatingSubstrate, g.LiquidSubstrate = w.plating, w.liquid
+	_, err = db.UpdateGeneration(ctx, types.Generation{UUID: missing(), PlatingSubstrate: w.plating, LiquidSubstrate: w.liquid}, cid(t))
+	require.NotNil(t, err)
+	g, err = db.UpdateGeneration(ctx, g, cid(t))
+	require.Nil(t, err)
+
+	// newest events first, and the generation's mtime keeps up
+	require.Nil(t, db.AddGenerationEvent(ctx, &g, types.Event{EventType: types.EventType{UUID: "agarsampling"}}, cid(t)))
+	require.Nil(t, db.AddGenerationEvent(ctx, &g, types.Event{EventType: types.EventType{UUID: "liquidinnoculation"}}, cid(t)))
+	require.NotNil(t, db.AddGenerationEvent(ctx, &g, types.Event{EventType: types.EventType{UUID: missing()}}, cid(t)))
+	require.Len(t, g.Events, 2)
+	require.Equal(t, types.UUID("liquidinnoculation"), g.Events[0].EventType.UUID)
+
+	e, err := db.ChangeGenerationEvent(ctx, &g, types.Event{UUID: g.Events[1].UUID, Temperature: 21, EventType: types.EventType{UUID: "agarsampling"}}, cid(t))
+	require.Nil(t, err)
+	require.Equal(t, float32(21), e.Temperature)
+	require.Equal(t, e.UUID, g.Events[0].UUID, "a changed event is the newest")
+	_, err = db.ChangeGenerationEvent(ctx, &g, types.Event{UUID: missing(), EventType: types.EventType{UUID: "agarsampling"}}, cid(t))
+	require.NotNil(t, err)
+
+	want := eventIDs(g.Events)
+	require.Nil(t, db.GetGenerationEvents(ctx, &g, cid(t)))
+	require.Equal(t, want, eventIDs(g.Events))
+
+	require.Nil(t, db.RemoveGenerationEvent(ctx, &g, e.UUID, cid(t)))
+	require.Len(t, g.Events, 1)
+	require.NotNil(t, db.RemoveGenerationEvent(ctx, &g, e.UUID, cid(t)))
+
+	// a report is the generation, with the strain that came of it, and the
+	// strain's report doesn't go round in circles
+	progeny, err := db.InsertStrain(ctx, types.Strain{Name: "dbtest progeny", Vendor: w.vendor}, cid(t))
+	require.Nil(t, err)
+	require.Nil(t, db.UpdateGeneratedStrain(ctx, &g.UUID, progeny.UUID, cid(t)))
+	_, err = db.AddNote(ctx, g.UUID, nil, types.Note{Note: "looks clean"}, cid(t))
+	require.Nil(t, err)
+
+	rpt, err := db.GenerationReport(ctx, g.UUID, cid(t))
+	require.Nil(t, err)
+	require.Equal(t, string(g.UUID), rpt["id"])
+	require.Len(t, rpt["notes"], 1)
+	require.Len(t, rpt["events"], 1)
+	require.Equal(t, string(progeny.UUID), rpt["progeny"].(types.Entity)["id"])
+	require.NotContains(t, rpt["progeny"], "generation")
+	_, err = db.GenerationReport(ctx, missing(), cid(t))
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	require.Nil(t, db.RemoveSource(ctx, &g, clone.UUID, cid(t)))
+	require.Len(t, g.Sources, 1)
+	require.NotNil(t, db.RemoveSource(ctx, &g, clone.UUID, cid(t)))
+
+	// deleting a generation only marks it deleted, and it's still in the
+	// index
+	require.Nil(t, db.DeleteGeneration(ctx, g.UUID, cid(t)))
+	got, err = db.SelectGeneration(ctx, g.UUID, cid(t))
+	require.Nil(t, err)
+	require.NotNil(t, got.DTime)
+	ndx, err = db.SelectGenerationIndex(ctx, cid(t))
+	require.Nil(t, err)
+	require.True(t, slices.ContainsFunc(ndx, func(gen types.Generation) bool { return gen.UUID == g.UUID && gen.DTime != nil }))
+	require.NotNil(t, db.DeleteGeneration(ctx, missing(), cid(t)))
+}
@@ -0,0 +1,36 @@
+package dbtest_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/config"
+	"github.com/jsmit257/centerforfunguscontrol/internal/data/dbtest"
+	"github.com/jsmit257/huautla"
+	"github.com/jsmit257/huautla/types"
+)
+
+// Test_huautla holds the postgres implementation to the same standard as
+// the others; it needs a real database, at the usual CFFC_HUAUTLA_*
+// settings, so it only runs when CFFC_CONFORMANCE_HUAUTLA is set
+func Test_huautla(t *testing.T) {
+	if os.Getenv("CFFC_CONFORMANCE_HUAUTLA") == "" {
+		t.Skip("CFFC_CONFORMANCE_HUAUTLA isn't set")
+	}
+	t.Parallel()
+
+	cfg := config.NewConfig()
+	db, err := huautla.New(&types.Config{
+		PGHost: cfg.HuautlaHost,
+		PGPort: cfg.HuautlaPort,
+		PGUser: cfg.HuautlaUser,
+		PGPass: cfg.HuautlaPass,
+		PGSSL:  cfg.HuautlaSSL,
+	}, logrus.WithField("test", t.Name()))
+	require.Nil(t, err)
+
+	dbtest.Run(t, func(*testing.T) types.DB { return db })
+}
@@ -0,0 +1,161 @@
+package dbtest
+
+import (
+	"database/sql"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/huautla/types"
+)
+
+// lifecycler covers LifecycleEventer too
+func lifecycler(t *testing.T, db types.DB) {
+	w := newWorld(t, db)
+
+	coir, err := db.InsertIngredient(ctx, types.Ingredient{Name: "dbtest coir"}, cid(t))
+	require.Nil(t, err)
+	require.Nil(t, db.AddIngredient(ctx, &w.bulk, coir, cid(t)))
+	_, err = db.AddAttribute(ctx, &w.strain, types.StrainAttribute{Name: "dbtest color", Value: "gold"}, cid(t))
+	require.Nil(t, err)
+
+	lc, err := db.InsertLifecycle(ctx, types.Lifecycle{
+		Location:       "dbtest shelf",
+		GrainCost:      4.5,
+		Count:          3,
+		Strain:         w.strain,
+		GrainSubstrate: w.grain,
+		BulkSubstrate:  w.bulk,
+	}, cid(t))
+	require.Nil(t, err)
+	require.NotEmpty(t, lc.UUID)
+	require.Equal(t, "dbtest shelf", lc.Location)
+	require.Equal(t, float32(4.5), lc.GrainCost)
+	require.Equal(t, int16(3), lc.Count)
+	require.False(t, lc.CTime.IsZero())
+
+	// grain and bulk can't be swapped, and everything has to be there
+	_, err = db.InsertLifecycle(ctx, types.Lifecycle{Strain: w.strain, GrainSubstrate: w.bulk, BulkSubstrate: w.grain}, cid(t))
+	require.NotNil(t, err)
+	_, err = db.InsertLifecycle(ctx, types.Lifecycle{Strain: types.Strain{UUID: missing()}, GrainSubstrate: w.grain, BulkSubstrate: w.bulk}, cid(t))
+	require.NotNil(t, err)
+
+	got, err := db.SelectLifecycle(ctx, lc.UUID, cid(t))
+	require.Nil(t, err)
+	require.Equal(t, lc.Location, got.Location)
+	require.Equal(t, w.strain.UUID, got.Strain.UUID)
+	require.Equal(t, w.grain.UUID, got.GrainSubstrate.UUID)
+	require.Equal(t, w.bulk.UUID, got.BulkSubstrate.UUID)
+	require.Empty(t, got.Events)
+	_, err = db.SelectLifecycle(ctx, missing(), cid(t))
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	lc.Location, lc.Yield = "dbtest tent", 250
+	lc, err = db.UpdateLifecycle(ctx, lc, cid(t))
+	require.Nil(t, err)
+	got, err = db.SelectLifecycle(ctx, lc.UUID, cid(t))
+	require.Nil(t, err)
+	require.Equal(t, "dbtest tent", got.Location)
+	require.Equal(t, float32(250), got.Yield)
+	lc.GrainSubstrate, lc.BulkSubstrate = w.bulk, w.grain
+	_, err = db.UpdateLifecycle(ctx, lc, cid(t))
+	require.NotNil(t, err)
+	lc.GrainSubstrate, lc.BulkSubstrate = w.grain, w.bulk
+	_, err = db.UpdateLifecycle(ctx, types.Lifecycle{UUID: missing(), Strain: w.strain, GrainSubstrate: w.grain, BulkSubstrate: w.bulk}, cid(t))
+	require.NotNil(t, err)
+
+	// newest events first, with their event types whole, and the
+	// lifecycle's mtime keeps up
+	require.Nil(t, db.AddLifecycleEvent(ctx, &lc, types.Event{Temperature: 75, Humidity: 80, EventType: types.EventType{UUID: "innoculation"}}, cid(t)))
+	require.Nil(t, db.AddLifecycleEvent(ctx, &lc, types.Event{EventType: types.EventType{UUID: "sporeprint"}}, cid(t)))
+	require.NotNil(t, db.AddLifecycleEvent(ctx, &lc, types.Event{EventType: types.EventType{UUID: missing()}}, cid(t)))
+	require.Len(t, lc.Events, 2)
+	require.Equal(t, types.UUID("sporeprint"), lc.Events[0].EventType.UUID)
+	require.Equal(t, "Spore print", lc.Events[0].EventType.Name)
+	require.Equal(t, "Majority", lc.Events[0].EventType.Stage.Name)
+
+	got, err = db.SelectLifecycle(ctx, lc.UUID, cid(t))
+	require.Nil(t, err)
+	require.Equal(t, eventIDs(lc.Events), eventIDs(got.Events))
+	require.WithinDuration(t, lc.MTime, got.MTime, time.Millisecond)
+	require.False(t, got.MTime.Before(got.Events[0].MTime), "%v < %v", got.MTime, got.Events[0].MTime)
+
+	e, err := db.ChangeLifecycleEvent(ctx, &lc, types.Event{UUID: lc.Events[1].UUID, Temperature: 70, EventType: types.EventType{UUID: "binning"}}, cid(t))
+	require.Nil(t, err)
+	require.Equal(t, "Binning", e.EventType.Name)
+	require.Equal(t, float32(70), e.Temperature)
+	require.Equal(t, e.UUID, lc.Events[0].UUID, "a changed event is the newest")
+	_, err = db.ChangeLifecycleEvent(ctx, &lc, types.Event{UUID: missing(), EventType: types.EventType{UUID: "binning"}}, cid(t))
+	require.NotNil(t, err)
+
+	want := eventIDs(lc.Events)
+	require.Nil(t, db.GetLifecycleEvents(ctx, &lc, cid(t)))
+	require.Equal(t, want, eventIDs(lc.Events))
+
+	// only sunset, spore print and clone make it to the index
+	ndx, err := db.SelectLifecycleIndex(ctx, cid(t))
+	require.Nil(t, err)
+	i := slices.IndexFunc(ndx, func(l types.Lifecycle) bool { return l.UUID == lc.UUID })
+	require.NotEqual(t, -1, i, "lifecycle isn't in the index")
+	require.Len(t, ndx[i].Events, 1)
+	require.Equal(t, types.UUID("sporeprint"), ndx[i].Events[0].EventType.UUID)
+
+	// a report is the lifecycle, with its strain's attributes, its
+	// substrates' ingredients, and its events, notes and photos
+	_, err = db.AddNote(ctx, lc.UUID, nil, types.Note{Note: "smells fine"}, cid(t))
+	require.Nil(t, err)
+	photos, err := db.AddPhoto(ctx, e.UUID, nil, types.Photo{Filename: "pins.jpg"}, cid(t))
+	require.Nil(t, err)
+	_, err = db.AddNote(ctx, photos[0].UUID, nil, types.Note{Note: "pins!"}, cid(t))
+	require.Nil(t, err)
+
+	rpt, err := db.LifecycleReport(ctx, lc.UUID, cid(t))
+	require.Nil(t, err)
+	require.Equal(t, string(lc.UUID), rpt["id"])
+	require.Len(t, rpt["notes"], 1)
+	require.Equal(t, "gold", rpt["strain"].(map[string]any)["attributes"].([]any)[0].(map[string]any)["value"])
+	require.Equal(t, "dbtest coir", rpt["bulk_substrate"].(map[string]any)["ingredients"].([]any)[0].(map[string]any)["name"])
+	events := rpt["events"].([]any)
+	require.Len(t, events, 2)
+	photo := events[0].(map[string]any)["photos"].([]any)[0].(map[string]any)
+	require.Equal(t, "pins.jpg", photo["image"])
+	require.Equal(t, "pins!", photo["notes"].([]any)[0].(map[string]any)["note"])
+	_, err = db.LifecycleReport(ctx, missing(), cid(t))
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	require.Nil(t, db.RemoveLifecycleEvent(ctx, &lc, e.UUID, cid(t)))
+	require.Len(t, lc.Events, 1)
+	require.NotNil(t, db.RemoveLifecycleEvent(ctx, &lc, e.UUID, cid(t)))
+	_, err = db.SelectEvent(ctx, e.UUID, cid(t))
+	require.ErrorIs(t, err, sql.ErrNoRows)
+	got2, err := db.GetPhotos(ctx, e.UUID, cid(t))
+	require.Nil(t, err)
+	require.Empty(t, got2, "an event takes its photos with it")
+
+	// things a lifecycle uses can't go while it's there, and a lifecycle
+	// takes its events and notes with it
+	require.NotNil(t, db.DeleteSubstrate(ctx, w.grain.UUID, cid(t)))
+	require.NotNil(t, db.DeleteVendor(ctx, w.vendor.UUID, cid(t)))
+	sporeprint := lc.Events[0].UUID
+	require.Nil(t, db.DeleteLifecycle(ctx, lc.UUID, cid(t)))
+	_, err = db.SelectLifecycle(ctx, lc.UUID, cid(t))
+	require.ErrorIs(t, err, sql.ErrNoRows)
+	_, err = db.SelectEvent(ctx, sporeprint, cid(t))
+	require.ErrorIs(t, err, sql.ErrNoRows)
+	notes, err := db.GetNotes(ctx, lc.UUID, cid(t))
+	require.Nil(t, err)
+	require.Empty(t, notes)
+	require.NotNil(t, db.DeleteLifecycle(ctx, lc.UUID, cid(t)))
+	require.Nil(t, db.DeleteSubstrate(ctx, w.grain.UUID, cid(t)))
+}
+
+// eventIDs is the ids of events, in order
+func eventIDs(events []types.Event) []types.UUID {
+	result := make([]types.UUID, 0, len(events))
+	for _, e := range events {
+		result = append(result, e.UUID)
+	}
+	return result
+}
@@ -0,0 +1,164 @@
+package dbtest
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/huautla/types"
+)
+
+// noteIDs is the ids of notes, in order
+func noteIDs(notes []types.Note) []types.UUID {
+	result := make([]types.UUID, 0, len(notes))
+	for _, n := range notes {
+		result = append(result, n.UUID)
+	}
+	return result
+}
+
+// photoIDs is the ids of photos, in order
+func photoIDs(photos []types.Photo) []types.UUID {
+	result := make([]types.UUID, 0, len(photos))
+	for _, p := range photos {
+		result = append(result, p.UUID)
+	}
+	return result
+}
+
+func noter(t *testing.T, db types.DB) {
+	lc := newWorld(t, db).lifecycle(t, db)
+
+	notes, err := db.GetNotes(ctx, lc.UUID, cid(t))
+	require.Nil(t, err)
+	require.Empty(t, notes)
+
+	notes, err = db.AddNote(ctx, lc.UUID, notes, types.Note{Note: "first"}, cid(t))
+	require.Nil(t, err)
+	require.NotEmpty(t, notes[0].UUID)
+	require.False(t, notes[0].CTime.IsZero())
+	notes, err = db.AddNote(ctx, lc.UUID, notes, types.Note{Note: "second"}, cid(t))
+	require.Nil(t, err)
+	notes, err = db.AddNote(ctx, lc.UUID, notes, types.Note{Note: "third"}, cid(t))
+	require.Nil(t, err)
+	require.Equal(t, "third", notes[0].Note, "a new note is the newest")
+
+	// newest first, by when they were last changed; the clock is set so
+	// that two changes in the same instant don't make it a coin toss
+	stamp(t, db, "notes", notes[0].UUID, ago(3), "mtime", "ctime")
+	stamp(t, db, "notes", notes[1].UUID, ago(1), "mtime", "ctime")
+	stamp(t, db, "notes", notes[2].UUID, ago(2), "mtime", "ctime")
+	got, err := db.GetNotes(ctx, lc.UUID, cid(t))
+	require.Nil(t, err)
+	require.Equal(t, []types.UUID{notes[1].UUID, notes[2].UUID, notes[0].UUID}, noteIDs(got))
+	require.WithinDuration(t, ago(1), got[0].MTime, 0)
+
+	changed := got[2]
+	changed.Note = "third, again"
+	notes, err = db.ChangeNote(ctx, got, changed, cid(t))
+	require.Nil(t, err)
+	require.Equal(t, []types.UUID{got[2].UUID, got[0].UUID, got[1].UUID}, noteIDs(notes), "a changed note is the newest")
+	require.Equal(t, "third, again", notes[0].Note)
+	got, err = db.GetNotes(ctx, lc.UUID, cid(t))
+	require.Nil(t, err)
+	require.Equal(t, noteIDs(notes), noteIDs(got))
+	require.Equal(t, "third, again", got[0].Note)
+	_, err = db.ChangeNote(ctx, notes, types.Note{UUID: missing(), Note: "x"}, cid(t))
+	require.NotNil(t, err)
+
+	notes, err = db.RemoveNote(ctx, notes, got[1].UUID, cid(t))
+	require.Nil(t, err)
+	require.Equal(t, []types.UUID{got[0].UUID, got[2].UUID}, noteIDs(notes))
+	_, err = db.RemoveNote(ctx, notes, got[1].UUID, cid(t))
+	require.NotNil(t, err)
+	got, err = db.GetNotes(ctx, lc.UUID, cid(t))
+	require.Nil(t, err)
+	require.Equal(t, noteIDs(notes), noteIDs(got))
+}
+
+func photoer(t *testing.T, db types.DB) {
+	lc := newWorld(t, db).lifecycle(t, db)
+	require.Nil(t, db.AddLifecycleEvent(ctx, &lc, types.Event{EventType: types.EventType{UUID: "binning"}}, cid(t)))
+	e := lc.Events[0]
+
+	photos, err := db.GetPhotos(ctx, e.UUID, cid(t))
+	require.Nil(t, err)
+	require.Empty(t, photos)
+
+	photos, err = db.AddPhoto(ctx, e.UUID, photos, types.Photo{Filename: "first.jpg"}, cid(t))
+	require.Nil(t, err)
+	require.NotEmpty(t, photos[0].UUID)
+	require.False(t, photos[0].CTime.IsZero())
+	photos, err = db.AddPhoto(ctx, e.UUID, photos, types.Photo{Filename: "second.jpg"}, cid(t))
+	require.Nil(t, err)
+	require.Equal(t, "second.jpg", photos[0].Filename, "a new photo is the newest")
+
+	// newest first, each with its notes
+	stamp(t, db, "photos", photos[0].UUID, ago(2), "mtime", "ctime")
+	stamp(t, db, "photos", photos[1].UUID, ago(1), "mtime", "ctime")
+	_, err = db.AddNote(ctx, photos[0].UUID, nil, types.Note{Note: "blurry"}, cid(t))
+	require.Nil(t, err)
+	got, err := db.GetPhotos(ctx, e.UUID, cid(t))
+	require.Nil(t, err)
+	require.Equal(t, []types.UUID{photos[1].UUID, photos[0].UUID}, photoIDs(got))
+	require.Empty(t, got[0].Notes)
+	require.Len(t, got[1].Notes, 1)
+	require.Equal(t, "blurry", got[1].Notes[0].Note)
+
+	changed := got[1]
+	changed.Filename = "second, cropped.jpg"
+	photos, err = db.ChangePhoto(ctx, got, changed, cid(t))
+	require.Nil(t, err)
+	require.Equal(t, []types.UUID{got[1].UUID, got[0].UUID}, photoIDs(photos), "a changed photo is the newest")
+	got, err = db.GetPhotos(ctx, e.UUID, cid(t))
+	require.Nil(t, err)
+	require.Equal(t, photoIDs(photos), photoIDs(got))
+	require.Equal(t, "second, cropped.jpg", got[0].Filename)
+	_, err = db.ChangePhoto(ctx, photos, types.Photo{UUID: missing(), Filename: "x.jpg"}, cid(t))
+	require.NotNil(t, err)
+
+	// a photo takes its notes with it
+	photos, err = db.RemovePhoto(ctx, photos, got[0].UUID, cid(t))
+	require.Nil(t, err)
+	require.Equal(t, []types.UUID{got[1].UUID}, photoIDs(photos))
+	_, err = db.RemovePhoto(ctx, photos, got[0].UUID, cid(t))
+	require.NotNil(t, err)
+	notes, err := db.GetNotes(ctx, got[0].UUID, cid(t))
+	require.Nil(t, err)
+	require.Empty(t, notes)
+}
+
+func observer(t *testing.T, db types.DB) {
+	// a new event type, so nothing else has events of it
+	et, err := db.InsertEventType(ctx, types.EventType{Name: "dbtest observation", Severity: "Info", Stage: types.Stage{UUID: "gestation"}}, cid(t))
+	require.Nil(t, err)
+
+	events, err := db.SelectByEventType(ctx, et, cid(t))
+	require.Nil(t, err)
+	require.Empty(t, events)
+
+	w := newWorld(t, db)
+	lc := w.lifecycle(t, db)
+	g := w.generation(t, db)
+	require.Nil(t, db.AddLifecycleEvent(ctx, &lc, types.Event{Temperature: 70, EventType: et}, cid(t)))
+	require.Nil(t, db.AddGenerationEvent(ctx, &g, types.Event{Temperature: 72, EventType: et}, cid(t)))
+	require.Nil(t, db.AddLifecycleEvent(ctx, &lc, types.Event{EventType: types.EventType{UUID: "binning"}}, cid(t)))
+
+	// newest first, whether they're a lifecycle's or a generation's
+	stamp(t, db, "events", lc.Events[1].UUID, ago(1), "mtime", "ctime")
+	stamp(t, db, "events", g.Events[0].UUID, ago(2), "mtime", "ctime")
+	events, err = db.SelectByEventType(ctx, et, cid(t))
+	require.Nil(t, err)
+	require.Equal(t, []types.UUID{lc.Events[1].UUID, g.Events[0].UUID}, eventIDs(events))
+	require.Equal(t, et.Name, events[0].EventType.Name)
+
+	e, err := db.SelectEvent(ctx, g.Events[0].UUID, cid(t))
+	require.Nil(t, err)
+	require.Equal(t, float32(72), e.Temperature)
+	require.Equal(t, et.UUID, e.EventType.UUID)
+	require.Equal(t, et.Name, e.EventType.Name)
+	require.WithinDuration(t, ago(2), e.CTime, 0)
+	_, err = db.SelectEvent(ctx, missing(), cid(t))
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
@@ -0,0 +1,193 @@
+package dbtest
+
+import (
+	"cmp"
+	"database/sql"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/huautla/types"
+)
+
+func vendorer(t *testing.T, db types.DB) {
+	v, err := db.InsertVendor(ctx, types.Vendor{Name: "dbtest vendor", Website: "https://example.com"}, cid(t))
+	require.Nil(t, err)
+	require.NotEmpty(t, v.UUID)
+
+	got, err := db.SelectVendor(ctx, v.UUID, cid(t))
+	require.Nil(t, err)
+	require.Equal(t, v, got)
+	_, err = db.SelectVendor(ctx, missing(), cid(t))
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	v.Name, v.Website = "dbtest vendor, renamed", ""
+	require.Nil(t, db.UpdateVendor(ctx, v.UUID, v, cid(t)))
+	got, err = db.SelectVendor(ctx, v.UUID, cid(t))
+	require.Nil(t, err)
+	require.Equal(t, v, got)
+	require.NotNil(t, db.UpdateVendor(ctx, missing(), v, cid(t)))
+
+	all, err := db.SelectAllVendors(ctx, cid(t))
+	require.Nil(t, err)
+	require.Contains(t, all, v)
+	require.True(t, slices.IsSortedFunc(all, func(a, b types.Vendor) int {
+		return strings.Compare(a.Name, b.Name)
+	}), "vendors are sorted by name")
+
+	// a report is the vendor, with what it sells
+	sub, err := db.InsertSubstrate(ctx, types.Substrate{Name: "rye", Type: types.GrainType, Vendor: v}, cid(t))
+	require.Nil(t, err)
+	rpt, err := db.VendorReport(ctx, v.UUID, cid(t))
+	require.Nil(t, err)
+	require.Equal(t, string(v.UUID), rpt["id"])
+	require.Equal(t, v.Name, rpt["name"])
+	require.Equal(t, []any{string(sub.UUID)}, ids(entities(t, rpt, "substrates")))
+	require.NotContains(t, rpt, "strains")
+	_, err = db.VendorReport(ctx, missing(), cid(t))
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	// a vendor that's still used can't go
+	require.NotNil(t, db.DeleteVendor(ctx, v.UUID, cid(t)))
+	require.Nil(t, db.DeleteSubstrate(ctx, sub.UUID, cid(t)))
+
+	require.Nil(t, db.DeleteVendor(ctx, v.UUID, cid(t)))
+	_, err = db.SelectVendor(ctx, v.UUID, cid(t))
+	require.ErrorIs(t, err, sql.ErrNoRows)
+	require.NotNil(t, db.DeleteVendor(ctx, v.UUID, cid(t)))
+}
+
+func ingredienter(t *testing.T, db types.DB) {
+	i, err := db.InsertIngredient(ctx, types.Ingredient{Name: "dbtest ingredient"}, cid(t))
+	require.Nil(t, err)
+	require.NotEmpty(t, i.UUID)
+
+	got, err := db.SelectIngredient(ctx, i.UUID, cid(t))
+	require.Nil(t, err)
+	require.Equal(t, i, got)
+	_, err = db.SelectIngredient(ctx, missing(), cid(t))
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	i.Name = "dbtest ingredient, renamed"
+	require.Nil(t, db.UpdateIngredient(ctx, i.UUID, i, cid(t)))
+	got, err = db.SelectIngredient(ctx, i.UUID, cid(t))
+	require.Nil(t, err)
+	require.Equal(t, i, got)
+	require.NotNil(t, db.UpdateIngredient(ctx, missing(), i, cid(t)))
+
+	all, err := db.SelectAllIngredients(ctx, cid(t))
+	require.Nil(t, err)
+	require.Contains(t, all, i)
+	require.True(t, slices.IsSortedFunc(all, func(a, b types.Ingredient) int {
+		return strings.Compare(a.Name, b.Name)
+	}), "ingredients are sorted by name")
+
+	require.Nil(t, db.DeleteIngredient(ctx, i.UUID, cid(t)))
+	_, err = db.SelectIngredient(ctx, i.UUID, cid(t))
+	require.ErrorIs(t, err, sql.ErrNoRows)
+	require.NotNil(t, db.DeleteIngredient(ctx, i.UUID, cid(t)))
+}
+
+func stager(t *testing.T, db types.DB) {
+	s, err := db.InsertStage(ctx, types.Stage{Name: "dbtest stage"}, cid(t))
+	require.Nil(t, err)
+	require.NotEmpty(t, s.UUID)
+
+	got, err := db.SelectStage(ctx, s.UUID, cid(t))
+	require.Nil(t, err)
+	require.Equal(t, s, got)
+	_, err = db.SelectStage(ctx, missing(), cid(t))
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	s.Name = "dbtest stage, renamed"
+	require.Nil(t, db.UpdateStage(ctx, s.UUID, s, cid(t)))
+	got, err = db.SelectStage(ctx, s.UUID, cid(t))
+	require.Nil(t, err)
+	require.Equal(t, s, got)
+	require.NotNil(t, db.UpdateStage(ctx, missing(), s, cid(t)))
+
+	// every database starts with these
+	all, err := db.SelectAllStages(ctx, cid(t))
+	require.Nil(t, err)
+	require.Contains(t, all, s)
+	require.Contains(t, all, types.Stage{UUID: "gestation", Name: "Gestation"})
+	require.True(t, slices.IsSortedFunc(all, func(a, b types.Stage) int {
+		return strings.Compare(a.Name, b.Name)
+	}), "stages are sorted by name")
+
+	// a stage that's still used can't go
+	et, err := db.InsertEventType(ctx, types.EventType{Name: "dbtest", Stage: s}, cid(t))
+	require.Nil(t, err)
+	require.NotNil(t, db.DeleteStage(ctx, s.UUID, cid(t)))
+	require.Nil(t, db.DeleteEventType(ctx, et.UUID, cid(t)))
+
+	require.Nil(t, db.DeleteStage(ctx, s.UUID, cid(t)))
+	_, err = db.SelectStage(ctx, s.UUID, cid(t))
+	require.ErrorIs(t, err, sql.ErrNoRows)
+	require.NotNil(t, db.DeleteStage(ctx, s.UUID, cid(t)))
+}
+
+func eventTyper(t *testing.T, db types.DB) {
+	stage, err := db.InsertStage(ctx, types.Stage{Name: "dbtest stage"}, cid(t))
+	require.Nil(t, err)
+
+	et, err := db.InsertEventType(ctx, types.EventType{Name: "dbtest eventtype", Severity: "Info", Stage: types.Stage{UUID: stage.UUID}}, cid(t))
+	require.Nil(t, err)
+	require.NotEmpty(t, et.UUID)
+	_, err = db.InsertEventType(ctx, types.EventType{Name: "dbtest eventtype", Stage: types.Stage{UUID: missing()}}, cid(t))
+	require.NotNil(t, err)
+
+	// the stage comes back whole, even if only its id went in
+	got, err := db.SelectEventType(ctx, et.UUID, cid(t))
+	require.Nil(t, err)
+	et.Stage = stage
+	require.Equal(t, et, got)
+	_, err = db.SelectEventType(ctx, missing(), cid(t))
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	et.Name, et.Severity, et.Stage = "dbtest eventtype, renamed", "Generation", types.Stage{UUID: "majority"}
+	require.Nil(t, db.UpdateEventType(ctx, et.UUID, et, cid(t)))
+	got, err = db.SelectEventType(ctx, et.UUID, cid(t))
+	require.Nil(t, err)
+	require.Equal(t, et.Name, got.Name)
+	require.Equal(t, types.Stage{UUID: "majority", Name: "Majority"}, got.Stage)
+	require.NotNil(t, db.UpdateEventType(ctx, missing(), et, cid(t)))
+
+	// every database starts with the ones the lifecycle index looks for
+	all, err := db.SelectAllEventTypes(ctx, cid(t))
+	require.Nil(t, err)
+	require.Contains(t, all, got)
+	for _, id := range []types.UUID{"sunset", "sporeprint", "clone"} {
+		require.True(t, slices.ContainsFunc(all, func(et types.EventType) bool { return et.UUID == id }), id)
+	}
+	require.True(t, slices.IsSortedFunc(all, func(a, b types.EventType) int {
+		return cmp.Or(strings.Compare(a.Stage.Name, b.Stage.Name), strings.Compare(a.Name, b.Name))
+	}), "eventtypes are sorted by stage, then name")
+
+	// a report is the event type, with whatever has events of its type
+	w := newWorld(t, db)
+	lc := w.lifecycle(t, db)
+	require.Nil(t, db.AddLifecycleEvent(ctx, &lc, types.Event{EventType: et}, cid(t)))
+	g := w.generation(t, db)
+	require.Nil(t, db.AddGenerationEvent(ctx, &g, types.Event{EventType: et}, cid(t)))
+
+	rpt, err := db.EventTypeReport(ctx, et.UUID, cid(t))
+	require.Nil(t, err)
+	require.Equal(t, string(et.UUID), rpt["id"])
+	require.Equal(t, []any{string(lc.UUID)}, ids(entities(t, rpt, "lifecycles")))
+	require.Equal(t, []any{string(g.UUID)}, ids(entities(t, rpt, "generations")))
+	_, err = db.EventTypeReport(ctx, missing(), cid(t))
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	// an event type that's still used can't go
+	require.NotNil(t, db.DeleteEventType(ctx, et.UUID, cid(t)))
+	require.Nil(t, db.DeleteLifecycle(ctx, lc.UUID, cid(t)))
+	require.Nil(t, db.RemoveGenerationEvent(ctx, &g, g.Events[0].UUID, cid(t)))
+
+	require.Nil(t, db.DeleteEventType(ctx, et.UUID, cid(t)))
+	_, err = db.SelectEventType(ctx, et.UUID, cid(t))
+	require.ErrorIs(t, err, sql.ErrNoRows)
+	require.NotNil(t, db.DeleteEventType(ctx, et.UUID, cid(t)))
+}
@@ -0,0 +1,142 @@
+package dbtest
+
+import (
+	"database/sql"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/huautla/types"
+)
+
+func strainer(t *testing.T, db types.DB) {
+	v, err := db.InsertVendor(ctx, types.Vendor{Name: "dbtest vendor"}, cid(t))
+	require.Nil(t, err)
+
+	s, err := db.InsertStrain(ctx, types.Strain{Name: "dbtest strain", Species: "P. ostreatus", Vendor: v}, cid(t))
+	require.Nil(t, err)
+	require.NotEmpty(t, s.UUID)
+	require.False(t, s.CTime.IsZero())
+	require.Nil(t, s.DTime)
+	_, err = db.InsertStrain(ctx, types.Strain{Name: "dbtest strain", Vendor: types.Vendor{UUID: missing()}}, cid(t))
+	require.NotNil(t, err)
+
+	got, err := db.SelectStrain(ctx, s.UUID, cid(t))
+	require.Nil(t, err)
+	require.Equal(t, s.Name, got.Name)
+	require.Equal(t, s.Species, got.Species)
+	require.Equal(t, v, got.Vendor)
+	require.True(t, s.CTime.Equal(got.CTime), "%v != %v", s.CTime, got.CTime)
+	require.Nil(t, got.Generation)
+	require.Empty(t, got.Attributes)
+	_, err = db.SelectStrain(ctx, missing(), cid(t))
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	s.Name, s.Species = "dbtest strain, renamed", "P. djamor"
+	require.Nil(t, db.UpdateStrain(ctx, s.UUID, s, cid(t)))
+	got, err = db.SelectStrain(ctx, s.UUID, cid(t))
+	require.Nil(t, err)
+	require.Equal(t, s.Name, got.Name)
+	require.Equal(t, s.Species, got.Species)
+	require.NotNil(t, db.UpdateStrain(ctx, missing(), s, cid(t)))
+	require.NotNil(t, db.UpdateStrain(ctx, s.UUID, types.Strain{Name: "x", Vendor: types.Vendor{UUID: missing()}}, cid(t)))
+
+	all, err := db.SelectAllStrains(ctx, cid(t))
+	require.Nil(t, err)
+	require.True(t, slices.ContainsFunc(all, func(str types.Strain) bool { return str.UUID == s.UUID }))
+	require.True(t, slices.IsSortedFunc(all, func(a, b types.Strain) int {
+		return strings.Compare(a.Name, b.Name)
+	}), "strains are sorted by name")
+
+	// a strain comes from at most one generation, and a generation makes at
+	// most one strain
+	w := newWorld(t, db)
+	g := w.generation(t, db)
+	_, err = db.GeneratedStrain(ctx, g.UUID, cid(t))
+	require.ErrorIs(t, err, sql.ErrNoRows)
+	require.Nil(t, db.UpdateGeneratedStrain(ctx, &g.UUID, s.UUID, cid(t)))
+	require.ErrorIs(t, db.UpdateGeneratedStrain(ctx, &g.UUID, missing(), cid(t)), sql.ErrNoRows)
+
+	progeny, err := db.GeneratedStrain(ctx, g.UUID, cid(t))
+	require.Nil(t, err)
+	require.Equal(t, s.UUID, progeny.UUID)
+	got, err = db.SelectStrain(ctx, s.UUID, cid(t))
+	require.Nil(t, err)
+	require.Equal(t, g.UUID, got.Generation.UUID)
+
+	// a report is the strain, with where it came from and what came of it,
+	// and a strain and the generation it came from don't go round in
+	// circles
+	lc, err := db.InsertLifecycle(ctx, types.Lifecycle{Strain: s, GrainSubstrate: w.grain, BulkSubstrate: w.bulk}, cid(t))
+	require.Nil(t, err)
+	rpt, err := db.StrainReport(ctx, s.UUID, cid(t))
+	require.Nil(t, err)
+	require.Equal(t, string(s.UUID), rpt["id"])
+	require.Equal(t, string(v.UUID), rpt["vendor"].(map[string]any)["id"])
+	require.Equal(t, []any{string(lc.UUID)}, ids(entities(t, rpt, "lifecycles")))
+	require.Equal(t, string(g.UUID), rpt["generation"].(types.Entity)["id"])
+	require.NotContains(t, rpt["generation"], "progeny")
+	_, err = db.StrainReport(ctx, missing(), cid(t))
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	require.Nil(t, db.UpdateGeneratedStrain(ctx, nil, s.UUID, cid(t)))
+	_, err = db.GeneratedStrain(ctx, g.UUID, cid(t))
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	// deleting a strain only marks it deleted; it's still there, for the
+	// lifecycles that came from it
+	require.Nil(t, db.DeleteStrain(ctx, s.UUID, cid(t)))
+	got, err = db.SelectStrain(ctx, s.UUID, cid(t))
+	require.Nil(t, err)
+	require.NotNil(t, got.DTime)
+	all, err = db.SelectAllStrains(ctx, cid(t))
+	require.Nil(t, err)
+	require.True(t, slices.ContainsFunc(all, func(str types.Strain) bool { return str.UUID == s.UUID && str.DTime != nil }))
+	lc, err = db.SelectLifecycle(ctx, lc.UUID, cid(t))
+	require.Nil(t, err)
+	require.NotNil(t, lc.Strain.DTime)
+	require.NotNil(t, db.DeleteStrain(ctx, missing(), cid(t)))
+}
+
+func strainAttributer(t *testing.T, db types.DB) {
+	w := newWorld(t, db)
+	s := w.strain
+
+	zeta, err := db.AddAttribute(ctx, &s, types.StrainAttribute{Name: "dbtest zeta", Value: "z"}, cid(t))
+	require.Nil(t, err)
+	require.NotEmpty(t, zeta.UUID)
+	alpha, err := db.AddAttribute(ctx, &s, types.StrainAttribute{Name: "dbtest alpha", Value: "a"}, cid(t))
+	require.Nil(t, err)
+	require.Equal(t, []types.StrainAttribute{zeta, alpha}, s.Attributes)
+	_, err = db.AddAttribute(ctx, &types.Strain{UUID: missing()}, types.StrainAttribute{Name: "dbtest", Value: "x"}, cid(t))
+	require.NotNil(t, err)
+
+	// they're sorted by name when they're read back
+	require.Nil(t, db.GetAllAttributes(ctx, &s, cid(t)))
+	require.Equal(t, []types.StrainAttribute{alpha, zeta}, s.Attributes)
+	got, err := db.SelectStrain(ctx, s.UUID, cid(t))
+	require.Nil(t, err)
+	require.Equal(t, s.Attributes, got.Attributes)
+
+	names, err := db.KnownAttributeNames(ctx, cid(t))
+	require.Nil(t, err)
+	require.Contains(t, names, alpha.Name)
+	require.Contains(t, names, zeta.Name)
+	require.True(t, slices.IsSorted(names))
+	require.Equal(t, len(names), len(slices.Compact(slices.Clone(names))), "names are only there once")
+
+	alpha.Value = "b"
+	require.Nil(t, db.ChangeAttribute(ctx, &s, alpha, cid(t)))
+	require.Equal(t, []types.StrainAttribute{alpha, zeta}, s.Attributes)
+	require.NotNil(t, db.ChangeAttribute(ctx, &s, types.StrainAttribute{UUID: missing(), Name: "x", Value: "y"}, cid(t)))
+	require.Nil(t, db.GetAllAttributes(ctx, &s, cid(t)))
+	require.Equal(t, []types.StrainAttribute{alpha, zeta}, s.Attributes)
+
+	require.Nil(t, db.RemoveAttribute(ctx, &s, zeta.UUID, cid(t)))
+	require.Equal(t, []types.StrainAttribute{alpha}, s.Attributes)
+	require.NotNil(t, db.RemoveAttribute(ctx, &s, zeta.UUID, cid(t)))
+	require.Nil(t, db.GetAllAttributes(ctx, &s, cid(t)))
+	require.Equal(t, []types.StrainAttribute{alpha}, s.Attributes)
+}
@@ -0,0 +1,117 @@
+package dbtest
+
+import (
+	"database/sql"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/huautla/types"
+)
+
+// substrater covers SubstrateIngredienter too, since there's not much to
+// a substrate without its ingredients
+func substrater(t *testing.T, db types.DB) {
+	v, err := db.InsertVendor(ctx, types.Vendor{Name: "dbtest vendor"}, cid(t))
+	require.Nil(t, err)
+
+	s, err := db.InsertSubstrate(ctx, types.Substrate{Name: "dbtest substrate", Type: types.GrainType, Vendor: v}, cid(t))
+	require.Nil(t, err)
+	require.NotEmpty(t, s.UUID)
+	_, err = db.InsertSubstrate(ctx, types.Substrate{Name: "dbtest substrate", Type: "mud", Vendor: v}, cid(t))
+	require.NotNil(t, err)
+	_, err = db.InsertSubstrate(ctx, types.Substrate{Name: "dbtest substrate", Type: types.GrainType, Vendor: types.Vendor{UUID: missing()}}, cid(t))
+	require.NotNil(t, err)
+
+	got, err := db.SelectSubstrate(ctx, s.UUID, cid(t))
+	require.Nil(t, err)
+	require.Equal(t, s.Name, got.Name)
+	require.Equal(t, s.Type, got.Type)
+	require.Equal(t, v, got.Vendor)
+	require.Empty(t, got.Ingredients)
+	_, err = db.SelectSubstrate(ctx, missing(), cid(t))
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	s.Name, s.Type = "dbtest substrate, renamed", types.BulkType
+	require.Nil(t, db.UpdateSubstrate(ctx, s.UUID, s, cid(t)))
+	got, err = db.SelectSubstrate(ctx, s.UUID, cid(t))
+	require.Nil(t, err)
+	require.Equal(t, s.Name, got.Name)
+	require.Equal(t, types.BulkType, got.Type)
+	require.NotNil(t, db.UpdateSubstrate(ctx, missing(), s, cid(t)))
+	require.NotNil(t, db.UpdateSubstrate(ctx, s.UUID, types.Substrate{Name: "x", Type: types.BulkType, Vendor: types.Vendor{UUID: missing()}}, cid(t)))
+
+	// ingredients are sorted by name, and each can only be in once
+	ing := make([]types.Ingredient, 0, 3)
+	for _, name := range []string{"dbtest vermiculite", "dbtest coir", "dbtest gypsum"} {
+		i, err := db.InsertIngredient(ctx, types.Ingredient{Name: name}, cid(t))
+		require.Nil(t, err)
+		ing = append(ing, i)
+	}
+	require.Nil(t, db.AddIngredient(ctx, &s, ing[0], cid(t)))
+	require.Nil(t, db.AddIngredient(ctx, &s, ing[1], cid(t)))
+	require.Equal(t, ing[:2], s.Ingredients)
+	require.NotNil(t, db.AddIngredient(ctx, &s, ing[1], cid(t)))
+	require.NotNil(t, db.AddIngredient(ctx, &s, types.Ingredient{UUID: missing()}, cid(t)))
+
+	require.Nil(t, db.GetAllIngredients(ctx, &s, cid(t)))
+	require.Equal(t, []types.Ingredient{ing[1], ing[0]}, s.Ingredients)
+	got, err = db.SelectSubstrate(ctx, s.UUID, cid(t))
+	require.Nil(t, err)
+	require.Equal(t, s.Ingredients, got.Ingredients)
+
+	require.Nil(t, db.ChangeIngredient(ctx, &s, ing[0], ing[2], cid(t)))
+	require.Equal(t, []types.Ingredient{ing[1], ing[2]}, s.Ingredients)
+	require.NotNil(t, db.ChangeIngredient(ctx, &s, ing[0], ing[2], cid(t)))
+	require.Nil(t, db.GetAllIngredients(ctx, &s, cid(t)))
+	require.Equal(t, []types.Ingredient{ing[1], ing[2]}, s.Ingredients)
+
+	require.Nil(t, db.RemoveIngredient(ctx, &s, ing[1], cid(t)))
+	require.Equal(t, []types.Ingredient{ing[2]}, s.Ingredients)
+	require.NotNil(t, db.RemoveIngredient(ctx, &s, ing[1], cid(t)))
+	require.Nil(t, db.GetAllIngredients(ctx, &s, cid(t)))
+	require.Equal(t, []types.Ingredient{ing[2]}, s.Ingredients)
+
+	// an ingredient that's in a substrate can't go
+	require.NotNil(t, db.DeleteIngredient(ctx, ing[2].UUID, cid(t)))
+	require.Nil(t, db.DeleteIngredient(ctx, ing[0].UUID, cid(t)))
+
+	all, err := db.SelectAllSubstrates(ctx, cid(t))
+	require.Nil(t, err)
+	require.True(t, slices.ContainsFunc(all, func(sub types.Substrate) bool { return sub.UUID == s.UUID }))
+	require.True(t, slices.IsSortedFunc(all, func(a, b types.Substrate) int {
+		return strings.Compare(a.Name, b.Name)
+	}), "substrates are sorted by name")
+
+	// a report is the substrate, with the generations that were plated or
+	// fed with it, or the lifecycles that grew in it
+	w := newWorld(t, db)
+	lc := w.lifecycle(t, db)
+	g := w.generation(t, db)
+
+	rpt, err := db.SubstrateReport(ctx, w.bulk.UUID, cid(t))
+	require.Nil(t, err)
+	require.Equal(t, string(w.bulk.UUID), rpt["id"])
+	require.Equal(t, string(types.BulkType), rpt["type"])
+	require.Equal(t, []any{string(lc.UUID)}, ids(entities(t, rpt, "lifecycles")))
+	require.NotContains(t, rpt, "generations")
+
+	rpt, err = db.SubstrateReport(ctx, w.plating.UUID, cid(t))
+	require.Nil(t, err)
+	require.Equal(t, []any{string(g.UUID)}, ids(entities(t, rpt, "generations")))
+	require.NotContains(t, rpt, "lifecycles")
+
+	_, err = db.SubstrateReport(ctx, missing(), cid(t))
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	// a substrate that's still used can't go
+	require.NotNil(t, db.DeleteSubstrate(ctx, w.bulk.UUID, cid(t)))
+	require.NotNil(t, db.DeleteSubstrate(ctx, w.plating.UUID, cid(t)))
+
+	require.Nil(t, db.DeleteSubstrate(ctx, s.UUID, cid(t)))
+	_, err = db.SelectSubstrate(ctx, s.UUID, cid(t))
+	require.ErrorIs(t, err, sql.ErrNoRows)
+	require.NotNil(t, db.DeleteSubstrate(ctx, s.UUID, cid(t)))
+}
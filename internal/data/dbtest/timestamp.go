@@ -0,0 +1,101 @@
+package dbtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/huautla/types"
+)
+
+func timestamper(t *testing.T, db types.DB) {
+	w := newWorld(t, db)
+	lc := w.lifecycle(t, db)
+	g := w.generation(t, db)
+
+	// an origin, moved along by its factors
+	origin := time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC)
+	require.Nil(t, db.UpdateTimestamps(ctx, "generations", g.UUID, types.Timestamp{
+		Fields: []string{"ctime", "mtime"},
+		Factor: []struct {
+			Delta    int    `json:"delta,omitempty"`
+			Interval string `json:"interval,omitempty"`
+		}{{Delta: 1, Interval: "month"}},
+		Origin: &origin,
+	}))
+	g, err := db.SelectGeneration(ctx, g.UUID, cid(t))
+	require.Nil(t, err)
+	require.WithinDuration(t, time.Date(2026, 2, 28, 0, 0, 0, 0, time.UTC), g.CTime, 0)
+	require.WithinDuration(t, g.CTime, g.MTime, 0)
+
+	when := ago(10)
+	stamp(t, db, "lifecycles", lc.UUID, when, "ctime")
+	lc, err = db.SelectLifecycle(ctx, lc.UUID, cid(t))
+	require.Nil(t, err)
+	require.WithinDuration(t, when, lc.CTime, 0)
+
+	tcs := map[string]struct {
+		table string
+		id    types.UUID
+		data  types.Timestamp
+	}{
+		"no_fields": {
+			table: "lifecycles",
+			id:    lc.UUID,
+			data:  types.Timestamp{Origin: &when},
+		},
+		"no_origin": {
+			table: "lifecycles",
+			id:    lc.UUID,
+			data:  types.Timestamp{Fields: []string{"ctime"}},
+		},
+		"no_such_table": {
+			table: "vendors",
+			id:    w.vendor.UUID,
+			data:  types.Timestamp{Fields: []string{"ctime"}, Origin: &when},
+		},
+		"no_such_field": {
+			table: "lifecycles",
+			id:    lc.UUID,
+			data:  types.Timestamp{Fields: []string{"dtime"}, Origin: &when},
+		},
+		"injection": {
+			table: "lifecycles",
+			id:    lc.UUID,
+			data:  types.Timestamp{Fields: []string{"ctime = now(), mtime"}, Origin: &when},
+		},
+		"no_such_row": {
+			table: "lifecycles",
+			id:    missing(),
+			data:  types.Timestamp{Fields: []string{"ctime"}, Origin: &when},
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			require.NotNil(t, db.UpdateTimestamps(ctx, tc.table, tc.id, tc.data))
+		})
+	}
+	lc, err = db.SelectLifecycle(ctx, lc.UUID, cid(t))
+	require.Nil(t, err)
+	require.WithinDuration(t, when, lc.CTime, 0, "nothing changed")
+
+	// only what deletes by marking can be undeleted
+	require.Nil(t, db.DeleteGeneration(ctx, g.UUID, cid(t)))
+	require.Nil(t, db.Undelete(ctx, "generations", g.UUID))
+	g, err = db.SelectGeneration(ctx, g.UUID, cid(t))
+	require.Nil(t, err)
+	require.Nil(t, g.DTime)
+
+	require.Nil(t, db.DeleteStrain(ctx, w.strain.UUID, cid(t)))
+	require.Nil(t, db.Undelete(ctx, "strains", w.strain.UUID))
+	s, err := db.SelectStrain(ctx, w.strain.UUID, cid(t))
+	require.Nil(t, err)
+	require.Nil(t, s.DTime)
+
+	require.NotNil(t, db.Undelete(ctx, "lifecycles", lc.UUID))
+	require.NotNil(t, db.Undelete(ctx, "vendors", w.vendor.UUID))
+	require.NotNil(t, db.Undelete(ctx, "strains", missing()))
+}
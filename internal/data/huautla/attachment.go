@@ -0,0 +1,247 @@
+package huautla
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/store"
+	"github.com/jsmit257/huautla/types"
+)
+
+// attachment is a file that isn't a photo: invoices, lab results,
+// spreadsheets and the like; the list of them for each owner is kept in the
+// store, keyed by the owner's id, and the files themselves live in their own
+// directory, named by the attachment's id
+type attachment struct {
+	UUID        types.UUID `json:"id"`
+	Filename    string     `json:"filename"`
+	ContentType string     `json:"content_type"`
+	Size        int64      `json:"size"`
+	MTime       time.Time  `json:"mtime"`
+	CTime       time.Time  `json:"ctime"`
+}
+
+const attachmentTable = "attachments"
+
+var (
+	errAttachmentTooLarge = errors.New("attachment is too large")
+	errAttachmentType     = errors.New("attachment type isn't allowed")
+)
+
+func (ha *HuautlaAdaptor) GetAttachments(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ms := ha.start(ctx, "GetAttachments")
+
+	if oID, err := getUUIDByName("o_id", w, r, ms); err != nil {
+		ms.error(w, err, http.StatusBadRequest, "failed to fetch uuid")
+	} else if atts, err := ha.attachments(ctx, oID); err != nil {
+		ms.error(w, err, http.StatusInternalServerError, "failed to fetch attachments")
+	} else {
+		ms.send(w, http.StatusOK, atts)
+	}
+}
+
+func (ha *HuautlaAdaptor) GetAttachment(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ms := ha.start(ctx, "GetAttachment")
+
+	if oID, err := getUUIDByName("o_id", w, r, ms); err != nil {
+		ms.error(w, err, http.StatusBadRequest, "failed to fetch uuid")
+	} else if id, err := getUUIDByName("id", w, r, ms); err != nil {
+		ms.error(w, err, http.StatusBadRequest, "failed to fetch uuid")
+	} else if atts, err := ha.attachments(ctx, oID); err != nil {
+		ms.error(w, err, http.StatusInternalServerError, "failed to fetch attachments")
+	} else if i := slices.IndexFunc(atts, func(a attachment) bool { return a.UUID == id }); i == -1 {
+		ms.error(w, fmt.Errorf("no such attachment: %s", id), http.StatusNotFound, "no such attachment")
+	} else if data, err := ha.reader(path.Join(ha.attachmentDir, string(id))); err != nil {
+		ms.error(w, err, http.StatusInternalServerError, "failed to read attachment")
+	} else {
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+			"filename": atts[i].Filename,
+		}))
+		ms.write(w, http.StatusOK, atts[i].ContentType, data)
+	}
+}
+
+func (ha *HuautlaAdaptor) PostAttachment(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ms := ha.start(ctx, "PostAttachment")
+	defer r.Body.Close()
+
+	ha.attachmentMtx.Lock()
+	defer ha.attachmentMtx.Unlock()
+
+	a := attachment{UUID: types.UUID(uuid.New().String())}
+
+	if oID, err := getUUIDByName("o_id", w, r, ms); err != nil {
+		ms.error(w, err, http.StatusBadRequest, "failed to fetch uuid")
+	} else if atts, err := ha.attachments(ctx, oID); err != nil {
+		ms.error(w, err, http.StatusInternalServerError, "failed to fetch attachments")
+	} else if err = ha.writeAttachment(w, r, &a); err != nil {
+		ms.error(w, err, attachmentStatus(err), "couldn't read/write request body")
+	} else if atts, err = ha.putAttachments(ctx, oID, append(atts, a)); err != nil {
+		ms.error(w, err, http.StatusInternalServerError, "failed to add attachment")
+	} else {
+		ha.emit(r.Context(), ms, "attachment.added", a.UUID, owned{oID, a})
+		ms.send(w, http.StatusOK, atts)
+	}
+}
+
+func (ha *HuautlaAdaptor) PatchAttachment(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ms := ha.start(ctx, "PatchAttachment")
+	defer r.Body.Close()
+
+	ha.attachmentMtx.Lock()
+	defer ha.attachmentMtx.Unlock()
+
+	if oID, err := getUUIDByName("o_id", w, r, ms); err != nil {
+		ms.error(w, err, http.StatusBadRequest, "failed to fetch uuid")
+	} else if id, err := getUUIDByName("id", w, r, ms); err != nil {
+		ms.error(w, err, http.StatusBadRequest, "failed to fetch uuid")
+	} else if atts, err := ha.attachments(ctx, oID); err != nil {
+		ms.error(w, err, http.StatusInternalServerError, "failed to fetch attachments")
+	} else if i := slices.IndexFunc(atts, func(a attachment) bool { return a.UUID == id }); i == -1 {
+		ms.error(w, fmt.Errorf("no such attachment: %s", id), http.StatusNotFound, "no such attachment")
+	} else if err = ha.writeAttachment(w, r, &atts[i]); err != nil {
+		ms.error(w, err, attachmentStatus(err), "couldn't read/write request body")
+	} else if atts, err = ha.putAttachments(ctx, oID, atts); err != nil {
+		ms.error(w, err, http.StatusInternalServerError, "failed to change attachment")
+	} else {
+		ha.emit(r.Context(), ms, "attachment.changed", id, owned{oID, atts[i]})
+		ms.send(w, http.StatusOK, atts)
+	}
+}
+
+func (ha *HuautlaAdaptor) DeleteAttachment(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ms := ha.start(ctx, "DeleteAttachment")
+
+	ha.attachmentMtx.Lock()
+	defer ha.attachmentMtx.Unlock()
+
+	if oID, err := getUUIDByName("o_id", w, r, ms); err != nil {
+		ms.error(w, err, http.StatusBadRequest, "failed to fetch uuid")
+	} else if id, err := getUUIDByName("id", w, r, ms); err != nil {
+		ms.error(w, err, http.StatusBadRequest, "failed to fetch uuid")
+	} else if atts, err := ha.attachments(ctx, oID); err != nil {
+		ms.error(w, err, http.StatusInternalServerError, "failed to fetch attachments")
+	} else if i := slices.IndexFunc(atts, func(a attachment) bool { return a.UUID == id }); i == -1 {
+		ms.error(w, fmt.Errorf("no such attachment: %s", id), http.StatusNotFound, "no such attachment")
+	} else if atts, err = ha.putAttachments(ctx, oID, slices.Delete(atts, i, i+1)); err != nil {
+		ms.error(w, err, http.StatusInternalServerError, "failed to remove attachment")
+	} else {
+		ha.emit(r.Context(), ms, "attachment.removed", id, owned{oID, nil})
+		ms.send(w, http.StatusOK, atts)
+	}
+}
+
+func (ha *HuautlaAdaptor) attachments(ctx context.Context, oID types.UUID) ([]attachment, error) {
+	result := []attachment{}
+	if err := ha.store.Get(ctx, attachmentTable, string(oID), &result); errors.Is(err, store.ErrNotFound) {
+		return result, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (ha *HuautlaAdaptor) putAttachments(ctx context.Context, oID types.UUID, atts []attachment) ([]attachment, error) {
+	if len(atts) == 0 {
+		if err := ha.store.Delete(ctx, attachmentTable, string(oID)); err != nil && !errors.Is(err, store.ErrNotFound) {
+			return nil, err
+		}
+		return []attachment{}, nil
+	}
+	return atts, ha.store.Put(ctx, attachmentTable, string(oID), atts)
+}
+
+// writeAttachment checks the uploaded file against the size limit and the
+// allowed types, writes it and fills in everything about a but its id
+func (ha *HuautlaAdaptor) writeAttachment(w http.ResponseWriter, r *http.Request, a *attachment) error {
+	// leave some room for the rest of the multipart form
+	r.Body = http.MaxBytesReader(w, r.Body, ha.attachmentMaxSize+1<<16)
+
+	var mbe *http.MaxBytesError
+	if err := r.ParseMultipartForm(1 << 16); errors.As(err, &mbe) {
+		return fmt.Errorf("%w: limit is %d bytes", errAttachmentTooLarge, ha.attachmentMaxSize)
+	} else if err != nil {
+		return err
+	}
+
+	f, fh, err := r.FormFile("file")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return err
+	} else if len(data) == 0 {
+		return fmt.Errorf("attachment is empty")
+	} else if int64(len(data)) > ha.attachmentMaxSize {
+		return fmt.Errorf("%w: limit is %d bytes", errAttachmentTooLarge, ha.attachmentMaxSize)
+	}
+
+	// some browsers send the whole client-side path
+	filename := path.Base(strings.ReplaceAll(fh.Filename, `\`, "/"))
+	if filename == "." || filename == "/" {
+		filename = "attachment"
+	}
+
+	ct := attachmentType(data, filename, fh.Header.Get("Content-Type"))
+	if !slices.Contains(ha.attachmentTypes, ct) {
+		return fmt.Errorf("%w: %s", errAttachmentType, ct)
+	}
+
+	if err = ha.filer(path.Join(ha.attachmentDir, string(a.UUID)), data, 0644); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	if a.CTime.IsZero() {
+		a.CTime = now
+	}
+	a.MTime = now
+	a.Filename = filename
+	a.ContentType = ct
+	a.Size = int64(len(data))
+
+	return nil
+}
+
+// attachmentType trusts the content over the client; the exception is text,
+// where the extension or the client's word is all there is to tell csv apart
+func attachmentType(data []byte, filename, declared string) string {
+	sniffed, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+	if sniffed != "text/plain" {
+		return sniffed
+	}
+
+	declared, _, _ = mime.ParseMediaType(declared)
+	if strings.EqualFold(path.Ext(filename), ".csv") || declared == "text/csv" {
+		return "text/csv"
+	}
+
+	return sniffed
+}
+
+func attachmentStatus(err error) int {
+	if errors.Is(err, errAttachmentTooLarge) {
+		return http.StatusRequestEntityTooLarge
+	} else if errors.Is(err, errAttachmentType) {
+		return http.StatusUnsupportedMediaType
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,260 @@
+package huautla
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"sync"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/store"
+	"github.com/jsmit257/centerforfunguscontrol/shared/metrics"
+	"github.com/jsmit257/huautla/types"
+)
+
+// fakeDir is a directory of files for filer and reader
+type fakeDir struct {
+	sync.Mutex
+	files map[string][]byte
+}
+
+func (fd *fakeDir) write(name string, data []byte, _ fs.FileMode) error {
+	fd.Lock()
+	defer fd.Unlock()
+	fd.files[name] = data
+	return nil
+}
+
+func (fd *fakeDir) read(name string) ([]byte, error) {
+	fd.Lock()
+	defer fd.Unlock()
+	if data, ok := fd.files[name]; ok {
+		return data, nil
+	}
+	return nil, fs.ErrNotExist
+}
+
+func newAttachmentAdaptor() (*HuautlaAdaptor, *fakeDir) {
+	fd := &fakeDir{files: map[string][]byte{}}
+	return &HuautlaAdaptor{
+		filer:             fd.write,
+		reader:            fd.read,
+		store:             store.NewMem(),
+		attachmentDir:     "attachments",
+		attachmentMaxSize: 64,
+		attachmentTypes:   []string{"application/pdf", "text/csv", "text/plain"},
+	}, fd
+}
+
+func sendAttachment(f http.HandlerFunc, meth string, params chi.RouteParams, filename, contentType string, data []byte) *httptest.ResponseRecorder {
+	b := &bytes.Buffer{}
+	mw := multipart.NewWriter(b)
+	if filename != "" {
+		h := textproto.MIMEHeader{}
+		h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename=%q`, filename))
+		if contentType != "" {
+			h.Set("Content-Type", contentType)
+		}
+		fw, _ := mw.CreatePart(h)
+		_, _ = fw.Write(data)
+	}
+	mw.Close()
+
+	w := httptest.NewRecorder()
+	rctx := chi.NewRouteContext()
+	rctx.URLParams = params
+	r, _ := http.NewRequestWithContext(
+		context.WithValue(
+			metrics.MockServiceContext,
+			chi.RouteCtxKey,
+			rctx),
+		meth,
+		"url",
+		b)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+
+	f(w, r)
+
+	return w
+}
+
+func Test_PostAttachment(t *testing.T) {
+	t.Parallel()
+
+	set := map[string]struct {
+		oID         types.UUID
+		filename    string
+		contentType string
+		data        []byte
+		result      attachment
+		sc          int
+	}{
+		"pdf": {
+			oID:      "vendor",
+			filename: "invoice.pdf",
+			data:     []byte("%PDF-1.4\n%%EOF\n"),
+			result:   attachment{Filename: "invoice.pdf", ContentType: "application/pdf", Size: 15},
+			sc:       http.StatusOK,
+		},
+		"csv_by_extension": {
+			oID:      "substrate",
+			filename: "batch.CSV",
+			data:     []byte("a,b\n1,2\n"),
+			result:   attachment{Filename: "batch.CSV", ContentType: "text/csv", Size: 8},
+			sc:       http.StatusOK,
+		},
+		"csv_by_content_type": {
+			oID:         "substrate",
+			filename:    "export",
+			contentType: "text/csv",
+			data:        []byte("a,b\n1,2\n"),
+			result:      attachment{Filename: "export", ContentType: "text/csv", Size: 8},
+			sc:          http.StatusOK,
+		},
+		"text_with_client_path": {
+			oID:      "lifecycle",
+			filename: `C:\Users\grower\lab results.txt`,
+			data:     []byte("no contamination\n"),
+			result:   attachment{Filename: "lab results.txt", ContentType: "text/plain", Size: 17},
+			sc:       http.StatusOK,
+		},
+		"disguised_image": {
+			oID:         "generation",
+			filename:    "invoice.pdf",
+			contentType: "application/pdf",
+			data:        []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n'},
+			sc:          http.StatusUnsupportedMediaType,
+		},
+		"too_large": {
+			oID:      "generation",
+			filename: "big.txt",
+			data:     bytes.Repeat([]byte("x"), 65),
+			sc:       http.StatusRequestEntityTooLarge,
+		},
+		"way_too_large": {
+			oID:      "generation",
+			filename: "huge.txt",
+			data:     bytes.Repeat([]byte("x"), 1<<17),
+			sc:       http.StatusRequestEntityTooLarge,
+		},
+		"empty": {
+			oID:      "generation",
+			filename: "empty.txt",
+			sc:       http.StatusBadRequest,
+		},
+		"missing_file": {
+			oID: "generation",
+			sc:  http.StatusBadRequest,
+		},
+		"missing_id": {
+			filename: "invoice.pdf",
+			data:     []byte("%PDF-1.4\n%%EOF\n"),
+			sc:       http.StatusBadRequest,
+		},
+	}
+
+	for k, v := range set {
+		k, v := k, v
+		t.Run(k, func(t *testing.T) {
+			t.Parallel()
+
+			ha, fd := newAttachmentAdaptor()
+			w := sendAttachment(
+				ha.PostAttachment,
+				http.MethodPost,
+				chi.RouteParams{Keys: []string{"o_id"}, Values: []string{string(v.oID)}},
+				v.filename,
+				v.contentType,
+				v.data)
+
+			require.Equal(t, v.sc, w.Code, w.Body.String())
+			if v.sc != http.StatusOK {
+				require.Empty(t, fd.files)
+				return
+			}
+
+			var atts []attachment
+			require.Nil(t, json.Unmarshal(w.Body.Bytes(), &atts))
+			require.Len(t, atts, 1)
+			require.NotEmpty(t, atts[0].UUID)
+			require.False(t, atts[0].CTime.IsZero())
+			require.Equal(t, v.result.Filename, atts[0].Filename)
+			require.Equal(t, v.result.ContentType, atts[0].ContentType)
+			require.Equal(t, v.result.Size, atts[0].Size)
+			require.Equal(t, v.data, fd.files["attachments/"+string(atts[0].UUID)])
+		})
+	}
+}
+
+func Test_Attachments(t *testing.T) {
+	t.Parallel()
+
+	ha, _ := newAttachmentAdaptor()
+	params := func(ids ...string) chi.RouteParams {
+		return chi.RouteParams{Keys: []string{"o_id", "id"}[:len(ids)], Values: ids}
+	}
+	get := func(f http.HandlerFunc, ids ...string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		rctx := chi.NewRouteContext()
+		rctx.URLParams = params(ids...)
+		r, _ := http.NewRequestWithContext(
+			context.WithValue(
+				metrics.MockServiceContext,
+				chi.RouteCtxKey,
+				rctx),
+			http.MethodGet,
+			"url",
+			nil)
+		f(w, r)
+		return w
+	}
+	list := func(w *httptest.ResponseRecorder) []attachment {
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var result []attachment
+		require.Nil(t, json.Unmarshal(w.Body.Bytes(), &result))
+		return result
+	}
+
+	require.Empty(t, list(get(ha.GetAttachments, "vendor")))
+
+	list(sendAttachment(ha.PostAttachment, http.MethodPost, params("vendor"), "résumé.txt", "", []byte("hire me")))
+	atts := list(sendAttachment(ha.PostAttachment, http.MethodPost, params("vendor"), "invoice.pdf", "", []byte("%PDF-1.4")))
+	require.Len(t, atts, 2)
+	require.Equal(t, atts, list(get(ha.GetAttachments, "vendor")))
+	require.Empty(t, list(get(ha.GetAttachments, "substrate")))
+
+	w := get(ha.GetAttachment, "vendor", string(atts[0].UUID))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "text/plain", w.Header().Get("Content-Type"))
+	require.Equal(t, `attachment; filename*=utf-8''r%C3%A9sum%C3%A9.txt`, w.Header().Get("Content-Disposition"))
+	body, _ := io.ReadAll(w.Body)
+	require.Equal(t, "hire me", string(body))
+
+	require.Equal(t, http.StatusNotFound, get(ha.GetAttachment, "substrate", string(atts[0].UUID)).Code)
+	require.Equal(t, http.StatusBadRequest, get(ha.GetAttachment, "vendor", "%zzz").Code)
+
+	changed := list(sendAttachment(ha.PatchAttachment, http.MethodPatch, params("vendor", string(atts[0].UUID)), "prices.csv", "", []byte("price\n9.99\n")))
+	require.Len(t, changed, 2)
+	require.Equal(t, atts[0].UUID, changed[0].UUID)
+	require.Equal(t, atts[0].CTime, changed[0].CTime)
+	require.Equal(t, "prices.csv", changed[0].Filename)
+	require.Equal(t, "text/csv", changed[0].ContentType)
+
+	w = sendAttachment(ha.PatchAttachment, http.MethodPatch, params("vendor", "missing"), "prices.csv", "", []byte("price\n"))
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	remaining := list(get(ha.DeleteAttachment, "vendor", string(atts[0].UUID)))
+	require.Equal(t, []types.UUID{atts[1].UUID}, []types.UUID{remaining[0].UUID})
+	require.Equal(t, http.StatusNotFound, get(ha.DeleteAttachment, "vendor", string(atts[0].UUID)).Code)
+	require.Empty(t, list(get(ha.DeleteAttachment, "vendor", string(atts[1].UUID))))
+}
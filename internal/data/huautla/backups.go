@@ -0,0 +1,69 @@
+package huautla
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/backup"
+)
+
+// GetBackups sends how scheduled backups are going, and the ones there are
+func (ha *HuautlaAdaptor) GetBackups(w http.ResponseWriter, r *http.Request) {
+	ms := ha.start(r.Context(), "GetBackups")
+
+	if ha.backups == nil {
+		ms.error(w, fmt.Errorf("backups aren't configured"), http.StatusNotFound, "backups aren't configured")
+	} else if status, err := ha.backups.Status(r.Context()); err != nil {
+		ms.error(w, err, http.StatusInternalServerError, "failed to list backups")
+	} else {
+		ms.send(w, http.StatusOK, status)
+	}
+}
+
+// PostBackup takes a backup now, without waiting for the schedule; it keeps
+// going if the client hangs up
+func (ha *HuautlaAdaptor) PostBackup(w http.ResponseWriter, r *http.Request) {
+	ms := ha.start(r.Context(), "PostBackup")
+
+	if ha.backups == nil {
+		ms.error(w, fmt.Errorf("backups aren't configured"), http.StatusNotFound, "backups aren't configured")
+	} else if b, err := ha.backups.Backup(context.WithoutCancel(r.Context())); errors.Is(err, backup.ErrRunning) {
+		ms.error(w, err, http.StatusConflict, "a backup is already running")
+	} else if err != nil {
+		ms.error(w, err, http.StatusInternalServerError, "failed to take backup")
+	} else {
+		ms.send(w, http.StatusCreated, b)
+	}
+}
+
+// PostVerifyBackup checks a backup against its checksum, and that it can be
+// read all the way through
+func (ha *HuautlaAdaptor) PostVerifyBackup(w http.ResponseWriter, r *http.Request) {
+	ms := ha.start(r.Context(), "PostVerifyBackup")
+
+	if ha.backups == nil {
+		ms.error(w, fmt.Errorf("backups aren't configured"), http.StatusNotFound, "backups aren't configured")
+	} else if name := chi.URLParam(r, "name"); name == "" {
+		ms.error(w, fmt.Errorf("missing required parameter: name"), http.StatusBadRequest, "missing required parameter")
+	} else if name, err := url.PathUnescape(name); err != nil {
+		ms.error(w, fmt.Errorf("malformed parameter: name"), http.StatusBadRequest, "malformed parameter")
+	} else if err = ha.backups.Verify(r.Context(), name); errors.Is(err, backup.ErrNotFound) {
+		ms.error(w, err, http.StatusNotFound, "no such backup")
+	} else if err != nil {
+		// it's the backup that's broken, not the request
+		ms.send(w, http.StatusOK, backupCheck{Name: name, Error: err.Error()})
+	} else {
+		ms.send(w, http.StatusOK, backupCheck{Name: name, OK: true})
+	}
+}
+
+type backupCheck struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
@@ -0,0 +1,133 @@
+package huautla
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/backup"
+	"github.com/jsmit257/centerforfunguscontrol/internal/config"
+)
+
+func Test_backups(t *testing.T) {
+	t.Parallel()
+
+	ha := newExportable(t, true)
+	s, err := backup.NewScheduler(backup.Dir(t.TempDir()), ha.export, backup.Config{Every: time.Hour}, logrus.WithField("test", t.Name()))
+	require.Nil(t, err)
+	ha.backups = s
+
+	w := sendWebhook(ha.PostBackup, http.MethodPost, "url", chi.RouteParams{}, "")
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	b := backup.Backup{}
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &b))
+
+	w = sendWebhook(ha.GetBackups, http.MethodGet, "url", chi.RouteParams{}, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	status := backup.Status{}
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &status))
+	require.Equal(t, []backup.Backup{b}, status.Backups)
+	require.Empty(t, status.LastError)
+
+	tcs := map[string]struct {
+		name  string
+		sc    int
+		check backupCheck
+	}{
+		"happy_path": {
+			name:  b.Name,
+			sc:    http.StatusOK,
+			check: backupCheck{Name: b.Name, OK: true},
+		},
+		"no_such_backup": {
+			name: "cffc-backup-20000101T000000Z.tar.gz",
+			sc:   http.StatusNotFound,
+		},
+		"not_a_backup": {
+			name: "../../etc/passwd",
+			sc:   http.StatusNotFound,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			w := sendWebhook(ha.PostVerifyBackup, http.MethodPost, "url", chi.RouteParams{
+				Keys:   []string{"name"},
+				Values: []string{tc.name},
+			}, "")
+			require.Equal(t, tc.sc, w.Code, w.Body.String())
+			if tc.sc == http.StatusOK {
+				check := backupCheck{}
+				require.Nil(t, json.Unmarshal(w.Body.Bytes(), &check))
+				require.Equal(t, tc.check, check)
+			}
+		})
+	}
+}
+
+func Test_backupsNotConfigured(t *testing.T) {
+	t.Parallel()
+
+	ha := &HuautlaAdaptor{}
+	for _, h := range []http.HandlerFunc{ha.GetBackups, ha.PostBackup, ha.PostVerifyBackup} {
+		w := sendWebhook(h, http.MethodGet, "url", chi.RouteParams{}, "")
+		require.Equal(t, http.StatusNotFound, w.Code)
+	}
+}
+
+func Test_backupDestination(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		cfg    config.Config
+		dest   backup.Destination
+		bucket bool
+		err    bool
+	}{
+		"neither": {},
+		"dir": {
+			cfg:  config.Config{BackupDir: "/backups"},
+			dest: backup.Dir("/backups"),
+		},
+		"bucket": {
+			cfg: config.Config{
+				BackupBucket:          "https://s3.us-east-1.amazonaws.com/cffc",
+				BackupBucketRegion:    "us-east-1",
+				BackupBucketAccessKey: "key",
+				BackupBucketSecretKey: "secret",
+			},
+			bucket: true,
+		},
+		"bucket_without_keys": {
+			cfg: config.Config{BackupBucket: "https://s3.us-east-1.amazonaws.com/cffc", BackupBucketRegion: "us-east-1"},
+			err: true,
+		},
+		"both": {
+			cfg: config.Config{BackupDir: "/backups", BackupBucket: "https://s3.us-east-1.amazonaws.com/cffc"},
+			err: true,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dest, err := backupDestination(&tc.cfg)
+			require.Equal(t, tc.err, err != nil, err)
+			if tc.bucket {
+				require.IsType(t, &backup.Bucket{}, dest)
+			} else {
+				require.Equal(t, tc.dest, dest)
+			}
+		})
+	}
+}
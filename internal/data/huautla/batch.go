@@ -0,0 +1,375 @@
+package huautla
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sirupsen/logrus"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/data/txn"
+	"github.com/jsmit257/centerforfunguscontrol/internal/events"
+	"github.com/jsmit257/centerforfunguscontrol/internal/idempotency"
+	"github.com/jsmit257/huautla/types"
+)
+
+type (
+	batchRequest struct {
+		// if anything fails, nothing any of the requests did happened, and
+		// nothing after it is tried; only databases that do transactions
+		// can do this
+		Atomic   bool        `json:"atomic"`
+		Requests []batchItem `json:"requests"`
+	}
+
+	batchItem struct {
+		Method  string            `json:"method"`
+		Path    string            `json:"path"`
+		Headers map[string]string `json:"headers,omitempty"`
+		Body    json.RawMessage   `json:"body,omitempty"`
+	}
+
+	batchResult struct {
+		Status int `json:"status"`
+		// whatever the request created or changed, for later requests to
+		// refer to as ${n}
+		ID   types.UUID      `json:"id,omitempty"`
+		Body json.RawMessage `json:"body,omitempty"`
+		// why a request wasn't tried, or a response that wasn't json
+		Error string `json:"error,omitempty"`
+	}
+
+	batchResponse struct {
+		Atomic    bool `json:"atomic"`
+		Succeeded int  `json:"succeeded"`
+		Failed    int  `json:"failed"`
+		// an atomic batch that failed was rolled back, so none of its
+		// results happened, even the ones that say they did
+		RolledBack bool          `json:"rolled_back,omitempty"`
+		Results    []batchResult `json:"results"`
+		// why an atomic batch that didn't fail couldn't be committed
+		Error string `json:"error,omitempty"`
+	}
+
+	// batchEvents are the events one request in a batch published, or in an
+	// atomic batch, would have published if it weren't waiting to be
+	// committed
+	batchEvents struct {
+		mtx    sync.Mutex
+		hold   bool
+		events []events.Event
+	}
+
+	batchEventsKey struct{}
+)
+
+const (
+	// the default for the largest batch anyone can send
+	defaultBatchMax = 100
+
+	batchPath = "/batch"
+)
+
+// ${n} is the id of whatever request n created or changed, and ${n.a.b} is
+// field a.b, or a[b] for arrays, of its response
+var batchRef = regexp.MustCompile(`\$\{(\d+)((?:\.[^.}]+)*)\}`)
+
+// PostBatch runs a list of requests through mux, in order, and returns what
+// each of them said. Later requests can refer to what earlier ones created
+// with ${n}. An atomic batch runs in one transaction, so the first failure
+// rolls back everything, and nothing after it is tried
+func (ha *HuautlaAdaptor) PostBatch(mux chi.Router) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		ms := ha.start(ctx, "PostBatch")
+		defer r.Body.Close()
+
+		var b batchRequest
+
+		limit := ha.batchMax
+		if limit <= 0 {
+			limit = defaultBatchMax
+		}
+
+		if body, err := io.ReadAll(r.Body); err != nil {
+			ms.error(w, err, http.StatusBadRequest, "couldn't read request body")
+		} else if err := json.Unmarshal(body, &b); err != nil {
+			ms.error(w, err, http.StatusBadRequest, "couldn't unmarshal request body")
+		} else if len(b.Requests) == 0 {
+			ms.error(w, fmt.Errorf("empty batch"), http.StatusBadRequest, "a batch needs at least one request")
+		} else if len(b.Requests) > limit {
+			ms.error(w, fmt.Errorf("batch of %d is more than %d", len(b.Requests), limit), http.StatusBadRequest, fmt.Sprintf("a batch can't have more than %d requests", limit))
+		} else if _, ok := ha.db.(txn.Beginner); b.Atomic && !ok {
+			ms.error(w, fmt.Errorf("%T doesn't do transactions", ha.db), http.StatusBadRequest, "this database can't do transactions, so batches can't be atomic")
+		} else if err := b.validate(); err != nil {
+			ms.error(w, err, http.StatusBadRequest, err.Error())
+		} else {
+			result := ha.runBatch(r, mux, ms, b)
+			ms.send(w, result.status(), result)
+		}
+	}
+}
+
+func (b batchRequest) validate() error {
+	for i := range b.Requests {
+		item := &b.Requests[i]
+		path, _, _ := strings.Cut(item.Path, "?")
+		switch item.Method = strings.ToUpper(item.Method); item.Method {
+		case http.MethodGet, http.MethodPost, http.MethodPatch, http.MethodDelete:
+		default:
+			return fmt.Errorf("request %d: method %q can't be batched", i, item.Method)
+		}
+
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("request %d: path must start with a slash: %q", i, item.Path)
+		} else if path == batchPath {
+			return fmt.Errorf("request %d: batches can't be nested", i)
+		} else if path == streamPath {
+			return fmt.Errorf("request %d: streams can't be batched", i)
+		}
+
+		for _, ref := range batchRef.FindAllStringSubmatch(item.Path+string(item.Body), -1) {
+			if n, err := strconv.Atoi(ref[1]); err != nil || n >= i {
+				return fmt.Errorf("request %d can only refer to requests before it: %s", i, ref[0])
+			}
+		}
+	}
+	return nil
+}
+
+func (ha *HuautlaAdaptor) runBatch(r *http.Request, mux chi.Router, ms *methodStats, b batchRequest) batchResponse {
+	result := batchResponse{
+		Atomic:  b.Atomic,
+		Results: make([]batchResult, len(b.Requests)),
+	}
+
+	var tx txn.Tx
+	if b.Atomic {
+		// PostBatch already made sure the database can
+		ctx, t, err := ha.db.(txn.Beginner).Begin(r.Context())
+		if err != nil {
+			result.Error = err.Error()
+			return result
+		}
+		r, tx = r.WithContext(ctx), t
+		defer func() { _ = tx.Rollback() }()
+	}
+
+	published := make([]*batchEvents, 0, len(b.Requests))
+	for i, item := range b.Requests {
+		cid := types.CID(fmt.Sprintf("%s-%d", ms.cid, i))
+		be := &batchEvents{hold: b.Atomic}
+
+		if path, body, err := result.resolve(i, item); err != nil {
+			result.Results[i] = batchResult{Status: http.StatusFailedDependency, Error: err.Error()}
+		} else {
+			w := ha.batchDo(r, mux, be, cid, item.Method, path, body, item.Headers)
+			result.Results[i] = newBatchResult(w, be.events)
+			published = append(published, be)
+		}
+
+		if sc := result.Results[i].Status; sc < http.StatusBadRequest {
+			result.Succeeded++
+			continue
+		}
+
+		result.Failed++
+		ms.l.WithFields(logrus.Fields{
+			"item": i,
+			"sc":   result.Results[i].Status,
+		}).Info("batch request failed")
+
+		if !b.Atomic {
+			continue
+		}
+
+		result.RolledBack = true
+		for j := i + 1; j < len(b.Requests); j++ {
+			result.Results[j] = batchResult{
+				Status: http.StatusFailedDependency,
+				Error:  fmt.Sprintf("not tried because request %d failed", i),
+			}
+		}
+		return result
+	}
+
+	if tx == nil {
+		return result
+	} else if err := tx.Commit(); err != nil {
+		result.RolledBack, result.Error = true, err.Error()
+		return result
+	}
+
+	// nothing hears about an atomic batch until it's committed
+	for _, be := range published {
+		for _, e := range be.events {
+			ha.publish(r.Context(), ms.l.WithField("event", e.Type), e)
+		}
+	}
+	return result
+}
+
+// batchDo runs one request through mux, on behalf of r, and keeps track of
+// the events it publishes in be
+func (ha *HuautlaAdaptor) batchDo(r *http.Request, mux http.Handler, be *batchEvents, cid types.CID, method, path string, body []byte, headers map[string]string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+
+	// mux only routes requests that haven't been routed yet
+	ctx := context.WithValue(r.Context(), chi.RouteCtxKey, nil)
+	ctx = context.WithValue(ctx, batchEventsKey{}, be)
+	sub, err := http.NewRequestWithContext(ctx, method, path, bytes.NewReader(body))
+	if err != nil {
+		w.Code = http.StatusBadRequest
+		_, _ = w.WriteString(strconv.Quote(err.Error()))
+		return w
+	}
+
+	// whoever sent the batch is whoever sent each of its requests, but the
+	// batch's idempotency key already covers all of them
+	sub.Header = r.Header.Clone()
+	sub.Header.Del(idempotency.Header)
+	sub.Header.Del("Content-Length")
+	sub.Header.Del("Content-Type")
+	for k, v := range headers {
+		sub.Header.Set(k, v)
+	}
+	if len(body) > 0 && sub.Header.Get("Content-Type") == "" {
+		sub.Header.Set("Content-Type", "application/json")
+	}
+	sub.Header.Set("Cid", string(cid))
+	sub.RemoteAddr = r.RemoteAddr
+	sub.RequestURI = path
+
+	mux.ServeHTTP(w, sub)
+
+	return w
+}
+
+func newBatchResult(w *httptest.ResponseRecorder, evs []events.Event) batchResult {
+	result := batchResult{Status: w.Code}
+	if body := bytes.TrimSpace(w.Body.Bytes()); len(body) == 0 {
+	} else if json.Valid(body) {
+		result.Body = body
+	} else {
+		result.Error = string(body)
+	}
+
+	// the first thing a request does is the thing it was asked to do; the
+	// rest, like generated strains, are consequences
+	if len(evs) > 0 {
+		result.ID = evs[0].EntityID
+	} else {
+		var v struct {
+			UUID types.UUID `json:"id"`
+		}
+		_ = json.Unmarshal(result.Body, &v)
+		result.ID = v.UUID
+	}
+
+	return result
+}
+
+// add keeps track of e, and says whether it's being held back from being
+// published
+func (be *batchEvents) add(e events.Event) bool {
+	be.mtx.Lock()
+	defer be.mtx.Unlock()
+	be.events = append(be.events, e)
+	return be.hold
+}
+
+// resolve fills in references to earlier requests in item's path and body
+func (br batchResponse) resolve(i int, item batchItem) (string, []byte, error) {
+	var err error
+
+	replace := func(s string, escape func(string) string) string {
+		return batchRef.ReplaceAllStringFunc(s, func(ref string) string {
+			v, e := br.ref(i, batchRef.FindStringSubmatch(ref))
+			if e != nil && err == nil {
+				err = e
+			}
+			return escape(v)
+		})
+	}
+
+	path := replace(item.Path, url.PathEscape)
+	body := replace(string(item.Body), func(s string) string {
+		// references in a body are always inside a json string
+		data, _ := json.Marshal(s)
+		return string(data[1 : len(data)-1])
+	})
+
+	return path, []byte(body), err
+}
+
+func (br batchResponse) ref(i int, match []string) (string, error) {
+	// validate already made sure n is before i
+	n, _ := strconv.Atoi(match[1])
+	if sc := br.Results[n].Status; sc >= http.StatusBadRequest {
+		return "", fmt.Errorf("request %d refers to request %d, which failed: %s", i, n, match[0])
+	} else if match[2] == "" {
+		if br.Results[n].ID == "" {
+			return "", fmt.Errorf("request %d didn't create anything: %s", n, match[0])
+		}
+		return string(br.Results[n].ID), nil
+	}
+
+	var v any
+	if err := json.Unmarshal(br.Results[n].Body, &v); err != nil {
+		return "", fmt.Errorf("request %d didn't return json: %s", n, match[0])
+	}
+
+	for _, field := range strings.Split(match[2][1:], ".") {
+		switch t := v.(type) {
+		case map[string]any:
+			v = t[field]
+		case []any:
+			if ndx, err := strconv.Atoi(field); err == nil && ndx >= 0 && ndx < len(t) {
+				v = t[ndx]
+			} else {
+				v = nil
+			}
+		default:
+			v = nil
+		}
+		if v == nil {
+			return "", fmt.Errorf("request %d's response has no %s: %s", n, match[2][1:], match[0])
+		}
+	}
+
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	data, _ := json.Marshal(v)
+	return string(data), nil
+}
+
+// status is 200 unless an atomic batch failed, in which case it's whatever
+// the failed request got, or 500 if it couldn't be committed
+func (br batchResponse) status() int {
+	if !br.Atomic {
+		return http.StatusOK
+	} else if br.Error != "" {
+		return http.StatusInternalServerError
+	}
+
+	for _, r := range br.Results {
+		if r.Status >= http.StatusBadRequest && r.Status != http.StatusFailedDependency {
+			return r.Status
+		}
+	}
+	if br.Failed > 0 {
+		return http.StatusFailedDependency
+	}
+	return http.StatusOK
+}
@@ -0,0 +1,342 @@
+package huautla
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/data/txn"
+	"github.com/jsmit257/centerforfunguscontrol/internal/events"
+	"github.com/jsmit257/centerforfunguscontrol/internal/store"
+	"github.com/jsmit257/centerforfunguscontrol/shared/metrics"
+	"github.com/jsmit257/huautla/types"
+)
+
+// batchVendors remembers what it's told, so a batch can be checked by what's
+// left when it's done; anything named fail fails
+type batchVendors struct {
+	types.Vendorer
+
+	mtx sync.Mutex
+	n   int
+	all map[types.UUID]types.Vendor
+}
+
+func (bv *batchVendors) SelectVendor(_ context.Context, id types.UUID, _ types.CID) (types.Vendor, error) {
+	bv.mtx.Lock()
+	defer bv.mtx.Unlock()
+	if v, ok := bv.all[id]; ok {
+		return v, nil
+	}
+	return types.Vendor{}, fmt.Errorf("no vendor %s", id)
+}
+
+func (bv *batchVendors) InsertVendor(_ context.Context, v types.Vendor, _ types.CID) (types.Vendor, error) {
+	bv.mtx.Lock()
+	defer bv.mtx.Unlock()
+	if v.Name == "fail" {
+		return v, fmt.Errorf("insert failed")
+	}
+	v.UUID = types.UUID(fmt.Sprintf("v%d", bv.n))
+	bv.n++
+	bv.all[v.UUID] = v
+	return v, nil
+}
+
+func (bv *batchVendors) UpdateVendor(_ context.Context, id types.UUID, v types.Vendor, _ types.CID) error {
+	bv.mtx.Lock()
+	defer bv.mtx.Unlock()
+	if _, ok := bv.all[id]; !ok || v.Name == "fail" {
+		return fmt.Errorf("update failed")
+	}
+	v.UUID = id
+	bv.all[id] = v
+	return nil
+}
+
+func (bv *batchVendors) DeleteVendor(_ context.Context, id types.UUID, _ types.CID) error {
+	bv.mtx.Lock()
+	defer bv.mtx.Unlock()
+	delete(bv.all, id)
+	return nil
+}
+
+func (bv *batchVendors) names() []string {
+	bv.mtx.Lock()
+	defer bv.mtx.Unlock()
+	result := []string{}
+	for _, v := range bv.all {
+		result = append(result, string(v.UUID)+"="+v.Name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// batchDB can do transactions, by remembering what the vendors were when
+// one started and putting them back if it's rolled back
+type (
+	batchDB struct {
+		*huautlaMock
+		bv *batchVendors
+	}
+
+	batchTx struct {
+		bv   *batchVendors
+		n    int
+		all  map[types.UUID]types.Vendor
+		done bool
+	}
+)
+
+func (db batchDB) Begin(ctx context.Context) (context.Context, txn.Tx, error) {
+	db.bv.mtx.Lock()
+	defer db.bv.mtx.Unlock()
+	tx := &batchTx{bv: db.bv, n: db.bv.n, all: map[types.UUID]types.Vendor{}}
+	for k, v := range db.bv.all {
+		tx.all[k] = v
+	}
+	return ctx, tx, nil
+}
+
+func (tx *batchTx) Commit() error {
+	tx.done = true
+	return nil
+}
+
+func (tx *batchTx) Rollback() error {
+	if tx.done {
+		return nil
+	}
+	tx.done = true
+	tx.bv.mtx.Lock()
+	defer tx.bv.mtx.Unlock()
+	tx.bv.n, tx.bv.all = tx.n, tx.all
+	return nil
+}
+
+func newBatchRouter(t *testing.T, vendors map[types.UUID]types.Vendor, transactions bool) (*chi.Mux, *batchVendors, *events.Outbox) {
+	outbox, err := events.NewOutbox(context.Background(), store.NewMem())
+	require.Nil(t, err)
+
+	bv := &batchVendors{all: vendors, n: len(vendors)}
+	var db types.DB = &huautlaMock{Vendorer: bv}
+	if transactions {
+		db = batchDB{huautlaMock: &huautlaMock{Vendorer: bv}, bv: bv}
+	}
+
+	ha := &HuautlaAdaptor{
+		db:       db,
+		events:   events.NewPublisher(outbox),
+		outbox:   outbox,
+		batchMax: 4,
+	}
+
+	r := chi.NewRouter()
+	r.Use(metrics.WrapContext(logrus.WithField("test", t.Name())))
+	r.Get("/vendor/{id}", ha.GetVendor)
+	r.Post("/vendor", ha.PostVendor)
+	r.Patch("/vendor/{id}", ha.PatchVendor)
+	r.Delete("/vendor/{id}", ha.DeleteVendor)
+	r.Post("/batch", ha.PostBatch(r))
+
+	return r, bv, outbox
+}
+
+func Test_PostBatch(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		existing     map[types.UUID]types.Vendor
+		transactions bool
+		body         string
+		sc           int
+		statuses     []int
+		ids          []types.UUID
+		rolledBack   bool
+		after        []string
+		events       []string
+	}{
+		"best_effort": {
+			body: `{"requests":[
+				{"method":"post","path":"/vendor","body":{"name":"a"}},
+				{"method":"patch","path":"/vendor/${0}","body":{"name":"${0.name}b"}},
+				{"method":"get","path":"/vendor/${0}"}]}`,
+			sc:       http.StatusOK,
+			statuses: []int{http.StatusCreated, http.StatusNoContent, http.StatusOK},
+			ids:      []types.UUID{"v0", "v0", "v0"},
+			after:    []string{"v0=ab"},
+			events:   []string{"vendor.created", "vendor.updated"},
+		},
+		"best_effort_failure": {
+			body: `{"requests":[
+				{"method":"post","path":"/vendor","body":{"name":"fail"}},
+				{"method":"post","path":"/vendor","body":{"name":"c"}},
+				{"method":"patch","path":"/vendor/${0}","body":{"name":"d"}},
+				{"method":"get","path":"/vendor/${1}"}]}`,
+			sc:       http.StatusOK,
+			statuses: []int{http.StatusInternalServerError, http.StatusCreated, http.StatusFailedDependency, http.StatusOK},
+			ids:      []types.UUID{"", "v0", "", "v0"},
+			after:    []string{"v0=c"},
+			events:   []string{"vendor.created"},
+		},
+		"missing_field": {
+			body: `{"requests":[
+				{"method":"post","path":"/vendor","body":{"name":"a"}},
+				{"method":"patch","path":"/vendor/${0.website}","body":{"name":"b"}}]}`,
+			sc:       http.StatusOK,
+			statuses: []int{http.StatusCreated, http.StatusFailedDependency},
+			ids:      []types.UUID{"v0", ""},
+			after:    []string{"v0=a"},
+			events:   []string{"vendor.created"},
+		},
+		"atomic": {
+			existing:     map[types.UUID]types.Vendor{"old": {UUID: "old", Name: "old"}},
+			transactions: true,
+			body: `{"atomic":true,"requests":[
+				{"method":"post","path":"/vendor","body":{"name":"a"}},
+				{"method":"patch","path":"/vendor/old","body":{"name":"new"}}]}`,
+			sc:       http.StatusOK,
+			statuses: []int{http.StatusCreated, http.StatusNoContent},
+			ids:      []types.UUID{"v1", "old"},
+			after:    []string{"old=new", "v1=a"},
+			events:   []string{"vendor.created", "vendor.updated"},
+		},
+		"rolled_back": {
+			existing:     map[types.UUID]types.Vendor{"old": {UUID: "old", Name: "old"}},
+			transactions: true,
+			body: `{"atomic":true,"requests":[
+				{"method":"post","path":"/vendor","body":{"name":"a"}},
+				{"method":"patch","path":"/vendor/old","body":{"name":"new"}},
+				{"method":"post","path":"/vendor","body":{"name":"fail"}},
+				{"method":"post","path":"/vendor","body":{"name":"b"}}]}`,
+			sc:         http.StatusInternalServerError,
+			statuses:   []int{http.StatusCreated, http.StatusNoContent, http.StatusInternalServerError, http.StatusFailedDependency},
+			ids:        []types.UUID{"v1", "old", "", ""},
+			rolledBack: true,
+			after:      []string{"old=old"},
+			events:     []string{},
+		},
+		"rolled_back_by_a_get": {
+			transactions: true,
+			body: `{"atomic":true,"requests":[
+				{"method":"post","path":"/vendor","body":{"name":"a"}},
+				{"method":"get","path":"/vendor/missing"}]}`,
+			sc:         http.StatusInternalServerError,
+			statuses:   []int{http.StatusCreated, http.StatusInternalServerError},
+			ids:        []types.UUID{"v0", ""},
+			rolledBack: true,
+			after:      []string{},
+			events:     []string{},
+		},
+		"no_transactions": {
+			body: `{"atomic":true,"requests":[
+				{"method":"post","path":"/vendor","body":{"name":"a"}}]}`,
+			sc:     http.StatusBadRequest,
+			after:  []string{},
+			events: []string{},
+		},
+		"forward_reference": {
+			body: `{"requests":[
+				{"method":"patch","path":"/vendor/${1}","body":{"name":"a"}},
+				{"method":"post","path":"/vendor","body":{"name":"b"}}]}`,
+			sc:     http.StatusBadRequest,
+			after:  []string{},
+			events: []string{},
+		},
+		"nested": {
+			body:   `{"requests":[{"method":"post","path":"/batch","body":{}}]}`,
+			sc:     http.StatusBadRequest,
+			after:  []string{},
+			events: []string{},
+		},
+		"stream": {
+			body:   `{"requests":[{"method":"get","path":"/stream?entity=vendor"}]}`,
+			sc:     http.StatusBadRequest,
+			after:  []string{},
+			events: []string{},
+		},
+		"bad_method": {
+			body:   `{"requests":[{"method":"options","path":"/vendor"}]}`,
+			sc:     http.StatusBadRequest,
+			after:  []string{},
+			events: []string{},
+		},
+		"bad_path": {
+			body:   `{"requests":[{"method":"get","path":"vendor"}]}`,
+			sc:     http.StatusBadRequest,
+			after:  []string{},
+			events: []string{},
+		},
+		"too_many": {
+			body:   `{"requests":[{"method":"get","path":"/vendor/0"},{"method":"get","path":"/vendor/1"},{"method":"get","path":"/vendor/2"},{"method":"get","path":"/vendor/3"},{"method":"get","path":"/vendor/4"}]}`,
+			sc:     http.StatusBadRequest,
+			after:  []string{},
+			events: []string{},
+		},
+		"empty": {
+			body:   `{"requests":[]}`,
+			sc:     http.StatusBadRequest,
+			after:  []string{},
+			events: []string{},
+		},
+		"unmarshal_error": {
+			body:   `{"requests":`,
+			sc:     http.StatusBadRequest,
+			after:  []string{},
+			events: []string{},
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			existing := map[types.UUID]types.Vendor{}
+			for k, v := range tc.existing {
+				existing[k] = v
+			}
+			r, bv, outbox := newBatchRouter(t, existing, tc.transactions)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/batch", bytes.NewReader([]byte(tc.body)))
+			req.Header.Set("Idempotency-Key", "k")
+			r.ServeHTTP(w, req)
+			require.Equal(t, tc.sc, w.Code, w.Body.String())
+			require.Equal(t, tc.after, bv.names())
+
+			evs, err := outbox.Since(context.Background(), 0, 0)
+			require.Nil(t, err)
+			published := []string{}
+			for _, e := range evs {
+				published = append(published, e.Type)
+			}
+			require.Equal(t, tc.events, published)
+
+			if tc.statuses == nil {
+				return
+			}
+
+			var resp batchResponse
+			require.Nil(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			statuses, ids := []int{}, []types.UUID{}
+			for _, res := range resp.Results {
+				statuses = append(statuses, res.Status)
+				ids = append(ids, res.ID)
+			}
+			require.Equal(t, tc.statuses, statuses)
+			require.Equal(t, tc.ids, ids)
+			require.Equal(t, tc.rolledBack, resp.RolledBack)
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package huautla
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jsmit257/huautla/types"
+	"github.com/sirupsen/logrus"
+)
+
+type (
+	// bulkEvents is one event for lots of things; targets are lifecycles,
+	// generations and every lifecycle at location, all together
+	bulkEvents struct {
+		Event       types.Event  `json:"event"`
+		Lifecycles  []types.UUID `json:"lifecycles,omitempty"`
+		Generations []types.UUID `json:"generations,omitempty"`
+		// generations don't have a location, so this only finds lifecycles
+		Location string `json:"location,omitempty"`
+	}
+
+	bulkEventResult struct {
+		// lifecycle or generation
+		Kind   string       `json:"kind"`
+		ID     types.UUID   `json:"id"`
+		Status int          `json:"status"`
+		Event  *types.Event `json:"event,omitempty"`
+		Error  string       `json:"error,omitempty"`
+	}
+)
+
+// PostBulkEvents adds the same event to every lifecycle and generation it's
+// given, and every lifecycle at a location, and says what happened to each
+// of them; the event's ctime, if there is one, is when it happened, for
+// logging things after the fact. It's 201 if they all got one and 207 if
+// only some did
+func (ha *HuautlaAdaptor) PostBulkEvents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ms := ha.start(ctx, "PostBulkEvents")
+	defer r.Body.Close()
+
+	var b bulkEvents
+
+	if body, err := io.ReadAll(r.Body); err != nil {
+		ms.error(w, err, http.StatusBadRequest, "couldn't read request body")
+	} else if err := json.Unmarshal(body, &b); err != nil {
+		ms.error(w, err, http.StatusBadRequest, "couldn't unmarshal request body")
+	} else if b.Event.EventType.UUID == "" {
+		ms.error(w, fmt.Errorf("missing event type"), http.StatusBadRequest, "the event needs an event_type")
+	} else if len(b.Lifecycles) == 0 && len(b.Generations) == 0 && strings.TrimSpace(b.Location) == "" {
+		ms.error(w, fmt.Errorf("no targets"), http.StatusBadRequest, "need lifecycles, generations or a location")
+	} else if lcs, err := ha.lifecyclesAt(ctx, b.Location, ms); err != nil {
+		ms.error(w, err, http.StatusInternalServerError, "failed to fetch lifecycles")
+	} else if b.Location != "" && len(lcs) == 0 {
+		ms.error(w, fmt.Errorf("no lifecycles at %q", b.Location), http.StatusNotFound, "no lifecycles at that location")
+	} else {
+		result := make([]bulkEventResult, 0, len(b.Lifecycles)+len(lcs)+len(b.Generations))
+		for _, id := range dedupe(append(b.Lifecycles, lcs...)) {
+			result = append(result, ha.addBulkLifecycleEvent(ctx, id, b.Event, ms))
+		}
+		for _, id := range dedupe(b.Generations) {
+			result = append(result, ha.addBulkGenerationEvent(ctx, id, b.Event, ms))
+		}
+
+		sc := http.StatusCreated
+		if slices.ContainsFunc(result, func(r bulkEventResult) bool { return r.Status != http.StatusCreated }) {
+			sc = http.StatusMultiStatus
+		}
+		ms.send(w, sc, result)
+	}
+}
+
+// lifecyclesAt is every lifecycle whose location is location, give or take
+// case and whitespace
+func (ha *HuautlaAdaptor) lifecyclesAt(ctx context.Context, location string, ms *methodStats) ([]types.UUID, error) {
+	if location = strings.TrimSpace(location); location == "" {
+		return nil, nil
+	}
+
+	all, err := ha.db.SelectLifecycleIndex(ctx, ms.cid)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []types.UUID{}
+	for _, l := range all {
+		if strings.EqualFold(strings.TrimSpace(l.Location), location) {
+			result = append(result, l.UUID)
+		}
+	}
+	return result, nil
+}
+
+func (ha *HuautlaAdaptor) addBulkLifecycleEvent(ctx context.Context, id types.UUID, e types.Event, ms *methodStats) bulkEventResult {
+	result := bulkEventResult{Kind: "lifecycle", ID: id}
+
+	if l, err := ha.db.SelectLifecycle(ctx, id, ms.cid); errors.Is(err, sql.ErrNoRows) {
+		result.failed(ms, http.StatusNotFound, err, "no such lifecycle")
+	} else if err != nil {
+		result.failed(ms, http.StatusInternalServerError, err, "failed to fetch lifecycle")
+	} else if after, err := ha.addLifecycleEvent(ctx, l, e, ms); err != nil {
+		result.failed(ms, http.StatusInternalServerError, err, "failed to add event")
+	} else {
+		ha.addedBulkEvent(ctx, &result, l.UUID, l.Events, after, e, ms)
+	}
+
+	return result
+}
+
+func (ha *HuautlaAdaptor) addBulkGenerationEvent(ctx context.Context, id types.UUID, e types.Event, ms *methodStats) bulkEventResult {
+	result := bulkEventResult{Kind: "generation", ID: id}
+
+	if g, err := ha.db.SelectGeneration(ctx, id, ms.cid); errors.Is(err, sql.ErrNoRows) {
+		result.failed(ms, http.StatusNotFound, err, "no such generation")
+	} else if err != nil {
+		result.failed(ms, http.StatusInternalServerError, err, "failed to fetch generation")
+	} else if after, err := ha.addGenerationEvent(ctx, g, e, ms); err != nil {
+		result.failed(ms, http.StatusInternalServerError, err, "failed to add event")
+	} else {
+		ha.addedBulkEvent(ctx, &result, g.UUID, g.Events, after, e, ms)
+	}
+
+	return result
+}
+
+// addLifecycleEvent adds e to a copy of l, so l still has the events it had
+// before, and returns the ones it has after
+func (ha *HuautlaAdaptor) addLifecycleEvent(ctx context.Context, l types.Lifecycle, e types.Event, ms *methodStats) ([]types.Event, error) {
+	l.Events = slices.Clone(l.Events)
+	err := ha.db.AddLifecycleEvent(ctx, &l, e, ms.cid)
+	return l.Events, err
+}
+
+// addGenerationEvent is addLifecycleEvent for generations
+func (ha *HuautlaAdaptor) addGenerationEvent(ctx context.Context, g types.Generation, e types.Event, ms *methodStats) ([]types.Event, error) {
+	g.Events = slices.Clone(g.Events)
+	err := ha.db.AddGenerationEvent(ctx, &g, e, ms.cid)
+	return g.Events, err
+}
+
+// addedBulkEvent finds the event that was just added to owner, backdates it
+// and tells everyone about it. The newest event isn't necessarily ours, if
+// somebody else added one at the same time, so ours is the one that wasn't
+// in before; when that's more than one, it's also the same type as e. The
+// event's there either way, so failing to find or backdate it still leaves
+// it added, and the result says so
+func (ha *HuautlaAdaptor) addedBulkEvent(ctx context.Context, result *bulkEventResult, owner types.UUID, before, after []types.Event, e types.Event, ms *methodStats) {
+	isNew := func(a types.Event) bool {
+		return !slices.ContainsFunc(before, func(b types.Event) bool { return b.UUID == a.UUID })
+	}
+	added := slices.DeleteFunc(slices.Clone(after), func(a types.Event) bool { return !isNew(a) })
+	if len(added) > 1 {
+		added = slices.DeleteFunc(added, func(a types.Event) bool { return a.EventType.UUID != e.EventType.UUID })
+	}
+	if len(added) != 1 {
+		result.failed(ms, http.StatusInternalServerError, fmt.Errorf("%d new events on %s", len(added), owner), "the event was added, but it couldn't be told apart from others added at the same time")
+		return
+	}
+
+	created, err := ha.backdate(ctx, added[0], e.CTime)
+	ha.emit(ctx, ms, "event.added", created.UUID, owned{owner, created})
+	if err != nil {
+		result.failed(ms, http.StatusInternalServerError, err, "the event was added, but it couldn't be backdated")
+		result.Event = &created
+	} else {
+		result.added(created)
+	}
+}
+
+// backdate sets when e happened, if that's not now; huautla always says
+// now, so if this fails the event is still there, just with the wrong time
+func (ha *HuautlaAdaptor) backdate(ctx context.Context, e types.Event, when time.Time) (types.Event, error) {
+	if when.IsZero() {
+		return e, nil
+	}
+
+	when = when.UTC()
+	if err := ha.db.UpdateTimestamps(ctx, "events", e.UUID, types.Timestamp{
+		Fields: []string{"ctime"},
+		Origin: &when,
+	}); err != nil {
+		return e, err
+	}
+
+	e.CTime = when
+	return e, nil
+}
+
+func (ber *bulkEventResult) added(e types.Event) {
+	ber.Status = http.StatusCreated
+	ber.Event = &e
+}
+
+func (ber *bulkEventResult) failed(ms *methodStats, sc int, err error, msg string) {
+	ms.l.WithError(err).WithFields(logrus.Fields{
+		"kind": ber.Kind,
+		"id":   ber.ID,
+	}).Error(msg)
+	ber.Status = sc
+	ber.Error = msg
+}
+
+// dedupe keeps the first of each id, in order
+func dedupe(ids []types.UUID) []types.UUID {
+	result := make([]types.UUID, 0, len(ids))
+	for _, id := range ids {
+		if id != "" && !slices.Contains(result, id) {
+			result = append(result, id)
+		}
+	}
+	return result
+}
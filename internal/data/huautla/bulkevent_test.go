@@ -0,0 +1,222 @@
+package huautla
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/centerforfunguscontrol/shared/metrics"
+	"github.com/jsmit257/huautla/types"
+)
+
+// bulkObservables has lifecycles and generations by id; adding an event to
+// anything called fail fails, and somebody else adds one to busy at the same
+// time, and another one of the same type to crowded
+type bulkObservables struct {
+	types.Lifecycler
+	types.Generationer
+	types.LifecycleEventer
+	types.GenerationEventer
+
+	index    []types.Lifecycle
+	indexErr error
+}
+
+func (bo *bulkObservables) SelectLifecycleIndex(context.Context, types.CID) ([]types.Lifecycle, error) {
+	return bo.index, bo.indexErr
+}
+
+func (bo *bulkObservables) SelectLifecycle(_ context.Context, id types.UUID, _ types.CID) (types.Lifecycle, error) {
+	if id == "missing" {
+		return types.Lifecycle{}, sql.ErrNoRows
+	}
+	return types.Lifecycle{UUID: id, Events: []types.Event{{UUID: "old"}}}, nil
+}
+
+func (bo *bulkObservables) SelectGeneration(_ context.Context, id types.UUID, _ types.CID) (types.Generation, error) {
+	if id == "missing" {
+		return types.Generation{}, sql.ErrNoRows
+	}
+	return types.Generation{UUID: id}, nil
+}
+
+func (bo *bulkObservables) AddLifecycleEvent(_ context.Context, l *types.Lifecycle, e types.Event, _ types.CID) error {
+	if l.UUID == "fail" {
+		return fmt.Errorf("add failed")
+	}
+	// huautla says it happened now, whatever it's told
+	e.UUID, e.CTime = "ev-"+l.UUID, time.Now().UTC()
+	l.Events = append([]types.Event{e}, l.Events...)
+	if other := e; l.UUID == "busy" || l.UUID == "crowded" {
+		other.UUID = "other-" + l.UUID
+		if l.UUID == "busy" {
+			other.EventType = types.EventType{UUID: "water"}
+		}
+		l.Events = append([]types.Event{other}, l.Events...)
+	}
+	return nil
+}
+
+func (bo *bulkObservables) AddGenerationEvent(_ context.Context, g *types.Generation, e types.Event, _ types.CID) error {
+	if g.UUID == "fail" {
+		return fmt.Errorf("add failed")
+	}
+	// huautla says it happened now, whatever it's told
+	e.UUID, e.CTime = "ev-"+g.UUID, time.Now().UTC()
+	g.Events = append([]types.Event{e}, g.Events...)
+	return nil
+}
+
+func Test_PostBulkEvents(t *testing.T) {
+	t.Parallel()
+
+	when := time.Date(2026, 3, 1, 6, 0, 0, 0, time.UTC)
+
+	type target struct {
+		kind   string
+		id     types.UUID
+		sc     int
+		ev     types.UUID
+		backed bool
+	}
+
+	tcs := map[string]struct {
+		body     string
+		index    []types.Lifecycle
+		indexErr error
+		tsErr    error
+		sc       int
+		targets  []target
+	}{
+		"by_id": {
+			body: `{"event":{"event_type":{"id":"mist"},"humidity":90},"lifecycles":["0","1","0"],"generations":["2"]}`,
+			sc:   http.StatusCreated,
+			targets: []target{
+				{kind: "lifecycle", id: "0", sc: http.StatusCreated, ev: "ev-0"},
+				{kind: "lifecycle", id: "1", sc: http.StatusCreated, ev: "ev-1"},
+				{kind: "generation", id: "2", sc: http.StatusCreated, ev: "ev-2"},
+			},
+		},
+		"by_location": {
+			body: `{"event":{"event_type":{"id":"mist"}},"location":" Tent B ","lifecycles":["1"]}`,
+			index: []types.Lifecycle{
+				{UUID: "0", Location: "tent a"},
+				{UUID: "1", Location: "tent b"},
+				{UUID: "2", Location: "Tent B"},
+			},
+			sc: http.StatusCreated,
+			targets: []target{
+				{kind: "lifecycle", id: "1", sc: http.StatusCreated, ev: "ev-1"},
+				{kind: "lifecycle", id: "2", sc: http.StatusCreated, ev: "ev-2"},
+			},
+		},
+		"backdated": {
+			body: fmt.Sprintf(`{"event":{"event_type":{"id":"mist"},"ctime":%q},"lifecycles":["0"]}`, when.Format(time.RFC3339)),
+			sc:   http.StatusCreated,
+			targets: []target{
+				{kind: "lifecycle", id: "0", sc: http.StatusCreated, ev: "ev-0", backed: true},
+			},
+		},
+		"backdate_fails": {
+			body:  fmt.Sprintf(`{"event":{"event_type":{"id":"mist"},"ctime":%q},"generations":["0"]}`, when.Format(time.RFC3339)),
+			tsErr: fmt.Errorf("some error"),
+			sc:    http.StatusMultiStatus,
+			targets: []target{
+				{kind: "generation", id: "0", sc: http.StatusInternalServerError, ev: "ev-0"},
+			},
+		},
+		"added_at_the_same_time": {
+			body: fmt.Sprintf(`{"event":{"event_type":{"id":"mist"},"ctime":%q},"lifecycles":["busy","crowded"]}`, when.Format(time.RFC3339)),
+			sc:   http.StatusMultiStatus,
+			targets: []target{
+				{kind: "lifecycle", id: "busy", sc: http.StatusCreated, ev: "ev-busy", backed: true},
+				{kind: "lifecycle", id: "crowded", sc: http.StatusInternalServerError},
+			},
+		},
+		"some_failed": {
+			body: `{"event":{"event_type":{"id":"mist"}},"lifecycles":["0","missing","fail"],"generations":["missing","fail"]}`,
+			sc:   http.StatusMultiStatus,
+			targets: []target{
+				{kind: "lifecycle", id: "0", sc: http.StatusCreated, ev: "ev-0"},
+				{kind: "lifecycle", id: "missing", sc: http.StatusNotFound},
+				{kind: "lifecycle", id: "fail", sc: http.StatusInternalServerError},
+				{kind: "generation", id: "missing", sc: http.StatusNotFound},
+				{kind: "generation", id: "fail", sc: http.StatusInternalServerError},
+			},
+		},
+		"nothing_at_location": {
+			body:  `{"event":{"event_type":{"id":"mist"}},"location":"tent c"}`,
+			index: []types.Lifecycle{{UUID: "0", Location: "tent a"}},
+			sc:    http.StatusNotFound,
+		},
+		"index_error": {
+			body:     `{"event":{"event_type":{"id":"mist"}},"location":"tent a"}`,
+			indexErr: fmt.Errorf("some error"),
+			sc:       http.StatusInternalServerError,
+		},
+		"no_targets": {
+			body: `{"event":{"event_type":{"id":"mist"}}}`,
+			sc:   http.StatusBadRequest,
+		},
+		"no_event_type": {
+			body: `{"event":{"humidity":90},"lifecycles":["0"]}`,
+			sc:   http.StatusBadRequest,
+		},
+		"unmarshal_error": {
+			body: `{"event":`,
+			sc:   http.StatusBadRequest,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			bo := &bulkObservables{index: tc.index, indexErr: tc.indexErr}
+			ha := &HuautlaAdaptor{
+				db: &huautlaMock{
+					Lifecycler:        bo,
+					Generationer:      bo,
+					LifecycleEventer:  bo,
+					GenerationEventer: bo,
+					Timestamper:       &mockTS{updErr: tc.tsErr},
+				},
+			}
+
+			w := httptest.NewRecorder()
+			r, _ := http.NewRequestWithContext(metrics.MockServiceContext, http.MethodPost, "url", bytes.NewReader([]byte(tc.body)))
+			ha.PostBulkEvents(w, r)
+			require.Equal(t, tc.sc, w.Code, w.Body.String())
+			if tc.targets == nil {
+				return
+			}
+
+			var results []bulkEventResult
+			require.Nil(t, json.Unmarshal(w.Body.Bytes(), &results))
+			require.Len(t, results, len(tc.targets))
+			for i, tgt := range tc.targets {
+				res := results[i]
+				require.Equal(t, tgt.kind, res.Kind)
+				require.Equal(t, tgt.id, res.ID)
+				require.Equal(t, tgt.sc, res.Status)
+				require.Equal(t, tgt.sc != http.StatusCreated, res.Error != "")
+				if tgt.ev == "" {
+					require.Nil(t, res.Event)
+					continue
+				}
+				require.Equal(t, tgt.ev, res.Event.UUID)
+				require.Equal(t, types.UUID("mist"), res.Event.EventType.UUID)
+				require.Equal(t, tgt.backed, res.Event.CTime.Equal(when))
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package huautla
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sirupsen/logrus"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/codes"
+	"github.com/jsmit257/centerforfunguscontrol/internal/store"
+	"github.com/jsmit257/huautla/types"
+)
+
+type (
+	// codedStrain, codedGeneration and codedLifecycle are what's sent
+	// instead of huautla's own types, so they have their short codes
+	codedStrain struct {
+		types.Strain
+		Code string `json:"code,omitempty"`
+	}
+
+	codedGeneration struct {
+		types.Generation
+		Code string `json:"code,omitempty"`
+	}
+
+	// a lifecycle's strain has its code too
+	codedLifecycle struct {
+		types.Lifecycle
+		Code   string      `json:"code,omitempty"`
+		Strain codedStrain `json:"strain"`
+	}
+)
+
+// GetResolve sends whoever has a code to the thing it stands for
+func (ha *HuautlaAdaptor) GetResolve(w http.ResponseWriter, r *http.Request) {
+	ms := ha.start(r.Context(), "GetResolve")
+
+	if code := chi.URLParam(r, "code"); code == "" {
+		ms.error(w, fmt.Errorf("missing required parameter: code"), http.StatusBadRequest, "missing required parameter")
+	} else if code, err := url.PathUnescape(code); err != nil {
+		ms.error(w, fmt.Errorf("malformed parameter: code"), http.StatusBadRequest, "malformed parameter")
+	} else if ha.codes == nil {
+		ms.error(w, fmt.Errorf("no codes"), http.StatusNotFound, "no such code")
+	} else if ref, err := ha.codes.Resolve(r.Context(), code); errors.Is(err, store.ErrNotFound) {
+		ms.error(w, fmt.Errorf("no such code: %q", code), http.StatusNotFound, "no such code")
+	} else if err != nil {
+		ms.error(w, err, http.StatusInternalServerError, "failed to resolve code")
+	} else {
+		ms.found(w, ref)
+	}
+}
+
+// found sends the client on to ref, with ref in the body for anyone who
+// doesn't follow redirects
+func (ms *methodStats) found(w http.ResponseWriter, ref codes.Ref) {
+	w.Header().Set("Location", fmt.Sprintf("/%s/%s", ref.Kind, url.PathEscape(string(ref.ID))))
+	ms.send(w, http.StatusFound, ref)
+}
+
+// assignCode gives a new lifecycle, generation or strain its code; the thing
+// is already there, so failing is logged and otherwise ignored, and the next
+// backfill gets it
+func (ha *HuautlaAdaptor) assignCode(ctx context.Context, ms *methodStats, kind codes.Kind, id types.UUID) string {
+	if ha.codes == nil {
+		return ""
+	}
+	code, err := ha.codes.Assign(ctx, kind, id, time.Now())
+	if err != nil {
+		ms.l.WithError(err).WithField("id", id).Error("failed to assign code")
+	}
+	return code
+}
+
+// code is id's code, or nothing if it doesn't have one; a response is
+// still worth sending without it, so failing to look it up is only logged
+func (ha *HuautlaAdaptor) code(ctx context.Context, ms *methodStats, id types.UUID) string {
+	if ha.codes == nil {
+		return ""
+	}
+	code, err := ha.codes.Code(ctx, id)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		ms.l.WithError(err).WithField("id", id).Error("failed to look up code")
+	}
+	return code
+}
+
+func (ha *HuautlaAdaptor) codedStrain(ctx context.Context, ms *methodStats, s types.Strain) codedStrain {
+	return codedStrain{Strain: s, Code: ha.code(ctx, ms, s.UUID)}
+}
+
+func (ha *HuautlaAdaptor) codedGeneration(ctx context.Context, ms *methodStats, g types.Generation) codedGeneration {
+	return codedGeneration{Generation: g, Code: ha.code(ctx, ms, g.UUID)}
+}
+
+func (ha *HuautlaAdaptor) codedLifecycle(ctx context.Context, ms *methodStats, l types.Lifecycle) codedLifecycle {
+	return codedLifecycle{
+		Lifecycle: l,
+		Code:      ha.code(ctx, ms, l.UUID),
+		Strain:    ha.codedStrain(ctx, ms, l.Strain),
+	}
+}
+
+// codedAll is every one of all with its code
+func codedAll[T, C any](ctx context.Context, ms *methodStats, all []T, coded func(context.Context, *methodStats, T) C) []C {
+	result := make([]C, 0, len(all))
+	for _, v := range all {
+		result = append(result, coded(ctx, ms, v))
+	}
+	return result
+}
+
+// codedReport adds id's code to a report, which is whatever huautla
+// thought to put in it
+func (ha *HuautlaAdaptor) codedReport(ctx context.Context, ms *methodStats, id types.UUID, report types.Entity) types.Entity {
+	if code := ha.code(ctx, ms, id); code == "" {
+	} else if report == nil {
+		report = types.Entity{"code": code}
+	} else {
+		report["code"] = code
+	}
+	return report
+}
+
+// backfillCodes gives everything that doesn't have a code one, oldest first,
+// so codes go in the order things were made; lifecycles get the year they
+// were made in
+func (ha *HuautlaAdaptor) backfillCodes(ctx context.Context, cid types.CID, log *logrus.Entry) error {
+	type made struct {
+		kind codes.Kind
+		id   types.UUID
+		when time.Time
+	}
+
+	if ha.codes == nil {
+		return nil
+	}
+
+	var all []made
+
+	if lcs, err := ha.db.SelectLifecycleIndex(ctx, cid); err != nil {
+		return fmt.Errorf("lifecycles: %w", err)
+	} else {
+		for _, l := range lcs {
+			all = append(all, made{codes.Lifecycle, l.UUID, l.CTime})
+		}
+	}
+
+	if gens, err := ha.db.SelectGenerationIndex(ctx, cid); err != nil {
+		return fmt.Errorf("generations: %w", err)
+	} else {
+		for _, g := range gens {
+			all = append(all, made{codes.Generation, g.UUID, g.CTime})
+		}
+	}
+
+	if strains, err := ha.db.SelectAllStrains(ctx, cid); err != nil {
+		return fmt.Errorf("strains: %w", err)
+	} else {
+		for _, s := range strains {
+			all = append(all, made{codes.Strain, s.UUID, s.CTime})
+		}
+	}
+
+	sort.SliceStable(all, func(i, j int) bool { return all[i].when.Before(all[j].when) })
+
+	n := 0
+	for _, m := range all {
+		if _, err := ha.codes.Code(ctx, m.id); err == nil {
+			continue
+		} else if !errors.Is(err, store.ErrNotFound) {
+			return fmt.Errorf("%s %s: %w", m.kind, m.id, err)
+		} else if _, err := ha.codes.Assign(ctx, m.kind, m.id, m.when); err != nil {
+			return fmt.Errorf("%s %s: %w", m.kind, m.id, err)
+		}
+		n++
+	}
+
+	if n > 0 {
+		log.WithField("assigned", n).Info("backfilled short codes")
+	}
+	return nil
+}
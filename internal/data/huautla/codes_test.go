@@ -0,0 +1,277 @@
+package huautla
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/codes"
+	"github.com/jsmit257/centerforfunguscontrol/internal/store"
+	"github.com/jsmit257/centerforfunguscontrol/shared/metrics"
+	"github.com/jsmit257/huautla/types"
+)
+
+func newCodes(t *testing.T, refs ...codes.Ref) *codes.Registry {
+	reg := codes.New(store.NewMem())
+	for _, ref := range refs {
+		_, err := reg.Assign(context.Background(), ref.Kind, ref.ID, time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC))
+		require.Nil(t, err)
+	}
+	return reg
+}
+
+func Test_GetResolve(t *testing.T) {
+	t.Parallel()
+
+	reg := newCodes(t,
+		codes.Ref{Kind: codes.Lifecycle, ID: "lc 0"},
+		codes.Ref{Kind: codes.Generation, ID: "g0"},
+		codes.Ref{Kind: codes.Strain, ID: "s0"},
+	)
+
+	tcs := map[string]struct {
+		code     string
+		codes    *codes.Registry
+		sc       int
+		location string
+	}{
+		"lifecycle": {
+			code:     "LC-2026-0001",
+			codes:    reg,
+			sc:       http.StatusFound,
+			location: "/lifecycle/lc%200",
+		},
+		"generation": {
+			code:     "gen-0001",
+			codes:    reg,
+			sc:       http.StatusFound,
+			location: "/generation/g0",
+		},
+		"strain": {
+			code:     "STR-0001",
+			codes:    reg,
+			sc:       http.StatusFound,
+			location: "/strain/s0",
+		},
+		"unknown": {
+			code:  "GEN-0002",
+			codes: reg,
+			sc:    http.StatusNotFound,
+		},
+		"no_codes": {
+			code: "GEN-0001",
+			sc:   http.StatusNotFound,
+		},
+		"missing_code": {
+			codes: reg,
+			sc:    http.StatusBadRequest,
+		},
+		"malformed_code": {
+			code:  "%zzz",
+			codes: reg,
+			sc:    http.StatusBadRequest,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ha := &HuautlaAdaptor{codes: tc.codes}
+			w := sendWebhook(ha.GetResolve, http.MethodGet, "url", chi.RouteParams{Keys: []string{"code"}, Values: []string{tc.code}}, "")
+			require.Equal(t, tc.sc, w.Code, w.Body.String())
+			require.Equal(t, tc.location, w.Header().Get("Location"))
+		})
+	}
+}
+
+func Test_assignCode(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		kind  codes.Kind
+		codes bool
+		code  string
+	}{
+		"lifecycle": {
+			kind:  codes.Lifecycle,
+			codes: true,
+			code:  fmt.Sprintf("LC-%d-0001", time.Now().UTC().Year()),
+		},
+		"generation": {
+			kind:  codes.Generation,
+			codes: true,
+			code:  "GEN-0001",
+		},
+		"strain": {
+			kind:  codes.Strain,
+			codes: true,
+			code:  "STR-0001",
+		},
+		"something_else": {
+			kind:  "vendor",
+			codes: true,
+		},
+		"no_codes": {
+			kind: codes.Strain,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ha := &HuautlaAdaptor{}
+			if tc.codes {
+				ha.codes = newCodes(t)
+			}
+			ms := ha.start(metrics.MockServiceContext, "Test_assignCode")
+			require.Equal(t, tc.code, ha.assignCode(metrics.MockServiceContext, ms, tc.kind, "0"))
+			require.Equal(t, tc.code, ha.code(metrics.MockServiceContext, ms, "0"))
+		})
+	}
+
+	t.Run("emit_doesnt", func(t *testing.T) {
+		t.Parallel()
+
+		ha := &HuautlaAdaptor{codes: newCodes(t)}
+		ha.emit(metrics.MockServiceContext, ha.start(metrics.MockServiceContext, "Test_assignCode"), "lifecycle.created", "0", nil)
+		_, err := ha.codes.Code(context.Background(), "0")
+		require.ErrorIs(t, err, store.ErrNotFound)
+	})
+}
+
+func Test_backfillCodes(t *testing.T) {
+	t.Parallel()
+
+	day := func(d int) time.Time { return time.Date(2025, 12, 30, 0, 0, 0, 0, time.UTC).AddDate(0, 0, d) }
+
+	tcs := map[string]struct {
+		existing   []codes.Ref
+		lifecycles []types.Lifecycle
+		lcErr      error
+		gens       []types.Generation
+		genErr     error
+		strains    []types.Strain
+		strainErr  error
+		codes      map[types.UUID]string
+		err        bool
+	}{
+		"oldest_first": {
+			existing: []codes.Ref{{Kind: codes.Generation, ID: "g1"}},
+			lifecycles: []types.Lifecycle{
+				{UUID: "lc2", CTime: day(3)},
+				{UUID: "lc0", CTime: day(0)},
+				{UUID: "lc1", CTime: day(1)},
+			},
+			gens: []types.Generation{
+				{UUID: "g0", CTime: day(2)},
+				{UUID: "g1", CTime: day(0)},
+			},
+			strains: []types.Strain{{UUID: "s0", CTime: day(1)}},
+			codes: map[types.UUID]string{
+				"lc0": "LC-2025-0001",
+				"lc1": "LC-2025-0002",
+				"lc2": "LC-2026-0001",
+				"g1":  "GEN-0001",
+				"g0":  "GEN-0002",
+				"s0":  "STR-0001",
+			},
+		},
+		"lifecycle_error": {
+			lcErr: fmt.Errorf("some error"),
+			err:   true,
+		},
+		"generation_error": {
+			genErr: fmt.Errorf("some error"),
+			err:    true,
+		},
+		"strain_error": {
+			strainErr: fmt.Errorf("some error"),
+			err:       true,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ha := &HuautlaAdaptor{
+				db: &huautlaMock{
+					Lifecycler:   &bulkObservables{index: tc.lifecycles, indexErr: tc.lcErr},
+					Generationer: &generationerMock{all: tc.gens, allErr: tc.genErr},
+					Strainer:     &strainerMock{selectAllResult: tc.strains, selectAllErr: tc.strainErr},
+				},
+				codes: newCodes(t, tc.existing...),
+			}
+
+			err := ha.backfillCodes(context.Background(), "cid", logrus.WithField("test", name))
+			require.Equal(t, tc.err, err != nil, err)
+			for id, want := range tc.codes {
+				code, err := ha.codes.Code(context.Background(), id)
+				require.Nil(t, err, id)
+				require.Equal(t, want, code, id)
+			}
+		})
+	}
+}
+
+func Test_codedLifecycle(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		codes  *codes.Registry
+		code   string
+		strain string
+	}{
+		"happy_path": {
+			codes: newCodes(t,
+				codes.Ref{Kind: codes.Lifecycle, ID: "lc0"},
+				codes.Ref{Kind: codes.Strain, ID: "s0"},
+			),
+			code:   "LC-2026-0001",
+			strain: "STR-0001",
+		},
+		"no_codes": {},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ha := &HuautlaAdaptor{codes: tc.codes}
+			ms := ha.start(metrics.MockServiceContext, "Test_codedLifecycle")
+			l := types.Lifecycle{UUID: "lc0", Location: "<shelf>", Strain: types.Strain{UUID: "s0", Name: "strain 0"}}
+
+			data, err := json.Marshal(ha.codedLifecycle(metrics.MockServiceContext, ms, l))
+			require.Nil(t, err)
+
+			var got map[string]any
+			require.Nil(t, json.Unmarshal(data, &got))
+			require.Equal(t, "lc0", got["id"])
+			require.Equal(t, "<shelf>", got["location"])
+			strain, ok := got["strain"].(map[string]any)
+			require.True(t, ok, string(data))
+			require.Equal(t, "s0", strain["id"])
+			require.Equal(t, "strain 0", strain["name"])
+			if tc.code == "" {
+				require.NotContains(t, got, "code")
+				require.NotContains(t, strain, "code")
+			} else {
+				require.Equal(t, tc.code, got["code"])
+				require.Equal(t, tc.strain, strain["code"])
+			}
+		})
+	}
+}
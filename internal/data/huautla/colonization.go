@@ -0,0 +1,193 @@
+package huautla
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"image"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/imaging"
+	"github.com/jsmit257/centerforfunguscontrol/internal/store"
+	"github.com/jsmit257/centerforfunguscontrol/shared/metrics"
+	"github.com/jsmit257/huautla/types"
+)
+
+type colonization struct {
+	Percent float64 `json:"percent"`
+	// the substrate whose profile was used, if it wasn't the default
+	Substrate types.UUID       `json:"substrate,omitempty"`
+	Suggested *types.EventType `json:"suggested_event_type,omitempty"`
+
+	profile imaging.ColonizationProfile
+}
+
+const (
+	colonizationTable = "colonization_profiles"
+
+	halfColonized = "50% colonization"
+	fullColonized = "100% colonization"
+)
+
+func (ha *HuautlaAdaptor) GetColonizationProfile(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ms := ha.start(ctx, "GetColonizationProfile")
+
+	if id, err := getUUIDByName("id", w, r, ms); err != nil {
+		ms.error(w, err, http.StatusBadRequest, "failed to fetch uuid")
+	} else if p, err := ha.colonizationProfile(ctx, id); err != nil {
+		ms.error(w, err, http.StatusInternalServerError, "failed to fetch colonization profile")
+	} else {
+		ms.send(w, http.StatusOK, p)
+	}
+}
+
+func (ha *HuautlaAdaptor) PatchColonizationProfile(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ms := ha.start(ctx, "PatchColonizationProfile")
+	defer r.Body.Close()
+
+	if id, err := getUUIDByName("id", w, r, ms); err != nil {
+		ms.error(w, err, http.StatusBadRequest, "failed to fetch uuid")
+	} else if p, err := ha.colonizationProfile(ctx, id); err != nil {
+		ms.error(w, err, http.StatusInternalServerError, "failed to fetch colonization profile")
+	} else if body, err := io.ReadAll(r.Body); err != nil {
+		ms.error(w, err, http.StatusBadRequest, "couldn't read request body")
+	} else if err := json.Unmarshal(body, &p); err != nil {
+		ms.error(w, err, http.StatusBadRequest, "couldn't unmarshal request body")
+	} else if err := p.Validate(); err != nil {
+		ms.error(w, err, http.StatusBadRequest, "invalid colonization profile")
+	} else if _, err := ha.db.SelectSubstrate(ctx, id, ms.cid); err != nil {
+		ms.error(w, err, http.StatusBadRequest, "failed to fetch substrate")
+	} else if err := ha.store.Put(ctx, colonizationTable, string(id), p); err != nil {
+		ms.error(w, err, http.StatusInternalServerError, "failed to store colonization profile")
+	} else {
+		ha.emit(r.Context(), ms, "colonizationprofile.updated", id, p)
+		ms.send(w, http.StatusOK, p)
+	}
+}
+
+// colonizationProfile is the profile for a substrate, or the default profile
+// if nobody has tuned one yet
+func (ha *HuautlaAdaptor) colonizationProfile(ctx context.Context, id types.UUID) (imaging.ColonizationProfile, error) {
+	result := imaging.DefaultColonizationProfile
+	if id == "" {
+		return result, nil
+	} else if err := ha.store.Get(ctx, colonizationTable, string(id), &result); errors.Is(err, store.ErrNotFound) {
+		return imaging.DefaultColonizationProfile, nil
+	} else if err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// estimateColonization measures the photo against the profile of the substrate
+// it's most likely of (see colonizingSubstrate); not every photo is of a jar
+// or a bag, so failing to come up with a number is only worth a log message
+func (ha *HuautlaAdaptor) estimateColonization(r *http.Request, oID types.UUID, img image.Image, l *logrus.Entry) *colonization {
+	result := &colonization{}
+
+	var err error
+	if result.Substrate, err = ha.colonizingSubstrate(r, oID); err != nil {
+		l.WithError(err).Warn("failed to find the photo's substrate")
+	}
+
+	if result.profile, err = ha.colonizationProfile(r.Context(), result.Substrate); err != nil {
+		l.WithError(err).Warn("failed to fetch colonization profile")
+	} else if result.Percent, err = imaging.Colonization(img, result.profile); err != nil {
+		l.WithError(err).Info("failed to estimate colonization")
+	} else {
+		return result
+	}
+
+	return nil
+}
+
+// colonizingSubstrate is the ?substrate= named in the request, or else, when
+// the photo belongs to a lifecycle or one of its events, whichever of the
+// lifecycle's substrates is colonizing: the grain until there's a 100%
+// colonization event, and the bulk after that; anything else gets the
+// default profile
+func (ha *HuautlaAdaptor) colonizingSubstrate(r *http.Request, oID types.UUID) (types.UUID, error) {
+	if id := r.URL.Query().Get("substrate"); id != "" {
+		return types.UUID(id), nil
+	}
+
+	l, err := ha.ownerLifecycle(r.Context(), oID, r.Context().Value(metrics.Cid).(types.CID))
+	if err != nil || l == nil {
+		return "", err
+	} else if l.BulkSubstrate.UUID != "" && slices.ContainsFunc(l.Events, func(e types.Event) bool {
+		return strings.EqualFold(e.EventType.Name, fullColonized)
+	}) {
+		return l.BulkSubstrate.UUID, nil
+	}
+	return l.GrainSubstrate.UUID, nil
+}
+
+// ownerLifecycle is the lifecycle oID is, or the one it's an event of, or nil;
+// events don't know their lifecycle, so finding one means looking through
+// all of them
+func (ha *HuautlaAdaptor) ownerLifecycle(ctx context.Context, oID types.UUID, cid types.CID) (*types.Lifecycle, error) {
+	if l, err := ha.db.SelectLifecycle(ctx, oID, cid); err == nil {
+		return &l, nil
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	lcs, err := ha.db.SelectLifecycleIndex(ctx, cid)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range lcs {
+		if l, err = ha.db.SelectLifecycle(ctx, l.UUID, cid); err != nil {
+			return nil, err
+		} else if slices.ContainsFunc(l.Events, func(e types.Event) bool { return e.UUID == oID }) {
+			return &l, nil
+		}
+	}
+	return nil, nil
+}
+
+// suggestEventType looks for a colonization milestone that this photo reached
+// and none of the owner's other photos did
+func (ha *HuautlaAdaptor) suggestEventType(ctx context.Context, photos []types.Photo, p types.Photo, c *colonization, ms *methodStats) {
+	var prior float64
+	for _, other := range photos {
+		var meta photoMeta
+		if other.UUID == p.UUID {
+		} else if err := ha.store.Get(ctx, photoTable, string(other.UUID), &meta); err != nil {
+		} else if meta.Colonization != nil {
+			prior = max(prior, meta.Colonization.Percent)
+		}
+	}
+
+	var name string
+	if c.Percent >= c.profile.Full && prior < c.profile.Full {
+		name = fullColonized
+	} else if c.Percent >= c.profile.Half && prior < c.profile.Half {
+		name = halfColonized
+	} else {
+		return
+	}
+
+	ets, err := ha.db.SelectAllEventTypes(ctx, ms.cid)
+	if err != nil {
+		ms.l.WithError(err).Warn("failed to fetch event types for a suggestion")
+		return
+	}
+
+	for _, et := range ets {
+		if strings.EqualFold(et.Name, name) {
+			c.Suggested = &et
+			return
+		}
+	}
+
+	ms.l.WithField("event_type", name).Warn("no event type to suggest")
+}
@@ -0,0 +1,252 @@
+package huautla
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/data/memory"
+	"github.com/jsmit257/centerforfunguscontrol/internal/imaging"
+	"github.com/jsmit257/centerforfunguscontrol/internal/store"
+	"github.com/jsmit257/centerforfunguscontrol/shared/metrics"
+	"github.com/jsmit257/huautla/types"
+)
+
+var colonizationEventTypes = []types.EventType{
+	{UUID: "0", Name: "Contamination"},
+	{UUID: "1", Name: "50% Colonization"},
+	{UUID: "2", Name: "100% Colonization"},
+}
+
+func Test_GetColonizationProfile(t *testing.T) {
+	t.Parallel()
+
+	tuned := imaging.DefaultColonizationProfile
+	tuned.MinValue = 0.5
+
+	set := map[string]struct {
+		id     types.UUID
+		stored *imaging.ColonizationProfile
+		result imaging.ColonizationProfile
+		sc     int
+	}{
+		"happy_path": {
+			id:     "tuned",
+			stored: &tuned,
+			result: tuned,
+			sc:     http.StatusOK,
+		},
+		"default": {
+			id:     "untuned",
+			result: imaging.DefaultColonizationProfile,
+			sc:     http.StatusOK,
+		},
+		"missing_id": {
+			sc: http.StatusBadRequest,
+		},
+		"urldecode_error": {
+			id: "%zzz",
+			sc: http.StatusBadRequest,
+		},
+	}
+
+	for k, v := range set {
+		k, v := k, v
+		ha := &HuautlaAdaptor{store: store.NewMem()}
+		if v.stored != nil {
+			require.Nil(t, ha.store.Put(context.Background(), colonizationTable, string(v.id), v.stored))
+		}
+		t.Run(k, func(t *testing.T) {
+			t.Parallel()
+
+			w := httptest.NewRecorder()
+			defer w.Result().Body.Close()
+			rctx := chi.NewRouteContext()
+			rctx.URLParams = chi.RouteParams{Keys: []string{"id"}, Values: []string{string(v.id)}}
+			r, _ := http.NewRequestWithContext(
+				context.WithValue(
+					metrics.MockServiceContext,
+					chi.RouteCtxKey,
+					rctx),
+				http.MethodGet,
+				"url",
+				nil)
+
+			ha.GetColonizationProfile(w, r)
+
+			require.Equal(t, v.sc, w.Code)
+			if v.sc != http.StatusOK {
+				return
+			}
+
+			var result imaging.ColonizationProfile
+			require.Nil(t, json.Unmarshal(w.Body.Bytes(), &result))
+			require.Equal(t, v.result, result)
+		})
+	}
+}
+
+func Test_PatchColonizationProfile(t *testing.T) {
+	t.Parallel()
+
+	set := map[string]struct {
+		id     types.UUID
+		body   string
+		selErr error
+		result imaging.ColonizationProfile
+		sc     int
+	}{
+		"happy_path": {
+			id:   "happy_path",
+			body: `{"min_value": 0.5}`,
+			result: func(p imaging.ColonizationProfile) imaging.ColonizationProfile {
+				p.MinValue = 0.5
+				return p
+			}(imaging.DefaultColonizationProfile),
+			sc: http.StatusOK,
+		},
+		"missing_id": {
+			body: `{}`,
+			sc:   http.StatusBadRequest,
+		},
+		"unmarshal_error": {
+			id:   "unmarshal_error",
+			body: `{`,
+			sc:   http.StatusBadRequest,
+		},
+		"invalid_profile": {
+			id:   "invalid_profile",
+			body: `{"half": 99}`,
+			sc:   http.StatusBadRequest,
+		},
+		"missing_substrate": {
+			id:     "missing_substrate",
+			body:   `{}`,
+			selErr: fmt.Errorf("some error"),
+			sc:     http.StatusBadRequest,
+		},
+	}
+
+	for k, v := range set {
+		k, v := k, v
+		ha := &HuautlaAdaptor{
+			db: &huautlaMock{
+				Substrater: &substraterMock{selectErr: v.selErr},
+			},
+			store: store.NewMem(),
+		}
+		t.Run(k, func(t *testing.T) {
+			t.Parallel()
+
+			w := httptest.NewRecorder()
+			defer w.Result().Body.Close()
+			rctx := chi.NewRouteContext()
+			rctx.URLParams = chi.RouteParams{Keys: []string{"id"}, Values: []string{string(v.id)}}
+			r, _ := http.NewRequestWithContext(
+				context.WithValue(
+					metrics.MockServiceContext,
+					chi.RouteCtxKey,
+					rctx),
+				http.MethodPatch,
+				"url",
+				strings.NewReader(v.body))
+
+			ha.PatchColonizationProfile(w, r)
+
+			require.Equal(t, v.sc, w.Code)
+			if v.sc != http.StatusOK {
+				return
+			}
+
+			stored, err := ha.colonizationProfile(context.Background(), v.id)
+			require.Nil(t, err)
+			require.Equal(t, v.result, stored)
+		})
+	}
+}
+
+func Test_colonizingSubstrate(t *testing.T) {
+	t.Parallel()
+
+	ctx := metrics.MockServiceContext
+	db := memory.New()
+	ha := &HuautlaAdaptor{db: db}
+
+	v, err := db.InsertVendor(ctx, types.Vendor{Name: "In house"}, "cid")
+	require.Nil(t, err)
+	s, err := db.InsertStrain(ctx, types.Strain{Name: "Blue Oyster", Vendor: v}, "cid")
+	require.Nil(t, err)
+	rye, err := db.InsertSubstrate(ctx, types.Substrate{Name: "Rye", Type: types.GrainType, Vendor: v}, "cid")
+	require.Nil(t, err)
+	cvg, err := db.InsertSubstrate(ctx, types.Substrate{Name: "CVG", Type: types.BulkType, Vendor: v}, "cid")
+	require.Nil(t, err)
+
+	// lifecycle returns a new lifecycle with an event of each type, and the
+	// id of its last event
+	lifecycle := func(eventTypes ...types.UUID) (types.UUID, types.UUID) {
+		lc, err := db.InsertLifecycle(ctx, types.Lifecycle{
+			Location:       "shelf",
+			Strain:         s,
+			GrainSubstrate: rye,
+			BulkSubstrate:  cvg,
+		}, "cid")
+		require.Nil(t, err)
+		for _, et := range eventTypes {
+			require.Nil(t, db.AddLifecycleEvent(ctx, &lc, types.Event{EventType: types.EventType{UUID: et}}, "cid"))
+		}
+		return lc.UUID, newest(lc.Events).UUID
+	}
+
+	colonizing, inoculated := lifecycle("innoculation")
+	binned, colonized := lifecycle("innoculation", "fullcolonization")
+
+	tcs := map[string]struct {
+		owner     types.UUID
+		query     string
+		substrate types.UUID
+	}{
+		"lifecycle_on_grain": {
+			owner:     colonizing,
+			substrate: rye.UUID,
+		},
+		"event_on_grain": {
+			owner:     inoculated,
+			substrate: rye.UUID,
+		},
+		"lifecycle_on_bulk": {
+			owner:     binned,
+			substrate: cvg.UUID,
+		},
+		"event_on_bulk": {
+			owner:     colonized,
+			substrate: cvg.UUID,
+		},
+		"override": {
+			owner:     binned,
+			query:     "?substrate=" + string(rye.UUID),
+			substrate: rye.UUID,
+		},
+		"not_a_lifecycle": {
+			owner: s.UUID,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest(http.MethodPost, "/photos/"+string(tc.owner)+tc.query, nil).WithContext(ctx)
+			substrate, err := ha.colonizingSubstrate(r, tc.owner)
+			require.Nil(t, err)
+			require.Equal(t, tc.substrate, substrate)
+		})
+	}
+}
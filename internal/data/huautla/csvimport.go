@@ -0,0 +1,664 @@
+package huautla
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/codes"
+	"github.com/jsmit257/huautla/types"
+)
+
+type (
+	// csvSpec says which column of a spreadsheet is which field; Defaults
+	// are for fields a sheet doesn't have a column for, or rows that leave
+	// it empty
+	csvSpec struct {
+		Columns  map[string]string `json:"columns"`
+		Defaults map[string]string `json:"defaults,omitempty"`
+		// a go layout, like 2006-01-02; the usual ones are tried if it's empty
+		TimeFormat string `json:"time_format,omitempty"`
+		// what times without a zone are in, UTC if it's empty
+		Timezone string `json:"timezone,omitempty"`
+	}
+
+	csvReport struct {
+		DryRun bool           `json:"dry_run"`
+		Rows   []csvRowResult `json:"rows"`
+		// how many of each kind of thing were, or would be, created
+		Created map[string]int `json:"created"`
+		Failed  int            `json:"failed"`
+	}
+
+	csvRowResult struct {
+		// the row's line in the file, counting the header
+		Row     int          `json:"row"`
+		Status  int          `json:"status"`
+		Created []csvCreated `json:"created,omitempty"`
+		// the lifecycle the row went into, if there was one; it's empty
+		// for lifecycles a dry run would create
+		Lifecycle types.UUID `json:"lifecycle,omitempty"`
+		Error     string     `json:"error,omitempty"`
+	}
+
+	csvCreated struct {
+		Kind string     `json:"kind"`
+		Name string     `json:"name"`
+		ID   types.UUID `json:"id,omitempty"`
+	}
+
+	// csvValues are one row's fields, parsed
+	csvValues struct {
+		vendor, website, strain, species string
+		grain, bulk, substrateVendor     string
+		location, eventType              string
+		started, eventTime               time.Time
+		strainCost, grainCost, bulkCost  float32
+		yield, gross, temperature        float32
+		count                            int16
+		humidity                         int8
+		hasLocation                      bool
+	}
+
+	csvImporter struct {
+		ha     *HuautlaAdaptor
+		ms     *methodStats
+		dry    bool
+		spec   csvSpec
+		header map[string]int
+		tz     *time.Location
+
+		// everything there already is, and everything rows have created,
+		// by lowercase name; substrates are by type and name
+		vendors    map[string]types.Vendor
+		strains    map[string][]types.Strain
+		substrates map[string]types.Substrate
+		eventTypes map[string]types.EventType
+		index      []types.Lifecycle
+		// lifecycles rows have gone into, by what they're called
+		lifecycles map[string]*csvLifecycle
+
+		report csvReport
+	}
+
+	csvLifecycle struct {
+		types.Lifecycle
+		// the type and time of each of its events, so the same event isn't
+		// added twice
+		events map[string]bool
+	}
+
+	// csvRowError is a row that's wrong, rather than a database that failed
+	csvRowError struct{ error }
+)
+
+// csvFields are the fields a spec can map
+var csvFields = []string{
+	"vendor", "vendor_website",
+	"strain", "species",
+	"grain_substrate", "bulk_substrate", "substrate_vendor",
+	"location", "started",
+	"strain_cost", "grain_cost", "bulk_cost", "yield", "count", "gross",
+	"event_type", "event_time", "temperature", "humidity",
+}
+
+// csvTimeFormats are tried in order when a spec doesn't have a time_format
+var csvTimeFormats = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"1/2/2006 15:04:05",
+	"1/2/2006 15:04",
+	"1/2/2006",
+}
+
+// the most a csv import can be, with room for the rest of the form
+const csvMaxSize = 16 << 20
+
+func rowErrorf(format string, args ...any) error {
+	return csvRowError{fmt.Errorf(format, args...)}
+}
+
+// PostCSVImport adds what's in a spreadsheet: the file part of the form is
+// the csv, with a header, and the mapping part is a csvSpec saying which of
+// its columns are which. Vendors, strains, substrates and event types are
+// found by name, and anything but an event type that isn't found is
+// created; rows for the same location, strain, substrates and start time go
+// into the same lifecycle, which is an existing one if there's one like it
+// that started then, and an event that's already there at the same time
+// isn't added again, so fixing the rows that failed and importing the whole
+// sheet again is safe. With ?dry_run=true nothing is changed and the report
+// says what would have been. It's 207 if any row failed
+func (ha *HuautlaAdaptor) PostCSVImport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ms := ha.start(ctx, "PostCSVImport")
+
+	dry := false
+	if v := r.URL.Query().Get("dry_run"); v == "" {
+	} else if b, err := strconv.ParseBool(v); err != nil {
+		ms.error(w, fmt.Errorf("dry_run must be true or false: %q", v), http.StatusBadRequest, "invalid dry_run")
+		return
+	} else {
+		dry = b
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, csvMaxSize)
+	var spec csvSpec
+
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		ms.error(w, err, http.StatusBadRequest, "couldn't parse multipart form")
+	} else if err = json.Unmarshal([]byte(r.FormValue("mapping")), &spec); err != nil {
+		ms.error(w, err, http.StatusBadRequest, "couldn't unmarshal mapping")
+	} else if f, _, err := r.FormFile("file"); err != nil {
+		ms.error(w, err, http.StatusBadRequest, "missing csv file")
+	} else if report, err := ha.importCSV(ctx, ms, f, spec, dry); errors.As(err, &csvRowError{}) {
+		f.Close()
+		ms.error(w, err, http.StatusBadRequest, err.Error())
+	} else if err != nil {
+		f.Close()
+		ms.error(w, err, http.StatusInternalServerError, "failed to import csv")
+	} else {
+		f.Close()
+		sc := http.StatusOK
+		if report.Failed > 0 {
+			sc = http.StatusMultiStatus
+		} else if !dry && len(report.Created) > 0 {
+			sc = http.StatusCreated
+		}
+		ms.send(w, sc, report)
+	}
+}
+
+// importCSV checks the spec against the header, then does each row in
+// turn; a row that fails doesn't stop the rest, and whatever it created
+// before it failed stays. A csvRowError here is the spec or the header
+func (ha *HuautlaAdaptor) importCSV(ctx context.Context, ms *methodStats, r io.Reader, spec csvSpec, dry bool) (csvReport, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return csvReport{}, rowErrorf("csv is empty")
+	} else if err != nil {
+		return csvReport{}, rowErrorf("couldn't read header: %w", err)
+	}
+
+	ci, err := ha.newCSVImporter(ctx, ms, spec, header, dry)
+	if err != nil {
+		return csvReport{}, err
+	}
+
+	for {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var pe *csv.ParseError
+		if errors.As(err, &pe) {
+			// the reader carries on after a bad quote or the like, so it's
+			// only this row
+			ci.report.add(csvRowResult{Row: pe.StartLine, Status: http.StatusUnprocessableEntity, Error: pe.Err.Error()})
+			continue
+		} else if err != nil {
+			return ci.report, err
+		}
+
+		line, _ := cr.FieldPos(0)
+		if slices.IndexFunc(rec, func(s string) bool { return strings.TrimSpace(s) != "" }) < 0 {
+			continue
+		}
+		ci.report.add(ci.row(ctx, line, rec))
+	}
+
+	return ci.report, nil
+}
+
+func (ha *HuautlaAdaptor) newCSVImporter(ctx context.Context, ms *methodStats, spec csvSpec, header []string, dry bool) (*csvImporter, error) {
+	ci := &csvImporter{
+		ha:         ha,
+		ms:         ms,
+		dry:        dry,
+		spec:       spec,
+		header:     map[string]int{},
+		tz:         time.UTC,
+		vendors:    map[string]types.Vendor{},
+		strains:    map[string][]types.Strain{},
+		substrates: map[string]types.Substrate{},
+		eventTypes: map[string]types.EventType{},
+		lifecycles: map[string]*csvLifecycle{},
+		report:     csvReport{DryRun: dry, Rows: []csvRowResult{}, Created: map[string]int{}},
+	}
+
+	for i, h := range header {
+		// spreadsheets like to start with a byte order mark
+		ci.header[strings.TrimSpace(strings.TrimPrefix(h, "\ufeff"))] = i
+	}
+
+	if len(spec.Columns) == 0 && len(spec.Defaults) == 0 {
+		return nil, rowErrorf("the mapping doesn't map anything")
+	}
+	for field, col := range spec.Columns {
+		if !slices.Contains(csvFields, field) {
+			return nil, rowErrorf("no field called %q, it's one of %s", field, strings.Join(csvFields, ", "))
+		} else if _, ok := ci.header[col]; !ok {
+			return nil, rowErrorf("%s is mapped to %q, which isn't a column", field, col)
+		}
+	}
+	for field := range spec.Defaults {
+		if !slices.Contains(csvFields, field) {
+			return nil, rowErrorf("no field called %q, it's one of %s", field, strings.Join(csvFields, ", "))
+		}
+	}
+	if spec.Timezone != "" {
+		tz, err := time.LoadLocation(spec.Timezone)
+		if err != nil {
+			return nil, rowErrorf("no timezone called %q", spec.Timezone)
+		}
+		ci.tz = tz
+	}
+
+	cid := ms.cid
+	if vendors, err := ha.db.SelectAllVendors(ctx, cid); err != nil {
+		return nil, fmt.Errorf("vendors: %w", err)
+	} else if strains, err := ha.db.SelectAllStrains(ctx, cid); err != nil {
+		return nil, fmt.Errorf("strains: %w", err)
+	} else if substrates, err := ha.db.SelectAllSubstrates(ctx, cid); err != nil {
+		return nil, fmt.Errorf("substrates: %w", err)
+	} else if eventTypes, err := ha.db.SelectAllEventTypes(ctx, cid); err != nil {
+		return nil, fmt.Errorf("event types: %w", err)
+	} else if ci.index, err = ha.db.SelectLifecycleIndex(ctx, cid); err != nil {
+		return nil, fmt.Errorf("lifecycles: %w", err)
+	} else {
+		for _, v := range vendors {
+			ci.vendors[fold(v.Name)] = v
+		}
+		for _, s := range strains {
+			ci.strains[fold(s.Name)] = append(ci.strains[fold(s.Name)], s)
+		}
+		for _, s := range substrates {
+			ci.substrates[fold(string(s.Type), s.Name)] = s
+		}
+		for _, et := range eventTypes {
+			ci.eventTypes[fold(et.Name)] = et
+		}
+	}
+
+	return ci, nil
+}
+
+// fold is what names are matched by, regardless of case and spacing
+func fold(names ...string) string {
+	for i, n := range names {
+		names[i] = strings.ToLower(strings.TrimSpace(n))
+	}
+	return strings.Join(names, "\x00")
+}
+
+// row does everything one row asks for, in order: vendor, strain,
+// substrates, lifecycle, event
+func (ci *csvImporter) row(ctx context.Context, line int, rec []string) csvRowResult {
+	result := csvRowResult{Row: line, Status: http.StatusOK}
+
+	v, err := ci.values(rec)
+	var et types.EventType
+	if err == nil && v.eventType != "" {
+		// event types can't be made up, so it's checked before anything's
+		// created
+		if found, ok := ci.eventTypes[fold(v.eventType)]; !ok {
+			err = rowErrorf("no event type called %q", v.eventType)
+		} else {
+			et = found
+		}
+	}
+
+	var strain types.Strain
+	var grain, bulk types.Substrate
+	var lc *csvLifecycle
+	if err != nil {
+	} else if strain, err = ci.strain(ctx, &result, v); err != nil {
+	} else if grain, err = ci.substrate(ctx, &result, types.GrainType, v.grain, v); err != nil {
+	} else if bulk, err = ci.substrate(ctx, &result, types.BulkType, v.bulk, v); err != nil {
+	} else if lc, err = ci.lifecycle(ctx, &result, v, strain, grain, bulk); err != nil {
+	} else if lc != nil && v.eventType != "" {
+		err = ci.event(ctx, &result, lc, et, v)
+	}
+
+	if lc != nil {
+		result.Lifecycle = lc.UUID
+	}
+	if err == nil {
+		return result
+	}
+
+	sc, msg := http.StatusUnprocessableEntity, err.Error()
+	if !errors.As(err, &csvRowError{}) {
+		sc, msg = http.StatusInternalServerError, "failed to import row"
+	}
+	ci.ms.l.WithError(err).WithField("row", line).Error("failed to import csv row")
+	result.Status, result.Error = sc, msg
+	return result
+}
+
+// values are rec's fields, checked and parsed; a row that has a lifecycle
+// or an event has to have everything that goes with it
+func (ci *csvImporter) values(rec []string) (csvValues, error) {
+	get := func(field string) string {
+		if col, ok := ci.spec.Columns[field]; ok {
+			if i := ci.header[col]; i < len(rec) && strings.TrimSpace(rec[i]) != "" {
+				return strings.TrimSpace(rec[i])
+			}
+		}
+		return strings.TrimSpace(ci.spec.Defaults[field])
+	}
+
+	_, mapped := ci.spec.Columns["location"]
+	_, defaulted := ci.spec.Defaults["location"]
+
+	v := csvValues{
+		vendor:          get("vendor"),
+		website:         get("vendor_website"),
+		strain:          get("strain"),
+		species:         get("species"),
+		grain:           get("grain_substrate"),
+		bulk:            get("bulk_substrate"),
+		substrateVendor: get("substrate_vendor"),
+		location:        get("location"),
+		eventType:       get("event_type"),
+		hasLocation:     mapped || defaulted,
+	}
+
+	var err error
+	for _, f := range []struct {
+		field string
+		dst   *float32
+	}{
+		{"strain_cost", &v.strainCost},
+		{"grain_cost", &v.grainCost},
+		{"bulk_cost", &v.bulkCost},
+		{"yield", &v.yield},
+		{"gross", &v.gross},
+		{"temperature", &v.temperature},
+	} {
+		if *f.dst, err = csvFloat(f.field, get(f.field)); err != nil {
+			return v, err
+		}
+	}
+
+	if s := get("count"); s == "" {
+	} else if n, err := strconv.ParseInt(s, 10, 16); err != nil || n < 0 {
+		return v, rowErrorf("count isn't a number: %q", s)
+	} else {
+		v.count = int16(n)
+	}
+	if s := strings.TrimSuffix(get("humidity"), "%"); s == "" {
+	} else if n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 8); err != nil || n < 0 || n > 100 {
+		return v, rowErrorf("humidity isn't a percentage: %q", s)
+	} else {
+		v.humidity = int8(n)
+	}
+
+	if v.started, err = ci.time("started", get("started")); err != nil {
+		return v, err
+	} else if v.eventTime, err = ci.time("event_time", get("event_time")); err != nil {
+		return v, err
+	}
+
+	if v.hasLocation && v.location == "" {
+		return v, rowErrorf("no location")
+	} else if v.location != "" && (v.strain == "" || v.grain == "" || v.bulk == "") {
+		return v, rowErrorf("a lifecycle needs a strain, a grain substrate and a bulk substrate")
+	} else if v.eventType != "" && v.location == "" {
+		return v, rowErrorf("an event needs a lifecycle, and there's no location")
+	}
+	return v, nil
+}
+
+func csvFloat(field, s string) (float32, error) {
+	if s == "" {
+		return 0, nil
+	}
+	f, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimPrefix(s, "$"), ",", ""), 32)
+	if err != nil {
+		return 0, rowErrorf("%s isn't a number: %q", field, s)
+	}
+	return float32(f), nil
+}
+
+func (ci *csvImporter) time(field, s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	layouts := csvTimeFormats
+	if ci.spec.TimeFormat != "" {
+		layouts = []string{ci.spec.TimeFormat}
+	}
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, s, ci.tz); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, rowErrorf("%s isn't a time: %q", field, s)
+}
+
+// vendor is the vendor called name, which is created if there isn't one
+func (ci *csvImporter) vendor(ctx context.Context, result *csvRowResult, name, website string) (types.Vendor, error) {
+	if v, ok := ci.vendors[fold(name)]; ok {
+		return v, nil
+	}
+
+	v := types.Vendor{Name: name, Website: website}
+	if !ci.dry {
+		var err error
+		if v, err = ci.ha.db.InsertVendor(ctx, v, ci.ms.cid); err != nil {
+			return v, fmt.Errorf("vendor %q: %w", name, err)
+		}
+		ci.ha.emit(ctx, ci.ms, "vendor.created", v.UUID, v)
+	}
+	ci.vendors[fold(name)] = v
+	ci.created(result, "vendor", name, v.UUID)
+	return v, nil
+}
+
+// strain is the row's strain, from its vendor if the row has one; it's
+// created if there isn't one, which takes a vendor
+func (ci *csvImporter) strain(ctx context.Context, result *csvRowResult, v csvValues) (types.Strain, error) {
+	if v.strain == "" {
+		if v.vendor != "" {
+			_, err := ci.vendor(ctx, result, v.vendor, v.website)
+			return types.Strain{}, err
+		}
+		return types.Strain{}, nil
+	}
+
+	var found []types.Strain
+	for _, s := range ci.strains[fold(v.strain)] {
+		if v.vendor == "" || fold(s.Vendor.Name) == fold(v.vendor) {
+			found = append(found, s)
+		}
+	}
+	if len(found) == 1 {
+		return found[0], nil
+	} else if len(found) > 1 {
+		return types.Strain{}, rowErrorf("there's more than one strain called %q, map a vendor to say which", v.strain)
+	} else if v.vendor == "" {
+		return types.Strain{}, rowErrorf("there's no strain called %q, and no vendor to create it with", v.strain)
+	}
+
+	vendor, err := ci.vendor(ctx, result, v.vendor, v.website)
+	if err != nil {
+		return types.Strain{}, err
+	}
+
+	s := types.Strain{Name: v.strain, Species: v.species, Vendor: vendor}
+	if !ci.dry {
+		if s, err = ci.ha.db.InsertStrain(ctx, s, ci.ms.cid); err != nil {
+			return s, fmt.Errorf("strain %q: %w", v.strain, err)
+		}
+		ci.ha.assignCode(ctx, ci.ms, codes.Strain, s.UUID)
+		ci.ha.emit(ctx, ci.ms, "strain.created", s.UUID, s)
+	}
+	ci.strains[fold(v.strain)] = append(ci.strains[fold(v.strain)], s)
+	ci.created(result, "strain", v.strain, s.UUID)
+	return s, nil
+}
+
+// substrate is the substrate of type typ called name; it's created if
+// there isn't one, from the row's substrate vendor, or the strain's vendor
+// if it doesn't have one
+func (ci *csvImporter) substrate(ctx context.Context, result *csvRowResult, typ types.SubstrateType, name string, v csvValues) (types.Substrate, error) {
+	if name == "" {
+		return types.Substrate{}, nil
+	} else if s, ok := ci.substrates[fold(string(typ), name)]; ok {
+		return s, nil
+	}
+
+	vendorName := v.substrateVendor
+	if vendorName == "" {
+		vendorName = v.vendor
+	}
+	if vendorName == "" {
+		return types.Substrate{}, rowErrorf("there's no %s substrate called %q, and no vendor to create it with", typ, name)
+	}
+	vendor, err := ci.vendor(ctx, result, vendorName, "")
+	if err != nil {
+		return types.Substrate{}, err
+	}
+
+	s := types.Substrate{Name: name, Type: typ, Vendor: vendor}
+	if !ci.dry {
+		if s, err = ci.ha.db.InsertSubstrate(ctx, s, ci.ms.cid); err != nil {
+			return s, fmt.Errorf("%s substrate %q: %w", typ, name, err)
+		}
+		ci.ha.emit(ctx, ci.ms, "substrate.created", s.UUID, s)
+	}
+	ci.substrates[fold(string(typ), name)] = s
+	ci.created(result, "substrate", name, s.UUID)
+	return s, nil
+}
+
+// lifecycle is the one the row goes into: one an earlier row went into,
+// one that's already there at the same location for the same strain that
+// started at the same time, or a new one
+func (ci *csvImporter) lifecycle(ctx context.Context, result *csvRowResult, v csvValues, strain types.Strain, grain, bulk types.Substrate) (*csvLifecycle, error) {
+	if v.location == "" {
+		return nil, nil
+	}
+
+	started := ""
+	if !v.started.IsZero() {
+		started = v.started.Format(time.RFC3339)
+	}
+	key := fold(v.location, v.strain, v.grain, v.bulk, started)
+	if lc, ok := ci.lifecycles[key]; ok {
+		return lc, nil
+	}
+
+	if started != "" && strain.UUID != "" {
+		for _, l := range ci.index {
+			if fold(l.Location) != fold(v.location) || l.Strain.UUID != strain.UUID || !l.CTime.Equal(v.started) {
+				continue
+			}
+			full, err := ci.ha.db.SelectLifecycle(ctx, l.UUID, ci.ms.cid)
+			if err != nil {
+				return nil, fmt.Errorf("lifecycle %s: %w", l.UUID, err)
+			}
+			lc := &csvLifecycle{Lifecycle: full, events: map[string]bool{}}
+			for _, e := range full.Events {
+				lc.events[eventKey(e.EventType, e.CTime)] = true
+			}
+			ci.lifecycles[key] = lc
+			return lc, nil
+		}
+	}
+
+	lc := &csvLifecycle{
+		Lifecycle: types.Lifecycle{
+			Location:       v.location,
+			StrainCost:     v.strainCost,
+			GrainCost:      v.grainCost,
+			BulkCost:       v.bulkCost,
+			Yield:          v.yield,
+			Count:          v.count,
+			Gross:          v.gross,
+			Strain:         strain,
+			GrainSubstrate: grain,
+			BulkSubstrate:  bulk,
+		},
+		events: map[string]bool{},
+	}
+	if !ci.dry {
+		added, err := ci.ha.db.InsertLifecycle(ctx, lc.Lifecycle, ci.ms.cid)
+		if err != nil {
+			return nil, fmt.Errorf("lifecycle at %q: %w", v.location, err)
+		} else if started != "" {
+			if err = ci.ha.db.UpdateTimestamps(ctx, "lifecycles", added.UUID, types.Timestamp{
+				Fields: []string{"ctime"},
+				Origin: &v.started,
+			}); err != nil {
+				ci.ms.l.WithError(err).WithField("lifecycle", added.UUID).Error("failed to backdate lifecycle")
+			} else {
+				added.CTime = v.started
+			}
+		}
+		lc.Lifecycle = added
+		ci.ha.assignCode(ctx, ci.ms, codes.Lifecycle, added.UUID)
+		ci.ha.emit(ctx, ci.ms, "lifecycle.created", added.UUID, added)
+	}
+	ci.lifecycles[key] = lc
+	ci.created(result, "lifecycle", v.location, lc.UUID)
+	return lc, nil
+}
+
+// event adds the row's event to lc, unless it already has one of the same
+// type at the same time
+func (ci *csvImporter) event(ctx context.Context, result *csvRowResult, lc *csvLifecycle, et types.EventType, v csvValues) error {
+	k := eventKey(et, v.eventTime)
+	if !v.eventTime.IsZero() && lc.events[k] {
+		return nil
+	}
+
+	e := types.Event{EventType: et, Temperature: v.temperature, Humidity: v.humidity}
+	if !ci.dry {
+		if err := ci.ha.db.AddLifecycleEvent(ctx, &lc.Lifecycle, e, ci.ms.cid); err != nil {
+			return fmt.Errorf("%s event: %w", et.Name, err)
+		}
+		var err error
+		if e, err = ci.ha.backdate(ctx, newest(lc.Events), v.eventTime); err != nil {
+			ci.ms.l.WithError(err).WithField("event", e.UUID).Error("failed to backdate event")
+		}
+		ci.ha.emit(ctx, ci.ms, "event.added", e.UUID, owned{lc.UUID, e})
+	}
+	if !v.eventTime.IsZero() {
+		lc.events[k] = true
+	}
+	ci.created(result, "event", et.Name, e.UUID)
+	return nil
+}
+
+func eventKey(et types.EventType, t time.Time) string {
+	return string(et.UUID) + "\x00" + t.UTC().Format(time.RFC3339)
+}
+
+func (ci *csvImporter) created(result *csvRowResult, kind, name string, id types.UUID) {
+	result.Status = http.StatusCreated
+	result.Created = append(result.Created, csvCreated{Kind: kind, Name: name, ID: id})
+	ci.report.Created[kind]++
+}
+
+func (cr *csvReport) add(row csvRowResult) {
+	if row.Error != "" {
+		cr.Failed++
+	}
+	cr.Rows = append(cr.Rows, row)
+}
@@ -0,0 +1,244 @@
+package huautla
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/data/memory"
+	"github.com/jsmit257/centerforfunguscontrol/shared/metrics"
+	"github.com/jsmit257/huautla/types"
+)
+
+const csvMapping = `{
+	"columns": {
+		"location": "Tub",
+		"strain": "Strain",
+		"vendor": "Vendor",
+		"species": "Species",
+		"grain_substrate": "Grain",
+		"bulk_substrate": "Bulk",
+		"started": "Started",
+		"event_type": "Event",
+		"event_time": "Date",
+		"temperature": "Temp",
+		"humidity": "RH",
+		"yield": "Yield"
+	},
+	"defaults": {"substrate_vendor": "In house"}
+}`
+
+const csvHeader = "Tub,Strain,Vendor,Species,Grain,Bulk,Started,Event,Date,Temp,RH,Yield\n"
+
+func sendCSV(f http.HandlerFunc, query, mapping, sheet string) *httptest.ResponseRecorder {
+	b := &bytes.Buffer{}
+	mw := multipart.NewWriter(b)
+	_ = mw.WriteField("mapping", mapping)
+	if sheet != "" {
+		fw, _ := mw.CreateFormFile("file", "grows.csv")
+		_, _ = fw.Write([]byte(sheet))
+	}
+	mw.Close()
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequestWithContext(
+		context.WithValue(metrics.MockServiceContext, chi.RouteCtxKey, chi.NewRouteContext()),
+		http.MethodPost,
+		"url"+query,
+		b)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+
+	f(w, r)
+
+	return w
+}
+
+func newCSVAdaptor(t *testing.T) *HuautlaAdaptor {
+	db := memory.New()
+	require.Nil(t, db.Seed(metrics.MockServiceContext))
+	return &HuautlaAdaptor{db: db}
+}
+
+func Test_PostCSVImport(t *testing.T) {
+	t.Parallel()
+
+	grows := csvHeader +
+		"Tub 1,Pink Oyster,Spore Works,Pleurotus djamor,Rye berries,CVG,2025-03-01,Innoculation,2025-03-01,72,60,\n" +
+		"Tub 1,Pink Oyster,Spore Works,Pleurotus djamor,Rye berries,CVG,2025-03-01,Harvesting,2025-03-20 08:30,70,90%,\n" +
+		",,,,,,,,,,,\n" +
+		"Tub 2,blue oyster,,,Oats,Straw,4/1/2025,,,,,1.5\n"
+
+	tcs := map[string]struct {
+		query, mapping, sheet string
+		sc                    int
+		created               map[string]int
+		statuses              []int
+		errors                []string
+	}{
+		"happy_path": {
+			mapping:  csvMapping,
+			sheet:    grows,
+			sc:       http.StatusCreated,
+			created:  map[string]int{"vendor": 1, "strain": 1, "substrate": 1, "lifecycle": 2, "event": 2},
+			statuses: []int{http.StatusCreated, http.StatusCreated, http.StatusCreated},
+		},
+		"dry_run": {
+			query:    "?dry_run=true",
+			mapping:  csvMapping,
+			sheet:    grows,
+			sc:       http.StatusOK,
+			created:  map[string]int{"vendor": 1, "strain": 1, "substrate": 1, "lifecycle": 2, "event": 2},
+			statuses: []int{http.StatusCreated, http.StatusCreated, http.StatusCreated},
+		},
+		"some_rows_fail": {
+			mapping: csvMapping,
+			sheet: csvHeader +
+				"Tub 3,Blue Oyster,,,Oats,CVG,2025-05-01,Dancing,2025-05-01,,,\n" +
+				"Tub 3,Blue Oyster,,,Oats,CVG,2025-05-01,Binning,yesterday,,,\n" +
+				",Blue Oyster,,,Oats,CVG,,,,,,\n" +
+				"Tub 4,Mystery,,,Oats,CVG,,,,,,\n" +
+				"Tub 5,Blue Oyster,,,Oats,CVG,,,,,,lots\n" +
+				"Tub 3,Blue Oyster,,,Oats,CVG,2025-05-01,Binning,2025-05-10,75,95%,\n",
+			sc:      http.StatusMultiStatus,
+			created: map[string]int{"lifecycle": 1, "event": 1},
+			statuses: []int{
+				http.StatusUnprocessableEntity,
+				http.StatusUnprocessableEntity,
+				http.StatusUnprocessableEntity,
+				http.StatusUnprocessableEntity,
+				http.StatusUnprocessableEntity,
+				http.StatusCreated,
+			},
+			errors: []string{
+				`no event type called "Dancing"`,
+				`event_time isn't a time: "yesterday"`,
+				"no location",
+				`there's no strain called "Mystery", and no vendor to create it with`,
+				`yield isn't a number: "lots"`,
+				"",
+			},
+		},
+		"strains_only": {
+			mapping:  `{"columns": {"strain": "Strain", "vendor": "Vendor", "species": "Species"}}`,
+			sheet:    "Strain,Vendor,Species\nPink Oyster,Spore Works,Pleurotus djamor\nLion's Mane,,\n",
+			sc:       http.StatusCreated,
+			created:  map[string]int{"vendor": 1, "strain": 1},
+			statuses: []int{http.StatusCreated, http.StatusOK},
+		},
+		"unknown_field": {
+			mapping: `{"columns": {"colour": "Tub"}}`,
+			sheet:   grows,
+			sc:      http.StatusBadRequest,
+		},
+		"missing_column": {
+			mapping: `{"columns": {"location": "Bin"}}`,
+			sheet:   grows,
+			sc:      http.StatusBadRequest,
+		},
+		"empty_mapping": {
+			mapping: `{}`,
+			sheet:   grows,
+			sc:      http.StatusBadRequest,
+		},
+		"malformed_mapping": {
+			mapping: `{`,
+			sheet:   grows,
+			sc:      http.StatusBadRequest,
+		},
+		"no_file": {
+			mapping: csvMapping,
+			sc:      http.StatusBadRequest,
+		},
+		"bad_dry_run": {
+			query:   "?dry_run=maybe",
+			mapping: csvMapping,
+			sheet:   grows,
+			sc:      http.StatusBadRequest,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ha := newCSVAdaptor(t)
+			before, err := ha.db.SelectLifecycleIndex(metrics.MockServiceContext, "cid")
+			require.Nil(t, err)
+
+			w := sendCSV(ha.PostCSVImport, tc.query, tc.mapping, tc.sheet)
+			require.Equal(t, tc.sc, w.Code, w.Body.String())
+			if tc.sc == http.StatusBadRequest {
+				return
+			}
+
+			report := csvReport{}
+			require.Nil(t, json.Unmarshal(w.Body.Bytes(), &report))
+			require.Equal(t, tc.created, report.Created)
+
+			var statuses []int
+			var errs []string
+			for _, row := range report.Rows {
+				statuses = append(statuses, row.Status)
+				errs = append(errs, row.Error)
+			}
+			require.Equal(t, tc.statuses, statuses)
+			if tc.errors != nil {
+				require.Equal(t, tc.errors, errs)
+			}
+
+			after, err := ha.db.SelectLifecycleIndex(metrics.MockServiceContext, "cid")
+			require.Nil(t, err)
+			if report.DryRun {
+				require.Equal(t, len(before), len(after))
+			} else {
+				require.Equal(t, len(before)+tc.created["lifecycle"], len(after))
+			}
+		})
+	}
+}
+
+func Test_importCSVAgain(t *testing.T) {
+	t.Parallel()
+
+	ctx := metrics.MockServiceContext
+	ha := newCSVAdaptor(t)
+	sheet := csvHeader +
+		"Tub 1,Pink Oyster,Spore Works,,Rye berries,CVG,2025-03-01,Innoculation,2025-03-01,72,60,\n" +
+		"Tub 1,Pink Oyster,Spore Works,,Rye berries,CVG,2025-03-01,Harvesting,2025-03-20,70,90,\n"
+
+	w := sendCSV(ha.PostCSVImport, "", csvMapping, sheet)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	report := csvReport{}
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &report))
+	require.NotEmpty(t, report.Rows[0].Lifecycle)
+	require.Equal(t, report.Rows[0].Lifecycle, report.Rows[1].Lifecycle)
+
+	lc, err := ha.db.SelectLifecycle(ctx, report.Rows[0].Lifecycle, "cid")
+	require.Nil(t, err)
+	require.Equal(t, time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), lc.CTime)
+	require.Len(t, lc.Events, 2)
+	require.Equal(t, types.UUID("harvesting"), lc.Events[0].EventType.UUID)
+	require.Equal(t, time.Date(2025, 3, 20, 0, 0, 0, 0, time.UTC), lc.Events[0].CTime)
+	require.Equal(t, float32(70), lc.Events[0].Temperature)
+
+	// the same sheet again finds everything it made the first time
+	w = sendCSV(ha.PostCSVImport, "", csvMapping, sheet)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	again := csvReport{}
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &again))
+	require.Empty(t, again.Created)
+	require.Equal(t, report.Rows[0].Lifecycle, again.Rows[0].Lifecycle)
+
+	lc, err = ha.db.SelectLifecycle(ctx, report.Rows[0].Lifecycle, "cid")
+	require.Nil(t, err)
+	require.Len(t, lc.Events, 2)
+}
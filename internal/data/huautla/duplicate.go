@@ -0,0 +1,268 @@
+package huautla
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/imaging"
+	"github.com/jsmit257/centerforfunguscontrol/internal/store"
+	"github.com/jsmit257/huautla/types"
+)
+
+type (
+	duplicatePhoto struct {
+		UUID  types.UUID `json:"id"`
+		Owner types.UUID `json:"owner"`
+		PHash string     `json:"phash"`
+	}
+
+	// duplicateCluster is a group of photos that all look like at least one
+	// other photo in the group
+	duplicateCluster struct {
+		Photos []duplicatePhoto `json:"photos"`
+		Owners []types.UUID     `json:"owners"`
+	}
+)
+
+var errDuplicatePhoto = errors.New("photo is a near-duplicate")
+
+func formatHash(h uint64) string {
+	return fmt.Sprintf("%016x", h)
+}
+
+func parseHash(s string) (uint64, error) {
+	return strconv.ParseUint(s, 16, 64)
+}
+
+func (ha *HuautlaAdaptor) hashFile(filename string) (uint64, error) {
+	data, err := ha.reader(path.Join(ha.album, filename))
+	if err != nil {
+		return 0, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return 0, err
+	}
+	return imaging.PHash(img), nil
+}
+
+// photoHash is p's perceptual hash; photos that were uploaded before photos
+// were hashed are hashed now, and remembered
+func (ha *HuautlaAdaptor) photoHash(ctx context.Context, oID types.UUID, p types.Photo) (uint64, error) {
+	var meta photoMeta
+	if err := ha.store.Get(ctx, photoTable, string(p.UUID), &meta); errors.Is(err, store.ErrNotFound) {
+		meta.Owner = oID
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to fetch photo metadata: %w", err)
+	} else if meta.PHash != "" {
+		return parseHash(meta.PHash)
+	}
+
+	h, err := ha.hashFile(p.Filename)
+	if err != nil {
+		return 0, err
+	}
+	meta.PHash = formatHash(h)
+	return h, ha.store.Put(ctx, photoTable, string(p.UUID), meta)
+}
+
+// duplicates finds the photos in others that look like hash
+func (ha *HuautlaAdaptor) duplicates(ctx context.Context, oID types.UUID, others []types.Photo, hash uint64, l *logrus.Entry) []types.UUID {
+	var result []types.UUID
+	for _, p := range others {
+		if h, err := ha.photoHash(ctx, oID, p); err != nil {
+			l.WithError(err).WithField("photo", p.UUID).Info("skipping photo that can't be hashed")
+		} else if imaging.Distance(h, hash) <= ha.duplicateDistance {
+			result = append(result, p.UUID)
+		}
+	}
+
+	if len(result) > 0 {
+		l.WithField("duplicates", result).Warn("photo is a near-duplicate")
+	}
+
+	return result
+}
+
+// GetDuplicatePhotos reports every cluster of similar photos in the album, no
+// matter who they belong to; `?distance=` overrides the configured threshold
+func (ha *HuautlaAdaptor) GetDuplicatePhotos(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ms := ha.start(ctx, "GetDuplicatePhotos")
+
+	distance := ha.duplicateDistance
+	if v := r.URL.Query().Get("distance"); v == "" {
+	} else if n, err := strconv.Atoi(v); err != nil || n < 0 || n > 64 {
+		ms.error(w, fmt.Errorf("distance must be between 0 and 64: %q", v), http.StatusBadRequest, "invalid distance")
+		return
+	} else {
+		distance = n
+	}
+
+	if photos, err := ha.hashedPhotos(ctx, ms); err != nil {
+		ms.error(w, err, http.StatusInternalServerError, "failed to fetch photos")
+	} else {
+		ms.send(w, http.StatusOK, duplicateClusters(photos, distance))
+	}
+}
+
+// hashedPhotos is every photo there is with its hash, hashing the ones that
+// haven't been yet; photos that can't be hashed are left out
+func (ha *HuautlaAdaptor) hashedPhotos(ctx context.Context, ms *methodStats) ([]duplicatePhoto, error) {
+	owners, err := ha.photoOwners(ctx, ms.cid)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []duplicatePhoto
+	for _, o := range owners {
+		photos, err := ha.db.GetPhotos(ctx, o, ms.cid)
+		if err != nil {
+			return nil, fmt.Errorf("photos of %s: %w", o, err)
+		}
+
+		for _, p := range photos {
+			if h, err := ha.photoHash(ctx, o, p); err != nil {
+				ms.l.WithError(err).WithField("photo", p.UUID).Info("skipping photo that can't be hashed")
+			} else {
+				result = append(result, duplicatePhoto{UUID: p.UUID, Owner: o, PHash: formatHash(h)})
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// photoOwners is everything that can have photos: vendors, ingredients,
+// substrates, strains, generations, lifecycles and their events
+func (ha *HuautlaAdaptor) photoOwners(ctx context.Context, cid types.CID) ([]types.UUID, error) {
+	var result []types.UUID
+
+	if vendors, err := ha.db.SelectAllVendors(ctx, cid); err != nil {
+		return nil, fmt.Errorf("vendors: %w", err)
+	} else {
+		for _, v := range vendors {
+			result = append(result, v.UUID)
+		}
+	}
+
+	if ingredients, err := ha.db.SelectAllIngredients(ctx, cid); err != nil {
+		return nil, fmt.Errorf("ingredients: %w", err)
+	} else {
+		for _, i := range ingredients {
+			result = append(result, i.UUID)
+		}
+	}
+
+	if substrates, err := ha.db.SelectAllSubstrates(ctx, cid); err != nil {
+		return nil, fmt.Errorf("substrates: %w", err)
+	} else {
+		for _, s := range substrates {
+			result = append(result, s.UUID)
+		}
+	}
+
+	if strains, err := ha.db.SelectAllStrains(ctx, cid); err != nil {
+		return nil, fmt.Errorf("strains: %w", err)
+	} else {
+		for _, s := range strains {
+			result = append(result, s.UUID)
+		}
+	}
+
+	// the indexes don't have events
+	if gens, err := ha.db.SelectGenerationIndex(ctx, cid); err != nil {
+		return nil, fmt.Errorf("generations: %w", err)
+	} else {
+		for _, g := range gens {
+			full, err := ha.db.SelectGeneration(ctx, g.UUID, cid)
+			if err != nil {
+				return nil, fmt.Errorf("generation %s: %w", g.UUID, err)
+			}
+			result = append(result, g.UUID)
+			for _, e := range full.Events {
+				result = append(result, e.UUID)
+			}
+		}
+	}
+
+	if lcs, err := ha.db.SelectLifecycleIndex(ctx, cid); err != nil {
+		return nil, fmt.Errorf("lifecycles: %w", err)
+	} else {
+		for _, l := range lcs {
+			full, err := ha.db.SelectLifecycle(ctx, l.UUID, cid)
+			if err != nil {
+				return nil, fmt.Errorf("lifecycle %s: %w", l.UUID, err)
+			}
+			result = append(result, l.UUID)
+			for _, e := range full.Events {
+				result = append(result, e.UUID)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// duplicateClusters links every pair of photos within distance of each other
+// and returns the groups that have more than one photo in them
+func duplicateClusters(photos []duplicatePhoto, distance int) []duplicateCluster {
+	hashes := make([]uint64, len(photos))
+	for i, p := range photos {
+		hashes[i], _ = parseHash(p.PHash)
+	}
+
+	parent := make([]int, len(photos))
+	for i := range parent {
+		parent[i] = i
+	}
+	var root func(int) int
+	root = func(i int) int {
+		if parent[i] != i {
+			parent[i] = root(parent[i])
+		}
+		return parent[i]
+	}
+
+	for i := range photos {
+		for j := i + 1; j < len(photos); j++ {
+			if imaging.Distance(hashes[i], hashes[j]) <= distance {
+				parent[root(j)] = root(i)
+			}
+		}
+	}
+
+	groups := map[int]*duplicateCluster{}
+	var order []int
+	for i, p := range photos {
+		r := root(i)
+		c, ok := groups[r]
+		if !ok {
+			c = &duplicateCluster{}
+			groups[r] = c
+			order = append(order, r)
+		}
+		c.Photos = append(c.Photos, p)
+		if !slices.Contains(c.Owners, p.Owner) {
+			c.Owners = append(c.Owners, p.Owner)
+		}
+	}
+
+	result := []duplicateCluster{}
+	for _, r := range order {
+		if len(groups[r].Photos) > 1 {
+			result = append(result, *groups[r])
+		}
+	}
+
+	return result
+}
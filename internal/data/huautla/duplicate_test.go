@@ -0,0 +1,173 @@
+package huautla
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/data/memory"
+	"github.com/jsmit257/centerforfunguscontrol/internal/store"
+	"github.com/jsmit257/centerforfunguscontrol/shared/metrics"
+	"github.com/jsmit257/huautla/types"
+)
+
+func Test_GetDuplicatePhotos(t *testing.T) {
+	t.Parallel()
+
+	ctx := metrics.MockServiceContext
+	db := memory.New()
+	ha := &HuautlaAdaptor{
+		db:                db,
+		store:             store.NewMem(),
+		album:             t.TempDir(),
+		reader:            os.ReadFile,
+		duplicateDistance: 6,
+	}
+
+	vendor, err := db.InsertVendor(ctx, types.Vendor{Name: "In house"}, "cid")
+	require.Nil(t, err)
+	s, err := db.InsertStrain(ctx, types.Strain{Name: "Blue Oyster", Vendor: vendor}, "cid")
+	require.Nil(t, err)
+	rye, err := db.InsertSubstrate(ctx, types.Substrate{Name: "Rye", Type: types.GrainType, Vendor: vendor}, "cid")
+	require.Nil(t, err)
+	cvg, err := db.InsertSubstrate(ctx, types.Substrate{Name: "CVG", Type: types.BulkType, Vendor: vendor}, "cid")
+	require.Nil(t, err)
+	lc, err := db.InsertLifecycle(ctx, types.Lifecycle{Location: "shelf", Strain: s, GrainSubstrate: rye, BulkSubstrate: cvg}, "cid")
+	require.Nil(t, err)
+	require.Nil(t, db.AddLifecycleEvent(ctx, &lc, types.Event{EventType: types.EventType{UUID: "innoculation"}}, "cid"))
+
+	// the database makes its own ids, so results are checked by name
+	owners := map[types.UUID]types.UUID{
+		"vendor":    vendor.UUID,
+		"strain":    s.UUID,
+		"lifecycle": lc.UUID,
+		"event":     lc.Events[0].UUID,
+	}
+	names := map[types.UUID]types.UUID{}
+	for name, id := range owners {
+		names[id] = name
+	}
+
+	// a and b differ by 2 bits, b and c by 4, so at the default distance they
+	// chain into one cluster even though a and c differ by 6; g can't be
+	// hashed, h hasn't been yet, and x's photo is gone
+	metas := map[types.UUID]photoMeta{
+		"a": {Owner: "event", PHash: "0000000000000000"},
+		"b": {Owner: "lifecycle", PHash: "0000000000000003"},
+		"c": {Owner: "strain", PHash: "000000000000003f"},
+		"d": {Owner: "event", PHash: "ffffffffffffffff"},
+		"e": {Owner: "vendor", PHash: "fffffffffffffffe"},
+		"f": {Owner: "event", PHash: "00000000ffffffff"},
+		"g": {Owner: "event"},
+		"x": {Owner: "lifecycle", PHash: "0000000000000001"},
+	}
+	for name, m := range metas {
+		id := name
+		m.Owner = owners[m.Owner]
+		if name != "x" {
+			photos, err := db.AddPhoto(ctx, m.Owner, nil, types.Photo{Filename: string(name) + ".png"}, "cid")
+			require.Nil(t, err)
+			id = photos[0].UUID
+		}
+		names[id] = name
+		require.Nil(t, ha.store.Put(ctx, photoTable, string(id), m))
+	}
+
+	// all one color, so its hash is 0
+	b := &bytes.Buffer{}
+	require.Nil(t, png.Encode(b, image.NewGray(image.Rect(0, 0, 16, 16))))
+	require.Nil(t, os.WriteFile(filepath.Join(ha.album, "h.png"), b.Bytes(), 0644))
+	photos, err := db.AddPhoto(ctx, vendor.UUID, nil, types.Photo{Filename: "h.png"}, "cid")
+	require.Nil(t, err)
+	h := photos[0].UUID
+	names[h] = "h"
+
+	set := map[string]struct {
+		query    string
+		clusters [][]types.UUID
+		owners   [][]types.UUID
+		sc       int
+	}{
+		"happy_path": {
+			clusters: [][]types.UUID{{"a", "b", "c", "h"}, {"d", "e"}},
+			owners:   [][]types.UUID{{"event", "lifecycle", "strain", "vendor"}, {"event", "vendor"}},
+			sc:       http.StatusOK,
+		},
+		"stricter": {
+			query:    "?distance=1",
+			clusters: [][]types.UUID{{"a", "h"}, {"d", "e"}},
+			owners:   [][]types.UUID{{"event", "vendor"}, {"event", "vendor"}},
+			sc:       http.StatusOK,
+		},
+		"nothing_alike": {
+			query:    "?distance=0",
+			clusters: [][]types.UUID{{"a", "h"}},
+			owners:   [][]types.UUID{{"event", "vendor"}},
+			sc:       http.StatusOK,
+		},
+		"invalid_distance": {
+			query: "?distance=65",
+			sc:    http.StatusBadRequest,
+		},
+	}
+
+	for k, v := range set {
+		k, v := k, v
+		t.Run(k, func(t *testing.T) {
+			t.Parallel()
+
+			w := httptest.NewRecorder()
+			defer w.Result().Body.Close()
+			r, _ := http.NewRequestWithContext(
+				metrics.MockServiceContext,
+				http.MethodGet,
+				"url"+v.query,
+				nil)
+
+			ha.GetDuplicatePhotos(w, r)
+
+			require.Equal(t, v.sc, w.Code)
+			if v.sc != http.StatusOK {
+				return
+			}
+
+			var result []duplicateCluster
+			require.Nil(t, json.Unmarshal(w.Body.Bytes(), &result))
+
+			clusters, owners := [][]types.UUID{}, [][]types.UUID{}
+			for _, c := range result {
+				var ids, by []types.UUID
+				for _, p := range c.Photos {
+					ids = append(ids, names[p.UUID])
+				}
+				for _, o := range c.Owners {
+					by = append(by, names[o])
+				}
+				slices.Sort(ids)
+				slices.Sort(by)
+				clusters = append(clusters, ids)
+				owners = append(owners, by)
+			}
+			// clusters come in whatever order the database lists photos in
+			slices.SortFunc(clusters, func(a, b []types.UUID) int { return strings.Compare(string(a[0]), string(b[0])) })
+			slices.SortFunc(owners, func(a, b []types.UUID) int { return slices.Compare(a, b) })
+			require.Equal(t, v.clusters, clusters)
+			require.Equal(t, v.owners, owners)
+
+			// and h is remembered
+			meta := photoMeta{}
+			require.Nil(t, ha.store.Get(ctx, photoTable, string(h), &meta))
+			require.Equal(t, photoMeta{Owner: vendor.UUID, PHash: "0000000000000000"}, meta)
+		})
+	}
+}
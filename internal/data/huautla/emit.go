@@ -0,0 +1,72 @@
+package huautla
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/events"
+	"github.com/jsmit257/centerforfunguscontrol/shared/metrics"
+	"github.com/jsmit257/huautla/types"
+)
+
+// emit tells anyone who's listening about a change that was just made; the
+// change has already happened by the time we get here, so failing to tell
+// anyone is logged and otherwise ignored
+func (ha *HuautlaAdaptor) emit(ctx context.Context, ms *methodStats, typ string, id types.UUID, payload any) {
+	l := ms.l.WithField("event", typ)
+
+	e := events.Event{
+		Type:     typ,
+		EntityID: id,
+		Actor:    metrics.GetContextActor(ctx),
+		Cid:      ms.cid,
+	}
+
+	if payload == nil {
+	} else if data, err := json.Marshal(payload); err != nil {
+		l.WithError(err).Error("failed to marshal event payload")
+	} else {
+		e.Payload = data
+	}
+
+	// a batch wants to know what each of its requests did, and an atomic
+	// one holds on to it until it's committed
+	if be, ok := ctx.Value(batchEventsKey{}).(*batchEvents); ok && be.add(e) {
+		return
+	}
+
+	ha.publish(ctx, l, e)
+}
+
+func (ha *HuautlaAdaptor) publish(ctx context.Context, l *logrus.Entry, e events.Event) {
+	if ha.events == nil {
+		return
+	} else if err := ha.events.Publish(ctx, e); err != nil {
+		l.WithError(err).Error("failed to publish event")
+	}
+}
+
+type (
+	// owned is the payload for things that only exist as part of something
+	// else, like a lifecycle's events, so listeners know whose they are
+	owned struct {
+		Owner types.UUID `json:"owner_id"`
+		Value any        `json:"value,omitempty"`
+	}
+
+	retimed struct {
+		Table string `json:"table"`
+		types.Timestamp
+	}
+)
+
+// newest is whatever huautla just added to a collection, which it always puts
+// first
+func newest[T any](all []T) (result T) {
+	if len(all) > 0 {
+		result = all[0]
+	}
+	return result
+}
@@ -0,0 +1,156 @@
+package huautla
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/events"
+	"github.com/jsmit257/centerforfunguscontrol/shared/metrics"
+	"github.com/jsmit257/huautla/types"
+)
+
+type sinkMock struct {
+	mtx    sync.Mutex
+	events []events.Event
+	err    error
+}
+
+func (sm *sinkMock) Publish(_ context.Context, e events.Event) error {
+	sm.mtx.Lock()
+	defer sm.mtx.Unlock()
+	sm.events = append(sm.events, e)
+	return sm.err
+}
+
+func Test_emit(t *testing.T) {
+	t.Parallel()
+
+	type emitted struct {
+		Type     string
+		EntityID types.UUID
+		Actor    string
+		Payload  string
+	}
+
+	set := map[string]struct {
+		db      *huautlaMock
+		handler func(*HuautlaAdaptor) http.HandlerFunc
+		params  chi.RouteParams
+		body    string
+		sinkErr error
+		sc      int
+		emitted []emitted
+	}{
+		"vendor_created": {
+			db: &huautlaMock{Vendorer: &vendorerMock{
+				insertResult: types.Vendor{UUID: "0", Name: "vendor"},
+			}},
+			handler: func(ha *HuautlaAdaptor) http.HandlerFunc { return ha.PostVendor },
+			body:    `{"name":"vendor"}`,
+			sc:      http.StatusCreated,
+			emitted: []emitted{{
+				Type:     "vendor.created",
+				EntityID: "0",
+				Actor:    "tester",
+				Payload:  `{"id":"0","name":"vendor"}`,
+			}},
+		},
+		"vendor_not_created": {
+			db: &huautlaMock{Vendorer: &vendorerMock{
+				insertErr: fmt.Errorf("some error"),
+			}},
+			handler: func(ha *HuautlaAdaptor) http.HandlerFunc { return ha.PostVendor },
+			body:    `{"name":"vendor"}`,
+			sc:      http.StatusInternalServerError,
+		},
+		"vendor_deleted": {
+			db:      &huautlaMock{Vendorer: &vendorerMock{}},
+			handler: func(ha *HuautlaAdaptor) http.HandlerFunc { return ha.DeleteVendor },
+			params:  chi.RouteParams{Keys: []string{"id"}, Values: []string{"0"}},
+			sc:      http.StatusNoContent,
+			emitted: []emitted{{Type: "vendor.deleted", EntityID: "0", Actor: "tester"}},
+		},
+		"event_removed": {
+			db: &huautlaMock{
+				Lifecycler:       &lifecyclerMock{selectResult: types.Lifecycle{UUID: "0"}},
+				LifecycleEventer: &eventerMock{},
+				Photoer:          &photoerMock{},
+			},
+			handler: func(ha *HuautlaAdaptor) http.HandlerFunc { return ha.DeleteLifecycleEvent },
+			params:  chi.RouteParams{Keys: []string{"lc_id", "ev_id"}, Values: []string{"0", "1"}},
+			sc:      http.StatusOK,
+			emitted: []emitted{{
+				Type:     "event.removed",
+				EntityID: "1",
+				Actor:    "tester",
+				Payload:  `{"owner_id":"0"}`,
+			}},
+		},
+		"sink_error": {
+			db:      &huautlaMock{Vendorer: &vendorerMock{}},
+			handler: func(ha *HuautlaAdaptor) http.HandlerFunc { return ha.DeleteVendor },
+			params:  chi.RouteParams{Keys: []string{"id"}, Values: []string{"0"}},
+			sinkErr: fmt.Errorf("some error"),
+			sc:      http.StatusNoContent,
+			emitted: []emitted{{Type: "vendor.deleted", EntityID: "0", Actor: "tester"}},
+		},
+	}
+
+	for k, v := range set {
+		k, v := k, v
+		sink := &sinkMock{err: v.sinkErr}
+		ha := &HuautlaAdaptor{db: v.db, events: sink}
+		t.Run(k, func(t *testing.T) {
+			t.Parallel()
+
+			w := httptest.NewRecorder()
+			defer w.Result().Body.Close()
+			rctx := chi.NewRouteContext()
+			rctx.URLParams = v.params
+			r, _ := http.NewRequestWithContext(
+				context.WithValue(
+					context.WithValue(
+						metrics.MockServiceContext,
+						metrics.Actor,
+						"tester"),
+					chi.RouteCtxKey,
+					rctx),
+				http.MethodPost,
+				"url",
+				bytes.NewReader([]byte(v.body)))
+
+			v.handler(ha)(w, r)
+
+			require.Equal(t, v.sc, w.Code)
+			require.Len(t, sink.events, len(v.emitted))
+			for i, e := range sink.events {
+				require.Equal(t, v.emitted[i], emitted{
+					Type:     e.Type,
+					EntityID: e.EntityID,
+					Actor:    e.Actor,
+					Payload:  string(e.Payload),
+				})
+				require.Equal(t, types.CID("cid"), e.Cid)
+			}
+		})
+	}
+}
+
+func Test_newest(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, types.Note{}, newest([]types.Note(nil)))
+	require.Equal(t, types.Note{UUID: "1"}, newest([]types.Note{{UUID: "1"}, {UUID: "0"}}))
+
+	_, err := json.Marshal(owned{Owner: "0", Value: newest([]types.Photo{})})
+	require.Nil(t, err)
+}
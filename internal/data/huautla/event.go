@@ -31,6 +31,7 @@ func (ha *HuautlaAdaptor) PostLifecycleEvent(w http.ResponseWriter, r *http.Requ
 	} else if err := ha.db.AddLifecycleEvent(r.Context(), &l, e, ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to add event")
 	} else {
+		ha.emit(r.Context(), ms, "event.added", newest(l.Events).UUID, owned{l.UUID, newest(l.Events)})
 		ms.send(w, http.StatusCreated, l)
 	}
 }
@@ -55,6 +56,7 @@ func (ha *HuautlaAdaptor) PatchLifecycleEvent(w http.ResponseWriter, r *http.Req
 	} else if _, err := ha.db.ChangeLifecycleEvent(r.Context(), &l, e, ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to change event")
 	} else {
+		ha.emit(r.Context(), ms, "event.changed", e.UUID, owned{l.UUID, e})
 		ms.send(w, http.StatusOK, l)
 	}
 }
@@ -73,9 +75,13 @@ func (ha *HuautlaAdaptor) DeleteLifecycleEvent(w http.ResponseWriter, r *http.Re
 		ms.error(w, fmt.Errorf("malformed id parameter"), http.StatusBadRequest, "malformed id parameter")
 	} else if l, err := ha.db.SelectLifecycle(r.Context(), types.UUID(lcID), ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to fetch lifecycle")
+	} else if photos, err := ha.db.GetPhotos(ctx, types.UUID(evID), ms.cid); err != nil {
+		ms.error(w, err, http.StatusInternalServerError, "failed to fetch photos")
 	} else if err := ha.db.RemoveLifecycleEvent(r.Context(), &l, types.UUID(evID), ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to remove event")
 	} else {
+		ha.forgetPhotos(ctx, photos, ms)
+		ha.emit(r.Context(), ms, "event.removed", types.UUID(evID), owned{l.UUID, nil})
 		ms.send(w, http.StatusOK, l)
 	}
 }
@@ -100,6 +106,7 @@ func (ha *HuautlaAdaptor) PostGenerationEvent(w http.ResponseWriter, r *http.Req
 	} else if err := ha.db.AddGenerationEvent(r.Context(), &g, e, ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to add event")
 	} else {
+		ha.emit(r.Context(), ms, "event.added", newest(g.Events).UUID, owned{g.UUID, newest(g.Events)})
 		ms.send(w, http.StatusCreated, g)
 	}
 }
@@ -124,6 +131,7 @@ func (ha *HuautlaAdaptor) PatchGenerationEvent(w http.ResponseWriter, r *http.Re
 	} else if _, err := ha.db.ChangeGenerationEvent(r.Context(), &g, e, ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to change event")
 	} else {
+		ha.emit(r.Context(), ms, "event.changed", e.UUID, owned{g.UUID, e})
 		ms.send(w, http.StatusOK, g)
 	}
 }
@@ -142,9 +150,13 @@ func (ha *HuautlaAdaptor) DeleteGenerationEvent(w http.ResponseWriter, r *http.R
 		ms.error(w, fmt.Errorf("malformed id parameter"), http.StatusBadRequest, "malformed id parameter")
 	} else if g, err := ha.db.SelectGeneration(r.Context(), types.UUID(gID), ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to fetch lifecycle")
+	} else if photos, err := ha.db.GetPhotos(ctx, types.UUID(evID), ms.cid); err != nil {
+		ms.error(w, err, http.StatusInternalServerError, "failed to fetch photos")
 	} else if err := ha.db.RemoveGenerationEvent(r.Context(), &g, types.UUID(evID), ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to remove lifecycle")
 	} else {
+		ha.forgetPhotos(ctx, photos, ms)
+		ha.emit(r.Context(), ms, "event.removed", types.UUID(evID), owned{g.UUID, nil})
 		ms.send(w, http.StatusOK, g)
 	}
 }
@@ -4,12 +4,14 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/jsmit257/centerforfunguscontrol/internal/store"
 	"github.com/jsmit257/centerforfunguscontrol/shared/metrics"
 	"github.com/jsmit257/huautla/types"
 	"github.com/stretchr/testify/require"
@@ -200,6 +202,7 @@ func Test_DeleteLifecycleEvent(t *testing.T) {
 		id    string
 		lcErr error
 		evErr error
+		phErr error
 		sc    int
 	}{
 		"happy_path": {
@@ -219,6 +222,12 @@ func Test_DeleteLifecycleEvent(t *testing.T) {
 			lcErr: fmt.Errorf("lifecycle_error"),
 			sc:    http.StatusInternalServerError,
 		},
+		"photos_error": {
+			l:     types.Lifecycle{UUID: "photos_error"},
+			id:    "photos_error",
+			phErr: fmt.Errorf("photos_error"),
+			sc:    http.StatusInternalServerError,
+		},
 		"missing_lifecycle": {
 			sc: http.StatusBadRequest,
 		},
@@ -235,13 +244,20 @@ func Test_DeleteLifecycleEvent(t *testing.T) {
 
 	for k, v := range set {
 		k, v := k, v
+		st := store.NewMem()
+		require.Nil(t, st.Put(context.Background(), photoTable, "photo", photoMeta{Owner: types.UUID(v.id)}))
 		ha := &HuautlaAdaptor{
+			store: st,
 			db: &huautlaMock{
 				Lifecycler: &lifecyclerMock{
 					selectResult: v.l,
 					selectErr:    v.lcErr,
 				},
 				LifecycleEventer: &eventerMock{rmErr: v.evErr},
+				Photoer: &photoerMock{
+					getResult: []types.Photo{{UUID: "photo"}},
+					getErr:    v.phErr,
+				},
 			},
 		}
 		t.Run(k, func(t *testing.T) {
@@ -266,6 +282,10 @@ func Test_DeleteLifecycleEvent(t *testing.T) {
 			ha.DeleteLifecycleEvent(w, r)
 
 			require.Equal(t, v.sc, w.Code)
+
+			// the photo's metadata goes with the event
+			err := st.Get(context.Background(), photoTable, "photo", &photoMeta{})
+			require.Equal(t, v.sc == http.StatusOK, errors.Is(err, store.ErrNotFound))
 		})
 	}
 }
@@ -430,6 +450,7 @@ func Test_DeleteGenerationEvent(t *testing.T) {
 		id     string
 		genErr error
 		evErr  error
+		phErr  error
 		sc     int
 	}{
 		"happy_path": {
@@ -449,6 +470,12 @@ func Test_DeleteGenerationEvent(t *testing.T) {
 			genErr: fmt.Errorf("lifecycle_error"),
 			sc:     http.StatusInternalServerError,
 		},
+		"photos_error": {
+			g:     types.Generation{UUID: "photos_error"},
+			id:    "photos_error",
+			phErr: fmt.Errorf("photos_error"),
+			sc:    http.StatusInternalServerError,
+		},
 		"missing_lifecycle": {
 			sc: http.StatusBadRequest,
 		},
@@ -465,13 +492,20 @@ func Test_DeleteGenerationEvent(t *testing.T) {
 
 	for k, v := range set {
 		k, v := k, v
+		st := store.NewMem()
+		require.Nil(t, st.Put(context.Background(), photoTable, "photo", photoMeta{Owner: types.UUID(v.id)}))
 		ha := &HuautlaAdaptor{
+			store: st,
 			db: &huautlaMock{
 				Generationer: &generationerMock{
 					sel:    v.g,
 					selErr: v.genErr,
 				},
 				GenerationEventer: &eventerMock{rmGenerationErr: v.evErr},
+				Photoer: &photoerMock{
+					getResult: []types.Photo{{UUID: "photo"}},
+					getErr:    v.phErr,
+				},
 			},
 		}
 		t.Run(k, func(t *testing.T) {
@@ -496,6 +530,10 @@ func Test_DeleteGenerationEvent(t *testing.T) {
 			ha.DeleteGenerationEvent(w, r)
 
 			require.Equal(t, v.sc, w.Code)
+
+			// the photo's metadata goes with the event
+			err := st.Get(context.Background(), photoTable, "photo", &photoMeta{})
+			require.Equal(t, v.sc == http.StatusOK, errors.Is(err, store.ErrNotFound))
 		})
 	}
 }
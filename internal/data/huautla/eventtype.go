@@ -53,6 +53,7 @@ func (ha *HuautlaAdaptor) PostEventType(w http.ResponseWriter, r *http.Request)
 	} else if et, err = ha.db.InsertEventType(r.Context(), et, ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to insert eventtype")
 	} else {
+		ha.emit(r.Context(), ms, "eventtype.created", et.UUID, et)
 		ms.send(w, http.StatusCreated, et)
 	}
 }
@@ -75,6 +76,7 @@ func (ha *HuautlaAdaptor) PatchEventType(w http.ResponseWriter, r *http.Request)
 	} else if err = ha.db.UpdateEventType(r.Context(), types.UUID(id), et, ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to update eventtype")
 	} else {
+		ha.emit(r.Context(), ms, "eventtype.updated", types.UUID(id), et)
 		ms.send(w, http.StatusNoContent, nil)
 	}
 }
@@ -90,6 +92,7 @@ func (ha *HuautlaAdaptor) DeleteEventType(w http.ResponseWriter, r *http.Request
 	} else if err := ha.db.DeleteEventType(r.Context(), types.UUID(id), ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to delete eventtype")
 	} else {
+		ha.emit(r.Context(), ms, "eventtype.deleted", types.UUID(id), nil)
 		ms.send(w, http.StatusNoContent, nil)
 	}
 }
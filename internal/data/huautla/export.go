@@ -0,0 +1,202 @@
+package huautla
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/archive"
+	"github.com/jsmit257/centerforfunguscontrol/internal/store"
+	"github.com/jsmit257/huautla/types"
+)
+
+// exportTables are the store tables that are data; the outbox, idempotency
+// keys and webhooks are this server's own business, and codes are exported
+// from the registry
+var exportTables = []string{
+	photoTable,
+	attachmentTable,
+	colonizationTable,
+	lineageTable,
+	inoculatedTable,
+}
+
+// codesTable is where codes go in an export
+const codesTable = "codes"
+
+// GetExport sends everything as one tarball: the database, cffc's own data
+// and every file, with a manifest saying what's in it; see archive for the
+// layout
+func (ha *HuautlaAdaptor) GetExport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ms := ha.start(ctx, "GetExport")
+
+	s, files, err := ha.snapshot(ctx, ms.cid)
+	if err != nil {
+		ms.error(w, err, http.StatusInternalServerError, "failed to export")
+		return
+	}
+
+	now := time.Now().UTC()
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="cffc-export-%s.tar.gz"`, now.Format("20060102T150405Z")))
+	w.WriteHeader(http.StatusOK)
+	ms.m.WithLabelValues(strconv.Itoa(http.StatusOK)).Inc()
+
+	// it's too late for a status now; the client gets an archive that
+	// doesn't end properly, which Read refuses
+	if err = archive.Write(w, archive.Manifest{Created: now, Database: ha.database}, s, files); err != nil {
+		ms.lap().l.WithError(err).Error("failed to write export")
+		return
+	}
+	ms.lap().l.Info("finished work")
+}
+
+// export writes the same archive GetExport sends, for backups
+func (ha *HuautlaAdaptor) export(ctx context.Context, w io.Writer) error {
+	s, files, err := ha.snapshot(ctx, "backup")
+	if err != nil {
+		return err
+	}
+	return archive.Write(w, archive.Manifest{Created: time.Now().UTC(), Database: ha.database}, s, files)
+}
+
+// snapshot is everything there is to export, and the files that go with it,
+// including strains and generations that have been deleted
+func (ha *HuautlaAdaptor) snapshot(ctx context.Context, cid types.CID) (archive.Snapshot, []archive.File, error) {
+	var err error
+	var lcs []types.Lifecycle
+	s := archive.Snapshot{
+		Generated: map[types.UUID]types.UUID{},
+		Notes:     map[types.UUID][]types.Note{},
+		Photos:    map[types.UUID][]types.Photo{},
+		Store:     map[string]map[string]json.RawMessage{},
+	}
+
+	if s.Stages, err = ha.db.SelectAllStages(ctx, cid); err != nil {
+		return s, nil, fmt.Errorf("stages: %w", err)
+	} else if s.EventTypes, err = ha.db.SelectAllEventTypes(ctx, cid); err != nil {
+		return s, nil, fmt.Errorf("event types: %w", err)
+	} else if s.Ingredients, err = ha.db.SelectAllIngredients(ctx, cid); err != nil {
+		return s, nil, fmt.Errorf("ingredients: %w", err)
+	} else if s.Vendors, err = ha.db.SelectAllVendors(ctx, cid); err != nil {
+		return s, nil, fmt.Errorf("vendors: %w", err)
+	} else if s.Substrates, err = ha.db.SelectAllSubstrates(ctx, cid); err != nil {
+		return s, nil, fmt.Errorf("substrates: %w", err)
+	} else if s.Strains, err = ha.db.SelectAllStrains(ctx, cid); err != nil {
+		return s, nil, fmt.Errorf("strains: %w", err)
+	} else if lcs, err = ha.db.SelectLifecycleIndex(ctx, cid); err != nil {
+		return s, nil, fmt.Errorf("lifecycles: %w", err)
+	}
+
+	for i := range s.Substrates {
+		if err = ha.db.GetAllIngredients(ctx, &s.Substrates[i], cid); err != nil {
+			return s, nil, fmt.Errorf("substrate %s: %w", s.Substrates[i].UUID, err)
+		}
+	}
+
+	for i := range s.Strains {
+		if err = ha.db.GetAllAttributes(ctx, &s.Strains[i], cid); err != nil {
+			return s, nil, fmt.Errorf("strain %s: %w", s.Strains[i].UUID, err)
+		} else if g := s.Strains[i].Generation; g != nil {
+			s.Generated[g.UUID] = s.Strains[i].UUID
+		}
+		s.Strains[i].Generation = nil
+	}
+
+	// the indexes only have some of each row
+	if index, err := ha.db.SelectGenerationIndex(ctx, cid); err != nil {
+		return s, nil, fmt.Errorf("generations: %w", err)
+	} else {
+		for _, g := range index {
+			full, err := ha.db.SelectGeneration(ctx, g.UUID, cid)
+			if err != nil {
+				return s, nil, fmt.Errorf("generation %s: %w", g.UUID, err)
+			}
+			s.Generations = append(s.Generations, full)
+		}
+	}
+	for _, l := range lcs {
+		full, err := ha.db.SelectLifecycle(ctx, l.UUID, cid)
+		if err != nil {
+			return s, nil, fmt.Errorf("lifecycle %s: %w", l.UUID, err)
+		}
+		s.Lifecycles = append(s.Lifecycles, full)
+	}
+
+	// anything can have notes and photos, and photos can have notes too
+	owners, err := ha.photoOwners(ctx, cid)
+	if err != nil {
+		return s, nil, err
+	}
+
+	var files []archive.File
+	for _, o := range owners {
+		if photos, err := ha.db.GetPhotos(ctx, o, cid); err != nil {
+			return s, nil, fmt.Errorf("photos of %s: %w", o, err)
+		} else if len(photos) > 0 {
+			s.Photos[o] = photos
+			for _, p := range photos {
+				owners = append(owners, p.UUID)
+				files = append(files, archive.File{Dir: "album", Name: p.Filename, Path: path.Join(ha.album, p.Filename)})
+			}
+		}
+	}
+	for _, o := range owners {
+		if notes, err := ha.db.GetNotes(ctx, o, cid); err != nil {
+			return s, nil, fmt.Errorf("notes of %s: %w", o, err)
+		} else if len(notes) > 0 {
+			s.Notes[o] = notes
+		}
+	}
+
+	for _, table := range exportTables {
+		rows := map[string]json.RawMessage{}
+		keys, err := ha.store.Keys(ctx, table)
+		if err != nil {
+			return s, nil, fmt.Errorf("%s: %w", table, err)
+		}
+		for _, k := range keys {
+			var row json.RawMessage
+			if err = ha.store.Get(ctx, table, k, &row); errors.Is(err, store.ErrNotFound) {
+				continue
+			} else if err != nil {
+				return s, nil, fmt.Errorf("%s/%s: %w", table, k, err)
+			}
+			rows[k] = row
+		}
+		s.Store[table] = rows
+	}
+
+	for _, atts := range s.Store[attachmentTable] {
+		var list []attachment
+		if err = json.Unmarshal(atts, &list); err != nil {
+			return s, nil, fmt.Errorf("attachments: %w", err)
+		}
+		for _, a := range list {
+			files = append(files, archive.File{Dir: "attachments", Name: string(a.UUID), Path: path.Join(ha.attachmentDir, string(a.UUID))})
+		}
+	}
+
+	if ha.codes != nil {
+		all, err := ha.codes.All(ctx)
+		if err != nil {
+			return s, nil, fmt.Errorf("codes: %w", err)
+		}
+		rows := map[string]json.RawMessage{}
+		for code, ref := range all {
+			if rows[code], err = json.Marshal(ref); err != nil {
+				return s, nil, fmt.Errorf("codes: %w", err)
+			}
+		}
+		s.Store[codesTable] = rows
+	}
+
+	return s, files, nil
+}
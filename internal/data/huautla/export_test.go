@@ -0,0 +1,323 @@
+package huautla
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/archive"
+	"github.com/jsmit257/centerforfunguscontrol/internal/codes"
+	"github.com/jsmit257/centerforfunguscontrol/internal/data/memory"
+	"github.com/jsmit257/centerforfunguscontrol/internal/store"
+	"github.com/jsmit257/centerforfunguscontrol/shared/metrics"
+	"github.com/jsmit257/huautla/types"
+)
+
+// newExportable is an adaptor with the demo data, a photo and an attachment
+// on the first lifecycle, a photo of the first vendor, a deleted strain and
+// generation, and codes for everything
+func newExportable(t *testing.T, seed bool) *HuautlaAdaptor {
+	ctx := metrics.MockServiceContext
+	db := memory.New()
+	if seed {
+		require.Nil(t, db.Seed(ctx))
+	}
+
+	s := store.NewMem()
+	reg := codes.New(s)
+
+	ha := &HuautlaAdaptor{
+		db:            db,
+		database:      "memory",
+		filer:         os.WriteFile,
+		reader:        os.ReadFile,
+		store:         s,
+		album:         t.TempDir(),
+		attachmentDir: t.TempDir(),
+		codes:         reg,
+	}
+	if !seed {
+		return ha
+	}
+
+	lcs, err := db.SelectLifecycleIndex(ctx, "cid")
+	require.Nil(t, err)
+	lc := lcs[0].UUID
+
+	require.Nil(t, os.WriteFile(filepath.Join(ha.album, "jar.png"), []byte("png"), 0644))
+	photos, err := db.AddPhoto(ctx, lc, nil, types.Photo{Filename: "jar.png"}, "cid")
+	require.Nil(t, err)
+	_, err = db.AddNote(ctx, photos[0].UUID, nil, types.Note{Note: "nice pins"}, "cid")
+	require.Nil(t, err)
+	require.Nil(t, s.Put(ctx, photoTable, string(photos[0].UUID), photoMeta{Owner: lc, PHash: "00ff00ff00ff00ff"}))
+
+	require.Nil(t, os.WriteFile(filepath.Join(ha.attachmentDir, "att0"), []byte("invoice"), 0644))
+	require.Nil(t, s.Put(ctx, attachmentTable, string(lc), []attachment{{UUID: "att0", Filename: "invoice.pdf", Size: 7}}))
+
+	vendors, err := db.SelectAllVendors(ctx, "cid")
+	require.Nil(t, err)
+	require.Nil(t, os.WriteFile(filepath.Join(ha.album, "van.png"), []byte("png"), 0644))
+	_, err = db.AddPhoto(ctx, vendors[0].UUID, nil, types.Photo{Filename: "van.png"}, "cid")
+	require.Nil(t, err)
+
+	strains, err := db.SelectAllStrains(ctx, "cid")
+	require.Nil(t, err)
+	require.Nil(t, db.DeleteStrain(ctx, strains[len(strains)-1].UUID, "cid"))
+	gens, err := db.SelectGenerationIndex(ctx, "cid")
+	require.Nil(t, err)
+	require.Nil(t, db.DeleteGeneration(ctx, gens[0].UUID, "cid"))
+
+	require.Nil(t, ha.backfillCodes(ctx, "cid", logrus.WithField("test", t.Name())))
+	return ha
+}
+
+func Test_exportImport(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		mode   string
+		seeded bool
+		// whether to import into the database that was exported
+		self  bool
+		sc    int
+		added int
+	}{
+		"preserve": {
+			mode:  "preserve",
+			sc:    http.StatusOK,
+			added: 4,
+		},
+		"preserve_over_itself": {
+			mode: "preserve",
+			self: true,
+			sc:   http.StatusOK,
+		},
+		"remap": {
+			mode:  "remap",
+			sc:    http.StatusOK,
+			added: 4,
+		},
+		"remap_seeded": {
+			seeded: true,
+			sc:     http.StatusOK,
+			added:  4,
+		},
+		"remap_over_itself": {
+			mode:  "remap",
+			self:  true,
+			sc:    http.StatusOK,
+			added: 4,
+		},
+		"bad_mode": {
+			mode: "merge",
+			sc:   http.StatusBadRequest,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := metrics.MockServiceContext
+
+			src := newExportable(t, true)
+			w := sendWebhook(src.GetExport, http.MethodGet, "url", chi.RouteParams{}, "")
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			require.Equal(t, "application/gzip", w.Header().Get("Content-Type"))
+			export := w.Body.String()
+
+			want, _, err := src.snapshot(ctx, "cid")
+			require.Nil(t, err)
+
+			dst := src
+			if !tc.self {
+				dst = newExportable(t, tc.seeded)
+			}
+			before, _, err := dst.snapshot(ctx, "cid")
+			require.Nil(t, err)
+			sink := &sinkMock{}
+			dst.events = sink
+
+			w = sendWebhook(dst.PostImport, http.MethodPost, "url?mode="+tc.mode, chi.RouteParams{}, export)
+			require.Equal(t, tc.sc, w.Code, w.Body.String())
+			if tc.sc != http.StatusOK {
+				require.Empty(t, sink.events)
+				return
+			}
+
+			report := importReport{}
+			require.Nil(t, json.Unmarshal(w.Body.Bytes(), &report))
+			require.Equal(t, tc.added, report.Added["lifecycles"], report)
+
+			// everything restored is announced like it was added by hand,
+			// and nothing that was already there is
+			emitted := map[string]int{}
+			for _, e := range sink.events {
+				emitted[e.Type]++
+			}
+			for typ, kind := range map[string]string{
+				"stage.created":     "stages",
+				"vendor.created":    "vendors",
+				"strain.created":    "strains",
+				"lifecycle.created": "lifecycles",
+				"event.added":       "events",
+				"note.added":        "notes",
+			} {
+				require.Equal(t, report.Added[kind], emitted[typ], typ)
+			}
+			if tc.added > 0 {
+				require.Equal(t, 1, emitted["strain.deleted"], emitted)
+				require.Equal(t, 1, emitted["generation.deleted"], emitted)
+			}
+
+			got, _, err := dst.snapshot(ctx, "cid")
+			require.Nil(t, err)
+			// the report counts photos' metadata along with them, so these are
+			// counted from what's there
+			require.Equal(t, got.Counts()["photos"]-before.Counts()["photos"], emitted["photo.added"])
+
+			// files are written whether or not their rows were, and
+			// they're named the same either way
+			for _, f := range []string{filepath.Join(dst.album, "jar.png"), filepath.Join(dst.attachmentDir, "att0")} {
+				_, err := os.Stat(f)
+				require.Nil(t, err, f)
+			}
+
+			if tc.mode == "preserve" {
+				require.Equal(t, importPreserve, report.Mode)
+				if tc.self {
+					require.Empty(t, report.Added, report)
+					require.Equal(t, 4, report.Existing["lifecycles"], report)
+				}
+				require.Equal(t, want.Strains, got.Strains)
+				require.Equal(t, want.Lifecycles, got.Lifecycles)
+				require.Equal(t, want.Generations, got.Generations)
+				require.Equal(t, want.Notes, got.Notes)
+				require.Equal(t, want.Photos, got.Photos)
+				require.Equal(t, want.Store, got.Store)
+				return
+			}
+			require.Equal(t, importRemap, report.Mode)
+
+			copies := 1
+			if tc.seeded || tc.self {
+				copies = 2
+			}
+
+			// reference data is matched by name, and everything else is
+			// there twice, except the vendor's photo, since the vendor was
+			// already there
+			counts := want.Counts()
+			for _, k := range []string{"strains", "generations", "lifecycles", "events", "notes", "photos"} {
+				counts[k] *= copies
+			}
+			counts["photos"] -= copies - 1
+			require.Equal(t, counts, got.Counts())
+
+			// deleted strains and generations come back deleted
+			deleted := func(s archive.Snapshot) (n int) {
+				for _, st := range s.Strains {
+					if st.DTime != nil {
+						n++
+					}
+				}
+				for _, g := range s.Generations {
+					if g.DTime != nil {
+						n++
+					}
+				}
+				return n
+			}
+			require.Equal(t, 2, deleted(want))
+			require.Equal(t, copies*deleted(want), deleted(got))
+
+			// everything got new ids, but kept its times and its share of
+			// the store, and got a code of its own
+			byLocation := map[string]types.Lifecycle{}
+			for _, l := range got.Lifecycles {
+				byLocation[l.Location+l.Strain.Name+l.CTime.String()] = l
+			}
+			for _, l := range want.Lifecycles {
+				restored, ok := byLocation[l.Location+l.Strain.Name+l.CTime.String()]
+				require.True(t, ok, l.Location)
+				require.Equal(t, len(l.Events), len(restored.Events))
+				require.Equal(t, l.MTime, restored.MTime)
+				if copies == 1 {
+					require.NotEqual(t, l.UUID, restored.UUID)
+				}
+				_, err = dst.codes.Code(context.Background(), restored.UUID)
+				require.Nil(t, err)
+			}
+			require.Equal(t, copies*len(want.Store[photoTable]), len(got.Store[photoTable]))
+			require.Equal(t, copies*len(want.Store[attachmentTable]), len(got.Store[attachmentTable]))
+		})
+	}
+}
+
+func Test_PostImport(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		body string
+		db   types.DB
+		mode string
+		sc   int
+	}{
+		"not_an_export": {
+			body: "hello",
+			db:   memory.New(),
+			sc:   http.StatusBadRequest,
+		},
+		"cant_preserve": {
+			db:   &huautlaMock{},
+			mode: "preserve",
+			sc:   http.StatusBadRequest,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ha := &HuautlaAdaptor{db: tc.db}
+			w := sendWebhook(ha.PostImport, http.MethodPost, "url?mode="+tc.mode, chi.RouteParams{}, tc.body)
+			require.Equal(t, tc.sc, w.Code, w.Body.String())
+		})
+	}
+}
+
+func Test_snapshot(t *testing.T) {
+	t.Parallel()
+
+	ha := newExportable(t, true)
+	s, files, err := ha.snapshot(context.Background(), "cid")
+	require.Nil(t, err)
+
+	require.Equal(t, 4, len(s.Lifecycles))
+	require.NotEmpty(t, s.Generated)
+	// vendors can have photos too
+	require.Len(t, s.Photos[s.Vendors[0].UUID], 1)
+	require.NotEmpty(t, s.Store[codesTable])
+	require.Contains(t, files, archive.File{Dir: "album", Name: "jar.png", Path: filepath.Join(ha.album, "jar.png")})
+	require.Contains(t, files, archive.File{Dir: "attachments", Name: "att0", Path: filepath.Join(ha.attachmentDir, "att0")})
+
+	// and a missing file doesn't stop the export
+	require.Nil(t, os.Remove(filepath.Join(ha.album, "jar.png")))
+	w := sendWebhook(ha.GetExport, http.MethodGet, "url", chi.RouteParams{}, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Nil(t, archive.Read(w.Body, func(m archive.Manifest, _ archive.Snapshot) (archive.Sink, error) {
+		require.Equal(t, []string{"album/jar.png"}, m.Missing)
+		require.WithinDuration(t, time.Now(), m.Created, time.Minute)
+		return nil, nil
+	}))
+}
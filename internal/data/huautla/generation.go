@@ -8,6 +8,8 @@ import (
 	"net/url"
 
 	"github.com/go-chi/chi/v5"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/codes"
 	"github.com/jsmit257/huautla/types"
 )
 
@@ -18,7 +20,7 @@ func (ha *HuautlaAdaptor) GetGenerationIndex(w http.ResponseWriter, r *http.Requ
 	if g, err := ha.db.SelectGenerationIndex(r.Context(), ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to fetch generations")
 	} else {
-		ms.send(w, http.StatusOK, g)
+		ms.send(w, http.StatusOK, codedAll(ctx, ms, g, ha.codedGeneration))
 	}
 }
 
@@ -31,7 +33,7 @@ func (ha *HuautlaAdaptor) GetGeneration(w http.ResponseWriter, r *http.Request)
 	} else if g, err := ha.db.SelectGeneration(r.Context(), id, ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to fetch generation")
 	} else {
-		ms.send(w, http.StatusOK, g)
+		ms.send(w, http.StatusOK, ha.codedGeneration(ctx, ms, g))
 	}
 }
 
@@ -49,7 +51,9 @@ func (ha *HuautlaAdaptor) PostGeneration(w http.ResponseWriter, r *http.Request)
 	} else if g, err = ha.db.InsertGeneration(r.Context(), g, ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to insert generation")
 	} else {
-		ms.send(w, http.StatusCreated, g)
+		ha.assignCode(ctx, ms, codes.Generation, g.UUID)
+		ha.emit(r.Context(), ms, "generation.created", g.UUID, g)
+		ms.send(w, http.StatusCreated, ha.codedGeneration(ctx, ms, g))
 	}
 }
 
@@ -71,7 +75,8 @@ func (ha *HuautlaAdaptor) PatchGeneration(w http.ResponseWriter, r *http.Request
 	} else if g, err = ha.db.UpdateGeneration(r.Context(), g, ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to update generation")
 	} else {
-		ms.send(w, http.StatusOK, g)
+		ha.emit(r.Context(), ms, "generation.updated", g.UUID, g)
+		ms.send(w, http.StatusOK, ha.codedGeneration(ctx, ms, g))
 	}
 }
 
@@ -84,6 +89,7 @@ func (ha *HuautlaAdaptor) DeleteGeneration(w http.ResponseWriter, r *http.Reques
 	} else if err := ha.db.DeleteGeneration(r.Context(), id, ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to delete generation")
 	} else {
+		ha.emit(r.Context(), ms, "generation.deleted", id, nil)
 		ms.send(w, http.StatusNoContent, nil)
 	}
 }
@@ -96,7 +102,16 @@ func (ha *HuautlaAdaptor) GetGenerationReport(w http.ResponseWriter, r *http.Req
 		ms.error(w, err, http.StatusBadRequest, "failed to fetch uuid")
 	} else if g, err := ha.db.GenerationReport(r.Context(), id, ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to fetch generation")
+	} else if lcs, err := ha.inoculatedLifecycles(ctx, id); err != nil {
+		ms.error(w, err, http.StatusInternalServerError, "failed to fetch lifecycles")
 	} else {
-		ms.send(w, http.StatusOK, g)
+		// huautla doesn't know which lifecycles came from a generation
+		if len(lcs) > 0 {
+			if g == nil {
+				g = types.Entity{}
+			}
+			g["lifecycles"] = lcs
+		}
+		ms.send(w, http.StatusOK, ha.codedReport(ctx, ms, id, g))
 	}
 }
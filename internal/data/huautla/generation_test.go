@@ -10,6 +10,7 @@ import (
 	"testing"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/jsmit257/centerforfunguscontrol/internal/store"
 	"github.com/jsmit257/centerforfunguscontrol/shared/metrics"
 	"github.com/jsmit257/huautla/types"
 	"github.com/stretchr/testify/require"
@@ -333,15 +334,27 @@ func Test_GetGenerationReport(t *testing.T) {
 
 	set := map[string]struct {
 		id     string
+		rpt    types.Entity
+		lcs    []inoculated
 		result types.Entity
 		err    error
 		sc     int
 	}{
 		"happy_path": {
 			id:     "1",
+			rpt:    types.Entity{},
 			result: types.Entity{},
 			sc:     http.StatusOK,
 		},
+		"with_lifecycles": {
+			id:  "1",
+			lcs: []inoculated{{ID: "lc0"}, {ID: "lc1"}},
+			result: types.Entity{"lifecycles": []any{
+				map[string]any{"id": "lc0", "ctime": "0001-01-01T00:00:00Z"},
+				map[string]any{"id": "lc1", "ctime": "0001-01-01T00:00:00Z"},
+			}},
+			sc: http.StatusOK,
+		},
 		"missing_id": {
 			sc: http.StatusBadRequest,
 		},
@@ -361,10 +374,14 @@ func Test_GetGenerationReport(t *testing.T) {
 		ha := &HuautlaAdaptor{
 			db: &huautlaMock{
 				Generationer: &generationerMock{
-					rpt:    v.result,
+					rpt:    v.rpt,
 					rptErr: v.err,
 				},
 			},
+			store: store.NewMem(),
+		}
+		if v.lcs != nil {
+			require.Nil(t, ha.store.Put(context.Background(), inoculatedTable, v.id, v.lcs))
 		}
 		t.Run(k, func(t *testing.T) {
 			t.Parallel()
@@ -9,12 +9,22 @@ import (
 	"net/url"
 	"os"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 
+	"github.com/jsmit257/centerforfunguscontrol/internal/backup"
+	"github.com/jsmit257/centerforfunguscontrol/internal/codes"
+	"github.com/jsmit257/centerforfunguscontrol/internal/config"
+	"github.com/jsmit257/centerforfunguscontrol/internal/data/memory"
+	"github.com/jsmit257/centerforfunguscontrol/internal/data/sqlite"
+	"github.com/jsmit257/centerforfunguscontrol/internal/events"
+	"github.com/jsmit257/centerforfunguscontrol/internal/idempotency"
+	"github.com/jsmit257/centerforfunguscontrol/internal/store"
+	"github.com/jsmit257/centerforfunguscontrol/internal/webhooks"
 	"github.com/jsmit257/centerforfunguscontrol/shared/metrics"
 	"github.com/jsmit257/huautla"
 	"github.com/jsmit257/huautla/types"
@@ -23,8 +33,46 @@ import (
 type (
 	HuautlaAdaptor struct {
 		db types.DB
+		// which kind of database db is, for exports
+		database string
 		// log   *logrus.Entry
-		filer func(string, []byte, fs.FileMode) error
+		filer  func(string, []byte, fs.FileMode) error
+		reader func(string) ([]byte, error)
+		store  store.Store
+		album  string
+		// whether to remove gps tags from photos before writing them
+		stripGPS bool
+		// photos whose hashes differ by at most this many bits are
+		// near-duplicates, and are refused if rejectDuplicates is set
+		duplicateDistance int
+		rejectDuplicates  bool
+		// rendered timelapses, keyed by lifecycle and rendering options
+		timelapses timelapseCache
+
+		attachmentDir     string
+		attachmentMaxSize int64
+		attachmentTypes   []string
+		// serializes changes to an owner's list of attachments
+		attachmentMtx sync.Mutex
+
+		// where domain events go; the bus and the outbox are also kept
+		// separately, for anyone who wants to subscribe
+		events events.Sink
+		bus    *events.Bus
+		outbox *events.Outbox
+		// how long events are kept in the outbox, at least
+		outboxRetention time.Duration
+
+		webhooks    *webhooks.Dispatcher
+		idempotency *idempotency.Keeper
+		codes       *codes.Registry
+		// nil unless there's somewhere to keep backups
+		backups *backup.Scheduler
+
+		// the most requests a batch can have
+		batchMax int
+		// where phones that scan a label can reach the server
+		publicURL string
 	}
 
 	methodStats struct {
@@ -37,15 +85,170 @@ type (
 	ParamError error
 )
 
-func New(cfg *types.Config, log *logrus.Entry) (*HuautlaAdaptor, error) {
-	if db, err := huautla.New(cfg, log); err != nil {
+func New(cfg *config.Config, log *logrus.Entry) (*HuautlaAdaptor, error) {
+	if cfg.DuplicatePhotos != "warn" && cfg.DuplicatePhotos != "reject" {
+		return nil, fmt.Errorf("duplicate photos must be either warn or reject: %q", cfg.DuplicatePhotos)
+	} else if db, err := newDB(cfg, log); err != nil {
+		return nil, err
+	} else if s, err := store.NewFile(cfg.StoreDir); err != nil {
+		return nil, err
+	} else if outbox, err := events.NewOutbox(context.Background(), s); err != nil {
+		return nil, err
+	} else if sinks, bus, err := eventSinks(cfg); err != nil {
+		return nil, err
+	} else if secrets, err := webhooks.NewSecrets(cfg.WebhookSecretKey); err != nil {
 		return nil, err
 	} else {
-		log.Info("connected to database")
-		return &HuautlaAdaptor{
-			db:    db,
-			filer: os.WriteFile,
-		}, nil
+		log.WithFields(logrus.Fields{"database": cfg.Database, "demo": cfg.Demo}).Info("connected to database")
+		database := cfg.Database
+		if cfg.Demo {
+			database = "memory"
+		}
+		ha := &HuautlaAdaptor{
+			events: events.NewPublisher(outbox, sinks...),
+			bus:    bus,
+			outbox: outbox,
+
+			outboxRetention: cfg.OutboxRetention,
+
+			webhooks: webhooks.NewDispatcher(s, outbox, webhooks.Config{
+				MaxAttempts: cfg.WebhookAttempts,
+				Backoff:     cfg.WebhookBackoff,
+				MaxBackoff:  cfg.WebhookMaxBackoff,
+				Timeout:     cfg.WebhookTimeout,
+				Poll:        cfg.WebhookPoll,
+				Secrets:     secrets,
+				AllowLocal:  cfg.WebhookAllowLocal,
+			}, log),
+			idempotency: idempotency.New(s, cfg.IdempotencyTTL, cfg.IdempotencyMaxBody),
+			codes:       codes.New(s),
+
+			db:       db,
+			database: database,
+			filer:    os.WriteFile,
+			reader:   os.ReadFile,
+			store:    s,
+			album:    cfg.AlbumDir,
+			stripGPS: cfg.StripGPS,
+
+			timelapses: timelapseCache{max: cfg.TimelapseCacheSize},
+
+			duplicateDistance: cfg.DuplicateDistance,
+			rejectDuplicates:  cfg.DuplicatePhotos == "reject",
+
+			attachmentDir:     cfg.AttachmentDir,
+			attachmentMaxSize: cfg.AttachmentMaxSize,
+			attachmentTypes:   cfg.AttachmentTypes,
+
+			batchMax:  cfg.BatchMax,
+			publicURL: cfg.PublicURL,
+		}
+		if dest, err := backupDestination(cfg); err != nil {
+			return nil, err
+		} else if dest != nil {
+			if ha.backups, err = backup.NewScheduler(dest, ha.export, backup.Config{
+				Every: cfg.BackupEvery,
+				At:    cfg.BackupAt,
+				Keep: backup.Policy{
+					Daily:   cfg.BackupKeepDaily,
+					Weekly:  cfg.BackupKeepWeekly,
+					Monthly: cfg.BackupKeepMonthly,
+				},
+			}, log); err != nil {
+				return nil, err
+			}
+		}
+		return ha, nil
+	}
+}
+
+// backupDestination is wherever cfg says to keep backups, or nil if it
+// doesn't say
+func backupDestination(cfg *config.Config) (backup.Destination, error) {
+	if cfg.BackupDir != "" && cfg.BackupBucket != "" {
+		return nil, fmt.Errorf("backups can go in a directory or a bucket, not both")
+	} else if cfg.BackupDir != "" {
+		return backup.Dir(cfg.BackupDir), nil
+	} else if cfg.BackupBucket == "" {
+		return nil, nil
+	}
+
+	// not returned directly, so a bad bucket is a nil Destination and not
+	// a nil *Bucket
+	b, err := backup.NewBucket(cfg.BackupBucket, cfg.BackupBucketRegion, cfg.BackupBucketAccessKey, cfg.BackupBucketSecretKey)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// newDB connects to whichever database cfg asks for
+func newDB(cfg *config.Config, log *logrus.Entry) (types.DB, error) {
+	if cfg.Demo {
+		db := memory.New()
+		return db, db.Seed(context.Background())
+	}
+
+	switch cfg.Database {
+	case "huautla":
+		return huautla.New(&types.Config{
+			PGHost: cfg.HuautlaHost,
+			PGPort: cfg.HuautlaPort,
+			PGUser: cfg.HuautlaUser,
+			PGPass: cfg.HuautlaPass,
+			PGSSL:  cfg.HuautlaSSL,
+		}, log)
+	case "sqlite":
+		return sqlite.Open(context.Background(), cfg.SQLitePath, log)
+	case "memory":
+		return memory.New(), nil
+	}
+	return nil, fmt.Errorf("database must be one of huautla, sqlite or memory: %q", cfg.Database)
+}
+
+// Run does the adaptor's background work, like delivering webhooks, until
+// ctx is done
+func (ha *HuautlaAdaptor) Run(ctx context.Context) {
+	go ha.idempotency.Run(ctx, time.Hour, logrus.WithField("component", "idempotency"))
+	go ha.runOutboxPruning(ctx, time.Hour, logrus.WithField("component", "outbox"))
+	go func(log *logrus.Entry) {
+		if err := ha.backfillCodes(ctx, "backfill-codes", log); err != nil {
+			log.WithError(err).Error("failed to backfill short codes")
+		}
+	}(logrus.WithField("component", "codes"))
+	if ha.backups != nil {
+		go ha.backups.Run(ctx)
+	}
+	ha.webhooks.Run(ctx)
+}
+
+// Idempotent replays the first response to a POST for repeats with the same
+// Idempotency-Key, except for imports: an archive is too big to keep in
+// memory to compare, and importing one twice doesn't add anything twice
+// anyway
+func (ha *HuautlaAdaptor) Idempotent(next http.Handler) http.Handler {
+	if ha == nil || ha.idempotency == nil {
+		return next
+	}
+
+	idempotent := ha.idempotency.Middleware(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == importPath {
+			next.ServeHTTP(w, r)
+		} else {
+			idempotent.ServeHTTP(w, r)
+		}
+	})
+}
+
+func eventSinks(cfg *config.Config) ([]events.Sink, *events.Bus, error) {
+	bus := events.NewBus()
+	if cfg.EventsFile == "" {
+		return []events.Sink{bus}, bus, nil
+	} else if fs, err := events.NewFileSink(cfg.EventsFile); err != nil {
+		return nil, nil, err
+	} else {
+		return []events.Sink{bus, fs}, bus, nil
 	}
 }
 
@@ -119,6 +322,15 @@ func (ms *methodStats) send(w http.ResponseWriter, sc int, i interface{}) {
 	ms.lap().l.Info("finished work")
 }
 
+// write is send for anything that isn't json
+func (ms *methodStats) write(w http.ResponseWriter, sc int, contentType string, data []byte) {
+	w.Header().Add("Content-type", contentType)
+	w.WriteHeader(sc)
+	_, _ = w.Write(data)
+	ms.m.WithLabelValues(strconv.Itoa(sc)).Inc()
+	ms.lap().l.Info("finished work")
+}
+
 func (ms *methodStats) empty(w http.ResponseWriter) {
 	ms.send(w, http.StatusNoContent, nil)
 }
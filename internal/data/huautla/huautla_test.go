@@ -5,10 +5,17 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/jsmit257/huautla/types"
 	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/idempotency"
+	"github.com/jsmit257/centerforfunguscontrol/internal/store"
 )
 
 type (
@@ -45,3 +52,33 @@ func checkResult(t *testing.T, b *bytes.Buffer, rx any, expected any) {
 	require.Nil(t, json.Unmarshal(body, rx), string(body))
 	require.Equal(t, expected, rx)
 }
+
+func Test_Idempotent(t *testing.T) {
+	t.Parallel()
+
+	ha := &HuautlaAdaptor{idempotency: idempotency.New(store.NewMem(), time.Hour, 8)}
+	h := ha.Idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	tcs := map[string]struct {
+		path string
+		sc   int
+	}{
+		"too_big": {path: "/vendor", sc: http.StatusRequestEntityTooLarge},
+		"import":  {path: importPath, sc: http.StatusCreated},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader("an archive, really"))
+			r.Header.Set(idempotency.Header, "k")
+			h.ServeHTTP(w, r)
+			require.Equal(t, tc.sc, w.Code, w.Body.String())
+		})
+	}
+}
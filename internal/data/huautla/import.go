@@ -0,0 +1,706 @@
+package huautla
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/archive"
+	"github.com/jsmit257/centerforfunguscontrol/internal/codes"
+	"github.com/jsmit257/centerforfunguscontrol/internal/data/ids"
+	"github.com/jsmit257/centerforfunguscontrol/internal/store"
+	"github.com/jsmit257/huautla/types"
+)
+
+type (
+	// importer puts a snapshot back, one kind of thing at a time, so
+	// everything's there before anything that refers to it
+	importer struct {
+		ha *HuautlaAdaptor
+		ms *methodStats
+		// whether rows keep their ids; otherwise they get new ones, and
+		// whatever referred to them is changed to match
+		keep bool
+		// old id => new id, for everything restored or already there
+		ids map[types.UUID]types.UUID
+		// what was restored, as opposed to being there already; only
+		// these get their notes, photos and codes from the snapshot
+		added  map[types.UUID]bool
+		report importReport
+	}
+
+	importReport struct {
+		Mode     string         `json:"mode"`
+		Added    map[string]int `json:"added"`
+		Existing map[string]int `json:"existing,omitempty"`
+		Files    int            `json:"files"`
+		// files the export couldn't find
+		Missing []string `json:"missing,omitempty"`
+	}
+)
+
+const (
+	importRemap    = "remap"
+	importPreserve = "preserve"
+
+	importPath = "/admin/import"
+)
+
+// PostImport restores an export from GetExport into this database, whether
+// or not it's empty. With mode=preserve, everything keeps its id and
+// anything that's already here is left alone; with mode=remap, the default,
+// everything gets a new id, and stages, event types, ingredients, vendors
+// and substrates that are already here by name are used instead of being
+// added again. Everything restored is announced the same as if it had been
+// added by hand
+func (ha *HuautlaAdaptor) PostImport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ms := ha.start(ctx, "PostImport")
+
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = importRemap
+	}
+
+	if mode != importRemap && mode != importPreserve {
+		ms.error(w, fmt.Errorf("mode must be remap or preserve: %q", mode), http.StatusBadRequest, "invalid mode")
+	} else if k, ok := ha.db.(ids.Keeper); mode == importPreserve && (!ok || !k.KeepsIDs()) {
+		ms.error(w, fmt.Errorf("%T makes its own ids", ha.db), http.StatusBadRequest, "this database can't preserve ids, use mode=remap")
+	} else if report, err := ha.restore(ctx, ms, r.Body, mode == importPreserve); errors.Is(err, archive.ErrFormat) || errors.Is(err, archive.ErrVersion) {
+		ms.error(w, err, http.StatusBadRequest, "not an export this server can read")
+	} else if err != nil {
+		ms.error(w, err, http.StatusInternalServerError, "failed to import")
+	} else {
+		ms.send(w, http.StatusOK, report)
+	}
+}
+
+// restore reads an export from r and puts it all back; there's no
+// transaction around it, so a failure part of the way through leaves
+// whatever was restored before it
+func (ha *HuautlaAdaptor) restore(ctx context.Context, ms *methodStats, r io.Reader, keep bool) (importReport, error) {
+	im := &importer{
+		ha:    ha,
+		ms:    ms,
+		keep:  keep,
+		ids:   map[types.UUID]types.UUID{},
+		added: map[types.UUID]bool{},
+		report: importReport{
+			Mode:     importRemap,
+			Added:    map[string]int{},
+			Existing: map[string]int{},
+		},
+	}
+	if keep {
+		ctx = ids.Keep(ctx)
+		im.report.Mode = importPreserve
+	}
+
+	err := archive.Read(r, func(m archive.Manifest, s archive.Snapshot) (archive.Sink, error) {
+		im.report.Missing = m.Missing
+		return im.file, im.restore(ctx, s)
+	})
+	if err != nil {
+		return im.report, err
+	}
+
+	// remapped things don't bring their codes with them, so they get new
+	// ones, in the order they were made
+	return im.report, ha.backfillCodes(ctx, ms.cid, ms.l)
+}
+
+func (im *importer) restore(ctx context.Context, s archive.Snapshot) error {
+	for _, step := range []func(context.Context, archive.Snapshot) error{
+		im.stages,
+		im.eventTypes,
+		im.ingredients,
+		im.vendors,
+		im.substrates,
+		im.strains,
+		im.generations,
+		im.lifecycles,
+		// sources can come from lifecycles' events, and photos can have
+		// notes, so these wait
+		im.sources,
+		im.generated,
+		im.photos,
+		im.notes,
+		im.store,
+		im.codes,
+	} {
+		if err := step(ctx, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// id is what old is called now; anything the snapshot didn't have, like a
+// stage every database starts with, is called what it always was
+func (im *importer) id(old types.UUID) types.UUID {
+	if id, ok := im.ids[old]; ok {
+		return id
+	}
+	return old
+}
+
+// emit tells webhooks and streams about something that was restored
+func (im *importer) emit(ctx context.Context, typ string, id types.UUID, payload any) {
+	im.ha.emit(ctx, im.ms, typ, id, payload)
+}
+
+func (im *importer) restored(kind string, old, id types.UUID, added bool) {
+	im.ids[old] = id
+	if added {
+		im.added[id] = true
+		im.report.Added[kind]++
+	} else {
+		im.report.Existing[kind]++
+	}
+}
+
+// key is what makes two rows the same: their ids when ids are kept, and
+// otherwise what a person would call them
+func (im *importer) key(id types.UUID, name ...string) string {
+	if im.keep {
+		return string(id)
+	}
+	return strings.Join(name, "\x00")
+}
+
+// retime puts back when something was made and last changed, since adding
+// it made it brand new
+func (im *importer) retime(ctx context.Context, table string, id types.UUID, ctime, mtime time.Time) error {
+	for _, stamp := range []struct {
+		field string
+		t     time.Time
+	}{{"ctime", ctime}, {"mtime", mtime}} {
+		if stamp.t.IsZero() {
+			continue
+		} else if err := im.ha.db.UpdateTimestamps(ctx, table, id, types.Timestamp{
+			Fields: []string{stamp.field},
+			Origin: &stamp.t,
+		}); err != nil {
+			return fmt.Errorf("%s of %s %s: %w", stamp.field, table, id, err)
+		}
+	}
+	return nil
+}
+
+// deleted puts back when something was deleted, for strains and
+// generations, which are only ever marked deleted; it's nothing if it
+// wasn't
+func (im *importer) deleted(ctx context.Context, table string, id types.UUID, dtime *time.Time) error {
+	if dtime == nil {
+		return nil
+	} else if err := im.ha.db.UpdateTimestamps(ctx, table, id, types.Timestamp{
+		Fields: []string{"dtime"},
+		Origin: dtime,
+	}); err != nil {
+		return fmt.Errorf("dtime of %s %s: %w", table, id, err)
+	}
+	return nil
+}
+
+func (im *importer) stages(ctx context.Context, s archive.Snapshot) error {
+	existing, err := im.ha.db.SelectAllStages(ctx, im.ms.cid)
+	if err != nil {
+		return fmt.Errorf("stages: %w", err)
+	}
+	known := map[string]types.UUID{}
+	for _, st := range existing {
+		known[im.key(st.UUID, st.Name)] = st.UUID
+	}
+
+	for _, st := range s.Stages {
+		k := im.key(st.UUID, st.Name)
+		if id, ok := known[k]; ok {
+			im.restored("stages", st.UUID, id, false)
+		} else if added, err := im.ha.db.InsertStage(ctx, st, im.ms.cid); err != nil {
+			return fmt.Errorf("stage %s: %w", st.UUID, err)
+		} else {
+			known[k] = added.UUID
+			im.restored("stages", st.UUID, added.UUID, true)
+			im.emit(ctx, "stage.created", added.UUID, added)
+		}
+	}
+	return nil
+}
+
+func (im *importer) eventTypes(ctx context.Context, s archive.Snapshot) error {
+	existing, err := im.ha.db.SelectAllEventTypes(ctx, im.ms.cid)
+	if err != nil {
+		return fmt.Errorf("event types: %w", err)
+	}
+	known := map[string]types.UUID{}
+	for _, et := range existing {
+		known[im.key(et.UUID, et.Name, string(et.Stage.UUID))] = et.UUID
+	}
+
+	for _, et := range s.EventTypes {
+		old := et.UUID
+		et.Stage = types.Stage{UUID: im.id(et.Stage.UUID)}
+		k := im.key(et.UUID, et.Name, string(et.Stage.UUID))
+		if id, ok := known[k]; ok {
+			im.restored("eventtypes", old, id, false)
+		} else if added, err := im.ha.db.InsertEventType(ctx, et, im.ms.cid); err != nil {
+			return fmt.Errorf("event type %s: %w", old, err)
+		} else {
+			known[k] = added.UUID
+			im.restored("eventtypes", old, added.UUID, true)
+			im.emit(ctx, "eventtype.created", added.UUID, added)
+		}
+	}
+	return nil
+}
+
+func (im *importer) ingredients(ctx context.Context, s archive.Snapshot) error {
+	existing, err := im.ha.db.SelectAllIngredients(ctx, im.ms.cid)
+	if err != nil {
+		return fmt.Errorf("ingredients: %w", err)
+	}
+	known := map[string]types.UUID{}
+	for _, i := range existing {
+		known[im.key(i.UUID, i.Name)] = i.UUID
+	}
+
+	for _, i := range s.Ingredients {
+		k := im.key(i.UUID, i.Name)
+		if id, ok := known[k]; ok {
+			im.restored("ingredients", i.UUID, id, false)
+		} else if added, err := im.ha.db.InsertIngredient(ctx, i, im.ms.cid); err != nil {
+			return fmt.Errorf("ingredient %s: %w", i.UUID, err)
+		} else {
+			known[k] = added.UUID
+			im.restored("ingredients", i.UUID, added.UUID, true)
+			im.emit(ctx, "ingredient.created", added.UUID, added)
+		}
+	}
+	return nil
+}
+
+func (im *importer) vendors(ctx context.Context, s archive.Snapshot) error {
+	existing, err := im.ha.db.SelectAllVendors(ctx, im.ms.cid)
+	if err != nil {
+		return fmt.Errorf("vendors: %w", err)
+	}
+	known := map[string]types.UUID{}
+	for _, v := range existing {
+		known[im.key(v.UUID, v.Name)] = v.UUID
+	}
+
+	for _, v := range s.Vendors {
+		k := im.key(v.UUID, v.Name)
+		if id, ok := known[k]; ok {
+			im.restored("vendors", v.UUID, id, false)
+		} else if added, err := im.ha.db.InsertVendor(ctx, v, im.ms.cid); err != nil {
+			return fmt.Errorf("vendor %s: %w", v.UUID, err)
+		} else {
+			known[k] = added.UUID
+			im.restored("vendors", v.UUID, added.UUID, true)
+			im.emit(ctx, "vendor.created", added.UUID, added)
+		}
+	}
+	return nil
+}
+
+func (im *importer) substrates(ctx context.Context, s archive.Snapshot) error {
+	existing, err := im.ha.db.SelectAllSubstrates(ctx, im.ms.cid)
+	if err != nil {
+		return fmt.Errorf("substrates: %w", err)
+	}
+	known := map[string]types.UUID{}
+	for _, su := range existing {
+		known[im.key(su.UUID, su.Name, string(su.Type), string(su.Vendor.UUID))] = su.UUID
+	}
+
+	for _, su := range s.Substrates {
+		old, ingredients := su.UUID, su.Ingredients
+		su.Vendor = types.Vendor{UUID: im.id(su.Vendor.UUID)}
+		su.Ingredients = nil
+
+		k := im.key(su.UUID, su.Name, string(su.Type), string(su.Vendor.UUID))
+		if id, ok := known[k]; ok {
+			im.restored("substrates", old, id, false)
+			continue
+		}
+
+		added, err := im.ha.db.InsertSubstrate(ctx, su, im.ms.cid)
+		if err != nil {
+			return fmt.Errorf("substrate %s: %w", old, err)
+		}
+		known[k] = added.UUID
+		im.restored("substrates", old, added.UUID, true)
+
+		for _, i := range ingredients {
+			if err = im.ha.db.AddIngredient(ctx, &added, types.Ingredient{UUID: im.id(i.UUID)}, im.ms.cid); err != nil {
+				return fmt.Errorf("ingredient %s of substrate %s: %w", i.UUID, old, err)
+			}
+		}
+		im.emit(ctx, "substrate.created", added.UUID, added)
+	}
+	return nil
+}
+
+func (im *importer) strains(ctx context.Context, s archive.Snapshot) error {
+	existing, err := im.ha.db.SelectAllStrains(ctx, im.ms.cid)
+	if err != nil {
+		return fmt.Errorf("strains: %w", err)
+	}
+	known := map[types.UUID]bool{}
+	for _, st := range existing {
+		known[st.UUID] = im.keep
+	}
+
+	for _, st := range s.Strains {
+		old, attrs, dtime := st.UUID, st.Attributes, st.DTime
+		if known[old] {
+			im.restored("strains", old, old, false)
+			continue
+		}
+
+		st.Vendor = types.Vendor{UUID: im.id(st.Vendor.UUID)}
+		st.Attributes, st.Generation, st.DTime = nil, nil, nil
+		added, err := im.ha.db.InsertStrain(ctx, st, im.ms.cid)
+		if err != nil {
+			return fmt.Errorf("strain %s: %w", old, err)
+		}
+		im.restored("strains", old, added.UUID, true)
+
+		for _, a := range attrs {
+			if _, err = im.ha.db.AddAttribute(ctx, &added, a, im.ms.cid); err != nil {
+				return fmt.Errorf("attribute %s of strain %s: %w", a.Name, old, err)
+			}
+		}
+		if err = im.retime(ctx, "strains", added.UUID, st.CTime, time.Time{}); err != nil {
+			return err
+		}
+		im.emit(ctx, "strain.created", added.UUID, added)
+
+		if err = im.deleted(ctx, "strains", added.UUID, dtime); err != nil {
+			return err
+		} else if dtime != nil {
+			im.emit(ctx, "strain.deleted", added.UUID, nil)
+		}
+	}
+	return nil
+}
+
+// events adds owner's events oldest first, since they're kept newest first
+// and the newest one added goes at the front
+func (im *importer) events(ctx context.Context, owner types.UUID, events []types.Event, add func(types.Event) ([]types.Event, error)) error {
+	for i := len(events) - 1; i >= 0; i-- {
+		e := events[i]
+		e.EventType = types.EventType{UUID: im.id(e.EventType.UUID)}
+		e.Notes, e.Photos = nil, nil
+
+		after, err := add(e)
+		if err != nil {
+			return fmt.Errorf("event %s: %w", events[i].UUID, err)
+		} else if len(after) == 0 {
+			return fmt.Errorf("event %s wasn't added", events[i].UUID)
+		}
+		im.restored("events", events[i].UUID, after[0].UUID, true)
+
+		if err = im.retime(ctx, "events", after[0].UUID, e.CTime, e.MTime); err != nil {
+			return err
+		}
+		im.emit(ctx, "event.added", after[0].UUID, owned{owner, after[0]})
+	}
+	return nil
+}
+
+func (im *importer) generations(ctx context.Context, s archive.Snapshot) error {
+	existing, err := im.ha.db.SelectGenerationIndex(ctx, im.ms.cid)
+	if err != nil {
+		return fmt.Errorf("generations: %w", err)
+	}
+	known := map[types.UUID]bool{}
+	for _, g := range existing {
+		known[g.UUID] = im.keep
+	}
+
+	for _, g := range s.Generations {
+		old, events, dtime := g.UUID, g.Events, g.DTime
+		if known[old] {
+			im.restored("generations", old, old, false)
+			continue
+		}
+
+		g.PlatingSubstrate = types.Substrate{UUID: im.id(g.PlatingSubstrate.UUID)}
+		g.LiquidSubstrate = types.Substrate{UUID: im.id(g.LiquidSubstrate.UUID)}
+		g.Sources, g.Events, g.DTime = nil, nil, nil
+		added, err := im.ha.db.InsertGeneration(ctx, g, im.ms.cid)
+		if err != nil {
+			return fmt.Errorf("generation %s: %w", old, err)
+		}
+		im.restored("generations", old, added.UUID, true)
+
+		im.emit(ctx, "generation.created", added.UUID, added)
+
+		if err = im.events(ctx, added.UUID, events, func(e types.Event) ([]types.Event, error) {
+			err := im.ha.db.AddGenerationEvent(ctx, &added, e, im.ms.cid)
+			return added.Events, err
+		}); err != nil {
+			return fmt.Errorf("generation %s: %w", old, err)
+		} else if err = im.retime(ctx, "generations", added.UUID, g.CTime, g.MTime); err != nil {
+			return err
+		} else if err = im.deleted(ctx, "generations", added.UUID, dtime); err != nil {
+			return err
+		} else if dtime != nil {
+			im.emit(ctx, "generation.deleted", added.UUID, nil)
+		}
+	}
+	return nil
+}
+
+func (im *importer) lifecycles(ctx context.Context, s archive.Snapshot) error {
+	existing, err := im.ha.db.SelectLifecycleIndex(ctx, im.ms.cid)
+	if err != nil {
+		return fmt.Errorf("lifecycles: %w", err)
+	}
+	known := map[types.UUID]bool{}
+	for _, l := range existing {
+		known[l.UUID] = im.keep
+	}
+
+	for _, l := range s.Lifecycles {
+		old, events := l.UUID, l.Events
+		if known[old] {
+			im.restored("lifecycles", old, old, false)
+			continue
+		}
+
+		l.Strain = types.Strain{UUID: im.id(l.Strain.UUID)}
+		l.GrainSubstrate = types.Substrate{UUID: im.id(l.GrainSubstrate.UUID)}
+		l.BulkSubstrate = types.Substrate{UUID: im.id(l.BulkSubstrate.UUID)}
+		l.Events = nil
+		added, err := im.ha.db.InsertLifecycle(ctx, l, im.ms.cid)
+		if err != nil {
+			return fmt.Errorf("lifecycle %s: %w", old, err)
+		}
+		im.restored("lifecycles", old, added.UUID, true)
+		im.emit(ctx, "lifecycle.created", added.UUID, added)
+
+		if err = im.events(ctx, added.UUID, events, func(e types.Event) ([]types.Event, error) {
+			err := im.ha.db.AddLifecycleEvent(ctx, &added, e, im.ms.cid)
+			return added.Events, err
+		}); err != nil {
+			return fmt.Errorf("lifecycle %s: %w", old, err)
+		} else if err = im.retime(ctx, "lifecycles", added.UUID, l.CTime, l.MTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sources are only restored for generations that were, since the rest
+// already have theirs
+func (im *importer) sources(ctx context.Context, s archive.Snapshot) error {
+	for _, g := range s.Generations {
+		gID := im.id(g.UUID)
+		if !im.added[gID] {
+			continue
+		}
+
+		for _, src := range g.Sources {
+			origin, restored := "strain", types.Source{
+				UUID:   src.UUID,
+				Type:   src.Type,
+				Strain: types.Strain{UUID: im.id(src.Strain.UUID)},
+			}
+			if l := src.Lifecycle; l != nil && len(l.Events) > 0 {
+				origin = "event"
+				restored.Lifecycle = &types.Lifecycle{
+					UUID:   im.id(l.UUID),
+					Events: []types.Event{{UUID: im.id(l.Events[0].UUID)}},
+				}
+			}
+
+			if added, err := im.ha.db.InsertSource(ctx, gID, origin, restored, im.ms.cid); err != nil {
+				return fmt.Errorf("source %s of generation %s: %w", src.UUID, g.UUID, err)
+			} else {
+				im.restored("sources", src.UUID, added.UUID, true)
+				im.emit(ctx, "source.added", added.UUID, owned{gID, added})
+			}
+		}
+	}
+	return nil
+}
+
+func (im *importer) generated(ctx context.Context, s archive.Snapshot) error {
+	for g, st := range s.Generated {
+		gID, sID := im.id(g), im.id(st)
+		if !im.added[sID] {
+			continue
+		} else if err := im.ha.db.UpdateGeneratedStrain(ctx, &gID, sID, im.ms.cid); err != nil {
+			return fmt.Errorf("strain %s generated by %s: %w", st, g, err)
+		}
+		im.emit(ctx, "generatedstrain.changed", sID, owned{gID, nil})
+	}
+	return nil
+}
+
+// photos and notes are only restored for owners that were; the files come
+// afterwards, with the same names they had
+func (im *importer) photos(ctx context.Context, s archive.Snapshot) error {
+	for owner, photos := range s.Photos {
+		oID := im.id(owner)
+		if !im.added[oID] {
+			im.report.Existing["photos"] += len(photos)
+			continue
+		}
+
+		var current []types.Photo
+		for i := len(photos) - 1; i >= 0; i-- {
+			p := types.Photo{UUID: photos[i].UUID, Filename: photos[i].Filename}
+			var err error
+			if current, err = im.ha.db.AddPhoto(ctx, oID, current, p, im.ms.cid); err != nil {
+				return fmt.Errorf("photo %s: %w", photos[i].UUID, err)
+			} else if len(current) == 0 {
+				return fmt.Errorf("photo %s wasn't added", photos[i].UUID)
+			}
+			im.restored("photos", photos[i].UUID, current[0].UUID, true)
+
+			if err = im.retime(ctx, "photos", current[0].UUID, photos[i].CTime, photos[i].MTime); err != nil {
+				return err
+			}
+			im.emit(ctx, "photo.added", current[0].UUID, owned{oID, current[0]})
+		}
+	}
+	return nil
+}
+
+func (im *importer) notes(ctx context.Context, s archive.Snapshot) error {
+	for owner, notes := range s.Notes {
+		oID := im.id(owner)
+		if !im.added[oID] {
+			im.report.Existing["notes"] += len(notes)
+			continue
+		}
+
+		var current []types.Note
+		for i := len(notes) - 1; i >= 0; i-- {
+			n := types.Note{UUID: notes[i].UUID, Note: notes[i].Note}
+			var err error
+			if current, err = im.ha.db.AddNote(ctx, oID, current, n, im.ms.cid); err != nil {
+				return fmt.Errorf("note %s: %w", notes[i].UUID, err)
+			} else if len(current) == 0 {
+				return fmt.Errorf("note %s wasn't added", notes[i].UUID)
+			}
+			im.restored("notes", notes[i].UUID, current[0].UUID, true)
+
+			if err = im.retime(ctx, "notes", current[0].UUID, notes[i].CTime, notes[i].MTime); err != nil {
+				return err
+			}
+			im.emit(ctx, "note.added", current[0].UUID, owned{oID, current[0]})
+		}
+	}
+	return nil
+}
+
+// store puts back cffc's own data, with every id in it remapped; rows that
+// are already here are left alone
+func (im *importer) store(ctx context.Context, s archive.Snapshot) error {
+	for _, table := range exportTables {
+		for key, row := range s.Store[table] {
+			k := string(im.id(types.UUID(key)))
+
+			var there json.RawMessage
+			if err := im.ha.store.Get(ctx, table, k, &there); err == nil {
+				im.report.Existing[table]++
+				continue
+			} else if !errors.Is(err, store.ErrNotFound) {
+				return fmt.Errorf("%s/%s: %w", table, k, err)
+			}
+
+			row, err := im.remap(row)
+			if err != nil {
+				return fmt.Errorf("%s/%s: %w", table, key, err)
+			} else if err = im.ha.store.Put(ctx, table, k, row); err != nil {
+				return fmt.Errorf("%s/%s: %w", table, k, err)
+			}
+			im.report.Added[table]++
+		}
+	}
+	return nil
+}
+
+// remap is row with every id in it, however deep, changed to its new one
+func (im *importer) remap(row json.RawMessage) (json.RawMessage, error) {
+	if im.keep {
+		return row, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(row))
+	dec.UseNumber()
+
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return json.Marshal(im.remapValue(doc))
+}
+
+func (im *importer) remapValue(v any) any {
+	switch v := v.(type) {
+	case string:
+		return string(im.id(types.UUID(v)))
+	case map[string]any:
+		for k, child := range v {
+			v[k] = im.remapValue(child)
+		}
+	case []any:
+		for i, child := range v {
+			v[i] = im.remapValue(child)
+		}
+	}
+	return v
+}
+
+// codes keeps the codes that were on labels, as long as their ids were kept
+// and nothing here has them already; anything that doesn't get its old
+// code is backfilled a new one after
+func (im *importer) codes(ctx context.Context, s archive.Snapshot) error {
+	if !im.keep || im.ha.codes == nil {
+		return nil
+	}
+
+	for code, row := range s.Store[codesTable] {
+		var ref codes.Ref
+		if err := json.Unmarshal(row, &ref); err != nil {
+			return fmt.Errorf("code %s: %w", code, err)
+		} else if !im.added[ref.ID] {
+			continue
+		} else if adopted, err := im.ha.codes.Adopt(ctx, code, ref); err != nil {
+			return fmt.Errorf("code %s: %w", code, err)
+		} else if adopted {
+			im.report.Added[codesTable]++
+		}
+	}
+	return nil
+}
+
+// file is where each file from the export goes
+func (im *importer) file(dir, name string, r io.Reader) error {
+	where := im.ha.album
+	if dir == "attachments" {
+		where = im.ha.attachmentDir
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	} else if err = im.ha.filer(path.Join(where, name), data, 0644); err != nil {
+		return err
+	}
+	im.report.Files++
+	return nil
+}
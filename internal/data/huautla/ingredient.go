@@ -51,6 +51,7 @@ func (ha *HuautlaAdaptor) PostIngredient(w http.ResponseWriter, r *http.Request)
 	} else if i, err = ha.db.InsertIngredient(r.Context(), i, ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to insert ingredient")
 	} else {
+		ha.emit(r.Context(), ms, "ingredient.created", i.UUID, i)
 		ms.send(w, http.StatusCreated, i)
 	}
 }
@@ -73,6 +74,7 @@ func (ha *HuautlaAdaptor) PatchIngredient(w http.ResponseWriter, r *http.Request
 	} else if err = ha.db.UpdateIngredient(r.Context(), types.UUID(id), i, ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to update ingredient")
 	} else {
+		ha.emit(r.Context(), ms, "ingredient.updated", types.UUID(id), i)
 		ms.send(w, http.StatusNoContent, nil)
 	}
 }
@@ -88,6 +90,7 @@ func (ha *HuautlaAdaptor) DeleteIngredient(w http.ResponseWriter, r *http.Reques
 	} else if err := ha.db.DeleteIngredient(r.Context(), types.UUID(id), ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to delete ingredient")
 	} else {
+		ha.emit(r.Context(), ms, "ingredient.deleted", types.UUID(id), nil)
 		ms.send(w, http.StatusNoContent, nil)
 	}
 }
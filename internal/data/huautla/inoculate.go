@@ -0,0 +1,190 @@
+package huautla
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/codes"
+	"github.com/jsmit257/centerforfunguscontrol/internal/store"
+	"github.com/jsmit257/huautla/types"
+)
+
+type (
+	// inoculation is count lifecycles that are the same except for where
+	// they are; costs are for all of them together
+	inoculation struct {
+		Count int `json:"count"`
+		// the first one's number, 1 if nobody says
+		Start     int             `json:"start,omitempty"`
+		Lifecycle types.Lifecycle `json:"lifecycle"`
+	}
+
+	// inoculated is a lifecycle that came from a generation
+	inoculated struct {
+		ID    types.UUID `json:"id"`
+		CTime time.Time  `json:"ctime"`
+	}
+
+	inoculationResult struct {
+		Generation types.UUID       `json:"generation"`
+		Lifecycles []codedLifecycle `json:"lifecycles"`
+	}
+)
+
+const (
+	inoculatedTable = "generation_lifecycles"
+
+	maxInoculation = 100
+)
+
+// PostGenerationLifecycles makes a batch of lifecycles from a generation,
+// like a set of jars inoculated from the same liquid culture; they all get
+// the same strain, substrates and location, with a number on the end, and
+// their share of the costs. The strain is the one the generation made,
+// unless it says otherwise
+func (ha *HuautlaAdaptor) PostGenerationLifecycles(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ms := ha.start(ctx, "PostGenerationLifecycles")
+	defer r.Body.Close()
+
+	var in inoculation
+
+	if id, err := getUUIDByName("id", w, r, ms); err != nil {
+		ms.error(w, err, http.StatusBadRequest, "failed to fetch uuid")
+	} else if body, err := io.ReadAll(r.Body); err != nil {
+		ms.error(w, err, http.StatusBadRequest, "couldn't read request body")
+	} else if err := json.Unmarshal(body, &in); err != nil {
+		ms.error(w, err, http.StatusBadRequest, "couldn't unmarshal request body")
+	} else if err := in.validate(); err != nil {
+		ms.error(w, err, http.StatusBadRequest, err.Error())
+	} else if g, err := ha.db.SelectGeneration(ctx, id, ms.cid); errors.Is(err, sql.ErrNoRows) {
+		ms.error(w, err, http.StatusBadRequest, "failed to fetch generation")
+	} else if err != nil {
+		ms.error(w, err, http.StatusInternalServerError, "failed to fetch generation")
+	} else if strain, err := ha.inoculationStrain(ctx, g.UUID, in.Lifecycle.Strain, ms); err != nil {
+		ms.error(w, err, http.StatusInternalServerError, "failed to fetch strains")
+	} else if strain.UUID == "" {
+		ms.error(w, fmt.Errorf("generation %s didn't make a strain", g.UUID), http.StatusBadRequest, "the generation didn't make a strain, so the lifecycle needs one")
+	} else if created, err := ha.insertLifecycles(ctx, in.lifecycles(strain), ms); err != nil {
+		ms.error(w, err, http.StatusInternalServerError, "failed to insert lifecycle")
+	} else if err := ha.linkInoculated(ctx, g.UUID, created); err != nil {
+		ha.deleteLifecycles(ctx, created, ms)
+		ms.error(w, err, http.StatusInternalServerError, "failed to link lifecycles")
+	} else {
+		for _, l := range created {
+			ha.assignCode(ctx, ms, codes.Lifecycle, l.UUID)
+			ha.emit(ctx, ms, "lifecycle.created", l.UUID, l)
+		}
+		ha.emit(ctx, ms, "generation.inoculated", g.UUID, lifecycleIDs(created))
+		ms.send(w, http.StatusCreated, inoculationResult{Generation: g.UUID, Lifecycles: codedAll(ctx, ms, created, ha.codedLifecycle)})
+	}
+}
+
+func (in inoculation) validate() error {
+	if in.Count < 1 {
+		return fmt.Errorf("count has to be at least 1")
+	} else if in.Count > maxInoculation {
+		return fmt.Errorf("count can't be more than %d", maxInoculation)
+	} else if in.Start < 0 {
+		return fmt.Errorf("start can't be negative")
+	}
+	return nil
+}
+
+// lifecycles are the ones to insert; numbers are padded so they sort, like
+// shelf 2 08, shelf 2 09, shelf 2 10
+func (in inoculation) lifecycles(strain types.Strain) []types.Lifecycle {
+	start := in.Start
+	if start == 0 {
+		start = 1
+	}
+	width := len(strconv.Itoa(start + in.Count - 1))
+
+	shares := make([]float64, in.Count)
+	for i := range shares {
+		shares[i] = 1 / float64(in.Count)
+	}
+	strainCosts := apportion(in.Lifecycle.StrainCost, shares)
+	grainCosts := apportion(in.Lifecycle.GrainCost, shares)
+	bulkCosts := apportion(in.Lifecycle.BulkCost, shares)
+
+	result := make([]types.Lifecycle, in.Count)
+	for i := range result {
+		result[i] = in.Lifecycle
+		result[i].UUID = ""
+		result[i].Events = nil
+		result[i].Strain = strain
+		result[i].Location = strings.TrimSpace(fmt.Sprintf("%s %0*d", strings.TrimSpace(in.Lifecycle.Location), width, start+i))
+		result[i].StrainCost = strainCosts[i]
+		result[i].GrainCost = grainCosts[i]
+		result[i].BulkCost = bulkCosts[i]
+	}
+	return result
+}
+
+// inoculationStrain is the strain the lifecycles are, which is the one the
+// generation made unless they say otherwise; it's empty if there isn't one
+func (ha *HuautlaAdaptor) inoculationStrain(ctx context.Context, genID types.UUID, s types.Strain, ms *methodStats) (types.Strain, error) {
+	if s.UUID != "" {
+		return s, nil
+	}
+
+	all, err := ha.db.SelectAllStrains(ctx, ms.cid)
+	if err != nil {
+		return s, err
+	}
+	for _, strain := range all {
+		if strain.Generation != nil && strain.Generation.UUID == genID {
+			return strain, nil
+		}
+	}
+	return s, nil
+}
+
+// linkInoculated records that lifecycles came from a generation, on both
+// ends; huautla's sources only go from a strain or a lifecycle to a
+// generation, not the other way. Both are kept under the lineage's lock,
+// same as splits and merges
+func (ha *HuautlaAdaptor) linkInoculated(ctx context.Context, genID types.UUID, lifecycles []types.Lifecycle) error {
+	unlock, err := ha.store.Lock(ctx, lineageTable)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	all, err := ha.inoculatedLifecycles(ctx, genID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	for _, l := range lifecycles {
+		links, err := ha.lifecycleLinks(ctx, l.UUID)
+		if err != nil {
+			return err
+		}
+		links.Generation = genID
+		if err := ha.store.Put(ctx, lineageTable, string(l.UUID), links); err != nil {
+			return err
+		}
+		all = append(all, inoculated{ID: l.UUID, CTime: now})
+	}
+
+	return ha.store.Put(ctx, inoculatedTable, string(genID), all)
+}
+
+func (ha *HuautlaAdaptor) inoculatedLifecycles(ctx context.Context, genID types.UUID) ([]inoculated, error) {
+	var result []inoculated
+	if err := ha.store.Get(ctx, inoculatedTable, string(genID), &result); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+	return result, nil
+}
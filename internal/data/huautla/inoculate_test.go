@@ -0,0 +1,204 @@
+package huautla
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/store"
+	"github.com/jsmit257/huautla/types"
+)
+
+func Test_PostGenerationLifecycles(t *testing.T) {
+	t.Parallel()
+
+	strains := []types.Strain{
+		{UUID: "other", Generation: &types.Generation{UUID: "g1"}},
+		{UUID: "made"},
+		{UUID: "generated", Generation: &types.Generation{UUID: "g0"}},
+	}
+
+	type lc struct {
+		location   string
+		strainCost float32
+		grainCost  float32
+		bulkCost   float32
+	}
+
+	tcs := map[string]struct {
+		id         string
+		body       string
+		selErr     error
+		strains    []types.Strain
+		strainsErr error
+		sc         int
+		strain     types.UUID
+		lcs        []lc
+		after      []string
+	}{
+		"generated_strain": {
+			id:      "g0",
+			body:    `{"count":3,"lifecycle":{"location":" shelf 2 ","grain_substrate":{"id":"rye"},"bulk_substrate":{"id":"cvg"},"strain_cost":1,"grain_cost":10,"bulk_cost":4.5}}`,
+			strains: strains,
+			sc:      http.StatusCreated,
+			strain:  "generated",
+			lcs: []lc{
+				{location: "shelf 2 1", strainCost: 0.33, grainCost: 3.33, bulkCost: 1.5},
+				{location: "shelf 2 2", strainCost: 0.33, grainCost: 3.33, bulkCost: 1.5},
+				{location: "shelf 2 3", strainCost: 0.34, grainCost: 3.34, bulkCost: 1.5},
+			},
+			after: []string{"jar0", "jar1", "jar2", "new0", "new1", "new2"},
+		},
+		"given_strain_and_start": {
+			id:      "g9",
+			body:    `{"count":2,"start":9,"lifecycle":{"location":"rack","strain":{"id":"made"}}}`,
+			strains: strains,
+			sc:      http.StatusCreated,
+			strain:  "made",
+			lcs: []lc{
+				{location: "rack 09"},
+				{location: "rack 10"},
+			},
+			after: []string{"jar0", "jar1", "jar2", "new0", "new1"},
+		},
+		"no_location": {
+			id:      "g0",
+			body:    `{"count":1,"lifecycle":{}}`,
+			strains: strains,
+			sc:      http.StatusCreated,
+			strain:  "generated",
+			lcs:     []lc{{location: "1"}},
+			after:   []string{"jar0", "jar1", "jar2", "new0"},
+		},
+		"no_strain": {
+			id:      "g9",
+			body:    `{"count":2,"lifecycle":{"location":"rack"}}`,
+			strains: strains,
+			sc:      http.StatusBadRequest,
+			after:   []string{"jar0", "jar1", "jar2"},
+		},
+		"insert_fails": {
+			id:      "g0",
+			body:    `{"count":2,"lifecycle":{"location":"fail"}}`,
+			strains: strains,
+			sc:      http.StatusInternalServerError,
+			after:   []string{"jar0", "jar1", "jar2"},
+		},
+		"strains_error": {
+			id:         "g0",
+			body:       `{"count":2,"lifecycle":{"location":"rack"}}`,
+			strainsErr: fmt.Errorf("some error"),
+			sc:         http.StatusInternalServerError,
+			after:      []string{"jar0", "jar1", "jar2"},
+		},
+		"missing_generation": {
+			id:     "g0",
+			body:   `{"count":2,"lifecycle":{"location":"rack"}}`,
+			selErr: sql.ErrNoRows,
+			sc:     http.StatusBadRequest,
+			after:  []string{"jar0", "jar1", "jar2"},
+		},
+		"generation_error": {
+			id:     "g0",
+			body:   `{"count":2,"lifecycle":{"location":"rack"}}`,
+			selErr: fmt.Errorf("some error"),
+			sc:     http.StatusInternalServerError,
+			after:  []string{"jar0", "jar1", "jar2"},
+		},
+		"no_count": {
+			id:    "g0",
+			body:  `{"lifecycle":{"location":"rack"}}`,
+			sc:    http.StatusBadRequest,
+			after: []string{"jar0", "jar1", "jar2"},
+		},
+		"too_many": {
+			id:    "g0",
+			body:  `{"count":101}`,
+			sc:    http.StatusBadRequest,
+			after: []string{"jar0", "jar1", "jar2"},
+		},
+		"unmarshal_error": {
+			id:    "g0",
+			body:  `{"count":`,
+			sc:    http.StatusBadRequest,
+			after: []string{"jar0", "jar1", "jar2"},
+		},
+		"missing_id": {
+			sc:    http.StatusBadRequest,
+			after: []string{"jar0", "jar1", "jar2"},
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ha, ll := newLineageAdaptor()
+			ha.db.(*huautlaMock).Generationer = &generationerMock{
+				sel:    types.Generation{UUID: types.UUID(tc.id)},
+				selErr: tc.selErr,
+			}
+			ha.db.(*huautlaMock).Strainer = &strainerMock{
+				selectAllResult: tc.strains,
+				selectAllErr:    tc.strainsErr,
+			}
+
+			w := sendWebhook(ha.PostGenerationLifecycles, http.MethodPost, "url", idParam(tc.id), tc.body)
+			require.Equal(t, tc.sc, w.Code, w.Body.String())
+			require.Equal(t, tc.after, ll.ids())
+			if tc.sc != http.StatusCreated {
+				return
+			}
+
+			var result inoculationResult
+			require.Nil(t, json.Unmarshal(w.Body.Bytes(), &result))
+			require.Equal(t, types.UUID(tc.id), result.Generation)
+			require.Len(t, result.Lifecycles, len(tc.lcs))
+
+			lcs, err := ha.inoculatedLifecycles(context.Background(), types.UUID(tc.id))
+			require.Nil(t, err)
+			require.Len(t, lcs, len(tc.lcs))
+
+			for i, want := range tc.lcs {
+				got := result.Lifecycles[i]
+				require.Equal(t, want.location, got.Location)
+				require.Equal(t, want.strainCost, got.StrainCost)
+				require.Equal(t, want.grainCost, got.GrainCost)
+				require.Equal(t, want.bulkCost, got.BulkCost)
+				require.Equal(t, tc.strain, got.Strain.UUID)
+				require.Equal(t, got.UUID, lcs[i].ID)
+
+				links, err := ha.lifecycleLinks(context.Background(), got.UUID)
+				require.Nil(t, err)
+				require.Equal(t, types.UUID(tc.id), links.Generation)
+			}
+		})
+	}
+}
+
+func Test_linkInoculated(t *testing.T) {
+	t.Parallel()
+
+	ha := &HuautlaAdaptor{store: store.NewMem()}
+	ctx := context.Background()
+
+	// lineage that's already there is kept, and so are earlier inoculations
+	require.Nil(t, ha.store.Put(ctx, lineageTable, "lc1", lifecycleLinks{Children: []lifecycleLink{{ID: "lc2", Kind: linkSplit, Share: 1}}}))
+	require.Nil(t, ha.linkInoculated(ctx, "g0", []types.Lifecycle{{UUID: "lc0"}}))
+	require.Nil(t, ha.linkInoculated(ctx, "g0", []types.Lifecycle{{UUID: "lc1"}}))
+
+	lcs, err := ha.inoculatedLifecycles(ctx, "g0")
+	require.Nil(t, err)
+	require.Equal(t, []types.UUID{"lc0", "lc1"}, []types.UUID{lcs[0].ID, lcs[1].ID})
+
+	links, err := ha.lifecycleLinks(ctx, "lc1")
+	require.Nil(t, err)
+	require.Equal(t, types.UUID("g0"), links.Generation)
+	require.Len(t, links.Children, 1)
+}
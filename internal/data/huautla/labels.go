@@ -0,0 +1,309 @@
+package huautla
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"image/png"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/codes"
+	"github.com/jsmit257/centerforfunguscontrol/internal/labels"
+	"github.com/jsmit257/centerforfunguscontrol/internal/qr"
+	"github.com/jsmit257/centerforfunguscontrol/internal/store"
+	"github.com/jsmit257/huautla/types"
+)
+
+type labelOptions struct {
+	ids    []string
+	format string
+	layout labels.Layout
+	skip   int
+	// png only draws one page at a time
+	page int
+	dpi  int
+}
+
+// maxLabels is the most labels one request can print, ten full pages of the
+// smallest ones
+const maxLabels = 300
+
+var labelTypes = map[string]string{
+	"pdf": "application/pdf",
+	"png": "image/png",
+}
+
+// GetLabels prints a label for every lifecycle, generation or strain in ids,
+// each with a qr code that scans back to it
+func (ha *HuautlaAdaptor) GetLabels(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ms := ha.start(ctx, "GetLabels")
+
+	opts, err := newLabelOptions(r.URL.Query())
+	if err != nil {
+		ms.error(w, err, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	base := ha.publicBase(r)
+	sheet := labels.Sheet{Layout: opts.layout, Skip: opts.skip}
+	for _, id := range opts.ids {
+		if ref, err := ha.locate(ctx, id, ms); errors.Is(err, sql.ErrNoRows) {
+			ms.error(w, err, http.StatusNotFound, fmt.Sprintf("nothing is %q", id))
+			return
+		} else if err != nil {
+			ms.error(w, err, http.StatusInternalServerError, "failed to look up "+id)
+			return
+		} else if l, err := ha.label(ctx, ref, base, ms); err != nil {
+			ms.error(w, err, http.StatusInternalServerError, "failed to make a label for "+id)
+			return
+		} else {
+			sheet.Labels = append(sheet.Labels, l)
+		}
+	}
+
+	b := &bytes.Buffer{}
+	if opts.format == "png" {
+		if opts.page > sheet.Pages() {
+			ms.error(w, fmt.Errorf("page %d of %d", opts.page, sheet.Pages()), http.StatusBadRequest, "no such page")
+			return
+		} else if img, err := sheet.PNG(opts.page, opts.dpi); err != nil {
+			ms.error(w, err, http.StatusInternalServerError, "failed to draw labels")
+			return
+		} else if err := png.Encode(b, img); err != nil {
+			ms.error(w, err, http.StatusInternalServerError, "failed to encode labels")
+			return
+		}
+	} else if err := sheet.PDF(b); err != nil {
+		ms.error(w, err, http.StatusInternalServerError, "failed to draw labels")
+		return
+	}
+
+	w.Header().Set("X-Label-Pages", strconv.Itoa(sheet.Pages()))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", "labels."+opts.format))
+	ms.write(w, http.StatusOK, labelTypes[opts.format], b.Bytes())
+}
+
+// GetScan is where a label's qr code goes; it sends the scanner on to
+// whatever the label is for
+func (ha *HuautlaAdaptor) GetScan(w http.ResponseWriter, r *http.Request) {
+	ms := ha.start(r.Context(), "GetScan")
+
+	if payload := chi.URLParam(r, "payload"); payload == "" {
+		ms.error(w, fmt.Errorf("missing required parameter: payload"), http.StatusBadRequest, "missing required parameter")
+	} else if payload, err := url.PathUnescape(payload); err != nil {
+		ms.error(w, fmt.Errorf("malformed parameter: payload"), http.StatusBadRequest, "malformed parameter")
+	} else if ref, err := ha.locate(r.Context(), payload, ms); errors.Is(err, sql.ErrNoRows) {
+		ms.error(w, err, http.StatusNotFound, "nothing has that label")
+	} else if err != nil {
+		ms.error(w, err, http.StatusInternalServerError, "failed to look up label")
+	} else {
+		ms.found(w, ref)
+	}
+}
+
+func newLabelOptions(q url.Values) (labelOptions, error) {
+	result := labelOptions{
+		format: "pdf",
+		layout: labels.Layouts[labels.DefaultLayout],
+		page:   1,
+		dpi:    300,
+	}
+
+	// ids can be repeated, comma separated or both
+	for _, v := range q["ids"] {
+		for _, id := range strings.Split(v, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				result.ids = append(result.ids, id)
+			}
+		}
+	}
+	if len(result.ids) == 0 {
+		return result, fmt.Errorf("missing required parameter: ids")
+	} else if len(result.ids) > maxLabels {
+		return result, fmt.Errorf("can print at most %d labels at a time, not %d", maxLabels, len(result.ids))
+	}
+
+	if f := q.Get("format"); f == "" {
+	} else if _, ok := labelTypes[f]; !ok {
+		return result, fmt.Errorf("unsupported format: %q", f)
+	} else {
+		result.format = f
+	}
+
+	if name := q.Get("layout"); name == "" {
+	} else if l, ok := labels.Layouts[name]; !ok {
+		return result, fmt.Errorf("unsupported layout: %q, try one of %s", name, strings.Join(labels.LayoutNames(), ", "))
+	} else {
+		result.layout = l
+	}
+
+	for name, n := range map[string]struct {
+		dst      *int
+		min, max int
+	}{
+		"skip": {&result.skip, 0, result.layout.PerPage() - 1},
+		"page": {&result.page, 1, maxLabels},
+		"dpi":  {&result.dpi, 72, 600},
+	} {
+		if v := q.Get(name); v == "" {
+		} else if i, err := strconv.Atoi(v); err != nil || i < n.min || i > n.max {
+			return result, fmt.Errorf("%s must be between %d and %d: %q", name, n.min, n.max, v)
+		} else {
+			*n.dst = i
+		}
+	}
+
+	return result, nil
+}
+
+// publicBase is where the server can be reached from a phone, for the urls
+// in qr codes; it's PublicURL if there is one, otherwise it's wherever this
+// request came in
+func (ha *HuautlaAdaptor) publicBase(r *http.Request) string {
+	if ha.publicURL != "" {
+		return strings.TrimRight(ha.publicURL, "/")
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	} else if p := r.Header.Get("X-Forwarded-Proto"); p == "http" || p == "https" {
+		scheme = p
+	}
+	return scheme + "://" + r.Host
+}
+
+// locate works out what s is, a short code or the id of a lifecycle,
+// generation or strain; a whole url is fine too, and only the last part of
+// its path counts. It's sql.ErrNoRows if it's none of those
+func (ha *HuautlaAdaptor) locate(ctx context.Context, s string, ms *methodStats) (codes.Ref, error) {
+	if u, err := url.Parse(strings.TrimSpace(s)); err == nil && strings.Contains(u.Path, "/") {
+		s = path.Base(u.Path)
+	}
+	if s = strings.TrimSpace(s); s == "" || s == "/" || s == "." {
+		return codes.Ref{}, fmt.Errorf("nothing to look up: %w", sql.ErrNoRows)
+	}
+
+	if ha.codes == nil {
+	} else if ref, err := ha.codes.Resolve(ctx, s); err == nil {
+		return ref, nil
+	} else if !errors.Is(err, store.ErrNotFound) {
+		return codes.Ref{}, err
+	} else if code, err := ha.codes.Code(ctx, types.UUID(s)); err == nil {
+		if ref, err := ha.codes.Resolve(ctx, code); err == nil {
+			return ref, nil
+		}
+	} else if !errors.Is(err, store.ErrNotFound) {
+		return codes.Ref{}, err
+	}
+
+	// it doesn't have a code yet, so ask everything that might have it
+	id := types.UUID(s)
+	if _, err := ha.db.SelectLifecycle(ctx, id, ms.cid); err == nil {
+		return codes.Ref{Kind: codes.Lifecycle, ID: id}, nil
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return codes.Ref{}, err
+	} else if _, err := ha.db.SelectGeneration(ctx, id, ms.cid); err == nil {
+		return codes.Ref{Kind: codes.Generation, ID: id}, nil
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return codes.Ref{}, err
+	} else if _, err := ha.db.SelectStrain(ctx, id, ms.cid); err == nil {
+		return codes.Ref{Kind: codes.Strain, ID: id}, nil
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return codes.Ref{}, err
+	}
+
+	return codes.Ref{}, fmt.Errorf("nothing is %q: %w", s, sql.ErrNoRows)
+}
+
+// label is ref's code, or its id if it doesn't have one, in big letters
+// with a few lines about it underneath; the qr code scans to base/scan/code
+func (ha *HuautlaAdaptor) label(ctx context.Context, ref codes.Ref, base string, ms *methodStats) (labels.Label, error) {
+	result := labels.Label{Title: string(ref.ID)}
+	if code := ha.code(ctx, ms, ref.ID); code != "" {
+		result.Title = code
+	}
+
+	switch ref.Kind {
+	case codes.Lifecycle:
+		l, err := ha.db.SelectLifecycle(ctx, ref.ID, ms.cid)
+		if err != nil {
+			return result, err
+		}
+		result.Lines = nonEmpty(
+			l.Strain.Name,
+			l.Location,
+			joinNonEmpty(" / ", l.GrainSubstrate.Name, l.BulkSubstrate.Name),
+			dated("inoculated", l.CTime))
+	case codes.Generation:
+		g, err := ha.db.SelectGeneration(ctx, ref.ID, ms.cid)
+		if err != nil {
+			return result, err
+		}
+		strains := []string{}
+		for _, s := range g.Sources {
+			strains = append(strains, s.Strain.Name)
+		}
+		result.Lines = nonEmpty(
+			joinNonEmpty(" x ", strains...),
+			joinNonEmpty(" / ", g.PlatingSubstrate.Name, g.LiquidSubstrate.Name),
+			dated("started", g.CTime))
+	case codes.Strain:
+		s, err := ha.db.SelectStrain(ctx, ref.ID, ms.cid)
+		if err != nil {
+			return result, err
+		}
+		result.Lines = nonEmpty(s.Name, s.Species, s.Vendor.Name, dated("added", s.CTime))
+	default:
+		return result, fmt.Errorf("can't label a %q", ref.Kind)
+	}
+
+	code, err := qr.Encode([]byte(base+"/scan/"+url.PathEscape(result.Title)), qr.Medium)
+	if err != nil {
+		return result, err
+	}
+	result.QR = code
+
+	return result, nil
+}
+
+func nonEmpty(lines ...string) []string {
+	result := make([]string, 0, len(lines))
+	for _, l := range lines {
+		if l != "" {
+			result = append(result, l)
+		}
+	}
+	return result
+}
+
+// joinNonEmpty is like strings.Join, but leaves out empty strings and
+// repeats
+func joinNonEmpty(sep string, all ...string) string {
+	result := []string{}
+	seen := map[string]bool{}
+	for _, s := range all {
+		if s != "" && !seen[s] {
+			seen[s] = true
+			result = append(result, s)
+		}
+	}
+	return strings.Join(result, sep)
+}
+
+func dated(what string, when time.Time) string {
+	if when.IsZero() {
+		return ""
+	}
+	return what + " " + when.Format(time.DateOnly)
+}
@@ -0,0 +1,362 @@
+package huautla
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/codes"
+	"github.com/jsmit257/centerforfunguscontrol/internal/labels"
+	"github.com/jsmit257/centerforfunguscontrol/internal/qr"
+	"github.com/jsmit257/centerforfunguscontrol/shared/metrics"
+	"github.com/jsmit257/huautla/types"
+)
+
+// labelDB has a few of everything that can have a label, and nothing else;
+// err is what every lookup fails with, if it's set
+type labelDB struct {
+	types.Lifecycler
+	types.Generationer
+	types.Strainer
+
+	err error
+}
+
+var labelled = struct {
+	lc  types.Lifecycle
+	gen types.Generation
+	str types.Strain
+}{
+	lc: types.Lifecycle{
+		UUID:           "lc0",
+		Location:       "shelf 2",
+		Strain:         types.Strain{Name: "Golden Teacher"},
+		GrainSubstrate: types.Substrate{Name: "rye"},
+		BulkSubstrate:  types.Substrate{Name: "cvg"},
+		CTime:          time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+	},
+	gen: types.Generation{
+		UUID:             "gen0",
+		PlatingSubstrate: types.Substrate{Name: "agar"},
+		Sources: []types.Source{
+			{Strain: types.Strain{Name: "B+"}},
+			{Strain: types.Strain{Name: "Golden Teacher"}},
+			{Strain: types.Strain{Name: "B+"}},
+		},
+		CTime: time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+	},
+	str: types.Strain{
+		UUID:    "str0",
+		Name:    "Golden Teacher",
+		Species: "P. cubensis",
+		Vendor:  types.Vendor{Name: "spore co"},
+	},
+}
+
+func (db *labelDB) SelectLifecycle(_ context.Context, id types.UUID, _ types.CID) (types.Lifecycle, error) {
+	if db.err != nil {
+		return types.Lifecycle{}, db.err
+	} else if id != labelled.lc.UUID {
+		return types.Lifecycle{}, sql.ErrNoRows
+	}
+	return labelled.lc, nil
+}
+
+func (db *labelDB) SelectGeneration(_ context.Context, id types.UUID, _ types.CID) (types.Generation, error) {
+	if db.err != nil {
+		return types.Generation{}, db.err
+	} else if id != labelled.gen.UUID {
+		return types.Generation{}, sql.ErrNoRows
+	}
+	return labelled.gen, nil
+}
+
+func (db *labelDB) SelectStrain(_ context.Context, id types.UUID, _ types.CID) (types.Strain, error) {
+	if db.err != nil {
+		return types.Strain{}, db.err
+	} else if id != labelled.str.UUID {
+		return types.Strain{}, sql.ErrNoRows
+	}
+	return labelled.str, nil
+}
+
+func newLabelAdaptor(t *testing.T, err error) *HuautlaAdaptor {
+	db := &labelDB{err: err}
+	return &HuautlaAdaptor{
+		db: &huautlaMock{
+			Lifecycler:   db,
+			Generationer: db,
+			Strainer:     db,
+		},
+		// the strain doesn't have a code yet
+		codes: newCodes(t,
+			codes.Ref{Kind: codes.Lifecycle, ID: labelled.lc.UUID},
+			codes.Ref{Kind: codes.Generation, ID: labelled.gen.UUID}),
+	}
+}
+
+func Test_GetLabels(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		query string
+		err   error
+		sc    int
+		ct    string
+		pages string
+	}{
+		"pdf": {
+			query: "ids=LC-2026-0001,gen0&ids=str0",
+			sc:    http.StatusOK,
+			ct:    "application/pdf",
+			pages: "1",
+		},
+		"png": {
+			query: "ids=LC-2026-0001&format=png&layout=5164&dpi=72",
+			sc:    http.StatusOK,
+			ct:    "image/png",
+			pages: "1",
+		},
+		"skip_to_the_next_page": {
+			query: "ids=lc0,gen0&layout=5164&skip=5",
+			sc:    http.StatusOK,
+			ct:    "application/pdf",
+			pages: "2",
+		},
+		"second_png_page": {
+			query: "ids=lc0,gen0&layout=5164&skip=5&format=png&page=2&dpi=72",
+			sc:    http.StatusOK,
+			ct:    "image/png",
+			pages: "2",
+		},
+		"no_such_page": {
+			query: "ids=lc0&format=png&page=2",
+			sc:    http.StatusBadRequest,
+		},
+		"missing_ids": {
+			query: "ids=,",
+			sc:    http.StatusBadRequest,
+		},
+		"too_many": {
+			query: "ids=" + strings.Repeat("lc0,", maxLabels+1),
+			sc:    http.StatusBadRequest,
+		},
+		"bad_format": {
+			query: "ids=lc0&format=gif",
+			sc:    http.StatusBadRequest,
+		},
+		"bad_layout": {
+			query: "ids=lc0&layout=8160",
+			sc:    http.StatusBadRequest,
+		},
+		"bad_skip": {
+			query: "ids=lc0&layout=5164&skip=6",
+			sc:    http.StatusBadRequest,
+		},
+		"bad_dpi": {
+			query: "ids=lc0&format=png&dpi=9000",
+			sc:    http.StatusBadRequest,
+		},
+		"unknown": {
+			query: "ids=lc0,lc1",
+			sc:    http.StatusNotFound,
+		},
+		"db_error": {
+			query: "ids=lc0",
+			err:   fmt.Errorf("some error"),
+			sc:    http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ha := newLabelAdaptor(t, tc.err)
+			w := sendWebhook(ha.GetLabels, http.MethodGet, "http://cffc.local/labels?"+tc.query, chi.RouteParams{}, "")
+			require.Equal(t, tc.sc, w.Code, w.Body.String())
+			if tc.sc != http.StatusOK {
+				return
+			}
+			require.Equal(t, tc.ct, w.Header().Get("Content-Type"))
+			require.Equal(t, tc.pages, w.Header().Get("X-Label-Pages"))
+			require.NotEmpty(t, w.Body.Bytes())
+		})
+	}
+}
+
+func Test_GetScan(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		payload  string
+		err      error
+		sc       int
+		location string
+	}{
+		"code": {
+			payload:  "LC-2026-0001",
+			sc:       http.StatusFound,
+			location: "/lifecycle/lc0",
+		},
+		"lowercase_code": {
+			payload:  "gen-0001",
+			sc:       http.StatusFound,
+			location: "/generation/gen0",
+		},
+		"id_with_a_code": {
+			payload:  "gen0",
+			sc:       http.StatusFound,
+			location: "/generation/gen0",
+		},
+		"id_without_a_code": {
+			payload:  "str0",
+			sc:       http.StatusFound,
+			location: "/strain/str0",
+		},
+		"whole_url": {
+			payload:  url.PathEscape("https://cffc.local/scan/LC-2026-0001"),
+			sc:       http.StatusFound,
+			location: "/lifecycle/lc0",
+		},
+		"unknown": {
+			payload: "LC-2026-0002",
+			sc:      http.StatusNotFound,
+		},
+		"missing": {
+			sc: http.StatusBadRequest,
+		},
+		"malformed": {
+			payload: "%zzz",
+			sc:      http.StatusBadRequest,
+		},
+		"db_error": {
+			payload: "str0",
+			err:     fmt.Errorf("some error"),
+			sc:      http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ha := newLabelAdaptor(t, tc.err)
+			w := sendWebhook(ha.GetScan, http.MethodGet, "url", chi.RouteParams{Keys: []string{"payload"}, Values: []string{tc.payload}}, "")
+			require.Equal(t, tc.sc, w.Code, w.Body.String())
+			require.Equal(t, tc.location, w.Header().Get("Location"))
+		})
+	}
+}
+
+func Test_label(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		ref    codes.Ref
+		result labels.Label
+		scan   string
+		err    bool
+	}{
+		"lifecycle": {
+			ref: codes.Ref{Kind: codes.Lifecycle, ID: "lc0"},
+			result: labels.Label{
+				Title: "LC-2026-0001",
+				Lines: []string{"Golden Teacher", "shelf 2", "rye / cvg", "inoculated 2026-03-01"},
+			},
+			scan: "https://cffc.local/scan/LC-2026-0001",
+		},
+		"generation": {
+			ref: codes.Ref{Kind: codes.Generation, ID: "gen0"},
+			result: labels.Label{
+				Title: "GEN-0001",
+				Lines: []string{"B+ x Golden Teacher", "agar", "started 2026-02-01"},
+			},
+			scan: "https://cffc.local/scan/GEN-0001",
+		},
+		"strain": {
+			ref: codes.Ref{Kind: codes.Strain, ID: "str0"},
+			result: labels.Label{
+				Title: "str0",
+				Lines: []string{"Golden Teacher", "P. cubensis", "spore co"},
+			},
+			scan: "https://cffc.local/scan/str0",
+		},
+		"something_else": {
+			ref: codes.Ref{Kind: "vendor", ID: "v0"},
+			err: true,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ha := newLabelAdaptor(t, nil)
+			l, err := ha.label(
+				metrics.MockServiceContext,
+				tc.ref,
+				"https://cffc.local",
+				ha.start(metrics.MockServiceContext, "Test_label"))
+			if tc.err {
+				require.NotNil(t, err)
+				return
+			}
+			require.Nil(t, err)
+
+			// same data, same code
+			tc.result.QR, err = qr.Encode([]byte(tc.scan), qr.Medium)
+			require.Nil(t, err)
+			require.Equal(t, tc.result, l)
+		})
+	}
+}
+
+func Test_publicBase(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		publicURL string
+		forwarded string
+		result    string
+	}{
+		"configured": {
+			publicURL: "https://cffc.example.com/",
+			result:    "https://cffc.example.com",
+		},
+		"request": {
+			result: "http://cffc.local:8080",
+		},
+		"behind_a_proxy": {
+			forwarded: "https",
+			result:    "https://cffc.local:8080",
+		},
+		"nonsense_proxy": {
+			forwarded: "javascript",
+			result:    "http://cffc.local:8080",
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := http.NewRequest(http.MethodGet, "http://cffc.local:8080/labels", nil)
+			require.Nil(t, err)
+			r.Header.Set("X-Forwarded-Proto", tc.forwarded)
+			require.Equal(t, tc.result, (&HuautlaAdaptor{publicURL: tc.publicURL}).publicBase(r))
+		})
+	}
+}
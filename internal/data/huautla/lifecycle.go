@@ -7,6 +7,7 @@ import (
 	"io"
 	"net/http"
 
+	"github.com/jsmit257/centerforfunguscontrol/internal/codes"
 	"github.com/jsmit257/huautla/types"
 )
 
@@ -17,7 +18,7 @@ func (ha *HuautlaAdaptor) GetLifecycleIndex(w http.ResponseWriter, r *http.Reque
 	if lifecycles, err := ha.db.SelectLifecycleIndex(r.Context(), ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to fetch lifecycles")
 	} else {
-		ms.send(w, http.StatusOK, lifecycles)
+		ms.send(w, http.StatusOK, codedAll(ctx, ms, lifecycles, ha.codedLifecycle))
 	}
 }
 
@@ -32,7 +33,7 @@ func (ha *HuautlaAdaptor) GetLifecycle(w http.ResponseWriter, r *http.Request) {
 	} else if err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to fetch lifecycle")
 	} else {
-		ms.send(w, http.StatusOK, l)
+		ms.send(w, http.StatusOK, ha.codedLifecycle(ctx, ms, l))
 	}
 }
 
@@ -50,7 +51,9 @@ func (ha *HuautlaAdaptor) PostLifecycle(w http.ResponseWriter, r *http.Request)
 	} else if l, err = ha.db.InsertLifecycle(r.Context(), l, ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to insert lifecycle")
 	} else {
-		ms.send(w, http.StatusCreated, l)
+		ha.assignCode(ctx, ms, codes.Lifecycle, l.UUID)
+		ha.emit(r.Context(), ms, "lifecycle.created", l.UUID, l)
+		ms.send(w, http.StatusCreated, ha.codedLifecycle(ctx, ms, l))
 	}
 }
 
@@ -68,7 +71,8 @@ func (ha *HuautlaAdaptor) PatchLifecycle(w http.ResponseWriter, r *http.Request)
 	} else if l, err = ha.db.UpdateLifecycle(r.Context(), l, ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, err.Error())
 	} else {
-		ms.send(w, http.StatusOK, l)
+		ha.emit(r.Context(), ms, "lifecycle.updated", l.UUID, l)
+		ms.send(w, http.StatusOK, ha.codedLifecycle(ctx, ms, l))
 	}
 }
 
@@ -76,11 +80,21 @@ func (ha *HuautlaAdaptor) DeleteLifecycle(w http.ResponseWriter, r *http.Request
 	ctx := r.Context()
 	ms := ha.start(ctx, "DeleteLifecycle")
 
+	// the lifecycle's photos, and its events', are deleted with it
+	var photos []types.Photo
+
 	if id, err := getUUIDByName("id", w, r, ms); err != nil {
 		ms.error(w, err, http.StatusBadRequest, "failed to fetch uuid")
+	} else if l, err := ha.db.SelectLifecycle(ctx, id, ms.cid); err != nil {
+		ms.error(w, err, http.StatusInternalServerError, "failed to fetch lifecycle")
+	} else if photos, err = ha.lifecyclePhotos(ctx, l, ms); err != nil {
+		ms.error(w, err, http.StatusInternalServerError, "failed to fetch photos")
 	} else if err = ha.db.DeleteLifecycle(r.Context(), id, ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to delete lifecycle")
 	} else {
+		ha.forgetPhotos(ctx, photos, ms)
+		ha.forgetLifecycle(ctx, id, ms)
+		ha.emit(r.Context(), ms, "lifecycle.deleted", id, nil)
 		ms.send(w, http.StatusNoContent, nil)
 	}
 }
@@ -95,7 +109,19 @@ func (ha *HuautlaAdaptor) GetLifecycleReport(w http.ResponseWriter, r *http.Requ
 		ms.error(w, err, http.StatusBadRequest, "failed to fetch lifecycle")
 	} else if err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to fetch lifecycle")
+	} else if links, err := ha.lifecycleLinks(ctx, id); err != nil {
+		ms.error(w, err, http.StatusInternalServerError, "failed to fetch lineage")
 	} else {
-		ms.send(w, http.StatusOK, l)
+		// splits, merges and inoculations aren't something huautla knows about
+		if len(links.Parents) > 0 || len(links.Children) > 0 || links.Generation != "" {
+			if l == nil {
+				l = types.Entity{}
+			}
+			l["parents"], l["children"] = links.Parents, links.Children
+			if links.Generation != "" {
+				l["generation"] = links.Generation
+			}
+		}
+		ms.send(w, http.StatusOK, ha.codedReport(ctx, ms, id, l))
 	}
 }
@@ -5,12 +5,16 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/jsmit257/centerforfunguscontrol/internal/codes"
+	"github.com/jsmit257/centerforfunguscontrol/internal/store"
 	"github.com/jsmit257/centerforfunguscontrol/shared/metrics"
 	"github.com/jsmit257/huautla/types"
 	"github.com/stretchr/testify/require"
@@ -347,9 +351,11 @@ func Test_DeleteLifecycle(t *testing.T) {
 	t.Parallel()
 
 	set := map[string]struct {
-		id  string
-		err error
-		sc  int
+		id     string
+		selErr error
+		getErr error
+		err    error
+		sc     int
 	}{
 		"happy_path": {
 			id: "1",
@@ -362,6 +368,16 @@ func Test_DeleteLifecycle(t *testing.T) {
 			id: "%zzz",
 			sc: http.StatusBadRequest,
 		},
+		"select_error": {
+			id:     "1",
+			selErr: fmt.Errorf("db error"),
+			sc:     http.StatusInternalServerError,
+		},
+		"photos_error": {
+			id:     "1",
+			getErr: fmt.Errorf("db error"),
+			sc:     http.StatusInternalServerError,
+		},
 		"db_error": {
 			id:  "1",
 			err: fmt.Errorf("db error"),
@@ -371,12 +387,27 @@ func Test_DeleteLifecycle(t *testing.T) {
 
 	for k, v := range set {
 		k, v := k, v
+		st := store.NewMem()
+		require.Nil(t, st.Put(context.Background(), photoTable, "photo", photoMeta{Owner: "1"}))
+		require.Nil(t, st.Put(context.Background(), lineageTable, "1", lifecycleLinks{Parents: []lifecycleLink{{ID: "0", Kind: linkSplit}}}))
+		require.Nil(t, st.Put(context.Background(), lineageTable, "0", lifecycleLinks{Children: []lifecycleLink{{ID: "1", Kind: linkSplit}}}))
+		reg := codes.New(st)
+		_, err := reg.Assign(context.Background(), codes.Lifecycle, "1", time.Now())
+		require.Nil(t, err)
 		ha := &HuautlaAdaptor{
 			db: &huautlaMock{
 				Lifecycler: &lifecyclerMock{
-					deleteErr: v.err,
+					selectResult: types.Lifecycle{UUID: "1"},
+					selectErr:    v.selErr,
+					deleteErr:    v.err,
+				},
+				Photoer: &photoerMock{
+					getResult: []types.Photo{{UUID: "photo"}},
+					getErr:    v.getErr,
 				},
 			},
+			store: st,
+			codes: reg,
 		}
 		t.Run(k, func(t *testing.T) {
 			t.Parallel()
@@ -397,6 +428,17 @@ func Test_DeleteLifecycle(t *testing.T) {
 			ha.DeleteLifecycle(w, r)
 
 			require.Equal(t, v.sc, w.Code)
+
+			// the photo's metadata, its code and its lineage go with the
+			// lifecycle
+			deleted := v.sc == http.StatusNoContent
+			err := st.Get(context.Background(), photoTable, "photo", &photoMeta{})
+			require.Equal(t, deleted, errors.Is(err, store.ErrNotFound))
+			_, err = reg.Code(context.Background(), "1")
+			require.Equal(t, deleted, errors.Is(err, store.ErrNotFound))
+			var parent lifecycleLinks
+			require.Nil(t, st.Get(context.Background(), lineageTable, "0", &parent))
+			require.Equal(t, deleted, len(parent.Children) == 0)
 		})
 	}
 }
@@ -406,15 +448,36 @@ func Test_GetLifecycleReport(t *testing.T) {
 
 	set := map[string]struct {
 		id     string
+		links  *lifecycleLinks
+		rpt    types.Entity
 		result types.Entity
 		err    error
 		sc     int
 	}{
 		"happy_path": {
 			id:     "1",
+			rpt:    types.Entity{},
 			result: types.Entity{},
 			sc:     http.StatusOK,
 		},
+		"with_lineage": {
+			id: "1",
+			links: &lifecycleLinks{
+				Parents: []lifecycleLink{{ID: "0", Kind: linkSplit, Share: 0.5}},
+			},
+			rpt: types.Entity{"location": "tub"},
+			result: types.Entity{
+				"location": "tub",
+				"parents": []any{map[string]any{
+					"id":    "0",
+					"kind":  "split",
+					"share": 0.5,
+					"ctime": "0001-01-01T00:00:00Z",
+				}},
+				"children": nil,
+			},
+			sc: http.StatusOK,
+		},
 		"missing_id": {
 			sc: http.StatusBadRequest,
 		},
@@ -436,17 +499,23 @@ func Test_GetLifecycleReport(t *testing.T) {
 
 	for k, v := range set {
 		k, v := k, v
+		s := store.NewMem()
 		ha := &HuautlaAdaptor{
 			db: &huautlaMock{
 				Lifecycler: &lifecyclerMock{
-					rpt:    v.result,
+					rpt:    v.rpt,
 					rptErr: v.err,
 				},
 			},
+			store: s,
 		}
 		t.Run(k, func(t *testing.T) {
 			t.Parallel()
 
+			if v.links != nil {
+				require.Nil(t, s.Put(context.Background(), lineageTable, v.id, v.links))
+			}
+
 			w := httptest.NewRecorder()
 			defer w.Result().Body.Close()
 			rctx := chi.NewRouteContext()
@@ -0,0 +1,594 @@
+package huautla
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/codes"
+	"github.com/jsmit257/centerforfunguscontrol/internal/store"
+	"github.com/jsmit257/huautla/types"
+)
+
+type (
+	// lifecycleLink is one end of a split or a merge
+	lifecycleLink struct {
+		ID types.UUID `json:"id"`
+		// split or merge
+		Kind string `json:"kind"`
+		// how much of the parent went into the child, from 0 to 1
+		Share float64   `json:"share"`
+		CTime time.Time `json:"ctime"`
+	}
+
+	// lifecycleLinks are what a lifecycle came from and went into; huautla
+	// only knows about one-to-one runs, so these are kept separately
+	lifecycleLinks struct {
+		// the generation it was inoculated from, if it was
+		Generation types.UUID      `json:"generation,omitempty"`
+		Parents    []lifecycleLink `json:"parents,omitempty"`
+		Children   []lifecycleLink `json:"children,omitempty"`
+	}
+
+	splitChild struct {
+		types.Lifecycle
+		// how much of the parent this child gets, compared to the others;
+		// anyone who doesn't say gets 1
+		Share float64 `json:"share,omitempty"`
+	}
+
+	lifecycleSplit struct {
+		Children []splitChild `json:"children"`
+	}
+
+	mergeParent struct {
+		ID types.UUID `json:"id"`
+		// how much of the parent goes into the merge, all of it if nobody
+		// says
+		Share float64 `json:"share,omitempty"`
+	}
+
+	lifecycleMerge struct {
+		Parents   []mergeParent   `json:"parents"`
+		Lifecycle types.Lifecycle `json:"lifecycle"`
+	}
+
+	splitResult struct {
+		Parent   types.UUID       `json:"parent"`
+		Children []codedLifecycle `json:"children"`
+	}
+
+	mergeResult struct {
+		codedLifecycle
+		Parents []lifecycleLink `json:"parents"`
+	}
+
+	lineageNode struct {
+		ID       types.UUID `json:"id"`
+		Code     string     `json:"code,omitempty"`
+		Location string     `json:"location,omitempty"`
+		Kind     string     `json:"kind,omitempty"`
+		Share    float64    `json:"share,omitempty"`
+		// it's been deleted since
+		Missing  bool          `json:"missing,omitempty"`
+		Parents  []lineageNode `json:"parents,omitempty"`
+		Children []lineageNode `json:"children,omitempty"`
+	}
+)
+
+const (
+	lineageTable = "lifecycle_links"
+
+	linkSplit = "split"
+	linkMerge = "merge"
+)
+
+// PostLifecycleSplit makes a new lifecycle for each child, like the bulk tubs
+// a grain jar was spawned to; each gets the parent's strain and grain
+// substrate, and its share of the parent's strain and grain costs, and
+// everything else comes from the request. The costs move rather than being
+// copied, so the parent is left with none and nothing is counted twice
+func (ha *HuautlaAdaptor) PostLifecycleSplit(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ms := ha.start(ctx, "PostLifecycleSplit")
+	defer r.Body.Close()
+
+	var s lifecycleSplit
+
+	if id, err := getUUIDByName("id", w, r, ms); err != nil {
+		ms.error(w, err, http.StatusBadRequest, "failed to fetch uuid")
+	} else if body, err := io.ReadAll(r.Body); err != nil {
+		ms.error(w, err, http.StatusBadRequest, "couldn't read request body")
+	} else if err := json.Unmarshal(body, &s); err != nil {
+		ms.error(w, err, http.StatusBadRequest, "couldn't unmarshal request body")
+	} else if shares, err := s.shares(); err != nil {
+		ms.error(w, err, http.StatusBadRequest, err.Error())
+	} else if p, err := ha.db.SelectLifecycle(ctx, id, ms.cid); errors.Is(err, sql.ErrNoRows) {
+		ms.error(w, err, http.StatusBadRequest, "failed to fetch lifecycle")
+	} else if err != nil {
+		ms.error(w, err, http.StatusInternalServerError, "failed to fetch lifecycle")
+	} else if children, err := ha.insertLifecycles(ctx, s.children(p, shares), ms); err != nil {
+		ms.error(w, err, http.StatusInternalServerError, "failed to insert lifecycle")
+	} else if left, err := ha.updateLifecycles(ctx, []types.Lifecycle{passedOn(p, children...)}, []types.Lifecycle{p}, ms); err != nil {
+		ha.deleteLifecycles(ctx, children, ms)
+		ms.error(w, err, http.StatusInternalServerError, "failed to update lifecycle")
+	} else if links, err := ha.linkLifecycles(ctx, linkSplit, []types.UUID{p.UUID}, lifecycleIDs(children), shares); err != nil {
+		ha.restoreLifecycles(ctx, []types.Lifecycle{p}, ms)
+		ha.deleteLifecycles(ctx, children, ms)
+		ms.error(w, err, http.StatusInternalServerError, "failed to link lifecycles")
+	} else {
+		for _, c := range children {
+			ha.assignCode(ctx, ms, codes.Lifecycle, c.UUID)
+			ha.emit(ctx, ms, "lifecycle.created", c.UUID, c)
+		}
+		ha.emit(ctx, ms, "lifecycle.updated", p.UUID, left[0])
+		ha.emit(ctx, ms, "lifecycle.split", p.UUID, links.Children)
+		ms.send(w, http.StatusCreated, splitResult{Parent: p.UUID, Children: codedAll(ctx, ms, children, ha.codedLifecycle)})
+	}
+}
+
+// PostLifecycleMerge makes one lifecycle out of several, like jars combined
+// into a tub; they all have to be the same strain, and the new one gets the
+// first parent's grain substrate, unless it says otherwise, and each
+// parent's share of its strain and grain costs, which come off the parent
+func (ha *HuautlaAdaptor) PostLifecycleMerge(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ms := ha.start(ctx, "PostLifecycleMerge")
+	defer r.Body.Close()
+
+	var m lifecycleMerge
+
+	if body, err := io.ReadAll(r.Body); err != nil {
+		ms.error(w, err, http.StatusBadRequest, "couldn't read request body")
+	} else if err := json.Unmarshal(body, &m); err != nil {
+		ms.error(w, err, http.StatusBadRequest, "couldn't unmarshal request body")
+	} else if err := m.validate(); err != nil {
+		ms.error(w, err, http.StatusBadRequest, err.Error())
+	} else if parents, err := ha.selectLifecycles(ctx, m.parentIDs(), ms); errors.Is(err, sql.ErrNoRows) {
+		ms.error(w, err, http.StatusBadRequest, "failed to fetch lifecycle")
+	} else if err != nil {
+		ms.error(w, err, http.StatusInternalServerError, "failed to fetch lifecycle")
+	} else if child, err := m.child(parents); err != nil {
+		ms.error(w, err, http.StatusBadRequest, err.Error())
+	} else if created, err := ha.insertLifecycles(ctx, []types.Lifecycle{child}, ms); err != nil {
+		ms.error(w, err, http.StatusInternalServerError, "failed to insert lifecycle")
+	} else if left, err := ha.updateLifecycles(ctx, m.left(parents), parents, ms); err != nil {
+		ha.deleteLifecycles(ctx, created, ms)
+		ms.error(w, err, http.StatusInternalServerError, "failed to update lifecycle")
+	} else if links, err := ha.linkLifecycles(ctx, linkMerge, m.parentIDs(), lifecycleIDs(created), m.shares()); err != nil {
+		ha.restoreLifecycles(ctx, parents, ms)
+		ha.deleteLifecycles(ctx, created, ms)
+		ms.error(w, err, http.StatusInternalServerError, "failed to link lifecycles")
+	} else {
+		ha.assignCode(ctx, ms, codes.Lifecycle, created[0].UUID)
+		ha.emit(ctx, ms, "lifecycle.created", created[0].UUID, created[0])
+		for _, p := range left {
+			ha.emit(ctx, ms, "lifecycle.updated", p.UUID, p)
+		}
+		ha.emit(ctx, ms, "lifecycle.merged", created[0].UUID, links.Parents)
+		ms.send(w, http.StatusCreated, mergeResult{codedLifecycle: ha.codedLifecycle(ctx, ms, created[0]), Parents: links.Parents})
+	}
+}
+
+// GetLifecycleLineage is everything a lifecycle came from, and everything it
+// went into, as far back and as far forward as it goes
+func (ha *HuautlaAdaptor) GetLifecycleLineage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ms := ha.start(ctx, "GetLifecycleLineage")
+
+	if id, err := getUUIDByName("id", w, r, ms); err != nil {
+		ms.error(w, err, http.StatusBadRequest, "failed to fetch uuid")
+	} else if l, err := ha.db.SelectLifecycle(ctx, id, ms.cid); errors.Is(err, sql.ErrNoRows) {
+		ms.error(w, err, http.StatusBadRequest, "failed to fetch lifecycle")
+	} else if err != nil {
+		ms.error(w, err, http.StatusInternalServerError, "failed to fetch lifecycle")
+	} else if root, err := ha.lineage(ctx, l, ms); err != nil {
+		ms.error(w, err, http.StatusInternalServerError, "failed to fetch lineage")
+	} else {
+		ms.send(w, http.StatusOK, root)
+	}
+}
+
+func (s lifecycleSplit) shares() ([]float64, error) {
+	if len(s.Children) == 0 {
+		return nil, fmt.Errorf("a split needs at least one child")
+	}
+
+	result, total := make([]float64, len(s.Children)), 0.0
+	for i, c := range s.Children {
+		if c.Share < 0 {
+			return nil, fmt.Errorf("child %d has a negative share", i)
+		} else if result[i] = c.Share; c.Share == 0 {
+			result[i] = 1
+		}
+		total += result[i]
+	}
+	for i := range result {
+		result[i] /= total
+	}
+	return result, nil
+}
+
+func (s lifecycleSplit) children(p types.Lifecycle, shares []float64) []types.Lifecycle {
+	strainCosts := apportion(p.StrainCost, shares)
+	grainCosts := apportion(p.GrainCost, shares)
+
+	result := make([]types.Lifecycle, len(s.Children))
+	for i, c := range s.Children {
+		result[i] = c.Lifecycle
+		result[i].UUID = ""
+		result[i].Strain = p.Strain
+		result[i].GrainSubstrate = p.GrainSubstrate
+		result[i].StrainCost = strainCosts[i]
+		result[i].GrainCost = grainCosts[i]
+		result[i].Events = nil
+		if result[i].Location == "" {
+			result[i].Location = p.Location
+		}
+	}
+	return result
+}
+
+func (m lifecycleMerge) validate() error {
+	if len(m.Parents) < 2 {
+		return fmt.Errorf("a merge needs at least two parents")
+	}
+	for i, p := range m.Parents {
+		if p.ID == "" {
+			return fmt.Errorf("parent %d has no id", i)
+		} else if p.Share < 0 || p.Share > 1 {
+			return fmt.Errorf("parent %d's share has to be between 0 and 1", i)
+		} else if slices.ContainsFunc(m.Parents[:i], func(o mergeParent) bool { return o.ID == p.ID }) {
+			return fmt.Errorf("parent %d is already in the merge", i)
+		}
+	}
+	return nil
+}
+
+func (m lifecycleMerge) parentIDs() []types.UUID {
+	result := make([]types.UUID, len(m.Parents))
+	for i, p := range m.Parents {
+		result[i] = p.ID
+	}
+	return result
+}
+
+func (m lifecycleMerge) shares() []float64 {
+	result := make([]float64, len(m.Parents))
+	for i, p := range m.Parents {
+		if result[i] = p.Share; p.Share == 0 {
+			result[i] = 1
+		}
+	}
+	return result
+}
+
+func (m lifecycleMerge) child(parents []types.Lifecycle) (types.Lifecycle, error) {
+	result := m.Lifecycle
+	result.UUID = ""
+	result.Events = nil
+	result.Strain = parents[0].Strain
+	if result.GrainSubstrate.UUID == "" {
+		result.GrainSubstrate = parents[0].GrainSubstrate
+	}
+	if result.Location == "" {
+		result.Location = parents[0].Location
+	}
+
+	var strainCost, grainCost float64
+	for i, p := range parents {
+		if p.Strain.UUID != result.Strain.UUID {
+			return result, fmt.Errorf("can't merge different strains: %s and %s", result.Strain.UUID, p.Strain.UUID)
+		}
+		share := shareOf(p, m.shares()[i])
+		strainCost += float64(share.StrainCost)
+		grainCost += float64(share.GrainCost)
+	}
+	result.StrainCost = float32(cents(strainCost))
+	result.GrainCost = float32(cents(grainCost))
+
+	return result, nil
+}
+
+// shareOf is the part of p's costs that goes into a merge
+func shareOf(p types.Lifecycle, share float64) types.Lifecycle {
+	return types.Lifecycle{
+		StrainCost: float32(cents(float64(p.StrainCost) * share)),
+		GrainCost:  float32(cents(float64(p.GrainCost) * share)),
+	}
+}
+
+// left is the parents with what went into the merge taken off their costs
+func (m lifecycleMerge) left(parents []types.Lifecycle) []types.Lifecycle {
+	result := make([]types.Lifecycle, len(parents))
+	for i, p := range parents {
+		result[i] = passedOn(p, shareOf(p, m.shares()[i]))
+	}
+	return result
+}
+
+// passedOn is p with the strain and grain costs that went to others taken
+// off its own, so reports that add up costs only count them once
+func passedOn(p types.Lifecycle, others ...types.Lifecycle) types.Lifecycle {
+	strainCost, grainCost := float64(p.StrainCost), float64(p.GrainCost)
+	for _, o := range others {
+		strainCost -= float64(o.StrainCost)
+		grainCost -= float64(o.GrainCost)
+	}
+	p.StrainCost = float32(cents(strainCost))
+	p.GrainCost = float32(cents(grainCost))
+	return p
+}
+
+func (ha *HuautlaAdaptor) selectLifecycles(ctx context.Context, ids []types.UUID, ms *methodStats) ([]types.Lifecycle, error) {
+	result := make([]types.Lifecycle, 0, len(ids))
+	for _, id := range ids {
+		l, err := ha.db.SelectLifecycle(ctx, id, ms.cid)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", err, id)
+		}
+		result = append(result, l)
+	}
+	return result, nil
+}
+
+// insertLifecycles inserts all of them or none of them
+func (ha *HuautlaAdaptor) insertLifecycles(ctx context.Context, all []types.Lifecycle, ms *methodStats) ([]types.Lifecycle, error) {
+	result := make([]types.Lifecycle, 0, len(all))
+	for _, l := range all {
+		created, err := ha.db.InsertLifecycle(ctx, l, ms.cid)
+		if err != nil {
+			ha.deleteLifecycles(ctx, result, ms)
+			return nil, err
+		}
+		result = append(result, created)
+	}
+	return result, nil
+}
+
+// updateLifecycles updates all of them or none of them; before is what they
+// were, to put back the ones that were updated if one fails
+func (ha *HuautlaAdaptor) updateLifecycles(ctx context.Context, all, before []types.Lifecycle, ms *methodStats) ([]types.Lifecycle, error) {
+	result := make([]types.Lifecycle, 0, len(all))
+	for _, l := range all {
+		updated, err := ha.db.UpdateLifecycle(ctx, l, ms.cid)
+		if err != nil {
+			ha.restoreLifecycles(ctx, before[:len(result)], ms)
+			return nil, err
+		}
+		result = append(result, updated)
+	}
+	return result, nil
+}
+
+// restoreLifecycles puts back lifecycles that were only just updated
+func (ha *HuautlaAdaptor) restoreLifecycles(ctx context.Context, all []types.Lifecycle, ms *methodStats) {
+	for _, l := range all {
+		if _, err := ha.db.UpdateLifecycle(ctx, l, ms.cid); err != nil {
+			ms.l.WithError(err).WithField("lifecycle", l.UUID).Error("failed to put back lifecycle that was only partly changed")
+		}
+	}
+}
+
+// deleteLifecycles takes back lifecycles that were only just made, along
+// with any links they got before something failed
+func (ha *HuautlaAdaptor) deleteLifecycles(ctx context.Context, all []types.Lifecycle, ms *methodStats) {
+	for _, l := range all {
+		if err := ha.db.DeleteLifecycle(ctx, l.UUID, ms.cid); err != nil {
+			ms.l.WithError(err).WithField("lifecycle", l.UUID).Error("failed to delete lifecycle that was only partly made")
+		} else {
+			ha.forgetLifecycle(ctx, l.UUID, ms)
+		}
+	}
+}
+
+// forgetLifecycle cleans up after a lifecycle that's been deleted: it comes
+// out of the lineage and loses its code. The lifecycle is already gone, so
+// failing is only logged
+func (ha *HuautlaAdaptor) forgetLifecycle(ctx context.Context, id types.UUID, ms *methodStats) {
+	l := ms.l.WithField("lifecycle", id)
+	if err := ha.unlinkLifecycle(ctx, id); err != nil {
+		l.WithError(err).Warn("failed to remove lifecycle from lineage")
+	}
+	if ha.codes == nil {
+	} else if err := ha.codes.Forget(ctx, id); err != nil {
+		l.WithError(err).Warn("failed to remove lifecycle's code")
+	}
+}
+
+// linkLifecycles records that children came from parents; shares are per
+// child for a split and per parent for a merge. It returns the links of the
+// one parent of a split, or the one child of a merge
+func (ha *HuautlaAdaptor) linkLifecycles(ctx context.Context, kind string, parents, children []types.UUID, shares []float64) (lifecycleLinks, error) {
+	// links are read, changed and written back, by the http and kafka
+	// servers alike, so it has to be the store's lock and not just ours
+	unlock, err := ha.store.Lock(ctx, lineageTable)
+	if err != nil {
+		return lifecycleLinks{}, err
+	}
+	defer unlock()
+
+	now := time.Now().UTC()
+	share := func(p, c int) float64 {
+		if kind == linkSplit {
+			return shares[c]
+		}
+		return shares[p]
+	}
+
+	all := map[types.UUID]*lifecycleLinks{}
+	for _, id := range append(slices.Clone(parents), children...) {
+		links, err := ha.lifecycleLinks(ctx, id)
+		if err != nil {
+			return lifecycleLinks{}, err
+		}
+		all[id] = &links
+	}
+
+	for p, pID := range parents {
+		for c, cID := range children {
+			all[pID].Children = append(all[pID].Children, lifecycleLink{ID: cID, Kind: kind, Share: share(p, c), CTime: now})
+			all[cID].Parents = append(all[cID].Parents, lifecycleLink{ID: pID, Kind: kind, Share: share(p, c), CTime: now})
+		}
+	}
+
+	for id, links := range all {
+		if err := ha.store.Put(ctx, lineageTable, string(id), links); err != nil {
+			return lifecycleLinks{}, err
+		}
+	}
+
+	if kind == linkSplit {
+		return *all[parents[0]], nil
+	}
+	return *all[children[0]], nil
+}
+
+// unlinkLifecycle takes id off its parents' children, its children's
+// parents and its generation's inoculations, and then drops its own links
+func (ha *HuautlaAdaptor) unlinkLifecycle(ctx context.Context, id types.UUID) error {
+	unlock, err := ha.store.Lock(ctx, lineageTable)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	links, err := ha.lifecycleLinks(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	gone := func(l lifecycleLink) bool { return l.ID == id }
+	for _, p := range links.Parents {
+		other, err := ha.lifecycleLinks(ctx, p.ID)
+		if err != nil {
+			return err
+		}
+		other.Children = slices.DeleteFunc(other.Children, gone)
+		if err = ha.store.Put(ctx, lineageTable, string(p.ID), other); err != nil {
+			return err
+		}
+	}
+	for _, c := range links.Children {
+		other, err := ha.lifecycleLinks(ctx, c.ID)
+		if err != nil {
+			return err
+		}
+		other.Parents = slices.DeleteFunc(other.Parents, gone)
+		if err = ha.store.Put(ctx, lineageTable, string(c.ID), other); err != nil {
+			return err
+		}
+	}
+	if links.Generation != "" {
+		all, err := ha.inoculatedLifecycles(ctx, links.Generation)
+		if err != nil {
+			return err
+		}
+		all = slices.DeleteFunc(all, func(in inoculated) bool { return in.ID == id })
+		if err = ha.store.Put(ctx, inoculatedTable, string(links.Generation), all); err != nil {
+			return err
+		}
+	}
+
+	if err := ha.store.Delete(ctx, lineageTable, string(id)); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+func (ha *HuautlaAdaptor) lifecycleLinks(ctx context.Context, id types.UUID) (lifecycleLinks, error) {
+	var result lifecycleLinks
+	if err := ha.store.Get(ctx, lineageTable, string(id), &result); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return result, err
+	}
+	return result, nil
+}
+
+func (ha *HuautlaAdaptor) lineage(ctx context.Context, l types.Lifecycle, ms *methodStats) (lineageNode, error) {
+	root := lineageNode{ID: l.UUID, Code: ha.code(ctx, ms, l.UUID), Location: l.Location}
+
+	links, err := ha.lifecycleLinks(ctx, l.UUID)
+	if err != nil {
+		return root, err
+	}
+
+	// merges mean the same lifecycle can turn up more than once, but it
+	// should never be its own ancestor
+	if root.Parents, err = ha.lineageNodes(ctx, links.Parents, true, map[types.UUID]bool{l.UUID: true}, ms); err != nil {
+		return root, err
+	}
+	root.Children, err = ha.lineageNodes(ctx, links.Children, false, map[types.UUID]bool{l.UUID: true}, ms)
+	return root, err
+}
+
+func (ha *HuautlaAdaptor) lineageNodes(ctx context.Context, links []lifecycleLink, up bool, seen map[types.UUID]bool, ms *methodStats) ([]lineageNode, error) {
+	var result []lineageNode
+	for _, link := range links {
+		if seen[link.ID] {
+			continue
+		}
+
+		n := lineageNode{ID: link.ID, Code: ha.code(ctx, ms, link.ID), Kind: link.Kind, Share: link.Share}
+		if l, err := ha.db.SelectLifecycle(ctx, link.ID, ms.cid); errors.Is(err, sql.ErrNoRows) {
+			n.Missing = true
+		} else if err != nil {
+			return nil, err
+		} else {
+			n.Location = l.Location
+		}
+
+		next, err := ha.lifecycleLinks(ctx, link.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		seen[link.ID] = true
+		if up {
+			n.Parents, err = ha.lineageNodes(ctx, next.Parents, up, seen, ms)
+		} else {
+			n.Children, err = ha.lineageNodes(ctx, next.Children, up, seen, ms)
+		}
+		delete(seen, link.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, n)
+	}
+	return result, nil
+}
+
+func lifecycleIDs(all []types.Lifecycle) []types.UUID {
+	result := make([]types.UUID, len(all))
+	for i, l := range all {
+		result[i] = l.UUID
+	}
+	return result
+}
+
+// apportion splits total by shares, to the cent; whatever's left over from
+// rounding goes to the last one, so they always add up to total
+func apportion(total float32, shares []float64) []float32 {
+	result := make([]float32, len(shares))
+	left := float64(total)
+	for i, s := range shares {
+		if i == len(shares)-1 {
+			result[i] = float32(cents(left))
+		} else {
+			part := cents(float64(total) * s)
+			result[i], left = float32(part), left-part
+		}
+	}
+	return result
+}
+
+func cents(f float64) float64 {
+	return math.Round(f*100) / 100
+}
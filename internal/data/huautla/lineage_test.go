@@ -0,0 +1,515 @@
+package huautla
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/store"
+	"github.com/jsmit257/huautla/types"
+)
+
+// lineageLifecycles remembers what it's told; inserting anything at a
+// location starting with fail fails, and so does updating failUpdate
+type lineageLifecycles struct {
+	types.Lifecycler
+
+	mtx        sync.Mutex
+	n          int
+	all        map[types.UUID]types.Lifecycle
+	failUpdate types.UUID
+}
+
+func (ll *lineageLifecycles) SelectLifecycle(_ context.Context, id types.UUID, _ types.CID) (types.Lifecycle, error) {
+	ll.mtx.Lock()
+	defer ll.mtx.Unlock()
+	if l, ok := ll.all[id]; ok {
+		return l, nil
+	}
+	return types.Lifecycle{}, sql.ErrNoRows
+}
+
+func (ll *lineageLifecycles) InsertLifecycle(_ context.Context, l types.Lifecycle, _ types.CID) (types.Lifecycle, error) {
+	ll.mtx.Lock()
+	defer ll.mtx.Unlock()
+	if strings.HasPrefix(l.Location, "fail") {
+		return l, fmt.Errorf("insert failed")
+	}
+	l.UUID = types.UUID(fmt.Sprintf("new%d", ll.n))
+	ll.n++
+	ll.all[l.UUID] = l
+	return l, nil
+}
+
+func (ll *lineageLifecycles) UpdateLifecycle(_ context.Context, l types.Lifecycle, _ types.CID) (types.Lifecycle, error) {
+	ll.mtx.Lock()
+	defer ll.mtx.Unlock()
+	if _, ok := ll.all[l.UUID]; !ok || l.UUID == ll.failUpdate {
+		return l, fmt.Errorf("update failed")
+	}
+	ll.all[l.UUID] = l
+	return l, nil
+}
+
+// costs are a lifecycle's strain and grain costs
+func (ll *lineageLifecycles) costs(id types.UUID) [2]float32 {
+	ll.mtx.Lock()
+	defer ll.mtx.Unlock()
+	return [2]float32{ll.all[id].StrainCost, ll.all[id].GrainCost}
+}
+
+func (ll *lineageLifecycles) DeleteLifecycle(_ context.Context, id types.UUID, _ types.CID) error {
+	ll.mtx.Lock()
+	defer ll.mtx.Unlock()
+	delete(ll.all, id)
+	return nil
+}
+
+func (ll *lineageLifecycles) ids() []string {
+	ll.mtx.Lock()
+	defer ll.mtx.Unlock()
+	result := []string{}
+	for id := range ll.all {
+		result = append(result, string(id))
+	}
+	sort.Strings(result)
+	return result
+}
+
+func newLineageAdaptor() (*HuautlaAdaptor, *lineageLifecycles) {
+	ll := &lineageLifecycles{all: map[types.UUID]types.Lifecycle{
+		"jar0": {
+			UUID:           "jar0",
+			Location:       "shelf",
+			Strain:         types.Strain{UUID: "golden"},
+			GrainSubstrate: types.Substrate{UUID: "rye"},
+			StrainCost:     1,
+			GrainCost:      10,
+		},
+		"jar1": {
+			UUID:           "jar1",
+			Location:       "shelf",
+			Strain:         types.Strain{UUID: "golden"},
+			GrainSubstrate: types.Substrate{UUID: "millet"},
+			StrainCost:     2,
+			GrainCost:      6,
+		},
+		"jar2": {
+			UUID:   "jar2",
+			Strain: types.Strain{UUID: "penis envy"},
+		},
+	}}
+	return &HuautlaAdaptor{
+		db:    &huautlaMock{Lifecycler: ll},
+		store: store.NewMem(),
+	}, ll
+}
+
+func idParam(id string) chi.RouteParams {
+	return chi.RouteParams{Keys: []string{"id"}, Values: []string{id}}
+}
+
+func Test_PostLifecycleSplit(t *testing.T) {
+	t.Parallel()
+
+	type child struct {
+		location    string
+		strainCost  float32
+		grainCost   float32
+		bulkSubstrt types.UUID
+	}
+
+	tcs := map[string]struct {
+		id         string
+		body       string
+		failUpdate types.UUID
+		sc         int
+		children   []child
+		after      []string
+		// the parent's strain and grain costs afterwards
+		left map[types.UUID][2]float32
+	}{
+		"equal_shares": {
+			id:   "jar0",
+			body: `{"children":[{"location":"tub 1","bulk_substrate":{"id":"cvg"}},{"location":"tub 2"},{}]}`,
+			sc:   http.StatusCreated,
+			children: []child{
+				{location: "tub 1", strainCost: 0.33, grainCost: 3.33, bulkSubstrt: "cvg"},
+				{location: "tub 2", strainCost: 0.33, grainCost: 3.33},
+				{location: "shelf", strainCost: 0.34, grainCost: 3.34},
+			},
+			after: []string{"jar0", "jar1", "jar2", "new0", "new1", "new2"},
+			left:  map[types.UUID][2]float32{"jar0": {0, 0}},
+		},
+		"weighted_shares": {
+			id:   "jar1",
+			body: `{"children":[{"location":"tub 1","share":2},{"location":"tub 2"}]}`,
+			sc:   http.StatusCreated,
+			children: []child{
+				{location: "tub 1", strainCost: 1.33, grainCost: 4},
+				{location: "tub 2", strainCost: 0.67, grainCost: 2},
+			},
+			after: []string{"jar0", "jar1", "jar2", "new0", "new1"},
+			left:  map[types.UUID][2]float32{"jar1": {0, 0}},
+		},
+		"insert_fails": {
+			id:    "jar0",
+			body:  `{"children":[{"location":"tub 1"},{"location":"fail"}]}`,
+			sc:    http.StatusInternalServerError,
+			after: []string{"jar0", "jar1", "jar2"},
+			left:  map[types.UUID][2]float32{"jar0": {1, 10}},
+		},
+		"update_fails": {
+			id:         "jar0",
+			body:       `{"children":[{"location":"tub 1"},{"location":"tub 2"}]}`,
+			failUpdate: "jar0",
+			sc:         http.StatusInternalServerError,
+			after:      []string{"jar0", "jar1", "jar2"},
+			left:       map[types.UUID][2]float32{"jar0": {1, 10}},
+		},
+		"missing_parent": {
+			id:    "jar9",
+			body:  `{"children":[{"location":"tub 1"}]}`,
+			sc:    http.StatusBadRequest,
+			after: []string{"jar0", "jar1", "jar2"},
+		},
+		"no_children": {
+			id:    "jar0",
+			body:  `{"children":[]}`,
+			sc:    http.StatusBadRequest,
+			after: []string{"jar0", "jar1", "jar2"},
+		},
+		"negative_share": {
+			id:    "jar0",
+			body:  `{"children":[{"share":-1}]}`,
+			sc:    http.StatusBadRequest,
+			after: []string{"jar0", "jar1", "jar2"},
+		},
+		"unmarshal_error": {
+			id:    "jar0",
+			body:  `{"children":`,
+			sc:    http.StatusBadRequest,
+			after: []string{"jar0", "jar1", "jar2"},
+		},
+		"missing_id": {
+			sc:    http.StatusBadRequest,
+			after: []string{"jar0", "jar1", "jar2"},
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ha, ll := newLineageAdaptor()
+			ll.failUpdate = tc.failUpdate
+			w := sendWebhook(ha.PostLifecycleSplit, http.MethodPost, "url", idParam(tc.id), tc.body)
+			require.Equal(t, tc.sc, w.Code, w.Body.String())
+			require.Equal(t, tc.after, ll.ids())
+			for id, left := range tc.left {
+				require.Equal(t, left, ll.costs(id), id)
+			}
+			if tc.sc != http.StatusCreated {
+				return
+			}
+
+			var result splitResult
+			require.Nil(t, json.Unmarshal(w.Body.Bytes(), &result))
+			require.Equal(t, types.UUID(tc.id), result.Parent)
+			require.Len(t, result.Children, len(tc.children))
+
+			links, err := ha.lifecycleLinks(context.Background(), types.UUID(tc.id))
+			require.Nil(t, err)
+			require.Len(t, links.Children, len(tc.children))
+
+			parent := ll.all[types.UUID(tc.id)]
+			for i, c := range tc.children {
+				got := result.Children[i]
+				require.Equal(t, c.location, got.Location)
+				require.Equal(t, c.strainCost, got.StrainCost)
+				require.Equal(t, c.grainCost, got.GrainCost)
+				require.Equal(t, c.bulkSubstrt, got.BulkSubstrate.UUID)
+				require.Equal(t, parent.Strain.UUID, got.Strain.UUID)
+				require.Equal(t, parent.GrainSubstrate.UUID, got.GrainSubstrate.UUID)
+
+				require.Equal(t, got.UUID, links.Children[i].ID)
+				childLinks, err := ha.lifecycleLinks(context.Background(), got.UUID)
+				require.Nil(t, err)
+				require.Len(t, childLinks.Parents, 1)
+				require.Equal(t, types.UUID(tc.id), childLinks.Parents[0].ID)
+				require.Equal(t, linkSplit, childLinks.Parents[0].Kind)
+				require.Equal(t, links.Children[i].Share, childLinks.Parents[0].Share)
+			}
+		})
+	}
+}
+
+func Test_PostLifecycleMerge(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		body       string
+		failUpdate types.UUID
+		sc         int
+		strainCost float32
+		grainCost  float32
+		grain      types.UUID
+		after      []string
+		// the parents' strain and grain costs afterwards
+		left map[types.UUID][2]float32
+	}{
+		"happy_path": {
+			body:       `{"parents":[{"id":"jar0"},{"id":"jar1"}],"lifecycle":{"location":"tub"}}`,
+			sc:         http.StatusCreated,
+			strainCost: 3,
+			grainCost:  16,
+			grain:      "rye",
+			after:      []string{"jar0", "jar1", "jar2", "new0"},
+			left:       map[types.UUID][2]float32{"jar0": {0, 0}, "jar1": {0, 0}},
+		},
+		"partial_parents": {
+			body:       `{"parents":[{"id":"jar0","share":0.5},{"id":"jar1","share":0.25}],"lifecycle":{"grain_substrate":{"id":"millet"}}}`,
+			sc:         http.StatusCreated,
+			strainCost: 1,
+			grainCost:  6.5,
+			grain:      "millet",
+			after:      []string{"jar0", "jar1", "jar2", "new0"},
+			left:       map[types.UUID][2]float32{"jar0": {0.5, 5}, "jar1": {1.5, 4.5}},
+		},
+		"different_strains": {
+			body:  `{"parents":[{"id":"jar0"},{"id":"jar2"}]}`,
+			sc:    http.StatusBadRequest,
+			after: []string{"jar0", "jar1", "jar2"},
+		},
+		"one_parent": {
+			body:  `{"parents":[{"id":"jar0"}]}`,
+			sc:    http.StatusBadRequest,
+			after: []string{"jar0", "jar1", "jar2"},
+		},
+		"same_parent_twice": {
+			body:  `{"parents":[{"id":"jar0"},{"id":"jar0"}]}`,
+			sc:    http.StatusBadRequest,
+			after: []string{"jar0", "jar1", "jar2"},
+		},
+		"share_too_big": {
+			body:  `{"parents":[{"id":"jar0","share":2},{"id":"jar1"}]}`,
+			sc:    http.StatusBadRequest,
+			after: []string{"jar0", "jar1", "jar2"},
+		},
+		"missing_parent": {
+			body:  `{"parents":[{"id":"jar0"},{"id":"jar9"}]}`,
+			sc:    http.StatusBadRequest,
+			after: []string{"jar0", "jar1", "jar2"},
+		},
+		"insert_fails": {
+			body:  `{"parents":[{"id":"jar0"},{"id":"jar1"}],"lifecycle":{"location":"fail"}}`,
+			sc:    http.StatusInternalServerError,
+			after: []string{"jar0", "jar1", "jar2"},
+			left:  map[types.UUID][2]float32{"jar0": {1, 10}, "jar1": {2, 6}},
+		},
+		"update_fails": {
+			body:       `{"parents":[{"id":"jar0"},{"id":"jar1"}],"lifecycle":{"location":"tub"}}`,
+			failUpdate: "jar1",
+			sc:         http.StatusInternalServerError,
+			after:      []string{"jar0", "jar1", "jar2"},
+			left:       map[types.UUID][2]float32{"jar0": {1, 10}, "jar1": {2, 6}},
+		},
+		"unmarshal_error": {
+			body:  `{"parents":`,
+			sc:    http.StatusBadRequest,
+			after: []string{"jar0", "jar1", "jar2"},
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ha, ll := newLineageAdaptor()
+			ll.failUpdate = tc.failUpdate
+			w := sendWebhook(ha.PostLifecycleMerge, http.MethodPost, "url", chi.RouteParams{}, tc.body)
+			require.Equal(t, tc.sc, w.Code, w.Body.String())
+			require.Equal(t, tc.after, ll.ids())
+			for id, left := range tc.left {
+				require.Equal(t, left, ll.costs(id), id)
+			}
+			if tc.sc != http.StatusCreated {
+				return
+			}
+
+			var result mergeResult
+			require.Nil(t, json.Unmarshal(w.Body.Bytes(), &result))
+			require.Equal(t, types.UUID("new0"), result.UUID)
+			require.Equal(t, types.UUID("golden"), result.Strain.UUID)
+			require.Equal(t, tc.grain, result.GrainSubstrate.UUID)
+			require.Equal(t, tc.strainCost, result.StrainCost)
+			require.Equal(t, tc.grainCost, result.GrainCost)
+			require.Len(t, result.Parents, 2)
+
+			for _, p := range result.Parents {
+				links, err := ha.lifecycleLinks(context.Background(), p.ID)
+				require.Nil(t, err)
+				require.Equal(t, []lifecycleLink{{ID: "new0", Kind: linkMerge, Share: p.Share, CTime: p.CTime}}, links.Children)
+			}
+		})
+	}
+}
+
+func Test_GetLifecycleLineage(t *testing.T) {
+	t.Parallel()
+
+	ha, ll := newLineageAdaptor()
+
+	// jar0 and jar1 go into new0, which is split into new1 and new2
+	w := sendWebhook(ha.PostLifecycleMerge, http.MethodPost, "url", chi.RouteParams{}, `{"parents":[{"id":"jar0"},{"id":"jar1","share":0.5}],"lifecycle":{"location":"bin"}}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	w = sendWebhook(ha.PostLifecycleSplit, http.MethodPost, "url", idParam("new0"), `{"children":[{"location":"tub 1"},{"location":"tub 2"}]}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	require.Nil(t, ll.DeleteLifecycle(context.Background(), "jar0", ""))
+
+	tcs := map[string]struct {
+		id     string
+		sc     int
+		result lineageNode
+	}{
+		"middle": {
+			id: "new0",
+			sc: http.StatusOK,
+			result: lineageNode{
+				ID:       "new0",
+				Location: "bin",
+				Parents: []lineageNode{
+					{ID: "jar0", Kind: linkMerge, Share: 1, Missing: true},
+					{ID: "jar1", Kind: linkMerge, Share: 0.5, Location: "shelf"},
+				},
+				Children: []lineageNode{
+					{ID: "new1", Kind: linkSplit, Share: 0.5, Location: "tub 1"},
+					{ID: "new2", Kind: linkSplit, Share: 0.5, Location: "tub 2"},
+				},
+			},
+		},
+		"leaf": {
+			id: "new2",
+			sc: http.StatusOK,
+			result: lineageNode{
+				ID:       "new2",
+				Location: "tub 2",
+				Parents: []lineageNode{
+					{ID: "new0", Kind: linkSplit, Share: 0.5, Location: "bin", Parents: []lineageNode{
+						{ID: "jar0", Kind: linkMerge, Share: 1, Missing: true},
+						{ID: "jar1", Kind: linkMerge, Share: 0.5, Location: "shelf"},
+					}},
+				},
+			},
+		},
+		"root": {
+			id: "jar1",
+			sc: http.StatusOK,
+			result: lineageNode{
+				ID:       "jar1",
+				Location: "shelf",
+				Children: []lineageNode{
+					{ID: "new0", Kind: linkMerge, Share: 0.5, Location: "bin", Children: []lineageNode{
+						{ID: "new1", Kind: linkSplit, Share: 0.5, Location: "tub 1"},
+						{ID: "new2", Kind: linkSplit, Share: 0.5, Location: "tub 2"},
+					}},
+				},
+			},
+		},
+		"no_lineage": {
+			id:     "jar2",
+			sc:     http.StatusOK,
+			result: lineageNode{ID: "jar2"},
+		},
+		"missing": {
+			id: "jar0",
+			sc: http.StatusBadRequest,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			w := sendWebhook(ha.GetLifecycleLineage, http.MethodGet, "url", idParam(tc.id), "")
+			require.Equal(t, tc.sc, w.Code, w.Body.String())
+			if tc.sc != http.StatusOK {
+				return
+			}
+
+			var result lineageNode
+			require.Nil(t, json.Unmarshal(w.Body.Bytes(), &result))
+			require.Equal(t, tc.result, result)
+		})
+	}
+}
+
+func Test_unlinkLifecycle(t *testing.T) {
+	t.Parallel()
+
+	ha := &HuautlaAdaptor{store: store.NewMem()}
+	ctx := context.Background()
+
+	// g0 inoculated jar0 and jar1, which were merged into new0, which was
+	// split into new1 and new2
+	require.Nil(t, ha.linkInoculated(ctx, "g0", []types.Lifecycle{{UUID: "jar0"}, {UUID: "jar1"}}))
+	_, err := ha.linkLifecycles(ctx, linkMerge, []types.UUID{"jar0", "jar1"}, []types.UUID{"new0"}, []float64{1, 1})
+	require.Nil(t, err)
+	_, err = ha.linkLifecycles(ctx, linkSplit, []types.UUID{"new0"}, []types.UUID{"new1", "new2"}, []float64{0.5, 0.5})
+	require.Nil(t, err)
+
+	require.Nil(t, ha.unlinkLifecycle(ctx, "jar0"))
+	require.Nil(t, ha.unlinkLifecycle(ctx, "new0"))
+	// there's nothing to take out, which is fine too
+	require.Nil(t, ha.unlinkLifecycle(ctx, "unlinked"))
+
+	for id, children := range map[types.UUID]int{"jar1": 0, "new1": 0, "new2": 0} {
+		links, err := ha.lifecycleLinks(ctx, id)
+		require.Nil(t, err)
+		require.Empty(t, links.Parents, id)
+		require.Len(t, links.Children, children, id)
+	}
+	err = ha.store.Get(ctx, lineageTable, "new0", &lifecycleLinks{})
+	require.ErrorIs(t, err, store.ErrNotFound)
+
+	lcs, err := ha.inoculatedLifecycles(ctx, "g0")
+	require.Nil(t, err)
+	require.Equal(t, []types.UUID{"jar1"}, []types.UUID{lcs[0].ID})
+	require.Len(t, lcs, 1)
+}
+
+func Test_apportion(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		total  float32
+		shares []float64
+		result []float32
+	}{
+		"thirds":   {total: 10, shares: []float64{1. / 3, 1. / 3, 1. / 3}, result: []float32{3.33, 3.33, 3.34}},
+		"uneven":   {total: 9.99, shares: []float64{0.75, 0.25}, result: []float32{7.49, 2.5}},
+		"one":      {total: 4.5, shares: []float64{1}, result: []float32{4.5}},
+		"nothing":  {total: 0, shares: []float64{0.5, 0.5}, result: []float32{0, 0}},
+		"no_parts": {total: 1, result: []float32{}},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tc.result, apportion(tc.total, tc.shares))
+		})
+	}
+}
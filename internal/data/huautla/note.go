@@ -52,6 +52,7 @@ func (ha *HuautlaAdaptor) PostNote(w http.ResponseWriter, r *http.Request) {
 	} else if notes, err = ha.db.AddNote(ctx, types.UUID(oID), notes, n, ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to add note")
 	} else {
+		ha.emit(r.Context(), ms, "note.added", newest(notes).UUID, owned{types.UUID(oID), newest(notes)})
 		ms.send(w, http.StatusOK, notes)
 	}
 }
@@ -63,7 +64,7 @@ func (ha *HuautlaAdaptor) PatchNote(w http.ResponseWriter, r *http.Request) {
 	ms := ha.start(ctx, "PatchNote")
 
 	var n types.Note
-	if _, notes, err := ha.getNotes(w, r, ms); err != nil {
+	if oID, notes, err := ha.getNotes(w, r, ms); err != nil {
 		return
 	} else if body, err := io.ReadAll(r.Body); err != nil {
 		ms.error(w, err, http.StatusBadRequest, "couldn't read request body")
@@ -72,6 +73,7 @@ func (ha *HuautlaAdaptor) PatchNote(w http.ResponseWriter, r *http.Request) {
 	} else if notes, err = ha.db.ChangeNote(ctx, notes, n, ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to change note")
 	} else {
+		ha.emit(r.Context(), ms, "note.changed", n.UUID, owned{types.UUID(oID), n})
 		ms.send(w, http.StatusOK, notes)
 	}
 }
@@ -80,7 +82,7 @@ func (ha *HuautlaAdaptor) DeleteNote(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	ms := ha.start(ctx, "DeleteNote")
 
-	if _, notes, err := ha.getNotes(w, r, ms); err != nil {
+	if oID, notes, err := ha.getNotes(w, r, ms); err != nil {
 		return
 	} else if id := chi.URLParam(r, "id"); id == "" {
 		ms.error(w, fmt.Errorf("missing required id parameter"), http.StatusBadRequest, "missing required id parameter")
@@ -89,6 +91,7 @@ func (ha *HuautlaAdaptor) DeleteNote(w http.ResponseWriter, r *http.Request) {
 	} else if notes, err = ha.db.RemoveNote(ctx, notes, types.UUID(id), ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to remove note")
 	} else {
+		ha.emit(r.Context(), ms, "note.removed", types.UUID(id), owned{types.UUID(oID), nil})
 		ms.send(w, http.StatusOK, notes)
 	}
 }
@@ -0,0 +1,48 @@
+package huautla
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// runOutboxPruning prunes the outbox every so often until ctx is done
+func (ha *HuautlaAdaptor) runOutboxPruning(ctx context.Context, every time.Duration, log *logrus.Entry) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if err := ha.pruneOutbox(ctx, time.Now()); err != nil {
+				log.WithError(err).Error("failed to prune the outbox")
+			}
+		}
+	}
+}
+
+// pruneOutbox forgets events that are older than the retention, as long as
+// webhooks are done with them; the dispatcher is the only consumer that keeps
+// its place in the store, so a stream that's been gone longer than the
+// retention starts over from whatever is left
+func (ha *HuautlaAdaptor) pruneOutbox(ctx context.Context, now time.Time) error {
+	if ha.outbox == nil || ha.outboxRetention <= 0 {
+		return nil
+	}
+
+	seq, err := ha.outbox.Before(ctx, now.Add(-ha.outboxRetention))
+	if err != nil || seq == 0 {
+		return err
+	} else if ha.webhooks != nil {
+		cursor, err := ha.webhooks.Cursor(ctx)
+		if err != nil {
+			return err
+		}
+		seq = min(seq, cursor)
+	}
+
+	return ha.outbox.Prune(ctx, seq)
+}
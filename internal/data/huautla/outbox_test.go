@@ -0,0 +1,84 @@
+package huautla
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/events"
+)
+
+func Test_pruneOutbox(t *testing.T) {
+	t.Parallel()
+
+	set := map[string]struct {
+		retention  time.Duration
+		cursor     uint64
+		noWebhooks bool
+		left       []uint64
+	}{
+		"forever": {
+			cursor: 5,
+			left:   []uint64{1, 2, 3, 4, 5},
+		},
+		"retention": {
+			retention: 48 * time.Hour,
+			cursor:    5,
+			left:      []uint64{4, 5},
+		},
+		"webhooks_behind": {
+			retention: 48 * time.Hour,
+			cursor:    2,
+			left:      []uint64{3, 4, 5},
+		},
+		"webhooks_never_ran": {
+			retention: 48 * time.Hour,
+			left:      []uint64{1, 2, 3, 4, 5},
+		},
+		"no_webhooks": {
+			retention:  48 * time.Hour,
+			noWebhooks: true,
+			left:       []uint64{4, 5},
+		},
+		"everything": {
+			retention: time.Minute,
+			cursor:    5,
+			left:      []uint64{},
+		},
+	}
+
+	for name, tc := range set {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			now := time.Now()
+
+			ha := newWebhookAdaptor(t)
+			ha.outboxRetention = tc.retention
+			for _, age := range []time.Duration{96 * time.Hour, 72 * time.Hour, 50 * time.Hour, 24 * time.Hour, time.Hour} {
+				require.Nil(t, ha.outbox.Publish(ctx, events.Event{Time: now.Add(-age)}))
+			}
+			if tc.cursor > 0 {
+				// where the dispatcher would have left it
+				require.Nil(t, ha.store.Put(ctx, "webhook_cursor", "seq", tc.cursor))
+			}
+			if tc.noWebhooks {
+				ha.webhooks = nil
+			}
+
+			require.Nil(t, ha.pruneOutbox(ctx, now))
+
+			evs, err := ha.outbox.Since(ctx, 0, 0)
+			require.Nil(t, err)
+			left := []uint64{}
+			for _, e := range evs {
+				left = append(left, e.Seq)
+			}
+			require.Equal(t, tc.left, left)
+		})
+	}
+}
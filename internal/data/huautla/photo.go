@@ -1,33 +1,65 @@
 package huautla
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"image"
 	"io"
 	"net/http"
 	"net/url"
+	"path"
+	"slices"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/jsmit257/centerforfunguscontrol/internal/exif"
+	"github.com/jsmit257/centerforfunguscontrol/internal/imaging"
+	"github.com/jsmit257/centerforfunguscontrol/internal/store"
 	"github.com/jsmit257/centerforfunguscontrol/shared/metrics"
 	"github.com/jsmit257/huautla/types"
 )
 
-func (ha *HuautlaAdaptor) writePhoto(r *http.Request) (string, error) {
+type (
+	// photoMeta is everything cffc knows about a photo that huautla doesn't
+	photoMeta struct {
+		Owner        types.UUID     `json:"owner"`
+		Exif         *exif.Metadata `json:"exif,omitempty"`
+		Colonization *colonization  `json:"colonization,omitempty"`
+		PHash        string         `json:"phash,omitempty"`
+		// near-duplicates with the same owner at the time of upload
+		Duplicates []types.UUID `json:"duplicates,omitempty"`
+	}
+
+	photo struct {
+		types.Photo
+		Meta *photoMeta `json:"meta,omitempty"`
+	}
+)
+
+const photoTable = "photos"
+
+// writePhoto saves the uploaded photo to the album, unless it looks too much
+// like one of the owner's other photos and duplicates are being rejected
+func (ha *HuautlaAdaptor) writePhoto(r *http.Request, oID types.UUID, others []types.Photo) (string, *photoMeta, error) {
 	var err error
 	var data []byte
 	var ct string
+	meta := &photoMeta{}
 
 	if err = r.ParseMultipartForm(1 << 16); err != nil {
-		return "", err
+		return "", nil, err
 	} else if f, fh, err := r.FormFile("file"); err != nil {
-		return "", err
+		return "", nil, err
 	} else if data, err = io.ReadAll(f); err != nil {
-		return "", err
+		return "", nil, err
 	} else if len(data) < 4 {
-		return "", fmt.Errorf("invalid request body")
+		return "", nil, fmt.Errorf("invalid request body")
 	} else {
 		ct = fh.Header.Get("Content-Type")
 	}
@@ -44,12 +76,42 @@ func (ha *HuautlaAdaptor) writePhoto(r *http.Request) (string, error) {
 		filetype = append(r.Header[http.CanonicalHeaderKey("Content-Type")], "image/x-unknown")[0]
 	}
 
-	r.Context().Value(metrics.Log).(*logrus.Entry).WithFields(log.Fields{
+	l := r.Context().Value(metrics.Log).(*logrus.Entry)
+
+	l.WithFields(log.Fields{
 		"from-request": ct,
 		"from-app":     filetype,
 	}).
 		Warn("comparing types")
 
+	if filetype != "image/jpeg" && filetype != "image/tiff" {
+	} else if meta.Exif, err = exif.Parse(data, time.Local); errors.Is(err, exif.ErrNoExif) {
+	} else if err != nil {
+		// a broken exif segment is no reason to refuse the photo
+		l.WithError(err).Warn("failed to parse exif")
+	}
+
+	// stripping doesn't care whether the rest of the exif made sense; if
+	// there's gps in there it goes, or the photo does
+	if !ha.stripGPS || (filetype != "image/jpeg" && filetype != "image/tiff") {
+	} else if data, err = exif.StripGPS(data); err != nil {
+		return "", nil, fmt.Errorf("failed to strip gps: %w", err)
+	} else if meta.Exif != nil {
+		meta.Exif.GPS = nil
+	}
+
+	if img, _, err := image.Decode(bytes.NewReader(data)); err != nil {
+		l.WithError(err).Debug("not analyzing undecodable photo")
+	} else {
+		meta.Colonization = ha.estimateColonization(r, oID, img, l)
+		hash := imaging.PHash(img)
+		meta.PHash = formatHash(hash)
+		meta.Duplicates = ha.duplicates(r.Context(), oID, others, hash, l)
+		if len(meta.Duplicates) > 0 && ha.rejectDuplicates {
+			return "", nil, fmt.Errorf("%w of %v", errDuplicatePhoto, meta.Duplicates)
+		}
+	}
+
 	ext := map[string]string{
 		"image/jpeg": "jpg",
 		"image/jpg":  "jpg",
@@ -63,7 +125,72 @@ func (ha *HuautlaAdaptor) writePhoto(r *http.Request) (string, error) {
 
 	name := fmt.Sprintf("%s.%s", uuid.New().String(), ext)
 
-	return name, ha.filer("album/"+name, data, 0644)
+	return name, meta, ha.filer(path.Join(ha.album, name), data, 0644)
+}
+
+// annotatePhoto remembers what we learned from the image itself, backdates the
+// photo to when it was taken, rather than when it was uploaded, and suggests
+// an event if the photo shows a colonization milestone. The photo's already
+// in the album and the database by now, so failing any of that is only
+// logged; an error would just have the client upload it again
+func (ha *HuautlaAdaptor) annotatePhoto(ctx context.Context, oID types.UUID, photos []types.Photo, filename string, meta *photoMeta, ms *methodStats) {
+	i := slices.IndexFunc(photos, func(p types.Photo) bool { return p.Filename == filename })
+	if i == -1 {
+		ms.l.WithField("filename", filename).Error("photo wasn't returned from the database, so it isn't annotated")
+		return
+	}
+	l := ms.l.WithField("photo", photos[i].UUID)
+
+	if md := meta.Exif; md != nil && md.Captured != nil {
+		ctime := md.Captured.UTC()
+		if err := ha.db.UpdateTimestamps(ctx, "photos", photos[i].UUID, types.Timestamp{
+			Fields: []string{"ctime"},
+			Origin: &ctime,
+		}); err != nil {
+			l.WithError(err).Error("failed to backdate photo, it keeps its upload time")
+		} else {
+			photos[i].CTime = ctime
+		}
+	}
+
+	if meta.Colonization != nil {
+		ha.suggestEventType(ctx, photos, photos[i], meta.Colonization, ms)
+	}
+
+	meta.Owner = oID
+
+	if err := ha.store.Put(ctx, photoTable, string(photos[i].UUID), meta); err != nil {
+		l.WithError(err).Error("failed to store photo metadata")
+	}
+}
+
+// forgetPhotos drops the metadata of photos that were deleted along with
+// whatever they belonged to; metadata that's left behind is only worth a log
+// message
+func (ha *HuautlaAdaptor) forgetPhotos(ctx context.Context, photos []types.Photo, ms *methodStats) {
+	for _, p := range photos {
+		if err := ha.store.Delete(ctx, photoTable, string(p.UUID)); err != nil && !errors.Is(err, store.ErrNotFound) {
+			ms.l.WithError(err).WithField("photo", p.UUID).Warn("failed to remove photo metadata")
+		}
+	}
+}
+
+// withMeta decorates photos with their metadata; photos that pre-date
+// metadata just don't get any
+func (ha *HuautlaAdaptor) withMeta(ctx context.Context, photos []types.Photo, ms *methodStats) []photo {
+	result := make([]photo, len(photos))
+	for i, p := range photos {
+		result[i].Photo = p
+
+		var meta photoMeta
+		if err := ha.store.Get(ctx, photoTable, string(p.UUID), &meta); errors.Is(err, store.ErrNotFound) {
+		} else if err != nil {
+			ms.l.WithError(err).WithField("photo", p.UUID).Warn("failed to fetch photo metadata")
+		} else {
+			result[i].Meta = &meta
+		}
+	}
+	return result
 }
 
 func (ha *HuautlaAdaptor) GetPhotos(w http.ResponseWriter, r *http.Request) {
@@ -75,7 +202,7 @@ func (ha *HuautlaAdaptor) GetPhotos(w http.ResponseWriter, r *http.Request) {
 	if _, photos, err := ha.getPhotos(w, r, ms); err != nil {
 		return
 	} else {
-		ms.send(w, http.StatusOK, photos)
+		ms.send(w, http.StatusOK, ha.withMeta(ctx, photos, ms))
 	}
 }
 
@@ -97,15 +224,20 @@ func (ha *HuautlaAdaptor) PostPhoto(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
 	var p types.Photo
+	var meta *photoMeta
 
 	if oID, photos, err := ha.getPhotos(w, r, ms); err != nil {
 		return
-	} else if p.Filename, err = ha.writePhoto(r); err != nil {
+	} else if p.Filename, meta, err = ha.writePhoto(r, types.UUID(oID), photos); errors.Is(err, errDuplicatePhoto) {
+		ms.error(w, err, http.StatusConflict, "photo is a near-duplicate")
+	} else if err != nil {
 		ms.error(w, err, http.StatusBadRequest, "couldn't read/write request body")
 	} else if photos, err = ha.db.AddPhoto(r.Context(), types.UUID(oID), photos, p, ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to add photo")
 	} else {
-		ms.send(w, http.StatusOK, photos)
+		ha.annotatePhoto(ctx, types.UUID(oID), photos, p.Filename, meta, ms)
+		ha.emit(r.Context(), ms, "photo.added", newest(photos).UUID, owned{types.UUID(oID), newest(photos)})
+		ms.send(w, http.StatusOK, ha.withMeta(ctx, photos, ms))
 	}
 }
 
@@ -115,17 +247,24 @@ func (ha *HuautlaAdaptor) PatchPhoto(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
 	var p types.Photo
+	var meta *photoMeta
 
-	if _, photos, err := ha.getPhotos(w, r, ms); err != nil {
+	if oID, photos, err := ha.getPhotos(w, r, ms); err != nil {
 		return
 	} else if p.UUID = types.UUID(chi.URLParam(r, "id")); p.UUID == "" {
 		ms.error(w, fmt.Errorf("missing required id parameter"), http.StatusBadRequest, "missing required id parameter")
-	} else if p.Filename, err = ha.writePhoto(r); err != nil {
+	} else if p.Filename, meta, err = ha.writePhoto(r, types.UUID(oID), slices.DeleteFunc(slices.Clone(photos), func(o types.Photo) bool {
+		return o.UUID == p.UUID
+	})); errors.Is(err, errDuplicatePhoto) {
+		ms.error(w, err, http.StatusConflict, "photo is a near-duplicate")
+	} else if err != nil {
 		ms.error(w, err, http.StatusBadRequest, "couldn't read request body")
 	} else if photos, err = ha.db.ChangePhoto(r.Context(), photos, p, ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to change photo")
 	} else {
-		ms.send(w, http.StatusOK, photos)
+		ha.annotatePhoto(ctx, types.UUID(oID), photos, p.Filename, meta, ms)
+		ha.emit(r.Context(), ms, "photo.changed", p.UUID, owned{types.UUID(oID), p})
+		ms.send(w, http.StatusOK, ha.withMeta(ctx, photos, ms))
 	}
 }
 
@@ -133,7 +272,7 @@ func (ha *HuautlaAdaptor) DeletePhoto(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	ms := ha.start(ctx, "DeletePhoto")
 
-	if _, photos, err := ha.getPhotos(w, r, ms); err != nil {
+	if oID, photos, err := ha.getPhotos(w, r, ms); err != nil {
 		return
 	} else if id := chi.URLParam(r, "id"); id == "" {
 		ms.error(w, fmt.Errorf("missing required id parameter"), http.StatusBadRequest, "missing required id parameter")
@@ -141,7 +280,10 @@ func (ha *HuautlaAdaptor) DeletePhoto(w http.ResponseWriter, r *http.Request) {
 		ms.error(w, fmt.Errorf("malformed id parameter"), http.StatusBadRequest, "malformed id parameter")
 	} else if photos, err = ha.db.RemovePhoto(r.Context(), photos, types.UUID(id), ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to remove photo")
+	} else if err = ha.store.Delete(ctx, photoTable, id); err != nil && !errors.Is(err, store.ErrNotFound) {
+		ms.error(w, err, http.StatusInternalServerError, "failed to remove photo metadata")
 	} else {
-		ms.send(w, http.StatusOK, photos)
+		ha.emit(r.Context(), ms, "photo.removed", types.UUID(id), owned{types.UUID(oID), nil})
+		ms.send(w, http.StatusOK, ha.withMeta(ctx, photos, ms))
 	}
 }
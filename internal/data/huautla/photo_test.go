@@ -3,17 +3,23 @@ package huautla
 import (
 	"bytes"
 	"context"
+	"database/sql"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/fs"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/require"
 
+	"github.com/jsmit257/centerforfunguscontrol/internal/exif"
+	"github.com/jsmit257/centerforfunguscontrol/internal/store"
 	"github.com/jsmit257/centerforfunguscontrol/shared/metrics"
 	"github.com/jsmit257/huautla/types"
 )
@@ -96,6 +102,7 @@ func Test_GetPhoto(t *testing.T) {
 					getErr: v.getErr,
 				},
 			},
+			store: store.NewMem(),
 		}
 		t.Run(k, func(t *testing.T) {
 			t.Parallel()
@@ -123,12 +130,30 @@ func Test_GetPhoto(t *testing.T) {
 func Test_PostPhoto(t *testing.T) {
 	t.Parallel()
 
+	sample, err := os.ReadFile("../../../tests/data/exif.jpg")
+	require.Nil(t, err)
+
+	// the exif directory points off the end of the file, so it can't be
+	// parsed, but the gps directory is still there to be stripped
+	broken := append([]byte{}, sample...)
+	i := bytes.Index(broken, []byte{0x69, 0x87, 0x04, 0x00})
+	require.NotEqual(t, -1, i)
+	copy(broken[i+8:], []byte{0xff, 0xff, 0xff, 0x00})
+
 	set := map[string]struct {
 		id       types.UUID
 		data     []byte
+		stripGPS bool
 		getErr   error
 		updErr   error
+		tsErr    error
 		writeErr error
+		ctime    time.Time
+		gps      bool
+		prior    float64
+		suggest  string
+		dupe     bool
+		reject   bool
 		sc       int
 	}{
 		"happy_path": {
@@ -136,6 +161,60 @@ func Test_PostPhoto(t *testing.T) {
 			data: []byte{0x89, 0x50, 0x4e, 0x47},
 			sc:   http.StatusOK,
 		},
+		"exif": {
+			id:      "exif",
+			data:    sample,
+			ctime:   time.Date(2024, 5, 6, 12, 8, 9, 0, time.UTC),
+			gps:     true,
+			suggest: "50% Colonization",
+			sc:      http.StatusOK,
+		},
+		"already_half_colonized": {
+			id:    "already_half_colonized",
+			data:  sample,
+			ctime: time.Date(2024, 5, 6, 12, 8, 9, 0, time.UTC),
+			gps:   true,
+			prior: 60,
+			sc:    http.StatusOK,
+		},
+		"strip_gps": {
+			id:       "strip_gps",
+			data:     sample,
+			stripGPS: true,
+			ctime:    time.Date(2024, 5, 6, 12, 8, 9, 0, time.UTC),
+			suggest:  "50% Colonization",
+			sc:       http.StatusOK,
+		},
+		"strip_gps_broken_exif": {
+			id:       "strip_gps_broken_exif",
+			data:     broken,
+			stripGPS: true,
+			sc:       http.StatusOK,
+		},
+		"duplicate_warning": {
+			id:      "duplicate_warning",
+			data:    sample,
+			ctime:   time.Date(2024, 5, 6, 12, 8, 9, 0, time.UTC),
+			gps:     true,
+			suggest: "50% Colonization",
+			dupe:    true,
+			sc:      http.StatusOK,
+		},
+		"duplicate_rejected": {
+			id:     "duplicate_rejected",
+			data:   sample,
+			dupe:   true,
+			reject: true,
+			sc:     http.StatusConflict,
+		},
+		"timestamp_error": {
+			id:    "timestamp_error",
+			data:  sample,
+			ctime: time.Date(2024, 5, 6, 12, 8, 9, 0, time.UTC),
+			gps:   true,
+			tsErr: fmt.Errorf("some error"),
+			sc:    http.StatusOK,
+		},
 		"missing_id": {
 			sc: http.StatusBadRequest,
 		},
@@ -168,16 +247,42 @@ func Test_PostPhoto(t *testing.T) {
 
 	for k, v := range set {
 		k, v := k, v
+		var written []byte
+		var prior, existing []types.Photo
+		if v.dupe {
+			existing = []types.Photo{{UUID: "dupe", Filename: "dupe.jpg"}}
+		}
+		st := store.NewMem()
+		if v.prior > 0 {
+			prior = []types.Photo{{UUID: "prior"}}
+			require.Nil(t, st.Put(context.Background(), photoTable, "prior", photoMeta{
+				Owner:        v.id,
+				Colonization: &colonization{Percent: v.prior},
+			}))
+		}
 		ha := &HuautlaAdaptor{
 			db: &huautlaMock{
+				Lifecycler: &lifecyclerMock{selectErr: sql.ErrNoRows},
 				Photoer: &photoerMock{
-					addErr: v.updErr,
-					getErr: v.getErr,
+					getResult: existing,
+					addResult: prior,
+					addErr:    v.updErr,
+					getErr:    v.getErr,
 				},
+				Timestamper: &mockTS{updErr: v.tsErr},
+				EventTyper:  &eventtyperMock{selectAllResult: colonizationEventTypes},
 			},
-			filer: func(string, []byte, fs.FileMode) error {
+			filer: func(_ string, data []byte, _ fs.FileMode) error {
+				written = data
 				return v.writeErr
 			},
+			reader: func(string) ([]byte, error) {
+				return sample, nil
+			},
+			store:             st,
+			stripGPS:          v.stripGPS,
+			duplicateDistance: 6,
+			rejectDuplicates:  v.reject,
 		}
 		t.Run(k, func(t *testing.T) {
 			t.Parallel()
@@ -188,6 +293,44 @@ func Test_PostPhoto(t *testing.T) {
 				chi.RouteParams{Keys: []string{"o_id"}, Values: []string{string(v.id)}})
 
 			require.Equal(t, v.sc, w.Code)
+			if v.sc != http.StatusOK {
+				return
+			}
+
+			var photos []photo
+			require.Nil(t, json.Unmarshal(w.Body.Bytes(), &photos))
+			require.Len(t, photos, len(prior)+1)
+			require.Equal(t, v.id, photos[0].Meta.Owner)
+			require.Equal(t, v.dupe, len(photos[0].Meta.Duplicates) > 0)
+			if v.stripGPS {
+				// stripping what's already been stripped changes nothing
+				stripped, err := exif.StripGPS(written)
+				require.Nil(t, err)
+				require.Equal(t, stripped, written)
+				require.NotEqual(t, v.data, written)
+			}
+			if v.ctime.IsZero() {
+				require.Nil(t, photos[0].Meta.Exif)
+				return
+			}
+			if v.tsErr != nil {
+				// the photo's there either way, it just isn't backdated
+				require.False(t, v.ctime.Equal(photos[0].CTime))
+				require.NotNil(t, photos[0].Meta.Exif)
+				return
+			}
+			require.True(t, v.ctime.Equal(photos[0].CTime))
+			require.Equal(t, 50.0, photos[0].Meta.Colonization.Percent)
+			if v.suggest == "" {
+				require.Nil(t, photos[0].Meta.Colonization.Suggested)
+			} else {
+				require.Equal(t, v.suggest, photos[0].Meta.Colonization.Suggested.Name)
+			}
+			require.Equal(t, v.gps, photos[0].Meta.Exif.GPS != nil)
+
+			md, err := exif.Parse(written, time.UTC)
+			require.Nil(t, err)
+			require.Equal(t, v.stripGPS, md.GPS == nil)
 		})
 	}
 }
@@ -244,14 +387,17 @@ func Test_PatchPhoto(t *testing.T) {
 		k, v := k, v
 		ha := &HuautlaAdaptor{
 			db: &huautlaMock{
+				Lifecycler: &lifecyclerMock{selectErr: sql.ErrNoRows},
 				Photoer: &photoerMock{
 					changeErr: v.updErr,
 					getErr:    v.getErr,
 				},
+				EventTyper: &eventtyperMock{selectAllResult: colonizationEventTypes},
 			},
 			filer: func(string, []byte, fs.FileMode) error {
 				return v.writeErr
 			},
+			store: store.NewMem(),
 		}
 		t.Run(k, func(t *testing.T) {
 			t.Parallel()
@@ -314,6 +460,7 @@ func Test_DeletePhoto(t *testing.T) {
 					getErr: v.getErr,
 				},
 			},
+			store: store.NewMem(),
 		}
 		t.Run(k, func(t *testing.T) {
 			t.Parallel()
@@ -345,12 +492,12 @@ func (pm *photoerMock) GetPhotos(context.Context, types.UUID, types.CID) ([]type
 	return pm.getResult, pm.getErr
 }
 
-func (pm *photoerMock) AddPhoto(context.Context, types.UUID, []types.Photo, types.Photo, types.CID) ([]types.Photo, error) {
-	return pm.addResult, pm.addErr
+func (pm *photoerMock) AddPhoto(_ context.Context, _ types.UUID, _ []types.Photo, p types.Photo, _ types.CID) ([]types.Photo, error) {
+	return append([]types.Photo{p}, pm.addResult...), pm.addErr
 }
 
-func (pm *photoerMock) ChangePhoto(context.Context, []types.Photo, types.Photo, types.CID) ([]types.Photo, error) {
-	return pm.changeResult, pm.changeErr
+func (pm *photoerMock) ChangePhoto(_ context.Context, _ []types.Photo, p types.Photo, _ types.CID) ([]types.Photo, error) {
+	return append([]types.Photo{p}, pm.changeResult...), pm.changeErr
 }
 
 func (pm *photoerMock) RemovePhoto(context.Context, []types.Photo, types.UUID, types.CID) ([]types.Photo, error) {
@@ -39,9 +39,10 @@ func (ha *HuautlaAdaptor) PostSource(w http.ResponseWriter, r *http.Request) {
 		ms.error(w, err, http.StatusBadRequest, "couldn't read request body")
 	} else if err = json.Unmarshal(body, &s); err != nil {
 		ms.error(w, err, http.StatusBadRequest, "couldn't unmarshal request body")
-	} else if _, err := ha.db.InsertSource(r.Context(), genID, origin, s, ms.cid); err != nil {
+	} else if created, err := ha.db.InsertSource(r.Context(), genID, origin, s, ms.cid); err != nil {
 		ms.error(w, fmt.Errorf("%w: %s", err, fmtSource(s)), http.StatusInternalServerError, err)
 	} else {
+		ha.emit(r.Context(), ms, "source.added", created.UUID, owned{genID, created})
 		ms.send(w, http.StatusCreated, s)
 	}
 }
@@ -53,7 +54,7 @@ func (ha *HuautlaAdaptor) PatchSource(w http.ResponseWriter, r *http.Request) {
 
 	var s types.Source
 
-	if _, err := getUUIDByName("g_id", w, r, ms); err != nil {
+	if gID, err := getUUIDByName("g_id", w, r, ms); err != nil {
 		ms.error(w, fmt.Errorf("%w: generation id", err), http.StatusBadRequest, err)
 	} else if origin := chi.URLParam(r, "origin"); origin == "" {
 		ms.error(w, fmt.Errorf("missing required parameter: origin"), http.StatusBadRequest, "missing required parameter")
@@ -70,6 +71,7 @@ func (ha *HuautlaAdaptor) PatchSource(w http.ResponseWriter, r *http.Request) {
 	} else if err := ha.db.UpdateSource(r.Context(), origin, s, ms.cid); err != nil {
 		ms.error(w, fmt.Errorf("%w: %s", err, fmtSource(s)), http.StatusInternalServerError, err)
 	} else {
+		ha.emit(r.Context(), ms, "source.changed", s.UUID, owned{gID, s})
 		ms.empty(w)
 	}
 }
@@ -91,6 +93,7 @@ func (ha *HuautlaAdaptor) DeleteSource(w http.ResponseWriter, r *http.Request) {
 	} else if err := ha.db.RemoveSource(r.Context(), &g, types.UUID(sID), ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to remove source")
 	} else {
+		ha.emit(r.Context(), ms, "source.removed", types.UUID(sID), owned{g.UUID, nil})
 		ms.send(w, http.StatusOK, g)
 	}
 }
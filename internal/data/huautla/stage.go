@@ -51,6 +51,7 @@ func (ha *HuautlaAdaptor) PostStage(w http.ResponseWriter, r *http.Request) {
 	} else if s, err = ha.db.InsertStage(r.Context(), s, ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to insert stage")
 	} else {
+		ha.emit(r.Context(), ms, "stage.created", s.UUID, s)
 		ms.send(w, http.StatusCreated, s)
 	}
 }
@@ -73,6 +74,7 @@ func (ha *HuautlaAdaptor) PatchStage(w http.ResponseWriter, r *http.Request) {
 	} else if err = ha.db.UpdateStage(r.Context(), types.UUID(id), s, ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to update stage")
 	} else {
+		ha.emit(r.Context(), ms, "stage.updated", types.UUID(id), s)
 		ms.send(w, http.StatusNoContent, nil)
 	}
 }
@@ -88,6 +90,7 @@ func (ha *HuautlaAdaptor) DeleteStage(w http.ResponseWriter, r *http.Request) {
 	} else if err := ha.db.DeleteStage(r.Context(), types.UUID(id), ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to delete stage")
 	} else {
+		ha.emit(r.Context(), ms, "stage.deleted", types.UUID(id), nil)
 		ms.send(w, http.StatusNoContent, nil)
 	}
 }
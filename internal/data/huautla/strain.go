@@ -9,6 +9,8 @@ import (
 	"net/url"
 
 	"github.com/go-chi/chi/v5"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/codes"
 	"github.com/jsmit257/huautla/types"
 )
 
@@ -19,7 +21,7 @@ func (ha *HuautlaAdaptor) GetAllStrains(w http.ResponseWriter, r *http.Request)
 	if Strains, err := ha.db.SelectAllStrains(r.Context(), ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to fetch strains")
 	} else {
-		ms.send(w, http.StatusOK, Strains)
+		ms.send(w, http.StatusOK, codedAll(ctx, ms, Strains, ha.codedStrain))
 	}
 }
 
@@ -34,7 +36,7 @@ func (ha *HuautlaAdaptor) GetStrain(w http.ResponseWriter, r *http.Request) {
 	} else if s, err := ha.db.SelectStrain(r.Context(), types.UUID(id), ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to fetch strain")
 	} else {
-		ms.send(w, http.StatusOK, s)
+		ms.send(w, http.StatusOK, ha.codedStrain(ctx, ms, s))
 	}
 }
 
@@ -52,7 +54,9 @@ func (ha *HuautlaAdaptor) PostStrain(w http.ResponseWriter, r *http.Request) {
 	} else if s, err = ha.db.InsertStrain(r.Context(), s, ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to insert strain")
 	} else {
-		ms.send(w, http.StatusCreated, s)
+		ha.assignCode(ctx, ms, codes.Strain, s.UUID)
+		ha.emit(r.Context(), ms, "strain.created", s.UUID, s)
+		ms.send(w, http.StatusCreated, ha.codedStrain(ctx, ms, s))
 	}
 }
 
@@ -74,6 +78,7 @@ func (ha *HuautlaAdaptor) PatchStrain(w http.ResponseWriter, r *http.Request) {
 	} else if err = ha.db.UpdateStrain(r.Context(), types.UUID(id), s, ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to update strain")
 	} else {
+		ha.emit(r.Context(), ms, "strain.updated", types.UUID(id), s)
 		ms.send(w, http.StatusNoContent, nil)
 	}
 }
@@ -89,6 +94,7 @@ func (ha *HuautlaAdaptor) DeleteStrain(w http.ResponseWriter, r *http.Request) {
 	} else if err := ha.db.DeleteStrain(r.Context(), types.UUID(id), ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to delete strain")
 	} else {
+		ha.emit(r.Context(), ms, "strain.deleted", types.UUID(id), nil)
 		ms.send(w, http.StatusNoContent, nil)
 	}
 }
@@ -106,7 +112,7 @@ func (ha *HuautlaAdaptor) GetGeneratedStrain(w http.ResponseWriter, r *http.Requ
 	} else if err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to fetch generations")
 	} else {
-		ms.send(w, http.StatusOK, s)
+		ms.send(w, http.StatusOK, ha.codedStrain(ctx, ms, s))
 	}
 }
 
@@ -137,7 +143,11 @@ func (ha *HuautlaAdaptor) updateGeneratedStrain(w http.ResponseWriter, r *http.R
 		ms.error(w, fmt.Errorf("malformed id parameter"), http.StatusBadRequest, "malformed id parameter")
 	} else if err := ha.db.UpdateGeneratedStrain(r.Context(), gid, types.UUID(sid), ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to update generation")
+	} else if gid == nil {
+		ha.emit(r.Context(), ms, "generatedstrain.removed", types.UUID(sid), nil)
+		ms.send(w, http.StatusNoContent, nil)
 	} else {
+		ha.emit(r.Context(), ms, "generatedstrain.changed", types.UUID(sid), owned{*gid, nil})
 		ms.send(w, http.StatusNoContent, nil)
 	}
 }
@@ -153,6 +163,6 @@ func (ha *HuautlaAdaptor) GetStrainReport(w http.ResponseWriter, r *http.Request
 	} else if s, err := ha.db.StrainReport(r.Context(), types.UUID(id), ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to fetch strain")
 	} else {
-		ms.send(w, http.StatusOK, s)
+		ms.send(w, http.StatusOK, ha.codedReport(ctx, ms, types.UUID(id), s))
 	}
 }
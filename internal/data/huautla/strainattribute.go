@@ -46,6 +46,7 @@ func (ha *HuautlaAdaptor) PostStrainAttribute(w http.ResponseWriter, r *http.Req
 	} else if a, err := ha.db.AddAttribute(r.Context(), &s, a, ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to add strainattribute")
 	} else {
+		ha.emit(r.Context(), ms, "strainattribute.added", a.UUID, owned{s.UUID, a})
 		ms.send(w, http.StatusCreated, a)
 	}
 }
@@ -74,7 +75,8 @@ func (ha *HuautlaAdaptor) PatchStrainAttribute(w http.ResponseWriter, r *http.Re
 	} else if err := ha.db.ChangeAttribute(r.Context(), &s, a, ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to change strainattribute")
 	} else {
-		ms.send(w, http.StatusOK, s)
+		ha.emit(r.Context(), ms, "strainattribute.changed", a.UUID, owned{s.UUID, a})
+		ms.send(w, http.StatusOK, ha.codedStrain(ctx, ms, s))
 	}
 }
 
@@ -95,6 +97,7 @@ func (ha *HuautlaAdaptor) DeleteStrainAttribute(w http.ResponseWriter, r *http.R
 	} else if err := ha.db.RemoveAttribute(r.Context(), &s, types.UUID(atID), ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to remove strainattribute")
 	} else {
-		ms.send(w, http.StatusOK, s)
+		ha.emit(r.Context(), ms, "strainattribute.removed", types.UUID(atID), owned{s.UUID, nil})
+		ms.send(w, http.StatusOK, ha.codedStrain(ctx, ms, s))
 	}
 }
@@ -0,0 +1,214 @@
+package huautla
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/events"
+	"github.com/jsmit257/centerforfunguscontrol/shared/metrics"
+	"github.com/jsmit257/huautla/types"
+)
+
+type streamFilter struct {
+	entities []string
+	ids      []types.UUID
+}
+
+const (
+	// how often to write something, so proxies don't decide the connection
+	// is idle and close it
+	streamKeepalive = 15 * time.Second
+
+	// how often to look in the outbox for events that were published by
+	// another process, like the kafka ingress, and never went by on our bus
+	streamPoll = time.Second
+
+	streamPath = "/stream"
+)
+
+// GetStream sends every event, as it happens, as server-sent events, until
+// the client goes away or the server stops. Each event's id is its place in
+// the outbox, so a client that reconnects with Last-Event-ID gets everything
+// it missed first. `?entity=` narrows it down to types of things, like
+// lifecycle or photo, and `?id=` to particular things, or things that belong
+// to them; both can be repeated or comma-separated
+func (ha *HuautlaAdaptor) GetStream(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ms := ha.start(ctx, "GetStream")
+
+	// a stream only ends when the client hangs up, so it needs a client on
+	// the other end of a connection, not a kafka command or a batch that's
+	// waiting for it to finish
+	f, ok := w.(http.Flusher)
+	if !ok {
+		ms.error(w, fmt.Errorf("streaming is not supported by %T", w), http.StatusInternalServerError, "streaming is not supported")
+		return
+	} else if ctx.Value(http.LocalAddrContextKey) == nil {
+		ms.error(w, fmt.Errorf("not a client connection"), http.StatusBadRequest, "streaming needs a client connection")
+		return
+	} else if ctx.Value(batchEventsKey{}) != nil {
+		ms.error(w, fmt.Errorf("streaming from a batch"), http.StatusBadRequest, "streams can't be batched")
+		return
+	}
+
+	newest, err := ha.outbox.Last(ctx)
+	if err != nil {
+		ms.error(w, err, http.StatusInternalServerError, "failed to fetch events")
+		return
+	}
+
+	last, err := lastEventID(r, newest)
+	if err != nil {
+		ms.error(w, err, http.StatusBadRequest, "malformed Last-Event-ID")
+		return
+	}
+
+	filter := newStreamFilter(r)
+
+	// subscribe before catching up, so nothing is published in between
+	live, unsubscribe := ha.bus.Subscribe(64)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	f.Flush()
+
+	ms.m.WithLabelValues(strconv.Itoa(http.StatusOK)).Inc()
+	defer ms.lap().l.Info("finished work")
+
+	keepalive := time.NewTicker(streamKeepalive)
+	defer keepalive.Stop()
+
+	poll := time.NewTicker(streamPoll)
+	defer poll.Stop()
+
+	// last is the newest event we've seen, whether or not it was sent; if
+	// anything newer turns up out of order, the bus dropped something and we
+	// fill in from the outbox
+	catchUp := func(until uint64) error {
+		missed, err := ha.outbox.Since(ctx, last, 0)
+		if err != nil {
+			return err
+		}
+		for _, e := range missed {
+			if e.Seq > until {
+				break
+			} else if err = filter.send(w, e); err != nil {
+				return err
+			}
+			last = e.Seq
+		}
+		f.Flush()
+		return nil
+	}
+
+	if newest, err = ha.outbox.Last(ctx); err != nil {
+		ms.lap().l.WithError(err).Error("failed to catch up")
+		return
+	} else if err = catchUp(newest); err != nil {
+		ms.lap().l.WithError(err).Error("failed to catch up")
+		return
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-metrics.GetContextStopping(ctx):
+			return
+		case <-keepalive.C:
+			if _, err = fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			f.Flush()
+		case <-poll.C:
+			if err = catchUp(math.MaxUint64); err != nil {
+				ms.lap().l.WithError(err).Error("failed to catch up")
+				return
+			}
+		case e, ok := <-live:
+			if !ok {
+				return
+			} else if e.Seq <= last {
+				continue
+			} else if e.Seq > last+1 {
+				err = catchUp(e.Seq)
+			} else if err = filter.send(w, e); err == nil {
+				last = e.Seq
+				f.Flush()
+			}
+			if err != nil {
+				ms.lap().l.WithError(err).Error("failed to send event")
+				return
+			}
+		}
+	}
+}
+
+// lastEventID is where to resume from; browsers send the header when they
+// reconnect, but anyone starting fresh can use `?last_event_id=` too, and
+// without either there's nothing to catch up on
+func lastEventID(r *http.Request, newest uint64) (uint64, error) {
+	id := r.Header.Get("Last-Event-ID")
+	if id == "" {
+		id = r.URL.Query().Get("last_event_id")
+	}
+	if id == "" {
+		return newest, nil
+	}
+	return strconv.ParseUint(id, 10, 64)
+}
+
+func newStreamFilter(r *http.Request) streamFilter {
+	var result streamFilter
+	for _, v := range r.URL.Query()["entity"] {
+		for _, e := range strings.Split(v, ",") {
+			if e = strings.TrimSpace(e); e != "" {
+				result.entities = append(result.entities, e)
+			}
+		}
+	}
+	for _, v := range r.URL.Query()["id"] {
+		for _, id := range strings.Split(v, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				result.ids = append(result.ids, types.UUID(id))
+			}
+		}
+	}
+	return result
+}
+
+func (sf streamFilter) matches(e events.Event) bool {
+	if len(sf.entities) > 0 && !slices.Contains(sf.entities, e.Entity()) {
+		return false
+	} else if len(sf.ids) == 0 || slices.Contains(sf.ids, e.EntityID) {
+		return true
+	}
+
+	var o owned
+	_ = json.Unmarshal(e.Payload, &o)
+	return o.Owner != "" && slices.Contains(sf.ids, o.Owner)
+}
+
+// send writes e if the client wants it
+func (sf streamFilter) send(w http.ResponseWriter, e events.Event) error {
+	if !sf.matches(e) {
+		return nil
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.Seq, e.Type, data)
+	return err
+}
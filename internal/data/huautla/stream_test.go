@@ -0,0 +1,296 @@
+package huautla
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/events"
+	"github.com/jsmit257/centerforfunguscontrol/internal/store"
+	"github.com/jsmit257/centerforfunguscontrol/shared/metrics"
+	"github.com/jsmit257/huautla/types"
+)
+
+type streamFixture struct {
+	ha       *HuautlaAdaptor
+	srv      *httptest.Server
+	stopping chan struct{}
+}
+
+func newStreamFixture(t *testing.T) *streamFixture {
+	outbox, err := events.NewOutbox(context.Background(), store.NewMem())
+	require.Nil(t, err)
+	bus := events.NewBus()
+
+	sf := &streamFixture{
+		ha: &HuautlaAdaptor{
+			events: events.NewPublisher(outbox, bus),
+			bus:    bus,
+			outbox: outbox,
+		},
+		stopping: make(chan struct{}),
+	}
+
+	r := chi.NewRouter()
+	r.Use(metrics.WrapContext(logrus.WithField("test", t.Name())))
+	r.Get("/stream", sf.ha.GetStream)
+	sf.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), metrics.Stopping, (<-chan struct{})(sf.stopping))))
+	}))
+	t.Cleanup(sf.srv.Close)
+
+	return sf
+}
+
+func (sf *streamFixture) publish(t *testing.T, typ string, id types.UUID, payload any) {
+	sf.publishTo(t, sf.ha.events, typ, id, payload)
+}
+
+// publishTo publishes to only p, like the outbox, which is all a process
+// that doesn't share our bus can do
+func (sf *streamFixture) publishTo(t *testing.T, p events.Sink, typ string, id types.UUID, payload any) {
+	data, err := json.Marshal(payload)
+	require.Nil(t, err)
+	require.Nil(t, p.Publish(context.Background(), events.Event{
+		Type:     typ,
+		EntityID: id,
+		Payload:  data,
+	}))
+}
+
+func (sf *streamFixture) connect(t *testing.T, query, lastEventID string) (*http.Response, *bufio.Reader) {
+	req, err := http.NewRequest(http.MethodGet, sf.srv.URL+"/stream"+query, nil)
+	require.Nil(t, err)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	resp, err := sf.srv.Client().Do(req)
+	require.Nil(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+
+	return resp, bufio.NewReader(resp.Body)
+}
+
+// readEvent returns the id and event fields of the next event in the stream
+func readEvent(t *testing.T, r *bufio.Reader) (id, typ string) {
+	result := make(chan [2]string)
+	go func() {
+		var fields [2]string
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				close(result)
+				return
+			} else if line = strings.TrimSuffix(line, "\n"); line == "" && fields[0] != "" {
+				result <- fields
+				return
+			} else if v, ok := strings.CutPrefix(line, "id: "); ok {
+				fields[0] = v
+			} else if v, ok := strings.CutPrefix(line, "event: "); ok {
+				fields[1] = v
+			}
+		}
+	}()
+
+	select {
+	case fields, ok := <-result:
+		require.True(t, ok, "stream ended")
+		return fields[0], fields[1]
+	case <-time.After(streamPoll + time.Second):
+		require.Fail(t, "timed out waiting for an event")
+	}
+	return "", ""
+}
+
+func Test_GetStream(t *testing.T) {
+	t.Parallel()
+
+	type sent struct {
+		typ     string
+		id      types.UUID
+		payload any
+		// published by some other process, so it's only in the outbox
+		elsewhere bool
+	}
+
+	tcs := map[string]struct {
+		query       string
+		lastEventID string
+		before      []sent
+		after       []sent
+		want        []string
+	}{
+		"live": {
+			after: []sent{
+				{typ: "vendor.created", id: "0"},
+				{typ: "lifecycle.created", id: "1"},
+			},
+			want: []string{"1 vendor.created", "2 lifecycle.created"},
+		},
+		"by_entity": {
+			query: "?entity=lifecycle,photo",
+			after: []sent{
+				{typ: "vendor.created", id: "0"},
+				{typ: "lifecycle.created", id: "1"},
+				{typ: "photo.added", id: "2", payload: owned{Owner: "1"}},
+			},
+			want: []string{"2 lifecycle.created", "3 photo.added"},
+		},
+		"by_id_and_owner": {
+			query: "?id=1",
+			after: []sent{
+				{typ: "lifecycle.created", id: "0"},
+				{typ: "lifecycle.created", id: "1"},
+				{typ: "event.added", id: "2", payload: owned{Owner: "0"}},
+				{typ: "event.added", id: "3", payload: owned{Owner: "1"}},
+			},
+			want: []string{"2 lifecycle.created", "4 event.added"},
+		},
+		"resume": {
+			lastEventID: "1",
+			before: []sent{
+				{typ: "vendor.created", id: "0"},
+				{typ: "vendor.updated", id: "0"},
+				{typ: "vendor.deleted", id: "0"},
+			},
+			after: []sent{
+				{typ: "stage.created", id: "1"},
+			},
+			want: []string{"2 vendor.updated", "3 vendor.deleted", "4 stage.created"},
+		},
+		"resume_from_query": {
+			query: "?last_event_id=0",
+			before: []sent{
+				{typ: "vendor.created", id: "0"},
+			},
+			want: []string{"1 vendor.created"},
+		},
+		"another_process": {
+			after: []sent{
+				{typ: "vendor.created", id: "0", elsewhere: true},
+				{typ: "vendor.updated", id: "0", elsewhere: true},
+			},
+			want: []string{"1 vendor.created", "2 vendor.updated"},
+		},
+		"no_resume": {
+			before: []sent{
+				{typ: "vendor.created", id: "0"},
+			},
+			after: []sent{
+				{typ: "vendor.deleted", id: "0"},
+			},
+			want: []string{"2 vendor.deleted"},
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			sf := newStreamFixture(t)
+			for _, s := range tc.before {
+				sf.publish(t, s.typ, s.id, s.payload)
+			}
+
+			resp, r := sf.connect(t, tc.query, tc.lastEventID)
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+			for _, s := range tc.after {
+				if s.elsewhere {
+					sf.publishTo(t, sf.ha.outbox, s.typ, s.id, s.payload)
+				} else {
+					sf.publish(t, s.typ, s.id, s.payload)
+				}
+			}
+
+			got := []string{}
+			for range tc.want {
+				id, typ := readEvent(t, r)
+				got = append(got, id+" "+typ)
+			}
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func Test_GetStreamStops(t *testing.T) {
+	t.Parallel()
+
+	sf := newStreamFixture(t)
+	resp, r := sf.connect(t, "", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	sf.publish(t, "vendor.created", "0", nil)
+	id, _ := readEvent(t, r)
+	require.Equal(t, "1", id)
+
+	close(sf.stopping)
+
+	done := make(chan error)
+	go func() {
+		_, err := io.ReadAll(r)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		require.Nil(t, err)
+	case <-time.After(time.Second):
+		require.Fail(t, "stream didn't stop")
+	}
+}
+
+func Test_GetStreamMalformedLastEventID(t *testing.T) {
+	t.Parallel()
+
+	sf := newStreamFixture(t)
+	resp, _ := sf.connect(t, "", "nope")
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+// a kafka command or a batch would wait forever for a stream to finish
+func Test_GetStreamNoClient(t *testing.T) {
+	t.Parallel()
+
+	sf := newStreamFixture(t)
+	mux := chi.NewRouter()
+	mux.Use(metrics.WrapContext(logrus.WithField("test", t.Name())))
+	mux.Get("/stream", sf.ha.GetStream)
+
+	tcs := map[string]struct {
+		ctx context.Context
+	}{
+		"no_connection": {
+			ctx: context.Background(),
+		},
+		"batched": {
+			ctx: context.WithValue(
+				context.WithValue(context.Background(), http.LocalAddrContextKey, nil),
+				batchEventsKey{},
+				&batchEvents{}),
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stream", nil).WithContext(tc.ctx))
+			require.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+}
@@ -51,6 +51,7 @@ func (ha *HuautlaAdaptor) PostSubstrate(w http.ResponseWriter, r *http.Request)
 	} else if s, err = ha.db.InsertSubstrate(r.Context(), s, ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to insert substrate")
 	} else {
+		ha.emit(r.Context(), ms, "substrate.created", s.UUID, s)
 		ms.send(w, http.StatusCreated, s)
 	}
 }
@@ -73,6 +74,7 @@ func (ha *HuautlaAdaptor) PatchSubstrate(w http.ResponseWriter, r *http.Request)
 	} else if err = ha.db.UpdateSubstrate(r.Context(), types.UUID(id), s, ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to update substrate")
 	} else {
+		ha.emit(r.Context(), ms, "substrate.updated", types.UUID(id), s)
 		ms.send(w, http.StatusNoContent, nil)
 	}
 }
@@ -88,6 +90,7 @@ func (ha *HuautlaAdaptor) DeleteSubstrate(w http.ResponseWriter, r *http.Request
 	} else if err := ha.db.DeleteSubstrate(r.Context(), types.UUID(id), ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to delete substrate")
 	} else {
+		ha.emit(r.Context(), ms, "substrate.deleted", types.UUID(id), nil)
 		ms.send(w, http.StatusNoContent, nil)
 	}
 }
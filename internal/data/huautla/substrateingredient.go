@@ -31,6 +31,7 @@ func (ha *HuautlaAdaptor) PostSubstrateIngredient(w http.ResponseWriter, r *http
 	} else if err = ha.db.AddIngredient(r.Context(), &s, i, ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to add substrateingredient")
 	} else {
+		ha.emit(r.Context(), ms, "substrateingredient.added", i.UUID, owned{s.UUID, i})
 		ms.send(w, http.StatusCreated, s)
 	}
 
@@ -60,6 +61,7 @@ func (ha *HuautlaAdaptor) PatchSubstrateIngredient(w http.ResponseWriter, r *htt
 	} else if err = ha.db.ChangeIngredient(r.Context(), &s, types.Ingredient{UUID: types.UUID(igID)}, newI, ms.cid); err != nil {
 		ms.error(w, fmt.Errorf("igID: '%s', newI: '%#q' sub: [%#q] %w", igID, newI, s, err), http.StatusInternalServerError, "failed to change substrateingredient")
 	} else {
+		ha.emit(r.Context(), ms, "substrateingredient.changed", types.UUID(igID), owned{s.UUID, newI})
 		ms.send(w, http.StatusOK, s)
 	}
 }
@@ -82,6 +84,7 @@ func (ha *HuautlaAdaptor) DeleteSubstrateIngredient(w http.ResponseWriter, r *ht
 	} else if err = ha.db.RemoveIngredient(r.Context(), &s, types.Ingredient{UUID: types.UUID(igID)}, ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to remove substrateingredient")
 	} else {
+		ha.emit(r.Context(), ms, "substrateingredient.removed", types.UUID(igID), owned{s.UUID, nil})
 		ms.send(w, http.StatusOK, s)
 	}
 }
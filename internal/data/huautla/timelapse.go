@@ -0,0 +1,247 @@
+package huautla
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"image"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/imaging"
+	"github.com/jsmit257/huautla/types"
+)
+
+type (
+	timelapse struct {
+		key string
+		// fingerprint of the photos that went into data; when it doesn't
+		// match the current photos anymore, data is stale
+		sig  [sha256.Size]byte
+		data []byte
+	}
+
+	// timelapseCache keeps the most recently used renderings, up to max
+	// bytes of them altogether
+	timelapseCache struct {
+		mtx  sync.Mutex
+		max  int64
+		size int64
+		// of *timelapse, most recently used first
+		order *list.List
+		byKey map[string]*list.Element
+	}
+)
+
+// defaultTimelapseCache is the limit for caches nobody gave one to
+const defaultTimelapseCache = 64 << 20
+
+var errNoUsablePhotos = errors.New("none of the lifecycle's photos could be read")
+
+func (ha *HuautlaAdaptor) GetLifecycleTimelapse(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ms := ha.start(ctx, "GetLifecycleTimelapse")
+
+	if id, err := getUUIDByName("id", w, r, ms); err != nil {
+		ms.error(w, err, http.StatusBadRequest, "failed to fetch uuid")
+	} else if opts, err := timelapseOptions(r.URL.Query()); err != nil {
+		ms.error(w, err, http.StatusBadRequest, "invalid timelapse options")
+	} else if l, err := ha.db.SelectLifecycle(ctx, id, ms.cid); errors.Is(err, sql.ErrNoRows) {
+		ms.error(w, err, http.StatusBadRequest, "failed to fetch lifecycle")
+	} else if err != nil {
+		ms.error(w, err, http.StatusInternalServerError, "failed to fetch lifecycle")
+	} else if photos, err := ha.lifecyclePhotos(ctx, l, ms); err != nil {
+		ms.error(w, err, http.StatusInternalServerError, "failed to fetch photos")
+	} else if len(photos) == 0 {
+		ms.error(w, fmt.Errorf("lifecycle has no photos"), http.StatusNotFound, "lifecycle has no photos")
+	} else if data, err := ha.timelapse(id, photos, opts, ms); errors.Is(err, errNoUsablePhotos) {
+		ms.error(w, err, http.StatusNotFound, "no usable photos were found")
+	} else if err != nil {
+		ms.error(w, err, http.StatusInternalServerError, "failed to render timelapse")
+	} else {
+		ms.write(w, http.StatusOK, imaging.ContentTypes[opts.Format], data)
+	}
+}
+
+func timelapseOptions(q url.Values) (imaging.AnimationOptions, error) {
+	result := imaging.AnimationOptions{
+		Format:  imaging.GIF,
+		Delay:   500 * time.Millisecond,
+		Width:   640,
+		Overlay: true,
+	}
+
+	if f := q.Get("format"); f == "" {
+	} else if _, ok := imaging.ContentTypes[f]; !ok {
+		return result, fmt.Errorf("unsupported format: %q", f)
+	} else {
+		result.Format = f
+	}
+
+	for name, dst := range map[string]*int{"width": &result.Width, "height": &result.Height} {
+		if v := q.Get(name); v == "" {
+		} else if n, err := strconv.Atoi(v); err != nil || n < 0 || n > 4096 {
+			return result, fmt.Errorf("%s must be between 0 and 4096: %q", name, v)
+		} else {
+			*dst = n
+		}
+	}
+	if q.Has("height") && !q.Has("width") {
+		result.Width = 0
+	}
+
+	if v := q.Get("delay"); v == "" {
+	} else if ms, err := strconv.Atoi(v); err != nil || ms < 10 || ms > 60000 {
+		return result, fmt.Errorf("delay must be between 10 and 60000 milliseconds: %q", v)
+	} else {
+		result.Delay = time.Duration(ms) * time.Millisecond
+	}
+
+	if v := q.Get("overlay"); v == "" {
+	} else if b, err := strconv.ParseBool(v); err != nil {
+		return result, fmt.Errorf("overlay must be a boolean: %q", v)
+	} else {
+		result.Overlay = b
+	}
+
+	return result, nil
+}
+
+// lifecyclePhotos collects the photos attached to a lifecycle and to each of
+// its events, in the order they were taken
+func (ha *HuautlaAdaptor) lifecyclePhotos(ctx context.Context, l types.Lifecycle, ms *methodStats) ([]types.Photo, error) {
+	owners := []types.UUID{l.UUID}
+	for _, e := range l.Events {
+		owners = append(owners, e.UUID)
+	}
+
+	seen := map[types.UUID]struct{}{}
+	var result []types.Photo
+	for _, o := range owners {
+		photos, err := ha.db.GetPhotos(ctx, o, ms.cid)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range photos {
+			if _, ok := seen[p.UUID]; !ok {
+				seen[p.UUID] = struct{}{}
+				result = append(result, p)
+			}
+		}
+	}
+
+	slices.SortStableFunc(result, func(a, b types.Photo) int {
+		return a.CTime.Compare(b.CTime)
+	})
+
+	return result, nil
+}
+
+// timelapse renders the photos, or returns the last rendering if none of
+// them have changed since
+func (ha *HuautlaAdaptor) timelapse(id types.UUID, photos []types.Photo, opts imaging.AnimationOptions, ms *methodStats) ([]byte, error) {
+	key := fmt.Sprintf("%s %+v", id, opts)
+
+	h := sha256.New()
+	for _, p := range photos {
+		fmt.Fprintf(h, "%s %s %d %d\n", p.UUID, p.Filename, p.MTime.UnixNano(), p.CTime.UnixNano())
+	}
+	var sig [sha256.Size]byte
+	copy(sig[:], h.Sum(nil))
+
+	if data, ok := ha.timelapses.get(key, sig); ok {
+		ms.l.Debug("using cached timelapse")
+		return data, nil
+	}
+
+	// each photo is shrunk to the animation's size as soon as it's decoded,
+	// otherwise a long lifecycle means holding every full size photo at once
+	var size image.Rectangle
+	frames := make([]imaging.Frame, 0, len(photos))
+	for _, p := range photos {
+		l := ms.l.WithField("photo", p.UUID)
+		if data, err := ha.reader(path.Join(ha.album, p.Filename)); err != nil {
+			l.WithError(err).Warn("skipping unreadable photo")
+		} else if img, _, err := image.Decode(bytes.NewReader(data)); err != nil {
+			l.WithError(err).Warn("skipping undecodable photo")
+		} else {
+			if len(frames) == 0 {
+				size = imaging.FrameSize(img, opts)
+			}
+			frames = append(frames, imaging.Frame{
+				Image: imaging.Fit(img, size.Dx(), size.Dy()),
+				Time:  p.CTime,
+			})
+		}
+	}
+	if len(frames) == 0 {
+		return nil, errNoUsablePhotos
+	}
+
+	b := &bytes.Buffer{}
+	if err := imaging.Animate(b, frames, opts); err != nil {
+		return nil, err
+	}
+
+	ha.timelapses.put(&timelapse{key: key, sig: sig, data: b.Bytes()})
+
+	return b.Bytes(), nil
+}
+
+// get is the rendering for key, as long as it was made from the same photos
+func (c *timelapseCache) get(key string, sig [sha256.Size]byte) ([]byte, bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if e, ok := c.byKey[key]; !ok {
+		return nil, false
+	} else if tl := e.Value.(*timelapse); tl.sig != sig {
+		return nil, false
+	} else {
+		c.order.MoveToFront(e)
+		return tl.data, true
+	}
+}
+
+// put replaces whatever was cached for tl.key, and forgets the least recently
+// used renderings until everything fits again; a rendering that's bigger than
+// the whole cache isn't kept at all
+func (c *timelapseCache) put(tl *timelapse) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.order == nil {
+		c.order, c.byKey = list.New(), map[string]*list.Element{}
+	}
+	if c.max == 0 {
+		c.max = defaultTimelapseCache
+	}
+
+	if e, ok := c.byKey[tl.key]; ok {
+		c.remove(e)
+	}
+	if int64(len(tl.data)) > c.max {
+		return
+	}
+
+	c.byKey[tl.key] = c.order.PushFront(tl)
+	c.size += int64(len(tl.data))
+	for c.size > c.max {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *timelapseCache) remove(e *list.Element) {
+	tl := c.order.Remove(e).(*timelapse)
+	delete(c.byKey, tl.key)
+	c.size -= int64(len(tl.data))
+}
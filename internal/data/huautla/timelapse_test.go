@@ -0,0 +1,264 @@
+package huautla
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"image/gif"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/centerforfunguscontrol/shared/metrics"
+	"github.com/jsmit257/huautla/types"
+)
+
+func Test_GetLifecycleTimelapse(t *testing.T) {
+	t.Parallel()
+
+	sample, err := os.ReadFile("../../../tests/data/exif.jpg")
+	require.Nil(t, err)
+
+	epoch := time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC)
+	photos := []types.Photo{
+		{UUID: "2", Filename: "2.jpg", CTime: epoch.Add(time.Hour)},
+		{UUID: "1", Filename: "1.jpg", CTime: epoch},
+		{UUID: "3", Filename: "3.txt", CTime: epoch.Add(2 * time.Hour)},
+	}
+
+	tcs := map[string]struct {
+		id        types.UUID
+		query     string
+		lc        types.Lifecycle
+		selectErr error
+		photos    []types.Photo
+		getErr    error
+		readErr   error
+		ct        string
+		frames    int
+		sc        int
+	}{
+		"happy_path": {
+			id:     "happy_path",
+			query:  "?width=16&delay=100",
+			lc:     types.Lifecycle{UUID: "happy_path", Events: []types.Event{{UUID: "0"}}},
+			photos: photos,
+			ct:     "image/gif",
+			frames: 2,
+			sc:     http.StatusOK,
+		},
+		"apng": {
+			id:     "apng",
+			query:  "?format=apng&height=16&overlay=false",
+			lc:     types.Lifecycle{UUID: "apng"},
+			photos: photos,
+			ct:     "image/apng",
+			sc:     http.StatusOK,
+		},
+		"missing_id": {
+			sc: http.StatusBadRequest,
+		},
+		"bad_format": {
+			id:    "bad_format",
+			query: "?format=webp",
+			sc:    http.StatusBadRequest,
+		},
+		"bad_width": {
+			id:    "bad_width",
+			query: "?width=-1",
+			sc:    http.StatusBadRequest,
+		},
+		"bad_delay": {
+			id:    "bad_delay",
+			query: "?delay=fast",
+			sc:    http.StatusBadRequest,
+		},
+		"bad_overlay": {
+			id:    "bad_overlay",
+			query: "?overlay=sometimes",
+			sc:    http.StatusBadRequest,
+		},
+		"no_rows": {
+			id:        "no_rows",
+			selectErr: sql.ErrNoRows,
+			sc:        http.StatusBadRequest,
+		},
+		"select_error": {
+			id:        "select_error",
+			selectErr: fmt.Errorf("some error"),
+			sc:        http.StatusInternalServerError,
+		},
+		"photos_error": {
+			id:     "photos_error",
+			getErr: fmt.Errorf("some error"),
+			sc:     http.StatusInternalServerError,
+		},
+		"no_photos": {
+			id: "no_photos",
+			sc: http.StatusNotFound,
+		},
+		"unreadable_photos": {
+			id:      "unreadable_photos",
+			photos:  photos,
+			readErr: fmt.Errorf("some error"),
+			sc:      http.StatusNotFound,
+		},
+		"undecodable_photos": {
+			id:     "undecodable_photos",
+			photos: photos[2:],
+			sc:     http.StatusNotFound,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			reads := &atomic.Int32{}
+			ha := &HuautlaAdaptor{
+				db: &huautlaMock{
+					Lifecycler: &lifecyclerMock{
+						selectResult: tc.lc,
+						selectErr:    tc.selectErr,
+					},
+					Photoer: &photoerMock{
+						getResult: tc.photos,
+						getErr:    tc.getErr,
+					},
+				},
+				reader: func(name string) ([]byte, error) {
+					reads.Add(1)
+					if name == "3.txt" {
+						return []byte("not a photo"), tc.readErr
+					}
+					return sample, tc.readErr
+				},
+			}
+
+			get := func() *httptest.ResponseRecorder {
+				w := httptest.NewRecorder()
+				rctx := chi.NewRouteContext()
+				rctx.URLParams = chi.RouteParams{Keys: []string{"id"}, Values: []string{string(tc.id)}}
+				r, _ := http.NewRequestWithContext(
+					context.WithValue(
+						metrics.MockServiceContext,
+						chi.RouteCtxKey,
+						rctx),
+					http.MethodGet,
+					"/lifecycle/"+string(tc.id)+"/timelapse"+tc.query,
+					nil)
+				ha.GetLifecycleTimelapse(w, r)
+				return w
+			}
+
+			w := get()
+			require.Equal(t, tc.sc, w.Code, w.Body.String())
+			if tc.sc != http.StatusOK {
+				return
+			}
+			require.Equal(t, tc.ct, w.Header().Get("Content-Type"))
+
+			if tc.frames > 0 {
+				anim, err := gif.DecodeAll(bytes.NewReader(w.Body.Bytes()))
+				require.Nil(t, err)
+				require.Len(t, anim.Image, tc.frames)
+				require.Equal(t, 10, anim.Delay[0])
+			}
+
+			// nothing changed, so nothing gets read the second time
+			before := reads.Load()
+			again := get()
+			require.Equal(t, w.Body.Bytes(), again.Body.Bytes())
+			require.Equal(t, before, reads.Load())
+		})
+	}
+}
+
+func Test_timelapseCache(t *testing.T) {
+	t.Parallel()
+
+	sig := func(s string) [32]byte { return [32]byte{s[0]} }
+	put := func(key string, size int) *timelapse {
+		return &timelapse{key: key, sig: sig(key), data: bytes.Repeat([]byte{key[0]}, size)}
+	}
+
+	tcs := map[string]struct {
+		max  int64
+		puts []*timelapse
+		// looked up in order after puts, and then there's more to put
+		gets []string
+		then []*timelapse
+		kept []string
+	}{
+		"everything_fits": {
+			max:  10,
+			puts: []*timelapse{put("a", 3), put("b", 3), put("c", 3)},
+			kept: []string{"a", "b", "c"},
+		},
+		"oldest_goes": {
+			max:  10,
+			puts: []*timelapse{put("a", 4), put("b", 4), put("c", 4)},
+			kept: []string{"b", "c"},
+		},
+		"least_recently_used_goes": {
+			max:  10,
+			puts: []*timelapse{put("a", 4), put("b", 4)},
+			gets: []string{"a"},
+			then: []*timelapse{put("c", 4)},
+			kept: []string{"a", "c"},
+		},
+		"too_big": {
+			max:  10,
+			puts: []*timelapse{put("a", 4), put("b", 11)},
+			kept: []string{"a"},
+		},
+		"replaced": {
+			max:  10,
+			puts: []*timelapse{put("a", 4), put("b", 4), put("a", 6)},
+			kept: []string{"a", "b"},
+		},
+		"default_max": {
+			puts: []*timelapse{put("a", defaultTimelapseCache), put("b", 1)},
+			kept: []string{"b"},
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := &timelapseCache{max: tc.max}
+			for _, tl := range tc.puts {
+				c.put(tl)
+			}
+			for _, key := range tc.gets {
+				_, ok := c.get(key, sig(key))
+				require.True(t, ok, key)
+			}
+			for _, tl := range tc.then {
+				c.put(tl)
+			}
+
+			var size int64
+			for _, key := range []string{"a", "b", "c"} {
+				data, ok := c.get(key, sig(key))
+				require.Equal(t, slices.Contains(tc.kept, key), ok, key)
+				size += int64(len(data))
+			}
+			require.Equal(t, size, c.size)
+
+			_, ok := c.get("a", sig("z"))
+			require.False(t, ok, "a stale rendering")
+		})
+	}
+}
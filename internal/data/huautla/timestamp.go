@@ -33,6 +33,7 @@ func (ha *HuautlaAdaptor) PatchTS(w http.ResponseWriter, r *http.Request) {
 	} else if err := ha.db.UpdateTimestamps(r.Context(), table, types.UUID(id), patch); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to delete vendor")
 	} else {
+		ha.emit(r.Context(), ms, "timestamp.changed", types.UUID(id), retimed{table, patch})
 		ms.send(w, http.StatusNoContent, nil)
 	}
 }
@@ -52,6 +53,7 @@ func (ha *HuautlaAdaptor) Undel(w http.ResponseWriter, r *http.Request) {
 	} else if err := ha.db.Undelete(r.Context(), table, types.UUID(id)); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to undelete row")
 	} else {
+		ha.emit(r.Context(), ms, table+".undeleted", types.UUID(id), nil)
 		ms.send(w, http.StatusNoContent, nil)
 	}
 }
@@ -52,9 +52,10 @@ func (ha *HuautlaAdaptor) PostVendor(w http.ResponseWriter, r *http.Request) {
 		ms.error(w, err, http.StatusBadRequest, "couldn't unmarshal request body") // XXX: better status code??
 	} else if v, err = ha.db.InsertVendor(r.Context(), v, ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to insert vendor")
+	} else {
+		ha.emit(r.Context(), ms, "vendor.created", v.UUID, v)
+		ms.send(w, http.StatusCreated, v)
 	}
-
-	ms.send(w, http.StatusCreated, v)
 }
 
 func (ha *HuautlaAdaptor) PatchVendor(w http.ResponseWriter, r *http.Request) {
@@ -74,9 +75,10 @@ func (ha *HuautlaAdaptor) PatchVendor(w http.ResponseWriter, r *http.Request) {
 		ms.error(w, err, http.StatusBadRequest, "couldn't unmarshal request body") // XXX: better status code??
 	} else if err = ha.db.UpdateVendor(r.Context(), types.UUID(id), v, ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to update vendor")
+	} else {
+		ha.emit(r.Context(), ms, "vendor.updated", types.UUID(id), v)
+		ms.send(w, http.StatusNoContent, nil)
 	}
-
-	ms.send(w, http.StatusNoContent, nil)
 }
 
 func (ha *HuautlaAdaptor) DeleteVendor(w http.ResponseWriter, r *http.Request) {
@@ -90,6 +92,7 @@ func (ha *HuautlaAdaptor) DeleteVendor(w http.ResponseWriter, r *http.Request) {
 	} else if err := ha.db.DeleteVendor(r.Context(), types.UUID(id), ms.cid); err != nil {
 		ms.error(w, err, http.StatusInternalServerError, "failed to delete vendor")
 	} else {
+		ha.emit(r.Context(), ms, "vendor.deleted", types.UUID(id), nil)
 		ms.send(w, http.StatusNoContent, nil)
 	}
 }
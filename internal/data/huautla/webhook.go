@@ -0,0 +1,165 @@
+package huautla
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/webhooks"
+)
+
+func (ha *HuautlaAdaptor) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ms := ha.start(ctx, "GetWebhooks")
+
+	if hooks, err := ha.webhooks.Hooks(ctx); err != nil {
+		ms.error(w, err, http.StatusInternalServerError, "failed to fetch webhooks")
+	} else {
+		for i := range hooks {
+			hooks[i] = hooks[i].Redacted()
+		}
+		ms.send(w, http.StatusOK, hooks)
+	}
+}
+
+func (ha *HuautlaAdaptor) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ms := ha.start(ctx, "GetWebhook")
+
+	if id, err := getUUIDByName("id", w, r, ms); err != nil {
+		ms.error(w, err, http.StatusBadRequest, "failed to fetch uuid")
+	} else if h, err := ha.webhooks.Hook(ctx, id); err != nil {
+		ms.error(w, err, webhookStatus(err), "failed to fetch webhook")
+	} else {
+		ms.send(w, http.StatusOK, h.Redacted())
+	}
+}
+
+// PostWebhook is the only time the secret is sent back, whether the client
+// chose it or we did
+func (ha *HuautlaAdaptor) PostWebhook(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ms := ha.start(ctx, "PostWebhook")
+	defer r.Body.Close()
+
+	var h webhooks.Hook
+
+	if body, err := io.ReadAll(r.Body); err != nil {
+		ms.error(w, err, http.StatusBadRequest, "couldn't read request body")
+	} else if err := json.Unmarshal(body, &h); err != nil {
+		ms.error(w, err, http.StatusBadRequest, "couldn't unmarshal request body")
+	} else if h.UUID != "" {
+		ms.error(w, fmt.Errorf("webhook ids are assigned by the server"), http.StatusBadRequest, "unexpected id")
+	} else if h, err = ha.webhooks.PutHook(ctx, h); err != nil {
+		ms.error(w, err, webhookStatus(err), "failed to store webhook")
+	} else {
+		ms.send(w, http.StatusCreated, h)
+	}
+}
+
+func (ha *HuautlaAdaptor) PatchWebhook(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ms := ha.start(ctx, "PatchWebhook")
+	defer r.Body.Close()
+
+	if id, err := getUUIDByName("id", w, r, ms); err != nil {
+		ms.error(w, err, http.StatusBadRequest, "failed to fetch uuid")
+	} else if h, err := ha.webhooks.Hook(ctx, id); err != nil {
+		ms.error(w, err, webhookStatus(err), "failed to fetch webhook")
+	} else if body, err := io.ReadAll(r.Body); err != nil {
+		ms.error(w, err, http.StatusBadRequest, "couldn't read request body")
+	} else if err := json.Unmarshal(body, &h); err != nil {
+		ms.error(w, err, http.StatusBadRequest, "couldn't unmarshal request body")
+	} else if h.UUID != id {
+		ms.error(w, fmt.Errorf("webhook ids can't be changed"), http.StatusBadRequest, "unexpected id")
+	} else if h, err = ha.webhooks.PutHook(ctx, h); err != nil {
+		ms.error(w, err, webhookStatus(err), "failed to store webhook")
+	} else {
+		ms.send(w, http.StatusOK, h.Redacted())
+	}
+}
+
+func (ha *HuautlaAdaptor) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ms := ha.start(ctx, "DeleteWebhook")
+
+	if id, err := getUUIDByName("id", w, r, ms); err != nil {
+		ms.error(w, err, http.StatusBadRequest, "failed to fetch uuid")
+	} else if err = ha.webhooks.DeleteHook(ctx, id); err != nil {
+		ms.error(w, err, webhookStatus(err), "failed to delete webhook")
+	} else {
+		ms.send(w, http.StatusNoContent, nil)
+	}
+}
+
+// GetWebhookDeliveries is the delivery history for one webhook, optionally
+// only those in `?state=pending|delivered|dead`
+func (ha *HuautlaAdaptor) GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ms := ha.start(ctx, "GetWebhookDeliveries")
+
+	if id, err := getUUIDByName("id", w, r, ms); err != nil {
+		ms.error(w, err, http.StatusBadRequest, "failed to fetch uuid")
+	} else if state, err := deliveryState(r); err != nil {
+		ms.error(w, err, http.StatusBadRequest, "invalid state")
+	} else if _, err := ha.webhooks.Hook(ctx, id); err != nil {
+		ms.error(w, err, webhookStatus(err), "failed to fetch webhook")
+	} else if dls, err := ha.webhooks.Deliveries(ctx, id, state); err != nil {
+		ms.error(w, err, http.StatusInternalServerError, "failed to fetch deliveries")
+	} else {
+		ms.send(w, http.StatusOK, dls)
+	}
+}
+
+// GetDeadLetters is every delivery, for any webhook, that ran out of attempts
+func (ha *HuautlaAdaptor) GetDeadLetters(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ms := ha.start(ctx, "GetDeadLetters")
+
+	if dls, err := ha.webhooks.Deliveries(ctx, "", webhooks.Dead); err != nil {
+		ms.error(w, err, http.StatusInternalServerError, "failed to fetch deliveries")
+	} else {
+		ms.send(w, http.StatusOK, dls)
+	}
+}
+
+func (ha *HuautlaAdaptor) PostRedelivery(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ms := ha.start(ctx, "PostRedelivery")
+
+	if id, err := getUUIDByName("id", w, r, ms); err != nil {
+		ms.error(w, err, http.StatusBadRequest, "failed to fetch uuid")
+	} else if dID := chi.URLParam(r, "d_id"); dID == "" {
+		ms.error(w, fmt.Errorf("missing required delivery id parameter"), http.StatusBadRequest, "missing required id parameter")
+	} else if dl, err := ha.webhooks.Delivery(ctx, dID); err != nil {
+		ms.error(w, err, webhookStatus(err), "failed to fetch delivery")
+	} else if dl.Hook != id {
+		ms.error(w, fmt.Errorf("delivery %s isn't for webhook %s", dID, id), http.StatusNotFound, "failed to fetch delivery")
+	} else if dl, err = ha.webhooks.Redeliver(ctx, dID); err != nil {
+		ms.error(w, err, webhookStatus(err), "failed to redeliver")
+	} else {
+		ms.send(w, http.StatusAccepted, dl)
+	}
+}
+
+func deliveryState(r *http.Request) (webhooks.State, error) {
+	switch state := webhooks.State(r.URL.Query().Get("state")); state {
+	case "", webhooks.Pending, webhooks.Delivered, webhooks.Dead:
+		return state, nil
+	default:
+		return state, fmt.Errorf("state must be one of pending, delivered or dead: %q", state)
+	}
+}
+
+func webhookStatus(err error) int {
+	if errors.Is(err, webhooks.ErrNotFound) {
+		return http.StatusNotFound
+	} else if errors.Is(err, webhooks.ErrInvalidHook) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
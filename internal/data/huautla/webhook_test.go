@@ -0,0 +1,161 @@
+package huautla
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/events"
+	"github.com/jsmit257/centerforfunguscontrol/internal/store"
+	"github.com/jsmit257/centerforfunguscontrol/internal/webhooks"
+	"github.com/jsmit257/centerforfunguscontrol/shared/metrics"
+)
+
+func newWebhookAdaptor(t *testing.T) *HuautlaAdaptor {
+	s := store.NewMem()
+	outbox, err := events.NewOutbox(context.Background(), s)
+	require.Nil(t, err)
+	return &HuautlaAdaptor{
+		store:  s,
+		outbox: outbox,
+		webhooks: webhooks.NewDispatcher(s, outbox, webhooks.Config{
+			MaxAttempts: 1,
+			Backoff:     time.Second,
+			MaxBackoff:  time.Second,
+			Timeout:     time.Second,
+			Poll:        time.Second,
+		}, logrus.WithField("test", t.Name())),
+	}
+}
+
+func sendWebhook(f http.HandlerFunc, meth, url string, params chi.RouteParams, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	rctx := chi.NewRouteContext()
+	rctx.URLParams = params
+	r, _ := http.NewRequestWithContext(
+		context.WithValue(
+			metrics.MockServiceContext,
+			chi.RouteCtxKey,
+			rctx),
+		meth,
+		url,
+		bytes.NewReader([]byte(body)))
+	f(w, r)
+	return w
+}
+
+func Test_PostWebhook(t *testing.T) {
+	t.Parallel()
+
+	set := map[string]struct {
+		body string
+		sc   int
+	}{
+		"happy_path": {
+			body: `{"url":"https://example.com/hook","types":["generation.created"]}`,
+			sc:   http.StatusCreated,
+		},
+		"with_secret": {
+			body: `{"url":"https://example.com/hook","types":["event.*"],"secret":"shh","severities":["High"]}`,
+			sc:   http.StatusCreated,
+		},
+		"with_id": {
+			body: `{"id":"0","url":"https://example.com/hook","types":["*"]}`,
+			sc:   http.StatusBadRequest,
+		},
+		"invalid_hook": {
+			body: `{"url":"example.com/hook","types":["*"]}`,
+			sc:   http.StatusBadRequest,
+		},
+		"unmarshal_error": {
+			body: `{"url":`,
+			sc:   http.StatusBadRequest,
+		},
+	}
+
+	for k, v := range set {
+		k, v := k, v
+		t.Run(k, func(t *testing.T) {
+			t.Parallel()
+
+			ha := newWebhookAdaptor(t)
+			w := sendWebhook(ha.PostWebhook, http.MethodPost, "url", chi.RouteParams{}, v.body)
+			require.Equal(t, v.sc, w.Code, w.Body.String())
+			if v.sc != http.StatusCreated {
+				return
+			}
+
+			var h webhooks.Hook
+			require.Nil(t, json.Unmarshal(w.Body.Bytes(), &h))
+			require.NotEmpty(t, h.UUID)
+			require.NotEmpty(t, h.Secret)
+
+			// the secret is never shown again
+			w = sendWebhook(ha.GetWebhooks, http.MethodGet, "url", chi.RouteParams{}, "")
+			require.Equal(t, http.StatusOK, w.Code)
+			var hooks []webhooks.Hook
+			require.Nil(t, json.Unmarshal(w.Body.Bytes(), &hooks))
+			require.Equal(t, []webhooks.Hook{h.Redacted()}, hooks)
+		})
+	}
+}
+
+func Test_Webhooks(t *testing.T) {
+	t.Parallel()
+
+	ha := newWebhookAdaptor(t)
+
+	w := sendWebhook(ha.PostWebhook, http.MethodPost, "url", chi.RouteParams{}, `{"url":"https://example.com/hook","types":["*"]}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var h webhooks.Hook
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &h))
+	id := chi.RouteParams{Keys: []string{"id"}, Values: []string{string(h.UUID)}}
+	missing := chi.RouteParams{Keys: []string{"id"}, Values: []string{"missing"}}
+
+	w = sendWebhook(ha.PatchWebhook, http.MethodPatch, "url", id, `{"disabled":true}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	w = sendWebhook(ha.GetWebhook, http.MethodGet, "url", id, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `"disabled":true`)
+	require.NotContains(t, w.Body.String(), h.Secret)
+
+	w = sendWebhook(ha.PatchWebhook, http.MethodPatch, "url", id, `{"id":"other"}`)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	w = sendWebhook(ha.PatchWebhook, http.MethodPatch, "url", id, `{"types":[]}`)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	w = sendWebhook(ha.PatchWebhook, http.MethodPatch, "url", missing, `{}`)
+	require.Equal(t, http.StatusNotFound, w.Code)
+	w = sendWebhook(ha.GetWebhook, http.MethodGet, "url", missing, "")
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	w = sendWebhook(ha.GetWebhookDeliveries, http.MethodGet, "url?state=dead", id, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "[]", w.Body.String())
+	w = sendWebhook(ha.GetWebhookDeliveries, http.MethodGet, "url?state=lost", id, "")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	w = sendWebhook(ha.GetWebhookDeliveries, http.MethodGet, "url", missing, "")
+	require.Equal(t, http.StatusNotFound, w.Code)
+	w = sendWebhook(ha.GetDeadLetters, http.MethodGet, "url", chi.RouteParams{}, "")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = sendWebhook(ha.PostRedelivery, http.MethodPost, "url", chi.RouteParams{
+		Keys:   []string{"id", "d_id"},
+		Values: []string{string(h.UUID), "missing"},
+	}, "")
+	require.Equal(t, http.StatusNotFound, w.Code)
+	w = sendWebhook(ha.PostRedelivery, http.MethodPost, "url", id, "")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = sendWebhook(ha.DeleteWebhook, http.MethodDelete, "url", id, "")
+	require.Equal(t, http.StatusNoContent, w.Code)
+	w = sendWebhook(ha.DeleteWebhook, http.MethodDelete, "url", id, "")
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
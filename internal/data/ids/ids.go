@@ -0,0 +1,38 @@
+// Package ids lets a restore keep the ids it's restoring; everything else
+// gets new ones from the database, the way it always has
+package ids
+
+import (
+	"context"
+
+	"github.com/jsmit257/huautla/types"
+)
+
+type (
+	// Keeper is a database whose inserts keep the ids they're given when
+	// ctx says to; huautla's postgres always makes its own
+	Keeper interface {
+		KeepsIDs() bool
+	}
+
+	keepKey struct{}
+)
+
+// Keep is ctx, but inserts with it keep whatever ids they're given
+func Keep(ctx context.Context) context.Context {
+	return context.WithValue(ctx, keepKey{}, true)
+}
+
+// Kept is whether ctx came from Keep
+func Kept(ctx context.Context) bool {
+	keep, _ := ctx.Value(keepKey{}).(bool)
+	return keep
+}
+
+// Or is id if ctx keeps ids and there is one, and a fresh one otherwise
+func Or(ctx context.Context, id types.UUID, fresh func() types.UUID) types.UUID {
+	if id != "" && Kept(ctx) {
+		return id
+	}
+	return fresh()
+}
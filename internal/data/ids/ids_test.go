@@ -0,0 +1,45 @@
+package ids
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/huautla/types"
+)
+
+func Test_Or(t *testing.T) {
+	t.Parallel()
+
+	fresh := func() types.UUID { return "fresh" }
+
+	tcs := map[string]struct {
+		ctx    context.Context
+		id     types.UUID
+		result types.UUID
+	}{
+		"happy_path": {
+			ctx:    Keep(context.Background()),
+			id:     "kept",
+			result: "kept",
+		},
+		"not_kept": {
+			ctx:    context.Background(),
+			id:     "kept",
+			result: "fresh",
+		},
+		"no_id": {
+			ctx:    Keep(context.Background()),
+			result: "fresh",
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tc.result, Or(tc.ctx, tc.id, fresh))
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/data/ids"
+	"github.com/jsmit257/huautla/types"
+)
+
+func (db *DB) SelectByEventType(ctx context.Context, et types.EventType, _ types.CID) ([]types.Event, error) {
+	defer db.rlock(ctx)()
+
+	return db.findEvents(func(row *eventRow) bool { return row.eventType == et.UUID }), nil
+}
+
+func (db *DB) SelectEvent(ctx context.Context, id types.UUID, _ types.CID) (types.Event, error) {
+	defer db.rlock(ctx)()
+
+	if _, ok := db.events[id]; !ok {
+		return types.Event{UUID: id}, sql.ErrNoRows
+	}
+	return db.event(id), nil
+}
+
+// eventsOf is every event that belongs to id, newest first
+func (db *DB) eventsOf(id types.UUID) []types.Event {
+	return db.findEvents(func(row *eventRow) bool { return row.observable == id })
+}
+
+func (db *DB) findEvents(match func(*eventRow) bool) []types.Event {
+	result := make([]types.Event, 0, 100)
+	for id, row := range db.events {
+		if match(row) {
+			result = append(result, db.event(id))
+		}
+	}
+	slices.SortFunc(result, func(a, b types.Event) int {
+		return cmp.Or(
+			b.MTime.Compare(a.MTime),
+			b.CTime.Compare(a.CTime),
+			strings.Compare(string(a.UUID), string(b.UUID)))
+	})
+	return result
+}
+
+// event is the row with id, with its event type, but not its notes or
+// photos; id has to be there
+func (db *DB) event(id types.UUID) types.Event {
+	row := db.events[id]
+	return types.Event{
+		UUID:        id,
+		Temperature: row.temperature,
+		Humidity:    row.humidity,
+		EventType:   db.eventType(row.eventType),
+		MTime:       row.mtime,
+		CTime:       row.ctime,
+	}
+}
+
+// notesAndPhotos fills in the notes and photos for events, the way reports
+// show them
+func (db *DB) notesAndPhotos(events []types.Event) {
+	for i := range events {
+		if notes := db.notesOf(events[i].UUID); len(notes) != 0 {
+			events[i].Notes = notes
+		}
+		if photos := db.photosOf(events[i].UUID); len(photos) != 0 {
+			events[i].Photos = photos
+		}
+	}
+}
+
+// addEvent adds e to whatever oID is, and puts it at the front of events
+func (db *DB) addEvent(ctx context.Context, oID types.UUID, events []types.Event, e *types.Event) ([]types.Event, error) {
+	e.UUID = ids.Or(ctx, e.UUID, db.newID)
+	e.MTime = db.now()
+	e.CTime = e.MTime
+
+	// the most likely reason for nothing to be added is a bad event type
+	if _, ok := db.eventTypes[e.EventType.UUID]; !ok {
+		return events, fmt.Errorf("event was not added")
+	}
+
+	db.events[e.UUID] = &eventRow{
+		temperature: e.Temperature,
+		humidity:    e.Humidity,
+		eventType:   e.EventType.UUID,
+		observable:  oID,
+		mtime:       e.MTime,
+		ctime:       e.CTime,
+	}
+	e.EventType = db.eventType(e.EventType.UUID)
+
+	return append([]types.Event{*e}, events...), nil
+}
+
+// changeEvent changes e, and moves it to the front of events since it's
+// now the newest
+func (db *DB) changeEvent(events []types.Event, e *types.Event) ([]types.Event, error) {
+	e.MTime = db.now()
+
+	row, ok := db.events[e.UUID]
+	if !ok {
+		return events, fmt.Errorf("event was not changed")
+	} else if _, ok = db.eventTypes[e.EventType.UUID]; !ok {
+		return events, fmt.Errorf("event was not changed")
+	}
+
+	row.temperature, row.humidity, row.eventType, row.mtime = e.Temperature, e.Humidity, e.EventType.UUID, e.MTime
+	e.EventType = db.eventType(e.EventType.UUID)
+
+	rest := slices.DeleteFunc(slices.Clone(events), func(old types.Event) bool { return old.UUID == e.UUID })
+	return append([]types.Event{*e}, rest...), nil
+}
+
+func (db *DB) removeEvent(events []types.Event, id types.UUID) ([]types.Event, error) {
+	if _, ok := db.events[id]; !ok {
+		return events, fmt.Errorf("event could not be removed")
+	}
+	db.deleteEvent(id)
+	return slices.DeleteFunc(events, func(old types.Event) bool { return old.UUID == id }), nil
+}
+
+// deleteEvent deletes id and everything that belongs to it
+func (db *DB) deleteEvent(id types.UUID) {
+	delete(db.events, id)
+	db.deleteNotes(id)
+	for pID, p := range db.photos {
+		if p.photoable == id {
+			db.deletePhoto(pID)
+		}
+	}
+}
+
+// touch sets the mtime of a lifecycle or generation, after one of its
+// events changes
+func (db *DB) touch(modified time.Time, id types.UUID) (time.Time, error) {
+	if lc, ok := db.lifecycles[id]; ok {
+		lc.mtime = modified
+	} else if g, ok := db.generations[id]; ok {
+		g.mtime = modified
+	} else {
+		return modified, fmt.Errorf("mtime was not updated")
+	}
+	return modified, nil
+}
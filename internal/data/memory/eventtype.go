@@ -0,0 +1,118 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/data/ids"
+	"github.com/jsmit257/huautla/types"
+)
+
+func (db *DB) SelectAllEventTypes(ctx context.Context, _ types.CID) ([]types.EventType, error) {
+	defer db.rlock(ctx)()
+
+	result := make([]types.EventType, 0, len(db.eventTypes))
+	for id := range db.eventTypes {
+		result = append(result, db.eventType(id))
+	}
+	slices.SortFunc(result, func(a, b types.EventType) int {
+		return cmp.Or(
+			strings.Compare(a.Stage.Name, b.Stage.Name),
+			strings.Compare(a.Name, b.Name),
+			strings.Compare(string(a.UUID), string(b.UUID)))
+	})
+
+	return result, nil
+}
+
+func (db *DB) SelectEventType(ctx context.Context, id types.UUID, _ types.CID) (types.EventType, error) {
+	defer db.rlock(ctx)()
+
+	if _, ok := db.eventTypes[id]; !ok {
+		return types.EventType{}, sql.ErrNoRows
+	}
+	return db.eventType(id), nil
+}
+
+// eventType is the row with id, with its stage; id has to be there
+func (db *DB) eventType(id types.UUID) types.EventType {
+	row := db.eventTypes[id]
+	return types.EventType{
+		UUID:     id,
+		Name:     row.name,
+		Severity: row.severity,
+		Stage:    db.stages[row.stage],
+	}
+}
+
+func (db *DB) InsertEventType(ctx context.Context, e types.EventType, _ types.CID) (types.EventType, error) {
+	defer db.lock(ctx)()
+
+	e.UUID = ids.Or(ctx, e.UUID, db.newID)
+	// the most likely reason for nothing to be added is a bad stage
+	if _, ok := db.stages[e.Stage.UUID]; !ok {
+		return e, fmt.Errorf("eventtype was not added")
+	}
+	db.eventTypes[e.UUID] = &eventTypeRow{name: e.Name, severity: e.Severity, stage: e.Stage.UUID}
+	return e, nil
+}
+
+func (db *DB) UpdateEventType(ctx context.Context, id types.UUID, e types.EventType, _ types.CID) error {
+	defer db.lock(ctx)()
+
+	row, ok := db.eventTypes[id]
+	if !ok {
+		return fmt.Errorf("eventtype was not updated: '%s'", id)
+	} else if _, ok = db.stages[e.Stage.UUID]; !ok {
+		return fmt.Errorf("eventtype was not updated: '%s'", id)
+	}
+	row.name, row.severity, row.stage, row.mtime = e.Name, e.Severity, e.Stage.UUID, ptr(db.now())
+	return nil
+}
+
+func (db *DB) DeleteEventType(ctx context.Context, id types.UUID, _ types.CID) error {
+	defer db.lock(ctx)()
+
+	if _, ok := db.eventTypes[id]; !ok {
+		return fmt.Errorf("eventtype could not be deleted: '%s'", id)
+	}
+	for _, e := range db.events {
+		if e.eventType == id {
+			return inUse("eventtype", id, "an event")
+		}
+	}
+	delete(db.eventTypes, id)
+	return nil
+}
+
+func (db *DB) EventTypeReport(ctx context.Context, id types.UUID, cid types.CID) (types.Entity, error) {
+	defer db.rlock(ctx)()
+
+	if _, ok := db.eventTypes[id]; !ok {
+		return nil, sql.ErrNoRows
+	}
+	return db.report(ctx, eventtype(db.eventType(id)), cid, nil)
+}
+
+func (e eventtype) children(ctx context.Context, db *DB, cid types.CID, p *rpttree) error {
+	param, _ := types.NewReportAttrs(url.Values{"eventtype-id": {string(e.UUID)}})
+
+	if lcs, err := db.lifecycleReport(ctx, param, cid, p); err != nil {
+		return err
+	} else if len(lcs) != 0 {
+		p.data["lifecycles"] = lcs
+	}
+
+	if gens, err := db.generationReport(ctx, param, cid, p); err != nil {
+		return err
+	} else if len(gens) != 0 {
+		p.data["generations"] = gens
+	}
+
+	return nil
+}
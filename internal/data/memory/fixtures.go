@@ -0,0 +1,286 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/jsmit257/huautla/types"
+)
+
+type seeder struct {
+	ctx   context.Context
+	db    *DB
+	start time.Time
+	clock time.Time
+	err   error
+}
+
+const seedCID types.CID = "seed"
+
+// Seed fills db with a few months of a small grow: vendors, substrates and
+// their ingredients, some strains, a couple of generations and lifecycles
+// at different stages, with their events and notes. Everything is dated
+// as if it happened over the 90 days before now, so timelines and reports
+// have something to show.
+//
+// It goes through the same methods as everyone else, so it can be called
+// on a database that already has things in it, but it's meant for a new one
+func (db *DB) Seed(ctx context.Context) error {
+	db.mu.Lock()
+	now := db.now
+	s := &seeder{ctx: ctx, db: db, start: now().AddDate(0, 0, -90).Truncate(24 * time.Hour)}
+	s.clock = s.start
+	// every change takes a minute, so things that happen on the same day
+	// still happen in order
+	db.now = func() time.Time {
+		s.clock = s.clock.Add(time.Minute)
+		return s.clock
+	}
+	db.mu.Unlock()
+
+	defer func() {
+		db.mu.Lock()
+		db.now = now
+		db.mu.Unlock()
+	}()
+
+	s.seed()
+	return s.err
+}
+
+func (s *seeder) seed() {
+	inhouse := s.vendor("In house", "")
+	northSpore := s.vendor("North Spore", "https://northspore.com")
+	perfecti := s.vendor("Fungi Perfecti", "https://fungi.com")
+	midwest := s.vendor("Midwest Grow Kits", "https://midwestgrowkits.com")
+
+	agar := s.ingredient("Agar")
+	lme := s.ingredient("Light malt extract")
+	yeast := s.ingredient("Nutritional yeast")
+	honey := s.ingredient("Honey")
+	rye := s.ingredient("Rye berries")
+	oats := s.ingredient("Oats")
+	gypsum := s.ingredient("Gypsum")
+	coir := s.ingredient("Coco coir")
+	vermiculite := s.ingredient("Vermiculite")
+	sawdust := s.ingredient("Hardwood sawdust")
+	hulls := s.ingredient("Soybean hulls")
+
+	mea := s.substrate("MEA plates", types.PlatingType, inhouse, agar, lme, yeast)
+	lc := s.substrate("Honey water", types.LiquidType, inhouse, honey)
+	ryeGrain := s.substrate("Rye berries", types.GrainType, northSpore, rye, gypsum)
+	oatGrain := s.substrate("Oats", types.GrainType, inhouse, oats, gypsum)
+	cvg := s.substrate("CVG", types.BulkType, inhouse, coir, vermiculite, gypsum)
+	masters := s.substrate("Master's mix", types.BulkType, midwest, sawdust, hulls)
+
+	blue := s.strain("Pleurotus ostreatus", "Blue Oyster", northSpore, "Color", "blue-grey", "Fruiting temperature", "55-65F")
+	golden := s.strain("Pleurotus citrinopileatus", "Golden Oyster", northSpore, "Color", "yellow")
+	lions := s.strain("Hericium erinaceus", "Lion's Mane", perfecti, "Fruiting temperature", "60-70F")
+	s.strain("Lentinula edodes", "Shiitake", perfecti, "Log friendly", "yes")
+
+	// a generation started from the blue oyster syringe, which became a
+	// strain of its own
+	s.day(3)
+	g1 := s.generation(mea, lc)
+	s.source(g1, "strain", types.Source{Type: "Spore", Strain: blue})
+	s.day(4)
+	s.generationEvent(&g1, "agarsampling", 22, 0)
+	s.day(11)
+	s.generationEvent(&g1, "liquidinnoculation", 22, 0)
+	s.day(18)
+	blueG1 := s.strain("Pleurotus ostreatus", "Blue Oyster G1", inhouse, "Color", "blue-grey")
+	s.generated(g1, blueG1)
+
+	// blue oyster, start to finish
+	s.day(7)
+	l1 := s.lifecycle(types.Lifecycle{
+		Location:       "Tent A",
+		StrainCost:     20,
+		GrainCost:      12.5,
+		BulkCost:       18,
+		Strain:         blue,
+		GrainSubstrate: ryeGrain,
+		BulkSubstrate:  masters,
+	})
+	s.lifecycleEvent(&l1, "innoculation", 24, 0)
+	s.day(16)
+	s.lifecycleEvent(&l1, "halfcolonization", 24, 0)
+	s.note(l1.UUID, "a little slow on the bottom of the jar, shook it")
+	s.day(23)
+	s.lifecycleEvent(&l1, "fullcolonization", 24, 0)
+	s.day(24)
+	s.lifecycleEvent(&l1, "binning", 18, 90)
+	s.day(35)
+	s.lifecycleEvent(&l1, "harvesting", 16, 92)
+	s.note(l1.UUID, "first flush, nice clusters")
+	s.day(36)
+	s.lifecycleEvent(&l1, "sporeprint", 18, 60)
+	s.day(49)
+	s.lifecycleEvent(&l1, "harvesting", 16, 90)
+	s.day(50)
+	s.lifecycleEvent(&l1, "sunset", 18, 50)
+	l1.Yield, l1.Count, l1.Gross = 612.5, 14, 45
+	s.updateLifecycle(l1)
+
+	// golden oyster, cloned for the next generation
+	s.day(28)
+	l2 := s.lifecycle(types.Lifecycle{
+		Location:       "Tent A",
+		StrainCost:     20,
+		GrainCost:      12.5,
+		BulkCost:       9,
+		Strain:         golden,
+		GrainSubstrate: ryeGrain,
+		BulkSubstrate:  cvg,
+	})
+	s.lifecycleEvent(&l2, "innoculation", 24, 0)
+	s.day(41)
+	s.lifecycleEvent(&l2, "fullcolonization", 24, 0)
+	s.day(42)
+	s.lifecycleEvent(&l2, "binning", 18, 90)
+	s.day(53)
+	clone := s.lifecycleEvent(&l2, "clone", 18, 90)
+	s.note(l2.UUID, "cloned the biggest cluster onto MEA")
+
+	s.day(54)
+	g2 := s.generation(mea, lc)
+	s.source(g2, "event", types.Source{Type: "Clone", Lifecycle: &types.Lifecycle{UUID: l2.UUID, Events: []types.Event{clone}}})
+	s.day(55)
+	s.generationEvent(&g2, "agarsampling", 22, 0)
+
+	// lion's mane, still colonizing
+	s.day(70)
+	l3 := s.lifecycle(types.Lifecycle{
+		Location:       "Tent B",
+		StrainCost:     25,
+		GrainCost:      6,
+		BulkCost:       18,
+		Strain:         lions,
+		GrainSubstrate: oatGrain,
+		BulkSubstrate:  masters,
+	})
+	s.lifecycleEvent(&l3, "innoculation", 23, 0)
+	s.day(84)
+	s.lifecycleEvent(&l3, "halfcolonization", 23, 0)
+	s.note(l3.UUID, "slow to colonize, as usual for lion's mane")
+
+	// the first of the new strain, just started
+	s.day(88)
+	l4 := s.lifecycle(types.Lifecycle{
+		Location:       "Tent B",
+		GrainCost:      12.5,
+		BulkCost:       9,
+		Strain:         blueG1,
+		GrainSubstrate: ryeGrain,
+		BulkSubstrate:  cvg,
+	})
+	s.lifecycleEvent(&l4, "innoculation", 24, 0)
+}
+
+// day sets the clock to n days after the start
+func (s *seeder) day(n int) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	s.clock = s.start.AddDate(0, 0, n).Add(9 * time.Hour)
+}
+
+func (s *seeder) vendor(name, website string) (result types.Vendor) {
+	if s.err == nil {
+		result, s.err = s.db.InsertVendor(s.ctx, types.Vendor{Name: name, Website: website}, seedCID)
+	}
+	return result
+}
+
+func (s *seeder) ingredient(name string) (result types.Ingredient) {
+	if s.err == nil {
+		result, s.err = s.db.InsertIngredient(s.ctx, types.Ingredient{Name: name}, seedCID)
+	}
+	return result
+}
+
+func (s *seeder) substrate(name string, t types.SubstrateType, v types.Vendor, ing ...types.Ingredient) (result types.Substrate) {
+	if s.err == nil {
+		result, s.err = s.db.InsertSubstrate(s.ctx, types.Substrate{Name: name, Type: t, Vendor: v}, seedCID)
+	}
+	for _, i := range ing {
+		if s.err == nil {
+			s.err = s.db.AddIngredient(s.ctx, &result, i, seedCID)
+		}
+	}
+	return result
+}
+
+// strain adds a strain with attrs, which are names and values, in turn
+func (s *seeder) strain(species, name string, v types.Vendor, attrs ...string) (result types.Strain) {
+	if s.err == nil {
+		result, s.err = s.db.InsertStrain(s.ctx, types.Strain{Species: species, Name: name, Vendor: v}, seedCID)
+	}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		if s.err == nil {
+			_, s.err = s.db.AddAttribute(s.ctx, &result, types.StrainAttribute{Name: attrs[i], Value: attrs[i+1]}, seedCID)
+		}
+	}
+	return result
+}
+
+func (s *seeder) generation(plating, liquid types.Substrate) (result types.Generation) {
+	if s.err == nil {
+		result, s.err = s.db.InsertGeneration(s.ctx, types.Generation{PlatingSubstrate: plating, LiquidSubstrate: liquid}, seedCID)
+	}
+	return result
+}
+
+func (s *seeder) source(g types.Generation, origin string, src types.Source) {
+	if s.err == nil {
+		_, s.err = s.db.InsertSource(s.ctx, g.UUID, origin, src, seedCID)
+	}
+}
+
+func (s *seeder) generated(g types.Generation, st types.Strain) {
+	if s.err == nil {
+		s.err = s.db.UpdateGeneratedStrain(s.ctx, &g.UUID, st.UUID, seedCID)
+	}
+}
+
+func (s *seeder) generationEvent(g *types.Generation, et types.UUID, temp float32, humidity int8) {
+	if s.err == nil {
+		s.err = s.db.AddGenerationEvent(s.ctx, g, types.Event{
+			Temperature: temp,
+			Humidity:    humidity,
+			EventType:   types.EventType{UUID: et},
+		}, seedCID)
+	}
+}
+
+func (s *seeder) lifecycle(lc types.Lifecycle) (result types.Lifecycle) {
+	if s.err == nil {
+		result, s.err = s.db.InsertLifecycle(s.ctx, lc, seedCID)
+	}
+	return result
+}
+
+func (s *seeder) updateLifecycle(lc types.Lifecycle) {
+	if s.err == nil {
+		_, s.err = s.db.UpdateLifecycle(s.ctx, lc, seedCID)
+	}
+}
+
+func (s *seeder) lifecycleEvent(lc *types.Lifecycle, et types.UUID, temp float32, humidity int8) types.Event {
+	if s.err == nil {
+		s.err = s.db.AddLifecycleEvent(s.ctx, lc, types.Event{
+			Temperature: temp,
+			Humidity:    humidity,
+			EventType:   types.EventType{UUID: et},
+		}, seedCID)
+	}
+	if s.err != nil {
+		return types.Event{}
+	}
+	return lc.Events[0]
+}
+
+func (s *seeder) note(id types.UUID, note string) {
+	if s.err == nil {
+		_, s.err = s.db.AddNote(s.ctx, id, nil, types.Note{Note: note}, seedCID)
+	}
+}
@@ -0,0 +1,259 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/data/ids"
+	"github.com/jsmit257/huautla/types"
+)
+
+// SelectGenerationIndex is every generation with its sources, including
+// deleted ones
+func (db *DB) SelectGenerationIndex(ctx context.Context, _ types.CID) ([]types.Generation, error) {
+	defer db.rlock(ctx)()
+
+	gens := db.findGenerations(func(types.UUID, *generationRow) bool { return true })
+
+	result := make([]types.Generation, 0, len(gens))
+	for _, g := range gens {
+		g.Events = nil
+		for i, s := range g.Sources {
+			g.Sources[i].Strain = types.Strain{
+				UUID:    s.Strain.UUID,
+				Name:    s.Strain.Name,
+				Species: s.Strain.Species,
+				CTime:   s.Strain.CTime,
+				Vendor:  s.Strain.Vendor,
+			}
+			if s.Lifecycle != nil {
+				g.Sources[i].Lifecycle = &types.Lifecycle{UUID: s.Lifecycle.UUID}
+			}
+		}
+		result = append(result, g)
+	}
+
+	return result, nil
+}
+
+func (db *DB) SelectGeneration(ctx context.Context, id types.UUID, _ types.CID) (types.Generation, error) {
+	defer db.rlock(ctx)()
+
+	return db.selectGeneration(id)
+}
+
+func (db *DB) selectGeneration(id types.UUID) (types.Generation, error) {
+	if _, ok := db.generations[id]; !ok {
+		return types.Generation{}, sql.ErrNoRows
+	}
+	return db.generation(id), nil
+}
+
+func (db *DB) selectGenerations(p types.ReportAttrs) ([]types.Generation, error) {
+	if !p.Contains("generation-id", "strain-id", "plating-id", "liquid-id", "eventtype-id") {
+		return nil, fmt.Errorf("request doesn't contain at least 1 required field")
+	}
+
+	gID, sID, pID, lID, etID := p.Get("generation-id"), p.Get("strain-id"), p.Get("plating-id"), p.Get("liquid-id"), p.Get("eventtype-id")
+	return db.findGenerations(func(id types.UUID, row *generationRow) bool {
+		return (gID == nil || *gID == id) &&
+			(sID == nil || db.fromStrain(id, *sID)) &&
+			(pID == nil || *pID == row.plating) &&
+			(lID == nil || *lID == row.liquid) &&
+			(etID == nil || db.hasEvent(id, *etID))
+	}), nil
+}
+
+// findGenerations is every generation that matches, by id, with its
+// events and sources
+func (db *DB) findGenerations(match func(types.UUID, *generationRow) bool) []types.Generation {
+	result := make([]types.Generation, 0, len(db.generations))
+	for id, row := range db.generations {
+		if match(id, row) {
+			result = append(result, db.generation(id))
+		}
+	}
+	slices.SortFunc(result, func(a, b types.Generation) int {
+		return strings.Compare(string(a.UUID), string(b.UUID))
+	})
+	return result
+}
+
+// generation is the row with id, with its substrates, events and sources;
+// id has to be there
+func (db *DB) generation(id types.UUID) types.Generation {
+	row := db.generations[id]
+	return types.Generation{
+		UUID:             id,
+		PlatingSubstrate: db.substrate(row.plating),
+		LiquidSubstrate:  db.substrate(row.liquid),
+		Sources:          db.sourcesOf(id),
+		Events:           db.eventsOf(id),
+		MTime:            row.mtime,
+		CTime:            row.ctime,
+		DTime:            row.dtime,
+	}
+}
+
+// fromStrain is whether any of generation id's sources came from strain
+// sID, either directly or from an event in one of its lifecycles
+func (db *DB) fromStrain(id, sID types.UUID) bool {
+	for _, s := range db.sources {
+		if s.generation == id {
+			if st, _, ok := db.progenitor(s); ok && st == sID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (db *DB) InsertGeneration(ctx context.Context, g types.Generation, _ types.CID) (types.Generation, error) {
+	defer db.lock(ctx)()
+
+	g.UUID = ids.Or(ctx, g.UUID, db.newID)
+	g.CTime = db.now()
+
+	if !db.substrateOf(g.PlatingSubstrate.UUID, types.PlatingType) || !db.substrateOf(g.LiquidSubstrate.UUID, types.LiquidType) {
+		return g, fmt.Errorf("generation was not added")
+	}
+
+	db.generations[g.UUID] = &generationRow{
+		plating: g.PlatingSubstrate.UUID,
+		liquid:  g.LiquidSubstrate.UUID,
+		mtime:   g.CTime,
+		ctime:   g.CTime,
+	}
+
+	return db.selectGeneration(g.UUID)
+}
+
+func (db *DB) UpdateGeneration(ctx context.Context, g types.Generation, _ types.CID) (types.Generation, error) {
+	defer db.lock(ctx)()
+
+	g.MTime = db.now()
+
+	row, ok := db.generations[g.UUID]
+	if !ok || !db.substrateOf(g.PlatingSubstrate.UUID, types.PlatingType) || !db.substrateOf(g.LiquidSubstrate.UUID, types.LiquidType) {
+		return g, fmt.Errorf("generation was not updated")
+	}
+	row.plating, row.liquid, row.mtime = g.PlatingSubstrate.UUID, g.LiquidSubstrate.UUID, g.MTime
+
+	return g, nil
+}
+
+// DeleteGeneration only marks the generation deleted, Undelete takes it
+// back
+func (db *DB) DeleteGeneration(ctx context.Context, id types.UUID, _ types.CID) error {
+	defer db.lock(ctx)()
+
+	row, ok := db.generations[id]
+	if !ok {
+		return fmt.Errorf("generation could not be deleted: '%s'", id)
+	}
+	row.dtime = ptr(db.now())
+	return nil
+}
+
+func (db *DB) GetGenerationEvents(ctx context.Context, g *types.Generation, _ types.CID) error {
+	defer db.rlock(ctx)()
+
+	g.Events = db.eventsOf(g.UUID)
+	return nil
+}
+
+func (db *DB) AddGenerationEvent(ctx context.Context, g *types.Generation, e types.Event, _ types.CID) error {
+	defer db.lock(ctx)()
+
+	var err error
+	if g.Events, err = db.addEvent(ctx, g.UUID, g.Events, &e); err != nil {
+		return err
+	} else if g.MTime, err = db.touch(e.MTime, g.UUID); err != nil {
+		return fmt.Errorf("couldn't update Generation.mtime")
+	}
+	return nil
+}
+
+func (db *DB) ChangeGenerationEvent(ctx context.Context, g *types.Generation, e types.Event, _ types.CID) (types.Event, error) {
+	defer db.lock(ctx)()
+
+	var err error
+	if g.Events, err = db.changeEvent(g.Events, &e); err != nil {
+		return e, err
+	}
+	g.MTime, err = db.touch(e.MTime, g.UUID)
+	return e, err
+}
+
+func (db *DB) RemoveGenerationEvent(ctx context.Context, g *types.Generation, id types.UUID, _ types.CID) error {
+	defer db.lock(ctx)()
+
+	var err error
+	if g.Events, err = db.removeEvent(g.Events, id); err != nil {
+		return err
+	}
+	g.MTime, err = db.touch(db.now(), g.UUID)
+	return err
+}
+
+func (db *DB) GenerationReport(ctx context.Context, id types.UUID, cid types.CID) (types.Entity, error) {
+	p, err := types.NewReportAttrs(url.Values{"generation-id": {string(id)}})
+	if err != nil {
+		return nil, err
+	}
+
+	defer db.rlock(ctx)()
+
+	if result, err := db.generationReport(ctx, p, cid, nil); err != nil {
+		return nil, err
+	} else if len(result) != 0 {
+		return result[0], nil
+	}
+
+	return nil, sql.ErrNoRows
+}
+
+func (db *DB) generationReport(ctx context.Context, params types.ReportAttrs, cid types.CID, p *rpttree) ([]types.Entity, error) {
+	gens, err := db.selectGenerations(params)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]types.Entity, 0, len(gens))
+	for _, gen := range gens {
+		gen.PlatingSubstrate.Ingredients = db.substrateIngredients(db.substrates[gen.PlatingSubstrate.UUID])
+		gen.LiquidSubstrate.Ingredients = db.substrateIngredients(db.substrates[gen.LiquidSubstrate.UUID])
+		db.notesAndPhotos(gen.Events)
+
+		if e, err := db.report(ctx, generation(gen), cid, p); err != nil {
+			return nil, err
+		} else if e != nil {
+			result = append(result, e)
+		}
+	}
+
+	return result, nil
+}
+
+func (g generation) children(ctx context.Context, db *DB, cid types.CID, p *rpttree) error {
+	if notes, err := db.notesReport(ctx, g.UUID, cid, p); err != nil {
+		return err
+	} else if len(notes) != 0 {
+		p.data["notes"] = notes
+	}
+
+	if progeny, err := db.generatedStrain(g.UUID); err == sql.ErrNoRows {
+	} else if err != nil {
+		return err
+	} else if e, err := db.report(ctx, strain(progeny), cid, p); err != nil {
+		return err
+	} else if e != nil {
+		p.data["progeny"] = e
+	}
+
+	return nil
+}
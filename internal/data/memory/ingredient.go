@@ -0,0 +1,74 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/data/ids"
+	"github.com/jsmit257/huautla/types"
+)
+
+func (db *DB) SelectAllIngredients(ctx context.Context, _ types.CID) ([]types.Ingredient, error) {
+	defer db.rlock(ctx)()
+
+	result := make([]types.Ingredient, 0, len(db.ingredients))
+	for _, i := range db.ingredients {
+		result = append(result, i)
+	}
+	sortIngredients(result)
+
+	return result, nil
+}
+
+func (db *DB) SelectIngredient(ctx context.Context, id types.UUID, _ types.CID) (types.Ingredient, error) {
+	defer db.rlock(ctx)()
+
+	if i, ok := db.ingredients[id]; ok {
+		return i, nil
+	}
+	return types.Ingredient{UUID: id}, sql.ErrNoRows
+}
+
+func (db *DB) InsertIngredient(ctx context.Context, i types.Ingredient, _ types.CID) (types.Ingredient, error) {
+	defer db.lock(ctx)()
+
+	i.UUID = ids.Or(ctx, i.UUID, db.newID)
+	db.ingredients[i.UUID] = i
+	return i, nil
+}
+
+func (db *DB) UpdateIngredient(ctx context.Context, id types.UUID, i types.Ingredient, _ types.CID) error {
+	defer db.lock(ctx)()
+
+	if _, ok := db.ingredients[id]; !ok {
+		return fmt.Errorf("ingredient was not updated: '%s'", id)
+	}
+	i.UUID = id
+	db.ingredients[id] = i
+	return nil
+}
+
+func (db *DB) DeleteIngredient(ctx context.Context, id types.UUID, _ types.CID) error {
+	defer db.lock(ctx)()
+
+	if _, ok := db.ingredients[id]; !ok {
+		return fmt.Errorf("ingredient could not be deleted: '%s'", id)
+	}
+	for _, s := range db.substrates {
+		if slices.Contains(s.ingredients, id) {
+			return inUse("ingredient", id, "a substrate")
+		}
+	}
+	delete(db.ingredients, id)
+	return nil
+}
+
+func sortIngredients(ing []types.Ingredient) {
+	slices.SortFunc(ing, func(a, b types.Ingredient) int {
+		return cmp.Or(strings.Compare(a.Name, b.Name), strings.Compare(string(a.UUID), string(b.UUID)))
+	})
+}
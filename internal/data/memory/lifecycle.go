@@ -0,0 +1,296 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/data/ids"
+	"github.com/jsmit257/huautla/types"
+)
+
+// SelectLifecycleIndex is every lifecycle with just the events that say
+// it's finished or that something came of it: sunset, spore print and clone
+func (db *DB) SelectLifecycleIndex(ctx context.Context, _ types.CID) ([]types.Lifecycle, error) {
+	defer db.rlock(ctx)()
+
+	lcs := db.findLifecycles(func(types.UUID, *lifecycleRow) bool { return true })
+
+	result := make([]types.Lifecycle, 0, len(lcs))
+	for _, lc := range lcs {
+		row := types.Lifecycle{
+			UUID:     lc.UUID,
+			Location: lc.Location,
+			MTime:    lc.MTime,
+			CTime:    lc.CTime,
+			Strain: types.Strain{
+				UUID:    lc.Strain.UUID,
+				Species: lc.Strain.Species,
+				Name:    lc.Strain.Name,
+				CTime:   lc.Strain.CTime,
+				Vendor:  lc.Strain.Vendor,
+			},
+		}
+		for _, e := range lc.Events {
+			switch e.EventType.UUID {
+			case "sunset", "sporeprint", "clone":
+				row.Events = append(row.Events, e)
+			}
+		}
+		result = append(result, row)
+	}
+
+	return result, nil
+}
+
+func (db *DB) SelectLifecycle(ctx context.Context, id types.UUID, _ types.CID) (types.Lifecycle, error) {
+	defer db.rlock(ctx)()
+
+	return db.selectLifecycle(id)
+}
+
+func (db *DB) selectLifecycle(id types.UUID) (types.Lifecycle, error) {
+	if _, ok := db.lifecycles[id]; !ok {
+		return types.Lifecycle{}, sql.ErrNoRows
+	}
+	return db.lifecycle(id), nil
+}
+
+func (db *DB) selectLifecycles(p types.ReportAttrs) ([]types.Lifecycle, error) {
+	if !p.Contains("lifecycle-id", "strain-id", "grain-id", "bulk-id", "eventtype-id") {
+		return nil, fmt.Errorf("request doesn't contain at least 1 required field")
+	}
+
+	lcID, sID, gID, bID, etID := p.Get("lifecycle-id"), p.Get("strain-id"), p.Get("grain-id"), p.Get("bulk-id"), p.Get("eventtype-id")
+	return db.findLifecycles(func(id types.UUID, row *lifecycleRow) bool {
+		return (lcID == nil || *lcID == id) &&
+			(sID == nil || *sID == row.strain) &&
+			(gID == nil || *gID == row.grain) &&
+			(bID == nil || *bID == row.bulk) &&
+			(etID == nil || db.hasEvent(id, *etID))
+	}), nil
+}
+
+// findLifecycles is every lifecycle that matches, most recently changed
+// first, with its events
+func (db *DB) findLifecycles(match func(types.UUID, *lifecycleRow) bool) []types.Lifecycle {
+	result := make([]types.Lifecycle, 0, len(db.lifecycles))
+	for id, row := range db.lifecycles {
+		if match(id, row) {
+			result = append(result, db.lifecycle(id))
+		}
+	}
+	slices.SortFunc(result, func(a, b types.Lifecycle) int {
+		return cmp.Or(b.MTime.Compare(a.MTime), strings.Compare(string(a.UUID), string(b.UUID)))
+	})
+	return result
+}
+
+// lifecycle is the row with id, with its strain, substrates and events;
+// id has to be there
+func (db *DB) lifecycle(id types.UUID) types.Lifecycle {
+	row := db.lifecycles[id]
+	return types.Lifecycle{
+		UUID:           id,
+		Location:       row.location,
+		StrainCost:     row.strainCost,
+		GrainCost:      row.grainCost,
+		BulkCost:       row.bulkCost,
+		Yield:          row.yield,
+		Count:          row.count,
+		Gross:          row.gross,
+		Strain:         db.strain(row.strain),
+		GrainSubstrate: db.substrate(row.grain),
+		BulkSubstrate:  db.substrate(row.bulk),
+		Events:         db.eventsOf(id),
+		MTime:          row.mtime,
+		CTime:          row.ctime,
+	}
+}
+
+// hasEvent is whether id has an event of type etID
+func (db *DB) hasEvent(id, etID types.UUID) bool {
+	for _, e := range db.events {
+		if e.observable == id && e.eventType == etID {
+			return true
+		}
+	}
+	return false
+}
+
+// validLifecycle is whether lc's strain is there, and its substrates are
+// grain and bulk
+func (db *DB) validLifecycle(lc types.Lifecycle) bool {
+	_, ok := db.strains[lc.Strain.UUID]
+	return ok &&
+		db.substrateOf(lc.GrainSubstrate.UUID, types.GrainType) &&
+		db.substrateOf(lc.BulkSubstrate.UUID, types.BulkType)
+}
+
+func (db *DB) InsertLifecycle(ctx context.Context, lc types.Lifecycle, _ types.CID) (types.Lifecycle, error) {
+	defer db.lock(ctx)()
+
+	lc.UUID = ids.Or(ctx, lc.UUID, db.newID)
+	lc.MTime = db.now()
+	lc.CTime = lc.MTime
+
+	if !db.validLifecycle(lc) {
+		return lc, fmt.Errorf("lifecycle was not added")
+	}
+
+	db.lifecycles[lc.UUID] = &lifecycleRow{
+		location:   lc.Location,
+		strainCost: lc.StrainCost,
+		grainCost:  lc.GrainCost,
+		bulkCost:   lc.BulkCost,
+		yield:      lc.Yield,
+		count:      lc.Count,
+		gross:      lc.Gross,
+		strain:     lc.Strain.UUID,
+		grain:      lc.GrainSubstrate.UUID,
+		bulk:       lc.BulkSubstrate.UUID,
+		mtime:      lc.MTime,
+		ctime:      lc.CTime,
+	}
+
+	return db.selectLifecycle(lc.UUID)
+}
+
+func (db *DB) UpdateLifecycle(ctx context.Context, lc types.Lifecycle, _ types.CID) (types.Lifecycle, error) {
+	defer db.lock(ctx)()
+
+	lc.MTime = db.now()
+
+	row, ok := db.lifecycles[lc.UUID]
+	if !ok || !db.validLifecycle(lc) {
+		return lc, fmt.Errorf("one of strain, grain or bulk is not the right type")
+	}
+
+	row.location = lc.Location
+	row.strainCost, row.grainCost, row.bulkCost = lc.StrainCost, lc.GrainCost, lc.BulkCost
+	row.yield, row.count, row.gross = lc.Yield, lc.Count, lc.Gross
+	row.strain, row.grain, row.bulk = lc.Strain.UUID, lc.GrainSubstrate.UUID, lc.BulkSubstrate.UUID
+	row.mtime = lc.MTime
+
+	return lc, nil
+}
+
+// DeleteLifecycle really deletes it, and its events, notes and photos go
+// with it
+func (db *DB) DeleteLifecycle(ctx context.Context, id types.UUID, _ types.CID) error {
+	defer db.lock(ctx)()
+
+	if _, ok := db.lifecycles[id]; !ok {
+		return fmt.Errorf("lifecycle could not be deleted: '%s'", id)
+	}
+
+	delete(db.lifecycles, id)
+	db.deleteNotes(id)
+	for eID, e := range db.events {
+		if e.observable == id {
+			db.deleteEvent(eID)
+		}
+	}
+
+	return nil
+}
+
+func (db *DB) GetLifecycleEvents(ctx context.Context, lc *types.Lifecycle, _ types.CID) error {
+	defer db.rlock(ctx)()
+
+	lc.Events = db.eventsOf(lc.UUID)
+	return nil
+}
+
+func (db *DB) AddLifecycleEvent(ctx context.Context, lc *types.Lifecycle, e types.Event, _ types.CID) error {
+	defer db.lock(ctx)()
+
+	var err error
+	if lc.Events, err = db.addEvent(ctx, lc.UUID, lc.Events, &e); err != nil {
+		return err
+	}
+	lc.MTime, err = db.touch(e.MTime, lc.UUID)
+	return err
+}
+
+func (db *DB) ChangeLifecycleEvent(ctx context.Context, lc *types.Lifecycle, e types.Event, _ types.CID) (types.Event, error) {
+	defer db.lock(ctx)()
+
+	var err error
+	if lc.Events, err = db.changeEvent(lc.Events, &e); err != nil {
+		return e, err
+	}
+	lc.MTime, err = db.touch(e.MTime, lc.UUID)
+	return e, err
+}
+
+func (db *DB) RemoveLifecycleEvent(ctx context.Context, lc *types.Lifecycle, id types.UUID, _ types.CID) error {
+	defer db.lock(ctx)()
+
+	var err error
+	if lc.Events, err = db.removeEvent(lc.Events, id); err != nil {
+		return err
+	}
+	lc.MTime, err = db.touch(db.now(), lc.UUID)
+	return err
+}
+
+func (db *DB) LifecycleReport(ctx context.Context, id types.UUID, cid types.CID) (types.Entity, error) {
+	param, err := types.NewReportAttrs(url.Values{"lifecycle-id": {string(id)}})
+	if err != nil {
+		return nil, err
+	}
+
+	defer db.rlock(ctx)()
+
+	if result, err := db.lifecycleReport(ctx, param, cid, nil); err != nil {
+		return nil, err
+	} else if len(result) != 0 {
+		return result[0], nil
+	}
+
+	return nil, sql.ErrNoRows
+}
+
+func (db *DB) lifecycleReport(ctx context.Context, params types.ReportAttrs, cid types.CID, p *rpttree) ([]types.Entity, error) {
+	lcs, err := db.selectLifecycles(params)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]types.Entity, 0, len(lcs))
+	for _, lc := range lcs {
+		lc.Strain.Attributes = db.strainAttributes(lc.Strain.UUID)
+		lc.GrainSubstrate.Ingredients = db.substrateIngredients(db.substrates[lc.GrainSubstrate.UUID])
+		lc.BulkSubstrate.Ingredients = db.substrateIngredients(db.substrates[lc.BulkSubstrate.UUID])
+		db.notesAndPhotos(lc.Events)
+
+		if e, err := db.report(ctx, lifecycle(lc), cid, p); err != nil {
+			return nil, err
+		} else if e != nil {
+			result = append(result, e)
+		}
+	}
+
+	return result, nil
+}
+
+func (lc lifecycle) children(ctx context.Context, db *DB, cid types.CID, p *rpttree) error {
+	if notes, err := db.notesReport(ctx, lc.UUID, cid, p); err != nil {
+		return err
+	} else if len(notes) != 0 {
+		p.data["notes"] = notes
+	}
+
+	if photos, err := db.photosReport(ctx, lc.Strain.UUID, cid, p); err != nil {
+		return err
+	} else if s, ok := p.data["strain"].(map[string]any); ok && len(photos) != 0 {
+		s["photos"] = photos
+	}
+
+	return nil
+}
@@ -0,0 +1,355 @@
+// Package memory is a types.DB that keeps everything in maps, for demos and
+// for tests that want a database that behaves like the real one without
+// having to run one. It's the same interface with the same behavior as the
+// sqlite package: the same errors for the same mistakes, soft deletes for
+// generations and strains, newest events, notes and photos first, and so on.
+//
+// Nothing is ever written anywhere, so everything is gone when the process
+// is; see Seed for something to start from
+package memory
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/data/ids"
+	"github.com/jsmit257/centerforfunguscontrol/internal/data/txn"
+	"github.com/jsmit257/huautla/types"
+)
+
+type (
+	DB struct {
+		// every exported method holds this for as long as it runs, and the
+		// unexported ones assume it's held, so they can call each other
+		mu sync.RWMutex
+		// and this, shared, unless it's part of the transaction that holds
+		// it exclusively, so nobody else sees or changes anything until the
+		// transaction is finished
+		txMu sync.RWMutex
+
+		newID func() types.UUID
+		now   func() time.Time
+
+		vendors     map[types.UUID]types.Vendor
+		ingredients map[types.UUID]types.Ingredient
+		stages      map[types.UUID]types.Stage
+		substrates  map[types.UUID]*substrateRow
+		eventTypes  map[types.UUID]*eventTypeRow
+		generations map[types.UUID]*generationRow
+		strains     map[types.UUID]*strainRow
+		attributes  map[types.UUID]*attributeRow
+		lifecycles  map[types.UUID]*lifecycleRow
+		events      map[types.UUID]*eventRow
+		notes       map[types.UUID]*noteRow
+		photos      map[types.UUID]*photoRow
+		sources     map[types.UUID]*sourceRow
+	}
+
+	// the rows are what a table would hold: other rows are referred to by
+	// id, and filled in when they're read
+
+	substrateRow struct {
+		name        string
+		kind        types.SubstrateType
+		vendor      types.UUID
+		ingredients []types.UUID
+	}
+
+	eventTypeRow struct {
+		name     string
+		severity string
+		stage    types.UUID
+		mtime    *time.Time
+	}
+
+	generationRow struct {
+		plating, liquid types.UUID
+		mtime, ctime    time.Time
+		dtime           *time.Time
+	}
+
+	strainRow struct {
+		species, name string
+		vendor        types.UUID
+		generation    *types.UUID
+		mtime         *time.Time
+		ctime         time.Time
+		dtime         *time.Time
+	}
+
+	attributeRow struct {
+		name, value string
+		strain      types.UUID
+	}
+
+	lifecycleRow struct {
+		location                        string
+		strainCost, grainCost, bulkCost float32
+		yield                           float32
+		count                           int16
+		gross                           float32
+		strain, grain, bulk             types.UUID
+		mtime, ctime                    time.Time
+	}
+
+	eventRow struct {
+		temperature  float32
+		humidity     int8
+		eventType    types.UUID
+		observable   types.UUID
+		mtime, ctime time.Time
+	}
+
+	noteRow struct {
+		note         string
+		notable      types.UUID
+		mtime, ctime time.Time
+	}
+
+	photoRow struct {
+		filename     string
+		photoable    types.UUID
+		mtime, ctime time.Time
+	}
+
+	sourceRow struct {
+		kind       string
+		progenitor types.UUID
+		generation types.UUID
+		mtime      *time.Time
+	}
+)
+
+var (
+	_ types.DB     = (*DB)(nil)
+	_ ids.Keeper   = (*DB)(nil)
+	_ txn.Beginner = (*DB)(nil)
+)
+
+// stages and eventTypes are what every huautla database starts with; the
+// lifecycle index looks for sunset, sporeprint and clone by id
+var (
+	stages = []types.Stage{
+		{UUID: "any", Name: "Any"},
+		{UUID: "gestation", Name: "Gestation"},
+		{UUID: "colonization", Name: "Colonization"},
+		{UUID: "majority", Name: "Majority"},
+		{UUID: "vacation", Name: "Vacation"},
+	}
+
+	eventTypes = []types.EventType{
+		{UUID: "fullcolonization", Name: "100% colonization", Severity: "Info", Stage: types.Stage{UUID: "any"}},
+		{UUID: "halfcolonization", Name: "50% colonization", Severity: "Info", Stage: types.Stage{UUID: "any"}},
+		{UUID: "clone", Name: "Clone", Severity: "Generation", Stage: types.Stage{UUID: "any"}},
+		{UUID: "sunset", Name: "Sunset", Severity: "Info", Stage: types.Stage{UUID: "any"}},
+		{UUID: "innoculation", Name: "Innoculation", Severity: "Info", Stage: types.Stage{UUID: "colonization"}},
+		{UUID: "redistribute", Name: "Redistribute substrate", Severity: "Info", Stage: types.Stage{UUID: "colonization"}},
+		{UUID: "agarsampling", Name: "Agar sampling", Severity: "Info", Stage: types.Stage{UUID: "gestation"}},
+		{UUID: "liquidinnoculation", Name: "Liquid innoculation", Severity: "Info", Stage: types.Stage{UUID: "gestation"}},
+		{UUID: "binning", Name: "Binning", Severity: "Info", Stage: types.Stage{UUID: "majority"}},
+		{UUID: "harvesting", Name: "Harvesting", Severity: "Info", Stage: types.Stage{UUID: "majority"}},
+		{UUID: "sporeprint", Name: "Spore print", Severity: "Generation", Stage: types.Stage{UUID: "majority"}},
+		{UUID: "chill", Name: "Chill", Severity: "Info", Stage: types.Stage{UUID: "vacation"}},
+	}
+)
+
+// New is an empty database, apart from the stages and event types every
+// database starts with
+func New() *DB {
+	result := &DB{
+		newID: func() types.UUID { return types.UUID(uuid.NewString()) },
+		now:   func() time.Time { return time.Now().UTC() },
+
+		vendors:     map[types.UUID]types.Vendor{},
+		ingredients: map[types.UUID]types.Ingredient{},
+		stages:      map[types.UUID]types.Stage{},
+		substrates:  map[types.UUID]*substrateRow{},
+		eventTypes:  map[types.UUID]*eventTypeRow{},
+		generations: map[types.UUID]*generationRow{},
+		strains:     map[types.UUID]*strainRow{},
+		attributes:  map[types.UUID]*attributeRow{},
+		lifecycles:  map[types.UUID]*lifecycleRow{},
+		events:      map[types.UUID]*eventRow{},
+		notes:       map[types.UUID]*noteRow{},
+		photos:      map[types.UUID]*photoRow{},
+		sources:     map[types.UUID]*sourceRow{},
+	}
+
+	for _, s := range stages {
+		result.stages[s.UUID] = s
+	}
+	for _, et := range eventTypes {
+		result.eventTypes[et.UUID] = &eventTypeRow{
+			name:     et.Name,
+			severity: et.Severity,
+			stage:    et.Stage.UUID,
+		}
+	}
+
+	return result
+}
+
+// KeepsIDs says inserts keep their ids when ids.Keep asks them to
+func (db *DB) KeepsIDs() bool {
+	return true
+}
+
+// lock is for methods that change things, and rlock for ones that only
+// look; both return what undoes them
+func (db *DB) lock(ctx context.Context) func() {
+	unlock := db.enter(ctx)
+	db.mu.Lock()
+	return func() {
+		db.mu.Unlock()
+		unlock()
+	}
+}
+
+func (db *DB) rlock(ctx context.Context) func() {
+	unlock := db.enter(ctx)
+	db.mu.RLock()
+	return func() {
+		db.mu.RUnlock()
+		unlock()
+	}
+}
+
+// enter waits for any transaction ctx isn't part of to finish
+func (db *DB) enter(ctx context.Context) func() {
+	if t, ok := ctx.Value(txKey{db}).(*tx); ok && !t.finished() {
+		return func() {}
+	}
+	db.txMu.RLock()
+	return db.txMu.RUnlock
+}
+
+// Begin starts a transaction that everything done with the ctx it returns
+// is part of; everybody else waits until it's committed or rolled back,
+// and rolling it back puts back everything the way it was at the start
+func (db *DB) Begin(ctx context.Context) (context.Context, txn.Tx, error) {
+	if t, ok := ctx.Value(txKey{db}).(*tx); ok && !t.finished() {
+		return nil, nil, fmt.Errorf("already in a transaction")
+	}
+
+	db.txMu.Lock()
+	db.mu.RLock()
+	t := &tx{db: db, saved: db.tables()}
+	db.mu.RUnlock()
+
+	return context.WithValue(ctx, txKey{db}, t), t, nil
+}
+
+// tables is a copy of everything, that nothing done afterwards changes
+func (db *DB) tables() *DB {
+	substrates := maps.Clone(db.substrates)
+	for id, row := range substrates {
+		c := *row
+		c.ingredients = slices.Clone(row.ingredients)
+		substrates[id] = &c
+	}
+
+	return &DB{
+		vendors:     maps.Clone(db.vendors),
+		ingredients: maps.Clone(db.ingredients),
+		stages:      maps.Clone(db.stages),
+		substrates:  substrates,
+		eventTypes:  cloneRows(db.eventTypes),
+		generations: cloneRows(db.generations),
+		strains:     cloneRows(db.strains),
+		attributes:  cloneRows(db.attributes),
+		lifecycles:  cloneRows(db.lifecycles),
+		events:      cloneRows(db.events),
+		notes:       cloneRows(db.notes),
+		photos:      cloneRows(db.photos),
+		sources:     cloneRows(db.sources),
+	}
+}
+
+func cloneRows[T any](m map[types.UUID]*T) map[types.UUID]*T {
+	result := make(map[types.UUID]*T, len(m))
+	for id, row := range m {
+		c := *row
+		result[id] = &c
+	}
+	return result
+}
+
+// restore puts back everything tables copied
+func (db *DB) restore(saved *DB) {
+	db.vendors = saved.vendors
+	db.ingredients = saved.ingredients
+	db.stages = saved.stages
+	db.substrates = saved.substrates
+	db.eventTypes = saved.eventTypes
+	db.generations = saved.generations
+	db.strains = saved.strains
+	db.attributes = saved.attributes
+	db.lifecycles = saved.lifecycles
+	db.events = saved.events
+	db.notes = saved.notes
+	db.photos = saved.photos
+	db.sources = saved.sources
+}
+
+type (
+	txKey struct{ db *DB }
+
+	tx struct {
+		db *DB
+		// what to put back on a rollback
+		saved *DB
+
+		mu   sync.Mutex
+		done bool
+	}
+)
+
+func (t *tx) finished() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.done
+}
+
+func (t *tx) Commit() error {
+	return t.finish(nil)
+}
+
+func (t *tx) Rollback() error {
+	return t.finish(t.saved)
+}
+
+// finish lets everybody else back in, after putting back saved if there is
+// one; only the first call does anything
+func (t *tx) finish(saved *DB) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.done {
+		return nil
+	}
+	t.done = true
+
+	if saved != nil {
+		t.db.mu.Lock()
+		t.db.restore(saved)
+		t.db.mu.Unlock()
+	}
+	t.db.txMu.Unlock()
+	return nil
+}
+
+// inUse is what deleting something that's still referred to says, where
+// a database would complain about a foreign key
+func inUse(table string, id types.UUID, by string) error {
+	return fmt.Errorf("%s could not be deleted: '%s' is still used by %s", table, id, by)
+}
+
+// ptr is a copy of t that nothing else points to
+func ptr[T any](t T) *T {
+	return &t
+}
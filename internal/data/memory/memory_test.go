@@ -0,0 +1,162 @@
+package memory
+
+import (
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/data/dbtest"
+	"github.com/jsmit257/centerforfunguscontrol/shared/metrics"
+	"github.com/jsmit257/huautla/types"
+)
+
+func Test_Seed(t *testing.T) {
+	t.Parallel()
+
+	db := New()
+	ctx := metrics.MockServiceContext
+	cid := types.CID("Test_Seed")
+	now := db.now()
+
+	require.Nil(t, db.Seed(ctx))
+
+	vendors, err := db.SelectAllVendors(ctx, cid)
+	require.Nil(t, err)
+	require.Len(t, vendors, 4)
+
+	strains, err := db.SelectAllStrains(ctx, cid)
+	require.Nil(t, err)
+	require.Len(t, strains, 5)
+
+	gens, err := db.SelectGenerationIndex(ctx, cid)
+	require.Nil(t, err)
+	require.Len(t, gens, 2)
+
+	ndx, err := db.SelectLifecycleIndex(ctx, cid)
+	require.Nil(t, err)
+	require.Len(t, ndx, 4)
+
+	// everything happened in the past, and every report has something in it
+	for _, lc := range ndx {
+		require.True(t, lc.CTime.Before(now), lc.CTime)
+		require.True(t, lc.MTime.Before(now), lc.MTime)
+
+		rpt, err := db.LifecycleReport(ctx, lc.UUID, cid)
+		require.Nil(t, err)
+		require.NotEmpty(t, rpt["events"])
+	}
+	for _, g := range gens {
+		require.Len(t, g.Sources, 1)
+
+		_, err := db.GenerationReport(ctx, g.UUID, cid)
+		require.Nil(t, err)
+	}
+	for _, v := range vendors {
+		_, err := db.VendorReport(ctx, v.UUID, cid)
+		require.Nil(t, err)
+	}
+
+	// the clock is back to normal afterwards
+	require.False(t, db.now().Before(now))
+}
+
+func Test_concurrent(t *testing.T) {
+	t.Parallel()
+
+	db := New()
+	ctx := metrics.MockServiceContext
+	cid := types.CID("Test_concurrent")
+
+	require.Nil(t, db.Seed(ctx))
+	ndx, err := db.SelectLifecycleIndex(ctx, cid)
+	require.Nil(t, err)
+
+	// run with -race to be sure of anything
+	errs := make(chan error, 8*20*3)
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(lc types.Lifecycle) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				errs <- db.AddLifecycleEvent(ctx, &lc, types.Event{EventType: types.EventType{UUID: "chill"}}, cid)
+				_, err := db.LifecycleReport(ctx, lc.UUID, cid)
+				errs <- err
+				_, err = db.SelectLifecycleIndex(ctx, cid)
+				errs <- err
+			}
+		}(ndx[i%len(ndx)])
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.Nil(t, err)
+	}
+
+	events, err := db.SelectByEventType(ctx, types.EventType{UUID: "chill"}, cid)
+	require.Nil(t, err)
+	require.Len(t, events, 160)
+}
+
+func Test_conformance(t *testing.T) {
+	t.Parallel()
+
+	db := New()
+	dbtest.Run(t, func(*testing.T) types.DB { return db })
+}
+
+func Test_Begin(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		commit bool
+		want   []string
+	}{
+		"committed":   {commit: true, want: []string{"changed", "new"}},
+		"rolled_back": {want: []string{"old"}},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			db := New()
+			cid := types.CID(t.Name())
+			v, err := db.InsertVendor(metrics.MockServiceContext, types.Vendor{Name: "old"}, cid)
+			require.Nil(t, err)
+
+			ctx, tx, err := db.Begin(metrics.MockServiceContext)
+			require.Nil(t, err)
+			_, _, err = db.Begin(ctx)
+			require.NotNil(t, err, "transactions don't nest")
+
+			_, err = db.InsertVendor(ctx, types.Vendor{Name: "new"}, cid)
+			require.Nil(t, err)
+			require.Nil(t, db.UpdateVendor(ctx, v.UUID, types.Vendor{Name: "changed"}, cid))
+
+			// anybody else waits until it's finished
+			seen := make(chan []types.Vendor)
+			go func() {
+				vendors, _ := db.SelectAllVendors(metrics.MockServiceContext, cid)
+				seen <- vendors
+			}()
+
+			if tc.commit {
+				require.Nil(t, tx.Commit())
+			} else {
+				require.Nil(t, tx.Rollback())
+			}
+			require.Nil(t, tx.Rollback(), "finishing twice is harmless")
+
+			var names []string
+			for _, v := range <-seen {
+				names = append(names, v.Name)
+			}
+			sort.Strings(names)
+			require.Equal(t, tc.want, names)
+		})
+	}
+}
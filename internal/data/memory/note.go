@@ -0,0 +1,96 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/data/ids"
+	"github.com/jsmit257/huautla/types"
+)
+
+// GetNotes is every note on id, newest first
+func (db *DB) GetNotes(ctx context.Context, id types.UUID, _ types.CID) ([]types.Note, error) {
+	defer db.rlock(ctx)()
+
+	return db.notesOf(id), nil
+}
+
+// notesOf is every note on id, newest first; it's nil if there aren't any
+func (db *DB) notesOf(id types.UUID) []types.Note {
+	var result []types.Note
+	for nID, n := range db.notes {
+		if n.notable == id {
+			result = append(result, types.Note{UUID: nID, Note: n.note, MTime: n.mtime, CTime: n.ctime})
+		}
+	}
+	slices.SortFunc(result, func(a, b types.Note) int {
+		return cmp.Or(b.MTime.Compare(a.MTime), strings.Compare(string(a.UUID), string(b.UUID)))
+	})
+	return result
+}
+
+func (db *DB) AddNote(ctx context.Context, oID types.UUID, notes []types.Note, n types.Note, _ types.CID) ([]types.Note, error) {
+	defer db.lock(ctx)()
+
+	n.UUID = ids.Or(ctx, n.UUID, db.newID)
+	n.MTime = db.now()
+	n.CTime = n.MTime
+
+	db.notes[n.UUID] = &noteRow{note: n.Note, notable: oID, mtime: n.MTime, ctime: n.CTime}
+
+	return append([]types.Note{n}, notes...), nil
+}
+
+// ChangeNote changes n, and moves it to the front of notes since it's now
+// the newest
+func (db *DB) ChangeNote(ctx context.Context, notes []types.Note, n types.Note, _ types.CID) ([]types.Note, error) {
+	defer db.lock(ctx)()
+
+	n.MTime = db.now()
+
+	row, ok := db.notes[n.UUID]
+	if !ok {
+		return notes, fmt.Errorf("note was not changed")
+	}
+	row.note, row.mtime = n.Note, n.MTime
+
+	rest := slices.DeleteFunc(slices.Clone(notes), func(old types.Note) bool { return old.UUID == n.UUID })
+	return append([]types.Note{n}, rest...), nil
+}
+
+func (db *DB) RemoveNote(ctx context.Context, notes []types.Note, id types.UUID, _ types.CID) ([]types.Note, error) {
+	defer db.lock(ctx)()
+
+	if _, ok := db.notes[id]; !ok {
+		return notes, fmt.Errorf("note could not be removed")
+	}
+	delete(db.notes, id)
+	return slices.DeleteFunc(notes, func(old types.Note) bool { return old.UUID == id }), nil
+}
+
+// deleteNotes deletes every note on id
+func (db *DB) deleteNotes(id types.UUID) {
+	for nID, n := range db.notes {
+		if n.notable == id {
+			delete(db.notes, nID)
+		}
+	}
+}
+
+func (db *DB) notesReport(ctx context.Context, id types.UUID, cid types.CID, p *rpttree) ([]types.Entity, error) {
+	notes := db.notesOf(id)
+
+	result := make([]types.Entity, 0, len(notes))
+	for _, n := range notes {
+		if e, err := db.report(ctx, n, cid, p); err != nil {
+			return nil, err
+		} else if e != nil {
+			result = append(result, e)
+		}
+	}
+
+	return result, nil
+}
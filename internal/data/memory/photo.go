@@ -0,0 +1,100 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/data/ids"
+	"github.com/jsmit257/huautla/types"
+)
+
+// GetPhotos is every photo of id, newest first, each with its notes
+func (db *DB) GetPhotos(ctx context.Context, id types.UUID, _ types.CID) ([]types.Photo, error) {
+	defer db.rlock(ctx)()
+
+	return db.photosOf(id), nil
+}
+
+// photosOf is every photo of id, newest first, each with its notes; it's
+// nil if there aren't any
+func (db *DB) photosOf(id types.UUID) []types.Photo {
+	var result []types.Photo
+	for pID, p := range db.photos {
+		if p.photoable == id {
+			result = append(result, types.Photo{
+				UUID:     pID,
+				Filename: p.filename,
+				Notes:    db.notesOf(pID),
+				MTime:    p.mtime,
+				CTime:    p.ctime,
+			})
+		}
+	}
+	slices.SortFunc(result, func(a, b types.Photo) int {
+		return cmp.Or(b.MTime.Compare(a.MTime), strings.Compare(string(a.UUID), string(b.UUID)))
+	})
+	return result
+}
+
+func (db *DB) AddPhoto(ctx context.Context, id types.UUID, photos []types.Photo, p types.Photo, _ types.CID) ([]types.Photo, error) {
+	defer db.lock(ctx)()
+
+	p.UUID = ids.Or(ctx, p.UUID, db.newID)
+	p.CTime = db.now()
+	p.MTime = p.CTime
+
+	db.photos[p.UUID] = &photoRow{filename: p.Filename, photoable: id, mtime: p.MTime, ctime: p.CTime}
+
+	return append([]types.Photo{p}, photos...), nil
+}
+
+// ChangePhoto changes p, and moves it to the front of photos since it's
+// now the newest
+func (db *DB) ChangePhoto(ctx context.Context, photos []types.Photo, p types.Photo, _ types.CID) ([]types.Photo, error) {
+	defer db.lock(ctx)()
+
+	p.MTime = db.now()
+
+	row, ok := db.photos[p.UUID]
+	if !ok {
+		return photos, fmt.Errorf("photo was not changed")
+	}
+	row.filename, row.mtime = p.Filename, p.MTime
+
+	rest := slices.DeleteFunc(slices.Clone(photos), func(old types.Photo) bool { return old.UUID == p.UUID })
+	return append([]types.Photo{p}, rest...), nil
+}
+
+func (db *DB) RemovePhoto(ctx context.Context, photos []types.Photo, id types.UUID, _ types.CID) ([]types.Photo, error) {
+	defer db.lock(ctx)()
+
+	if _, ok := db.photos[id]; !ok {
+		return photos, fmt.Errorf("photo could not be removed")
+	}
+	db.deletePhoto(id)
+	return slices.DeleteFunc(photos, func(old types.Photo) bool { return old.UUID == id }), nil
+}
+
+// deletePhoto deletes id and its notes
+func (db *DB) deletePhoto(id types.UUID) {
+	delete(db.photos, id)
+	db.deleteNotes(id)
+}
+
+func (db *DB) photosReport(ctx context.Context, id types.UUID, cid types.CID, p *rpttree) ([]types.Entity, error) {
+	photos := db.photosOf(id)
+
+	result := make([]types.Entity, 0, len(photos))
+	for _, photo := range photos {
+		if e, err := db.report(ctx, photo, cid, p); err != nil {
+			return nil, err
+		} else if e != nil {
+			result = append(result, e)
+		}
+	}
+
+	return result, nil
+}
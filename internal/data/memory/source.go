@@ -0,0 +1,117 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/data/ids"
+	"github.com/jsmit257/huautla/types"
+)
+
+// sourcesOf is where generation id came from; a source that's an event
+// comes with its lifecycle, and just that one event. It's nil if there
+// aren't any
+func (db *DB) sourcesOf(id types.UUID) []types.Source {
+	var result []types.Source
+	for sID, s := range db.sources {
+		if s.generation != id {
+			continue
+		}
+
+		// a source whose strain is gone is left out, rather than half
+		// filled in
+		st, lcID, ok := db.progenitor(s)
+		if !ok {
+			continue
+		}
+
+		source := types.Source{UUID: sID, Type: s.kind, Strain: db.strain(st)}
+		source.Strain.Generation = nil
+		if lcID != nil {
+			lc := db.lifecycle(*lcID)
+			if i := slices.IndexFunc(lc.Events, func(e types.Event) bool { return e.UUID == s.progenitor }); i >= 0 {
+				lc.Events = lc.Events[i : i+1]
+			}
+			source.Lifecycle = &lc
+		}
+		result = append(result, source)
+	}
+
+	slices.SortFunc(result, func(a, b types.Source) int {
+		return strings.Compare(string(a.UUID), string(b.UUID))
+	})
+	return result
+}
+
+// progenitor is the strain s came from, and the lifecycle too if it came
+// from an event in one; it's not ok if either of them is gone
+func (db *DB) progenitor(s *sourceRow) (types.UUID, *types.UUID, bool) {
+	if e, ok := db.events[s.progenitor]; !ok {
+	} else if lc, ok := db.lifecycles[e.observable]; !ok {
+		return "", nil, false
+	} else {
+		return lc.strain, ptr(e.observable), true
+	}
+
+	_, ok := db.strains[s.progenitor]
+	return s.progenitor, nil, ok
+}
+
+// InsertSource adds a source to genid; if origin is strain, s.Strain is
+// where it came from, and if it's event, it's the first of
+// s.Lifecycle.Events
+func (db *DB) InsertSource(ctx context.Context, genid types.UUID, origin string, s types.Source, _ types.CID) (types.Source, error) {
+	progenitor := s.Strain.UUID
+	if origin == "event" {
+		if s.Lifecycle == nil || len(s.Lifecycle.Events) == 0 {
+			return types.Source{}, fmt.Errorf("an event source needs a lifecycle with an event")
+		}
+		progenitor = s.Lifecycle.Events[0].UUID
+	} else if origin != "strain" {
+		return types.Source{}, fmt.Errorf("only origins of type 'strain' and 'event' are allowed: '%s'", origin)
+	}
+
+	defer db.lock(ctx)()
+
+	if _, ok := db.generations[genid]; !ok {
+		return types.Source{}, fmt.Errorf("source was not added")
+	}
+
+	s.UUID = ids.Or(ctx, s.UUID, db.newID)
+	db.sources[s.UUID] = &sourceRow{
+		kind:       s.Type,
+		progenitor: progenitor,
+		generation: genid,
+		mtime:      ptr(db.now()),
+	}
+
+	return s, nil
+}
+
+func (db *DB) UpdateSource(ctx context.Context, origin string, s types.Source, _ types.CID) error {
+	if origin != "event" && origin != "strain" {
+		return fmt.Errorf("only origins of type 'strain' and 'event' are allowed: '%s'", origin)
+	}
+
+	defer db.lock(ctx)()
+
+	row, ok := db.sources[s.UUID]
+	if !ok {
+		return fmt.Errorf("source was not changed")
+	}
+	row.kind, row.mtime = s.Type, ptr(db.now())
+	return nil
+}
+
+func (db *DB) RemoveSource(ctx context.Context, g *types.Generation, id types.UUID, _ types.CID) error {
+	defer db.lock(ctx)()
+
+	if _, ok := db.sources[id]; !ok {
+		return fmt.Errorf("source could not be deleted: '%s'", id)
+	}
+	delete(db.sources, id)
+	g.Sources = slices.DeleteFunc(g.Sources, func(s types.Source) bool { return s.UUID == id })
+	return nil
+}
@@ -0,0 +1,70 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/data/ids"
+	"github.com/jsmit257/huautla/types"
+)
+
+func (db *DB) SelectAllStages(ctx context.Context, _ types.CID) ([]types.Stage, error) {
+	defer db.rlock(ctx)()
+
+	result := make([]types.Stage, 0, len(db.stages))
+	for _, s := range db.stages {
+		result = append(result, s)
+	}
+	slices.SortFunc(result, func(a, b types.Stage) int {
+		return cmp.Or(strings.Compare(a.Name, b.Name), strings.Compare(string(a.UUID), string(b.UUID)))
+	})
+
+	return result, nil
+}
+
+func (db *DB) SelectStage(ctx context.Context, id types.UUID, _ types.CID) (types.Stage, error) {
+	defer db.rlock(ctx)()
+
+	if s, ok := db.stages[id]; ok {
+		return s, nil
+	}
+	return types.Stage{UUID: id}, sql.ErrNoRows
+}
+
+func (db *DB) InsertStage(ctx context.Context, s types.Stage, _ types.CID) (types.Stage, error) {
+	defer db.lock(ctx)()
+
+	s.UUID = ids.Or(ctx, s.UUID, db.newID)
+	db.stages[s.UUID] = s
+	return s, nil
+}
+
+func (db *DB) UpdateStage(ctx context.Context, id types.UUID, s types.Stage, _ types.CID) error {
+	defer db.lock(ctx)()
+
+	if _, ok := db.stages[id]; !ok {
+		return fmt.Errorf("stage was not updated: '%s'", id)
+	}
+	s.UUID = id
+	db.stages[id] = s
+	return nil
+}
+
+func (db *DB) DeleteStage(ctx context.Context, id types.UUID, _ types.CID) error {
+	defer db.lock(ctx)()
+
+	if _, ok := db.stages[id]; !ok {
+		return fmt.Errorf("stage could not be deleted: '%s'", id)
+	}
+	for _, et := range db.eventTypes {
+		if et.stage == id {
+			return inUse("stage", id, "an eventtype")
+		}
+	}
+	delete(db.stages, id)
+	return nil
+}
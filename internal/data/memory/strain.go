@@ -0,0 +1,223 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/data/ids"
+	"github.com/jsmit257/huautla/types"
+)
+
+func (db *DB) SelectAllStrains(ctx context.Context, _ types.CID) ([]types.Strain, error) {
+	defer db.rlock(ctx)()
+
+	return db.findStrains(func(types.UUID, *strainRow) bool { return true }), nil
+}
+
+func (db *DB) SelectStrain(ctx context.Context, id types.UUID, _ types.CID) (types.Strain, error) {
+	defer db.rlock(ctx)()
+
+	if _, ok := db.strains[id]; !ok {
+		return types.Strain{}, sql.ErrNoRows
+	}
+	result := db.strain(id)
+	result.Attributes = db.strainAttributes(id)
+	return result, nil
+}
+
+func (db *DB) selectStrains(p types.ReportAttrs) []types.Strain {
+	sID, vID := p.Get("strain-id"), p.Get("vendor-id")
+
+	result := db.findStrains(func(id types.UUID, row *strainRow) bool {
+		return (sID == nil || *sID == id) && (vID == nil || *vID == row.vendor)
+	})
+	for i := range result {
+		result[i].Attributes = db.strainAttributes(result[i].UUID)
+	}
+
+	return result
+}
+
+// findStrains is every strain that matches, deleted or not, by name
+func (db *DB) findStrains(match func(types.UUID, *strainRow) bool) []types.Strain {
+	result := make([]types.Strain, 0, len(db.strains))
+	for id, row := range db.strains {
+		if match(id, row) {
+			result = append(result, db.strain(id))
+		}
+	}
+	slices.SortFunc(result, func(a, b types.Strain) int {
+		return cmp.Or(
+			strings.Compare(a.Name, b.Name),
+			a.CTime.Compare(b.CTime),
+			strings.Compare(string(a.UUID), string(b.UUID)))
+	})
+	return result
+}
+
+// strain is the row with id, with its vendor, and the id of the generation
+// it came from, if it did; id has to be there
+func (db *DB) strain(id types.UUID) types.Strain {
+	row := db.strains[id]
+	result := types.Strain{
+		UUID:    id,
+		Species: row.species,
+		Name:    row.name,
+		Vendor:  db.vendors[row.vendor],
+		CTime:   row.ctime,
+		DTime:   row.dtime,
+	}
+	if row.generation != nil {
+		result.Generation = &types.Generation{UUID: *row.generation}
+	}
+	return result
+}
+
+func (db *DB) InsertStrain(ctx context.Context, s types.Strain, _ types.CID) (types.Strain, error) {
+	defer db.lock(ctx)()
+
+	s.UUID = ids.Or(ctx, s.UUID, db.newID)
+	s.CTime = db.now()
+	// the most likely reason for nothing to be added is a bad vendor
+	if _, ok := db.vendors[s.Vendor.UUID]; !ok {
+		return s, fmt.Errorf("strain was not added")
+	}
+	db.strains[s.UUID] = &strainRow{species: s.Species, name: s.Name, vendor: s.Vendor.UUID, ctime: s.CTime}
+	return s, nil
+}
+
+func (db *DB) UpdateStrain(ctx context.Context, id types.UUID, s types.Strain, _ types.CID) error {
+	defer db.lock(ctx)()
+
+	row, ok := db.strains[id]
+	if !ok {
+		return fmt.Errorf("strain was not updated: '%s'", id)
+	} else if _, ok = db.vendors[s.Vendor.UUID]; !ok {
+		return fmt.Errorf("strain was not updated: '%s'", id)
+	}
+	row.species, row.name, row.vendor = s.Species, s.Name, s.Vendor.UUID
+	return nil
+}
+
+// DeleteStrain only marks the strain deleted, Undelete takes it back
+func (db *DB) DeleteStrain(ctx context.Context, id types.UUID, _ types.CID) error {
+	defer db.lock(ctx)()
+
+	row, ok := db.strains[id]
+	if !ok {
+		return fmt.Errorf("strain could not be deleted: '%s'", id)
+	}
+	now := db.now()
+	row.mtime, row.dtime = ptr(now), ptr(now)
+	return nil
+}
+
+func (db *DB) GeneratedStrain(ctx context.Context, id types.UUID, _ types.CID) (types.Strain, error) {
+	defer db.rlock(ctx)()
+
+	return db.generatedStrain(id)
+}
+
+// generatedStrain is the first strain, by name, that came from generation
+// id
+func (db *DB) generatedStrain(id types.UUID) (types.Strain, error) {
+	strs := db.findStrains(func(_ types.UUID, row *strainRow) bool {
+		return row.generation != nil && *row.generation == id
+	})
+	if len(strs) == 0 {
+		return types.Strain{}, sql.ErrNoRows
+	}
+	// the query this mimics doesn't say which generation, it's the one asked
+	// about
+	strs[0].Generation = nil
+	return strs[0], nil
+}
+
+func (db *DB) UpdateGeneratedStrain(ctx context.Context, gid *types.UUID, sid types.UUID, _ types.CID) error {
+	defer db.lock(ctx)()
+
+	row, ok := db.strains[sid]
+	if !ok {
+		return sql.ErrNoRows
+	} else if gid == nil {
+		row.generation = nil
+	} else if _, ok = db.generations[*gid]; !ok {
+		return fmt.Errorf("generation '%s' doesn't exist", *gid)
+	} else {
+		row.generation = ptr(*gid)
+	}
+	return nil
+}
+
+func (db *DB) StrainReport(ctx context.Context, id types.UUID, cid types.CID) (types.Entity, error) {
+	param, err := types.NewReportAttrs(url.Values{"strain-id": {string(id)}})
+	if err != nil {
+		return nil, err
+	}
+
+	defer db.rlock(ctx)()
+
+	if result, err := db.strainReport(ctx, param, cid, nil); err != nil {
+		return nil, err
+	} else if len(result) == 1 {
+		return result[0], nil
+	}
+
+	return nil, sql.ErrNoRows
+}
+
+func (db *DB) strainReport(ctx context.Context, params types.ReportAttrs, cid types.CID, p *rpttree) ([]types.Entity, error) {
+	strs := db.selectStrains(params)
+
+	result := make([]types.Entity, 0, len(strs))
+	for _, str := range strs {
+		if e, err := db.report(ctx, strain(str), cid, p); err != nil {
+			return nil, err
+		} else if e != nil {
+			result = append(result, e)
+		}
+	}
+
+	return result, nil
+}
+
+func (s strain) children(ctx context.Context, db *DB, cid types.CID, p *rpttree) error {
+	param, _ := types.NewReportAttrs(url.Values{"strain-id": {string(s.UUID)}})
+
+	if gens, err := db.generationReport(ctx, param, cid, p); err != nil {
+		return err
+	} else if len(gens) != 0 {
+		p.data["generations"] = gens
+	}
+
+	if lcs, err := db.lifecycleReport(ctx, param, cid, p); err != nil {
+		return err
+	} else if len(lcs) != 0 {
+		p.data["lifecycles"] = lcs
+	}
+
+	if photos, err := db.photosReport(ctx, s.UUID, cid, p); err != nil {
+		return err
+	} else if len(photos) != 0 {
+		p.data["photos"] = photos
+	}
+
+	if s.Generation == nil {
+		return nil
+	}
+
+	param, _ = types.NewReportAttrs(url.Values{"generation-id": {string(s.Generation.UUID)}})
+	if gens, err := db.generationReport(ctx, param, cid, p); err != nil {
+		return err
+	} else if len(gens) != 0 {
+		// it's empty if the generation is already further up the report
+		p.data["generation"] = gens[0]
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/data/ids"
+	"github.com/jsmit257/huautla/types"
+)
+
+func (db *DB) KnownAttributeNames(ctx context.Context, _ types.CID) ([]string, error) {
+	defer db.rlock(ctx)()
+
+	result := []string{}
+	for _, a := range db.attributes {
+		if !slices.Contains(result, a.name) {
+			result = append(result, a.name)
+		}
+	}
+	slices.Sort(result)
+
+	return result, nil
+}
+
+func (db *DB) GetAllAttributes(ctx context.Context, s *types.Strain, _ types.CID) error {
+	defer db.rlock(ctx)()
+
+	s.Attributes = db.strainAttributes(s.UUID)
+	return nil
+}
+
+// strainAttributes is every attribute of strain id, by name
+func (db *DB) strainAttributes(id types.UUID) []types.StrainAttribute {
+	result := make([]types.StrainAttribute, 0, 100)
+	for aID, a := range db.attributes {
+		if a.strain == id {
+			result = append(result, types.StrainAttribute{UUID: aID, Name: a.name, Value: a.value})
+		}
+	}
+	slices.SortFunc(result, func(a, b types.StrainAttribute) int {
+		return cmp.Or(strings.Compare(a.Name, b.Name), strings.Compare(string(a.UUID), string(b.UUID)))
+	})
+	return result
+}
+
+func (db *DB) AddAttribute(ctx context.Context, s *types.Strain, a types.StrainAttribute, _ types.CID) (types.StrainAttribute, error) {
+	defer db.lock(ctx)()
+
+	a.UUID = ids.Or(ctx, a.UUID, db.newID)
+	if _, ok := db.strains[s.UUID]; !ok {
+		return a, fmt.Errorf("attribute was not added")
+	}
+	db.attributes[a.UUID] = &attributeRow{name: a.Name, value: a.Value, strain: s.UUID}
+	s.Attributes = append(s.Attributes, a)
+	return a, nil
+}
+
+func (db *DB) ChangeAttribute(ctx context.Context, s *types.Strain, a types.StrainAttribute, _ types.CID) error {
+	defer db.lock(ctx)()
+
+	row, ok := db.attributes[a.UUID]
+	if !ok {
+		return fmt.Errorf("attribute was not changed")
+	}
+	row.name, row.value = a.Name, a.Value
+	if i := slices.IndexFunc(s.Attributes, func(old types.StrainAttribute) bool { return old.UUID == a.UUID }); i >= 0 {
+		s.Attributes[i] = a
+	}
+	return nil
+}
+
+func (db *DB) RemoveAttribute(ctx context.Context, s *types.Strain, id types.UUID, _ types.CID) error {
+	defer db.lock(ctx)()
+
+	if _, ok := db.attributes[id]; !ok {
+		return fmt.Errorf("attribute was not removed")
+	}
+	delete(db.attributes, id)
+	s.Attributes = slices.DeleteFunc(s.Attributes, func(old types.StrainAttribute) bool { return old.UUID == id })
+	return nil
+}
@@ -0,0 +1,188 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/data/ids"
+	"github.com/jsmit257/huautla/types"
+)
+
+func (db *DB) SelectAllSubstrates(ctx context.Context, _ types.CID) ([]types.Substrate, error) {
+	defer db.rlock(ctx)()
+
+	return db.findSubstrates(func(types.UUID, *substrateRow) bool { return true }), nil
+}
+
+func (db *DB) SelectSubstrate(ctx context.Context, id types.UUID, _ types.CID) (types.Substrate, error) {
+	defer db.rlock(ctx)()
+
+	row, ok := db.substrates[id]
+	if !ok {
+		return types.Substrate{}, sql.ErrNoRows
+	}
+	result := db.substrate(id)
+	result.Ingredients = db.substrateIngredients(row)
+	return result, nil
+}
+
+func (db *DB) selectSubstrates(param types.ReportAttrs) ([]types.Substrate, error) {
+	if !param.Contains("substrate-id", "vendor-id") {
+		return nil, fmt.Errorf("request doesn't contain at least 1 required field: %#v", param)
+	}
+
+	subID, vID := param.Get("substrate-id"), param.Get("vendor-id")
+	return db.findSubstrates(func(id types.UUID, row *substrateRow) bool {
+		return (subID == nil || *subID == id) && (vID == nil || *vID == row.vendor)
+	}), nil
+}
+
+// findSubstrates is every substrate that matches, by name, with its vendor
+// and ingredients
+func (db *DB) findSubstrates(match func(types.UUID, *substrateRow) bool) []types.Substrate {
+	result := make([]types.Substrate, 0, len(db.substrates))
+	for id, row := range db.substrates {
+		if match(id, row) {
+			s := db.substrate(id)
+			s.Ingredients = db.substrateIngredients(row)
+			result = append(result, s)
+		}
+	}
+	slices.SortFunc(result, func(a, b types.Substrate) int {
+		return cmp.Or(strings.Compare(a.Name, b.Name), strings.Compare(string(a.UUID), string(b.UUID)))
+	})
+	return result
+}
+
+// substrate is the row with id, with its vendor but not its ingredients;
+// id has to be there
+func (db *DB) substrate(id types.UUID) types.Substrate {
+	row := db.substrates[id]
+	return types.Substrate{
+		UUID:   id,
+		Name:   row.name,
+		Type:   row.kind,
+		Vendor: db.vendors[row.vendor],
+	}
+}
+
+// substrateOf is whether id is a substrate of kind
+func (db *DB) substrateOf(id types.UUID, kind types.SubstrateType) bool {
+	row, ok := db.substrates[id]
+	return ok && row.kind == kind
+}
+
+func (db *DB) InsertSubstrate(ctx context.Context, s types.Substrate, _ types.CID) (types.Substrate, error) {
+	defer db.lock(ctx)()
+
+	s.UUID = ids.Or(ctx, s.UUID, db.newID)
+	// the most likely reason for nothing to be added is a bad vendor
+	if _, ok := db.vendors[s.Vendor.UUID]; !ok {
+		return s, fmt.Errorf("substrate was not added")
+	} else if !validType(s.Type) {
+		return s, fmt.Errorf("substrate was not added")
+	}
+	db.substrates[s.UUID] = &substrateRow{name: s.Name, kind: s.Type, vendor: s.Vendor.UUID}
+	return s, nil
+}
+
+func (db *DB) UpdateSubstrate(ctx context.Context, id types.UUID, s types.Substrate, _ types.CID) error {
+	defer db.lock(ctx)()
+
+	row, ok := db.substrates[id]
+	if !ok {
+		return fmt.Errorf("substrate was not updated: '%s'", id)
+	} else if _, ok = db.vendors[s.Vendor.UUID]; !ok {
+		return fmt.Errorf("substrate was not updated: '%s'", id)
+	} else if !validType(s.Type) {
+		return fmt.Errorf("substrate was not updated: '%s'", id)
+	}
+	row.name, row.kind, row.vendor = s.Name, s.Type, s.Vendor.UUID
+	return nil
+}
+
+func (db *DB) DeleteSubstrate(ctx context.Context, id types.UUID, _ types.CID) error {
+	defer db.lock(ctx)()
+
+	if _, ok := db.substrates[id]; !ok {
+		return fmt.Errorf("substrate could not be deleted: '%s'", id)
+	}
+	for _, g := range db.generations {
+		if g.plating == id || g.liquid == id {
+			return inUse("substrate", id, "a generation")
+		}
+	}
+	for _, lc := range db.lifecycles {
+		if lc.grain == id || lc.bulk == id {
+			return inUse("substrate", id, "a lifecycle")
+		}
+	}
+	delete(db.substrates, id)
+	return nil
+}
+
+func validType(t types.SubstrateType) bool {
+	switch t {
+	case types.PlatingType, types.LiquidType, types.GrainType, types.BulkType:
+		return true
+	}
+	return false
+}
+
+func (db *DB) SubstrateReport(ctx context.Context, id types.UUID, cid types.CID) (types.Entity, error) {
+	param, err := types.NewReportAttrs(url.Values{"substrate-id": {string(id)}})
+	if err != nil {
+		return nil, err
+	}
+
+	defer db.rlock(ctx)()
+
+	if result, err := db.substrateReport(ctx, param, cid, nil); err != nil {
+		return nil, err
+	} else if len(result) == 1 {
+		return result[0], nil
+	}
+
+	return nil, sql.ErrNoRows
+}
+
+func (db *DB) substrateReport(ctx context.Context, param types.ReportAttrs, cid types.CID, p *rpttree) ([]types.Entity, error) {
+	subs, err := db.selectSubstrates(param)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]types.Entity, 0, len(subs))
+	for _, s := range subs {
+		if e, err := db.report(ctx, substrate(s), cid, p); err != nil {
+			return nil, err
+		} else if e != nil {
+			result = append(result, e)
+		}
+	}
+
+	return result, nil
+}
+
+func (s substrate) children(ctx context.Context, db *DB, cid types.CID, p *rpttree) error {
+	getter, key := db.lifecycleReport, "lifecycles"
+	switch s.Type {
+	case types.PlatingType, types.LiquidType:
+		getter, key = db.generationReport, "generations"
+	}
+
+	if param, err := types.NewReportAttrs(url.Values{fmt.Sprintf("%s-id", s.Type): {string(s.UUID)}}); err != nil {
+		return err
+	} else if values, err := getter(ctx, param, cid, p); err != nil {
+		return err
+	} else if len(values) != 0 {
+		p.data[key] = values
+	}
+
+	return nil
+}
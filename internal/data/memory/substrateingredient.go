@@ -0,0 +1,82 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/jsmit257/huautla/types"
+)
+
+func (db *DB) GetAllIngredients(ctx context.Context, s *types.Substrate, _ types.CID) error {
+	defer db.rlock(ctx)()
+
+	if row, ok := db.substrates[s.UUID]; ok {
+		s.Ingredients = db.substrateIngredients(row)
+	} else {
+		s.Ingredients = []types.Ingredient{}
+	}
+	return nil
+}
+
+// substrateIngredients is what's in row, by name
+func (db *DB) substrateIngredients(row *substrateRow) []types.Ingredient {
+	result := make([]types.Ingredient, 0, len(row.ingredients))
+	for _, id := range row.ingredients {
+		result = append(result, db.ingredients[id])
+	}
+	sortIngredients(result)
+	return result
+}
+
+func (db *DB) AddIngredient(ctx context.Context, s *types.Substrate, i types.Ingredient, _ types.CID) error {
+	defer db.lock(ctx)()
+
+	row, ok := db.substrates[s.UUID]
+	if !ok {
+		return fmt.Errorf("substrateingredient was not added")
+	} else if _, ok = db.ingredients[i.UUID]; !ok {
+		return fmt.Errorf("substrateingredient was not added")
+	} else if slices.Contains(row.ingredients, i.UUID) {
+		return fmt.Errorf("substrateingredient was not added: '%s' is already in '%s'", i.UUID, s.UUID)
+	}
+	row.ingredients = append(row.ingredients, i.UUID)
+	s.Ingredients = append(s.Ingredients, i)
+	return nil
+}
+
+func (db *DB) ChangeIngredient(ctx context.Context, s *types.Substrate, oldI, newI types.Ingredient, _ types.CID) error {
+	defer db.lock(ctx)()
+
+	row, ok := db.substrates[s.UUID]
+	if !ok {
+		return fmt.Errorf("substrateingredient was not changed")
+	} else if _, ok = db.ingredients[newI.UUID]; !ok {
+		return fmt.Errorf("substrateingredient was not changed")
+	} else if slices.Contains(row.ingredients, newI.UUID) {
+		return fmt.Errorf("substrateingredient was not changed: '%s' is already in '%s'", newI.UUID, s.UUID)
+	}
+
+	ndx := slices.Index(row.ingredients, oldI.UUID)
+	if ndx < 0 {
+		return fmt.Errorf("substrateingredient was not changed")
+	}
+	row.ingredients[ndx] = newI.UUID
+
+	if i := slices.IndexFunc(s.Ingredients, func(i types.Ingredient) bool { return i.UUID == oldI.UUID }); i >= 0 {
+		s.Ingredients[i] = newI
+	}
+	return nil
+}
+
+func (db *DB) RemoveIngredient(ctx context.Context, s *types.Substrate, i types.Ingredient, _ types.CID) error {
+	defer db.lock(ctx)()
+
+	row, ok := db.substrates[s.UUID]
+	if !ok || !slices.Contains(row.ingredients, i.UUID) {
+		return fmt.Errorf("substrateingredient was not removed")
+	}
+	row.ingredients = slices.DeleteFunc(row.ingredients, func(id types.UUID) bool { return id == i.UUID })
+	s.Ingredients = slices.DeleteFunc(s.Ingredients, func(old types.Ingredient) bool { return old.UUID == i.UUID })
+	return nil
+}
@@ -0,0 +1,143 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/data/timeshift"
+	"github.com/jsmit257/huautla/types"
+)
+
+// timestamped is which timestamps each table has, by the names the tables
+// have in a real database
+var timestamped = map[string][]string{
+	"event_types": {"mtime"},
+	"events":      {"mtime", "ctime"},
+	"generations": {"mtime", "ctime", "dtime"},
+	"lifecycles":  {"mtime", "ctime"},
+	"notes":       {"mtime", "ctime"},
+	"photos":      {"mtime", "ctime"},
+	"sources":     {"mtime"},
+	"strains":     {"mtime", "ctime", "dtime"},
+}
+
+// UpdateTimestamps sets the fields in data to its origin, moved along by
+// its factors, on the row in table with id
+func (db *DB) UpdateTimestamps(ctx context.Context, table string, id types.UUID, data types.Timestamp) error {
+	if err := data.Validate(); err != nil {
+		return err
+	}
+
+	fields, ok := timestamped[table]
+	if !ok {
+		return fmt.Errorf("%q doesn't have timestamps", table)
+	}
+	for _, f := range data.Fields {
+		if !slices.Contains(fields, f) {
+			return fmt.Errorf("%q doesn't have a %q timestamp", table, f)
+		}
+	}
+
+	when, err := timeshift.Shift(*data.Origin, data)
+	if err != nil {
+		return err
+	}
+
+	defer db.lock(ctx)()
+
+	stamps := db.timestamps(table, id)
+	if stamps == nil {
+		return fmt.Errorf("timestamps were not updated")
+	}
+	for _, f := range data.Fields {
+		stamps[f](when)
+	}
+
+	return nil
+}
+
+// Undelete takes back a delete that only marked something deleted; that's
+// generations and strains
+func (db *DB) Undelete(ctx context.Context, table string, id types.UUID) error {
+	if !slices.Contains(timestamped[table], "dtime") {
+		return fmt.Errorf("%q can't be undeleted", table)
+	}
+
+	defer db.lock(ctx)()
+
+	if g, ok := db.generations[id]; ok && table == "generations" {
+		g.dtime = nil
+	} else if s, ok := db.strains[id]; ok && table == "strains" {
+		s.dtime = nil
+	} else {
+		return fmt.Errorf("record could not be undeleted")
+	}
+
+	return nil
+}
+
+// timestamps is how to set each of the timestamps on the row in table with
+// id; it's nil if there's no such row
+func (db *DB) timestamps(table string, id types.UUID) map[string]func(time.Time) {
+	switch table {
+	case "event_types":
+		if row, ok := db.eventTypes[id]; ok {
+			return map[string]func(time.Time){
+				"mtime": func(t time.Time) { row.mtime = &t },
+			}
+		}
+	case "events":
+		if row, ok := db.events[id]; ok {
+			return map[string]func(time.Time){
+				"mtime": func(t time.Time) { row.mtime = t },
+				"ctime": func(t time.Time) { row.ctime = t },
+			}
+		}
+	case "generations":
+		if row, ok := db.generations[id]; ok {
+			return map[string]func(time.Time){
+				"mtime": func(t time.Time) { row.mtime = t },
+				"ctime": func(t time.Time) { row.ctime = t },
+				"dtime": func(t time.Time) { row.dtime = &t },
+			}
+		}
+	case "lifecycles":
+		if row, ok := db.lifecycles[id]; ok {
+			return map[string]func(time.Time){
+				"mtime": func(t time.Time) { row.mtime = t },
+				"ctime": func(t time.Time) { row.ctime = t },
+			}
+		}
+	case "notes":
+		if row, ok := db.notes[id]; ok {
+			return map[string]func(time.Time){
+				"mtime": func(t time.Time) { row.mtime = t },
+				"ctime": func(t time.Time) { row.ctime = t },
+			}
+		}
+	case "photos":
+		if row, ok := db.photos[id]; ok {
+			return map[string]func(time.Time){
+				"mtime": func(t time.Time) { row.mtime = t },
+				"ctime": func(t time.Time) { row.ctime = t },
+			}
+		}
+	case "sources":
+		if row, ok := db.sources[id]; ok {
+			return map[string]func(time.Time){
+				"mtime": func(t time.Time) { row.mtime = &t },
+			}
+		}
+	case "strains":
+		if row, ok := db.strains[id]; ok {
+			return map[string]func(time.Time){
+				"mtime": func(t time.Time) { row.mtime = &t },
+				"ctime": func(t time.Time) { row.ctime = t },
+				"dtime": func(t time.Time) { row.dtime = &t },
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/data/ids"
+	"github.com/jsmit257/huautla/types"
+)
+
+func (db *DB) SelectAllVendors(ctx context.Context, _ types.CID) ([]types.Vendor, error) {
+	defer db.rlock(ctx)()
+
+	result := make([]types.Vendor, 0, len(db.vendors))
+	for _, v := range db.vendors {
+		result = append(result, v)
+	}
+	slices.SortFunc(result, func(a, b types.Vendor) int {
+		return cmp.Or(strings.Compare(a.Name, b.Name), strings.Compare(string(a.UUID), string(b.UUID)))
+	})
+
+	return result, nil
+}
+
+func (db *DB) SelectVendor(ctx context.Context, id types.UUID, _ types.CID) (types.Vendor, error) {
+	defer db.rlock(ctx)()
+
+	if v, ok := db.vendors[id]; ok {
+		return v, nil
+	}
+	return types.Vendor{}, sql.ErrNoRows
+}
+
+func (db *DB) InsertVendor(ctx context.Context, v types.Vendor, _ types.CID) (types.Vendor, error) {
+	defer db.lock(ctx)()
+
+	v.UUID = ids.Or(ctx, v.UUID, db.newID)
+	db.vendors[v.UUID] = v
+	return v, nil
+}
+
+func (db *DB) UpdateVendor(ctx context.Context, id types.UUID, v types.Vendor, _ types.CID) error {
+	defer db.lock(ctx)()
+
+	if _, ok := db.vendors[id]; !ok {
+		return fmt.Errorf("vendor was not updated: '%s'", id)
+	}
+	v.UUID = id
+	db.vendors[id] = v
+	return nil
+}
+
+func (db *DB) DeleteVendor(ctx context.Context, id types.UUID, _ types.CID) error {
+	defer db.lock(ctx)()
+
+	if _, ok := db.vendors[id]; !ok {
+		return fmt.Errorf("vendor could not be deleted: '%s'", id)
+	}
+	for _, s := range db.substrates {
+		if s.vendor == id {
+			return inUse("vendor", id, "a substrate")
+		}
+	}
+	for _, s := range db.strains {
+		if s.vendor == id {
+			return inUse("vendor", id, "a strain")
+		}
+	}
+	delete(db.vendors, id)
+	return nil
+}
+
+func (db *DB) VendorReport(ctx context.Context, id types.UUID, cid types.CID) (types.Entity, error) {
+	defer db.rlock(ctx)()
+
+	v, ok := db.vendors[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return db.report(ctx, vendor(v), cid, nil)
+}
+
+func (v vendor) children(ctx context.Context, db *DB, cid types.CID, p *rpttree) error {
+	param, _ := types.NewReportAttrs(url.Values{"vendor-id": {string(v.UUID)}})
+
+	if subs, err := db.substrateReport(ctx, param, cid, p); err != nil {
+		return err
+	} else if len(subs) != 0 {
+		p.data["substrates"] = subs
+	}
+
+	if strs, err := db.strainReport(ctx, param, cid, p); err != nil {
+		return err
+	} else if len(strs) != 0 {
+		p.data["strains"] = strs
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package sqlite
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/data/dbtest"
+	"github.com/jsmit257/centerforfunguscontrol/shared/metrics"
+	"github.com/jsmit257/huautla/types"
+)
+
+func newConn(t *testing.T) (*Conn, string) {
+	path := filepath.Join(t.TempDir(), "cffc.db")
+	db, err := Open(metrics.MockServiceContext, path, logrus.WithField("test", t.Name()))
+	require.Nil(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	return db, path
+}
+
+func Test_migrate(t *testing.T) {
+	t.Parallel()
+
+	db, path := newConn(t)
+	ctx := metrics.MockServiceContext
+
+	v, err := db.InsertVendor(ctx, types.Vendor{Name: "spore co"}, "Test_migrate")
+	require.Nil(t, err)
+	require.Nil(t, db.Close())
+
+	// opening it again doesn't apply anything twice, or lose anything
+	db, err = Open(ctx, path, logrus.WithField("test", t.Name()))
+	require.Nil(t, err)
+	defer db.Close()
+
+	var version int
+	require.Nil(t, db.db.QueryRowContext(ctx, `select max(version) from schema_migrations`).Scan(&version))
+	require.Equal(t, len(migrations), version)
+
+	got, err := db.SelectVendor(ctx, v.UUID, "Test_migrate")
+	require.Nil(t, err)
+	require.Equal(t, v, got)
+
+	stages, err := db.SelectAllStages(ctx, "Test_migrate")
+	require.Nil(t, err)
+	require.Len(t, stages, 5)
+}
+
+func Test_conformance(t *testing.T) {
+	t.Parallel()
+
+	dbtest.Run(t, func(t *testing.T) types.DB {
+		db, _ := newConn(t)
+		return db
+	})
+}
@@ -0,0 +1,8 @@
+package sqlite
+
+// the driver runs sqlite compiled to wasm, so it's pure go and the binary
+// stays static, and it's a few MB vendored instead of a few hundred
+import (
+	_ "github.com/ncruces/go-sqlite3/driver"
+	_ "github.com/ncruces/go-sqlite3/embed"
+)
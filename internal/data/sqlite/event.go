@@ -0,0 +1,207 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/data/ids"
+	"github.com/jsmit257/huautla/types"
+)
+
+func (db *Conn) SelectByEventType(ctx context.Context, et types.EventType, _ types.CID) ([]types.Event, error) {
+	return db.selectEventsList(ctx, sqls["event"]["all-by-eventtype"], et.UUID)
+}
+
+func (db *Conn) SelectEvent(ctx context.Context, id types.UUID, _ types.CID) (types.Event, error) {
+	result := types.Event{UUID: id}
+	return result, db.conn(ctx).
+		QueryRowContext(ctx, sqls["event"]["select"], id).
+		Scan(eventFields(&result)...)
+}
+
+func eventFields(e *types.Event) []any {
+	return []any{
+		&e.UUID,
+		&e.Temperature,
+		&e.Humidity,
+		ts(&e.MTime),
+		ts(&e.CTime),
+		&e.EventType.UUID,
+		&e.EventType.Name,
+		&e.EventType.Severity,
+		&e.EventType.Stage.UUID,
+		&e.EventType.Stage.Name,
+	}
+}
+
+func (db *Conn) selectEventsList(ctx context.Context, query string, id types.UUID) ([]types.Event, error) {
+	rows, err := db.conn(ctx).QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]types.Event, 0, 100)
+	for rows.Next() {
+		row := types.Event{}
+		if err = rows.Scan(eventFields(&row)...); err != nil {
+			return nil, err
+		}
+		result = append(result, row)
+	}
+
+	return result, rows.Err()
+}
+
+// notesAndPhotos fills in the notes and photos for events that belong to
+// id, newest first, the way reports show them
+func (db *Conn) notesAndPhotos(ctx context.Context, events []types.Event, id types.UUID) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	evts := make(map[types.UUID]*types.Event, len(events))
+	for i := range events {
+		evts[events[i].UUID] = &events[i]
+	}
+
+	rows, err := db.conn(ctx).QueryContext(ctx, sqls["event"]["notes-and-photos"], id)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	type (
+		nullnote struct {
+			uuid         *types.UUID
+			note         *string
+			mtime, ctime *time.Time
+		}
+		nullphoto struct {
+			uuid         *types.UUID
+			filename     *string
+			mtime, ctime *time.Time
+		}
+	)
+
+	var lastnote *types.Note
+	var lastphoto *types.Photo
+	for rows.Next() {
+		var eventID types.UUID
+		n, p, pn := nullnote{}, nullphoto{}, nullnote{}
+		if err = rows.Scan(
+			&eventID,
+			&n.uuid,
+			&n.note,
+			nts(&n.mtime),
+			nts(&n.ctime),
+			&p.uuid,
+			&p.filename,
+			nts(&p.mtime),
+			nts(&p.ctime),
+			&pn.uuid,
+			&pn.note,
+			nts(&pn.mtime),
+			nts(&pn.ctime),
+		); err != nil {
+			return err
+		}
+
+		evt, ok := evts[eventID]
+		if !ok {
+			continue
+		}
+
+		if n.uuid != nil {
+			note := types.Note{UUID: *n.uuid, Note: *n.note, MTime: *n.mtime, CTime: *n.ctime}
+			if lastnote == nil || *lastnote != note {
+				evt.Notes = append([]types.Note{note}, evt.Notes...)
+			}
+			lastnote = &note
+		}
+
+		if p.uuid != nil {
+			photo := types.Photo{UUID: *p.uuid, Filename: *p.filename, MTime: *p.mtime, CTime: *p.ctime}
+			if pn.uuid != nil {
+				photo.Notes = []types.Note{{UUID: *pn.uuid, Note: *pn.note, MTime: *pn.mtime, CTime: *pn.ctime}}
+			}
+
+			if lastphoto == nil || lastphoto.UUID != photo.UUID {
+				evt.Photos = append([]types.Photo{photo}, evt.Photos...)
+			} else {
+				evt.Photos[0].Notes = append(photo.Notes, evt.Photos[0].Notes...)
+			}
+			lastphoto = &photo
+		}
+	}
+
+	return rows.Err()
+}
+
+// addEvent adds e to whatever oID is, and puts it at the front of events
+func (db *Conn) addEvent(ctx context.Context, oID types.UUID, events []types.Event, e *types.Event, cid types.CID) ([]types.Event, error) {
+	e.UUID = ids.Or(ctx, e.UUID, db.newID)
+	e.MTime = db.now()
+	e.CTime = e.MTime
+
+	// the most likely reason for nothing to be added is a bad event type
+	if err := db.exec(ctx, "event was not added", sqls["event"]["add"],
+		e.UUID,
+		e.Temperature,
+		e.Humidity,
+		stamp(e.MTime),
+		stamp(e.CTime),
+		oID,
+		e.EventType.UUID,
+	); err != nil {
+		return events, err
+	}
+
+	et, err := db.SelectEventType(ctx, e.EventType.UUID, cid)
+	if err != nil {
+		return events, fmt.Errorf("couldn't fetch eventtype")
+	}
+	e.EventType = et
+
+	return append([]types.Event{*e}, events...), nil
+}
+
+// changeEvent changes e, and moves it to the front of events since it's
+// now the newest
+func (db *Conn) changeEvent(ctx context.Context, events []types.Event, e *types.Event, cid types.CID) ([]types.Event, error) {
+	e.MTime = db.now()
+
+	if err := db.exec(ctx, "event was not changed", sqls["event"]["change"],
+		e.Temperature,
+		e.Humidity,
+		stamp(e.MTime),
+		e.UUID,
+		e.EventType.UUID,
+	); err != nil {
+		return events, err
+	}
+
+	et, err := db.SelectEventType(ctx, e.EventType.UUID, cid)
+	if err != nil {
+		return events, fmt.Errorf("couldn't fetch eventtype")
+	}
+	e.EventType = et
+
+	rest := slices.DeleteFunc(slices.Clone(events), func(old types.Event) bool { return old.UUID == e.UUID })
+	return append([]types.Event{*e}, rest...), nil
+}
+
+func (db *Conn) removeEvent(ctx context.Context, events []types.Event, id types.UUID) ([]types.Event, error) {
+	if err := db.exec(ctx, "event could not be removed", sqls["event"]["remove"], id); err != nil {
+		return events, err
+	}
+	return slices.DeleteFunc(events, func(old types.Event) bool { return old.UUID == id }), nil
+}
+
+// touch sets the mtime of whatever id is in table, after one of its events
+// changes
+func (db *Conn) touch(ctx context.Context, table string, modified time.Time, id types.UUID) (time.Time, error) {
+	return modified, db.exec(ctx, "mtime was not updated", fmt.Sprintf(sqls["timestamp"]["touch"], table), stamp(modified), id)
+}
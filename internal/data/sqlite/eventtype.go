@@ -0,0 +1,92 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/data/ids"
+	"github.com/jsmit257/huautla/types"
+)
+
+func (db *Conn) SelectAllEventTypes(ctx context.Context, _ types.CID) ([]types.EventType, error) {
+	rows, err := db.conn(ctx).QueryContext(ctx, sqls["eventtype"]["select-all"])
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]types.EventType, 0, 100)
+	for rows.Next() {
+		row := types.EventType{}
+		if err = rows.Scan(
+			&row.UUID,
+			&row.Name,
+			&row.Severity,
+			&row.Stage.UUID,
+			&row.Stage.Name); err != nil {
+			return nil, err
+		}
+		result = append(result, row)
+	}
+
+	return result, rows.Err()
+}
+
+func (db *Conn) SelectEventType(ctx context.Context, id types.UUID, _ types.CID) (types.EventType, error) {
+	result := types.EventType{}
+	return result, db.conn(ctx).
+		QueryRowContext(ctx, sqls["eventtype"]["select"], id).
+		Scan(
+			&result.UUID,
+			&result.Name,
+			&result.Severity,
+			&result.Stage.UUID,
+			&result.Stage.Name)
+}
+
+func (db *Conn) InsertEventType(ctx context.Context, e types.EventType, _ types.CID) (types.EventType, error) {
+	e.UUID = ids.Or(ctx, e.UUID, db.newID)
+	// the most likely reason for nothing to be added is a bad stage
+	return e, db.exec(ctx, "eventtype was not added", sqls["eventtype"]["insert"], e.UUID, e.Name, e.Severity, e.Stage.UUID)
+}
+
+func (db *Conn) UpdateEventType(ctx context.Context, id types.UUID, e types.EventType, _ types.CID) error {
+	return db.exec(ctx, fmt.Sprintf("eventtype was not updated: '%s'", id),
+		sqls["eventtype"]["update"],
+		e.Name,
+		e.Severity,
+		e.Stage.UUID,
+		id,
+		stamp(db.now()))
+}
+
+func (db *Conn) DeleteEventType(ctx context.Context, id types.UUID, _ types.CID) error {
+	return db.deleteByUUID(ctx, id, "eventtype")
+}
+
+func (db *Conn) EventTypeReport(ctx context.Context, id types.UUID, cid types.CID) (types.Entity, error) {
+	e, err := db.SelectEventType(ctx, id, cid)
+	if err != nil {
+		return nil, err
+	}
+	return db.report(ctx, eventtype(e), cid, nil)
+}
+
+func (e eventtype) children(ctx context.Context, db *Conn, cid types.CID, p *rpttree) error {
+	param, _ := types.NewReportAttrs(url.Values{"eventtype-id": {string(e.UUID)}})
+
+	if lcs, err := db.lifecycleReport(ctx, param, cid, p); err != nil {
+		return err
+	} else if len(lcs) != 0 {
+		p.data["lifecycles"] = lcs
+	}
+
+	if gens, err := db.generationReport(ctx, param, cid, p); err != nil {
+		return err
+	} else if len(gens) != 0 {
+		p.data["generations"] = gens
+	}
+
+	return nil
+}
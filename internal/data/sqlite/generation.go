@@ -0,0 +1,286 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/data/ids"
+	"github.com/jsmit257/huautla/types"
+)
+
+// SelectGenerationIndex is every generation with its sources, including
+// deleted ones
+func (db *Conn) SelectGenerationIndex(ctx context.Context, _ types.CID) ([]types.Generation, error) {
+	rows, err := db.conn(ctx).QueryContext(ctx, sqls["generation"]["ndx"])
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]types.Generation, 0, 100)
+	for rows.Next() {
+		var soID, lcID, stID, vID *types.UUID
+		var soType, stName, stSpecies, vName, vWebsite *string
+		var stCTime *time.Time
+
+		row := types.Generation{}
+		if err = rows.Scan(
+			&row.UUID,
+			&row.PlatingSubstrate.UUID,
+			&row.PlatingSubstrate.Name,
+			&row.PlatingSubstrate.Type,
+			&row.PlatingSubstrate.Vendor.UUID,
+			&row.PlatingSubstrate.Vendor.Name,
+			&row.PlatingSubstrate.Vendor.Website,
+			&row.LiquidSubstrate.UUID,
+			&row.LiquidSubstrate.Name,
+			&row.LiquidSubstrate.Type,
+			&row.LiquidSubstrate.Vendor.UUID,
+			&row.LiquidSubstrate.Vendor.Name,
+			&row.LiquidSubstrate.Vendor.Website,
+			&soID,
+			&soType,
+			&lcID,
+			&stID,
+			&stName,
+			&stSpecies,
+			nts(&stCTime),
+			&vID,
+			&vName,
+			&vWebsite,
+			ts(&row.MTime),
+			ts(&row.CTime),
+			nts(&row.DTime),
+		); err != nil {
+			return nil, err
+		}
+
+		// a source whose strain is gone is left out, rather than half
+		// filled in
+		if soID != nil && stName != nil && vID != nil {
+			source := types.Source{
+				UUID: *soID,
+				Type: *soType,
+				Strain: types.Strain{
+					UUID:    *stID,
+					Name:    *stName,
+					Species: *stSpecies,
+					CTime:   *stCTime,
+					Vendor:  types.Vendor{UUID: *vID, Name: *vName, Website: *vWebsite},
+				},
+			}
+			if lcID != nil {
+				source.Lifecycle = &types.Lifecycle{UUID: *lcID}
+			}
+			row.Sources = []types.Source{source}
+		}
+
+		if curr := len(result) - 1; curr < 0 || result[curr].UUID != row.UUID {
+			result = append(result, row)
+		} else {
+			result[curr].Sources = append(result[curr].Sources, row.Sources...)
+		}
+	}
+
+	return result, rows.Err()
+}
+
+func (db *Conn) SelectGeneration(ctx context.Context, id types.UUID, cid types.CID) (types.Generation, error) {
+	param, err := types.NewReportAttrs(url.Values{"generation-id": {string(id)}})
+	if err != nil {
+		return types.Generation{}, err
+	}
+
+	if result, err := db.selectGenerations(ctx, param, cid); err != nil {
+		return types.Generation{}, err
+	} else if len(result) == 1 {
+		return result[0], nil
+	}
+
+	return types.Generation{}, sql.ErrNoRows
+}
+
+func (db *Conn) selectGenerations(ctx context.Context, p types.ReportAttrs, cid types.CID) ([]types.Generation, error) {
+	if !p.Contains("generation-id", "strain-id", "plating-id", "liquid-id", "eventtype-id") {
+		return nil, fmt.Errorf("request doesn't contain at least 1 required field")
+	}
+
+	rows, err := db.conn(ctx).QueryContext(ctx, sqls["generation"]["select"],
+		p.Get("generation-id"),
+		p.Get("strain-id"),
+		p.Get("plating-id"),
+		p.Get("liquid-id"),
+		p.Get("eventtype-id"))
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]types.Generation, 0, 100)
+	for rows.Next() {
+		row := types.Generation{}
+		if err = rows.Scan(
+			&row.UUID,
+			&row.PlatingSubstrate.UUID,
+			&row.PlatingSubstrate.Name,
+			&row.PlatingSubstrate.Type,
+			&row.PlatingSubstrate.Vendor.UUID,
+			&row.PlatingSubstrate.Vendor.Name,
+			&row.PlatingSubstrate.Vendor.Website,
+			&row.LiquidSubstrate.UUID,
+			&row.LiquidSubstrate.Name,
+			&row.LiquidSubstrate.Type,
+			&row.LiquidSubstrate.Vendor.UUID,
+			&row.LiquidSubstrate.Vendor.Name,
+			&row.LiquidSubstrate.Vendor.Website,
+			ts(&row.MTime),
+			ts(&row.CTime),
+			nts(&row.DTime),
+		); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		result = append(result, row)
+	}
+	if err = rows.Close(); err != nil {
+		return nil, err
+	} else if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range result {
+		if err = db.GetGenerationEvents(ctx, &result[i], cid); err != nil {
+			return nil, err
+		} else if err = db.getSources(ctx, &result[i], cid); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+func (db *Conn) InsertGeneration(ctx context.Context, g types.Generation, cid types.CID) (types.Generation, error) {
+	g.UUID = ids.Or(ctx, g.UUID, db.newID)
+	g.CTime = db.now()
+
+	if err := db.exec(ctx, "generation was not added", sqls["generation"]["insert"],
+		g.UUID,
+		g.PlatingSubstrate.UUID,
+		g.LiquidSubstrate.UUID,
+		stamp(g.CTime),
+	); err != nil {
+		return g, err
+	}
+
+	return db.SelectGeneration(ctx, g.UUID, cid)
+}
+
+func (db *Conn) UpdateGeneration(ctx context.Context, g types.Generation, _ types.CID) (types.Generation, error) {
+	g.MTime = db.now()
+
+	return g, db.exec(ctx, "generation was not updated", sqls["generation"]["update"],
+		g.PlatingSubstrate.UUID,
+		g.LiquidSubstrate.UUID,
+		g.UUID,
+		stamp(g.MTime))
+}
+
+// DeleteGeneration only marks the generation deleted, Undelete takes it
+// back
+func (db *Conn) DeleteGeneration(ctx context.Context, id types.UUID, _ types.CID) error {
+	return db.exec(ctx, fmt.Sprintf("generation could not be deleted: '%s'", id), sqls["generation"]["delete"], id, stamp(db.now()))
+}
+
+func (db *Conn) GetGenerationEvents(ctx context.Context, g *types.Generation, _ types.CID) error {
+	var err error
+	g.Events, err = db.selectEventsList(ctx, sqls["event"]["all-by-observable"], g.UUID)
+	return err
+}
+
+func (db *Conn) AddGenerationEvent(ctx context.Context, g *types.Generation, e types.Event, cid types.CID) error {
+	var err error
+	if g.Events, err = db.addEvent(ctx, g.UUID, g.Events, &e, cid); err != nil {
+		return err
+	} else if g.MTime, err = db.touch(ctx, "generations", e.MTime, g.UUID); err != nil {
+		return fmt.Errorf("couldn't update Generation.mtime")
+	}
+	return nil
+}
+
+func (db *Conn) ChangeGenerationEvent(ctx context.Context, g *types.Generation, e types.Event, cid types.CID) (types.Event, error) {
+	var err error
+	if g.Events, err = db.changeEvent(ctx, g.Events, &e, cid); err != nil {
+		return e, err
+	}
+	g.MTime, err = db.touch(ctx, "generations", e.MTime, g.UUID)
+	return e, err
+}
+
+func (db *Conn) RemoveGenerationEvent(ctx context.Context, g *types.Generation, id types.UUID, _ types.CID) error {
+	var err error
+	if g.Events, err = db.removeEvent(ctx, g.Events, id); err != nil {
+		return err
+	}
+	g.MTime, err = db.touch(ctx, "generations", db.now(), g.UUID)
+	return err
+}
+
+func (db *Conn) GenerationReport(ctx context.Context, id types.UUID, cid types.CID) (types.Entity, error) {
+	p, err := types.NewReportAttrs(url.Values{"generation-id": {string(id)}})
+	if err != nil {
+		return nil, err
+	}
+
+	if result, err := db.generationReport(ctx, p, cid, nil); err != nil {
+		return nil, err
+	} else if len(result) != 0 {
+		return result[0], nil
+	}
+
+	return nil, sql.ErrNoRows
+}
+
+func (db *Conn) generationReport(ctx context.Context, params types.ReportAttrs, cid types.CID, p *rpttree) ([]types.Entity, error) {
+	gens, err := db.selectGenerations(ctx, params, cid)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]types.Entity, 0, len(gens))
+	for _, gen := range gens {
+		if err = db.GetAllIngredients(ctx, &gen.PlatingSubstrate, cid); err != nil {
+			return nil, err
+		} else if err = db.GetAllIngredients(ctx, &gen.LiquidSubstrate, cid); err != nil {
+			return nil, err
+		} else if err = db.notesAndPhotos(ctx, gen.Events, gen.UUID); err != nil {
+			return nil, err
+		} else if e, err := db.report(ctx, generation(gen), cid, p); err != nil {
+			return nil, err
+		} else if e != nil {
+			result = append(result, e)
+		}
+	}
+
+	return result, nil
+}
+
+func (g generation) children(ctx context.Context, db *Conn, cid types.CID, p *rpttree) error {
+	if notes, err := db.notesReport(ctx, g.UUID, cid, p); err != nil {
+		return err
+	} else if len(notes) != 0 {
+		p.data["notes"] = notes
+	}
+
+	if progeny, err := db.GeneratedStrain(ctx, g.UUID, cid); err == sql.ErrNoRows {
+	} else if err != nil {
+		return err
+	} else if e, err := db.report(ctx, strain(progeny), cid, p); err != nil {
+		return err
+	} else if e != nil {
+		p.data["progeny"] = e
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/data/ids"
+	"github.com/jsmit257/huautla/types"
+)
+
+func (db *Conn) SelectAllIngredients(ctx context.Context, _ types.CID) ([]types.Ingredient, error) {
+	rows, err := db.conn(ctx).QueryContext(ctx, sqls["ingredient"]["select-all"])
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]types.Ingredient, 0, 100)
+	for rows.Next() {
+		row := types.Ingredient{}
+		if err = rows.Scan(&row.UUID, &row.Name); err != nil {
+			return nil, err
+		}
+		result = append(result, row)
+	}
+
+	return result, rows.Err()
+}
+
+func (db *Conn) SelectIngredient(ctx context.Context, id types.UUID, _ types.CID) (types.Ingredient, error) {
+	result := types.Ingredient{UUID: id}
+	return result, db.conn(ctx).
+		QueryRowContext(ctx, sqls["ingredient"]["select"], id).
+		Scan(&result.Name)
+}
+
+func (db *Conn) InsertIngredient(ctx context.Context, i types.Ingredient, _ types.CID) (types.Ingredient, error) {
+	i.UUID = ids.Or(ctx, i.UUID, db.newID)
+	return i, db.exec(ctx, "ingredient was not added", sqls["ingredient"]["insert"], i.UUID, i.Name)
+}
+
+func (db *Conn) UpdateIngredient(ctx context.Context, id types.UUID, i types.Ingredient, _ types.CID) error {
+	return db.exec(ctx, fmt.Sprintf("ingredient was not updated: '%s'", id), sqls["ingredient"]["update"], i.Name, id)
+}
+
+func (db *Conn) DeleteIngredient(ctx context.Context, id types.UUID, _ types.CID) error {
+	return db.deleteByUUID(ctx, id, "ingredient")
+}
@@ -0,0 +1,314 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/data/ids"
+	"github.com/jsmit257/huautla/types"
+)
+
+// SelectLifecycleIndex is every lifecycle with just the events that say
+// it's finished or that something came of it: sunset, spore print and clone
+func (db *Conn) SelectLifecycleIndex(ctx context.Context, _ types.CID) ([]types.Lifecycle, error) {
+	rows, err := db.conn(ctx).QueryContext(ctx, sqls["lifecycle"]["index"])
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]types.Lifecycle, 0, 100)
+	for rows.Next() {
+		var eID, etID, stID *types.UUID
+		var etName, etSev, stName *string
+		var mtime, ctime *time.Time
+		var temp *float32
+		var hum *int8
+
+		row := types.Lifecycle{}
+		if err = rows.Scan(
+			&row.UUID,
+			&row.Location,
+			ts(&row.MTime),
+			ts(&row.CTime),
+			&row.Strain.UUID,
+			&row.Strain.Species,
+			&row.Strain.Name,
+			ts(&row.Strain.CTime),
+			&row.Strain.Vendor.UUID,
+			&row.Strain.Vendor.Name,
+			&row.Strain.Vendor.Website,
+			&eID,
+			&temp,
+			&hum,
+			nts(&mtime),
+			nts(&ctime),
+			&etID,
+			&etName,
+			&etSev,
+			&stID,
+			&stName,
+		); err != nil {
+			return nil, err
+		}
+
+		if eID != nil {
+			row.Events = []types.Event{{
+				UUID:        *eID,
+				Temperature: *temp,
+				Humidity:    *hum,
+				MTime:       *mtime,
+				CTime:       *ctime,
+				EventType: types.EventType{
+					UUID:     *etID,
+					Name:     *etName,
+					Severity: *etSev,
+					Stage:    types.Stage{UUID: *stID, Name: *stName},
+				},
+			}}
+		}
+
+		if curr := len(result) - 1; curr < 0 || result[curr].UUID != row.UUID {
+			result = append(result, row)
+		} else {
+			result[curr].Events = append(result[curr].Events, row.Events...)
+		}
+	}
+
+	return result, rows.Err()
+}
+
+func (db *Conn) SelectLifecycle(ctx context.Context, id types.UUID, cid types.CID) (types.Lifecycle, error) {
+	p, _ := types.NewReportAttrs(url.Values{"lifecycle-id": {string(id)}})
+
+	result, err := db.selectLifecycles(ctx, p, cid)
+	if err != nil {
+		return types.Lifecycle{}, err
+	} else if len(result) == 0 {
+		return types.Lifecycle{}, sql.ErrNoRows
+	} else if len(result) > 1 {
+		return types.Lifecycle{}, fmt.Errorf("too many rows returned for SelectLifecycle")
+	}
+
+	return result[0], nil
+}
+
+func (db *Conn) selectLifecycles(ctx context.Context, p types.ReportAttrs, cid types.CID) ([]types.Lifecycle, error) {
+	if !p.Contains("lifecycle-id", "strain-id", "grain-id", "bulk-id", "eventtype-id") {
+		return nil, fmt.Errorf("request doesn't contain at least 1 required field")
+	}
+
+	rows, err := db.conn(ctx).QueryContext(ctx, sqls["lifecycle"]["select"],
+		p.Get("lifecycle-id"),
+		p.Get("strain-id"),
+		p.Get("grain-id"),
+		p.Get("bulk-id"),
+		p.Get("eventtype-id"))
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]types.Lifecycle, 0, 100)
+	for rows.Next() {
+		row := types.Lifecycle{}
+		var generationID *types.UUID
+		if err = rows.Scan(
+			&row.UUID,
+			&row.Location,
+			&row.StrainCost,
+			&row.GrainCost,
+			&row.BulkCost,
+			&row.Yield,
+			&row.Count,
+			&row.Gross,
+			ts(&row.MTime),
+			ts(&row.CTime),
+			&row.Strain.UUID,
+			&row.Strain.Species,
+			&row.Strain.Name,
+			&generationID,
+			ts(&row.Strain.CTime),
+			nts(&row.Strain.DTime),
+			&row.Strain.Vendor.UUID,
+			&row.Strain.Vendor.Name,
+			&row.Strain.Vendor.Website,
+			&row.GrainSubstrate.UUID,
+			&row.GrainSubstrate.Name,
+			&row.GrainSubstrate.Type,
+			&row.GrainSubstrate.Vendor.UUID,
+			&row.GrainSubstrate.Vendor.Name,
+			&row.GrainSubstrate.Vendor.Website,
+			&row.BulkSubstrate.UUID,
+			&row.BulkSubstrate.Name,
+			&row.BulkSubstrate.Type,
+			&row.BulkSubstrate.Vendor.UUID,
+			&row.BulkSubstrate.Vendor.Name,
+			&row.BulkSubstrate.Vendor.Website,
+		); err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		if generationID != nil {
+			row.Strain.Generation = &types.Generation{UUID: *generationID}
+		}
+
+		result = append(result, row)
+	}
+	if err = rows.Close(); err != nil {
+		return nil, err
+	} else if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range result {
+		if err = db.GetLifecycleEvents(ctx, &result[i], cid); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+func (db *Conn) InsertLifecycle(ctx context.Context, lc types.Lifecycle, cid types.CID) (types.Lifecycle, error) {
+	lc.UUID = ids.Or(ctx, lc.UUID, db.newID)
+	lc.MTime = db.now()
+	lc.CTime = lc.MTime
+
+	if err := db.exec(ctx, "lifecycle was not added", sqls["lifecycle"]["insert"],
+		lc.UUID,
+		lc.Location,
+		lc.StrainCost,
+		lc.GrainCost,
+		lc.BulkCost,
+		lc.Yield,
+		lc.Count,
+		lc.Gross,
+		stamp(lc.MTime),
+		stamp(lc.CTime),
+		lc.Strain.UUID,
+		lc.GrainSubstrate.UUID,
+		lc.BulkSubstrate.UUID,
+	); err != nil {
+		return lc, err
+	}
+
+	return db.SelectLifecycle(ctx, lc.UUID, cid)
+}
+
+func (db *Conn) UpdateLifecycle(ctx context.Context, lc types.Lifecycle, _ types.CID) (types.Lifecycle, error) {
+	lc.MTime = db.now()
+
+	return lc, db.exec(ctx, "one of strain, grain or bulk is not the right type", sqls["lifecycle"]["update"],
+		lc.Location,
+		lc.StrainCost,
+		lc.GrainCost,
+		lc.BulkCost,
+		lc.Yield,
+		lc.Count,
+		lc.Gross,
+		stamp(lc.MTime),
+		lc.Strain.UUID,
+		lc.GrainSubstrate.UUID,
+		lc.BulkSubstrate.UUID,
+		lc.UUID)
+}
+
+// DeleteLifecycle really deletes it, and its events, notes and photos go
+// with it
+func (db *Conn) DeleteLifecycle(ctx context.Context, id types.UUID, _ types.CID) error {
+	return db.deleteByUUID(ctx, id, "lifecycle")
+}
+
+func (db *Conn) GetLifecycleEvents(ctx context.Context, lc *types.Lifecycle, _ types.CID) error {
+	var err error
+	lc.Events, err = db.selectEventsList(ctx, sqls["event"]["all-by-observable"], lc.UUID)
+	return err
+}
+
+func (db *Conn) AddLifecycleEvent(ctx context.Context, lc *types.Lifecycle, e types.Event, cid types.CID) error {
+	var err error
+	if lc.Events, err = db.addEvent(ctx, lc.UUID, lc.Events, &e, cid); err != nil {
+		return err
+	}
+	lc.MTime, err = db.touch(ctx, "lifecycles", e.MTime, lc.UUID)
+	return err
+}
+
+func (db *Conn) ChangeLifecycleEvent(ctx context.Context, lc *types.Lifecycle, e types.Event, cid types.CID) (types.Event, error) {
+	var err error
+	if lc.Events, err = db.changeEvent(ctx, lc.Events, &e, cid); err != nil {
+		return e, err
+	}
+	lc.MTime, err = db.touch(ctx, "lifecycles", e.MTime, lc.UUID)
+	return e, err
+}
+
+func (db *Conn) RemoveLifecycleEvent(ctx context.Context, lc *types.Lifecycle, id types.UUID, _ types.CID) error {
+	var err error
+	if lc.Events, err = db.removeEvent(ctx, lc.Events, id); err != nil {
+		return err
+	}
+	lc.MTime, err = db.touch(ctx, "lifecycles", db.now(), lc.UUID)
+	return err
+}
+
+func (db *Conn) LifecycleReport(ctx context.Context, id types.UUID, cid types.CID) (types.Entity, error) {
+	param, err := types.NewReportAttrs(url.Values{"lifecycle-id": {string(id)}})
+	if err != nil {
+		return nil, err
+	}
+
+	if result, err := db.lifecycleReport(ctx, param, cid, nil); err != nil {
+		return nil, err
+	} else if len(result) != 0 {
+		return result[0], nil
+	}
+
+	return nil, sql.ErrNoRows
+}
+
+func (db *Conn) lifecycleReport(ctx context.Context, params types.ReportAttrs, cid types.CID, p *rpttree) ([]types.Entity, error) {
+	lcs, err := db.selectLifecycles(ctx, params, cid)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]types.Entity, 0, len(lcs))
+	for _, lc := range lcs {
+		if err = db.GetAllAttributes(ctx, &lc.Strain, cid); err != nil {
+			return nil, err
+		} else if err = db.GetAllIngredients(ctx, &lc.GrainSubstrate, cid); err != nil {
+			return nil, err
+		} else if err = db.GetAllIngredients(ctx, &lc.BulkSubstrate, cid); err != nil {
+			return nil, err
+		} else if err = db.notesAndPhotos(ctx, lc.Events, lc.UUID); err != nil {
+			return nil, err
+		} else if e, err := db.report(ctx, lifecycle(lc), cid, p); err != nil {
+			return nil, err
+		} else if e != nil {
+			result = append(result, e)
+		}
+	}
+
+	return result, nil
+}
+
+func (lc lifecycle) children(ctx context.Context, db *Conn, cid types.CID, p *rpttree) error {
+	if notes, err := db.notesReport(ctx, lc.UUID, cid, p); err != nil {
+		return err
+	} else if len(notes) != 0 {
+		p.data["notes"] = notes
+	}
+
+	if photos, err := db.photosReport(ctx, lc.Strain.UUID, cid, p); err != nil {
+		return err
+	} else if s, ok := p.data["strain"].(map[string]any); ok && len(photos) != 0 {
+		s["photos"] = photos
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package sqlite
+
+import (
+	"context"
+	"slices"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/data/ids"
+	"github.com/jsmit257/huautla/types"
+)
+
+// GetNotes is every note on id, newest first
+func (db *Conn) GetNotes(ctx context.Context, id types.UUID, _ types.CID) ([]types.Note, error) {
+	rows, err := db.conn(ctx).QueryContext(ctx, sqls["note"]["get"], id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []types.Note
+	for rows.Next() {
+		note := types.Note{}
+		if err = rows.Scan(&note.UUID, &note.Note, ts(&note.MTime), ts(&note.CTime)); err != nil {
+			return result, err
+		}
+		result = append(result, note)
+	}
+
+	return result, rows.Err()
+}
+
+func (db *Conn) AddNote(ctx context.Context, oID types.UUID, notes []types.Note, n types.Note, _ types.CID) ([]types.Note, error) {
+	n.UUID = ids.Or(ctx, n.UUID, db.newID)
+	n.MTime = db.now()
+	n.CTime = n.MTime
+
+	if err := db.exec(ctx, "note was not added", sqls["note"]["add"], n.UUID, n.Note, oID, stamp(n.CTime)); err != nil {
+		return notes, err
+	}
+
+	return append([]types.Note{n}, notes...), nil
+}
+
+// ChangeNote changes n, and moves it to the front of notes since it's now
+// the newest
+func (db *Conn) ChangeNote(ctx context.Context, notes []types.Note, n types.Note, _ types.CID) ([]types.Note, error) {
+	n.MTime = db.now()
+
+	if err := db.exec(ctx, "note was not changed", sqls["note"]["change"], n.Note, stamp(n.MTime), n.UUID); err != nil {
+		return notes, err
+	}
+
+	rest := slices.DeleteFunc(slices.Clone(notes), func(old types.Note) bool { return old.UUID == n.UUID })
+	return append([]types.Note{n}, rest...), nil
+}
+
+func (db *Conn) RemoveNote(ctx context.Context, notes []types.Note, id types.UUID, _ types.CID) ([]types.Note, error) {
+	if err := db.exec(ctx, "note could not be removed", sqls["note"]["remove"], id); err != nil {
+		return notes, err
+	}
+	return slices.DeleteFunc(notes, func(old types.Note) bool { return old.UUID == id }), nil
+}
+
+func (db *Conn) notesReport(ctx context.Context, id types.UUID, cid types.CID, p *rpttree) ([]types.Entity, error) {
+	notes, err := db.GetNotes(ctx, id, cid)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]types.Entity, 0, len(notes))
+	for _, n := range notes {
+		if e, err := db.report(ctx, n, cid, p); err != nil {
+			return nil, err
+		} else if e != nil {
+			result = append(result, e)
+		}
+	}
+
+	return result, nil
+}
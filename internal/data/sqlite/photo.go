@@ -0,0 +1,108 @@
+package sqlite
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/data/ids"
+	"github.com/jsmit257/huautla/types"
+)
+
+// GetPhotos is every photo of id, newest first, each with its notes
+func (db *Conn) GetPhotos(ctx context.Context, id types.UUID, _ types.CID) ([]types.Photo, error) {
+	rows, err := db.conn(ctx).QueryContext(ctx, sqls["photo"]["get"], id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []types.Photo
+	for rows.Next() {
+		var p types.Photo
+		var noteID *types.UUID
+		var noteText *string
+		var noteMTime, noteCTime *time.Time
+
+		if err = rows.Scan(
+			&p.UUID,
+			&p.Filename,
+			ts(&p.MTime),
+			ts(&p.CTime),
+			&noteID,
+			&noteText,
+			nts(&noteMTime),
+			nts(&noteCTime),
+		); err != nil {
+			return result, err
+		}
+
+		if noteID != nil {
+			p.Notes = []types.Note{{UUID: *noteID, Note: *noteText, MTime: *noteMTime, CTime: *noteCTime}}
+		}
+
+		if curr := len(result) - 1; curr < 0 || result[curr].UUID != p.UUID {
+			result = append(result, p)
+		} else {
+			result[curr].Notes = append(result[curr].Notes, p.Notes...)
+		}
+	}
+
+	return result, rows.Err()
+}
+
+func (db *Conn) AddPhoto(ctx context.Context, id types.UUID, photos []types.Photo, p types.Photo, _ types.CID) ([]types.Photo, error) {
+	p.UUID = ids.Or(ctx, p.UUID, db.newID)
+	p.CTime = db.now()
+	p.MTime = p.CTime
+
+	if err := db.exec(ctx, "photo was not added", sqls["photo"]["add"],
+		p.UUID,
+		p.Filename,
+		id,
+		stamp(p.MTime),
+		stamp(p.CTime),
+	); err != nil {
+		return photos, err
+	}
+
+	return append([]types.Photo{p}, photos...), nil
+}
+
+// ChangePhoto changes p, and moves it to the front of photos since it's
+// now the newest
+func (db *Conn) ChangePhoto(ctx context.Context, photos []types.Photo, p types.Photo, _ types.CID) ([]types.Photo, error) {
+	p.MTime = db.now()
+
+	if err := db.exec(ctx, "photo was not changed", sqls["photo"]["change"], p.Filename, stamp(p.MTime), p.UUID); err != nil {
+		return photos, err
+	}
+
+	rest := slices.DeleteFunc(slices.Clone(photos), func(old types.Photo) bool { return old.UUID == p.UUID })
+	return append([]types.Photo{p}, rest...), nil
+}
+
+func (db *Conn) RemovePhoto(ctx context.Context, photos []types.Photo, id types.UUID, _ types.CID) ([]types.Photo, error) {
+	if err := db.exec(ctx, "photo could not be removed", sqls["photo"]["remove"], id); err != nil {
+		return photos, err
+	}
+	return slices.DeleteFunc(photos, func(old types.Photo) bool { return old.UUID == id }), nil
+}
+
+func (db *Conn) photosReport(ctx context.Context, id types.UUID, cid types.CID, p *rpttree) ([]types.Entity, error) {
+	photos, err := db.GetPhotos(ctx, id, cid)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]types.Entity, 0, len(photos))
+	for _, photo := range photos {
+		if e, err := db.report(ctx, photo, cid, p); err != nil {
+			return nil, err
+		} else if e != nil {
+			result = append(result, e)
+		}
+	}
+
+	return result, nil
+}
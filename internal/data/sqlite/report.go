@@ -0,0 +1,82 @@
+package sqlite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jsmit257/huautla/types"
+)
+
+// these are what reports are made of; the ones with children go and get
+// the things that belong to them, and so on down, stopping wherever
+// something is already further up the report
+type (
+	eventtype  types.EventType
+	generation types.Generation
+	lifecycle  types.Lifecycle
+	strain     types.Strain
+	substrate  types.Substrate
+	vendor     types.Vendor
+
+	rpttree struct {
+		id     string
+		data   types.Entity
+		parent *rpttree
+	}
+)
+
+// report is e as a map, with its children added; it's nil if e is already
+// somewhere above p
+func (db *Conn) report(ctx context.Context, e any, cid types.CID, p *rpttree) (types.Entity, error) {
+	result := &rpttree{id: rptID(e), data: types.Entity{}, parent: p}
+	if result.id == "" {
+		return nil, fmt.Errorf("couldn't determine entity type: '%v' '%T'", e, e)
+	} else if result.cycle() {
+		return nil, nil
+	}
+
+	if js, err := json.Marshal(e); err != nil {
+		return nil, err
+	} else if err = json.Unmarshal(js, &result.data); err != nil {
+		return nil, err
+	} else if T, ok := e.(interface {
+		children(context.Context, *Conn, types.CID, *rpttree) error
+	}); !ok {
+	} else if err = T.children(ctx, db, cid, result); err != nil {
+		return nil, err
+	}
+
+	return result.data, nil
+}
+
+func (r *rpttree) cycle() bool {
+	for p := r.parent; p != nil; p = p.parent {
+		if p.id == r.id {
+			return true
+		}
+	}
+	return false
+}
+
+func rptID(e any) string {
+	switch T := e.(type) {
+	case lifecycle:
+		return fmt.Sprintf("lifecycle#%s", T.UUID)
+	case generation:
+		return fmt.Sprintf("generation#%s", T.UUID)
+	case strain:
+		return fmt.Sprintf("strain#%s", T.UUID)
+	case substrate:
+		return fmt.Sprintf("substrate#%s", T.UUID)
+	case eventtype:
+		return fmt.Sprintf("eventtype#%s", T.UUID)
+	case vendor:
+		return fmt.Sprintf("vendor#%s", T.UUID)
+	case types.Note:
+		return fmt.Sprintf("note#%s", T.UUID)
+	case types.Photo:
+		return fmt.Sprintf("photo#%s", T.UUID)
+	}
+	return ""
+}
@@ -0,0 +1,214 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+)
+
+// migrations are applied in order, each exactly once, and each in its own
+// transaction; only ever add to the end of this list, since a database
+// remembers how many it's had, not which
+var migrations = []string{
+	// the same tables huautla has, near enough; events, notes and photos
+	// can belong to more than one kind of thing, so there's no foreign key
+	// to their owners, and triggers clean up after them instead
+	`
+	create table vendors (
+		uuid    text primary key,
+		name    text not null,
+		website text not null default ''
+	);
+
+	create table ingredients (
+		uuid text primary key,
+		name text not null
+	);
+
+	create table substrates (
+		uuid        text primary key,
+		name        text not null,
+		type        text not null check (type in ('plating', 'liquid', 'grain', 'bulk')),
+		vendor_uuid text not null references vendors(uuid)
+	);
+
+	create table substrate_ingredients (
+		uuid            text primary key,
+		substrate_uuid  text not null references substrates(uuid) on delete cascade,
+		ingredient_uuid text not null references ingredients(uuid),
+		unique (substrate_uuid, ingredient_uuid)
+	);
+
+	create table stages (
+		uuid text primary key,
+		name text not null
+	);
+
+	create table event_types (
+		uuid       text primary key,
+		name       text not null,
+		severity   text not null default '',
+		stage_uuid text not null references stages(uuid),
+		mtime      text
+	);
+
+	create table generations (
+		uuid                  text primary key,
+		platingsubstrate_uuid text not null references substrates(uuid),
+		liquidsubstrate_uuid  text not null references substrates(uuid),
+		mtime                 text not null,
+		ctime                 text not null,
+		dtime                 text
+	);
+
+	create table strains (
+		uuid            text primary key,
+		species         text not null default '',
+		name            text not null,
+		vendor_uuid     text not null references vendors(uuid),
+		generation_uuid text references generations(uuid),
+		mtime           text,
+		ctime           text not null,
+		dtime           text
+	);
+
+	create table strain_attributes (
+		uuid        text primary key,
+		name        text not null,
+		value       text not null,
+		strain_uuid text not null references strains(uuid) on delete cascade
+	);
+
+	create table lifecycles (
+		uuid                text primary key,
+		location            text not null default '',
+		strain_cost         real not null default 0,
+		grain_cost          real not null default 0,
+		bulk_cost           real not null default 0,
+		yield               real not null default 0,
+		headcount           integer not null default 0,
+		gross               real not null default 0,
+		mtime               text not null,
+		ctime               text not null,
+		strain_uuid         text not null references strains(uuid),
+		grainsubstrate_uuid text not null references substrates(uuid),
+		bulksubstrate_uuid  text not null references substrates(uuid)
+	);
+
+	create table events (
+		uuid            text primary key,
+		temperature     real not null default 0,
+		humidity        integer not null default 0,
+		mtime           text not null,
+		ctime           text not null,
+		observable_uuid text not null,
+		eventtype_uuid  text not null references event_types(uuid)
+	);
+	create index events_observable on events(observable_uuid);
+
+	create table notes (
+		uuid         text primary key,
+		note         text not null,
+		notable_uuid text not null,
+		mtime        text not null,
+		ctime        text not null
+	);
+	create index notes_notable on notes(notable_uuid);
+
+	create table photos (
+		uuid           text primary key,
+		filename       text not null,
+		photoable_uuid text not null,
+		mtime          text not null,
+		ctime          text not null
+	);
+	create index photos_photoable on photos(photoable_uuid);
+
+	create table sources (
+		uuid            text primary key,
+		type            text not null,
+		progenitor_uuid text not null,
+		generation_uuid text not null references generations(uuid),
+		mtime           text
+	);
+	create index sources_generation on sources(generation_uuid);
+
+	create trigger lifecycles_cleanup after delete on lifecycles begin
+		delete from events where observable_uuid = old.uuid;
+		delete from notes where notable_uuid = old.uuid;
+	end;
+
+	create trigger events_cleanup after delete on events begin
+		delete from notes where notable_uuid = old.uuid;
+		delete from photos where photoable_uuid = old.uuid;
+	end;
+
+	create trigger photos_cleanup after delete on photos begin
+		delete from notes where notable_uuid = old.uuid;
+	end;
+	`,
+
+	// the stages and event types every huautla database starts with; the
+	// lifecycle index looks for sunset, sporeprint and clone by id
+	`
+	insert into stages(uuid, name) values
+		('any', 'Any'),
+		('gestation', 'Gestation'),
+		('colonization', 'Colonization'),
+		('majority', 'Majority'),
+		('vacation', 'Vacation');
+
+	insert into event_types(uuid, name, severity, stage_uuid) values
+		('fullcolonization', '100% colonization', 'Info', 'any'),
+		('halfcolonization', '50% colonization', 'Info', 'any'),
+		('clone', 'Clone', 'Generation', 'any'),
+		('sunset', 'Sunset', 'Info', 'any'),
+		('innoculation', 'Innoculation', 'Info', 'colonization'),
+		('redistribute', 'Redistribute substrate', 'Info', 'colonization'),
+		('agarsampling', 'Agar sampling', 'Info', 'gestation'),
+		('liquidinnoculation', 'Liquid innoculation', 'Info', 'gestation'),
+		('binning', 'Binning', 'Info', 'majority'),
+		('harvesting', 'Harvesting', 'Info', 'majority'),
+		('sporeprint', 'Spore print', 'Generation', 'majority'),
+		('chill', 'Chill', 'Info', 'vacation');
+	`,
+}
+
+func (db *Conn) migrate(ctx context.Context) error {
+	if _, err := db.db.ExecContext(ctx, `
+		create table if not exists schema_migrations (
+			version integer primary key,
+			applied text not null
+		)`); err != nil {
+		return err
+	}
+
+	var version int
+	if err := db.db.
+		QueryRowContext(ctx, `select coalesce(max(version), 0) from schema_migrations`).
+		Scan(&version); err != nil {
+		return err
+	} else if version > len(migrations) {
+		return fmt.Errorf("database is at version %d, but this build only knows about %d", version, len(migrations))
+	}
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := db.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		} else if _, err = tx.ExecContext(ctx, migrations[i]); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		} else if _, err = tx.ExecContext(ctx,
+			`insert into schema_migrations(version, applied) values(?1, ?2)`,
+			i+1,
+			stamp(db.now())); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		} else if err = tx.Commit(); err != nil {
+			return err
+		}
+		db.log.WithField("version", i+1).Info("migrated database")
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/data/ids"
+	"github.com/jsmit257/huautla/types"
+)
+
+// getSources fills in where g came from; a source that's an event comes
+// with its lifecycle, and just that one event
+func (db *Conn) getSources(ctx context.Context, g *types.Generation, cid types.CID) error {
+	rows, err := db.conn(ctx).QueryContext(ctx, sqls["source"]["get"], g.UUID)
+	if err != nil {
+		return err
+	}
+
+	type progenitor struct {
+		id     types.UUID
+		lcID   *types.UUID
+		source types.Source
+	}
+
+	found := []progenitor{}
+	for rows.Next() {
+		row := progenitor{}
+		if err = rows.Scan(
+			&row.source.UUID,
+			&row.source.Type,
+			&row.id,
+			&row.lcID,
+			&row.source.Strain.UUID,
+			&row.source.Strain.Name,
+			&row.source.Strain.Species,
+			ts(&row.source.Strain.CTime),
+			nts(&row.source.Strain.DTime),
+			&row.source.Strain.Vendor.UUID,
+			&row.source.Strain.Vendor.Name,
+			&row.source.Strain.Vendor.Website,
+		); err != nil {
+			rows.Close()
+			return err
+		}
+		found = append(found, row)
+	}
+	if err = rows.Close(); err != nil {
+		return err
+	} else if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, p := range found {
+		if p.lcID != nil {
+			lc, err := db.SelectLifecycle(ctx, *p.lcID, cid)
+			if err != nil {
+				return err
+			}
+			if i := slices.IndexFunc(lc.Events, func(e types.Event) bool { return e.UUID == p.id }); i >= 0 {
+				lc.Events = lc.Events[i : i+1]
+			}
+			p.source.Lifecycle = &lc
+		}
+		g.Sources = append(g.Sources, p.source)
+	}
+
+	return nil
+}
+
+// InsertSource adds a source to genid; if origin is strain, s.Strain is
+// where it came from, and if it's event, it's the first of
+// s.Lifecycle.Events
+func (db *Conn) InsertSource(ctx context.Context, genid types.UUID, origin string, s types.Source, _ types.CID) (types.Source, error) {
+	progenitor := s.Strain.UUID
+	if origin == "event" {
+		if s.Lifecycle == nil || len(s.Lifecycle.Events) == 0 {
+			return types.Source{}, fmt.Errorf("an event source needs a lifecycle with an event")
+		}
+		progenitor = s.Lifecycle.Events[0].UUID
+	} else if origin != "strain" {
+		return types.Source{}, fmt.Errorf("only origins of type 'strain' and 'event' are allowed: '%s'", origin)
+	}
+
+	s.UUID = ids.Or(ctx, s.UUID, db.newID)
+	if err := db.exec(ctx, "source was not added", sqls["source"]["add"],
+		s.UUID,
+		s.Type,
+		progenitor,
+		genid,
+		stamp(db.now()),
+	); err != nil {
+		return types.Source{}, err
+	}
+
+	return s, nil
+}
+
+func (db *Conn) UpdateSource(ctx context.Context, origin string, s types.Source, _ types.CID) error {
+	if origin != "event" && origin != "strain" {
+		return fmt.Errorf("only origins of type 'strain' and 'event' are allowed: '%s'", origin)
+	}
+	return db.exec(ctx, "source was not changed", sqls["source"]["change"], s.Type, s.UUID, stamp(db.now()))
+}
+
+func (db *Conn) RemoveSource(ctx context.Context, g *types.Generation, id types.UUID, _ types.CID) error {
+	if err := db.deleteByUUID(ctx, id, "source"); err != nil {
+		return err
+	}
+	g.Sources = slices.DeleteFunc(g.Sources, func(s types.Source) bool { return s.UUID == id })
+	return nil
+}
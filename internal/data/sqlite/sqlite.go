@@ -0,0 +1,211 @@
+// Package sqlite is a types.DB that keeps everything in one sqlite file, for
+// running cffc without a huautla database to talk to. It's the same
+// interface with the same behavior, as near as sqlite allows: the same
+// errors for the same mistakes, soft deletes for generations and strains,
+// newest events, notes and photos first, and so on.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/data/ids"
+	"github.com/jsmit257/centerforfunguscontrol/internal/data/txn"
+	"github.com/jsmit257/huautla/types"
+)
+
+type (
+	Conn struct {
+		db    *sql.DB
+		log   *logrus.Entry
+		newID func() types.UUID
+		now   func() time.Time
+	}
+
+	// querier is what a query needs, which a transaction has as well as
+	// the database
+	querier interface {
+		ExecContext(context.Context, string, ...any) (sql.Result, error)
+		QueryContext(context.Context, string, ...any) (*sql.Rows, error)
+		QueryRowContext(context.Context, string, ...any) *sql.Row
+	}
+
+	// txKey is where Begin keeps a transaction in a ctx; it's per Conn, so
+	// a transaction on one database is never used for another
+	txKey struct{ db *Conn }
+)
+
+// driverName is what the driver registers itself as with database/sql
+const driverName = "sqlite3"
+
+// stampFormat is how times are stored; it's fixed width so comparing them
+// as text is the same as comparing them as times
+const stampFormat = "2006-01-02T15:04:05.000000000Z"
+
+var (
+	_ types.DB     = (*Conn)(nil)
+	_ ids.Keeper   = (*Conn)(nil)
+	_ txn.Beginner = (*Conn)(nil)
+)
+
+// Open opens the database in path, creating it if it's not there yet, and
+// brings its schema up to date
+func Open(ctx context.Context, path string, log *logrus.Entry) (*Conn, error) {
+	if path == "" {
+		return nil, fmt.Errorf("sqlite needs a path to its data file")
+	}
+
+	db, err := sql.Open(driverName, "file:"+path)
+	if err != nil {
+		return nil, err
+	}
+
+	// sqlite only has one writer at a time anyway, and pragmas are per
+	// connection, so there's only ever the one
+	db.SetMaxOpenConns(1)
+	db.SetConnMaxLifetime(0)
+
+	result := &Conn{
+		db:    db,
+		log:   log.WithField("component", "sqlite"),
+		newID: func() types.UUID { return types.UUID(uuid.NewString()) },
+		now:   func() time.Time { return time.Now().UTC() },
+	}
+
+	for _, p := range []string{
+		"pragma foreign_keys = on",
+		"pragma busy_timeout = 5000",
+		"pragma journal_mode = wal",
+	} {
+		if _, err = db.ExecContext(ctx, p); err != nil {
+			_ = db.Close()
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+	}
+
+	if err = result.migrate(ctx); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// KeepsIDs says inserts keep their ids when ids.Keep asks them to
+func (db *Conn) KeepsIDs() bool {
+	return true
+}
+
+func (db *Conn) Close() error {
+	return db.db.Close()
+}
+
+// Begin starts a transaction that everything done with the ctx it returns
+// is part of. There's only ever one connection, so everybody else waits
+// for it to be committed or rolled back
+func (db *Conn) Begin(ctx context.Context) (context.Context, txn.Tx, error) {
+	if _, ok := ctx.Value(txKey{db}).(*sql.Tx); ok {
+		return nil, nil, fmt.Errorf("already in a transaction")
+	}
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return context.WithValue(ctx, txKey{db}, tx), tx, nil
+}
+
+// conn is the transaction ctx is part of, if it's part of one, and the
+// database otherwise
+func (db *Conn) conn(ctx context.Context) querier {
+	if tx, ok := ctx.Value(txKey{db}).(*sql.Tx); ok {
+		return tx
+	}
+	return db.db
+}
+
+// exec runs a statement that should change exactly one row, and says what
+// didn't happen if it didn't
+func (db *Conn) exec(ctx context.Context, what, query string, args ...any) error {
+	if result, err := db.conn(ctx).ExecContext(ctx, query, args...); err != nil {
+		return err
+	} else if rows, err := result.RowsAffected(); err != nil {
+		return err
+	} else if rows != 1 {
+		return errors.New(what)
+	}
+	return nil
+}
+
+func (db *Conn) deleteByUUID(ctx context.Context, id types.UUID, table string) error {
+	return db.exec(ctx, fmt.Sprintf("%s could not be deleted: '%s'", table, id), sqls[table]["delete"], id)
+}
+
+// stamp is how a time goes into the database
+func stamp(t time.Time) string {
+	return t.UTC().Format(stampFormat)
+}
+
+type (
+	// timeScanner reads a stored time back; drivers hand them over as
+	// strings, bytes or, if they think they know better, time.Time
+	timeScanner struct{ t *time.Time }
+
+	// nullTimeScanner is a timeScanner for columns that can be null, like
+	// dtime
+	nullTimeScanner struct{ t **time.Time }
+)
+
+func (s timeScanner) Scan(src any) error {
+	t, err := parseTime(src)
+	if err != nil {
+		return err
+	} else if t == nil {
+		return fmt.Errorf("unexpected null time")
+	}
+	*s.t = *t
+	return nil
+}
+
+func (s nullTimeScanner) Scan(src any) error {
+	t, err := parseTime(src)
+	if err != nil {
+		return err
+	}
+	*s.t = t
+	return nil
+}
+
+func parseTime(src any) (*time.Time, error) {
+	var s string
+	switch v := src.(type) {
+	case nil:
+		return nil, nil
+	case time.Time:
+		v = v.UTC()
+		return &v, nil
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return nil, fmt.Errorf("can't read a time from a %T", src)
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return nil, err
+	}
+	t = t.UTC()
+	return &t, nil
+}
+
+// ts and nts are shorthand for scanning into times
+func ts(t *time.Time) timeScanner       { return timeScanner{t} }
+func nts(t **time.Time) nullTimeScanner { return nullTimeScanner{t} }
@@ -0,0 +1,144 @@
+package sqlite
+
+import (
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/centerforfunguscontrol/shared/metrics"
+	"github.com/jsmit257/huautla/types"
+)
+
+func Test_Open(t *testing.T) {
+	t.Parallel()
+
+	l := logrus.WithField("test", "Test_Open")
+
+	_, err := Open(metrics.MockServiceContext, "", l)
+	require.NotNil(t, err)
+
+	db, err := Open(metrics.MockServiceContext, filepath.Join(t.TempDir(), "cffc.db"), l)
+	require.Nil(t, err)
+	require.Nil(t, db.Close())
+}
+
+func Test_Begin(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		commit bool
+		want   []string
+	}{
+		"committed":   {commit: true, want: []string{"changed", "new"}},
+		"rolled_back": {want: []string{"old"}},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			db, _ := newConn(t)
+			cid := types.CID(t.Name())
+			v, err := db.InsertVendor(metrics.MockServiceContext, types.Vendor{Name: "old"}, cid)
+			require.Nil(t, err)
+
+			ctx, tx, err := db.Begin(metrics.MockServiceContext)
+			require.Nil(t, err)
+			_, _, err = db.Begin(ctx)
+			require.NotNil(t, err, "transactions don't nest")
+
+			_, err = db.InsertVendor(ctx, types.Vendor{Name: "new"}, cid)
+			require.Nil(t, err)
+			require.Nil(t, db.UpdateVendor(ctx, v.UUID, types.Vendor{Name: "changed"}, cid))
+
+			// anybody else waits for the one connection until it's finished
+			seen := make(chan []types.Vendor)
+			go func() {
+				vendors, _ := db.SelectAllVendors(metrics.MockServiceContext, cid)
+				seen <- vendors
+			}()
+
+			if tc.commit {
+				require.Nil(t, tx.Commit())
+			} else {
+				require.Nil(t, tx.Rollback())
+			}
+			_ = tx.Rollback()
+
+			var names []string
+			for _, v := range <-seen {
+				names = append(names, v.Name)
+			}
+			sort.Strings(names)
+			require.Equal(t, tc.want, names)
+		})
+	}
+}
+
+func Test_parseTime(t *testing.T) {
+	t.Parallel()
+
+	when := time.Date(2026, 5, 1, 12, 30, 0, 123, time.UTC)
+
+	tcs := map[string]struct {
+		src    any
+		result *time.Time
+		err    bool
+	}{
+		"null":       {},
+		"time":       {src: when.In(time.FixedZone("x", 3600)), result: &when},
+		"string":     {src: stamp(when), result: &when},
+		"bytes":      {src: []byte(stamp(when)), result: &when},
+		"rfc3339":    {src: "2026-05-01T13:30:00.000000123+01:00", result: &when},
+		"not_a_time": {src: "yesterday", err: true},
+		"number":     {src: int64(12), err: true},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := parseTime(tc.src)
+			if tc.err {
+				require.NotNil(t, err)
+				return
+			}
+			require.Nil(t, err)
+			require.Equal(t, tc.result, result)
+		})
+	}
+}
+
+func Test_scanners(t *testing.T) {
+	t.Parallel()
+
+	when := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	var got time.Time
+	require.Nil(t, ts(&got).Scan(stamp(when)))
+	require.Equal(t, when, got)
+	// only nullable columns can be null
+	require.NotNil(t, ts(&got).Scan(nil))
+
+	maybe := &when
+	require.Nil(t, nts(&maybe).Scan(nil))
+	require.Nil(t, maybe)
+	require.Nil(t, nts(&maybe).Scan(stamp(when)))
+	require.Equal(t, &when, maybe)
+}
+
+func Test_stamp(t *testing.T) {
+	t.Parallel()
+
+	// stamps sort the same as the times they're from, whatever their zone
+	early := time.Date(2026, 5, 1, 9, 0, 0, 5, time.UTC)
+	late := time.Date(2026, 5, 1, 10, 30, 0, 0, time.FixedZone("x", 3600))
+	require.Less(t, stamp(early), stamp(late))
+	require.Len(t, stamp(early), len(stampFormat))
+}
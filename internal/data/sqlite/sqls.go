@@ -0,0 +1,700 @@
+package sqlite
+
+// sqls are huautla's queries, rewritten for sqlite; the keys are the same
+// so it's easy to compare them. Parameters are numbered (?1) rather than
+// named ($1), since sqlite numbers named parameters by where they first
+// show up, not by their names
+var sqls = map[string]map[string]string{
+
+	"event": {
+		"all-by-observable": `
+      select  e.uuid,
+              e.temperature,
+              e.humidity,
+              e.mtime,
+              e.ctime,
+              et.uuid,
+              et.name,
+              et.severity,
+              s.uuid,
+              s.name
+        from  events e
+        join  event_types et
+          on  e.eventtype_uuid = et.uuid
+        join  stages s
+          on  et.stage_uuid = s.uuid
+       where  e.observable_uuid = ?1
+       order
+          by  e.mtime desc`,
+		"all-by-eventtype": `
+      select  e.uuid,
+              e.temperature,
+              e.humidity,
+              e.mtime,
+              e.ctime,
+              et.uuid,
+              et.name,
+              et.severity,
+              s.uuid,
+              s.name
+        from  events e
+        join  event_types et
+          on  e.eventtype_uuid = et.uuid
+        join  stages s
+          on  et.stage_uuid = s.uuid
+       where  et.uuid = ?1`,
+		"notes-and-photos": `
+      select  e.uuid,
+              n.uuid,
+              n.note,
+              n.mtime,
+              n.ctime,
+              p.uuid,
+              p.filename,
+              p.mtime,
+              p.ctime,
+              pn.uuid,
+              pn.note,
+              pn.mtime,
+              pn.ctime
+        from  events e
+        left
+        join  notes n
+          on  e.uuid = n.notable_uuid
+        left
+        join  photos p
+          on  e.uuid = p.photoable_uuid
+        left
+        join  notes pn
+          on  p.uuid = pn.notable_uuid
+       where  e.observable_uuid = ?1
+         and  coalesce(n.uuid, p.uuid) is not null
+       order
+          by  e.uuid, n.mtime, p.mtime, pn.mtime`,
+		"select": `
+      select  e.uuid,
+              e.temperature,
+              e.humidity,
+              e.mtime,
+              e.ctime,
+              et.uuid,
+              et.name,
+              et.severity,
+              s.uuid,
+              s.name
+        from  events e
+        join  event_types et
+          on  e.eventtype_uuid = et.uuid
+        join  stages s
+          on  et.stage_uuid = s.uuid
+       where  e.uuid = ?1`,
+		"add": `
+      insert
+        into  events(uuid, temperature, humidity, mtime, ctime, observable_uuid, eventtype_uuid)
+      select  ?1, ?2, ?3, ?4, ?5, ?6, et.uuid
+        from  event_types et
+       where  et.uuid = ?7`,
+		"change": `
+      update  events
+         set  temperature = ?1,
+              humidity = ?2,
+              mtime = ?3,
+              eventtype_uuid = ?5
+       where  uuid = ?4
+         and  exists (select 1 from event_types where uuid = ?5)`,
+		"remove": `delete from events where uuid = ?1`,
+	},
+
+	"eventtype": {
+		"select-all": `
+      select  e.uuid,
+              e.name,
+              e.severity,
+              s.uuid,
+              s.name
+        from  event_types e
+        join  stages s
+          on  e.stage_uuid = s.uuid
+       order
+          by  s.name, e.name`,
+		"select": `
+      select  e.uuid,
+              e.name,
+              e.severity,
+              s.uuid,
+              s.name
+        from  event_types e
+        join  stages s
+          on  e.stage_uuid = s.uuid
+       where  e.uuid = ?1`,
+		"insert": `
+      insert
+        into  event_types(uuid, name, severity, stage_uuid)
+      select  ?1, ?2, ?3, s.uuid
+        from  stages s
+       where  s.uuid = ?4`,
+		"update": `
+      update  event_types
+         set  name = ?1,
+              severity = ?2,
+              stage_uuid = ?3,
+              mtime = ?5
+       where  uuid = ?4`,
+		"delete": `delete from event_types where uuid = ?1`,
+	},
+
+	"generation": {
+		"ndx": `
+      select  g.uuid,
+              ps.uuid,
+              ps.name,
+              ps.type,
+              psv.uuid,
+              psv.name,
+              psv.website,
+              ls.uuid,
+              ls.name,
+              ls.type,
+              lsv.uuid,
+              lsv.name,
+              lsv.website,
+              s.uuid,
+              s.type,
+              lc.uuid,
+              coalesce(lc.strain_uuid, s.progenitor_uuid),
+              st.name,
+              st.species,
+              st.ctime,
+              stv.uuid,
+              stv.name,
+              stv.website,
+              g.mtime,
+              g.ctime,
+              g.dtime
+        from  generations g
+        join  substrates ps
+          on  g.platingsubstrate_uuid = ps.uuid
+        join  vendors psv
+          on  ps.vendor_uuid = psv.uuid
+        join  substrates ls
+          on  g.liquidsubstrate_uuid = ls.uuid
+        join  vendors lsv
+          on  ls.vendor_uuid = lsv.uuid
+        left
+        join  sources s
+          on  g.uuid = s.generation_uuid
+        left
+        join  events e
+          on  s.progenitor_uuid = e.uuid
+        left
+        join  lifecycles lc
+          on  e.observable_uuid = lc.uuid
+        left
+        join  strains st
+          on  st.uuid = coalesce(lc.strain_uuid, s.progenitor_uuid)
+        left
+        join  vendors stv
+          on  st.vendor_uuid = stv.uuid
+       order
+          by  g.uuid`,
+		// a generation with no sources matches anything but a strain, and
+		// one with no events matches anything but an event type
+		"select": `
+        with  strain_sources as (
+      select  so.generation_uuid,
+              st.uuid as strain_uuid
+        from  sources so
+        join  strains st
+          on  so.progenitor_uuid = st.uuid
+       union
+      select  so.generation_uuid,
+              st.uuid
+        from  sources so
+        join  events ev
+          on  so.progenitor_uuid = ev.uuid
+        join  lifecycles lc
+          on  ev.observable_uuid = lc.uuid
+        join  strains st
+          on  lc.strain_uuid = st.uuid
+      )
+      select  g.uuid,
+              ps.uuid,
+              ps.name,
+              ps.type,
+              psv.uuid,
+              psv.name,
+              psv.website,
+              ls.uuid,
+              ls.name,
+              ls.type,
+              lsv.uuid,
+              lsv.name,
+              lsv.website,
+              g.mtime,
+              g.ctime,
+              g.dtime
+        from  generations g
+        join  substrates ps
+          on  g.platingsubstrate_uuid = ps.uuid
+        join  vendors psv
+          on  ps.vendor_uuid = psv.uuid
+        join  substrates ls
+          on  g.liquidsubstrate_uuid = ls.uuid
+        join  vendors lsv
+          on  ls.vendor_uuid = lsv.uuid
+       where  g.uuid = coalesce(?1, g.uuid)
+         and  (?2 is null or exists (
+                select  1
+                  from  strain_sources ss
+                 where  ss.generation_uuid = g.uuid
+                   and  ss.strain_uuid = ?2))
+         and  ps.uuid = coalesce(?3, ps.uuid)
+         and  ls.uuid = coalesce(?4, ls.uuid)
+         and  (?5 is null or exists (
+                select  1
+                  from  events e
+                 where  e.observable_uuid = g.uuid
+                   and  e.eventtype_uuid = ?5))
+       order
+          by  g.uuid`,
+		"insert": `
+      insert  into generations(uuid, platingsubstrate_uuid, liquidsubstrate_uuid, mtime, ctime)
+      select  ?1, ps.uuid, ls.uuid, ?4, ?4
+        from  substrates ps,
+              substrates ls
+       where  ps.type = 'plating'
+         and  ls.type = 'liquid'
+         and  ps.uuid = ?2
+         and  ls.uuid = ?3`,
+		"update": `
+      update  generations
+         set  platingsubstrate_uuid = ?1,
+              liquidsubstrate_uuid = ?2,
+              mtime = ?4
+       where  uuid = ?3
+         and  exists (select 1 from substrates where uuid = ?1 and type = 'plating')
+         and  exists (select 1 from substrates where uuid = ?2 and type = 'liquid')`,
+		"delete": `update generations set dtime = ?2 where uuid = ?1`,
+	},
+
+	"ingredient": {
+		"select-all": `select uuid, name from ingredients order by name`,
+		"select":     `select name from ingredients where uuid = ?1`,
+		"insert":     `insert into ingredients(uuid, name) values(?1, ?2)`,
+		"update":     `update ingredients set name = ?1 where uuid = ?2`,
+		"delete":     `delete from ingredients where uuid = ?1`,
+	},
+
+	"lifecycle": {
+		"index": `
+      select  distinct
+              l.uuid,
+              l.location,
+              l.mtime,
+              l.ctime,
+              s.uuid,
+              s.species,
+              s.name,
+              s.ctime,
+              v.uuid,
+              v.name,
+              v.website,
+              e.uuid,
+              e.temperature,
+              e.humidity,
+              e.mtime,
+              e.ctime,
+              et.uuid,
+              et.name,
+              et.severity,
+              st.uuid,
+              st.name
+        from  lifecycles l
+        join  strains s
+          on  l.strain_uuid = s.uuid
+        join  vendors v
+          on  s.vendor_uuid = v.uuid
+        left
+        join  events e
+          on  e.observable_uuid = l.uuid
+         and  e.eventtype_uuid in ('sunset', 'sporeprint', 'clone')
+        left
+        join  event_types et
+          on  e.eventtype_uuid = et.uuid
+        left
+        join  stages st
+          on  et.stage_uuid = st.uuid
+       order
+          by  l.mtime desc, l.uuid`,
+		"select": `
+      select  lc.uuid,
+              lc.location,
+              lc.strain_cost,
+              lc.grain_cost,
+              lc.bulk_cost,
+              lc.yield,
+              lc.headcount,
+              lc.gross,
+              lc.mtime,
+              lc.ctime,
+              s.uuid,
+              s.species,
+              s.name,
+              s.generation_uuid,
+              s.ctime,
+              s.dtime,
+              sv.uuid,
+              sv.name,
+              sv.website,
+              gs.uuid,
+              gs.name,
+              gs.type,
+              gv.uuid,
+              gv.name,
+              gv.website,
+              bs.uuid,
+              bs.name,
+              bs.type,
+              bv.uuid,
+              bv.name,
+              bv.website
+        from  lifecycles lc
+        join  strains s
+          on  lc.strain_uuid = s.uuid
+        join  vendors sv
+          on  s.vendor_uuid = sv.uuid
+        join  substrates gs
+          on  lc.grainsubstrate_uuid = gs.uuid
+        join  vendors gv
+          on  gs.vendor_uuid = gv.uuid
+        join  substrates bs
+          on  lc.bulksubstrate_uuid = bs.uuid
+        join  vendors bv
+          on  bs.vendor_uuid = bv.uuid
+       where  lc.uuid = coalesce(?1, lc.uuid)
+         and  s.uuid = coalesce(?2, s.uuid)
+         and  gs.uuid = coalesce(?3, gs.uuid)
+         and  bs.uuid = coalesce(?4, bs.uuid)
+         and  (?5 is null or exists (
+                select  1
+                  from  events e
+                 where  e.observable_uuid = lc.uuid
+                   and  e.eventtype_uuid = ?5))
+       order
+          by  lc.mtime desc, lc.uuid`,
+		"insert": `
+      insert
+        into  lifecycles(
+              uuid,
+              location,
+              strain_cost,
+              grain_cost,
+              bulk_cost,
+              yield,
+              headcount,
+              gross,
+              mtime,
+              ctime,
+              strain_uuid,
+              grainsubstrate_uuid,
+              bulksubstrate_uuid)
+      select  ?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, s.uuid, gs.uuid, bs.uuid
+        from  strains s,
+              substrates gs,
+              substrates bs
+       where  s.uuid = ?11
+         and  gs.uuid = ?12
+         and  gs.type = 'grain'
+         and  bs.uuid = ?13
+         and  bs.type = 'bulk'`,
+		"update": `
+      update  lifecycles
+         set  location = ?1,
+              strain_cost = ?2,
+              grain_cost = ?3,
+              bulk_cost = ?4,
+              yield = ?5,
+              headcount = ?6,
+              gross = ?7,
+              mtime = ?8,
+              strain_uuid = ?9,
+              grainsubstrate_uuid = ?10,
+              bulksubstrate_uuid = ?11
+       where  uuid = ?12
+         and  exists (select 1 from strains where uuid = ?9)
+         and  exists (select 1 from substrates where uuid = ?10 and type = 'grain')
+         and  exists (select 1 from substrates where uuid = ?11 and type = 'bulk')`,
+		"delete": `delete from lifecycles where uuid = ?1`,
+	},
+
+	"note": {
+		"get": `
+      select  uuid,
+              note,
+              mtime,
+              ctime
+        from  notes
+       where  notable_uuid = ?1
+       order
+          by  mtime desc`,
+		"add": `
+      insert  into notes(uuid, note, notable_uuid, mtime, ctime)
+      values  (?1, ?2, ?3, ?4, ?4)`,
+		"change": `
+      update  notes
+         set  note = ?1,
+              mtime = ?2
+       where  uuid = ?3`,
+		"remove": `delete from notes where uuid = ?1`,
+	},
+
+	"photo": {
+		"get": `
+      select  p.uuid,
+              p.filename,
+              p.mtime,
+              p.ctime,
+              n.uuid,
+              n.note,
+              n.mtime,
+              n.ctime
+        from  photos p
+        left
+        join  notes n
+          on  n.notable_uuid = p.uuid
+       where  p.photoable_uuid = ?1
+       order
+          by  p.mtime desc, p.uuid, n.mtime desc`,
+		"add": `
+      insert  into photos(uuid, filename, photoable_uuid, mtime, ctime)
+      values  (?1, ?2, ?3, ?4, ?5)`,
+		"change": `
+      update  photos
+         set  filename = ?1,
+              mtime = ?2
+       where  uuid = ?3`,
+		"remove": `delete from photos where uuid = ?1`,
+	},
+
+	"source": {
+		"get": `
+      select  s.uuid,
+              s.type,
+              s.progenitor_uuid,
+              lc.uuid,
+              st.uuid,
+              st.name,
+              st.species,
+              st.ctime,
+              st.dtime,
+              v.uuid,
+              v.name,
+              v.website
+        from  sources s
+        left
+        join  events e
+          on  s.progenitor_uuid = e.uuid
+        left
+        join  lifecycles lc
+          on  e.observable_uuid = lc.uuid
+        join  strains st
+          on  st.uuid = coalesce(lc.strain_uuid, s.progenitor_uuid)
+        join  vendors v
+          on  st.vendor_uuid = v.uuid
+       where  s.generation_uuid = ?1`,
+		"add": `
+      insert
+        into  sources(uuid, type, progenitor_uuid, generation_uuid, mtime)
+      values  (?1, ?2, ?3, ?4, ?5)`,
+		"change": `
+      update  sources
+         set  type = ?1,
+              mtime = ?3
+       where  uuid = ?2`,
+		"delete": `delete from sources where uuid = ?1`,
+	},
+
+	"stage": {
+		"select-all": `select uuid, name from stages order by name`,
+		"select":     `select name from stages where uuid = ?1`,
+		"insert":     `insert into stages(uuid, name) values(?1, ?2)`,
+		"update":     `update stages set name = ?1 where uuid = ?2`,
+		"delete":     `delete from stages where uuid = ?1`,
+	},
+
+	"strain": {
+		"select-all": `
+      select  s.uuid,
+              s.species,
+              s.name,
+              s.ctime,
+              s.dtime,
+              v.uuid,
+              v.name,
+              v.website,
+              s.generation_uuid
+        from  strains s
+        join  vendors v
+          on  s.vendor_uuid = v.uuid
+       order
+          by  s.name`,
+		"select": `
+      select  s.uuid,
+              s.species,
+              s.name,
+              s.ctime,
+              s.dtime,
+              v.uuid,
+              v.name,
+              v.website,
+              s.generation_uuid
+        from  strains s
+        join  vendors v
+          on  s.vendor_uuid = v.uuid
+       where  s.uuid = coalesce(?1, s.uuid)
+         and  v.uuid = coalesce(?2, v.uuid)
+       order
+          by  s.name`,
+		"insert": `
+      insert
+        into  strains(uuid, species, name, ctime, vendor_uuid)
+      select  ?1, ?2, ?3, ?4, v.uuid
+        from  vendors v
+       where  v.uuid = ?5`,
+		"update": `
+      update  strains
+         set  species = ?1,
+              name = ?2,
+              vendor_uuid = ?3
+       where  uuid = ?4`,
+		"delete": `update strains set mtime = ?2, dtime = ?2 where uuid = ?1`,
+		"generated-strain": `
+      select  s.uuid,
+              s.species,
+              s.name,
+              s.ctime,
+              s.dtime,
+              v.uuid,
+              v.name,
+              v.website
+        from  strains s
+        join  vendors v
+          on  s.vendor_uuid = v.uuid
+       where  s.generation_uuid = ?1
+       order
+          by  s.name, s.ctime`,
+		"update-gen-strain": `
+      update  strains
+         set  generation_uuid = ?1
+       where  uuid = ?2`,
+	},
+
+	"strainattribute": {
+		"get-unique-names": `select distinct name from strain_attributes order by name`,
+		"all": `
+      select  uuid, name, value
+        from  strain_attributes
+       where  strain_uuid = ?1
+       order
+          by  name`,
+		"add": `
+      insert
+        into  strain_attributes(uuid, name, value, strain_uuid)
+      select  ?1, ?2, ?3, s.uuid
+        from  strains s
+       where  s.uuid = ?4`,
+		"change": `
+      update  strain_attributes
+         set  value = ?1,
+              name = ?2
+       where  uuid = ?3`,
+		"remove": `delete from strain_attributes where uuid = ?1`,
+	},
+
+	"substrate-ingredient": {
+		"all": `
+      select  i.uuid,
+              i.name
+        from  ingredients i
+        join  substrate_ingredients si
+          on  i.uuid = si.ingredient_uuid
+       where  si.substrate_uuid = ?1
+       order
+          by  i.name`,
+		"add": `
+      insert
+        into  substrate_ingredients(uuid, substrate_uuid, ingredient_uuid)
+      select  ?1, s.uuid, i.uuid
+        from  substrates s
+        join  ingredients i
+          on  s.uuid = ?2
+         and  i.uuid = ?3`,
+		"change": `
+      update  substrate_ingredients
+         set  ingredient_uuid = ?1
+       where  substrate_uuid = ?2
+         and  ingredient_uuid = ?3`,
+		"remove": `
+      delete
+        from  substrate_ingredients
+       where  substrate_uuid = ?1
+         and  ingredient_uuid = ?2`,
+	},
+
+	"substrate": {
+		"select-all": `
+      select  s.uuid,
+              s.name,
+              s.type,
+              v.uuid,
+              v.name,
+              v.website
+        from  substrates s
+        join  vendors v
+          on  s.vendor_uuid = v.uuid
+       order
+          by  s.name`,
+		"select": `
+      select  s.uuid,
+              s.name,
+              s.type,
+              v.uuid,
+              v.name,
+              v.website
+        from  substrates s
+        join  vendors v
+          on  s.vendor_uuid = v.uuid
+       where  s.uuid = coalesce(?1, s.uuid)
+         and  v.uuid = coalesce(?2, v.uuid)
+       order
+          by  s.name`,
+		"insert": `
+      insert
+        into  substrates(uuid, name, type, vendor_uuid)
+      select  ?1, ?2, ?3, v.uuid
+        from  vendors v
+       where  v.uuid = ?4`,
+		"update": `
+      update  substrates
+         set  name = ?1,
+              type = ?2,
+              vendor_uuid = ?3
+       where  uuid = ?4
+         and  exists (select 1 from vendors where uuid = ?3)`,
+		"delete": `delete from substrates where uuid = ?1`,
+	},
+
+	"timestamp": {
+		"touch":    `update %s set mtime = ?1 where uuid = ?2`,
+		"update":   `update %s set %s where uuid = ?1`,
+		"undelete": `update %s set dtime = null where uuid = ?1`,
+	},
+
+	"vendor": {
+		"select-all": `select uuid, name, website from vendors order by name`,
+		"select":     `select uuid, name, website from vendors where uuid = ?1`,
+		"insert":     `insert into vendors(uuid, name, website) values(?1, ?2, ?3)`,
+		"update":     `update vendors set name = ?1, website = ?2 where uuid = ?3`,
+		"delete":     `delete from vendors where uuid = ?1`,
+	},
+}
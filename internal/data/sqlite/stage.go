@@ -0,0 +1,48 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/data/ids"
+	"github.com/jsmit257/huautla/types"
+)
+
+func (db *Conn) SelectAllStages(ctx context.Context, _ types.CID) ([]types.Stage, error) {
+	rows, err := db.conn(ctx).QueryContext(ctx, sqls["stage"]["select-all"])
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]types.Stage, 0, 100)
+	for rows.Next() {
+		row := types.Stage{}
+		if err = rows.Scan(&row.UUID, &row.Name); err != nil {
+			return nil, err
+		}
+		result = append(result, row)
+	}
+
+	return result, rows.Err()
+}
+
+func (db *Conn) SelectStage(ctx context.Context, id types.UUID, _ types.CID) (types.Stage, error) {
+	result := types.Stage{UUID: id}
+	return result, db.conn(ctx).
+		QueryRowContext(ctx, sqls["stage"]["select"], id).
+		Scan(&result.Name)
+}
+
+func (db *Conn) InsertStage(ctx context.Context, s types.Stage, _ types.CID) (types.Stage, error) {
+	s.UUID = ids.Or(ctx, s.UUID, db.newID)
+	return s, db.exec(ctx, "stage was not added", sqls["stage"]["insert"], s.UUID, s.Name)
+}
+
+func (db *Conn) UpdateStage(ctx context.Context, id types.UUID, s types.Stage, _ types.CID) error {
+	return db.exec(ctx, fmt.Sprintf("stage was not updated: '%s'", id), sqls["stage"]["update"], s.Name, id)
+}
+
+func (db *Conn) DeleteStage(ctx context.Context, id types.UUID, _ types.CID) error {
+	return db.deleteByUUID(ctx, id, "stage")
+}
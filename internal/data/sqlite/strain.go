@@ -0,0 +1,188 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/data/ids"
+	"github.com/jsmit257/huautla/types"
+)
+
+func (db *Conn) SelectAllStrains(ctx context.Context, _ types.CID) ([]types.Strain, error) {
+	return db.scanStrains(ctx, sqls["strain"]["select-all"])
+}
+
+func (db *Conn) SelectStrain(ctx context.Context, id types.UUID, cid types.CID) (types.Strain, error) {
+	p, _ := types.NewReportAttrs(url.Values{"strain-id": {string(id)}})
+
+	if strs, err := db.selectStrains(ctx, p, cid); err != nil {
+		return types.Strain{}, err
+	} else if len(strs) == 1 {
+		return strs[0], nil
+	}
+
+	return types.Strain{}, sql.ErrNoRows
+}
+
+func (db *Conn) selectStrains(ctx context.Context, p types.ReportAttrs, cid types.CID) ([]types.Strain, error) {
+	result, err := db.scanStrains(ctx, sqls["strain"]["select"], p.Get("strain-id"), p.Get("vendor-id"))
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range result {
+		if err = db.GetAllAttributes(ctx, &result[i], cid); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+func (db *Conn) scanStrains(ctx context.Context, query string, args ...any) ([]types.Strain, error) {
+	rows, err := db.conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]types.Strain, 0, 100)
+	for rows.Next() {
+		row := types.Strain{}
+		var generationID *types.UUID
+		if err = rows.Scan(
+			&row.UUID,
+			&row.Species,
+			&row.Name,
+			ts(&row.CTime),
+			nts(&row.DTime),
+			&row.Vendor.UUID,
+			&row.Vendor.Name,
+			&row.Vendor.Website,
+			&generationID,
+		); err != nil {
+			return nil, err
+		}
+
+		if generationID != nil {
+			row.Generation = &types.Generation{UUID: *generationID}
+		}
+
+		result = append(result, row)
+	}
+
+	return result, rows.Err()
+}
+
+func (db *Conn) InsertStrain(ctx context.Context, s types.Strain, _ types.CID) (types.Strain, error) {
+	s.UUID = ids.Or(ctx, s.UUID, db.newID)
+	s.CTime = db.now()
+	// the most likely reason for nothing to be added is a bad vendor
+	return s, db.exec(ctx, "strain was not added", sqls["strain"]["insert"], s.UUID, s.Species, s.Name, stamp(s.CTime), s.Vendor.UUID)
+}
+
+func (db *Conn) UpdateStrain(ctx context.Context, id types.UUID, s types.Strain, _ types.CID) error {
+	return db.exec(ctx, fmt.Sprintf("strain was not updated: '%s'", id), sqls["strain"]["update"], s.Species, s.Name, s.Vendor.UUID, id)
+}
+
+// DeleteStrain only marks the strain deleted, Undelete takes it back
+func (db *Conn) DeleteStrain(ctx context.Context, id types.UUID, _ types.CID) error {
+	return db.exec(ctx, fmt.Sprintf("strain could not be deleted: '%s'", id), sqls["strain"]["delete"], id, stamp(db.now()))
+}
+
+func (db *Conn) GeneratedStrain(ctx context.Context, id types.UUID, _ types.CID) (types.Strain, error) {
+	result := types.Strain{}
+	return result, db.conn(ctx).
+		QueryRowContext(ctx, sqls["strain"]["generated-strain"], id).
+		Scan(
+			&result.UUID,
+			&result.Species,
+			&result.Name,
+			ts(&result.CTime),
+			nts(&result.DTime),
+			&result.Vendor.UUID,
+			&result.Vendor.Name,
+			&result.Vendor.Website)
+}
+
+func (db *Conn) UpdateGeneratedStrain(ctx context.Context, gid *types.UUID, sid types.UUID, _ types.CID) error {
+	if result, err := db.conn(ctx).ExecContext(ctx, sqls["strain"]["update-gen-strain"], gid, sid); err != nil {
+		return err
+	} else if rows, err := result.RowsAffected(); err != nil {
+		return err
+	} else if rows != 1 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (db *Conn) StrainReport(ctx context.Context, id types.UUID, cid types.CID) (types.Entity, error) {
+	param, err := types.NewReportAttrs(url.Values{"strain-id": {string(id)}})
+	if err != nil {
+		return nil, err
+	}
+
+	if result, err := db.strainReport(ctx, param, cid, nil); err != nil {
+		return nil, err
+	} else if len(result) == 1 {
+		return result[0], nil
+	}
+
+	return nil, sql.ErrNoRows
+}
+
+func (db *Conn) strainReport(ctx context.Context, params types.ReportAttrs, cid types.CID, p *rpttree) ([]types.Entity, error) {
+	strs, err := db.selectStrains(ctx, params, cid)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]types.Entity, 0, len(strs))
+	for _, str := range strs {
+		if e, err := db.report(ctx, strain(str), cid, p); err != nil {
+			return nil, err
+		} else if e != nil {
+			result = append(result, e)
+		}
+	}
+
+	return result, nil
+}
+
+func (s strain) children(ctx context.Context, db *Conn, cid types.CID, p *rpttree) error {
+	param, _ := types.NewReportAttrs(url.Values{"strain-id": {string(s.UUID)}})
+
+	if gens, err := db.generationReport(ctx, param, cid, p); err != nil {
+		return err
+	} else if len(gens) != 0 {
+		p.data["generations"] = gens
+	}
+
+	if lcs, err := db.lifecycleReport(ctx, param, cid, p); err != nil {
+		return err
+	} else if len(lcs) != 0 {
+		p.data["lifecycles"] = lcs
+	}
+
+	if photos, err := db.photosReport(ctx, s.UUID, cid, p); err != nil {
+		return err
+	} else if len(photos) != 0 {
+		p.data["photos"] = photos
+	}
+
+	if s.Generation == nil {
+		return nil
+	}
+
+	param, _ = types.NewReportAttrs(url.Values{"generation-id": {string(s.Generation.UUID)}})
+	if gens, err := db.generationReport(ctx, param, cid, p); err != nil {
+		return err
+	} else if len(gens) != 0 {
+		// it's empty if the generation is already further up the report
+		p.data["generation"] = gens[0]
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package sqlite
+
+import (
+	"context"
+	"slices"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/data/ids"
+	"github.com/jsmit257/huautla/types"
+)
+
+func (db *Conn) KnownAttributeNames(ctx context.Context, _ types.CID) ([]string, error) {
+	rows, err := db.conn(ctx).QueryContext(ctx, sqls["strainattribute"]["get-unique-names"])
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []string{}
+	for rows.Next() {
+		var s string
+		if err = rows.Scan(&s); err != nil {
+			return []string{}, err
+		}
+		result = append(result, s)
+	}
+
+	return result, rows.Err()
+}
+
+func (db *Conn) GetAllAttributes(ctx context.Context, s *types.Strain, _ types.CID) error {
+	rows, err := db.conn(ctx).QueryContext(ctx, sqls["strainattribute"]["all"], s.UUID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	s.Attributes = make([]types.StrainAttribute, 0, 100)
+	for rows.Next() {
+		row := types.StrainAttribute{}
+		if err = rows.Scan(&row.UUID, &row.Name, &row.Value); err != nil {
+			return err
+		}
+		s.Attributes = append(s.Attributes, row)
+	}
+
+	return rows.Err()
+}
+
+func (db *Conn) AddAttribute(ctx context.Context, s *types.Strain, a types.StrainAttribute, _ types.CID) (types.StrainAttribute, error) {
+	a.UUID = ids.Or(ctx, a.UUID, db.newID)
+	if err := db.exec(ctx, "attribute was not added", sqls["strainattribute"]["add"], a.UUID, a.Name, a.Value, s.UUID); err != nil {
+		return a, err
+	}
+	s.Attributes = append(s.Attributes, a)
+	return a, nil
+}
+
+func (db *Conn) ChangeAttribute(ctx context.Context, s *types.Strain, a types.StrainAttribute, _ types.CID) error {
+	if err := db.exec(ctx, "attribute was not changed", sqls["strainattribute"]["change"], a.Value, a.Name, a.UUID); err != nil {
+		return err
+	}
+	if i := slices.IndexFunc(s.Attributes, func(old types.StrainAttribute) bool { return old.UUID == a.UUID }); i >= 0 {
+		s.Attributes[i] = a
+	}
+	return nil
+}
+
+func (db *Conn) RemoveAttribute(ctx context.Context, s *types.Strain, id types.UUID, _ types.CID) error {
+	if err := db.exec(ctx, "attribute was not removed", sqls["strainattribute"]["remove"], id); err != nil {
+		return err
+	}
+	s.Attributes = slices.DeleteFunc(s.Attributes, func(old types.StrainAttribute) bool { return old.UUID == id })
+	return nil
+}
@@ -0,0 +1,142 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/data/ids"
+	"github.com/jsmit257/huautla/types"
+)
+
+func (db *Conn) SelectAllSubstrates(ctx context.Context, cid types.CID) ([]types.Substrate, error) {
+	return db.scanSubstrates(ctx, cid, sqls["substrate"]["select-all"])
+}
+
+func (db *Conn) SelectSubstrate(ctx context.Context, id types.UUID, cid types.CID) (types.Substrate, error) {
+	param, err := types.NewReportAttrs(url.Values{"substrate-id": {string(id)}})
+	if err != nil {
+		return types.Substrate{}, err
+	}
+
+	if subs, err := db.selectSubstrates(ctx, param, cid); err != nil {
+		return types.Substrate{}, err
+	} else if len(subs) == 1 {
+		return subs[0], nil
+	}
+
+	return types.Substrate{}, sql.ErrNoRows
+}
+
+func (db *Conn) selectSubstrates(ctx context.Context, param types.ReportAttrs, cid types.CID) ([]types.Substrate, error) {
+	if !param.Contains("substrate-id", "vendor-id") {
+		return nil, fmt.Errorf("request doesn't contain at least 1 required field: %#v", param)
+	}
+	return db.scanSubstrates(ctx, cid, sqls["substrate"]["select"],
+		param.Get("substrate-id"),
+		param.Get("vendor-id"))
+}
+
+func (db *Conn) scanSubstrates(ctx context.Context, cid types.CID, query string, args ...any) ([]types.Substrate, error) {
+	rows, err := db.conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]types.Substrate, 0, 100)
+	for rows.Next() {
+		row := types.Substrate{}
+		if err = rows.Scan(
+			&row.UUID,
+			&row.Name,
+			&row.Type,
+			&row.Vendor.UUID,
+			&row.Vendor.Name,
+			&row.Vendor.Website); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		result = append(result, row)
+	}
+	// there's only one connection, so the rows have to be done with before
+	// the ingredients can be read
+	if err = rows.Close(); err != nil {
+		return nil, err
+	} else if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range result {
+		if err = db.GetAllIngredients(ctx, &result[i], cid); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+func (db *Conn) InsertSubstrate(ctx context.Context, s types.Substrate, _ types.CID) (types.Substrate, error) {
+	s.UUID = ids.Or(ctx, s.UUID, db.newID)
+	// the most likely reason for nothing to be added is a bad vendor
+	return s, db.exec(ctx, "substrate was not added", sqls["substrate"]["insert"], s.UUID, s.Name, s.Type, s.Vendor.UUID)
+}
+
+func (db *Conn) UpdateSubstrate(ctx context.Context, id types.UUID, s types.Substrate, _ types.CID) error {
+	return db.exec(ctx, fmt.Sprintf("substrate was not updated: '%s'", id), sqls["substrate"]["update"], s.Name, s.Type, s.Vendor.UUID, id)
+}
+
+func (db *Conn) DeleteSubstrate(ctx context.Context, id types.UUID, _ types.CID) error {
+	return db.deleteByUUID(ctx, id, "substrate")
+}
+
+func (db *Conn) SubstrateReport(ctx context.Context, id types.UUID, cid types.CID) (types.Entity, error) {
+	param, err := types.NewReportAttrs(url.Values{"substrate-id": {string(id)}})
+	if err != nil {
+		return nil, err
+	}
+
+	if result, err := db.substrateReport(ctx, param, cid, nil); err != nil {
+		return nil, err
+	} else if len(result) == 1 {
+		return result[0], nil
+	}
+
+	return nil, sql.ErrNoRows
+}
+
+func (db *Conn) substrateReport(ctx context.Context, param types.ReportAttrs, cid types.CID, p *rpttree) ([]types.Entity, error) {
+	subs, err := db.selectSubstrates(ctx, param, cid)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]types.Entity, 0, len(subs))
+	for _, s := range subs {
+		if e, err := db.report(ctx, substrate(s), cid, p); err != nil {
+			return nil, err
+		} else if e != nil {
+			result = append(result, e)
+		}
+	}
+
+	return result, nil
+}
+
+func (s substrate) children(ctx context.Context, db *Conn, cid types.CID, p *rpttree) error {
+	getter, key := db.lifecycleReport, "lifecycles"
+	switch s.Type {
+	case types.PlatingType, types.LiquidType:
+		getter, key = db.generationReport, "generations"
+	}
+
+	if param, err := types.NewReportAttrs(url.Values{fmt.Sprintf("%s-id", s.Type): {string(s.UUID)}}); err != nil {
+		return err
+	} else if values, err := getter(ctx, param, cid, p); err != nil {
+		return err
+	} else if len(values) != 0 {
+		p.data[key] = values
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package sqlite
+
+import (
+	"context"
+	"slices"
+
+	"github.com/jsmit257/huautla/types"
+)
+
+func (db *Conn) GetAllIngredients(ctx context.Context, s *types.Substrate, _ types.CID) error {
+	rows, err := db.conn(ctx).QueryContext(ctx, sqls["substrate-ingredient"]["all"], s.UUID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	ing := make([]types.Ingredient, 0, 100)
+	for rows.Next() {
+		row := types.Ingredient{}
+		if err = rows.Scan(&row.UUID, &row.Name); err != nil {
+			return err
+		}
+		ing = append(ing, row)
+	}
+	s.Ingredients = ing
+
+	return rows.Err()
+}
+
+func (db *Conn) AddIngredient(ctx context.Context, s *types.Substrate, i types.Ingredient, _ types.CID) error {
+	if err := db.exec(ctx, "substrateingredient was not added", sqls["substrate-ingredient"]["add"], db.newID(), s.UUID, i.UUID); err != nil {
+		return err
+	}
+	s.Ingredients = append(s.Ingredients, i)
+	return nil
+}
+
+func (db *Conn) ChangeIngredient(ctx context.Context, s *types.Substrate, oldI, newI types.Ingredient, _ types.CID) error {
+	if err := db.exec(ctx, "substrateingredient was not changed", sqls["substrate-ingredient"]["change"], newI.UUID, s.UUID, oldI.UUID); err != nil {
+		return err
+	}
+	if i := slices.IndexFunc(s.Ingredients, func(i types.Ingredient) bool { return i.UUID == oldI.UUID }); i >= 0 {
+		s.Ingredients[i] = newI
+	}
+	return nil
+}
+
+func (db *Conn) RemoveIngredient(ctx context.Context, s *types.Substrate, i types.Ingredient, _ types.CID) error {
+	if err := db.exec(ctx, "substrateingredient was not removed", sqls["substrate-ingredient"]["remove"], s.UUID, i.UUID); err != nil {
+		return err
+	}
+	s.Ingredients = slices.DeleteFunc(s.Ingredients, func(old types.Ingredient) bool { return old.UUID == i.UUID })
+	return nil
+}
@@ -0,0 +1,64 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/data/timeshift"
+	"github.com/jsmit257/huautla/types"
+)
+
+// timestamped is which timestamps each table has; it's also the only
+// tables and columns that ever get put into a query
+var timestamped = map[string][]string{
+	"event_types": {"mtime"},
+	"events":      {"mtime", "ctime"},
+	"generations": {"mtime", "ctime", "dtime"},
+	"lifecycles":  {"mtime", "ctime"},
+	"notes":       {"mtime", "ctime"},
+	"photos":      {"mtime", "ctime"},
+	"sources":     {"mtime"},
+	"strains":     {"mtime", "ctime", "dtime"},
+}
+
+// UpdateTimestamps sets the fields in data to its origin, moved along by
+// its factors, on the row in table with id
+func (db *Conn) UpdateTimestamps(ctx context.Context, table string, id types.UUID, data types.Timestamp) error {
+	if err := data.Validate(); err != nil {
+		return err
+	}
+
+	fields, ok := timestamped[table]
+	if !ok {
+		return fmt.Errorf("%q doesn't have timestamps", table)
+	}
+
+	set := make([]string, 0, len(data.Fields))
+	for _, f := range data.Fields {
+		if !slices.Contains(fields, f) {
+			return fmt.Errorf("%q doesn't have a %q timestamp", table, f)
+		}
+		set = append(set, f+" = ?2")
+	}
+
+	when, err := timeshift.Shift(*data.Origin, data)
+	if err != nil {
+		return err
+	}
+
+	return db.exec(ctx, "timestamps were not updated",
+		fmt.Sprintf(sqls["timestamp"]["update"], table, strings.Join(set, ", ")),
+		id,
+		stamp(when))
+}
+
+// Undelete takes back a delete that only marked something deleted; that's
+// generations and strains
+func (db *Conn) Undelete(ctx context.Context, table string, id types.UUID) error {
+	if !slices.Contains(timestamped[table], "dtime") {
+		return fmt.Errorf("%q can't be undeleted", table)
+	}
+	return db.exec(ctx, "record could not be undeleted", fmt.Sprintf(sqls["timestamp"]["undelete"], table), id)
+}
@@ -0,0 +1,60 @@
+package sqlite
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/centerforfunguscontrol/shared/metrics"
+	"github.com/jsmit257/huautla/types"
+)
+
+func Test_UpdateTimestamps(t *testing.T) {
+	t.Parallel()
+
+	origin := time.Date(2026, 1, 31, 12, 0, 0, 0, time.UTC)
+
+	// none of these get as far as the database
+	tcs := map[string]struct {
+		table string
+		data  types.Timestamp
+	}{
+		"no_fields": {
+			table: "events",
+			data:  types.Timestamp{Origin: &origin},
+		},
+		"no_origin": {
+			table: "events",
+			data:  types.Timestamp{Fields: []string{"ctime"}},
+		},
+		"no_such_table": {
+			table: "vendors",
+			data:  types.Timestamp{Fields: []string{"ctime"}, Origin: &origin},
+		},
+		"no_such_field": {
+			table: "events",
+			data:  types.Timestamp{Fields: []string{"dtime"}, Origin: &origin},
+		},
+		"injection": {
+			table: "events",
+			data:  types.Timestamp{Fields: []string{"ctime = 0; drop table events; --"}, Origin: &origin},
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			require.NotNil(t, (&Conn{}).UpdateTimestamps(metrics.MockServiceContext, tc.table, "0", tc.data))
+		})
+	}
+}
+
+func Test_Undelete(t *testing.T) {
+	t.Parallel()
+
+	for _, table := range []string{"lifecycles", "vendors", "generations; drop table strains"} {
+		require.NotNil(t, (&Conn{}).Undelete(metrics.MockServiceContext, table, "0"), table)
+	}
+}
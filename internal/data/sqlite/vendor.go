@@ -0,0 +1,75 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/data/ids"
+	"github.com/jsmit257/huautla/types"
+)
+
+func (db *Conn) SelectAllVendors(ctx context.Context, _ types.CID) ([]types.Vendor, error) {
+	rows, err := db.conn(ctx).QueryContext(ctx, sqls["vendor"]["select-all"])
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]types.Vendor, 0, 100)
+	for rows.Next() {
+		row := types.Vendor{}
+		if err = rows.Scan(&row.UUID, &row.Name, &row.Website); err != nil {
+			return nil, err
+		}
+		result = append(result, row)
+	}
+
+	return result, rows.Err()
+}
+
+func (db *Conn) SelectVendor(ctx context.Context, id types.UUID, _ types.CID) (types.Vendor, error) {
+	result := types.Vendor{}
+	return result, db.conn(ctx).
+		QueryRowContext(ctx, sqls["vendor"]["select"], id).
+		Scan(&result.UUID, &result.Name, &result.Website)
+}
+
+func (db *Conn) InsertVendor(ctx context.Context, v types.Vendor, _ types.CID) (types.Vendor, error) {
+	v.UUID = ids.Or(ctx, v.UUID, db.newID)
+	return v, db.exec(ctx, "vendor was not added", sqls["vendor"]["insert"], v.UUID, v.Name, v.Website)
+}
+
+func (db *Conn) UpdateVendor(ctx context.Context, id types.UUID, v types.Vendor, _ types.CID) error {
+	return db.exec(ctx, fmt.Sprintf("vendor was not updated: '%s'", id), sqls["vendor"]["update"], v.Name, v.Website, id)
+}
+
+func (db *Conn) DeleteVendor(ctx context.Context, id types.UUID, _ types.CID) error {
+	return db.deleteByUUID(ctx, id, "vendor")
+}
+
+func (db *Conn) VendorReport(ctx context.Context, id types.UUID, cid types.CID) (types.Entity, error) {
+	v, err := db.SelectVendor(ctx, id, cid)
+	if err != nil {
+		return nil, err
+	}
+	return db.report(ctx, vendor(v), cid, nil)
+}
+
+func (v vendor) children(ctx context.Context, db *Conn, cid types.CID, p *rpttree) error {
+	param, _ := types.NewReportAttrs(url.Values{"vendor-id": {string(v.UUID)}})
+
+	if subs, err := db.substrateReport(ctx, param, cid, p); err != nil {
+		return err
+	} else if len(subs) != 0 {
+		p.data["substrates"] = subs
+	}
+
+	if strs, err := db.strainReport(ctx, param, cid, p); err != nil {
+		return err
+	} else if len(strs) != 0 {
+		p.data["strains"] = strs
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+// Package timeshift is what postgres makes of a timestamp plus some
+// intervals, for the databases that aren't postgres
+package timeshift
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jsmit257/huautla/types"
+)
+
+// Shift is origin + interval 'n unit' + ..., for each of data's factors
+func Shift(origin time.Time, data types.Timestamp) (time.Time, error) {
+	result := origin.UTC()
+	for _, f := range data.Factor {
+		switch f.Interval {
+		case "hour":
+			result = result.Add(time.Duration(f.Delta) * time.Hour)
+		case "day":
+			result = result.AddDate(0, 0, f.Delta)
+		case "week":
+			result = result.AddDate(0, 0, 7*f.Delta)
+		case "month":
+			result = addMonths(result, f.Delta)
+		case "year":
+			result = addMonths(result, 12*f.Delta)
+		default:
+			return result, fmt.Errorf("invalid interval: '%s'", f.Interval)
+		}
+	}
+	return result, nil
+}
+
+// addMonths stops at the end of a short month, like postgres does, instead
+// of rolling over into the next one like time.AddDate
+func addMonths(t time.Time, n int) time.Time {
+	y, m, d := t.Date()
+	first := time.Date(y, m+time.Month(n), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	if last := first.AddDate(0, 1, -1).Day(); d > last {
+		d = last
+	}
+	return first.AddDate(0, 0, d-1)
+}
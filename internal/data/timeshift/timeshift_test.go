@@ -0,0 +1,66 @@
+package timeshift
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/huautla/types"
+)
+
+type factor = struct {
+	Delta    int    `json:"delta,omitempty"`
+	Interval string `json:"interval,omitempty"`
+}
+
+func Test_Shift(t *testing.T) {
+	t.Parallel()
+
+	origin := time.Date(2026, 1, 31, 12, 0, 0, 0, time.UTC)
+
+	tcs := map[string]struct {
+		factors []factor
+		result  time.Time
+		err     bool
+	}{
+		"nothing": {
+			result: origin,
+		},
+		"hours_and_days": {
+			factors: []factor{{Delta: 3, Interval: "hour"}, {Delta: -2, Interval: "day"}},
+			result:  time.Date(2026, 1, 29, 15, 0, 0, 0, time.UTC),
+		},
+		"week": {
+			factors: []factor{{Delta: 1, Interval: "week"}},
+			result:  time.Date(2026, 2, 7, 12, 0, 0, 0, time.UTC),
+		},
+		"short_month": {
+			factors: []factor{{Delta: 1, Interval: "month"}},
+			result:  time.Date(2026, 2, 28, 12, 0, 0, 0, time.UTC),
+		},
+		"back_a_year": {
+			factors: []factor{{Delta: -1, Interval: "year"}, {Delta: 1, Interval: "month"}},
+			result:  time.Date(2025, 2, 28, 12, 0, 0, 0, time.UTC),
+		},
+		"fortnight": {
+			factors: []factor{{Delta: 1, Interval: "fortnight"}},
+			err:     true,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := Shift(origin, types.Timestamp{Factor: tc.factors})
+			if tc.err {
+				require.NotNil(t, err)
+				return
+			}
+			require.Nil(t, err)
+			require.Equal(t, tc.result, result)
+		})
+	}
+}
@@ -0,0 +1,22 @@
+// Package txn lets a batch be all or nothing on databases that can do
+// transactions; huautla's postgres can't be handed one, so it can't
+package txn
+
+import "context"
+
+type (
+	// Beginner is a database that can make everything done with the ctx
+	// from Begin one transaction, until it's committed or rolled back.
+	// Nobody else sees any of it before it's committed, and anybody else
+	// who wants the database waits until it is
+	Beginner interface {
+		Begin(ctx context.Context) (context.Context, Tx, error)
+	}
+
+	// Tx is finished by whichever of these is called first; calling the
+	// other one after that is harmless
+	Tx interface {
+		Commit() error
+		Rollback() error
+	}
+)
@@ -0,0 +1,63 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// Bus hands events to everyone subscribed at the time they're published;
+// subscribers that fall too far behind miss events rather than hold up the
+// request that made them, and can catch up from the outbox
+type Bus struct {
+	mtx     sync.Mutex
+	subs    map[int]chan Event
+	next    int
+	dropped atomic.Uint64
+}
+
+func NewBus() *Bus {
+	return &Bus{subs: map[int]chan Event{}}
+}
+
+func (b *Bus) Publish(_ context.Context, e Event) error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	for _, c := range b.subs {
+		select {
+		case c <- e:
+		default:
+			b.dropped.Add(1)
+		}
+	}
+
+	return nil
+}
+
+// Subscribe returns a channel of every event published from now on, and a
+// function that closes it
+func (b *Bus) Subscribe(buffer int) (<-chan Event, func()) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	id := b.next
+	b.next++
+	c := make(chan Event, buffer)
+	b.subs[id] = c
+
+	var once sync.Once
+	return c, func() {
+		once.Do(func() {
+			b.mtx.Lock()
+			defer b.mtx.Unlock()
+			delete(b.subs, id)
+			close(c)
+		})
+	}
+}
+
+// Dropped is how many events subscribers have missed so far
+func (b *Bus) Dropped() uint64 {
+	return b.dropped.Load()
+}
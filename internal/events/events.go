@@ -0,0 +1,76 @@
+// Package events carries domain events, the record of every change made
+// through the api, to whoever wants to know about them
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/jsmit257/huautla/types"
+)
+
+type (
+	Event struct {
+		// Seq orders events; it's assigned by the outbox, so it's zero for
+		// events that never went through one
+		Seq uint64 `json:"seq,omitempty"`
+		ID  string `json:"id"`
+		// Type is `<entity>.<what happened>`, like `lifecycle.created` or
+		// `photo.removed`
+		Type     string          `json:"type"`
+		EntityID types.UUID      `json:"entity_id"`
+		Actor    string          `json:"actor,omitempty"`
+		Cid      types.CID       `json:"cid"`
+		Time     time.Time       `json:"time"`
+		Payload  json.RawMessage `json:"payload,omitempty"`
+	}
+
+	Sink interface {
+		Publish(ctx context.Context, e Event) error
+	}
+
+	// Publisher is the Sink handlers use; it stamps every event, records it
+	// in the outbox, if there is one, and then hands it to each of the sinks
+	Publisher struct {
+		outbox *Outbox
+		sinks  []Sink
+	}
+)
+
+func NewPublisher(outbox *Outbox, sinks ...Sink) *Publisher {
+	return &Publisher{outbox: outbox, sinks: sinks}
+}
+
+// Entity is the part of the type before the dot
+func (e Event) Entity() string {
+	entity, _, _ := strings.Cut(e.Type, ".")
+	return entity
+}
+
+func (p *Publisher) Publish(ctx context.Context, e Event) error {
+	if e.ID == "" {
+		e.ID = uuid.NewString()
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+
+	if p.outbox != nil {
+		var err error
+		if e, err = p.outbox.Append(ctx, e); err != nil {
+			return err
+		}
+	}
+
+	var errs []error
+	for _, s := range p.sinks {
+		errs = append(errs, s.Publish(ctx, e))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,264 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/store"
+)
+
+type failingSink struct{}
+
+func (failingSink) Publish(context.Context, Event) error {
+	return fmt.Errorf("some error")
+}
+
+func Test_Publisher(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	outbox, err := NewOutbox(ctx, store.NewMem())
+	require.Nil(t, err)
+	bus := NewBus()
+	c, unsubscribe := bus.Subscribe(10)
+	defer unsubscribe()
+
+	p := NewPublisher(outbox, bus)
+	require.Nil(t, p.Publish(ctx, Event{Type: "lifecycle.created", EntityID: "0", Cid: "cid"}))
+	require.Nil(t, p.Publish(ctx, Event{Type: "photo.removed", EntityID: "1", Cid: "cid"}))
+
+	first, second := <-c, <-c
+	require.Equal(t, uint64(1), first.Seq)
+	require.Equal(t, "lifecycle", first.Entity())
+	require.NotEmpty(t, first.ID)
+	require.False(t, first.Time.IsZero())
+	require.Equal(t, uint64(2), second.Seq)
+	require.Equal(t, "photo", second.Entity())
+
+	stored, err := outbox.Since(ctx, 0, 0)
+	require.Nil(t, err)
+	require.Equal(t, []Event{first, second}, stored)
+
+	require.ErrorContains(t, NewPublisher(nil, bus, failingSink{}).Publish(ctx, Event{}), "some error")
+	require.Equal(t, uint64(0), (<-c).Seq)
+}
+
+func Test_Outbox(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := store.NewMem()
+
+	o, err := NewOutbox(ctx, s)
+	require.Nil(t, err)
+	for i := 0; i < 12; i++ {
+		require.Nil(t, o.Publish(ctx, Event{Type: "note.added", EntityID: "n"}))
+	}
+	last, err := o.Last(ctx)
+	require.Nil(t, err)
+	require.Equal(t, uint64(12), last)
+
+	tcs := map[string]struct {
+		since uint64
+		limit int
+		seqs  []uint64
+	}{
+		"everything": {
+			seqs: []uint64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12},
+		},
+		"since": {
+			since: 9,
+			seqs:  []uint64{10, 11, 12},
+		},
+		"limit": {
+			since: 1,
+			limit: 2,
+			seqs:  []uint64{2, 3},
+		},
+		"caught_up": {
+			since: 12,
+			seqs:  []uint64{},
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			evs, err := o.Since(ctx, tc.since, tc.limit)
+			require.Nil(t, err)
+			seqs := []uint64{}
+			for _, e := range evs {
+				seqs = append(seqs, e.Seq)
+			}
+			require.Equal(t, tc.seqs, seqs)
+		})
+	}
+
+	t.Run("restart", func(t *testing.T) {
+		t.Parallel()
+
+		s := store.NewMem()
+		o, err := NewOutbox(ctx, s)
+		require.Nil(t, err)
+		for i := 0; i < 3; i++ {
+			require.Nil(t, o.Publish(ctx, Event{}))
+		}
+
+		// pruning everything still counts from the newest event
+		require.Nil(t, o.Prune(ctx, 5))
+		evs, err := o.Since(ctx, 0, 0)
+		require.Nil(t, err)
+		require.Empty(t, evs)
+		keys, err := s.Keys(ctx, outboxTable)
+		require.Nil(t, err)
+		require.Empty(t, keys)
+
+		o, err = NewOutbox(ctx, s)
+		require.Nil(t, err)
+		e, err := o.Append(ctx, Event{})
+		require.Nil(t, err)
+		require.Equal(t, uint64(4), e.Seq)
+	})
+
+	t.Run("shared", func(t *testing.T) {
+		t.Parallel()
+
+		// each outbox has its own store, like two processes would, and they
+		// still never hand out the same number
+		dir := t.TempDir()
+		seqs := make(chan uint64, 100)
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			s, err := store.NewFile(dir)
+			require.Nil(t, err)
+			o, err := NewOutbox(ctx, s)
+			require.Nil(t, err)
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 25; j++ {
+					e, err := o.Append(ctx, Event{})
+					require.Nil(t, err)
+					seqs <- e.Seq
+				}
+			}()
+		}
+		wg.Wait()
+		close(seqs)
+
+		seen := map[uint64]bool{}
+		for seq := range seqs {
+			require.False(t, seen[seq], "%d was handed out twice", seq)
+			seen[seq] = true
+		}
+		require.Len(t, seen, 100)
+		require.True(t, seen[1])
+		require.True(t, seen[100])
+	})
+
+	t.Run("before", func(t *testing.T) {
+		t.Parallel()
+
+		o, err := NewOutbox(ctx, store.NewMem())
+		require.Nil(t, err)
+
+		now := time.Now()
+		for _, age := range []time.Duration{72 * time.Hour, 48 * time.Hour, time.Hour} {
+			require.Nil(t, o.Publish(ctx, Event{Time: now.Add(-age)}))
+		}
+
+		for cutoff, want := range map[time.Duration]uint64{
+			96 * time.Hour: 0,
+			60 * time.Hour: 1,
+			24 * time.Hour: 2,
+			0:              3,
+		} {
+			seq, err := o.Before(ctx, now.Add(-cutoff))
+			require.Nil(t, err)
+			require.Equal(t, want, seq, "%s", cutoff)
+		}
+
+		// pruned events were old enough, whenever they were
+		require.Nil(t, o.Prune(ctx, 1))
+		seq, err := o.Before(ctx, now.Add(-60*time.Hour))
+		require.Nil(t, err)
+		require.Equal(t, uint64(1), seq)
+	})
+}
+
+func Test_Bus(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	b := NewBus()
+
+	fast, unsubscribeFast := b.Subscribe(2)
+	slow, unsubscribeSlow := b.Subscribe(1)
+
+	for _, typ := range []string{"a.b", "c.d"} {
+		require.Nil(t, b.Publish(ctx, Event{Type: typ}))
+	}
+
+	require.Equal(t, "a.b", (<-fast).Type)
+	require.Equal(t, "c.d", (<-fast).Type)
+	require.Equal(t, "a.b", (<-slow).Type)
+	require.Equal(t, uint64(1), b.Dropped())
+
+	unsubscribeSlow()
+	unsubscribeSlow()
+	_, ok := <-slow
+	require.False(t, ok)
+
+	require.Nil(t, b.Publish(ctx, Event{Type: "e.f"}))
+	require.Equal(t, "e.f", (<-fast).Type)
+
+	unsubscribeFast()
+	select {
+	case _, ok := <-fast:
+		require.False(t, ok)
+	case <-time.After(time.Second):
+		require.Fail(t, "channel wasn't closed")
+	}
+}
+
+func Test_FileSink(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+
+	for _, id := range []string{"0", "1"} {
+		// reopening appends rather than truncating
+		fs, err := NewFileSink(path)
+		require.Nil(t, err)
+		require.Nil(t, fs.Publish(context.Background(), Event{ID: id, Type: "vendor.created"}))
+		require.Nil(t, fs.Close())
+	}
+
+	f, err := os.Open(path)
+	require.Nil(t, err)
+	defer f.Close()
+
+	var ids []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Event
+		require.Nil(t, json.Unmarshal(scanner.Bytes(), &e))
+		ids = append(ids, e.ID)
+	}
+	require.Equal(t, []string{"0", "1"}, ids)
+
+	_, err = NewFileSink(filepath.Join(t.TempDir(), "missing", "events.jsonl"))
+	require.NotNil(t, err)
+}
@@ -0,0 +1,41 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+// FileSink appends each event to a file as a line of json
+type FileSink struct {
+	mtx sync.Mutex
+	f   *os.File
+}
+
+func NewFileSink(path string) (*FileSink, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{f: f}, nil
+}
+
+func (fs *FileSink) Publish(_ context.Context, e Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	fs.mtx.Lock()
+	defer fs.mtx.Unlock()
+
+	_, err = fs.f.Write(append(data, '\n'))
+	return err
+}
+
+func (fs *FileSink) Close() error {
+	fs.mtx.Lock()
+	defer fs.mtx.Unlock()
+	return fs.f.Close()
+}
@@ -0,0 +1,184 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/store"
+)
+
+// Outbox is the durable record of events, numbered in the order they were
+// published; consumers remember the last Seq they handled and ask for
+// everything Since then, so nothing is missed across restarts on either side.
+// numbers come from a counter in the store, under its lock, so every process
+// sharing it counts from the same place. It isn't in the database's
+// transaction, so an event is appended after its change is made, and a
+// crash in between loses the event
+type Outbox struct {
+	store store.Store
+}
+
+const (
+	outboxTable = "outbox"
+
+	// the newest event's Seq is kept under last, and the newest one that's
+	// been pruned under pruned, so nobody has to list the outbox to find
+	// where it starts or ends
+	outboxCounters = "outbox_counters"
+	lastCounter    = "last"
+	prunedCounter  = "pruned"
+)
+
+// NewOutbox makes sure the outbox is readable before anything is published
+// to it
+func NewOutbox(ctx context.Context, s store.Store) (*Outbox, error) {
+	result := &Outbox{store: s}
+	if _, err := result.Last(ctx); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// outboxKey is zero-padded, so keys sort in the same order as numbers
+func outboxKey(seq uint64) string {
+	return fmt.Sprintf("%020d", seq)
+}
+
+func (o *Outbox) counter(ctx context.Context, name string) (uint64, error) {
+	var result uint64
+	if err := o.store.Get(ctx, outboxCounters, name, &result); errors.Is(err, store.ErrNotFound) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	return result, nil
+}
+
+// Append numbers the event after whatever is newest, whoever published it,
+// and stores it
+func (o *Outbox) Append(ctx context.Context, e Event) (Event, error) {
+	unlock, err := o.store.Lock(ctx, outboxTable)
+	if err != nil {
+		return e, err
+	}
+	defer unlock()
+
+	if e.Seq, err = o.Last(ctx); err != nil {
+		return e, err
+	}
+	e.Seq++
+
+	// the event goes first, so a crash in between leaves a number that gets
+	// handed out again, not a gap that's never filled
+	if err = o.store.Put(ctx, outboxTable, outboxKey(e.Seq), e); err != nil {
+		return e, err
+	}
+	return e, o.store.Put(ctx, outboxCounters, lastCounter, e.Seq)
+}
+
+func (o *Outbox) Publish(ctx context.Context, e Event) error {
+	_, err := o.Append(ctx, e)
+	return err
+}
+
+// Since returns up to limit events after seq, oldest first; a limit of zero
+// means all of them. Events are looked up by number, so it only reads the
+// ones it returns
+func (o *Outbox) Since(ctx context.Context, seq uint64, limit int) ([]Event, error) {
+	last, err := o.Last(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pruned, err := o.counter(ctx, prunedCounter)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []Event{}
+	for next := max(seq, pruned) + 1; next <= last; next++ {
+		if limit > 0 && len(result) == limit {
+			break
+		}
+
+		var e Event
+		if err := o.store.Get(ctx, outboxTable, outboxKey(next), &e); errors.Is(err, store.ErrNotFound) {
+			// pruned since we looked
+		} else if err != nil {
+			return nil, err
+		} else {
+			result = append(result, e)
+		}
+	}
+
+	return result, nil
+}
+
+// Last is the Seq of the newest event
+func (o *Outbox) Last(ctx context.Context) (uint64, error) {
+	return o.counter(ctx, lastCounter)
+}
+
+// Before is the Seq of the newest event published before t, or zero if
+// there aren't any; events are published in order, so it's a binary search
+func (o *Outbox) Before(ctx context.Context, t time.Time) (uint64, error) {
+	lo, err := o.counter(ctx, prunedCounter)
+	if err != nil {
+		return 0, err
+	}
+	hi, err := o.Last(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// everything up to lo is older than t, as far as anyone can tell now,
+	// and nothing after hi exists
+	for lo < hi {
+		mid := lo + (hi-lo+1)/2
+
+		var e Event
+		if err := o.store.Get(ctx, outboxTable, outboxKey(mid), &e); errors.Is(err, store.ErrNotFound) {
+			// pruned since we looked, so it was old enough
+			lo = mid
+		} else if err != nil {
+			return 0, err
+		} else if e.Time.Before(t) {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return lo, nil
+}
+
+// Prune forgets every event up to and including seq, for when everybody
+// downstream has them
+func (o *Outbox) Prune(ctx context.Context, seq uint64) error {
+	unlock, err := o.store.Lock(ctx, outboxTable)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	pruned, err := o.counter(ctx, prunedCounter)
+	if err != nil {
+		return err
+	}
+	last, err := o.Last(ctx)
+	if err != nil {
+		return err
+	}
+
+	// whatever was deleted before a failure is just not found next time
+	if seq = min(seq, last); seq <= pruned {
+		return nil
+	}
+	for next := pruned + 1; next <= seq; next++ {
+		if err := o.store.Delete(ctx, outboxTable, outboxKey(next)); err != nil && !errors.Is(err, store.ErrNotFound) {
+			return err
+		}
+	}
+
+	return o.store.Put(ctx, outboxCounters, prunedCounter, seq)
+}
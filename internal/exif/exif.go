@@ -0,0 +1,376 @@
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+type (
+	// Metadata is the handful of exif attributes anybody has asked for so far
+	Metadata struct {
+		Captured     *time.Time `json:"captured,omitempty"`
+		Make         string     `json:"make,omitempty"`
+		Model        string     `json:"model,omitempty"`
+		Lens         string     `json:"lens,omitempty"`
+		ExposureTime string     `json:"exposure_time,omitempty"`
+		FNumber      float64    `json:"f_number,omitempty"`
+		ISO          int        `json:"iso,omitempty"`
+		FocalLength  float64    `json:"focal_length,omitempty"`
+		GPS          *GPS       `json:"gps,omitempty"`
+	}
+
+	GPS struct {
+		Latitude  float64  `json:"latitude"`
+		Longitude float64  `json:"longitude"`
+		Altitude  *float64 `json:"altitude,omitempty"`
+	}
+
+	tiff struct {
+		b     []byte
+		order binary.ByteOrder
+		// where b starts in the original image
+		base int
+	}
+
+	entry struct {
+		tag, typ uint16
+		count    uint32
+		// offset of the entry itself, relative to the start of the tiff header
+		at uint32
+	}
+
+	ifd map[uint16]entry
+)
+
+const (
+	tagMake           = 0x010f
+	tagModel          = 0x0110
+	tagExifIFD        = 0x8769
+	tagGPSIFD         = 0x8825
+	tagExposureTime   = 0x829a
+	tagFNumber        = 0x829d
+	tagISO            = 0x8827
+	tagDateOriginal   = 0x9003
+	tagOffsetOriginal = 0x9011
+	tagFocalLength    = 0x920a
+	tagLensModel      = 0xa434
+	tagGPSLatRef      = 0x0001
+	tagGPSLat         = 0x0002
+	tagGPSLonRef      = 0x0003
+	tagGPSLon         = 0x0004
+	tagGPSAltRef      = 0x0005
+	tagGPSAlt         = 0x0006
+
+	exifTimeFormat = "2006:01:02 15:04:05"
+)
+
+var (
+	ErrNoExif = errors.New("no exif data found")
+
+	// bytes per component for each tiff type, indexed by type
+	typeSize = [...]uint32{0, 1, 1, 2, 4, 8, 1, 1, 2, 4, 8, 4, 8}
+)
+
+// Parse extracts Metadata from a jpeg or tiff image; cameras don't agree on
+// whether to record a timezone, so a capture time without an offset is
+// interpreted in loc
+func Parse(data []byte, loc *time.Location) (*Metadata, error) {
+	t, err := find(data)
+	if err != nil {
+		return nil, err
+	}
+
+	ifd0, err := t.ifd(t.u32(4))
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Metadata{
+		Make:  t.ascii(ifd0[tagMake]),
+		Model: t.ascii(ifd0[tagModel]),
+	}
+
+	if e, ok := ifd0[tagExifIFD]; ok {
+		if sub, err := t.ifd(t.u32(e.at + 8)); err != nil {
+			return nil, fmt.Errorf("exif ifd: %w", err)
+		} else {
+			t.applyExif(sub, result, loc)
+		}
+	}
+
+	if e, ok := ifd0[tagGPSIFD]; ok {
+		if sub, err := t.ifd(t.u32(e.at + 8)); err != nil {
+			return nil, fmt.Errorf("gps ifd: %w", err)
+		} else {
+			result.GPS = t.gps(sub)
+		}
+	}
+
+	return result, nil
+}
+
+// StripGPS returns a copy of data with every gps attribute zeroed out and
+// the gps directory emptied; images without exif or gps are returned as-is
+func StripGPS(data []byte) ([]byte, error) {
+	t, err := find(data)
+	if errors.Is(err, ErrNoExif) {
+		return data, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	ifd0, err := t.ifd(t.u32(4))
+	if err != nil {
+		return nil, err
+	}
+
+	e, ok := ifd0[tagGPSIFD]
+	if !ok {
+		return data, nil
+	}
+
+	off := t.u32(e.at + 8)
+	if _, err := t.ifd(off); err != nil {
+		return nil, err
+	}
+
+	// t.b is a window into result, so zeroing t.b zeroes result
+	result := append([]byte{}, data...)
+	t.b = result[t.base : t.base+len(t.b)]
+
+	// ifd() leaves out duplicates and types it doesn't know, but their
+	// values are still in the file, so every entry in the directory is
+	// zeroed, not just the ones ifd() kept. A type nobody knows the size of
+	// is taken to be a byte per component, so nothing past it gets zeroed
+	n := uint32(t.u16(off))
+	for i := uint32(0); i < n; i++ {
+		e := t.entry(off + 2 + 12*i)
+		size := uint64(e.count)
+		if e.known() {
+			size *= uint64(typeSize[e.typ])
+		}
+		if at := uint64(t.u32(e.at + 8)); size > 4 && at+size <= uint64(len(t.b)) {
+			clear(t.b[at : at+size])
+		}
+	}
+	clear(t.b[off : off+2+12*n])
+
+	return result, nil
+}
+
+// find locates the tiff header either at the start of data, or inside the
+// APP1 segment of a jpeg
+func find(data []byte) (*tiff, error) {
+	if len(data) >= 4 && (bytes.HasPrefix(data, []byte("II*\x00")) || bytes.HasPrefix(data, []byte("MM\x00*"))) {
+		return newTiff(data, 0)
+	} else if len(data) < 4 || data[0] != 0xff || data[1] != 0xd8 {
+		return nil, ErrNoExif
+	}
+
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xff {
+			return nil, fmt.Errorf("malformed jpeg segment at %d", i)
+		}
+		marker := data[i+1]
+		if marker == 0xd9 || marker == 0xda { // EOI, SOS
+			break
+		}
+		size := int(binary.BigEndian.Uint16(data[i+2:]))
+		if size < 2 || i+2+size > len(data) {
+			return nil, fmt.Errorf("malformed jpeg segment length at %d", i)
+		}
+		if seg := data[i+4 : i+2+size]; marker == 0xe1 && bytes.HasPrefix(seg, []byte("Exif\x00\x00")) {
+			return newTiff(seg[6:], i+10)
+		}
+		i += 2 + size
+	}
+
+	return nil, ErrNoExif
+}
+
+func newTiff(b []byte, base int) (*tiff, error) {
+	if len(b) < 8 {
+		return nil, fmt.Errorf("truncated tiff header")
+	} else if b[0] == 'I' && b[1] == 'I' {
+		return &tiff{b: b, order: binary.LittleEndian, base: base}, nil
+	} else if b[0] == 'M' && b[1] == 'M' {
+		return &tiff{b: b, order: binary.BigEndian, base: base}, nil
+	}
+	return nil, fmt.Errorf("unknown tiff byte order")
+}
+
+func (t *tiff) u16(at uint32) uint16 {
+	if uint64(at)+2 > uint64(len(t.b)) {
+		return 0
+	}
+	return t.order.Uint16(t.b[at:])
+}
+
+func (t *tiff) u32(at uint32) uint32 {
+	if uint64(at)+4 > uint64(len(t.b)) {
+		return 0
+	}
+	return t.order.Uint32(t.b[at:])
+}
+
+func (t *tiff) ifd(at uint32) (ifd, error) {
+	n := uint32(t.u16(at))
+	if at == 0 || uint64(at)+2+12*uint64(n) > uint64(len(t.b)) {
+		return nil, fmt.Errorf("ifd offset out of range: %d", at)
+	}
+
+	result := make(ifd, n)
+	for i := uint32(0); i < n; i++ {
+		if e := t.entry(at + 2 + 12*i); e.known() {
+			result[e.tag] = e
+		}
+	}
+
+	return result, nil
+}
+
+func (t *tiff) entry(at uint32) entry {
+	return entry{
+		tag:   t.u16(at),
+		typ:   t.u16(at + 2),
+		count: t.u32(at + 4),
+		at:    at,
+	}
+}
+
+// known is whether e's type is one we know the size of
+func (e entry) known() bool {
+	return int(e.typ) < len(typeSize) && typeSize[e.typ] != 0
+}
+
+func (e entry) size() uint32 {
+	return typeSize[e.typ] * e.count
+}
+
+// value returns the raw bytes for an entry, whether they're inline or not
+func (t *tiff) value(e entry) []byte {
+	size := e.size()
+	if e.typ == 0 || size == 0 {
+		return nil
+	} else if size <= 4 {
+		return t.b[e.at+8 : e.at+8+size]
+	} else if off := t.u32(e.at + 8); uint64(off)+uint64(size) <= uint64(len(t.b)) {
+		return t.b[off : off+size]
+	}
+	return nil
+}
+
+func (t *tiff) ascii(e entry) string {
+	return strings.TrimSpace(strings.TrimRight(string(t.value(e)), "\x00"))
+}
+
+func (t *tiff) uint(e entry) int {
+	switch b := t.value(e); {
+	case len(b) >= 2 && e.typ == 3:
+		return int(t.order.Uint16(b))
+	case len(b) >= 4 && e.typ == 4:
+		return int(t.order.Uint32(b))
+	}
+	return 0
+}
+
+func (t *tiff) rationals(e entry) [][2]uint32 {
+	if e.typ != 5 && e.typ != 10 {
+		return nil
+	}
+
+	b := t.value(e)
+	result := make([][2]uint32, 0, len(b)/8)
+	for i := 0; i+8 <= len(b); i += 8 {
+		result = append(result, [2]uint32{t.order.Uint32(b[i:]), t.order.Uint32(b[i+4:])})
+	}
+
+	return result
+}
+
+func (t *tiff) float(e entry) float64 {
+	if r := t.rationals(e); len(r) == 0 || r[0][1] == 0 {
+		return 0
+	} else {
+		return float64(r[0][0]) / float64(r[0][1])
+	}
+}
+
+func (t *tiff) applyExif(sub ifd, md *Metadata, loc *time.Location) {
+	md.FNumber = round(t.float(sub[tagFNumber]), 2)
+	md.FocalLength = round(t.float(sub[tagFocalLength]), 2)
+	md.ISO = t.uint(sub[tagISO])
+	md.Lens = t.ascii(sub[tagLensModel])
+
+	if r := t.rationals(sub[tagExposureTime]); len(r) > 0 && r[0][0] != 0 && r[0][1] != 0 {
+		if num, den := r[0][0], r[0][1]; num >= den {
+			md.ExposureTime = fmt.Sprintf("%gs", round(float64(num)/float64(den), 2))
+		} else {
+			md.ExposureTime = fmt.Sprintf("1/%gs", round(float64(den)/float64(num), 0))
+		}
+	}
+
+	if s := t.ascii(sub[tagDateOriginal]); s == "" {
+	} else if tz := t.ascii(sub[tagOffsetOriginal]); tz != "" {
+		if ts, err := time.Parse(exifTimeFormat+"-07:00", s+tz); err == nil {
+			md.Captured = &ts
+		}
+	} else if ts, err := time.ParseInLocation(exifTimeFormat, s, loc); err == nil {
+		md.Captured = &ts
+	}
+}
+
+func (t *tiff) gps(sub ifd) *GPS {
+	lat, lon := t.degrees(sub[tagGPSLat]), t.degrees(sub[tagGPSLon])
+	if lat == nil || lon == nil {
+		return nil
+	}
+
+	result := &GPS{Latitude: *lat, Longitude: *lon}
+	if t.ascii(sub[tagGPSLatRef]) == "S" {
+		result.Latitude = -result.Latitude
+	}
+	if t.ascii(sub[tagGPSLonRef]) == "W" {
+		result.Longitude = -result.Longitude
+	}
+
+	if e, ok := sub[tagGPSAlt]; ok {
+		alt := t.float(e)
+		if ref := t.value(sub[tagGPSAltRef]); len(ref) > 0 && ref[0] == 1 {
+			alt = -alt
+		}
+		result.Altitude = &alt
+	}
+
+	return result
+}
+
+// degrees converts the degrees/minutes/seconds triple gps uses into decimal
+// degrees
+func (t *tiff) degrees(e entry) *float64 {
+	r := t.rationals(e)
+	if len(r) != 3 {
+		return nil
+	}
+
+	var result float64
+	for i, div := range []float64{1, 60, 3600} {
+		if r[i][1] == 0 {
+			return nil
+		}
+		result += float64(r[i][0]) / float64(r[i][1]) / div
+	}
+	result = round(result, 6)
+
+	return &result
+}
+
+func round(f float64, places int) float64 {
+	p := math.Pow10(places)
+	return math.Round(f*p) / p
+}
@@ -0,0 +1,228 @@
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type (
+	field struct {
+		tag, typ uint16
+		count    uint32
+		data     []byte
+	}
+
+	builder struct {
+		buf []byte
+	}
+)
+
+var le = binary.LittleEndian
+
+func ascii(tag uint16, s string) field {
+	return field{tag: tag, typ: 2, count: uint32(len(s) + 1), data: append([]byte(s), 0)}
+}
+
+func short(tag uint16, v uint16) field {
+	return field{tag: tag, typ: 3, count: 1, data: le.AppendUint16(nil, v)}
+}
+
+func rational(tag uint16, v ...uint32) field {
+	var data []byte
+	for _, n := range v {
+		data = le.AppendUint32(data, n)
+	}
+	return field{tag: tag, typ: 5, count: uint32(len(v) / 2), data: data}
+}
+
+func (b *builder) ifd(fields ...field) uint32 {
+	at := uint32(len(b.buf))
+	data := at + 2 + 12*uint32(len(fields)) + 4
+
+	var extra []byte
+	b.buf = le.AppendUint16(b.buf, uint16(len(fields)))
+	for _, f := range fields {
+		b.buf = le.AppendUint16(b.buf, f.tag)
+		b.buf = le.AppendUint16(b.buf, f.typ)
+		b.buf = le.AppendUint32(b.buf, f.count)
+		if len(f.data) > 4 {
+			b.buf = le.AppendUint32(b.buf, data+uint32(len(extra)))
+			extra = append(extra, f.data...)
+		} else {
+			b.buf = append(b.buf, append(f.data, make([]byte, 4-len(f.data))...)...)
+		}
+	}
+	b.buf = le.AppendUint32(b.buf, 0)
+	b.buf = append(b.buf, extra...)
+
+	return at
+}
+
+// sample builds a jpeg with just enough structure to carry an exif segment;
+// extra goes at the front of the gps directory, if there is one
+func sample(gps bool, extra ...field) []byte {
+	b := &builder{buf: []byte("II*\x00\x00\x00\x00\x00")}
+
+	sub := b.ifd(
+		rational(tagExposureTime, 1, 125),
+		rational(tagFNumber, 28, 10),
+		short(tagISO, 400),
+		ascii(tagDateOriginal, "2024:05:06 07:08:09"),
+		ascii(tagOffsetOriginal, "-05:00"),
+		rational(tagFocalLength, 50, 1),
+	)
+
+	ifd0 := []field{
+		ascii(tagMake, "Fungicam"),
+		ascii(tagModel, "Spore 9"),
+		{tag: tagExifIFD, typ: 4, count: 1, data: le.AppendUint32(nil, sub)},
+	}
+
+	if gps {
+		g := b.ifd(append(extra,
+			ascii(tagGPSLatRef, "N"),
+			rational(tagGPSLat, 45, 1, 30, 1, 0, 1),
+			ascii(tagGPSLonRef, "W"),
+			rational(tagGPSLon, 122, 1, 15, 1, 36, 1),
+			rational(tagGPSAlt, 100, 1),
+		)...)
+		ifd0 = append(ifd0, field{tag: tagGPSIFD, typ: 4, count: 1, data: le.AppendUint32(nil, g)})
+	}
+
+	le.PutUint32(b.buf[4:], b.ifd(ifd0...))
+
+	app1 := append([]byte("Exif\x00\x00"), b.buf...)
+	result := []byte{0xff, 0xd8, 0xff, 0xe1}
+	result = binary.BigEndian.AppendUint16(result, uint16(len(app1)+2))
+	result = append(result, app1...)
+
+	return append(result, 0xff, 0xd9)
+}
+
+func Test_Parse(t *testing.T) {
+	t.Parallel()
+
+	captured := time.Date(2024, 5, 6, 12, 8, 9, 0, time.UTC)
+	alt := 100.0
+
+	tcs := map[string]struct {
+		data   []byte
+		result *Metadata
+		err    error
+	}{
+		"happy_path": {
+			data: sample(true),
+			result: &Metadata{
+				Captured:     &captured,
+				Make:         "Fungicam",
+				Model:        "Spore 9",
+				ExposureTime: "1/125s",
+				FNumber:      2.8,
+				ISO:          400,
+				FocalLength:  50,
+				GPS: &GPS{
+					Latitude:  45.5,
+					Longitude: -122.26,
+					Altitude:  &alt,
+				},
+			},
+		},
+		"no_gps": {
+			data: sample(false),
+			result: &Metadata{
+				Captured:     &captured,
+				Make:         "Fungicam",
+				Model:        "Spore 9",
+				ExposureTime: "1/125s",
+				FNumber:      2.8,
+				ISO:          400,
+				FocalLength:  50,
+			},
+		},
+		"png": {
+			data: []byte{0x89, 'P', 'N', 'G', 0, 0, 0, 0},
+			err:  ErrNoExif,
+		},
+		"jpeg_without_exif": {
+			data: []byte{0xff, 0xd8, 0xff, 0xe0, 0x00, 0x04, 'J', 'F', 0xff, 0xd9},
+			err:  ErrNoExif,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			md, err := Parse(tc.data, time.UTC)
+			require.ErrorIs(t, err, tc.err)
+			if tc.result != nil {
+				require.True(t, tc.result.Captured.Equal(*md.Captured))
+				tc.result.Captured = md.Captured
+			}
+			require.Equal(t, tc.result, md)
+		})
+	}
+}
+
+func Test_StripGPS(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		data    []byte
+		changed bool
+		// none of these can be left anywhere in the result
+		leaks [][]byte
+	}{
+		"happy_path": {
+			data:    sample(true),
+			changed: true,
+		},
+		"skipped_entries": {
+			data: sample(true,
+				// the later tagGPSLon wins, so this one is a duplicate
+				rational(tagGPSLon, 0xdeadbeef, 1, 0xfeedface, 1, 0xcafebabe, 1),
+				field{tag: 0x001b, typ: 42, count: 16, data: []byte("an unknown type!")},
+			),
+			changed: true,
+			leaks: [][]byte{
+				le.AppendUint32(nil, 0xdeadbeef),
+				le.AppendUint32(nil, 0xcafebabe),
+				[]byte("an unknown type!"),
+			},
+		},
+		"no_gps": {
+			data: sample(false),
+		},
+		"no_exif": {
+			data: []byte{0x89, 'P', 'N', 'G', 0, 0, 0, 0},
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			orig := append([]byte{}, tc.data...)
+
+			stripped, err := StripGPS(tc.data)
+			require.Nil(t, err)
+			require.Equal(t, orig, tc.data, "input should never be modified")
+			require.Equal(t, tc.changed, string(orig) != string(stripped))
+			for _, leak := range tc.leaks {
+				require.True(t, bytes.Contains(orig, leak))
+				require.False(t, bytes.Contains(stripped, leak), "%q", leak)
+			}
+
+			if md, err := Parse(stripped, time.UTC); err == nil {
+				require.Nil(t, md.GPS)
+				require.Equal(t, "Fungicam", md.Make)
+			}
+		})
+	}
+}
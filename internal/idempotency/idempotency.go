@@ -0,0 +1,282 @@
+// Package idempotency lets clients retry a POST safely: the first response
+// for an Idempotency-Key is kept for a while, and repeats get it back instead
+// of doing the work again
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/store"
+	"github.com/jsmit257/centerforfunguscontrol/shared/metrics"
+)
+
+type (
+	Keeper struct {
+		store store.Store
+		ttl   time.Duration
+		// the biggest body that's buffered to be fingerprinted
+		maxBody int64
+		now     func() time.Time
+	}
+
+	record struct {
+		// the first request for the key hasn't finished yet
+		Pending     bool      `json:"pending,omitempty"`
+		Method      string    `json:"method"`
+		Path        string    `json:"path"`
+		Fingerprint string    `json:"fingerprint"`
+		Status      int       `json:"status"`
+		ContentType string    `json:"content_type,omitempty"`
+		Body        []byte    `json:"body,omitempty"`
+		Expires     time.Time `json:"expires"`
+	}
+
+	// recorder keeps a copy of everything written to the client
+	recorder struct {
+		http.ResponseWriter
+		status int
+		body   bytes.Buffer
+	}
+)
+
+const (
+	Header         = "Idempotency-Key"
+	ReplayedHeader = "Idempotent-Replayed"
+
+	table = "idempotency"
+
+	// how long a key stays claimed by a request that never finished, like
+	// one whose server crashed, before a retry gets another go
+	claimTTL = 10 * time.Minute
+)
+
+var errTooBig = errors.New("request body is too big to be made idempotent")
+
+func New(s store.Store, ttl time.Duration, maxBody int64) *Keeper {
+	return &Keeper{store: s, ttl: ttl, maxBody: maxBody, now: time.Now}
+}
+
+// Middleware only looks at POSTs with an Idempotency-Key; everything else
+// goes straight through
+func (k *Keeper) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(Header)
+		if r.Method != http.MethodPost || key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := r.Context()
+		l := metrics.GetContextLog(ctx).WithField("idempotency_key", key)
+		id := storeKey(metrics.GetContextActor(ctx), r.URL.Path, key)
+
+		fp, err := fingerprint(r, k.maxBody)
+		if errors.Is(err, errTooBig) {
+			reply(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("%s; the limit is %d bytes, or send it without an %s", err, k.maxBody, Header))
+			return
+		} else if err != nil {
+			reply(w, http.StatusBadRequest, "couldn't read request body")
+			return
+		}
+
+		rec, err := k.claim(ctx, id, fp)
+		if err != nil {
+			l.WithError(err).Error("failed to claim idempotency key")
+			reply(w, http.StatusInternalServerError, "couldn't check the idempotency key")
+			return
+		} else if rec.Pending {
+			reply(w, http.StatusConflict, "a request with this idempotency key is still in progress")
+			return
+		} else if rec.Status != 0 && rec.Fingerprint != fp {
+			reply(w, http.StatusUnprocessableEntity, "idempotency key was already used for a different request")
+			return
+		} else if rec.Status != 0 {
+			l.Info("replaying response")
+			if rec.ContentType != "" {
+				w.Header().Set("Content-Type", rec.ContentType)
+			}
+			w.Header().Set(ReplayedHeader, "true")
+			w.WriteHeader(rec.Status)
+			_, _ = w.Write(rec.Body)
+			return
+		}
+
+		// whatever doesn't get kept gives the key back, so the retry gets
+		// another go
+		kept := false
+		defer func() {
+			if kept {
+			} else if err := k.store.Delete(ctx, table, id); err != nil && !errors.Is(err, store.ErrNotFound) {
+				l.WithError(err).Error("failed to release idempotency key")
+			}
+		}()
+
+		rw := &recorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rw, r)
+
+		// server errors aren't the client's fault
+		if rw.status >= http.StatusInternalServerError {
+			return
+		}
+
+		if err := k.store.Put(ctx, table, id, record{
+			Method:      r.Method,
+			Path:        r.URL.Path,
+			Fingerprint: fp,
+			Status:      rw.status,
+			ContentType: rw.Header().Get("Content-Type"),
+			Body:        rw.body.Bytes(),
+			Expires:     k.now().Add(k.ttl),
+		}); err != nil {
+			l.WithError(err).Error("failed to store idempotency record")
+		} else {
+			kept = true
+		}
+	})
+}
+
+// claim returns the record to replay for id, if there is one, or claims id
+// for this request if there isn't; it holds the store's lock while it does,
+// since the http and kafka ingresses can share a store and get the same key
+// at the same time
+func (k *Keeper) claim(ctx context.Context, id, fp string) (record, error) {
+	unlock, err := k.store.Lock(ctx, table)
+	if err != nil {
+		return record{}, err
+	}
+	defer unlock()
+
+	var rec record
+	if err := k.store.Get(ctx, table, id, &rec); err == nil && k.now().Before(rec.Expires) {
+		return rec, nil
+	} else if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return rec, err
+	}
+
+	return record{}, k.store.Put(ctx, table, id, record{
+		Pending:     true,
+		Fingerprint: fp,
+		Expires:     k.now().Add(claimTTL),
+	})
+}
+
+// Prune forgets everything that's expired
+func (k *Keeper) Prune(ctx context.Context) error {
+	keys, err := k.store.Keys(ctx, table)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		var rec record
+		if err := k.store.Get(ctx, table, key, &rec); errors.Is(err, store.ErrNotFound) {
+		} else if err != nil {
+			return err
+		} else if k.now().After(rec.Expires) {
+			if err = k.store.Delete(ctx, table, key); err != nil && !errors.Is(err, store.ErrNotFound) {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Run prunes every so often until ctx is done
+func (k *Keeper) Run(ctx context.Context, every time.Duration, log *logrus.Entry) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if err := k.Prune(ctx); err != nil {
+				log.WithError(err).Error("failed to prune idempotency records")
+			}
+		}
+	}
+}
+
+// storeKey scopes keys to who sent them, as far as authentication can
+// tell, and the route, so the same key can't replay one client's response to
+// another, or one route's on another
+func storeKey(actor, path, key string) string {
+	sum := sha256.Sum256([]byte(actor + "\x00" + path + "\x00" + key))
+	return hex.EncodeToString(sum[:])
+}
+
+// fingerprint identifies what was asked for; multipart bodies are hashed a
+// part at a time, since the boundary is different every time a browser sends
+// the same form. r.Body is put back so the handler can still read it, which
+// means it's all in memory, so it can't be more than max
+func fingerprint(r *http.Request, max int64) (string, error) {
+	if r.ContentLength > max {
+		return "", errTooBig
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, max+1))
+	if err != nil {
+		return "", err
+	} else if int64(len(body)) > max {
+		return "", errTooBig
+	}
+	_ = r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s?%s\n", r.Method, r.URL.Path, r.URL.RawQuery)
+
+	mt, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if !strings.HasPrefix(mt, "multipart/") || params["boundary"] == "" {
+		h.Write(body)
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+
+	mr := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%q %q %q\n", p.FormName(), p.FileName(), p.Header.Get("Content-Type"))
+		if _, err = io.Copy(h, p); err != nil {
+			return "", err
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func reply(w http.ResponseWriter, sc int, msg string) {
+	data, _ := json.Marshal(msg)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(sc)
+	_, _ = w.Write(data)
+}
+
+func (rw *recorder) WriteHeader(sc int) {
+	rw.status = sc
+	rw.ResponseWriter.WriteHeader(sc)
+}
+
+func (rw *recorder) Write(data []byte) (int, error) {
+	rw.body.Write(data)
+	return rw.ResponseWriter.Write(data)
+}
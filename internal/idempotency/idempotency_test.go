@@ -0,0 +1,283 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/store"
+	"github.com/jsmit257/centerforfunguscontrol/shared/metrics"
+)
+
+// counter answers every request with how many it's seen
+type counter struct {
+	calls  atomic.Int64
+	status int
+}
+
+func (c *counter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n := c.calls.Add(1)
+	body, _ := io.ReadAll(r.Body)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(c.status)
+	_, _ = fmt.Fprintf(w, `{"call":%d,"body":%q}`, n, body)
+}
+
+func send(h http.Handler, method, path, key, contentType string, body []byte) *httptest.ResponseRecorder {
+	return sendAs(h, "us-authn:0", method, path, key, contentType, body)
+}
+
+func sendAs(h http.Handler, actor, method, path, key, contentType string, body []byte) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(method, path, bytes.NewReader(body))
+	r = r.WithContext(context.WithValue(r.Context(), metrics.Actor, actor))
+	if key != "" {
+		r.Header.Set(Header, key)
+	}
+	if contentType != "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func form(t *testing.T, content string) (string, []byte) {
+	b := &bytes.Buffer{}
+	mw := multipart.NewWriter(b)
+	fw, err := mw.CreateFormFile("file", "photo.jpg")
+	require.Nil(t, err)
+	_, err = fw.Write([]byte(content))
+	require.Nil(t, err)
+	require.Nil(t, mw.Close())
+	return mw.FormDataContentType(), b.Bytes()
+}
+
+func Test_Middleware(t *testing.T) {
+	t.Parallel()
+
+	type req struct {
+		actor, method, path, key, body string
+		sc                             int
+		replayed                       bool
+		call                           int
+	}
+
+	tcs := map[string]struct {
+		status int
+		reqs   []req
+	}{
+		"replayed": {
+			status: http.StatusCreated,
+			reqs: []req{
+				{method: http.MethodPost, path: "/notes/0", key: "k", body: "a", sc: http.StatusCreated, call: 1},
+				{method: http.MethodPost, path: "/notes/0", key: "k", body: "a", sc: http.StatusCreated, call: 1, replayed: true},
+			},
+		},
+		"different_body": {
+			status: http.StatusCreated,
+			reqs: []req{
+				{method: http.MethodPost, path: "/notes/0", key: "k", body: "a", sc: http.StatusCreated, call: 1},
+				{method: http.MethodPost, path: "/notes/0", key: "k", body: "b", sc: http.StatusUnprocessableEntity},
+			},
+		},
+		"different_key": {
+			status: http.StatusCreated,
+			reqs: []req{
+				{method: http.MethodPost, path: "/notes/0", key: "k", body: "a", sc: http.StatusCreated, call: 1},
+				{method: http.MethodPost, path: "/notes/0", key: "j", body: "a", sc: http.StatusCreated, call: 2},
+			},
+		},
+		"different_route": {
+			status: http.StatusCreated,
+			reqs: []req{
+				{method: http.MethodPost, path: "/notes/0", key: "k", body: "a", sc: http.StatusCreated, call: 1},
+				{method: http.MethodPost, path: "/notes/1", key: "k", body: "a", sc: http.StatusCreated, call: 2},
+			},
+		},
+		"different_actor": {
+			status: http.StatusCreated,
+			reqs: []req{
+				{actor: "us-authn:0", method: http.MethodPost, path: "/notes/0", key: "k", body: "a", sc: http.StatusCreated, call: 1},
+				{actor: "us-authn:1", method: http.MethodPost, path: "/notes/0", key: "k", body: "a", sc: http.StatusCreated, call: 2},
+			},
+		},
+		"too_big": {
+			status: http.StatusCreated,
+			reqs: []req{
+				{method: http.MethodPost, path: "/notes/0", key: "k", body: strings.Repeat("a", 65), sc: http.StatusRequestEntityTooLarge},
+				{method: http.MethodPost, path: "/notes/0", body: strings.Repeat("a", 65), sc: http.StatusCreated, call: 1},
+			},
+		},
+		"no_key": {
+			status: http.StatusCreated,
+			reqs: []req{
+				{method: http.MethodPost, path: "/notes/0", body: "a", sc: http.StatusCreated, call: 1},
+				{method: http.MethodPost, path: "/notes/0", body: "a", sc: http.StatusCreated, call: 2},
+			},
+		},
+		"not_a_post": {
+			status: http.StatusOK,
+			reqs: []req{
+				{method: http.MethodPatch, path: "/notes/0", key: "k", body: "a", sc: http.StatusOK, call: 1},
+				{method: http.MethodPatch, path: "/notes/0", key: "k", body: "a", sc: http.StatusOK, call: 2},
+			},
+		},
+		"client_errors_are_kept": {
+			status: http.StatusBadRequest,
+			reqs: []req{
+				{method: http.MethodPost, path: "/notes/0", key: "k", body: "a", sc: http.StatusBadRequest, call: 1},
+				{method: http.MethodPost, path: "/notes/0", key: "k", body: "a", sc: http.StatusBadRequest, call: 1, replayed: true},
+			},
+		},
+		"server_errors_are_retried": {
+			status: http.StatusInternalServerError,
+			reqs: []req{
+				{method: http.MethodPost, path: "/notes/0", key: "k", body: "a", sc: http.StatusInternalServerError, call: 1},
+				{method: http.MethodPost, path: "/notes/0", key: "k", body: "a", sc: http.StatusInternalServerError, call: 2},
+			},
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := &counter{status: tc.status}
+			h := New(store.NewMem(), time.Hour, 64).Middleware(c)
+
+			for i, rq := range tc.reqs {
+				if rq.actor == "" {
+					rq.actor = "us-authn:0"
+				}
+				w := sendAs(h, rq.actor, rq.method, rq.path, rq.key, "", []byte(rq.body))
+				require.Equal(t, rq.sc, w.Code, "request %d", i)
+				require.Equal(t, rq.replayed, w.Header().Get(ReplayedHeader) == "true", "request %d", i)
+				if rq.call != 0 {
+					require.Equal(t, fmt.Sprintf(`{"call":%d,"body":%q}`, rq.call, rq.body), w.Body.String(), "request %d", i)
+					require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+				}
+			}
+		})
+	}
+}
+
+func Test_MiddlewareMultipart(t *testing.T) {
+	t.Parallel()
+
+	c := &counter{status: http.StatusOK}
+	h := New(store.NewMem(), time.Hour, 1<<20).Middleware(c)
+
+	// every form gets a new boundary, like a browser retrying
+	ct, body := form(t, "jpeg")
+	require.Equal(t, http.StatusOK, send(h, http.MethodPost, "/photos/0", "k", ct, body).Code)
+	ct, body = form(t, "jpeg")
+	w := send(h, http.MethodPost, "/photos/0", "k", ct, body)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "true", w.Header().Get(ReplayedHeader))
+	require.Equal(t, int64(1), c.calls.Load())
+
+	ct, body = form(t, "png")
+	require.Equal(t, http.StatusUnprocessableEntity, send(h, http.MethodPost, "/photos/0", "k", ct, body).Code)
+}
+
+func Test_MiddlewareInflight(t *testing.T) {
+	t.Parallel()
+
+	// each keeper has its own store, like the http and kafka ingresses
+	// sharing a STORE_DIR would
+	dir := t.TempDir()
+	keeper := func() *Keeper {
+		s, err := store.NewFile(dir)
+		require.Nil(t, err)
+		return New(s, time.Hour, 1<<20)
+	}
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	h := keeper().Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusCreated)
+	}))
+	other := keeper().Middleware(&counter{status: http.StatusCreated})
+
+	done := make(chan int)
+	go func() { done <- send(h, http.MethodPost, "/vendor", "k", "", nil).Code }()
+	<-started
+
+	require.Equal(t, http.StatusConflict, send(h, http.MethodPost, "/vendor", "k", "", nil).Code)
+	require.Equal(t, http.StatusConflict, send(other, http.MethodPost, "/vendor", "k", "", nil).Code)
+	close(release)
+	require.Equal(t, http.StatusCreated, <-done)
+
+	w := send(other, http.MethodPost, "/vendor", "k", "", nil)
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.Equal(t, "true", w.Header().Get(ReplayedHeader))
+}
+
+func Test_MiddlewareAbandoned(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	k := New(store.NewMem(), time.Hour, 1<<20)
+	k.now = func() time.Time { return now }
+
+	// a request that never finished, like one whose server crashed
+	id := storeKey("us-authn:0", "/vendor", "k")
+	_, err := k.claim(context.Background(), id, "fp")
+	require.Nil(t, err)
+
+	c := &counter{status: http.StatusCreated}
+	h := k.Middleware(c)
+	require.Equal(t, http.StatusConflict, send(h, http.MethodPost, "/vendor", "k", "", nil).Code)
+
+	now = now.Add(claimTTL)
+	require.Equal(t, http.StatusCreated, send(h, http.MethodPost, "/vendor", "k", "", nil).Code)
+	require.Equal(t, int64(1), c.calls.Load())
+}
+
+func Test_Expiry(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := store.NewMem()
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	k := New(s, time.Hour, 1<<20)
+	k.now = func() time.Time { return now }
+
+	c := &counter{status: http.StatusCreated}
+	h := k.Middleware(c)
+
+	send(h, http.MethodPost, "/vendor", "k", "", []byte("a"))
+	send(h, http.MethodPost, "/vendor", "j", "", []byte("a"))
+
+	now = now.Add(30 * time.Minute)
+	send(h, http.MethodPost, "/vendor", "k", "", []byte("a")) // still a replay
+	require.Equal(t, int64(2), c.calls.Load())
+	require.Nil(t, k.Prune(ctx))
+	keys, err := s.Keys(ctx, table)
+	require.Nil(t, err)
+	require.Len(t, keys, 2)
+
+	// once it's expired the key is as good as new, even for a different body
+	now = now.Add(time.Hour)
+	w := send(h, http.MethodPost, "/vendor", "k", "", []byte("b"))
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.Equal(t, int64(3), c.calls.Load())
+
+	require.Nil(t, k.Prune(ctx))
+	keys, err = s.Keys(ctx, table)
+	require.Nil(t, err)
+	require.Len(t, keys, 1)
+}
@@ -0,0 +1,181 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"time"
+)
+
+type (
+	Frame struct {
+		Image image.Image
+		Time  time.Time
+	}
+
+	// AnimationOptions describe every frame in the animation; frames are
+	// scaled to Width x Height, see Fit for how zeros are handled
+	AnimationOptions struct {
+		Format  string
+		Delay   time.Duration
+		Width   int
+		Height  int
+		Overlay bool
+	}
+)
+
+const (
+	GIF  = "gif"
+	APNG = "apng"
+)
+
+var ContentTypes = map[string]string{
+	GIF:  "image/gif",
+	APNG: "image/apng",
+}
+
+// Animate scales every frame to the same size, optionally stamps each one
+// with its date, and encodes them in order
+func Animate(w io.Writer, frames []Frame, opts AnimationOptions) error {
+	if len(frames) == 0 {
+		return fmt.Errorf("no frames to animate")
+	}
+
+	// the first frame decides the size for any dimension that wasn't given
+	size := FrameSize(frames[0].Image, opts)
+
+	imgs := make([]*image.RGBA, len(frames))
+	for i, f := range frames {
+		// every frame has to be opaque, otherwise the png encoder might pick
+		// a different color type for some of them
+		imgs[i] = image.NewRGBA(size)
+		draw.Draw(imgs[i], size, image.White, image.Point{}, draw.Src)
+		draw.Draw(imgs[i], size, Fit(f.Image, size.Dx(), size.Dy()), image.Point{}, draw.Over)
+		if opts.Overlay {
+			Caption(imgs[i], f.Time.Format(time.DateOnly), max(1, size.Dy()/160), color.White, color.Black)
+		}
+	}
+
+	switch opts.Format {
+	case GIF, "":
+		return encodeGIF(w, imgs, opts.Delay)
+	case APNG:
+		return encodeAPNG(w, imgs, opts.Delay)
+	}
+
+	return fmt.Errorf("unsupported animation format: %q", opts.Format)
+}
+
+// FrameSize is the size every frame of an animation is scaled to when img is
+// the first one; callers can shrink frames to it early so they don't have to
+// hold on to full size images until Animate gets them
+func FrameSize(img image.Image, opts AnimationOptions) image.Rectangle {
+	width, height := opts.Width, opts.Height
+	if width <= 0 && height <= 0 {
+		width = 640
+	}
+	return Fit(img, width, height).Bounds()
+}
+
+func encodeGIF(w io.Writer, imgs []*image.RGBA, delay time.Duration) error {
+	anim := &gif.GIF{}
+	for _, img := range imgs {
+		p := image.NewPaletted(img.Bounds(), palette.Plan9)
+		draw.FloydSteinberg.Draw(p, p.Bounds(), img, image.Point{})
+		anim.Image = append(anim.Image, p)
+		anim.Delay = append(anim.Delay, int(delay/(10*time.Millisecond)))
+	}
+	return gif.EncodeAll(w, anim)
+}
+
+// encodeAPNG borrows the standard png encoder for the heavy lifting, and then
+// re-frames the IDAT chunks it produces as animation chunks; see
+// https://wiki.mozilla.org/APNG_Specification
+func encodeAPNG(w io.Writer, imgs []*image.RGBA, delay time.Duration) error {
+	var ihdr []byte
+	var seq uint32
+
+	out := &bytes.Buffer{}
+	out.WriteString("\x89PNG\r\n\x1a\n")
+
+	for i, img := range imgs {
+		chunks, err := pngChunks(img)
+		if err != nil {
+			return err
+		}
+
+		if i == 0 {
+			ihdr = chunks["IHDR"][0]
+			writeChunk(out, "IHDR", ihdr)
+			writeChunk(out, "acTL", binary.BigEndian.AppendUint32(
+				binary.BigEndian.AppendUint32(nil, uint32(len(imgs))),
+				0)) // loop forever
+		} else if !bytes.Equal(ihdr, chunks["IHDR"][0]) {
+			return fmt.Errorf("frame %d has a different png header than the first", i)
+		}
+
+		b := img.Bounds()
+		fctl := binary.BigEndian.AppendUint32(nil, seq)
+		fctl = binary.BigEndian.AppendUint32(fctl, uint32(b.Dx()))
+		fctl = binary.BigEndian.AppendUint32(fctl, uint32(b.Dy()))
+		fctl = binary.BigEndian.AppendUint64(fctl, 0) // x and y offsets
+		fctl = binary.BigEndian.AppendUint16(fctl, uint16(delay.Milliseconds()))
+		fctl = binary.BigEndian.AppendUint16(fctl, 1000)
+		fctl = append(fctl, 0, 0) // dispose and blend ops
+		writeChunk(out, "fcTL", fctl)
+		seq++
+
+		for _, data := range chunks["IDAT"] {
+			if i == 0 {
+				writeChunk(out, "IDAT", data)
+				continue
+			}
+			writeChunk(out, "fdAT", append(binary.BigEndian.AppendUint32(nil, seq), data...))
+			seq++
+		}
+	}
+
+	writeChunk(out, "IEND", nil)
+
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+func pngChunks(img image.Image) (map[string][][]byte, error) {
+	b := &bytes.Buffer{}
+	if err := png.Encode(b, img); err != nil {
+		return nil, err
+	}
+
+	data := b.Bytes()[8:]
+	result := map[string][][]byte{}
+	for len(data) >= 12 {
+		n := binary.BigEndian.Uint32(data)
+		if uint64(n)+12 > uint64(len(data)) {
+			return nil, fmt.Errorf("truncated png chunk")
+		}
+		name := string(data[4:8])
+		result[name] = append(result[name], data[8:8+n])
+		data = data[12+n:]
+	}
+
+	return result, nil
+}
+
+func writeChunk(w *bytes.Buffer, name string, data []byte) {
+	_ = binary.Write(w, binary.BigEndian, uint32(len(data)))
+	crc := crc32.NewIEEE()
+	_, _ = io.WriteString(crc, name)
+	_, _ = crc.Write(data)
+	w.WriteString(name)
+	w.Write(data)
+	_ = binary.Write(w, binary.BigEndian, crc.Sum32())
+}
@@ -0,0 +1,104 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func frames(n int) []Frame {
+	result := make([]Frame, n)
+	for i := range result {
+		img := image.NewRGBA(image.Rect(0, 0, 64, 48))
+		for p := 0; p < len(img.Pix); p += 4 {
+			img.Pix[p], img.Pix[p+3] = uint8((i+1)*40), 0xff
+		}
+		result[i] = Frame{Image: img, Time: time.Date(2024, 5, i+1, 0, 0, 0, 0, time.UTC)}
+	}
+	return result
+}
+
+func Test_Animate(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		frames []Frame
+		opts   AnimationOptions
+		size   image.Point
+		err    bool
+	}{
+		"gif": {
+			frames: frames(3),
+			opts:   AnimationOptions{Format: GIF, Delay: 200 * time.Millisecond, Width: 32, Overlay: true},
+			size:   image.Pt(32, 24),
+		},
+		"default_gif": {
+			frames: frames(2),
+			size:   image.Pt(640, 480),
+		},
+		"apng": {
+			frames: frames(3),
+			opts:   AnimationOptions{Format: APNG, Delay: 100 * time.Millisecond, Height: 24},
+			size:   image.Pt(32, 24),
+		},
+		"no_frames": {
+			err: true,
+		},
+		"bad_format": {
+			frames: frames(1),
+			opts:   AnimationOptions{Format: "webp"},
+			err:    true,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			b := &bytes.Buffer{}
+			err := Animate(b, tc.frames, tc.opts)
+			if tc.err {
+				require.NotNil(t, err)
+				return
+			}
+			require.Nil(t, err)
+
+			switch tc.opts.Format {
+			case APNG:
+				// anything that can't animate a png shows the first frame
+				img, err := png.Decode(bytes.NewReader(b.Bytes()))
+				require.Nil(t, err)
+				require.Equal(t, tc.size, img.Bounds().Size())
+				require.Equal(t, len(tc.frames), bytes.Count(b.Bytes(), []byte("fcTL")))
+			default:
+				anim, err := gif.DecodeAll(b)
+				require.Nil(t, err)
+				require.Len(t, anim.Image, len(tc.frames))
+				require.Equal(t, tc.size, anim.Image[0].Bounds().Size())
+				require.Equal(t, int(tc.opts.Delay/(10*time.Millisecond)), anim.Delay[0])
+			}
+		})
+	}
+}
+
+func Test_Animate_overlay(t *testing.T) {
+	t.Parallel()
+
+	b := &bytes.Buffer{}
+	require.Nil(t, Animate(b, frames(1), AnimationOptions{Format: APNG, Width: 64, Overlay: true}))
+
+	img, err := png.Decode(b)
+	require.Nil(t, err)
+
+	// the caption box is black, the frame is not
+	r, g, bl, _ := img.At(0, img.Bounds().Max.Y-1).RGBA()
+	require.Equal(t, [3]uint32{0, 0, 0}, [3]uint32{r, g, bl})
+	require.NotEqual(t, color.RGBAModel.Convert(color.Black), color.RGBAModel.Convert(img.At(63, 0)))
+}
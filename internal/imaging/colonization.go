@@ -0,0 +1,103 @@
+package imaging
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+// ColonizationProfile tunes how mycelium is told apart from substrate; every
+// field but the thresholds is a fraction between 0 and 1
+type ColonizationProfile struct {
+	// pixels at least this bright...
+	MinValue float64 `json:"min_value"`
+	// ...and at most this saturated are counted as mycelium
+	MaxSaturation float64 `json:"max_saturation"`
+	// pixels darker than this are shadows or background, so they're counted
+	// as neither mycelium nor substrate
+	IgnoreBelow float64 `json:"ignore_below"`
+	// how much of each edge to crop before counting, since jars and bags
+	// rarely fill the frame
+	Margin float64 `json:"margin"`
+	// the percent coverage that counts as 50% and 100% colonization; photos
+	// almost never show 100% of anything
+	Half float64 `json:"half"`
+	Full float64 `json:"full"`
+}
+
+// analysisWidth is the size photos are scaled down to before counting; more
+// pixels don't make the estimate any better
+const analysisWidth = 256
+
+var DefaultColonizationProfile = ColonizationProfile{
+	MinValue:      0.7,
+	MaxSaturation: 0.25,
+	IgnoreBelow:   0.08,
+	Margin:        0.1,
+	Half:          50,
+	Full:          95,
+}
+
+func (p ColonizationProfile) Validate() error {
+	for name, f := range map[string]float64{
+		"min_value":      p.MinValue,
+		"max_saturation": p.MaxSaturation,
+		"ignore_below":   p.IgnoreBelow,
+	} {
+		if f < 0 || f > 1 {
+			return fmt.Errorf("%s must be between 0 and 1: %g", name, f)
+		}
+	}
+
+	if p.Margin < 0 || p.Margin >= 0.5 {
+		return fmt.Errorf("margin must be at least 0 and less than 0.5: %g", p.Margin)
+	} else if p.IgnoreBelow >= p.MinValue {
+		return fmt.Errorf("ignore_below must be less than min_value")
+	} else if p.Half <= 0 || p.Full > 100 || p.Half >= p.Full {
+		return fmt.Errorf("thresholds must satisfy 0 < half < full <= 100")
+	}
+
+	return nil
+}
+
+// Colonization estimates the percent of the substrate in img that's covered
+// in mycelium, by thresholding each pixel's brightness and saturation
+func Colonization(img image.Image, p ColonizationProfile) (float64, error) {
+	if img.Bounds().Empty() {
+		return 0, fmt.Errorf("empty image")
+	}
+
+	small := Fit(img, min(analysisWidth, img.Bounds().Dx()), 0)
+	b := small.Bounds()
+	mx, my := int(float64(b.Dx())*p.Margin), int(float64(b.Dy())*p.Margin)
+
+	var mycelium, total int
+	for y := b.Min.Y + my; y < b.Max.Y-my; y++ {
+		for x := b.Min.X + mx; x < b.Max.X-mx; x++ {
+			c := small.RGBAAt(x, y)
+			s, v := saturationValue(c.R, c.G, c.B)
+			if v < p.IgnoreBelow {
+				continue
+			}
+			total++
+			if v >= p.MinValue && s <= p.MaxSaturation {
+				mycelium++
+			}
+		}
+	}
+
+	if total == 0 {
+		return 0, fmt.Errorf("nothing left to measure after ignoring the background")
+	}
+
+	return math.Round(1000*float64(mycelium)/float64(total)) / 10, nil
+}
+
+// saturationValue is the S and V from HSV, hue doesn't matter here
+func saturationValue(r, g, b uint8) (float64, float64) {
+	hi, lo := max(r, g, b), min(r, g, b)
+	if hi == 0 {
+		return 0, 0
+	}
+	return float64(hi-lo) / float64(hi), float64(hi) / 255
+}
@@ -0,0 +1,130 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// jar is mostly substrate with a white band across the top; the outer edge is
+// a dark background that should never be counted
+func jar(white int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 100, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 100; x++ {
+			switch {
+			case x < 10 || x >= 90 || y < 10 || y >= 90:
+				img.SetRGBA(x, y, color.RGBA{A: 0xff})
+			case y < 10+white*80/100:
+				img.SetRGBA(x, y, color.RGBA{R: 235, G: 235, B: 225, A: 0xff})
+			default:
+				img.SetRGBA(x, y, color.RGBA{R: 120, G: 80, B: 40, A: 0xff})
+			}
+		}
+	}
+	return img
+}
+
+func Test_Colonization(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		img     image.Image
+		profile ColonizationProfile
+		pct     float64
+		err     bool
+	}{
+		"none": {
+			img:     jar(0),
+			profile: DefaultColonizationProfile,
+		},
+		"half": {
+			img:     jar(50),
+			profile: DefaultColonizationProfile,
+			pct:     50,
+		},
+		"all": {
+			img:     jar(100),
+			profile: DefaultColonizationProfile,
+			pct:     100,
+		},
+		"no_margin_still_ignores_background": {
+			img: jar(25),
+			profile: func(p ColonizationProfile) ColonizationProfile {
+				p.Margin = 0
+				return p
+			}(DefaultColonizationProfile),
+			pct: 25,
+		},
+		"strict_profile": {
+			img: jar(50),
+			profile: func(p ColonizationProfile) ColonizationProfile {
+				p.MinValue = 0.95
+				return p
+			}(DefaultColonizationProfile),
+		},
+		"all_background": {
+			img:     image.NewRGBA(image.Rect(0, 0, 10, 10)),
+			profile: DefaultColonizationProfile,
+			err:     true,
+		},
+		"empty": {
+			img:     image.NewRGBA(image.Rect(0, 0, 0, 0)),
+			profile: DefaultColonizationProfile,
+			err:     true,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			pct, err := Colonization(tc.img, tc.profile)
+			require.Equal(t, tc.err, err != nil, err)
+			require.InDelta(t, tc.pct, pct, 2)
+		})
+	}
+}
+
+func Test_ColonizationProfile_Validate(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		change func(*ColonizationProfile)
+		err    bool
+	}{
+		"default": {
+			change: func(*ColonizationProfile) {},
+		},
+		"value_out_of_range": {
+			change: func(p *ColonizationProfile) { p.MinValue = 1.5 },
+			err:    true,
+		},
+		"margin_too_big": {
+			change: func(p *ColonizationProfile) { p.Margin = 0.5 },
+			err:    true,
+		},
+		"ignoring_mycelium": {
+			change: func(p *ColonizationProfile) { p.IgnoreBelow = p.MinValue },
+			err:    true,
+		},
+		"thresholds_backwards": {
+			change: func(p *ColonizationProfile) { p.Half, p.Full = p.Full, p.Half },
+			err:    true,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			p := DefaultColonizationProfile
+			tc.change(&p)
+			require.Equal(t, tc.err, p.Validate() != nil)
+		})
+	}
+}
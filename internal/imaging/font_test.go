@@ -0,0 +1,90 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_TextSize(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		s     string
+		scale int
+		size  image.Point
+	}{
+		"empty": {
+			scale: 1,
+		},
+		"one_glyph": {
+			s:     "A",
+			scale: 1,
+			size:  image.Pt(5, 7),
+		},
+		"scaled": {
+			s:     "2024-05-06",
+			scale: 2,
+			size:  image.Pt(118, 14),
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tc.size, TextSize(tc.s, tc.scale))
+		})
+	}
+}
+
+func Test_DrawText(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		s   string
+		lit int
+	}{
+		"blank": {
+			s: " ",
+		},
+		"bar": {
+			s:   "|",
+			lit: 7,
+		},
+		"unprintable_is_a_question": {
+			s:   "\x01",
+			lit: 9,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+			DrawText(img, image.Point{}, tc.s, 1, color.White)
+
+			lit := 0
+			for i := 0; i < len(img.Pix); i += 4 {
+				if img.Pix[i] == 0xff {
+					lit++
+				}
+			}
+			require.Equal(t, tc.lit, lit)
+		})
+	}
+}
+
+func Test_Caption(t *testing.T) {
+	t.Parallel()
+
+	img := image.NewRGBA(image.Rect(0, 0, 100, 50))
+	Caption(img, "hi", 1, color.White, color.RGBA{R: 1, A: 0xff})
+
+	require.Equal(t, color.RGBA{R: 1, A: 0xff}, img.RGBAAt(0, 49))
+	require.Equal(t, color.RGBA{}, img.RGBAAt(99, 0))
+}
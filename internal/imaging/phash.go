@@ -0,0 +1,82 @@
+package imaging
+
+import (
+	"image"
+	"math"
+	"math/bits"
+	"slices"
+)
+
+const (
+	// photos are shrunk to hashSize x hashSize before transforming...
+	hashSize = 32
+	// ...and only the lowest hashBits x hashBits frequencies are kept
+	hashBits = 8
+)
+
+// dctCos[u][x] is the DCT-II basis for frequency u at sample x
+var dctCos = func() (result [hashBits][hashSize]float64) {
+	for u := range result {
+		for x := range result[u] {
+			result[u][x] = math.Cos(float64(2*x+1) * float64(u) * math.Pi / (2 * hashSize))
+		}
+	}
+	return result
+}()
+
+// PHash is a perceptual hash of img: the sign of each low frequency of its
+// brightness relative to their median. Resizing, recompressing or slightly
+// adjusting a photo changes only a few bits, so similar photos have a small
+// Distance between their hashes
+func PHash(img image.Image) uint64 {
+	small := Fit(img, hashSize, hashSize)
+
+	var lum [hashSize][hashSize]float64
+	for y := range lum {
+		for x := range lum[y] {
+			c := small.RGBAAt(x, y)
+			lum[y][x] = 0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B)
+		}
+	}
+
+	// the transform is separable, so do the rows and then the columns
+	var rows [hashSize][hashBits]float64
+	for y := range rows {
+		for u := range rows[y] {
+			for x := 0; x < hashSize; x++ {
+				rows[y][u] += lum[y][x] * dctCos[u][x]
+			}
+		}
+	}
+
+	coeffs := make([]float64, 0, hashBits*hashBits)
+	for v := 0; v < hashBits; v++ {
+		for u := 0; u < hashBits; u++ {
+			var sum float64
+			for y := 0; y < hashSize; y++ {
+				sum += rows[y][u] * dctCos[v][y]
+			}
+			coeffs = append(coeffs, sum)
+		}
+	}
+
+	// the first coefficient is the average brightness, which says nothing
+	// about what's in the photo, so it's left out of the median
+	sorted := slices.Clone(coeffs[1:])
+	slices.Sort(sorted)
+	median := sorted[len(sorted)/2]
+
+	var result uint64
+	for i, c := range coeffs {
+		if c > median {
+			result |= 1 << i
+		}
+	}
+
+	return result
+}
+
+// Distance is the number of bits that differ between two hashes
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
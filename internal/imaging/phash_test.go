@@ -0,0 +1,91 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// scene draws a few overlapping shapes; seed moves them around so different
+// seeds make different pictures
+func scene(w, h, seed int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			fx, fy := x*100/w, y*100/h
+			c := color.RGBA{R: uint8(fx * 2), G: uint8(fy * 2), B: 90, A: 0xff}
+			if dx, dy := fx-(20+seed*13)%80, fy-(30+seed*29)%70; dx*dx+dy*dy < 300 {
+				c = color.RGBA{R: 240, G: 240, B: 230, A: 0xff}
+			}
+			if fx > (50+seed*7)%60 && fx < (50+seed*7)%60+30 && fy > 60 {
+				c = color.RGBA{R: 30, G: 20, B: 10, A: 0xff}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func Test_PHash(t *testing.T) {
+	t.Parallel()
+
+	original := scene(400, 300, 1)
+
+	recompressed := func() image.Image {
+		b := &bytes.Buffer{}
+		require.Nil(t, jpeg.Encode(b, original, &jpeg.Options{Quality: 30}))
+		img, err := jpeg.Decode(b)
+		require.Nil(t, err)
+		return img
+	}()
+
+	tcs := map[string]struct {
+		img     image.Image
+		similar bool
+	}{
+		"identical": {
+			img:     original,
+			similar: true,
+		},
+		"resized": {
+			img:     Fit(original, 160, 0),
+			similar: true,
+		},
+		"recompressed": {
+			img:     recompressed,
+			similar: true,
+		},
+		"different": {
+			img: scene(400, 300, 4),
+		},
+		"blank": {
+			img: image.NewRGBA(image.Rect(0, 0, 400, 300)),
+		},
+	}
+
+	want := PHash(original)
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			d := Distance(want, PHash(tc.img))
+			if tc.similar {
+				require.LessOrEqual(t, d, 6, "distance")
+			} else {
+				require.Greater(t, d, 12, "distance")
+			}
+		})
+	}
+}
+
+func Test_Distance(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, 0, Distance(0xf0f0, 0xf0f0))
+	require.Equal(t, 4, Distance(0xf0f0, 0xf0ff))
+	require.Equal(t, 64, Distance(0, ^uint64(0)))
+}
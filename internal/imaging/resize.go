@@ -0,0 +1,70 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	// register the decoders for everything writePhoto accepts that the
+	// standard library can read
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// maxSamples caps how many source pixels, per axis, are averaged into each
+// destination pixel; it keeps shrinking a 12 megapixel photo reasonably fast
+const maxSamples = 4
+
+// Fit scales src to exactly width x height; when one dimension is 0 it's
+// derived from the other so the aspect ratio is preserved
+func Fit(src image.Image, width, height int) *image.RGBA {
+	b := src.Bounds()
+	if b.Empty() {
+		return image.NewRGBA(image.Rect(0, 0, width, height))
+	} else if width <= 0 && height <= 0 {
+		width, height = b.Dx(), b.Dy()
+	} else if width <= 0 {
+		width = max(1, b.Dx()*height/b.Dy())
+	} else if height <= 0 {
+		height = max(1, b.Dy()*width/b.Dx())
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	if width == b.Dx() && height == b.Dy() {
+		draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Src)
+		return dst
+	}
+
+	for y := 0; y < height; y++ {
+		y0, y1 := b.Min.Y+y*b.Dy()/height, b.Min.Y+(y+1)*b.Dy()/height
+		for x := 0; x < width; x++ {
+			x0, x1 := b.Min.X+x*b.Dx()/width, b.Min.X+(x+1)*b.Dx()/width
+			dst.SetRGBA(x, y, average(src, x0, y0, max(x1, x0+1), max(y1, y0+1)))
+		}
+	}
+
+	return dst
+}
+
+// average samples at most maxSamples^2 evenly spaced pixels from the
+// rectangle and returns their mean
+func average(src image.Image, x0, y0, x1, y1 int) color.RGBA {
+	sx, sy := min(x1-x0, maxSamples), min(y1-y0, maxSamples)
+
+	var r, g, b, a, n uint32
+	for j := 0; j < sy; j++ {
+		y := y0 + j*(y1-y0)/sy
+		for i := 0; i < sx; i++ {
+			cr, cg, cb, ca := src.At(x0+i*(x1-x0)/sx, y).RGBA()
+			r, g, b, a, n = r+cr, g+cg, b+cb, a+ca, n+1
+		}
+	}
+
+	return color.RGBA{
+		R: uint8(r / n >> 8),
+		G: uint8(g / n >> 8),
+		B: uint8(b / n >> 8),
+		A: uint8(a / n >> 8),
+	}
+}
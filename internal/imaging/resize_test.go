@@ -0,0 +1,56 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Fit(t *testing.T) {
+	t.Parallel()
+
+	src := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 40; x++ {
+			if x >= 20 {
+				src.SetRGBA(x, y, color.RGBA{R: 0xff, A: 0xff})
+			}
+		}
+	}
+
+	tcs := map[string]struct {
+		w, h int
+		size image.Point
+	}{
+		"width_only": {
+			w:    20,
+			size: image.Pt(20, 10),
+		},
+		"height_only": {
+			h:    40,
+			size: image.Pt(80, 40),
+		},
+		"both": {
+			w:    4,
+			h:    4,
+			size: image.Pt(4, 4),
+		},
+		"neither": {
+			size: image.Pt(40, 20),
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dst := Fit(src, tc.w, tc.h)
+			require.Equal(t, tc.size, dst.Bounds().Size())
+			require.Equal(t, color.RGBA{}, dst.RGBAAt(0, 0))
+			require.Equal(t, color.RGBA{R: 0xff, A: 0xff}, dst.RGBAAt(tc.size.X-1, tc.size.Y-1))
+		})
+	}
+}
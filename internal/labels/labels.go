@@ -0,0 +1,252 @@
+// Package labels lays out sheets of printable labels, each with a qr code
+// and a few lines of text, on standard Avery stock, and renders them as png
+// or pdf
+package labels
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"sort"
+	"strings"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/imaging"
+	"github.com/jsmit257/centerforfunguscontrol/internal/qr"
+)
+
+type (
+	// Layout is a kind of label stock; everything is in points, 1/72 of an
+	// inch, measured from the top-left corner of the page
+	Layout struct {
+		Name string `json:"name"`
+		// what it's sold as
+		Description string `json:"description"`
+
+		PageWidth  float64 `json:"page_width"`
+		PageHeight float64 `json:"page_height"`
+		Columns    int     `json:"columns"`
+		Rows       int     `json:"rows"`
+		Width      float64 `json:"width"`
+		Height     float64 `json:"height"`
+		// where the first label's top-left corner is
+		Left float64 `json:"left"`
+		Top  float64 `json:"top"`
+		// from one label's top-left corner to the next one's
+		HPitch float64 `json:"h_pitch"`
+		VPitch float64 `json:"v_pitch"`
+	}
+
+	Label struct {
+		QR *qr.Code
+		// drawn bigger than the rest, like a short code
+		Title string
+		Lines []string
+	}
+
+	// Sheet is labels on as many pages of a layout as it takes; Skip is how
+	// many positions on the first page are already used, so a partly used
+	// sheet can go back in the printer
+	Sheet struct {
+		Layout Layout
+		Labels []Label
+		Skip   int
+	}
+
+	// box is where a label goes, in whatever units are being drawn in
+	box struct {
+		x, y, w, h float64
+	}
+)
+
+const inch = 72.0
+
+// padding is how far everything stays from the edge of a label, since label
+// printers aren't that accurate
+const padding = 0.06 * inch
+
+var Layouts = map[string]Layout{
+	"5160": {
+		Name:        "5160",
+		Description: "address, 1\" x 2 5/8\", 30 per sheet",
+		PageWidth:   8.5 * inch, PageHeight: 11 * inch,
+		Columns: 3, Rows: 10,
+		Width: 2.625 * inch, Height: 1 * inch,
+		Left: 0.1875 * inch, Top: 0.5 * inch,
+		HPitch: 2.75 * inch, VPitch: 1 * inch,
+	},
+	"5163": {
+		Name:        "5163",
+		Description: "shipping, 2\" x 4\", 10 per sheet",
+		PageWidth:   8.5 * inch, PageHeight: 11 * inch,
+		Columns: 2, Rows: 5,
+		Width: 4 * inch, Height: 2 * inch,
+		Left: 0.15625 * inch, Top: 0.5 * inch,
+		HPitch: 4.1875 * inch, VPitch: 2 * inch,
+	},
+	"5164": {
+		Name:        "5164",
+		Description: "shipping, 3 1/3\" x 4\", 6 per sheet",
+		PageWidth:   8.5 * inch, PageHeight: 11 * inch,
+		Columns: 2, Rows: 3,
+		Width: 4 * inch, Height: 10.0 / 3 * inch,
+		Left: 0.15625 * inch, Top: 0.5 * inch,
+		HPitch: 4.1875 * inch, VPitch: 10.0 / 3 * inch,
+	},
+}
+
+// DefaultLayout is the one used when nobody says
+const DefaultLayout = "5160"
+
+// LayoutNames are all the layouts there are, in order
+func LayoutNames() []string {
+	result := make([]string, 0, len(Layouts))
+	for name := range Layouts {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func (l Layout) PerPage() int {
+	return l.Columns * l.Rows
+}
+
+// slot is where the label in position i on a page goes, across then down
+func (l Layout) slot(i int) box {
+	col, row := i%l.Columns, i/l.Columns
+	return box{
+		x: l.Left + float64(col)*l.HPitch,
+		y: l.Top + float64(row)*l.VPitch,
+		w: l.Width,
+		h: l.Height,
+	}
+}
+
+func (s Sheet) validate() error {
+	if s.Layout.PerPage() == 0 {
+		return fmt.Errorf("layout %q has no labels", s.Layout.Name)
+	} else if s.Skip < 0 || s.Skip >= s.Layout.PerPage() {
+		return fmt.Errorf("can skip from 0 to %d labels, not %d", s.Layout.PerPage()-1, s.Skip)
+	} else if len(s.Labels) == 0 {
+		return fmt.Errorf("no labels")
+	}
+	return nil
+}
+
+// Pages is how many pages it takes
+func (s Sheet) Pages() int {
+	per := s.Layout.PerPage()
+	if per == 0 {
+		return 0
+	}
+	return (s.Skip + len(s.Labels) + per - 1) / per
+}
+
+// page is the labels on page p, counting from zero, and where they go
+func (s Sheet) page(p int) ([]Label, []box) {
+	per := s.Layout.PerPage()
+
+	var labels []Label
+	var boxes []box
+	for i := p * per; i < (p+1)*per; i++ {
+		if n := i - s.Skip; n >= 0 && n < len(s.Labels) {
+			labels = append(labels, s.Labels[n])
+			boxes = append(boxes, s.Layout.slot(i%per))
+		}
+	}
+	return labels, boxes
+}
+
+// PNG draws page p, counting from one, at dpi
+func (s Sheet) PNG(p, dpi int) (*image.Paletted, error) {
+	if err := s.validate(); err != nil {
+		return nil, err
+	} else if p < 1 || p > s.Pages() {
+		return nil, fmt.Errorf("there are %d pages, not %d", s.Pages(), p)
+	} else if dpi < 72 || dpi > 600 {
+		return nil, fmt.Errorf("dpi has to be from 72 to 600, not %d", dpi)
+	}
+
+	px := func(pt float64) int { return int(pt * float64(dpi) / inch) }
+
+	img := image.NewPaletted(
+		image.Rect(0, 0, px(s.Layout.PageWidth), px(s.Layout.PageHeight)),
+		color.Palette{color.White, color.Black})
+
+	labels, boxes := s.page(p - 1)
+	for i, l := range labels {
+		b := boxes[i]
+		drawLabel(img, l, image.Rect(px(b.x), px(b.y), px(b.x+b.w), px(b.y+b.h)), px(padding))
+	}
+
+	return img, nil
+}
+
+// drawLabel puts the qr code on the left of r, as big as it fits, and the
+// text to the right of it
+func drawLabel(dst draw.Image, l Label, r image.Rectangle, pad int) {
+	r = r.Inset(pad)
+	if r.Empty() {
+		return
+	}
+
+	text := r
+	if l.QR != nil {
+		modules := l.QR.Size + 2*qr.QuietZone
+		side := min(r.Dy(), r.Dx()/2)
+		if scale := side / modules; scale > 0 {
+			code := l.QR.Image(scale)
+			at := image.Pt(r.Min.X, r.Min.Y+(r.Dy()-code.Bounds().Dy())/2)
+			draw.Draw(dst, code.Bounds().Add(at), code, image.Point{}, draw.Src)
+			text.Min.X += code.Bounds().Dx()
+		}
+	}
+
+	// the title is half again as big as the rest, and everything is as big
+	// as it can be and still fit
+	lines := len(l.Lines)
+	body := max(1, text.Dy()*2/((lines*2+3)*(glyphRows+2)))
+	title := max(body, body*3/2)
+	for title > 1 && imaging.TextSize(ascii(l.Title), title).X > text.Dx() {
+		title--
+	}
+
+	y := text.Min.Y
+	imaging.DrawText(dst, image.Pt(text.Min.X, y), fit(ascii(l.Title), text.Dx(), title), title, color.Black)
+	y += (glyphRows + 2) * title
+	for _, line := range l.Lines {
+		if y+glyphRows*body > text.Max.Y {
+			break
+		}
+		imaging.DrawText(dst, image.Pt(text.Min.X, y), fit(ascii(line), text.Dx(), body), body, color.Black)
+		y += (glyphRows + 2) * body
+	}
+}
+
+// glyphRows is how tall imaging's font is
+const glyphRows = 7
+
+// fit cuts s short, with an ellipsis, until it's no wider than width
+func fit(s string, width, scale int) string {
+	if imaging.TextSize(s, scale).X <= width {
+		return s
+	}
+	for len(s) > 0 && imaging.TextSize(s+"..", scale).X > width {
+		s = s[:len(s)-1]
+	}
+	if s == "" {
+		return ""
+	}
+	return s + ".."
+}
+
+// ascii is s with anything imaging can't draw replaced, a rune at a time
+func ascii(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < ' ' || r > '~' {
+			return '?'
+		}
+		return r
+	}, s)
+}
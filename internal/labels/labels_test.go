@@ -0,0 +1,294 @@
+package labels
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/qr"
+)
+
+func newLabels(t *testing.T, n int) []Label {
+	result := make([]Label, n)
+	for i := range result {
+		code, err := qr.Encode([]byte(fmt.Sprintf("https://cffc.local/scan/LC-2026-%04d", i+1)), qr.Medium)
+		require.Nil(t, err)
+		result[i] = Label{
+			QR:    code,
+			Title: fmt.Sprintf("LC-2026-%04d", i+1),
+			Lines: []string{"Golden Teacher", "rye / cvg", "inoculated 2026-03-01"},
+		}
+	}
+	return result
+}
+
+func Test_Pages(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		layout string
+		labels int
+		skip   int
+		pages  int
+	}{
+		"one":         {layout: "5160", labels: 1, pages: 1},
+		"full_page":   {layout: "5160", labels: 30, pages: 1},
+		"spills":      {layout: "5160", labels: 31, pages: 2},
+		"skip_spills": {layout: "5160", labels: 30, skip: 1, pages: 2},
+		"skip_fits":   {layout: "5163", labels: 5, skip: 5, pages: 1},
+		"big_labels":  {layout: "5164", labels: 13, pages: 3},
+		"nothing":     {layout: "5164", pages: 0},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			s := Sheet{Layout: Layouts[tc.layout], Labels: make([]Label, tc.labels), Skip: tc.skip}
+			require.Equal(t, tc.pages, s.Pages())
+		})
+	}
+}
+
+func Test_Layouts(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, []string{"5160", "5163", "5164"}, LayoutNames())
+	for name, l := range Layouts {
+		require.Equal(t, name, l.Name)
+		// every label is on the page, and none of them overlap
+		last := l.slot(l.PerPage() - 1)
+		require.LessOrEqual(t, last.x+last.w, l.PageWidth, name)
+		require.LessOrEqual(t, last.y+last.h, l.PageHeight+0.001, name)
+		require.LessOrEqual(t, l.Width, l.HPitch, name)
+		require.LessOrEqual(t, l.Height, l.VPitch, name)
+	}
+}
+
+func Test_PNG(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		labels int
+		skip   int
+		page   int
+		dpi    int
+		filled []int
+		err    bool
+	}{
+		"first_page": {
+			labels: 2,
+			page:   1,
+			dpi:    150,
+			filled: []int{0, 1},
+		},
+		"skipped": {
+			labels: 2,
+			skip:   2,
+			page:   1,
+			dpi:    150,
+			filled: []int{2, 3},
+		},
+		"second_page": {
+			labels: 31,
+			page:   2,
+			dpi:    100,
+			filled: []int{0},
+		},
+		"no_such_page": {
+			labels: 2,
+			page:   2,
+			dpi:    150,
+			err:    true,
+		},
+		"bad_dpi": {
+			labels: 2,
+			page:   1,
+			dpi:    10,
+			err:    true,
+		},
+		"skip_too_many": {
+			labels: 2,
+			skip:   30,
+			page:   1,
+			dpi:    150,
+			err:    true,
+		},
+		"no_labels": {
+			page: 1,
+			dpi:  150,
+			err:  true,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			l := Layouts["5160"]
+			s := Sheet{Layout: l, Labels: newLabels(t, tc.labels), Skip: tc.skip}
+			img, err := s.PNG(tc.page, tc.dpi)
+			if tc.err {
+				require.NotNil(t, err)
+				return
+			}
+			require.Nil(t, err)
+			require.Equal(t, int(8.5*float64(tc.dpi)), img.Bounds().Dx())
+			require.Equal(t, 11*tc.dpi, img.Bounds().Dy())
+
+			px := func(pt float64) int { return int(pt * float64(tc.dpi) / inch) }
+			for i := 0; i < l.PerPage(); i++ {
+				b := l.slot(i)
+				r := image.Rect(px(b.x), px(b.y), px(b.x+b.w), px(b.y+b.h))
+				require.Equal(t, contains(tc.filled, i), dark(img, r), "slot %d", i)
+			}
+
+			// the first label's qr code is the one it was given, module for
+			// module
+			b := l.slot(tc.filled[0])
+			r := image.Rect(px(b.x), px(b.y), px(b.x+b.w), px(b.y+b.h)).Inset(px(padding))
+			code := s.Labels[(tc.page-1)*l.PerPage()+tc.filled[0]-tc.skip].QR
+			scale := min(r.Dy(), r.Dx()/2) / (code.Size + 2*qr.QuietZone)
+			require.Greater(t, scale, 0)
+			top := r.Min.Y + (r.Dy()-(code.Size+2*qr.QuietZone)*scale)/2
+			for y := 0; y < code.Size; y++ {
+				for x := 0; x < code.Size; x++ {
+					at := image.Pt(
+						r.Min.X+(x+qr.QuietZone)*scale+scale/2,
+						top+(y+qr.QuietZone)*scale+scale/2)
+					require.Equal(t, code.Black(x, y), img.ColorIndexAt(at.X, at.Y) == 1, "%d,%d", x, y)
+				}
+			}
+		})
+	}
+}
+
+func dark(img *image.Paletted, r image.Rectangle) bool {
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if img.ColorIndexAt(x, y) == 1 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func contains(all []int, i int) bool {
+	for _, a := range all {
+		if a == i {
+			return true
+		}
+	}
+	return false
+}
+
+func Test_PDF(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		layout string
+		labels int
+		skip   int
+		pages  int
+		err    bool
+	}{
+		"one_page":   {layout: "5160", labels: 3, pages: 1},
+		"two_pages":  {layout: "5163", labels: 8, skip: 3, pages: 2},
+		"no_labels":  {layout: "5160", err: true},
+		"skip_a_lot": {layout: "5163", labels: 1, skip: 10, err: true},
+	}
+
+	objRe := regexp.MustCompile(`^(\d+) 0 obj\n`)
+	streamRe := regexp.MustCompile(`(?s)/Length (\d+) /Filter /FlateDecode >>\nstream\n`)
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			s := Sheet{Layout: Layouts[tc.layout], Labels: newLabels(t, tc.labels), Skip: tc.skip}
+			b := &bytes.Buffer{}
+			err := s.PDF(b)
+			if tc.err {
+				require.NotNil(t, err)
+				return
+			}
+			require.Nil(t, err)
+
+			doc := b.Bytes()
+			require.True(t, bytes.HasPrefix(doc, []byte("%PDF-1.4\n")))
+			require.True(t, bytes.HasSuffix(doc, []byte("%%EOF\n")))
+			require.Equal(t, tc.pages, bytes.Count(doc, []byte("/Type /Page ")))
+
+			// every object is where the cross-reference table says it is
+			tail := doc[bytes.LastIndex(doc, []byte("startxref\n"))+len("startxref\n"):]
+			xref, err := strconv.Atoi(strings.TrimSpace(strings.TrimSuffix(string(tail), "%%EOF\n")))
+			require.Nil(t, err)
+			entries := strings.Split(string(doc[xref:]), "\n")
+			require.Equal(t, "xref", entries[0])
+			for i, entry := range entries[3 : 3+3+tc.pages*2] {
+				off, err := strconv.Atoi(entry[:10])
+				require.Nil(t, err)
+				m := objRe.FindSubmatch(doc[off:])
+				require.NotNil(t, m, entry)
+				require.Equal(t, strconv.Itoa(i+1), string(m[1]))
+			}
+
+			// and the pages have every label's code and title on them
+			var content bytes.Buffer
+			for _, loc := range streamRe.FindAllSubmatchIndex(doc, -1) {
+				n, _ := strconv.Atoi(string(doc[loc[2]:loc[3]]))
+				zr, err := zlib.NewReader(bytes.NewReader(doc[loc[1] : loc[1]+n]))
+				require.Nil(t, err)
+				_, err = io.Copy(&content, zr)
+				require.Nil(t, err)
+			}
+			for _, l := range s.Labels {
+				require.Contains(t, content.String(), "("+l.Title+") Tj")
+			}
+			require.Contains(t, content.String(), " re\n")
+		})
+	}
+}
+
+func Test_pdfString(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		in, out string
+	}{
+		"plain":   {in: "LC-2026-0001", out: "LC-2026-0001"},
+		"parens":  {in: `a (b) \c`, out: `a \(b\) \\c`},
+		"latin_1": {in: "café", out: `caf\351`},
+		"other":   {in: "菌", out: "?"},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tc.out, pdfString(tc.in))
+		})
+	}
+}
+
+func Test_fit(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "abc", fit("abc", 100, 1))
+	require.Equal(t, "ab..", fit("abcdef", 23, 1))
+	require.Equal(t, "", fit("abcdef", 5, 1))
+	require.Equal(t, "abcdef", pdfFit("abcdef", 100, 10))
+	require.Equal(t, "ab..", pdfFit("abcdef", 24, 10))
+	require.Equal(t, "", pdfFit("abcdef", 12, 10))
+}
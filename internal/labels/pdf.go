@@ -0,0 +1,233 @@
+package labels
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/qr"
+)
+
+// helveticaWidth is about as wide as the average character in Helvetica, as
+// a fraction of the font size; it errs on the wide side so nothing runs off
+// the edge of a label
+const helveticaWidth = 0.6
+
+// PDF writes every page; it's drawn with rectangles and the standard
+// Helvetica font, so it's small and prints sharp at any size, and there's
+// nothing to embed
+func (s Sheet) PDF(w io.Writer) error {
+	if err := s.validate(); err != nil {
+		return err
+	}
+
+	pw := &pdfWriter{w: bufio.NewWriter(w)}
+	pw.printf("%%PDF-1.4\n%%\xe2\xe3\xcf\xd3\n")
+
+	pages := s.Pages()
+	// 1 is the catalog, 2 the page tree and 3 the font; then each page is
+	// followed by its contents
+	kids := make([]string, pages)
+	for p := range kids {
+		kids[p] = fmt.Sprintf("%d 0 R", 4+p*2)
+	}
+
+	pw.object(1, "<< /Type /Catalog /Pages 2 0 R >>")
+	pw.object(2, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), pages))
+	pw.object(3, "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+
+	for p := 0; p < pages; p++ {
+		content, err := deflate(s.pdfPage(p))
+		if err != nil {
+			return err
+		}
+
+		pw.object(4+p*2, fmt.Sprintf(
+			"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>",
+			num(s.Layout.PageWidth), num(s.Layout.PageHeight), 5+p*2))
+		pw.stream(5+p*2, content)
+	}
+
+	pw.trailer()
+	return pw.err
+}
+
+// pdfPage is the content stream for page p, counting from zero; pdf
+// measures up from the bottom of the page
+func (s Sheet) pdfPage(p int) []byte {
+	b := &bytes.Buffer{}
+	labels, boxes := s.page(p)
+	for i, l := range labels {
+		bx := boxes[i]
+		r := box{
+			x: bx.x + padding,
+			y: s.Layout.PageHeight - bx.y - bx.h + padding,
+			w: bx.w - 2*padding,
+			h: bx.h - 2*padding,
+		}
+		if r.w <= 0 || r.h <= 0 {
+			continue
+		}
+
+		text := r
+		if l.QR != nil {
+			modules := float64(l.QR.Size + 2*qr.QuietZone)
+			side := min(r.h, r.w/2)
+			module := side / modules
+			x0 := r.x + qr.QuietZone*module
+			y0 := r.y + (r.h-side)/2 + side - qr.QuietZone*module
+
+			// each run of dark modules in a row is one rectangle
+			for y := 0; y < l.QR.Size; y++ {
+				for x := 0; x < l.QR.Size; x++ {
+					if !l.QR.Black(x, y) {
+						continue
+					}
+					run := 1
+					for l.QR.Black(x+run, y) {
+						run++
+					}
+					fmt.Fprintf(b, "%s %s %s %s re\n",
+						num(x0+float64(x)*module), num(y0-float64(y+1)*module),
+						num(float64(run)*module), num(module))
+					x += run - 1
+				}
+			}
+			b.WriteString("f\n")
+
+			text.x += side
+			text.w -= side
+		}
+
+		// the title is half again as big as the rest, like the png
+		lines := float64(len(l.Lines))
+		body := text.h / (lines*1.2 + 1.5*1.2)
+		title := min(body*1.5, text.w/(helveticaWidth*float64(max(1, len([]rune(l.Title))))))
+
+		y := text.y + text.h - title
+		writeText(b, text.x, y, title, pdfFit(l.Title, text.w, title))
+		for _, line := range l.Lines {
+			y -= body * 1.2
+			if y < text.y {
+				break
+			}
+			writeText(b, text.x, y, body, pdfFit(line, text.w, body))
+		}
+	}
+	return b.Bytes()
+}
+
+func writeText(b *bytes.Buffer, x, y, size float64, s string) {
+	if s == "" || size <= 0 {
+		return
+	}
+	fmt.Fprintf(b, "BT /F1 %s Tf %s %s Td (%s) Tj ET\n", num(size), num(x), num(y), pdfString(s))
+}
+
+// pdfFit cuts s short, with an ellipsis, until it's about no wider than
+// width at size
+func pdfFit(s string, width, size float64) string {
+	runes := []rune(s)
+	// a little slack, so a title sized to fit exactly isn't cut short by
+	// rounding
+	most := int(width/(helveticaWidth*size) + 1e-9)
+	if len(runes) <= most {
+		return s
+	} else if most < 3 {
+		return ""
+	}
+	return string(runes[:most-2]) + ".."
+}
+
+// pdfString escapes s for a literal string in WinAnsi, which covers ascii
+// and latin-1; anything else is a ?
+func pdfString(s string) string {
+	b := &strings.Builder{}
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= ' ' && r <= '~':
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// num is a number the way pdf likes them, without exponents or more
+// precision than anyone can see
+func num(f float64) string {
+	s := strings.TrimRight(fmt.Sprintf("%.3f", f), "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+func deflate(data []byte) ([]byte, error) {
+	b := &bytes.Buffer{}
+	zw := zlib.NewWriter(b)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	} else if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// pdfWriter keeps track of where each object starts, for the cross-reference
+// table at the end; the first error sticks
+type pdfWriter struct {
+	w       *bufio.Writer
+	n       int
+	offsets []int
+	err     error
+}
+
+func (pw *pdfWriter) printf(format string, args ...any) {
+	if pw.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(pw.w, format, args...)
+	pw.n += n
+	pw.err = err
+}
+
+func (pw *pdfWriter) write(data []byte) {
+	if pw.err != nil {
+		return
+	}
+	n, err := pw.w.Write(data)
+	pw.n += n
+	pw.err = err
+}
+
+// object has to be called in order, starting from 1
+func (pw *pdfWriter) object(id int, body string) {
+	pw.offsets = append(pw.offsets, pw.n)
+	pw.printf("%d 0 obj\n%s\nendobj\n", id, body)
+}
+
+func (pw *pdfWriter) stream(id int, data []byte) {
+	pw.offsets = append(pw.offsets, pw.n)
+	pw.printf("%d 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n", id, len(data))
+	pw.write(data)
+	pw.printf("\nendstream\nendobj\n")
+}
+
+func (pw *pdfWriter) trailer() {
+	xref := pw.n
+	pw.printf("xref\n0 %d\n0000000000 65535 f \n", len(pw.offsets)+1)
+	for _, off := range pw.offsets {
+		pw.printf("%010d 00000 n \n", off)
+	}
+	pw.printf("trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(pw.offsets)+1, xref)
+	if pw.err == nil {
+		pw.err = pw.w.Flush()
+	}
+}
@@ -0,0 +1,238 @@
+package qr
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// read undoes everything Encode does, using nothing but the modules and the
+// spec's tables: it finds the level and mask from the format bits, unmasks,
+// reads the codewords back out, checks every block's error correction and
+// returns the data
+func read(t *testing.T, c *Code) []byte {
+	t.Helper()
+
+	format := 0
+	for i := 0; i <= 5; i++ {
+		format |= b2i(c.Black(8, i)) << i
+	}
+	format |= b2i(c.Black(8, 7))<<6 | b2i(c.Black(8, 8))<<7 | b2i(c.Black(7, 8))<<8
+	for i := 9; i < 15; i++ {
+		format |= b2i(c.Black(14-i, 8)) << i
+	}
+
+	// and the other copy says the same thing
+	other := 0
+	for i := 0; i < 8; i++ {
+		other |= b2i(c.Black(c.Size-1-i, 8)) << i
+	}
+	for i := 8; i < 15; i++ {
+		other |= b2i(c.Black(8, c.Size-15+i)) << i
+	}
+	require.Equal(t, format, other)
+	require.Equal(t, formatInfo(c.Level, c.Mask), format)
+
+	// a fresh code of the same version knows where the patterns are
+	fresh := newCode(c.Version, c.Level)
+	fresh.drawFunctionPatterns()
+
+	raw := make([]byte, rawDataModules(c.Version)/8)
+	i := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < c.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x, y := right-j, vert
+				if (right+1)&2 == 0 {
+					y = c.Size - 1 - vert
+				}
+				if fresh.function[y][x] || i >= len(raw)*8 {
+					continue
+				}
+				if c.Black(x, y) != masked(c.Mask, x, y) {
+					raw[i>>3] |= 0x80 >> (i & 7)
+				}
+				i++
+			}
+		}
+	}
+
+	blocks := eccBlocks[c.Level][c.Version]
+	eccLen := eccPerBlock[c.Level][c.Version]
+	short := blocks - len(raw)%blocks
+	shortLen := len(raw) / blocks
+
+	all := make([][]byte, blocks)
+	k := 0
+	for col := 0; col <= shortLen; col++ {
+		for j := range all {
+			if col == shortLen-eccLen && j < short {
+				continue
+			}
+			all[j] = append(all[j], raw[k])
+			k++
+		}
+	}
+	require.Equal(t, len(raw), k)
+
+	var data []byte
+	for _, block := range all {
+		n := len(block) - eccLen
+		require.Equal(t, block[n:], rsRemainder(block[:n], rsDivisor(eccLen)))
+		data = append(data, block[:n]...)
+	}
+
+	bits := func(from, n int) int {
+		v := 0
+		for i := from; i < from+n; i++ {
+			v = v<<1 | int(data[i/8]>>(7-i%8)&1)
+		}
+		return v
+	}
+	require.Equal(t, 0x4, bits(0, 4))
+	count := bits(4, countBits(c.Version))
+	result := make([]byte, count)
+	for i := range result {
+		result[i] = byte(bits(4+countBits(c.Version)+i*8, 8))
+	}
+	return result
+}
+
+func b2i(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func Test_Encode(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		data    string
+		level   Level
+		version int
+		err     bool
+	}{
+		"empty":         {data: "", level: Medium, version: 1},
+		"short_url":     {data: "https://cffc.local/scan/LC-2026-0142", level: Medium, version: 3},
+		"fills_v1_m":    {data: strings.Repeat("a", 14), level: Medium, version: 1},
+		"spills_to_v2":  {data: strings.Repeat("a", 15), level: Medium, version: 2},
+		"low":           {data: strings.Repeat("b", 17), level: Low, version: 1},
+		"high":          {data: strings.Repeat("c", 98), level: High, version: 9},
+		"spills_to_v10": {data: strings.Repeat("c", 99), level: High, version: 10},
+		"version_info":  {data: strings.Repeat("d", 200), level: Medium, version: 10},
+		"uneven_blocks": {
+			data:    strings.Repeat("e", 292),
+			level:   Quartile,
+			version: 15,
+		},
+		"binary":    {data: "\x00\xff\x80 uuid", level: Medium, version: 1},
+		"too_big":   {data: strings.Repeat("f", 2400), level: High, err: true},
+		"bad_level": {data: "x", level: 7, err: true},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c, err := Encode([]byte(tc.data), tc.level)
+			if tc.err {
+				require.NotNil(t, err)
+				return
+			}
+			require.Nil(t, err)
+			require.Equal(t, tc.version, c.Version)
+			require.Equal(t, tc.version*4+17, c.Size)
+			require.Equal(t, []byte(tc.data), read(t, c))
+
+			// every finder is where it should be
+			for _, corner := range [][2]int{{0, 0}, {c.Size - 7, 0}, {0, c.Size - 7}} {
+				for i := 0; i < 7; i++ {
+					require.True(t, c.Black(corner[0]+i, corner[1]))
+					require.True(t, c.Black(corner[0], corner[1]+i))
+				}
+				require.False(t, c.Black(corner[0]+1, corner[1]+1))
+				require.True(t, c.Black(corner[0]+3, corner[1]+3))
+			}
+		})
+	}
+}
+
+func Test_rsRemainder(t *testing.T) {
+	t.Parallel()
+
+	// HELLO WORLD as 1-M, from the spec's worked example
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	require.Equal(t,
+		[]byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23},
+		rsRemainder(data, rsDivisor(10)))
+}
+
+func Test_formatInfo(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		level  Level
+		mask   int
+		result int
+	}{
+		"low_0":      {level: Low, mask: 0, result: 0b111011111000100},
+		"medium_0":   {level: Medium, mask: 0, result: 0b101010000010010},
+		"medium_5":   {level: Medium, mask: 5, result: 0b100000011001110},
+		"quartile_7": {level: Quartile, mask: 7, result: 0b010101111101101},
+		"high_3":     {level: High, mask: 3, result: 0b001100111010000},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tc.result, formatInfo(tc.level, tc.mask))
+		})
+	}
+}
+
+func Test_versionInfo(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, 0x07c94, versionInfo(7))
+	require.Equal(t, 0x085bc, versionInfo(8))
+	require.Equal(t, 0x28c69, versionInfo(40))
+}
+
+func Test_alignmentPositions(t *testing.T) {
+	t.Parallel()
+
+	require.Nil(t, alignmentPositions(1))
+	require.Equal(t, []int{6, 18}, alignmentPositions(2))
+	require.Equal(t, []int{6, 22, 38}, alignmentPositions(7))
+	require.Equal(t, []int{6, 34, 60, 86, 112, 138}, alignmentPositions(32))
+	require.Equal(t, []int{6, 30, 58, 86, 114, 142, 170}, alignmentPositions(40))
+}
+
+func Test_Image(t *testing.T) {
+	t.Parallel()
+
+	c, err := Encode([]byte("x"), Medium)
+	require.Nil(t, err)
+
+	img := c.Image(3)
+	side := (c.Size + 2*QuietZone) * 3
+	require.Equal(t, side, img.Bounds().Dx())
+	require.Equal(t, side, img.Bounds().Dy())
+
+	for y := 0; y < side; y++ {
+		for x := 0; x < side; x++ {
+			want := c.Black(x/3-QuietZone, y/3-QuietZone)
+			require.Equal(t, want, img.ColorIndexAt(x, y) == 1, "%d,%d", x, y)
+		}
+	}
+	require.False(t, bytes.ContainsRune(img.Pix[:side*3*QuietZone], 1))
+}
@@ -1,6 +1,9 @@
-package main
+package router
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
@@ -17,6 +20,15 @@ func loginRedirect(w http.ResponseWriter, _ *http.Request) {
 	w.WriteHeader(http.StatusForbidden)
 }
 
+// actor is who a request came from, as far as events and idempotency keys
+// are concerned; userservice only says whether a session is valid, not whose
+// it is, so it's the session, hashed, since the cookie itself is as good as
+// a password
+func actor(c *http.Cookie) string {
+	sum := sha256.Sum256([]byte(c.Value))
+	return "us-authn:" + hex.EncodeToString(sum[:8])
+}
+
 func authn(host string, port uint16) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -43,13 +55,15 @@ func authn(host string, port uint16) func(next http.Handler) http.Handler {
 				// 	}
 				// }
 				http.SetCookie(w, newc)
-				next.ServeHTTP(w, r)
+				next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), metrics.Actor, actor(c))))
 			}
 		})
 	}
 }
 
-func newHuautla(cfg *config.Config, ha *huautla.HuautlaAdaptor, l *logrus.Entry) *chi.Mux {
+// NewHuautla builds the router for every ingress, so http, kafka and anything
+// else all get the same routes and middleware
+func NewHuautla(cfg *config.Config, ha *huautla.HuautlaAdaptor, l *logrus.Entry) *chi.Mux {
 	l = l.WithField("database", "huautla")
 	r := chi.NewRouter()
 
@@ -59,6 +73,8 @@ func newHuautla(cfg *config.Config, ha *huautla.HuautlaAdaptor, l *logrus.Entry)
 		r.Use(authn(cfg.AuthnHost, cfg.AuthnPort))
 	}
 
+	r.Use(ha.Idempotent)
+
 	r.Get("/vendors", ha.GetAllVendors)
 	r.Get("/vendor/{id}", ha.GetVendor)
 	r.Post("/vendor", ha.PostVendor)
@@ -92,6 +108,8 @@ func newHuautla(cfg *config.Config, ha *huautla.HuautlaAdaptor, l *logrus.Entry)
 	r.Post("/substrate/{id}/ingredients", ha.PostSubstrateIngredient)
 	r.Patch("/substrate/{su_id}/ingredients/{ig_id}", ha.PatchSubstrateIngredient)
 	r.Delete("/substrate/{su_id}/ingredients/{ig_id}", ha.DeleteSubstrateIngredient)
+	r.Get("/substrate/{id}/colonization", ha.GetColonizationProfile)
+	r.Patch("/substrate/{id}/colonization", ha.PatchColonizationProfile)
 
 	r.Get("/strains", ha.GetAllStrains)
 	r.Get("/strain/{id}", ha.GetStrain)
@@ -113,11 +131,17 @@ func newHuautla(cfg *config.Config, ha *huautla.HuautlaAdaptor, l *logrus.Entry)
 	r.Post("/lifecycle", ha.PostLifecycle)
 	r.Patch("/lifecycle/{id}", ha.PatchLifecycle)
 	r.Delete("/lifecycle/{id}", ha.DeleteLifecycle)
+	r.Get("/lifecycle/{id}/timelapse", ha.GetLifecycleTimelapse)
+	r.Post("/lifecycle/{id}/split", ha.PostLifecycleSplit)
+	r.Post("/lifecycles/merge", ha.PostLifecycleMerge)
+	r.Get("/lifecycle/{id}/lineage", ha.GetLifecycleLineage)
 
 	r.Post("/lifecycle/{id}/events", ha.PostLifecycleEvent)
 	r.Patch("/lifecycle/{lc_id}/events", ha.PatchLifecycleEvent)
 	r.Delete("/lifecycle/{lc_id}/events/{ev_id}", ha.DeleteLifecycleEvent)
 
+	r.Post("/events/bulk", ha.PostBulkEvents)
+
 	r.Get("/generations", ha.GetGenerationIndex)
 	r.Get("/generation/{id}", ha.GetGeneration)
 	r.Post("/generation", ha.PostGeneration)
@@ -129,6 +153,8 @@ func newHuautla(cfg *config.Config, ha *huautla.HuautlaAdaptor, l *logrus.Entry)
 	r.Patch("/generation/{id}/events", ha.PatchGenerationEvent)
 	r.Delete("/generation/{g_id}/events/{ev_id}", ha.DeleteGenerationEvent)
 
+	r.Post("/generation/{id}/lifecycles", ha.PostGenerationLifecycles)
+
 	r.Post("/generation/{id}/sources/{origin}", ha.PostSource)
 	r.Patch("/generation/{g_id}/sources/{origin}/{s_id}", ha.PatchSource)
 	r.Delete("/generation/{g_id}/sources/{s_id}", ha.DeleteSource)
@@ -142,6 +168,30 @@ func newHuautla(cfg *config.Config, ha *huautla.HuautlaAdaptor, l *logrus.Entry)
 	r.Post("/photos/{o_id}", ha.PostPhoto)
 	r.Patch("/photos/{o_id}/{id}", ha.PatchPhoto)
 	r.Delete("/photos/{o_id}/{id}", ha.DeletePhoto)
+	r.Get("/admin/photos/duplicates", ha.GetDuplicatePhotos)
+
+	r.Get("/attachments/{o_id}", ha.GetAttachments)
+	r.Get("/attachments/{o_id}/{id}", ha.GetAttachment)
+	r.Post("/attachments/{o_id}", ha.PostAttachment)
+	r.Patch("/attachments/{o_id}/{id}", ha.PatchAttachment)
+	r.Delete("/attachments/{o_id}/{id}", ha.DeleteAttachment)
+
+	r.Post("/batch", ha.PostBatch(r))
+
+	r.Get("/resolve/{code}", ha.GetResolve)
+	r.Get("/labels", ha.GetLabels)
+	r.Get("/scan/{payload}", ha.GetScan)
+
+	r.Get("/stream", ha.GetStream)
+
+	r.Get("/webhooks", ha.GetWebhooks)
+	r.Post("/webhooks", ha.PostWebhook)
+	r.Get("/webhooks/deadletters", ha.GetDeadLetters)
+	r.Get("/webhooks/{id}", ha.GetWebhook)
+	r.Patch("/webhooks/{id}", ha.PatchWebhook)
+	r.Delete("/webhooks/{id}", ha.DeleteWebhook)
+	r.Get("/webhooks/{id}/deliveries", ha.GetWebhookDeliveries)
+	r.Post("/webhooks/{id}/deliveries/{d_id}/redeliver", ha.PostRedelivery)
 
 	r.Get("/reports/lifecycle/{id}", ha.GetLifecycleReport)
 	r.Get("/reports/generation/{id}", ha.GetGenerationReport)
@@ -153,6 +203,13 @@ func newHuautla(cfg *config.Config, ha *huautla.HuautlaAdaptor, l *logrus.Entry)
 	r.Patch("/ts/{table}/{id}", ha.PatchTS)
 	r.Patch("/undel/{table}/{id}", ha.Undel)
 
+	r.Get("/admin/export", ha.GetExport)
+	r.Post("/admin/import", ha.PostImport)
+	r.Post("/import/csv", ha.PostCSVImport)
+	r.Get("/admin/backups", ha.GetBackups)
+	r.Post("/admin/backups", ha.PostBackup)
+	r.Post("/admin/backups/{name}/verify", ha.PostVerifyBackup)
+
 	r.Get("/metrics", metrics.NewHandler())
 
 	return r
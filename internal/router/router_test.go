@@ -1,4 +1,4 @@
-package main
+package router
 
 import (
 	"context"
@@ -15,9 +15,13 @@ import (
 	"github.com/jsmit257/centerforfunguscontrol/shared/metrics"
 )
 
-type mockHandler struct{}
+type mockHandler struct {
+	actor string
+}
 
-func (mh *mockHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {}
+func (mh *mockHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	mh.actor = metrics.GetContextActor(r.Context())
+}
 
 func Test_authn(t *testing.T) {
 
@@ -29,6 +33,7 @@ func Test_authn(t *testing.T) {
 		err      error
 		pad      string // ought to be used in responses.Header["Authn-Pad"]
 		sc       int
+		actor    string
 	}{
 		"happy_path": {
 			host: "Test_authn",
@@ -45,7 +50,8 @@ func Test_authn(t *testing.T) {
 				},
 			},
 			// pad: "123",
-			sc: http.StatusFound,
+			sc:    http.StatusFound,
+			actor: actor(&http.Cookie{Value: "hmmm"}),
 		},
 		"missing_uri": {
 			host:   "Test_authn",
@@ -115,14 +121,18 @@ func Test_authn(t *testing.T) {
 			handler.ServeHTTP(w, r)
 
 			require.Equal(t, tc.pad, w.Header().Get("Authn-Pad"))
+			if tc.actor != "" {
+				require.Equal(t, tc.actor, mh.actor)
+				require.NotContains(t, mh.actor, tc.cookie.Value)
+			}
 		})
 	}
 }
 
-func Test_newHuautla(t *testing.T) {
+func Test_NewHuautla(t *testing.T) {
 	// TODO: give it a whirl
-	newHuautla(&config.Config{
-		AuthnHost: "Test_newHuautla",
+	NewHuautla(&config.Config{
+		AuthnHost: "Test_NewHuautla",
 		AuthnPort: 12000,
-	}, nil, logrus.WithField("test", "Test_newHuautla"))
+	}, nil, logrus.WithField("test", "Test_NewHuautla"))
 }
@@ -0,0 +1,246 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Store persists the little bits of state cffc owns that don't belong in
+// the huautla schema (photo metadata, attachment indexes, etc); values are
+// grouped by table and addressed by key, and are always marshaled as JSON
+type (
+	Store interface {
+		Get(ctx context.Context, table, key string, v any) error
+		Put(ctx context.Context, table, key string, v any) error
+		Delete(ctx context.Context, table, key string) error
+		Keys(ctx context.Context, table string) ([]string, error)
+		// Lock keeps everyone else out of table, including other processes
+		// sharing the same directory, until unlock is called; it's for
+		// reading something and writing what it led to, like the next number
+		// in a sequence
+		Lock(ctx context.Context, table string) (unlock func(), err error)
+	}
+
+	fileStore struct {
+		mtx   sync.RWMutex
+		dir   string
+		locks tableLocks
+	}
+
+	memStore struct {
+		mtx    sync.RWMutex
+		tables map[string]map[string][]byte
+		locks  tableLocks
+	}
+
+	// tableLocks is the in-process half of Lock
+	tableLocks struct {
+		mtx   sync.Mutex
+		locks map[string]*sync.Mutex
+	}
+)
+
+var ErrNotFound = errors.New("key not found")
+
+// NewFile returns a Store that keeps one JSON file per key in a directory
+// per table, rooted at dir
+func NewFile(dir string) (Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &fileStore{dir: dir}, nil
+}
+
+// NewMem returns a Store that forgets everything when the process exits
+func NewMem() Store {
+	return &memStore{tables: map[string]map[string][]byte{}}
+}
+
+func (fs *fileStore) path(table, key string) string {
+	return filepath.Join(fs.dir, url.PathEscape(table), url.PathEscape(key)+".json")
+}
+
+func (fs *fileStore) Get(_ context.Context, table, key string, v any) error {
+	fs.mtx.RLock()
+	defer fs.mtx.RUnlock()
+
+	if b, err := os.ReadFile(fs.path(table, key)); errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w: %s/%s", ErrNotFound, table, key)
+	} else if err != nil {
+		return err
+	} else {
+		return json.Unmarshal(b, v)
+	}
+}
+
+func (fs *fileStore) Put(_ context.Context, table, key string, v any) error {
+	fs.mtx.Lock()
+	defer fs.mtx.Unlock()
+
+	path := fs.path(table, key)
+	if b, err := json.Marshal(v); err != nil {
+		return err
+	} else if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	} else {
+		return replace(path, b)
+	}
+}
+
+// replace writes b next to path and renames it into place; the temp file has
+// a name of its own, so another process writing the same key doesn't write
+// over it halfway through
+func replace(path string, b []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // there's nothing to remove after the rename
+
+	if _, err = tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	} else if err = tmp.Close(); err != nil {
+		return err
+	} else if err = os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (fs *fileStore) Delete(_ context.Context, table, key string) error {
+	fs.mtx.Lock()
+	defer fs.mtx.Unlock()
+
+	if err := os.Remove(fs.path(table, key)); errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w: %s/%s", ErrNotFound, table, key)
+	} else {
+		return err
+	}
+}
+
+func (fs *fileStore) Keys(_ context.Context, table string) ([]string, error) {
+	fs.mtx.RLock()
+	defer fs.mtx.RUnlock()
+
+	entries, err := os.ReadDir(filepath.Join(fs.dir, url.PathEscape(table)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	result := make([]string, 0, len(entries))
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok || e.IsDir() {
+			continue
+		} else if key, err := url.PathUnescape(name); err == nil {
+			result = append(result, key)
+		}
+	}
+	sort.Strings(result)
+
+	return result, nil
+}
+
+func (fs *fileStore) Lock(_ context.Context, table string) (func(), error) {
+	unlock := fs.locks.lock(table)
+
+	dir := filepath.Join(fs.dir, url.PathEscape(table))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		unlock()
+		return nil, err
+	}
+
+	// the lock file doesn't end in .json, so it's never one of the keys
+	unlockFile, err := lockFile(filepath.Join(dir, ".lock"))
+	if err != nil {
+		unlock()
+		return nil, err
+	}
+
+	return func() {
+		unlockFile()
+		unlock()
+	}, nil
+}
+
+func (ms *memStore) Get(_ context.Context, table, key string, v any) error {
+	ms.mtx.RLock()
+	defer ms.mtx.RUnlock()
+
+	if b, ok := ms.tables[table][key]; !ok {
+		return fmt.Errorf("%w: %s/%s", ErrNotFound, table, key)
+	} else {
+		return json.Unmarshal(b, v)
+	}
+}
+
+func (ms *memStore) Put(_ context.Context, table, key string, v any) error {
+	ms.mtx.Lock()
+	defer ms.mtx.Unlock()
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	} else if _, ok := ms.tables[table]; !ok {
+		ms.tables[table] = map[string][]byte{}
+	}
+	ms.tables[table][key] = b
+
+	return nil
+}
+
+func (ms *memStore) Delete(_ context.Context, table, key string) error {
+	ms.mtx.Lock()
+	defer ms.mtx.Unlock()
+
+	if _, ok := ms.tables[table][key]; !ok {
+		return fmt.Errorf("%w: %s/%s", ErrNotFound, table, key)
+	}
+	delete(ms.tables[table], key)
+
+	return nil
+}
+
+func (ms *memStore) Keys(_ context.Context, table string) ([]string, error) {
+	ms.mtx.RLock()
+	defer ms.mtx.RUnlock()
+
+	result := make([]string, 0, len(ms.tables[table]))
+	for k := range ms.tables[table] {
+		result = append(result, k)
+	}
+	sort.Strings(result)
+
+	return result, nil
+}
+
+func (ms *memStore) Lock(_ context.Context, table string) (func(), error) {
+	return ms.locks.lock(table), nil
+}
+
+func (tl *tableLocks) lock(table string) func() {
+	tl.mtx.Lock()
+	if tl.locks == nil {
+		tl.locks = map[string]*sync.Mutex{}
+	}
+	l, ok := tl.locks[table]
+	if !ok {
+		l = &sync.Mutex{}
+		tl.locks[table] = l
+	}
+	tl.mtx.Unlock()
+
+	l.Lock()
+	return l.Unlock
+}
@@ -0,0 +1,123 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type record struct {
+	Name string `json:"name"`
+}
+
+func Test_Store(t *testing.T) {
+	t.Parallel()
+
+	file, err := NewFile(t.TempDir())
+	require.Nil(t, err)
+
+	tcs := map[string]struct {
+		s Store
+	}{
+		"file": {s: file},
+		"mem":  {s: NewMem()},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			var r record
+
+			require.ErrorIs(t, tc.s.Get(ctx, "table", "missing", &r), ErrNotFound)
+			require.ErrorIs(t, tc.s.Delete(ctx, "table", "missing"), ErrNotFound)
+
+			keys, err := tc.s.Keys(ctx, "empty")
+			require.Nil(t, err)
+			require.Empty(t, keys)
+
+			require.Nil(t, tc.s.Put(ctx, "table", "b/2", record{Name: name}))
+			require.Nil(t, tc.s.Put(ctx, "table", "a 1", record{Name: "first"}))
+			require.Nil(t, tc.s.Get(ctx, "table", "b/2", &r))
+			require.Equal(t, record{Name: name}, r)
+
+			keys, err = tc.s.Keys(ctx, "table")
+			require.Nil(t, err)
+			require.Equal(t, []string{"a 1", "b/2"}, keys)
+
+			require.Nil(t, tc.s.Delete(ctx, "table", "a 1"))
+			keys, err = tc.s.Keys(ctx, "table")
+			require.Nil(t, err)
+			require.Equal(t, []string{"b/2"}, keys)
+		})
+	}
+}
+
+func Test_Lock(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	first, err := NewFile(dir)
+	require.Nil(t, err)
+	second, err := NewFile(dir)
+	require.Nil(t, err)
+
+	tcs := map[string]struct {
+		a, b Store
+	}{
+		"file_same_store": {a: first, b: first},
+		// like two processes sharing a directory
+		"file_same_dir": {a: first, b: second},
+		"mem":           {a: NewMem()},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.b == nil {
+				tc.b = tc.a
+			}
+
+			ctx := context.Background()
+			table := "lock_" + name
+
+			unlock, err := tc.a.Lock(ctx, table)
+			require.Nil(t, err)
+
+			locked := make(chan func())
+			go func() {
+				unlock, err := tc.b.Lock(ctx, table)
+				require.Nil(t, err)
+				locked <- unlock
+			}()
+
+			select {
+			case <-locked:
+				require.Fail(t, "got the lock while it was held")
+			case <-time.After(50 * time.Millisecond):
+			}
+
+			unlock()
+			select {
+			case unlock := <-locked:
+				unlock()
+			case <-time.After(time.Second):
+				require.Fail(t, "didn't get the lock after it was released")
+			}
+
+			// other tables aren't held up, and the lock file isn't a key
+			unlock, err = tc.a.Lock(ctx, "other_"+name)
+			require.Nil(t, err)
+			defer unlock()
+			keys, err := tc.a.Keys(ctx, table)
+			require.Nil(t, err)
+			require.Empty(t, keys)
+		})
+	}
+}
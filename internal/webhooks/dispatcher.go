@@ -0,0 +1,507 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/cipher"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/events"
+	"github.com/jsmit257/centerforfunguscontrol/internal/store"
+	"github.com/jsmit257/huautla/types"
+)
+
+type (
+	Config struct {
+		// MaxAttempts is how many times a delivery is tried before it's dead
+		MaxAttempts int
+		// Backoff is how long to wait after the first failure; it doubles
+		// after each one after that, up to MaxBackoff
+		Backoff    time.Duration
+		MaxBackoff time.Duration
+		Timeout    time.Duration
+		// Poll is how often to look for new events and deliveries that are due
+		Poll time.Duration
+		// Secrets encrypts hooks' secrets in the store; they're kept in plain
+		// text if it's nil
+		Secrets cipher.AEAD
+		// AllowLocal lets hooks post to loopback and link-local addresses,
+		// which are otherwise off limits, so nobody can use a hook to reach
+		// what only the server can, like a cloud's metadata service
+		AllowLocal bool
+	}
+
+	// Dispatcher turns events from the outbox into deliveries, and makes them
+	Dispatcher struct {
+		cfg    Config
+		store  store.Store
+		outbox *events.Outbox
+		client *http.Client
+		log    *logrus.Entry
+		now    func() time.Time
+
+		// mtx keeps the background loop and the api from updating the same
+		// delivery at the same time
+		mtx  sync.Mutex
+		wake chan struct{}
+
+		// busy has every hook whose worker is still delivering
+		busyMtx sync.Mutex
+		busy    map[types.UUID]bool
+		workers sync.WaitGroup
+	}
+)
+
+const (
+	hookTable     = "webhooks"
+	deliveryTable = "webhook_deliveries"
+	// queueTable has a key for every pending delivery, so we don't have to
+	// look through the whole history to find them
+	queueTable = "webhook_queue"
+	// deadTable does the same for dead letters
+	deadTable   = "webhook_dead"
+	cursorTable = "webhook_cursor"
+	cursorKey   = "seq"
+)
+
+// hookDeliveryTable has a key for every delivery to a hook, so its history doesn't
+// mean reading everybody's
+func hookDeliveryTable(id types.UUID) string {
+	return deliveryTable + "_" + string(id)
+}
+
+func NewDispatcher(s store.Store, o *events.Outbox, cfg Config, log *logrus.Entry) *Dispatcher {
+	client := &http.Client{Timeout: cfg.Timeout}
+	if !cfg.AllowLocal {
+		// a name can resolve to anything, so where it's allowed to go is
+		// checked again when it's dialed
+		dialer := &net.Dialer{Timeout: cfg.Timeout, Control: refuseLocal}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.DialContext = dialer.DialContext
+		client.Transport = transport
+	}
+
+	return &Dispatcher{
+		cfg:    cfg,
+		store:  s,
+		outbox: o,
+		client: client,
+		log:    log.WithField("component", "webhooks"),
+		now:    time.Now,
+		wake:   make(chan struct{}, 1),
+		busy:   map[types.UUID]bool{},
+	}
+}
+
+func (d *Dispatcher) Hooks(ctx context.Context) ([]Hook, error) {
+	keys, err := d.store.Keys(ctx, hookTable)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Hook, 0, len(keys))
+	for _, k := range keys {
+		if h, err := d.Hook(ctx, types.UUID(k)); errors.Is(err, ErrNotFound) {
+			// deleted since we listed the keys
+		} else if err != nil {
+			return nil, err
+		} else {
+			result = append(result, h)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].CTime.Before(result[j].CTime) })
+
+	return result, nil
+}
+
+func (d *Dispatcher) Hook(ctx context.Context, id types.UUID) (Hook, error) {
+	var result Hook
+	if err := d.store.Get(ctx, hookTable, string(id), &result); err != nil {
+		return result, err
+	}
+	return d.openSecret(result)
+}
+
+// PutHook validates h and saves it, filling in its id, secret and ctime if
+// it's new
+func (d *Dispatcher) PutHook(ctx context.Context, h Hook) (Hook, error) {
+	if err := h.Validate(); err != nil {
+		return h, err
+	} else if err = h.local(); err != nil && !d.cfg.AllowLocal {
+		return h, err
+	} else if h.CTime.IsZero() {
+		h.CTime = d.now().UTC()
+	}
+	return h, d.putHook(ctx, h)
+}
+
+func (d *Dispatcher) putHook(ctx context.Context, h Hook) error {
+	sealed, err := d.sealSecret(h)
+	if err != nil {
+		return err
+	}
+	return d.store.Put(ctx, hookTable, string(h.UUID), sealed)
+}
+
+// DeleteHook stops future deliveries; the history is kept, and anything still
+// pending is abandoned the next time it's due
+func (d *Dispatcher) DeleteHook(ctx context.Context, id types.UUID) error {
+	return d.store.Delete(ctx, hookTable, string(id))
+}
+
+// Deliveries returns the history for a hook, or every hook if id is empty,
+// oldest first, optionally only those in the given state
+func (d *Dispatcher) Deliveries(ctx context.Context, id types.UUID, state State) ([]Delivery, error) {
+	table := deliveryTable
+	if id != "" {
+		table = hookDeliveryTable(id)
+	} else if state == Pending {
+		table = queueTable
+	} else if state == Dead {
+		table = deadTable
+	}
+
+	keys, err := d.store.Keys(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []Delivery{}
+	for _, k := range keys {
+		if dl, err := d.Delivery(ctx, k); errors.Is(err, ErrNotFound) {
+		} else if err != nil {
+			return nil, err
+		} else if id != "" && dl.Hook != id {
+		} else if state != "" && dl.State != state {
+		} else {
+			result = append(result, dl)
+		}
+	}
+
+	return result, nil
+}
+
+func (d *Dispatcher) Delivery(ctx context.Context, id string) (Delivery, error) {
+	var result Delivery
+	return result, d.store.Get(ctx, deliveryTable, id, &result)
+}
+
+// Redeliver queues a delivery to be tried again right away, whatever state
+// it's in; it gets a full set of tries, and keeps its history
+func (d *Dispatcher) Redeliver(ctx context.Context, id string) (Delivery, error) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	dl, err := d.Delivery(ctx, id)
+	if err != nil {
+		return dl, err
+	}
+
+	dl.State, dl.Tries, dl.Next = Pending, 0, d.now().UTC()
+	if err = d.save(ctx, dl); err == nil {
+		d.poke()
+	}
+
+	return dl, err
+}
+
+// Run delivers until ctx is done, and then waits for deliveries that are
+// already under way
+func (d *Dispatcher) Run(ctx context.Context) {
+	t := time.NewTicker(d.cfg.Poll)
+	defer t.Stop()
+	defer d.workers.Wait()
+
+	for {
+		if err := d.tick(ctx); err != nil {
+			d.log.WithError(err).Error("failed to deliver webhooks")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		case <-d.wake:
+		}
+	}
+}
+
+func (d *Dispatcher) poke() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (d *Dispatcher) tick(ctx context.Context) error {
+	if err := d.enqueue(ctx); err != nil {
+		return err
+	}
+	return d.deliverDue(ctx)
+}
+
+// enqueue makes a delivery for every hook that wants each event published
+// since the last time; deliveries are keyed by event and hook, so a crash
+// before the cursor is saved doesn't deliver anything twice
+func (d *Dispatcher) enqueue(ctx context.Context) error {
+	cursor, err := d.Cursor(ctx)
+	if err != nil {
+		return err
+	}
+
+	evs, err := d.outbox.Since(ctx, cursor, 0)
+	if err != nil || len(evs) == 0 {
+		return err
+	}
+
+	hooks, err := d.Hooks(ctx)
+	if err != nil {
+		return err
+	}
+
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	for _, e := range evs {
+		for _, h := range hooks {
+			if !h.Matches(e) {
+				continue
+			}
+
+			dl := Delivery{
+				ID:       fmt.Sprintf("%020d-%s", e.Seq, h.UUID),
+				Hook:     h.UUID,
+				Event:    e,
+				State:    Pending,
+				Attempts: []Attempt{},
+				Next:     d.now().UTC(),
+			}
+			if _, err := d.Delivery(ctx, dl.ID); err == nil {
+				continue
+			} else if !errors.Is(err, ErrNotFound) {
+				return err
+			} else if err = d.save(ctx, dl); err != nil {
+				return err
+			}
+		}
+
+		if err := d.store.Put(ctx, cursorTable, cursorKey, e.Seq); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Cursor is the Seq of the newest event that's been turned into deliveries,
+// so the outbox doesn't need anything up to it for webhooks' sake
+func (d *Dispatcher) Cursor(ctx context.Context) (uint64, error) {
+	var result uint64
+	if err := d.store.Get(ctx, cursorTable, cursorKey, &result); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return 0, err
+	}
+	return result, nil
+}
+
+// deliverDue starts delivering whatever is due; every hook gets a worker of
+// its own, so one that's slow to answer only holds up its own deliveries,
+// which still go out in order: nothing goes to a hook while an older
+// delivery to it is waiting to be retried. a hook whose worker is still busy
+// from last time is left to it
+func (d *Dispatcher) deliverDue(ctx context.Context) error {
+	// keys start with the event's number, so they're oldest first
+	keys, err := d.store.Keys(ctx, queueTable)
+	if err != nil {
+		return err
+	}
+
+	due := map[types.UUID][]Delivery{}
+	waiting := map[types.UUID]bool{}
+	for _, k := range keys {
+		if ctx.Err() != nil {
+			return nil
+		} else if dl, err := d.Delivery(ctx, k); errors.Is(err, ErrNotFound) {
+			_ = d.store.Delete(ctx, queueTable, k)
+		} else if err != nil {
+			return err
+		} else if dl.State != Pending || waiting[dl.Hook] {
+			continue
+		} else if dl.Next.After(d.now()) {
+			waiting[dl.Hook] = true
+		} else {
+			due[dl.Hook] = append(due[dl.Hook], dl)
+		}
+	}
+
+	d.busyMtx.Lock()
+	defer d.busyMtx.Unlock()
+
+	for id, dls := range due {
+		if d.busy[id] {
+			continue
+		}
+		d.busy[id] = true
+		d.workers.Add(1)
+		go d.work(ctx, id, dls)
+	}
+
+	return nil
+}
+
+// work makes dls, oldest first, stopping at the first one that has to be
+// retried, or can't be saved
+func (d *Dispatcher) work(ctx context.Context, id types.UUID, dls []Delivery) {
+	defer d.workers.Done()
+	defer func() {
+		d.busyMtx.Lock()
+		defer d.busyMtx.Unlock()
+		delete(d.busy, id)
+	}()
+
+	for _, dl := range dls {
+		if ctx.Err() != nil {
+			return
+		} else if state, err := d.deliver(ctx, dl); err != nil {
+			d.log.WithError(err).WithField("hook", id).Error("failed to deliver webhooks")
+			return
+		} else if state == Pending {
+			return
+		}
+	}
+}
+
+// deliver makes one attempt, saves the outcome and says what state that left
+// the delivery in
+func (d *Dispatcher) deliver(ctx context.Context, dl Delivery) (State, error) {
+	l := d.log.WithFields(logrus.Fields{
+		"delivery": dl.ID,
+		"hook":     dl.Hook,
+		"event":    dl.Event.Type,
+	})
+
+	h, err := d.Hook(ctx, dl.Hook)
+	if errors.Is(err, ErrNotFound) {
+		l.Info("hook was deleted, abandoning delivery")
+		dl.State = Dead
+		dl.Attempts = append(dl.Attempts, Attempt{Time: d.now().UTC(), Error: "webhook was deleted"})
+		dl.Tries++
+		return dl.State, d.locked(ctx, dl)
+	} else if err != nil {
+		return dl.State, err
+	}
+
+	a := d.attempt(ctx, h, dl)
+	dl.Attempts = append(dl.Attempts, a)
+	dl.Tries++
+
+	if a.Error == "" {
+		dl.State = Delivered
+	} else if dl.Tries >= d.cfg.MaxAttempts {
+		l.WithField("tries", dl.Tries).Warn("giving up on delivery")
+		dl.State = Dead
+	} else {
+		dl.Next = a.Time.Add(d.backoff(dl.Tries))
+	}
+
+	return dl.State, d.locked(ctx, dl)
+}
+
+func (d *Dispatcher) backoff(failures int) time.Duration {
+	result := d.cfg.Backoff
+	for i := 1; i < failures && result < d.cfg.MaxBackoff; i++ {
+		result *= 2
+	}
+	return min(result, d.cfg.MaxBackoff)
+}
+
+func (d *Dispatcher) attempt(ctx context.Context, h Hook, dl Delivery) Attempt {
+	result := Attempt{Time: d.now().UTC()}
+
+	body, err := json.Marshal(dl.Event)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	ts := result.Time.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, dl.Event.Type)
+	req.Header.Set(DeliveryHeader, dl.ID)
+	req.Header.Set(TimestampHeader, strconv.FormatInt(ts, 10))
+	req.Header.Set(SignatureHeader, Sign(h.Secret, ts, body))
+	req.Header.Set("Cid", string(dl.Event.Cid))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if result.Status = resp.StatusCode; result.Status < 200 || result.Status > 299 {
+		result.Error = resp.Status
+	}
+
+	return result
+}
+
+func (d *Dispatcher) locked(ctx context.Context, dl Delivery) error {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	// the api may have redelivered it while we were busy; theirs wins
+	if current, err := d.Delivery(ctx, dl.ID); err != nil {
+		return err
+	} else if current.State != Pending || current.Tries != dl.Tries-1 {
+		return nil
+	}
+
+	return d.save(ctx, dl)
+}
+
+// save writes the delivery and keeps the indexes in step with its state
+func (d *Dispatcher) save(ctx context.Context, dl Delivery) error {
+	if err := d.store.Put(ctx, deliveryTable, dl.ID, dl); err != nil {
+		return err
+	}
+	return d.index(ctx, dl)
+}
+
+func (d *Dispatcher) index(ctx context.Context, dl Delivery) error {
+	if err := d.store.Put(ctx, hookDeliveryTable(dl.Hook), dl.ID, dl.State); err != nil {
+		return err
+	} else if err = d.indexIf(ctx, queueTable, dl.State == Pending, dl); err != nil {
+		return err
+	}
+	return d.indexIf(ctx, deadTable, dl.State == Dead, dl)
+}
+
+// indexIf keeps dl in table as long as in is true
+func (d *Dispatcher) indexIf(ctx context.Context, table string, in bool, dl Delivery) error {
+	if in {
+		return d.store.Put(ctx, table, dl.ID, dl.Next)
+	} else if err := d.store.Delete(ctx, table, dl.ID); errors.Is(err, store.ErrNotFound) {
+		return nil
+	} else {
+		return err
+	}
+}
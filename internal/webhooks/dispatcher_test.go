@@ -0,0 +1,484 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/events"
+	"github.com/jsmit257/centerforfunguscontrol/internal/store"
+	"github.com/jsmit257/huautla/types"
+)
+
+// receiver is a webhook endpoint that checks signatures and fails on demand
+type receiver struct {
+	mtx      sync.Mutex
+	secret   string
+	statuses []int
+	got      []events.Event
+}
+
+func (rx *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rx.mtx.Lock()
+	defer rx.mtx.Unlock()
+
+	body, _ := io.ReadAll(r.Body)
+	ts, _ := strconv.ParseInt(r.Header.Get(TimestampHeader), 10, 64)
+	if !Verify(rx.secret, ts, body, r.Header.Get(SignatureHeader)) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	status := http.StatusNoContent
+	if len(rx.statuses) > 0 {
+		status, rx.statuses = rx.statuses[0], rx.statuses[1:]
+	}
+	if status < 300 {
+		var e events.Event
+		_ = json.Unmarshal(body, &e)
+		rx.got = append(rx.got, e)
+	}
+	w.WriteHeader(status)
+}
+
+func (rx *receiver) received() []events.Event {
+	rx.mtx.Lock()
+	defer rx.mtx.Unlock()
+	return rx.got
+}
+
+type fixture struct {
+	d      *Dispatcher
+	outbox *events.Outbox
+	rx     *receiver
+	hook   Hook
+	clock  time.Time
+}
+
+func newFixture(t *testing.T, statuses ...int) *fixture {
+	ctx := context.Background()
+	s := store.NewMem()
+
+	outbox, err := events.NewOutbox(ctx, s)
+	require.Nil(t, err)
+
+	f := &fixture{
+		outbox: outbox,
+		rx:     &receiver{secret: "secret", statuses: statuses},
+		clock:  time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	srv := httptest.NewServer(f.rx)
+	t.Cleanup(srv.Close)
+
+	// the receiver is on localhost
+	f.d = NewDispatcher(s, outbox, Config{
+		MaxAttempts: 3,
+		Backoff:     time.Second,
+		MaxBackoff:  time.Minute,
+		Timeout:     time.Second,
+		Poll:        time.Hour,
+		AllowLocal:  true,
+	}, logrus.WithField("test", t.Name()))
+	f.d.now = func() time.Time { return f.clock }
+
+	f.hook, err = f.d.PutHook(ctx, Hook{
+		URL:    srv.URL,
+		Secret: "secret",
+		Types:  []string{"vendor.*"},
+	})
+	require.Nil(t, err)
+
+	return f
+}
+
+func (f *fixture) publish(t *testing.T, types ...string) {
+	for _, typ := range types {
+		_, err := f.outbox.Append(context.Background(), events.Event{Type: typ, EntityID: "0", Cid: "cid"})
+		require.Nil(t, err)
+	}
+}
+
+func (f *fixture) tick(t *testing.T, after time.Duration) {
+	f.clock = f.clock.Add(after)
+	require.Nil(t, f.d.tick(context.Background()))
+	f.d.workers.Wait()
+}
+
+func Test_Dispatcher_delivers(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	f := newFixture(t)
+	f.publish(t, "vendor.created", "lifecycle.created", "vendor.deleted")
+
+	f.tick(t, 0)
+	require.Equal(t, []string{"vendor.created", "vendor.deleted"}, typesOf(f.rx.received()))
+
+	dls, err := f.d.Deliveries(ctx, f.hook.UUID, "")
+	require.Nil(t, err)
+	require.Len(t, dls, 2)
+	for _, dl := range dls {
+		require.Equal(t, Delivered, dl.State)
+		require.Equal(t, 1, dl.Tries)
+		require.Equal(t, http.StatusNoContent, dl.Attempts[0].Status)
+	}
+
+	// nothing new, nothing delivered twice
+	f.tick(t, time.Hour)
+	require.Len(t, f.rx.received(), 2)
+}
+
+func Test_Dispatcher_retries(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	f := newFixture(t, http.StatusInternalServerError, http.StatusBadGateway)
+	f.publish(t, "vendor.created")
+
+	f.tick(t, 0)
+	dls, err := f.d.Deliveries(ctx, "", Pending)
+	require.Nil(t, err)
+	require.Len(t, dls, 1)
+	require.Equal(t, f.clock.Add(time.Second), dls[0].Next)
+	require.Equal(t, "500 Internal Server Error", dls[0].Attempts[0].Error)
+
+	// not due yet
+	f.tick(t, 500*time.Millisecond)
+	dl, err := f.d.Delivery(ctx, dls[0].ID)
+	require.Nil(t, err)
+	require.Len(t, dl.Attempts, 1)
+
+	// the second failure waits twice as long
+	f.tick(t, 500*time.Millisecond)
+	dl, err = f.d.Delivery(ctx, dls[0].ID)
+	require.Nil(t, err)
+	require.Equal(t, f.clock.Add(2*time.Second), dl.Next)
+
+	f.tick(t, 2*time.Second)
+	dl, err = f.d.Delivery(ctx, dls[0].ID)
+	require.Nil(t, err)
+	require.Equal(t, Delivered, dl.State)
+	require.Equal(t, 3, dl.Tries)
+	require.Len(t, f.rx.received(), 1)
+}
+
+func Test_Dispatcher_deadLetters(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	f := newFixture(t, 500, 500, 500, 500)
+	f.publish(t, "vendor.updated")
+
+	for range 3 {
+		f.tick(t, time.Minute)
+	}
+
+	dead, err := f.d.Deliveries(ctx, "", Dead)
+	require.Nil(t, err)
+	require.Len(t, dead, 1)
+	require.Len(t, dead[0].Attempts, 3)
+	require.Empty(t, f.rx.received())
+
+	// dead letters stay dead...
+	f.tick(t, time.Hour)
+	dl, err := f.d.Delivery(ctx, dead[0].ID)
+	require.Nil(t, err)
+	require.Equal(t, Dead, dl.State)
+
+	// ...until somebody redelivers them, which keeps the history
+	dl, err = f.d.Redeliver(ctx, dead[0].ID)
+	require.Nil(t, err)
+	require.Equal(t, Pending, dl.State)
+	require.Equal(t, 0, dl.Tries)
+
+	f.tick(t, 0) // the last 500
+	f.tick(t, time.Second)
+	dl, err = f.d.Delivery(ctx, dead[0].ID)
+	require.Nil(t, err)
+	require.Equal(t, Delivered, dl.State)
+	require.Len(t, dl.Attempts, 5)
+	require.Equal(t, []string{"vendor.updated"}, typesOf(f.rx.received()))
+
+	_, err = f.d.Redeliver(ctx, "missing")
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func Test_Dispatcher_deletedHook(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	f := newFixture(t, 500)
+	f.publish(t, "vendor.created")
+	f.tick(t, 0)
+
+	require.Nil(t, f.d.DeleteHook(ctx, f.hook.UUID))
+	f.tick(t, time.Minute)
+
+	dls, err := f.d.Deliveries(ctx, f.hook.UUID, Dead)
+	require.Nil(t, err)
+	require.Len(t, dls, 1)
+	require.Equal(t, "webhook was deleted", dls[0].Attempts[1].Error)
+
+	hooks, err := f.d.Hooks(ctx)
+	require.Nil(t, err)
+	require.Empty(t, hooks)
+}
+
+func Test_Dispatcher_Run(t *testing.T) {
+	t.Parallel()
+
+	f := newFixture(t)
+	f.d.now = time.Now
+	f.publish(t, "vendor.created")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		f.d.Run(ctx)
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool {
+		return len(f.rx.received()) == 1
+	}, time.Second, time.Millisecond)
+
+	cancel()
+	<-done
+}
+
+func Test_Dispatcher_slowHook(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	f := newFixture(t)
+
+	// the slow hook answers when it's told to
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(slow.Close)
+	slowHook, err := f.d.PutHook(ctx, Hook{URL: slow.URL, Types: []string{"vendor.*"}})
+	require.Nil(t, err)
+
+	f.publish(t, "vendor.created")
+	require.Nil(t, f.d.tick(ctx))
+	require.Eventually(t, func() bool {
+		return len(f.rx.received()) == 1
+	}, time.Second, time.Millisecond)
+
+	// the slow hook's worker is still going, so it doesn't get another one
+	f.publish(t, "vendor.updated")
+	require.Nil(t, f.d.tick(ctx))
+	require.Eventually(t, func() bool {
+		return len(f.rx.received()) == 2
+	}, time.Second, time.Millisecond)
+
+	close(release)
+	f.d.workers.Wait()
+	f.tick(t, 0)
+
+	dls, err := f.d.Deliveries(ctx, slowHook.UUID, Delivered)
+	require.Nil(t, err)
+	require.Len(t, dls, 2)
+	require.Equal(t, []string{"vendor.created", "vendor.updated"}, typesOf([]events.Event{dls[0].Event, dls[1].Event}))
+}
+
+func Test_Dispatcher_inOrder(t *testing.T) {
+	t.Parallel()
+
+	f := newFixture(t, http.StatusInternalServerError)
+	f.publish(t, "vendor.created", "vendor.updated")
+
+	// the first one failed, so the second waits for it
+	f.tick(t, 0)
+	require.Empty(t, f.rx.received())
+	f.tick(t, 500*time.Millisecond)
+	require.Empty(t, f.rx.received())
+
+	f.tick(t, 500*time.Millisecond)
+	require.Equal(t, []string{"vendor.created", "vendor.updated"}, typesOf(f.rx.received()))
+}
+
+func Test_Dispatcher_local(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	f := newFixture(t)
+	f.d = NewDispatcher(f.d.store, f.outbox, Config{
+		MaxAttempts: 1,
+		Timeout:     time.Second,
+	}, logrus.WithField("test", t.Name()))
+
+	tcs := map[string]struct {
+		url string
+		ok  bool
+	}{
+		"loopback":    {url: "http://127.0.0.1:8080/hook"},
+		"loopback_v6": {url: "http://[::1]/hook"},
+		"localhost":   {url: "http://LocalHost./hook"},
+		"metadata":    {url: "http://169.254.169.254/latest/meta-data"},
+		"unspecified": {url: "http://0.0.0.0/"},
+		"private":     {url: "http://192.168.1.10/hook", ok: true},
+		"somewhere":   {url: "https://example.com/hook", ok: true},
+		"not_an_ip":   {url: "https://10.0.0.256/hook", ok: true},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			_, err := f.d.PutHook(ctx, Hook{URL: tc.url, Types: []string{"*"}})
+			require.Equal(t, tc.ok, err == nil, err)
+			if !tc.ok {
+				require.ErrorIs(t, err, ErrInvalidHook)
+			}
+		})
+	}
+
+	// the fixture's hook was saved when local was allowed, and a name could
+	// resolve to anywhere, so it's refused when it's dialed too
+	t.Run("dialed", func(t *testing.T) {
+		t.Parallel()
+
+		h, err := f.d.Hook(ctx, f.hook.UUID)
+		require.Nil(t, err)
+		a := f.d.attempt(ctx, h, Delivery{ID: "dialed", Event: events.Event{Type: "vendor.created"}})
+		require.Contains(t, a.Error, "can't post to local address")
+		require.Empty(t, f.rx.received())
+	})
+}
+
+func Test_Dispatcher_indexes(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	f := newFixture(t, 500, 500, 500)
+	other, err := f.d.PutHook(ctx, Hook{URL: "http://127.0.0.1:1/", Types: []string{"lifecycle.*"}})
+	require.Nil(t, err)
+
+	f.publish(t, "vendor.created", "lifecycle.created")
+	for range 3 {
+		f.tick(t, time.Minute)
+	}
+
+	for id, want := range map[types.UUID]string{
+		f.hook.UUID: "vendor.created",
+		other.UUID:  "lifecycle.created",
+	} {
+		dls, err := f.d.Deliveries(ctx, id, "")
+		require.Nil(t, err)
+		require.Len(t, dls, 1)
+		require.Equal(t, want, dls[0].Event.Type)
+	}
+
+	dead, err := f.d.Deliveries(ctx, "", Dead)
+	require.Nil(t, err)
+	require.Len(t, dead, 2)
+}
+
+func Test_Dispatcher_secrets(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	key := "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+
+	f := newFixture(t)
+	f.d.cfg.Secrets, _ = NewSecrets(key)
+
+	// the fixture's hook was saved before there was a key, so it's sealed
+	// the next time it's saved
+	var stored Hook
+	require.Nil(t, f.d.store.Get(ctx, hookTable, string(f.hook.UUID), &stored))
+	require.Equal(t, "secret", stored.Secret)
+
+	_, err := f.d.PutHook(ctx, f.hook)
+	require.Nil(t, err)
+	require.Nil(t, f.d.store.Get(ctx, hookTable, string(f.hook.UUID), &stored))
+	require.True(t, sealed(stored.Secret))
+	require.NotContains(t, stored.Secret, "secret")
+
+	h, err := f.d.Hook(ctx, f.hook.UUID)
+	require.Nil(t, err)
+	require.Equal(t, "secret", h.Secret)
+
+	// deliveries are still signed with the real thing
+	f.publish(t, "vendor.created")
+	f.tick(t, 0)
+	require.Len(t, f.rx.received(), 1)
+
+	// a secret can't be moved to another hook
+	stored.UUID = "other"
+	require.Nil(t, f.d.store.Put(ctx, hookTable, "other", stored))
+	_, err = f.d.Hook(ctx, "other")
+	require.ErrorContains(t, err, "failed to decrypt")
+
+	f.d.cfg.Secrets, _ = NewSecrets(strings.Repeat("ff", 32))
+	_, err = f.d.Hook(ctx, f.hook.UUID)
+	require.ErrorContains(t, err, "failed to decrypt")
+
+	f.d.cfg.Secrets = nil
+	_, err = f.d.Hook(ctx, f.hook.UUID)
+	require.ErrorContains(t, err, "there's no key")
+}
+
+func Test_NewSecrets(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		key string
+		nil bool
+		err string
+	}{
+		"none": {
+			nil: true,
+		},
+		"happy": {
+			key: strings.Repeat("ab", 32),
+		},
+		"short": {
+			key: strings.Repeat("ab", 16),
+			nil: true,
+			err: "must be 32 bytes",
+		},
+		"not_hex": {
+			key: strings.Repeat("zz", 32),
+			nil: true,
+			err: "malformed",
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			aead, err := NewSecrets(tc.key)
+			if tc.err == "" {
+				require.Nil(t, err)
+			} else {
+				require.ErrorContains(t, err, tc.err)
+			}
+			require.Equal(t, tc.nil, aead == nil, name)
+		})
+	}
+}
+
+func typesOf(evs []events.Event) []string {
+	result := make([]string, 0, len(evs))
+	for _, e := range evs {
+		result = append(result, e.Type)
+	}
+	return result
+}
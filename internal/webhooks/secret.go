@@ -0,0 +1,79 @@
+package webhooks
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// sealedPrefix marks a secret that's encrypted; anything else was stored
+// before there was a key, or without one
+const sealedPrefix = "sealed:"
+
+// NewSecrets makes what Config.Secrets needs from a key of 64 hex characters,
+// like `openssl rand -hex 32` makes; there's nothing to encrypt with if key is
+// empty
+func NewSecrets(key string) (cipher.AEAD, error) {
+	if key == "" {
+		return nil, nil
+	}
+
+	b, err := hex.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("malformed webhook secret key: %w", err)
+	} else if len(b) != 32 {
+		return nil, fmt.Errorf("webhook secret key must be 32 bytes, not %d", len(b))
+	}
+
+	block, err := aes.NewCipher(b)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func sealed(secret string) bool {
+	return strings.HasPrefix(secret, sealedPrefix)
+}
+
+// sealSecret is h the way it's stored; the hook's id goes in as additional
+// data, so a secret can't be copied from one hook to another
+func (d *Dispatcher) sealSecret(h Hook) (Hook, error) {
+	if d.cfg.Secrets == nil || h.Secret == "" {
+		return h, nil
+	}
+
+	nonce := make([]byte, d.cfg.Secrets.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return h, err
+	}
+
+	b := d.cfg.Secrets.Seal(nonce, nonce, []byte(h.Secret), []byte(h.UUID))
+	h.Secret = sealedPrefix + base64.StdEncoding.EncodeToString(b)
+	return h, nil
+}
+
+// openSecret is sealSecret the other way around
+func (d *Dispatcher) openSecret(h Hook) (Hook, error) {
+	if !sealed(h.Secret) {
+		return h, nil
+	} else if d.cfg.Secrets == nil {
+		return h, fmt.Errorf("the secret for webhook %s is encrypted, but there's no key", h.UUID)
+	}
+
+	b, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(h.Secret, sealedPrefix))
+	if err != nil {
+		return h, fmt.Errorf("malformed secret for webhook %s: %w", h.UUID, err)
+	} else if n := d.cfg.Secrets.NonceSize(); len(b) < n {
+		return h, fmt.Errorf("malformed secret for webhook %s", h.UUID)
+	} else if b, err = d.cfg.Secrets.Open(nil, b[:n], b[n:], []byte(h.UUID)); err != nil {
+		return h, fmt.Errorf("failed to decrypt the secret for webhook %s: %w", h.UUID, err)
+	}
+
+	h.Secret = string(b)
+	return h, nil
+}
@@ -0,0 +1,202 @@
+// Package webhooks posts domain events to urls registered for them, signed
+// so the receiver knows they came from us, retrying with backoff until they're
+// delivered or we give up on them
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/events"
+	"github.com/jsmit257/centerforfunguscontrol/internal/store"
+	"github.com/jsmit257/huautla/types"
+)
+
+type (
+	Hook struct {
+		UUID types.UUID `json:"id"`
+		URL  string     `json:"url"`
+		// Secret signs every delivery; it's only ever shown when the hook is
+		// created
+		Secret string `json:"secret,omitempty"`
+		// Types are the events to deliver, like `generation.created`, or
+		// `event.*` for everything that happens to events, or `*` for
+		// everything at all
+		Types []string `json:"types"`
+		// EventTypes and Severities narrow event.* down to lifecycle and
+		// generation events of the given name or severity, like Harvest or
+		// High
+		EventTypes []string  `json:"event_types,omitempty"`
+		Severities []string  `json:"severities,omitempty"`
+		Disabled   bool      `json:"disabled,omitempty"`
+		CTime      time.Time `json:"ctime"`
+	}
+
+	Attempt struct {
+		Time   time.Time `json:"time"`
+		Status int       `json:"status,omitempty"`
+		Error  string    `json:"error,omitempty"`
+	}
+
+	Delivery struct {
+		ID       string       `json:"id"`
+		Hook     types.UUID   `json:"hook_id"`
+		Event    events.Event `json:"event"`
+		State    State        `json:"state"`
+		Attempts []Attempt    `json:"attempts"`
+		// Tries counts attempts since the delivery was made, or last
+		// redelivered
+		Tries int       `json:"tries"`
+		Next  time.Time `json:"next_attempt,omitempty"`
+	}
+
+	State string
+)
+
+const (
+	Pending   State = "pending"
+	Delivered State = "delivered"
+	// Dead deliveries ran out of attempts; they stay where they are until
+	// someone redelivers them
+	Dead State = "dead"
+
+	SignatureHeader = "X-Cffc-Signature"
+	TimestampHeader = "X-Cffc-Timestamp"
+	EventHeader     = "X-Cffc-Event"
+	DeliveryHeader  = "X-Cffc-Delivery"
+)
+
+var (
+	ErrInvalidHook = errors.New("invalid webhook")
+	ErrNotFound    = store.ErrNotFound
+)
+
+// Validate fills in what it can and complains about the rest
+func (h *Hook) Validate() error {
+	if u, err := url.Parse(h.URL); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidHook, err)
+	} else if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("%w: url must be absolute http(s): %q", ErrInvalidHook, h.URL)
+	} else if len(h.Types) == 0 {
+		return fmt.Errorf("%w: at least one type is required", ErrInvalidHook)
+	}
+
+	for _, t := range h.Types {
+		if _, err := path.Match(t, ""); err != nil {
+			return fmt.Errorf("%w: malformed type %q", ErrInvalidHook, t)
+		}
+	}
+
+	if h.UUID == "" {
+		h.UUID = types.UUID(uuid.NewString())
+	}
+	if h.Secret == "" {
+		h.Secret = newSecret()
+	}
+
+	return nil
+}
+
+// local complains if h posts to a loopback or link-local address, or to
+// localhost, which is only a name for one
+func (h Hook) local() error {
+	u, err := url.Parse(h.URL)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidHook, err)
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("%w: url can't be local: %q", ErrInvalidHook, h.URL)
+	} else if ip := net.ParseIP(host); ip != nil && isLocal(ip) {
+		return fmt.Errorf("%w: url can't be local: %q", ErrInvalidHook, h.URL)
+	}
+	return nil
+}
+
+func isLocal(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified()
+}
+
+// refuseLocal is a dialer's Control, so a hook whose name resolves to a local
+// address doesn't get there either
+func refuseLocal(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	} else if ip := net.ParseIP(host); ip == nil || isLocal(ip) {
+		return fmt.Errorf("webhooks can't post to local address %s", address)
+	}
+	return nil
+}
+
+// Redacted is the hook without its secret, for showing to people
+func (h Hook) Redacted() Hook {
+	h.Secret = ""
+	return h
+}
+
+// Matches says whether e should be delivered to h
+func (h Hook) Matches(e events.Event) bool {
+	if h.Disabled || !slices.ContainsFunc(h.Types, func(t string) bool {
+		ok, _ := path.Match(t, e.Type)
+		return ok
+	}) {
+		return false
+	} else if len(h.EventTypes) == 0 && len(h.Severities) == 0 {
+		return true
+	}
+
+	var p struct {
+		Value struct {
+			EventType *types.EventType `json:"event_type"`
+		} `json:"value"`
+	}
+	if err := json.Unmarshal(e.Payload, &p); err != nil || p.Value.EventType == nil {
+		return false
+	}
+
+	return matchAny(h.EventTypes, p.Value.EventType.Name) &&
+		matchAny(h.Severities, p.Value.EventType.Severity)
+}
+
+func matchAny(want []string, have string) bool {
+	return len(want) == 0 || slices.ContainsFunc(want, func(w string) bool {
+		return strings.EqualFold(w, have)
+	})
+}
+
+// Sign is what goes in the signature header: an hmac of the timestamp header
+// and the body, so a captured delivery can't be replayed later with a new
+// timestamp
+func Sign(secret string, ts int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", ts)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify is Sign for receivers
+func Verify(secret string, ts int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, ts, body)), []byte(signature))
+}
+
+func newSecret() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,131 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/events"
+)
+
+func Test_Validate(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		h   Hook
+		err error
+	}{
+		"happy_path": {
+			h: Hook{URL: "https://example.com/hook", Types: []string{"event.*"}},
+		},
+		"relative_url": {
+			h:   Hook{URL: "/hook", Types: []string{"*"}},
+			err: ErrInvalidHook,
+		},
+		"wrong_scheme": {
+			h:   Hook{URL: "ftp://example.com/hook", Types: []string{"*"}},
+			err: ErrInvalidHook,
+		},
+		"malformed_url": {
+			h:   Hook{URL: "http://example.com/%zzz", Types: []string{"*"}},
+			err: ErrInvalidHook,
+		},
+		"no_types": {
+			h:   Hook{URL: "https://example.com/hook"},
+			err: ErrInvalidHook,
+		},
+		"malformed_type": {
+			h:   Hook{URL: "https://example.com/hook", Types: []string{"event.["}},
+			err: ErrInvalidHook,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tc.h.Validate()
+			require.ErrorIs(t, err, tc.err)
+			if tc.err == nil {
+				require.NotEmpty(t, tc.h.UUID)
+				require.Len(t, tc.h.Secret, 64)
+				require.Empty(t, tc.h.Redacted().Secret)
+			}
+		})
+	}
+}
+
+func Test_Matches(t *testing.T) {
+	t.Parallel()
+
+	harvest := json.RawMessage(`{"owner_id":"0","value":{"id":"1","event_type":{"name":"Harvest","severity":"Info"}}}`)
+	contamination := json.RawMessage(`{"owner_id":"0","value":{"id":"1","event_type":{"name":"Contamination","severity":"High"}}}`)
+
+	tcs := map[string]struct {
+		h       Hook
+		e       events.Event
+		matches bool
+	}{
+		"everything": {
+			h:       Hook{Types: []string{"*"}},
+			e:       events.Event{Type: "vendor.created"},
+			matches: true,
+		},
+		"exact": {
+			h:       Hook{Types: []string{"vendor.deleted", "generation.created"}},
+			e:       events.Event{Type: "generation.created"},
+			matches: true,
+		},
+		"wrong_type": {
+			h: Hook{Types: []string{"generation.created"}},
+			e: events.Event{Type: "generation.deleted"},
+		},
+		"disabled": {
+			h: Hook{Types: []string{"*"}, Disabled: true},
+			e: events.Event{Type: "vendor.created"},
+		},
+		"harvests": {
+			h:       Hook{Types: []string{"event.added"}, EventTypes: []string{"harvest"}},
+			e:       events.Event{Type: "event.added", Payload: harvest},
+			matches: true,
+		},
+		"not_a_harvest": {
+			h: Hook{Types: []string{"event.added"}, EventTypes: []string{"harvest"}},
+			e: events.Event{Type: "event.added", Payload: contamination},
+		},
+		"high_severity": {
+			h:       Hook{Types: []string{"event.*"}, Severities: []string{"High"}},
+			e:       events.Event{Type: "event.changed", Payload: contamination},
+			matches: true,
+		},
+		"low_severity": {
+			h: Hook{Types: []string{"event.*"}, Severities: []string{"High"}},
+			e: events.Event{Type: "event.changed", Payload: harvest},
+		},
+		"no_event_type": {
+			h: Hook{Types: []string{"*"}, Severities: []string{"High"}},
+			e: events.Event{Type: "event.removed", Payload: json.RawMessage(`{"owner_id":"0"}`)},
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tc.matches, tc.h.Matches(tc.e))
+		})
+	}
+}
+
+func Test_Sign(t *testing.T) {
+	t.Parallel()
+
+	sig := Sign("secret", 1700000000, []byte(`{"type":"vendor.created"}`))
+	require.Regexp(t, "^sha256=[0-9a-f]{64}$", sig)
+	require.True(t, Verify("secret", 1700000000, []byte(`{"type":"vendor.created"}`), sig))
+	require.False(t, Verify("other", 1700000000, []byte(`{"type":"vendor.created"}`), sig))
+	require.False(t, Verify("secret", 1700000001, []byte(`{"type":"vendor.created"}`), sig))
+	require.False(t, Verify("secret", 1700000000, []byte(`{"type":"vendor.deleted"}`), sig))
+}
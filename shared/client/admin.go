@@ -0,0 +1,189 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/jsmit257/huautla/types"
+)
+
+// ImportReport is how many of each kind of thing an import added, and how
+// many were already there
+type ImportReport struct {
+	Mode     string         `json:"mode"`
+	Added    map[string]int `json:"added"`
+	Existing map[string]int `json:"existing,omitempty"`
+	Files    int            `json:"files"`
+	// files the export couldn't find
+	Missing []string `json:"missing,omitempty"`
+}
+
+type (
+	Backup struct {
+		Name    string    `json:"name"`
+		Created time.Time `json:"created"`
+		Size    int64     `json:"size"`
+		SHA256  string    `json:"sha256"`
+	}
+
+	// BackupStatus is how scheduled backups are going, and the ones there are,
+	// newest first
+	BackupStatus struct {
+		Running     bool       `json:"running"`
+		LastAttempt *time.Time `json:"last_attempt,omitempty"`
+		LastSuccess *time.Time `json:"last_success,omitempty"`
+		LastError   string     `json:"last_error,omitempty"`
+		Next        *time.Time `json:"next,omitempty"`
+		Backups     []Backup   `json:"backups"`
+	}
+
+	// BackupCheck is whether a backup still matches its checksum and reads
+	// all the way through; Error says what's wrong if it doesn't
+	BackupCheck struct {
+		Name  string `json:"name"`
+		OK    bool   `json:"ok"`
+		Error string `json:"error,omitempty"`
+	}
+)
+
+const (
+	ImportRemap    = "remap"
+	ImportPreserve = "preserve"
+)
+
+// Retime changes when the row id in table says it was made, changed or
+// deleted
+func (c *Client) Retime(ctx context.Context, table string, id types.UUID, ts types.Timestamp) error {
+	return call(ctx, c, http.MethodPatch, join("ts", table, string(id)), ts, http.StatusNoContent)
+}
+
+// Undelete brings back the row id in table
+func (c *Client) Undelete(ctx context.Context, table string, id types.UUID) error {
+	return call(ctx, c, http.MethodPatch, join("undel", table, string(id)), nil, http.StatusNoContent)
+}
+
+// Metrics is everything the server tells prometheus, in its text format
+func (c *Client) Metrics(ctx context.Context) ([]byte, error) {
+	_, body, err := c.do(ctx, request{method: http.MethodGet, path: "/metrics"}, http.StatusOK)
+	return body, err
+}
+
+// Export is everything on the server, as a tarball Import can restore
+func (c *Client) Export(ctx context.Context) (File, error) {
+	return c.file(ctx, request{method: http.MethodGet, path: "/admin/export"})
+}
+
+// Import restores an export; mode is ImportRemap or ImportPreserve, and the
+// server remaps if it's empty
+func (c *Client) Import(ctx context.Context, export []byte, mode string) (ImportReport, error) {
+	var result ImportReport
+
+	query := url.Values{}
+	if mode != "" {
+		query.Set("mode", mode)
+	}
+	_, body, err := c.do(ctx, request{
+		method:      http.MethodPost,
+		path:        "/admin/import",
+		query:       query,
+		body:        export,
+		contentType: "application/gzip",
+	}, http.StatusOK)
+	if err != nil {
+		return result, err
+	}
+	return result, json.Unmarshal(body, &result)
+}
+
+// Backups is how scheduled backups are going; it's a 404 if the server
+// doesn't take them
+func (c *Client) Backups(ctx context.Context) (BackupStatus, error) {
+	return get[BackupStatus](ctx, c, "/admin/backups", nil)
+}
+
+// Backup has the server take a backup now
+func (c *Client) Backup(ctx context.Context) (Backup, error) {
+	return send[Backup](ctx, c, http.MethodPost, "/admin/backups", nil, http.StatusCreated)
+}
+
+// VerifyBackup has the server read back the backup called name
+func (c *Client) VerifyBackup(ctx context.Context, name string) (BackupCheck, error) {
+	return send[BackupCheck](ctx, c, http.MethodPost, join("admin", "backups", name, "verify"), nil, http.StatusOK)
+}
+
+type (
+	// CSVMapping says which of a spreadsheet's columns are which; see the
+	// README for the fields
+	CSVMapping struct {
+		Columns    map[string]string `json:"columns"`
+		Defaults   map[string]string `json:"defaults,omitempty"`
+		TimeFormat string            `json:"time_format,omitempty"`
+		Timezone   string            `json:"timezone,omitempty"`
+	}
+
+	CSVReport struct {
+		DryRun  bool           `json:"dry_run"`
+		Rows    []CSVRow       `json:"rows"`
+		Created map[string]int `json:"created"`
+		Failed  int            `json:"failed"`
+	}
+
+	CSVRow struct {
+		Row       int          `json:"row"`
+		Status    int          `json:"status"`
+		Created   []CSVCreated `json:"created,omitempty"`
+		Lifecycle types.UUID   `json:"lifecycle,omitempty"`
+		Error     string       `json:"error,omitempty"`
+	}
+
+	CSVCreated struct {
+		Kind string     `json:"kind"`
+		Name string     `json:"name"`
+		ID   types.UUID `json:"id,omitempty"`
+	}
+)
+
+// ImportCSV adds the rows of a spreadsheet, or with dryRun, says what it
+// would add; rows that fail are in the report, with their error, rather
+// than being an error here
+func (c *Client) ImportCSV(ctx context.Context, sheet []byte, m CSVMapping, dryRun bool) (CSVReport, error) {
+	var result CSVReport
+
+	mapping, err := json.Marshal(m)
+	if err != nil {
+		return result, err
+	}
+
+	b := &bytes.Buffer{}
+	w := multipart.NewWriter(b)
+	if err = w.WriteField("mapping", string(mapping)); err != nil {
+		return result, err
+	} else if part, err := w.CreateFormFile("file", "import.csv"); err != nil {
+		return result, err
+	} else if _, err = part.Write(sheet); err != nil {
+		return result, err
+	} else if err = w.Close(); err != nil {
+		return result, err
+	}
+
+	query := url.Values{}
+	if dryRun {
+		query.Set("dry_run", "true")
+	}
+	_, body, err := c.do(ctx, request{
+		method:      http.MethodPost,
+		path:        "/import/csv",
+		query:       query,
+		body:        b.Bytes(),
+		contentType: w.FormDataContentType(),
+	}, http.StatusOK, http.StatusCreated, http.StatusMultiStatus)
+	if err != nil {
+		return result, err
+	}
+	return result, json.Unmarshal(body, &result)
+}
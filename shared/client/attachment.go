@@ -0,0 +1,46 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/jsmit257/huautla/types"
+)
+
+type (
+	// AttachmentService is files that aren't photos, on anything with an
+	// id; every change is answered with all of the owner's attachments
+	AttachmentService struct{ c *Client }
+
+	Attachment struct {
+		UUID        types.UUID `json:"id"`
+		Filename    string     `json:"filename"`
+		ContentType string     `json:"content_type"`
+		Size        int64      `json:"size"`
+		MTime       time.Time  `json:"mtime"`
+		CTime       time.Time  `json:"ctime"`
+	}
+)
+
+func (as *AttachmentService) List(ctx context.Context, owner types.UUID) ([]Attachment, error) {
+	return get[[]Attachment](ctx, as.c, join("attachments", string(owner)), nil)
+}
+
+// Get is the attachment's file, with the name it was uploaded with
+func (as *AttachmentService) Get(ctx context.Context, owner, id types.UUID) (File, error) {
+	return as.c.file(ctx, request{method: http.MethodGet, path: join("attachments", string(owner), string(id))})
+}
+
+func (as *AttachmentService) Add(ctx context.Context, owner types.UUID, f File) ([]Attachment, error) {
+	return upload[[]Attachment](ctx, as.c, http.MethodPost, join("attachments", string(owner)), f)
+}
+
+// Replace uploads f in place of the attachment id
+func (as *AttachmentService) Replace(ctx context.Context, owner, id types.UUID, f File) ([]Attachment, error) {
+	return upload[[]Attachment](ctx, as.c, http.MethodPatch, join("attachments", string(owner), string(id)), f)
+}
+
+func (as *AttachmentService) Remove(ctx context.Context, owner, id types.UUID) ([]Attachment, error) {
+	return send[[]Attachment](ctx, as.c, http.MethodDelete, join("attachments", string(owner), string(id)), nil, http.StatusOK)
+}
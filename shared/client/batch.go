@@ -0,0 +1,82 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/jsmit257/huautla/types"
+)
+
+type (
+	BatchRequest struct {
+		// if anything fails, nothing any of the requests did happened, and
+		// nothing after it is tried; only databases that do transactions
+		// can do this
+		Atomic   bool        `json:"atomic"`
+		Requests []BatchItem `json:"requests"`
+	}
+
+	// BatchItem is one request in a batch; Path and Body can refer to what
+	// an earlier request made as ${n}
+	BatchItem struct {
+		Method  string            `json:"method"`
+		Path    string            `json:"path"`
+		Headers map[string]string `json:"headers,omitempty"`
+		Body    json.RawMessage   `json:"body,omitempty"`
+	}
+
+	BatchResult struct {
+		Status int             `json:"status"`
+		ID     types.UUID      `json:"id,omitempty"`
+		Body   json.RawMessage `json:"body,omitempty"`
+		// why a request wasn't tried, or a response that wasn't json
+		Error string `json:"error,omitempty"`
+	}
+
+	BatchResponse struct {
+		Atomic    bool `json:"atomic"`
+		Succeeded int  `json:"succeeded"`
+		Failed    int  `json:"failed"`
+		// an atomic batch that failed was rolled back, so none of its
+		// results happened, even the ones that say they did
+		RolledBack bool          `json:"rolled_back,omitempty"`
+		Results    []BatchResult `json:"results"`
+		// why an atomic batch that didn't fail couldn't be committed
+		Error string `json:"error,omitempty"`
+	}
+)
+
+// NewBatchItem is a BatchItem with v for a body
+func NewBatchItem(method, path string, v any) (BatchItem, error) {
+	result := BatchItem{Method: method, Path: path}
+	if v == nil {
+		return result, nil
+	}
+
+	b, err := json.Marshal(v)
+	result.Body = b
+	return result, err
+}
+
+// Batch sends every request in b at once. An atomic batch that failed is an
+// *Error, and the response says what happened to each
+// request, whether or not it's an error
+func (c *Client) Batch(ctx context.Context, b BatchRequest) (BatchResponse, error) {
+	var result BatchResponse
+
+	req, err := jsonRequest(http.MethodPost, "/batch", b)
+	if err != nil {
+		return result, err
+	}
+
+	_, body, err := c.do(ctx, req, http.StatusOK)
+	var e *Error
+	if err != nil && !errors.As(err, &e) {
+		return result, err
+	} else if jsonErr := json.Unmarshal(body, &result); jsonErr != nil && err == nil {
+		return result, jsonErr
+	}
+	return result, err
+}
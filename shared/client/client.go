@@ -0,0 +1,454 @@
+// Package client is a typed client for the cffc api: one method for every
+// route, that takes and returns huautla types, and turns anything that isn't
+// a success into an *Error
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/jsmit257/huautla/types"
+)
+
+type (
+	// Client is safe to share between goroutines; the cookie the server
+	// refreshes on every response is shared along with it
+	Client struct {
+		base    *url.URL
+		hc      *http.Client
+		retries int
+		backoff time.Duration
+
+		mtx    sync.Mutex
+		cookie *http.Cookie
+
+		Vendors     *VendorService
+		Stages      *StageService
+		EventTypes  *EventTypeService
+		Substrates  *SubstrateService
+		Ingredients *IngredientService
+		Strains     *StrainService
+		Lifecycles  *LifecycleService
+		Generations *GenerationService
+		Events      *EventService
+		Notes       *NoteService
+		Photos      *PhotoService
+		Attachments *AttachmentService
+		Webhooks    *WebhookService
+	}
+
+	Option func(*Client)
+
+	// Error is any response with a status code the route doesn't succeed
+	// with; it unwraps to one of the Err* sentinels, so callers can use
+	// errors.Is without caring about exact codes
+	Error struct {
+		Method     string
+		Path       string
+		StatusCode int
+		// Message is what the server said was wrong, or the body, if it
+		// didn't say it in json
+		Message string
+		// Cid is the correlation id the server logged the request under
+		Cid types.CID
+	}
+
+	// File is a photo, attachment, timelapse or sheet of labels, going
+	// either way
+	File struct {
+		Name        string
+		ContentType string
+		Data        []byte
+	}
+
+	// request is everything needed to make a call more than once
+	request struct {
+		method      string
+		path        string
+		query       url.Values
+		body        []byte
+		contentType string
+	}
+
+	cidKey struct{}
+)
+
+const (
+	// CookieName is the userservice cookie every route needs, when the
+	// server is configured to check
+	CookieName = "us-authn"
+
+	CidHeader         = "Cid"
+	IdempotencyHeader = "Idempotency-Key"
+)
+
+var (
+	ErrBadRequest  = errors.New("bad request")
+	ErrForbidden   = errors.New("forbidden")
+	ErrNotFound    = errors.New("not found")
+	ErrConflict    = errors.New("conflict")
+	ErrServer      = errors.New("server error")
+	ErrUnavailable = errors.New("unavailable")
+)
+
+// New makes a client for the server at base, like http://localhost:8080;
+// by default it retries twice, starting 100ms apart
+func New(base string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimRight(base, "/"))
+	if err != nil {
+		return nil, err
+	} else if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("base url needs a scheme and a host: %q", base)
+	}
+
+	c := &Client{
+		base:    u,
+		hc:      &http.Client{Timeout: 30 * time.Second},
+		retries: 2,
+		backoff: 100 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	// resolve and scan answer with a redirect, and what's in it is the
+	// answer, so it's never followed
+	hc := *c.hc
+	hc.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	c.hc = &hc
+
+	c.Vendors = &VendorService{c}
+	c.Stages = &StageService{c}
+	c.EventTypes = &EventTypeService{c}
+	c.Substrates = &SubstrateService{c}
+	c.Ingredients = &IngredientService{c}
+	c.Strains = &StrainService{c}
+	c.Lifecycles = &LifecycleService{c}
+	c.Generations = &GenerationService{c}
+	c.Events = &EventService{c}
+	c.Notes = &NoteService{c}
+	c.Photos = &PhotoService{c}
+	c.Attachments = &AttachmentService{c}
+	c.Webhooks = &WebhookService{c}
+
+	return c, nil
+}
+
+// WithHTTPClient uses hc for everything, except that redirects are never
+// followed
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.hc = hc
+	}
+}
+
+// WithCookie authenticates with the cookie from logging in to userservice
+func WithCookie(cookie *http.Cookie) Option {
+	return func(c *Client) {
+		c.cookie = cookie
+	}
+}
+
+// WithToken authenticates with just the value of the userservice cookie,
+// for automation that keeps it somewhere other than a cookie jar
+func WithToken(token string) Option {
+	return WithCookie(&http.Cookie{Name: CookieName, Value: token})
+}
+
+// WithRetries is how many more times to try a request that didn't get to
+// the server, or that the server was too busy for, and how long to wait
+// before the first retry; the wait doubles after that
+func WithRetries(n int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.retries, c.backoff = n, backoff
+	}
+}
+
+// WithCid sends cid with every request made with ctx, so the server logs
+// them all under it; without one, each call gets its own
+func WithCid(ctx context.Context, cid types.CID) context.Context {
+	return context.WithValue(ctx, cidKey{}, cid)
+}
+
+// Cookie is the latest cookie the server sent, for handing on to another
+// client
+func (c *Client) Cookie() *http.Cookie {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	return c.cookie
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s %s: %d %s (cid: %s)", e.Method, e.Path, e.StatusCode, e.Message, e.Cid)
+}
+
+func (e *Error) Unwrap() error {
+	switch sc := e.StatusCode; {
+	case sc == http.StatusForbidden || sc == http.StatusUnauthorized:
+		return ErrForbidden
+	case sc == http.StatusNotFound:
+		return ErrNotFound
+	case sc == http.StatusConflict:
+		return ErrConflict
+	case sc == http.StatusTooManyRequests || sc == http.StatusBadGateway || sc == http.StatusServiceUnavailable || sc == http.StatusGatewayTimeout:
+		return ErrUnavailable
+	case sc >= http.StatusInternalServerError:
+		return ErrServer
+	default:
+		return ErrBadRequest
+	}
+}
+
+// do sends req until it gets an answer, and says whether it's one of want;
+// POSTs get an idempotency key, so trying them again is as safe as trying
+// anything else again
+func (c *Client) do(ctx context.Context, req request, want ...int) (*http.Response, []byte, error) {
+	cid := cidFrom(ctx)
+
+	var key string
+	if req.method == http.MethodPost {
+		key = uuid.NewString()
+	}
+
+	for attempt := 0; ; attempt++ {
+		res, body, err := c.try(ctx, req, cid, key)
+		if err != nil && ctx.Err() != nil {
+			return nil, nil, ctx.Err()
+		} else if attempt < c.retries && (err != nil || retryable(res.StatusCode)) {
+			if err = c.wait(ctx, attempt); err != nil {
+				return nil, nil, err
+			}
+			continue
+		} else if err != nil {
+			return nil, nil, err
+		} else if !slices.Contains(want, res.StatusCode) {
+			return res, body, newError(req, res, body, cid)
+		}
+		return res, body, nil
+	}
+}
+
+func cidFrom(ctx context.Context) types.CID {
+	if cid, ok := ctx.Value(cidKey{}).(types.CID); ok && cid != "" {
+		return cid
+	}
+	return types.CID(uuid.NewString())
+}
+
+func (c *Client) try(ctx context.Context, req request, cid types.CID, key string) (*http.Response, []byte, error) {
+	u := c.url(req.path, req.query)
+
+	r, err := http.NewRequestWithContext(ctx, req.method, u.String(), bytes.NewReader(req.body))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r.Header.Set(CidHeader, string(cid))
+	if key != "" {
+		r.Header.Set(IdempotencyHeader, key)
+	}
+	if req.contentType != "" {
+		r.Header.Set("Content-Type", req.contentType)
+	}
+	if cookie := c.Cookie(); cookie != nil {
+		r.AddCookie(cookie)
+	}
+
+	res, err := c.hc.Do(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Body.Close()
+
+	c.refresh(res)
+
+	body, err := io.ReadAll(res.Body)
+	return res, body, err
+}
+
+// refresh keeps the cookie the authn middleware sends back with every
+// response
+// url is base with path, which is already escaped, on the end
+func (c *Client) url(path string, query url.Values) url.URL {
+	u := *c.base
+	u.RawPath = c.base.EscapedPath() + path
+	u.Path, _ = url.PathUnescape(u.RawPath)
+	u.RawQuery = query.Encode()
+	return u
+}
+
+func (c *Client) refresh(res *http.Response) {
+	for _, cookie := range res.Cookies() {
+		if cookie.Name == CookieName {
+			c.mtx.Lock()
+			c.cookie = cookie
+			c.mtx.Unlock()
+		}
+	}
+}
+
+func (c *Client) wait(ctx context.Context, attempt int) error {
+	t := time.NewTimer(c.backoff << attempt)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// retryable is whether the server didn't get around to doing anything; a
+// 500 is usually the database saying no, and saying it again won't help
+func retryable(sc int) bool {
+	return sc == http.StatusTooManyRequests ||
+		sc == http.StatusBadGateway ||
+		sc == http.StatusServiceUnavailable ||
+		sc == http.StatusGatewayTimeout
+}
+
+func newError(req request, res *http.Response, body []byte, cid types.CID) *Error {
+	result := &Error{
+		Method:     req.method,
+		Path:       req.path,
+		StatusCode: res.StatusCode,
+		Cid:        cid,
+	}
+	if echoed := res.Header.Get(CidHeader); echoed != "" {
+		result.Cid = types.CID(echoed)
+	}
+	if err := json.Unmarshal(body, &result.Message); err != nil {
+		result.Message = strings.TrimSpace(string(body))
+	}
+	if result.Message == "" {
+		result.Message = http.StatusText(res.StatusCode)
+	}
+	return result
+}
+
+// get, send and call are do for json: get and send decode what comes back,
+// and call doesn't expect anything to
+func get[T any](ctx context.Context, c *Client, path string, query url.Values) (T, error) {
+	var result T
+	_, body, err := c.do(ctx, request{method: http.MethodGet, path: path, query: query}, http.StatusOK)
+	if err != nil {
+		return result, err
+	}
+	return result, json.Unmarshal(body, &result)
+}
+
+func send[T any](ctx context.Context, c *Client, method, path string, v any, want ...int) (T, error) {
+	var result T
+	req, err := jsonRequest(method, path, v)
+	if err != nil {
+		return result, err
+	}
+	_, body, err := c.do(ctx, req, want...)
+	if err != nil {
+		return result, err
+	}
+	return result, json.Unmarshal(body, &result)
+}
+
+func call(ctx context.Context, c *Client, method, path string, v any, want ...int) error {
+	req, err := jsonRequest(method, path, v)
+	if err != nil {
+		return err
+	}
+	_, _, err = c.do(ctx, req, want...)
+	return err
+}
+
+// upload sends f as the multipart form photos and attachments expect
+func upload[T any](ctx context.Context, c *Client, method, path string, f File) (T, error) {
+	var result T
+
+	b := &bytes.Buffer{}
+	w := multipart.NewWriter(b)
+	h := textproto.MIMEHeader{}
+	h.Set("Content-Disposition", mime.FormatMediaType("form-data", map[string]string{
+		"name":     "file",
+		"filename": f.Name,
+	}))
+	if f.ContentType != "" {
+		h.Set("Content-Type", f.ContentType)
+	} else {
+		h.Set("Content-Type", "application/octet-stream")
+	}
+	if part, err := w.CreatePart(h); err != nil {
+		return result, err
+	} else if _, err = part.Write(f.Data); err != nil {
+		return result, err
+	} else if err = w.Close(); err != nil {
+		return result, err
+	}
+
+	_, body, err := c.do(ctx, request{
+		method:      method,
+		path:        path,
+		body:        b.Bytes(),
+		contentType: w.FormDataContentType(),
+	}, http.StatusOK)
+	if err != nil {
+		return result, err
+	}
+	return result, json.Unmarshal(body, &result)
+}
+
+// file is whatever req gets back that isn't json
+func (c *Client) file(ctx context.Context, req request) (File, error) {
+	res, body, err := c.do(ctx, req, http.StatusOK)
+	if err != nil {
+		return File{}, err
+	}
+	return fileFrom(res, body), nil
+}
+
+func fileFrom(res *http.Response, body []byte) File {
+	result := File{ContentType: res.Header.Get("Content-Type"), Data: body}
+	if _, params, err := mime.ParseMediaType(res.Header.Get("Content-Disposition")); err == nil {
+		result.Name = params["filename"]
+	}
+	return result
+}
+
+func jsonRequest(method, path string, v any) (request, error) {
+	req := request{method: method, path: path}
+	if v == nil {
+		return req, nil
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return req, err
+	}
+	req.body, req.contentType = b, "application/json"
+	return req, nil
+}
+
+// join makes a path out of segments, escaping each of them
+func join(segments ...string) string {
+	var b strings.Builder
+	for _, s := range segments {
+		b.WriteByte('/')
+		b.WriteString(url.PathEscape(s))
+	}
+	return b.String()
+}
@@ -0,0 +1,420 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/huautla/types"
+)
+
+// replay answers each request with the next of its responses, and keeps
+// the requests for looking at afterwards
+type replay struct {
+	mtx       sync.Mutex
+	responses []response
+	requests  []*http.Request
+	bodies    [][]byte
+}
+
+type response struct {
+	sc     int
+	body   string
+	header map[string]string
+}
+
+func (rp *replay) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rp.mtx.Lock()
+	defer rp.mtx.Unlock()
+
+	b, _ := io.ReadAll(r.Body)
+	rp.requests = append(rp.requests, r)
+	rp.bodies = append(rp.bodies, b)
+
+	res := rp.responses[0]
+	if len(rp.responses) > 1 {
+		rp.responses = rp.responses[1:]
+	}
+	for k, v := range res.header {
+		w.Header().Set(k, v)
+	}
+	w.WriteHeader(res.sc)
+	_, _ = w.Write([]byte(res.body))
+}
+
+func newTestClient(t *testing.T, rp *replay, opts ...Option) *Client {
+	srv := httptest.NewServer(rp)
+	t.Cleanup(srv.Close)
+
+	c, err := New(srv.URL, append([]Option{WithRetries(2, time.Millisecond)}, opts...)...)
+	require.Nil(t, err)
+	return c
+}
+
+func Test_New(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		base string
+		err  bool
+	}{
+		"happy_path":     {base: "http://localhost:8080"},
+		"trailing_slash": {base: "http://localhost:8080/"},
+		"no_scheme":      {base: "localhost:8080", err: true},
+		"no_host":        {base: "/vendors", err: true},
+		"unparseable":    {base: "http://[::1", err: true},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c, err := New(tc.base)
+			if tc.err {
+				require.NotNil(t, err)
+			} else {
+				require.Nil(t, err)
+				require.Equal(t, "http://localhost:8080", c.base.String())
+				require.NotNil(t, c.Lifecycles)
+			}
+		})
+	}
+}
+
+func Test_do(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		responses []response
+		result    []types.Vendor
+		err       error
+		sc        int
+		msg       string
+		tries     int
+	}{
+		"happy_path": {
+			responses: []response{{sc: http.StatusOK, body: `[{"id":"0","name":"vendor 0"}]`}},
+			result:    []types.Vendor{{UUID: "0", Name: "vendor 0"}},
+			tries:     1,
+		},
+		"retried": {
+			responses: []response{
+				{sc: http.StatusServiceUnavailable},
+				{sc: http.StatusBadGateway},
+				{sc: http.StatusOK, body: `[]`},
+			},
+			result: []types.Vendor{},
+			tries:  3,
+		},
+		"out_of_retries": {
+			responses: []response{{sc: http.StatusServiceUnavailable, body: `"down for maintenance"`}},
+			err:       ErrUnavailable,
+			sc:        http.StatusServiceUnavailable,
+			msg:       "down for maintenance",
+			tries:     3,
+		},
+		"not_retried": {
+			responses: []response{{sc: http.StatusInternalServerError, body: `"failed to fetch vendors"`}},
+			err:       ErrServer,
+			sc:        http.StatusInternalServerError,
+			msg:       "failed to fetch vendors",
+			tries:     1,
+		},
+		"forbidden": {
+			responses: []response{{sc: http.StatusForbidden, header: map[string]string{"Location": "/"}}},
+			err:       ErrForbidden,
+			sc:        http.StatusForbidden,
+			msg:       "Forbidden",
+			tries:     1,
+		},
+		"not_json": {
+			responses: []response{{sc: http.StatusNotFound, body: "404 page not found\n"}},
+			err:       ErrNotFound,
+			sc:        http.StatusNotFound,
+			msg:       "404 page not found",
+			tries:     1,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			rp := &replay{responses: tc.responses}
+			c := newTestClient(t, rp)
+
+			ctx := WithCid(context.Background(), types.CID(name))
+			result, err := c.Vendors.All(ctx)
+			require.Len(t, rp.requests, tc.tries)
+			for _, r := range rp.requests {
+				require.Equal(t, name, r.Header.Get(CidHeader))
+			}
+			if tc.err == nil {
+				require.Nil(t, err)
+				require.Equal(t, tc.result, result)
+				return
+			}
+
+			require.True(t, errors.Is(err, tc.err), err)
+			var e *Error
+			require.True(t, errors.As(err, &e))
+			require.Equal(t, tc.sc, e.StatusCode)
+			require.Equal(t, tc.msg, e.Message)
+			require.Equal(t, types.CID(name), e.Cid)
+			require.Equal(t, "/vendors", e.Path)
+		})
+	}
+}
+
+// Test_idempotent is whether a POST that's tried again is the same request,
+// so the server can tell
+func Test_idempotent(t *testing.T) {
+	t.Parallel()
+
+	rp := &replay{responses: []response{
+		{sc: http.StatusGatewayTimeout},
+		{sc: http.StatusCreated, body: `{"id":"0","name":"vendor 0"}`},
+	}}
+	c := newTestClient(t, rp)
+
+	v, err := c.Vendors.Create(context.Background(), types.Vendor{Name: "vendor 0"})
+	require.Nil(t, err)
+	require.Equal(t, types.UUID("0"), v.UUID)
+
+	require.Len(t, rp.requests, 2)
+	require.NotEmpty(t, rp.requests[0].Header.Get(IdempotencyHeader))
+	require.Equal(t, rp.requests[0].Header.Get(IdempotencyHeader), rp.requests[1].Header.Get(IdempotencyHeader))
+	require.Equal(t, rp.requests[0].Header.Get(CidHeader), rp.requests[1].Header.Get(CidHeader))
+	require.Equal(t, rp.bodies[0], rp.bodies[1])
+	require.Equal(t, "application/json", rp.requests[0].Header.Get("Content-Type"))
+
+	// another call is another request
+	_, err = c.Vendors.Create(context.Background(), types.Vendor{Name: "vendor 0"})
+	require.Nil(t, err)
+	require.NotEqual(t, rp.requests[0].Header.Get(IdempotencyHeader), rp.requests[2].Header.Get(IdempotencyHeader))
+	require.NotEqual(t, rp.requests[0].Header.Get(CidHeader), rp.requests[2].Header.Get(CidHeader))
+}
+
+func Test_cookie(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		opt  Option
+		sent string
+	}{
+		"cookie": {
+			opt:  WithCookie(&http.Cookie{Name: CookieName, Value: "from a cookie"}),
+			sent: "from a cookie",
+		},
+		"token": {
+			opt:  WithToken("from a token"),
+			sent: "from a token",
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			rp := &replay{responses: []response{{
+				sc:     http.StatusNoContent,
+				header: map[string]string{"Set-Cookie": CookieName + "=refreshed; Path=/"},
+			}}}
+			c := newTestClient(t, rp, tc.opt)
+
+			require.Nil(t, c.Vendors.Delete(context.Background(), "0"))
+			require.Nil(t, c.Vendors.Delete(context.Background(), "0"))
+
+			first, err := rp.requests[0].Cookie(CookieName)
+			require.Nil(t, err)
+			require.Equal(t, tc.sent, first.Value)
+
+			// the server refreshed it, so the next request has the new one
+			second, err := rp.requests[1].Cookie(CookieName)
+			require.Nil(t, err)
+			require.Equal(t, "refreshed", second.Value)
+			require.Equal(t, "refreshed", c.Cookie().Value)
+		})
+	}
+}
+
+func Test_paths(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		call func(*Client) error
+		path string
+		raw  string
+	}{
+		"scan_url": {
+			call: func(c *Client) error {
+				_, err := c.Scan(context.Background(), "https://cffc.example.com/scan/LC-2026-0142")
+				return err
+			},
+			path: "/scan/https://cffc.example.com/scan/LC-2026-0142",
+			raw:  "/scan/https:%2F%2Fcffc.example.com%2Fscan%2FLC-2026-0142",
+		},
+		"source": {
+			call: func(c *Client) error {
+				return c.Generations.ChangeSource(context.Background(), "g 0", OriginStrain, types.Source{UUID: "s0"})
+			},
+			path: "/generation/g 0/sources/strain/s0",
+			raw:  "/generation/g%200/sources/strain/s0",
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			rp := &replay{responses: []response{{sc: http.StatusNoContent}}}
+			c := newTestClient(t, rp)
+
+			_ = tc.call(c)
+			require.Len(t, rp.requests, 1)
+			require.Equal(t, tc.path, rp.requests[0].URL.Path)
+			require.Equal(t, tc.raw, rp.requests[0].URL.EscapedPath())
+		})
+	}
+}
+
+func Test_Resolve(t *testing.T) {
+	t.Parallel()
+
+	rp := &replay{responses: []response{{
+		sc:     http.StatusFound,
+		body:   `{"kind":"lifecycle","id":"lc0"}`,
+		header: map[string]string{"Location": "/lifecycle/lc0"},
+	}}}
+	c := newTestClient(t, rp)
+
+	ref, err := c.Resolve(context.Background(), "LC-2026-0142")
+	require.Nil(t, err)
+	require.Equal(t, Ref{Kind: "lifecycle", ID: "lc0"}, ref)
+	// the redirect wasn't followed
+	require.Len(t, rp.requests, 1)
+}
+
+func Test_Batch(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		sc  int
+		err error
+	}{
+		"happy_path": {sc: http.StatusOK},
+		"rolled_back": {
+			sc:  http.StatusBadRequest,
+			err: ErrBadRequest,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			rp := &replay{responses: []response{{
+				sc:   tc.sc,
+				body: `{"atomic":true,"succeeded":1,"failed":1,"rolled_back":true,"results":[{"status":201,"id":"v0"},{"status":400}]}`,
+			}}}
+			c := newTestClient(t, rp)
+
+			item, err := NewBatchItem(http.MethodPost, "/vendor", types.Vendor{Name: name})
+			require.Nil(t, err)
+
+			result, err := c.Batch(context.Background(), BatchRequest{Atomic: true, Requests: []BatchItem{item}})
+			require.Equal(t, tc.err == nil, err == nil, err)
+			if tc.err != nil {
+				require.True(t, errors.Is(err, tc.err))
+			}
+			// what happened to each request is there either way
+			require.Len(t, result.Results, 2)
+			require.True(t, result.RolledBack)
+
+			var sent BatchRequest
+			require.Nil(t, json.Unmarshal(rp.bodies[0], &sent))
+			var v types.Vendor
+			require.Nil(t, json.Unmarshal(sent.Requests[0].Body, &v))
+			require.Equal(t, name, v.Name)
+		})
+	}
+}
+
+func Test_Import(t *testing.T) {
+	t.Parallel()
+
+	rp := &replay{responses: []response{{
+		sc:   http.StatusOK,
+		body: `{"mode":"preserve","added":{"lifecycles":4},"files":2}`,
+	}}}
+	c := newTestClient(t, rp)
+
+	report, err := c.Import(context.Background(), []byte("tarball"), ImportPreserve)
+	require.Nil(t, err)
+	require.Equal(t, ImportReport{Mode: ImportPreserve, Added: map[string]int{"lifecycles": 4}, Files: 2}, report)
+
+	require.Len(t, rp.requests, 1)
+	require.Equal(t, "/admin/import", rp.requests[0].URL.Path)
+	require.Equal(t, ImportPreserve, rp.requests[0].URL.Query().Get("mode"))
+	require.Equal(t, "application/gzip", rp.requests[0].Header.Get("Content-Type"))
+	require.Equal(t, "tarball", string(rp.bodies[0]))
+}
+
+func Test_VerifyBackup(t *testing.T) {
+	t.Parallel()
+
+	rp := &replay{responses: []response{{
+		sc:   http.StatusOK,
+		body: `{"name":"cffc-backup-20261019T030000Z.tar.gz","ok":false,"error":"backup doesn't match its checksum"}`,
+	}}}
+	c := newTestClient(t, rp)
+
+	check, err := c.VerifyBackup(context.Background(), "cffc-backup-20261019T030000Z.tar.gz")
+	require.Nil(t, err)
+	require.Equal(t, BackupCheck{
+		Name:  "cffc-backup-20261019T030000Z.tar.gz",
+		Error: "backup doesn't match its checksum",
+	}, check)
+
+	require.Len(t, rp.requests, 1)
+	require.Equal(t, http.MethodPost, rp.requests[0].Method)
+	require.Equal(t, "/admin/backups/cffc-backup-20261019T030000Z.tar.gz/verify", rp.requests[0].URL.Path)
+}
+
+func Test_ImportCSV(t *testing.T) {
+	t.Parallel()
+
+	rp := &replay{responses: []response{{
+		sc:   http.StatusMultiStatus,
+		body: `{"dry_run":true,"rows":[{"row":2,"status":422,"error":"no location"}],"created":{},"failed":1}`,
+	}}}
+	c := newTestClient(t, rp)
+
+	report, err := c.ImportCSV(context.Background(), []byte("Tub\n\n"), CSVMapping{Columns: map[string]string{"location": "Tub"}}, true)
+	require.Nil(t, err)
+	require.Equal(t, CSVReport{
+		DryRun:  true,
+		Rows:    []CSVRow{{Row: 2, Status: http.StatusUnprocessableEntity, Error: "no location"}},
+		Created: map[string]int{},
+		Failed:  1,
+	}, report)
+
+	require.Len(t, rp.requests, 1)
+	require.Equal(t, "/import/csv", rp.requests[0].URL.Path)
+	require.Equal(t, "true", rp.requests[0].URL.Query().Get("dry_run"))
+	require.Contains(t, rp.requests[0].Header.Get("Content-Type"), "multipart/form-data")
+	require.Contains(t, string(rp.bodies[0]), `{"columns":{"location":"Tub"}}`)
+}
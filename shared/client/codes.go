@@ -0,0 +1,85 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/jsmit257/huautla/types"
+)
+
+type (
+	// Ref is what a short code or label stands for
+	Ref struct {
+		// lifecycle, generation or strain
+		Kind string     `json:"kind"`
+		ID   types.UUID `json:"id"`
+	}
+
+	LabelOptions struct {
+		// IDs are short codes or ids of lifecycles, generations or strains;
+		// the same one twice gets two labels
+		IDs []string
+		// pdf, the default, or png
+		Format string
+		// Layout is the Avery sheet, like 5160
+		Layout string
+		// Skip is how many labels on the first sheet are already used
+		Skip int
+		// Page and DPI are only for png, which is one page at a time
+		Page int
+		DPI  int
+	}
+
+	Labels struct {
+		File
+		Pages int
+	}
+)
+
+// Resolve is what a short code, like LC-2026-0142, stands for
+func (c *Client) Resolve(ctx context.Context, code string) (Ref, error) {
+	return c.ref(ctx, join("resolve", code))
+}
+
+// Scan is what a label's qr code stands for; payload can be the whole url
+// in it, a short code or an id
+func (c *Client) Scan(ctx context.Context, payload string) (Ref, error) {
+	return c.ref(ctx, join("scan", payload))
+}
+
+// Labels prints a label for everything in opts.IDs
+func (c *Client) Labels(ctx context.Context, opts LabelOptions) (Labels, error) {
+	q := url.Values{"ids": {strings.Join(opts.IDs, ",")}}
+	for k, v := range map[string]string{"format": opts.Format, "layout": opts.Layout} {
+		if v != "" {
+			q.Set(k, v)
+		}
+	}
+	for k, v := range map[string]int{"skip": opts.Skip, "page": opts.Page, "dpi": opts.DPI} {
+		if v != 0 {
+			q.Set(k, strconv.Itoa(v))
+		}
+	}
+
+	res, body, err := c.do(ctx, request{method: http.MethodGet, path: "/labels", query: q}, http.StatusOK)
+	if err != nil {
+		return Labels{}, err
+	}
+
+	result := Labels{File: fileFrom(res, body)}
+	result.Pages, _ = strconv.Atoi(res.Header.Get("X-Label-Pages"))
+	return result, nil
+}
+
+func (c *Client) ref(ctx context.Context, path string) (Ref, error) {
+	var result Ref
+	if _, body, err := c.do(ctx, request{method: http.MethodGet, path: path}, http.StatusFound); err != nil {
+		return result, err
+	} else {
+		return result, json.Unmarshal(body, &result)
+	}
+}
@@ -0,0 +1,153 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jsmit257/huautla/types"
+)
+
+type (
+	// EventService is what lifecycles and generations have in common:
+	// adding the same event to lots of them, and hearing about everything
+	// that changes
+	EventService struct{ c *Client }
+
+	// BulkEvents is one event for lots of things; it goes to every
+	// lifecycle and generation listed, and every lifecycle at Location
+	BulkEvents struct {
+		Event       types.Event  `json:"event"`
+		Lifecycles  []types.UUID `json:"lifecycles,omitempty"`
+		Generations []types.UUID `json:"generations,omitempty"`
+		Location    string       `json:"location,omitempty"`
+	}
+
+	BulkEventResult struct {
+		// lifecycle or generation
+		Kind   string       `json:"kind"`
+		ID     types.UUID   `json:"id"`
+		Status int          `json:"status"`
+		Event  *types.Event `json:"event,omitempty"`
+		Error  string       `json:"error,omitempty"`
+	}
+
+	// StreamEvent is something that happened to something; Type is
+	// `<entity>.<what happened>`, like `lifecycle.created`
+	StreamEvent struct {
+		Seq      uint64          `json:"seq,omitempty"`
+		ID       string          `json:"id"`
+		Type     string          `json:"type"`
+		EntityID types.UUID      `json:"entity_id"`
+		Actor    string          `json:"actor,omitempty"`
+		Cid      types.CID       `json:"cid"`
+		Time     time.Time       `json:"time"`
+		Payload  json.RawMessage `json:"payload,omitempty"`
+	}
+
+	StreamOptions struct {
+		// Entities narrows it down to types of things, like lifecycle or
+		// photo
+		Entities []string
+		// IDs narrows it down to particular things, or things that belong
+		// to them
+		IDs []types.UUID
+		// LastEventID starts with whatever happened after it; without one
+		// it starts with whatever happens next
+		LastEventID *uint64
+	}
+)
+
+// Bulk adds b's event to everything in b, and says what happened to each
+// of them; some of them failing isn't an error, so check their Status
+func (es *EventService) Bulk(ctx context.Context, b BulkEvents) ([]BulkEventResult, error) {
+	return send[[]BulkEventResult](ctx, es.c, http.MethodPost, "/events/bulk", b, http.StatusCreated, http.StatusMultiStatus)
+}
+
+// Stream calls fn with every event, as it happens, until ctx is done, fn
+// returns an error or the server goes away. Only ctx being done isn't an
+// error; it isn't retried, since LastEventID can pick up where it left off
+func (es *EventService) Stream(ctx context.Context, opts StreamOptions, fn func(StreamEvent) error) error {
+	q := url.Values{}
+	if len(opts.Entities) > 0 {
+		q.Set("entity", strings.Join(opts.Entities, ","))
+	}
+	for _, id := range opts.IDs {
+		q.Add("id", string(id))
+	}
+
+	u := es.c.url("/stream", q)
+
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	cid := cidFrom(ctx)
+	r.Header.Set(CidHeader, string(cid))
+	r.Header.Set("Accept", "text/event-stream")
+	if opts.LastEventID != nil {
+		r.Header.Set("Last-Event-ID", strconv.FormatUint(*opts.LastEventID, 10))
+	}
+	if cookie := es.c.Cookie(); cookie != nil {
+		r.AddCookie(cookie)
+	}
+
+	// the timeout is for the whole response, and this one never ends
+	hc := *es.c.hc
+	hc.Timeout = 0
+
+	res, err := hc.Do(r)
+	if err != nil {
+		return streamErr(ctx, err)
+	}
+	defer res.Body.Close()
+
+	es.c.refresh(res)
+
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 1<<10))
+		return newError(request{method: http.MethodGet, path: "/stream"}, res, body, cid)
+	}
+
+	var data strings.Builder
+	scanner := bufio.NewScanner(res.Body)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		// only data matters, since the event has its own id and type
+		if line := scanner.Text(); line != "" {
+			if v, ok := strings.CutPrefix(line, "data:"); ok {
+				data.WriteString(strings.TrimPrefix(v, " "))
+			}
+			continue
+		} else if data.Len() == 0 {
+			continue
+		}
+
+		var e StreamEvent
+		if err = json.Unmarshal([]byte(data.String()), &e); err != nil {
+			return err
+		} else if err = fn(e); err != nil {
+			return err
+		}
+		data.Reset()
+	}
+
+	return streamErr(ctx, scanner.Err())
+}
+
+func streamErr(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return nil
+	} else if err == nil {
+		return fmt.Errorf("%w: the server closed the stream", ErrUnavailable)
+	}
+	return err
+}
@@ -0,0 +1,125 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/huautla/types"
+)
+
+func Test_Stream(t *testing.T) {
+	t.Parallel()
+
+	stop := errors.New("that's enough")
+
+	tcs := map[string]struct {
+		body   string
+		sc     int
+		last   *uint64
+		stopAt int
+		events []StreamEvent
+		err    error
+	}{
+		"happy_path": {
+			body: ": keepalive\n\n" +
+				"id: 7\nevent: lifecycle.created\ndata: {\"seq\":7,\"type\":\"lifecycle.created\",\"entity_id\":\"lc0\"}\n\n" +
+				": keepalive\n\n" +
+				"id: 8\nevent: photo.added\ndata: {\"seq\":8,\"type\":\"photo.added\",\"entity_id\":\"p0\"}\n\n",
+			sc:     http.StatusOK,
+			stopAt: 2,
+			events: []StreamEvent{
+				{Seq: 7, Type: "lifecycle.created", EntityID: "lc0"},
+				{Seq: 8, Type: "photo.added", EntityID: "p0"},
+			},
+			err: stop,
+		},
+		"resumed": {
+			body:   "id: 9\nevent: note.added\ndata: {\"seq\":9,\"type\":\"note.added\",\"entity_id\":\"n0\"}\n\n",
+			sc:     http.StatusOK,
+			last:   ptr(uint64(8)),
+			stopAt: 1,
+			events: []StreamEvent{{Seq: 9, Type: "note.added", EntityID: "n0"}},
+			err:    stop,
+		},
+		"server_went_away": {
+			sc:  http.StatusOK,
+			err: ErrUnavailable,
+		},
+		"forbidden": {
+			sc:  http.StatusForbidden,
+			err: ErrForbidden,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var got *http.Request
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r
+				w.WriteHeader(tc.sc)
+				_, _ = fmt.Fprint(w, tc.body)
+			}))
+			defer srv.Close()
+
+			c, err := New(srv.URL)
+			require.Nil(t, err)
+
+			var events []StreamEvent
+			err = c.Events.Stream(context.Background(), StreamOptions{
+				Entities:    []string{"lifecycle", "photo"},
+				IDs:         []types.UUID{"lc0", "lc1"},
+				LastEventID: tc.last,
+			}, func(e StreamEvent) error {
+				events = append(events, e)
+				if len(events) == tc.stopAt {
+					return stop
+				}
+				return nil
+			})
+			require.True(t, errors.Is(err, tc.err), err)
+			require.Equal(t, tc.events, events)
+
+			require.Equal(t, "lifecycle,photo", got.URL.Query().Get("entity"))
+			require.Equal(t, []string{"lc0", "lc1"}, got.URL.Query()["id"])
+			if tc.last != nil {
+				require.Equal(t, fmt.Sprint(*tc.last), got.Header.Get("Last-Event-ID"))
+			} else {
+				require.Empty(t, got.Header.Get("Last-Event-ID"))
+			}
+		})
+	}
+}
+
+func Test_Stream_cancelled(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	c, err := New(srv.URL)
+	require.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- c.Events.Stream(ctx, StreamOptions{}, func(StreamEvent) error { return nil }) }()
+
+	cancel()
+	require.Nil(t, <-done)
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
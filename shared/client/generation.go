@@ -0,0 +1,91 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/jsmit257/huautla/types"
+)
+
+type (
+	GenerationService struct{ c *Client }
+
+	// Inoculation is Count lifecycles that are the same except for where
+	// they are; costs are for all of them together
+	Inoculation struct {
+		Count int `json:"count"`
+		// the first one's number; 0 is the same as 1
+		Start     int             `json:"start,omitempty"`
+		Lifecycle types.Lifecycle `json:"lifecycle"`
+	}
+
+	InoculationResult struct {
+		Generation types.UUID        `json:"generation"`
+		Lifecycles []types.Lifecycle `json:"lifecycles"`
+	}
+)
+
+// the things a generation's source can come from
+const (
+	OriginStrain = "strain"
+	OriginEvent  = "event"
+)
+
+func (gs *GenerationService) All(ctx context.Context) ([]types.Generation, error) {
+	return get[[]types.Generation](ctx, gs.c, "/generations", nil)
+}
+
+func (gs *GenerationService) Get(ctx context.Context, id types.UUID) (types.Generation, error) {
+	return get[types.Generation](ctx, gs.c, join("generation", string(id)), nil)
+}
+
+func (gs *GenerationService) Create(ctx context.Context, g types.Generation) (types.Generation, error) {
+	return send[types.Generation](ctx, gs.c, http.MethodPost, "/generation", g, http.StatusCreated)
+}
+
+// Update changes the generation with g's id
+func (gs *GenerationService) Update(ctx context.Context, g types.Generation) (types.Generation, error) {
+	return send[types.Generation](ctx, gs.c, http.MethodPatch, join("generation", string(g.UUID)), g, http.StatusOK)
+}
+
+func (gs *GenerationService) Delete(ctx context.Context, id types.UUID) error {
+	return call(ctx, gs.c, http.MethodDelete, join("generation", string(id)), nil, http.StatusNoContent)
+}
+
+func (gs *GenerationService) Report(ctx context.Context, id types.UUID) (types.Entity, error) {
+	return get[types.Entity](ctx, gs.c, join("reports", "generation", string(id)), nil)
+}
+
+// AddEvent is the generation with e in it
+func (gs *GenerationService) AddEvent(ctx context.Context, id types.UUID, e types.Event) (types.Generation, error) {
+	return send[types.Generation](ctx, gs.c, http.MethodPost, join("generation", string(id), "events"), e, http.StatusCreated)
+}
+
+// ChangeEvent changes the event with e's id
+func (gs *GenerationService) ChangeEvent(ctx context.Context, id types.UUID, e types.Event) (types.Generation, error) {
+	return send[types.Generation](ctx, gs.c, http.MethodPatch, join("generation", string(id), "events"), e, http.StatusOK)
+}
+
+func (gs *GenerationService) RemoveEvent(ctx context.Context, id, evID types.UUID) (types.Generation, error) {
+	return send[types.Generation](ctx, gs.c, http.MethodDelete, join("generation", string(id), "events", string(evID)), nil, http.StatusOK)
+}
+
+// Inoculate makes a batch of lifecycles from the generation
+func (gs *GenerationService) Inoculate(ctx context.Context, id types.UUID, in Inoculation) (InoculationResult, error) {
+	return send[InoculationResult](ctx, gs.c, http.MethodPost, join("generation", string(id), "lifecycles"), in, http.StatusCreated)
+}
+
+// AddSource says what the generation came from; origin is OriginStrain or
+// OriginEvent
+func (gs *GenerationService) AddSource(ctx context.Context, id types.UUID, origin string, s types.Source) (types.Source, error) {
+	return send[types.Source](ctx, gs.c, http.MethodPost, join("generation", string(id), "sources", origin), s, http.StatusCreated)
+}
+
+// ChangeSource changes the source with s's id
+func (gs *GenerationService) ChangeSource(ctx context.Context, id types.UUID, origin string, s types.Source) error {
+	return call(ctx, gs.c, http.MethodPatch, join("generation", string(id), "sources", origin, string(s.UUID)), s, http.StatusNoContent)
+}
+
+func (gs *GenerationService) RemoveSource(ctx context.Context, id, sID types.UUID) (types.Generation, error) {
+	return send[types.Generation](ctx, gs.c, http.MethodDelete, join("generation", string(id), "sources", string(sID)), nil, http.StatusOK)
+}
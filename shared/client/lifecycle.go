@@ -0,0 +1,155 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/jsmit257/huautla/types"
+)
+
+type (
+	LifecycleService struct{ c *Client }
+
+	// LifecycleLink is one end of a split or a merge
+	LifecycleLink struct {
+		ID types.UUID `json:"id"`
+		// split or merge
+		Kind string `json:"kind"`
+		// how much of the parent went into the child, from 0 to 1
+		Share float64   `json:"share"`
+		CTime time.Time `json:"ctime"`
+	}
+
+	SplitChild struct {
+		types.Lifecycle
+		// how much of the parent this child gets, compared to the others;
+		// 0 is the same as 1
+		Share float64 `json:"share,omitempty"`
+	}
+
+	SplitResult struct {
+		Parent   types.UUID        `json:"parent"`
+		Children []types.Lifecycle `json:"children"`
+	}
+
+	MergeParent struct {
+		ID types.UUID `json:"id"`
+		// how much of the parent goes into the merge; 0 is all of it
+		Share float64 `json:"share,omitempty"`
+	}
+
+	Merge struct {
+		Parents   []MergeParent   `json:"parents"`
+		Lifecycle types.Lifecycle `json:"lifecycle"`
+	}
+
+	MergeResult struct {
+		types.Lifecycle
+		Parents []LifecycleLink `json:"parents"`
+	}
+
+	// LineageNode is a lifecycle and everything it came from and went into
+	LineageNode struct {
+		ID       types.UUID `json:"id"`
+		Location string     `json:"location,omitempty"`
+		Kind     string     `json:"kind,omitempty"`
+		Share    float64    `json:"share,omitempty"`
+		// it's been deleted since
+		Missing  bool          `json:"missing,omitempty"`
+		Parents  []LineageNode `json:"parents,omitempty"`
+		Children []LineageNode `json:"children,omitempty"`
+	}
+
+	// TimelapseOptions are all optional; the server's defaults are a 640
+	// pixel wide gif, half a second a frame, with dates on it
+	TimelapseOptions struct {
+		// gif or apng
+		Format string
+		Width  int
+		Height int
+		Delay  time.Duration
+		// Overlay is only sent when it's set
+		Overlay *bool
+	}
+)
+
+func (ls *LifecycleService) All(ctx context.Context) ([]types.Lifecycle, error) {
+	return get[[]types.Lifecycle](ctx, ls.c, "/lifecycles", nil)
+}
+
+func (ls *LifecycleService) Get(ctx context.Context, id types.UUID) (types.Lifecycle, error) {
+	return get[types.Lifecycle](ctx, ls.c, join("lifecycle", string(id)), nil)
+}
+
+func (ls *LifecycleService) Create(ctx context.Context, l types.Lifecycle) (types.Lifecycle, error) {
+	return send[types.Lifecycle](ctx, ls.c, http.MethodPost, "/lifecycle", l, http.StatusCreated)
+}
+
+// Update changes the lifecycle with l's id
+func (ls *LifecycleService) Update(ctx context.Context, l types.Lifecycle) (types.Lifecycle, error) {
+	return send[types.Lifecycle](ctx, ls.c, http.MethodPatch, join("lifecycle", string(l.UUID)), l, http.StatusOK)
+}
+
+func (ls *LifecycleService) Delete(ctx context.Context, id types.UUID) error {
+	return call(ctx, ls.c, http.MethodDelete, join("lifecycle", string(id)), nil, http.StatusNoContent)
+}
+
+func (ls *LifecycleService) Report(ctx context.Context, id types.UUID) (types.Entity, error) {
+	return get[types.Entity](ctx, ls.c, join("reports", "lifecycle", string(id)), nil)
+}
+
+// Timelapse animates the lifecycle's photos; it's ErrNotFound if there
+// aren't any
+func (ls *LifecycleService) Timelapse(ctx context.Context, id types.UUID, opts TimelapseOptions) (File, error) {
+	q := url.Values{}
+	if opts.Format != "" {
+		q.Set("format", opts.Format)
+	}
+	if opts.Width != 0 {
+		q.Set("width", strconv.Itoa(opts.Width))
+	}
+	if opts.Height != 0 {
+		q.Set("height", strconv.Itoa(opts.Height))
+	}
+	if opts.Delay != 0 {
+		q.Set("delay", strconv.FormatInt(opts.Delay.Milliseconds(), 10))
+	}
+	if opts.Overlay != nil {
+		q.Set("overlay", strconv.FormatBool(*opts.Overlay))
+	}
+
+	return ls.c.file(ctx, request{method: http.MethodGet, path: join("lifecycle", string(id), "timelapse"), query: q})
+}
+
+// Split makes a lifecycle for each child, from the lifecycle id
+func (ls *LifecycleService) Split(ctx context.Context, id types.UUID, children []SplitChild) (SplitResult, error) {
+	return send[SplitResult](ctx, ls.c, http.MethodPost, join("lifecycle", string(id), "split"), struct {
+		Children []SplitChild `json:"children"`
+	}{children}, http.StatusCreated)
+}
+
+// Merge makes one lifecycle from several of the same strain
+func (ls *LifecycleService) Merge(ctx context.Context, m Merge) (MergeResult, error) {
+	return send[MergeResult](ctx, ls.c, http.MethodPost, "/lifecycles/merge", m, http.StatusCreated)
+}
+
+func (ls *LifecycleService) Lineage(ctx context.Context, id types.UUID) (LineageNode, error) {
+	return get[LineageNode](ctx, ls.c, join("lifecycle", string(id), "lineage"), nil)
+}
+
+// AddEvent is the lifecycle with e in it
+func (ls *LifecycleService) AddEvent(ctx context.Context, id types.UUID, e types.Event) (types.Lifecycle, error) {
+	return send[types.Lifecycle](ctx, ls.c, http.MethodPost, join("lifecycle", string(id), "events"), e, http.StatusCreated)
+}
+
+// ChangeEvent changes the event with e's id
+func (ls *LifecycleService) ChangeEvent(ctx context.Context, id types.UUID, e types.Event) (types.Lifecycle, error) {
+	return send[types.Lifecycle](ctx, ls.c, http.MethodPatch, join("lifecycle", string(id), "events"), e, http.StatusOK)
+}
+
+func (ls *LifecycleService) RemoveEvent(ctx context.Context, id, evID types.UUID) (types.Lifecycle, error) {
+	return send[types.Lifecycle](ctx, ls.c, http.MethodDelete, join("lifecycle", string(id), "events", string(evID)), nil, http.StatusOK)
+}
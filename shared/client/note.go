@@ -0,0 +1,29 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/jsmit257/huautla/types"
+)
+
+// NoteService is notes on anything with an id; every change is answered
+// with all of the owner's notes
+type NoteService struct{ c *Client }
+
+func (ns *NoteService) List(ctx context.Context, owner types.UUID) ([]types.Note, error) {
+	return get[[]types.Note](ctx, ns.c, join("notes", string(owner)), nil)
+}
+
+func (ns *NoteService) Add(ctx context.Context, owner types.UUID, n types.Note) ([]types.Note, error) {
+	return send[[]types.Note](ctx, ns.c, http.MethodPost, join("notes", string(owner)), n, http.StatusOK)
+}
+
+// Change changes the note with n's id
+func (ns *NoteService) Change(ctx context.Context, owner types.UUID, n types.Note) ([]types.Note, error) {
+	return send[[]types.Note](ctx, ns.c, http.MethodPatch, join("notes", string(owner)), n, http.StatusOK)
+}
+
+func (ns *NoteService) Remove(ctx context.Context, owner, id types.UUID) ([]types.Note, error) {
+	return send[[]types.Note](ctx, ns.c, http.MethodDelete, join("notes", string(owner), string(id)), nil, http.StatusOK)
+}
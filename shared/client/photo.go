@@ -0,0 +1,100 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/jsmit257/huautla/types"
+)
+
+type (
+	// PhotoService is photos of anything with an id; every change is
+	// answered with all of the owner's photos
+	PhotoService struct{ c *Client }
+
+	Photo struct {
+		types.Photo
+		// Meta is nil for photos from before there was any
+		Meta *PhotoMeta `json:"meta,omitempty"`
+	}
+
+	// PhotoMeta is everything the server worked out from the image itself
+	PhotoMeta struct {
+		Owner        types.UUID    `json:"owner"`
+		Exif         *Exif         `json:"exif,omitempty"`
+		Colonization *Colonization `json:"colonization,omitempty"`
+		PHash        string        `json:"phash,omitempty"`
+		// near-duplicates with the same owner at the time of upload
+		Duplicates []types.UUID `json:"duplicates,omitempty"`
+	}
+
+	Exif struct {
+		Captured     *time.Time `json:"captured,omitempty"`
+		Make         string     `json:"make,omitempty"`
+		Model        string     `json:"model,omitempty"`
+		Lens         string     `json:"lens,omitempty"`
+		ExposureTime string     `json:"exposure_time,omitempty"`
+		FNumber      float64    `json:"f_number,omitempty"`
+		ISO          int        `json:"iso,omitempty"`
+		FocalLength  float64    `json:"focal_length,omitempty"`
+		GPS          *GPS       `json:"gps,omitempty"`
+	}
+
+	GPS struct {
+		Latitude  float64  `json:"latitude"`
+		Longitude float64  `json:"longitude"`
+		Altitude  *float64 `json:"altitude,omitempty"`
+	}
+
+	Colonization struct {
+		Percent float64 `json:"percent"`
+		// the substrate whose profile was used, if it wasn't the default
+		Substrate types.UUID       `json:"substrate,omitempty"`
+		Suggested *types.EventType `json:"suggested_event_type,omitempty"`
+	}
+
+	DuplicatePhoto struct {
+		UUID  types.UUID `json:"id"`
+		Owner types.UUID `json:"owner"`
+		PHash string     `json:"phash"`
+	}
+
+	// DuplicateCluster is photos that all look like at least one other
+	// photo in it
+	DuplicateCluster struct {
+		Photos []DuplicatePhoto `json:"photos"`
+		Owners []types.UUID     `json:"owners"`
+	}
+)
+
+func (ps *PhotoService) List(ctx context.Context, owner types.UUID) ([]Photo, error) {
+	return get[[]Photo](ctx, ps.c, join("photos", string(owner)), nil)
+}
+
+// Add uploads f; it's ErrConflict if the server rejects near-duplicates and
+// f is one
+func (ps *PhotoService) Add(ctx context.Context, owner types.UUID, f File) ([]Photo, error) {
+	return upload[[]Photo](ctx, ps.c, http.MethodPost, join("photos", string(owner)), f)
+}
+
+// Replace uploads f in place of the photo id
+func (ps *PhotoService) Replace(ctx context.Context, owner, id types.UUID, f File) ([]Photo, error) {
+	return upload[[]Photo](ctx, ps.c, http.MethodPatch, join("photos", string(owner), string(id)), f)
+}
+
+func (ps *PhotoService) Remove(ctx context.Context, owner, id types.UUID) ([]Photo, error) {
+	return send[[]Photo](ctx, ps.c, http.MethodDelete, join("photos", string(owner), string(id)), nil, http.StatusOK)
+}
+
+// Duplicates is every cluster of similar photos in the album; a negative
+// distance uses the server's
+func (ps *PhotoService) Duplicates(ctx context.Context, distance int) ([]DuplicateCluster, error) {
+	q := url.Values{}
+	if distance >= 0 {
+		q.Set("distance", strconv.Itoa(distance))
+	}
+	return get[[]DuplicateCluster](ctx, ps.c, "/admin/photos/duplicates", q)
+}
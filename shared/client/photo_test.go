@@ -0,0 +1,75 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_upload(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		f   File
+		ct  string
+		sc  int
+		err error
+	}{
+		"happy_path": {
+			f:  File{Name: "jar.png", ContentType: "image/png", Data: []byte("\x89PNG")},
+			ct: "image/png",
+			sc: http.StatusOK,
+		},
+		"no_content_type": {
+			f:  File{Name: "jar", Data: []byte("???")},
+			ct: "application/octet-stream",
+			sc: http.StatusOK,
+		},
+		"duplicate": {
+			f:   File{Name: "jar.png", ContentType: "image/png", Data: []byte("\x89PNG")},
+			ct:  "image/png",
+			sc:  http.StatusConflict,
+			err: ErrConflict,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				f, fh, err := r.FormFile("file")
+				require.Nil(t, err)
+				data, err := io.ReadAll(f)
+				require.Nil(t, err)
+
+				require.Equal(t, "/photos/lc0", r.URL.Path)
+				require.Equal(t, tc.f.Name, fh.Filename)
+				require.Equal(t, tc.ct, fh.Header.Get("Content-Type"))
+				require.Equal(t, tc.f.Data, data)
+
+				w.WriteHeader(tc.sc)
+				_, _ = w.Write([]byte(`[{"id":"p0","filename":"p0.png","meta":{"owner":"lc0","phash":"00ff"}}]`))
+			}))
+			defer srv.Close()
+
+			c, err := New(srv.URL)
+			require.Nil(t, err)
+
+			photos, err := c.Photos.Add(context.Background(), "lc0", tc.f)
+			if tc.err != nil {
+				require.True(t, errors.Is(err, tc.err), err)
+				return
+			}
+			require.Nil(t, err)
+			require.Len(t, photos, 1)
+			require.Equal(t, "00ff", photos[0].Meta.PHash)
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/jsmit257/huautla/types"
+)
+
+type (
+	VendorService     struct{ c *Client }
+	StageService      struct{ c *Client }
+	EventTypeService  struct{ c *Client }
+	IngredientService struct{ c *Client }
+
+	SubstrateService struct{ c *Client }
+
+	// ColonizationProfile is how a substrate's photos are judged for
+	// colonization
+	ColonizationProfile struct {
+		// pixels at least this bright...
+		MinValue float64 `json:"min_value"`
+		// ...and at most this saturated are counted as mycelium
+		MaxSaturation float64 `json:"max_saturation"`
+		// pixels darker than this are counted as neither
+		IgnoreBelow float64 `json:"ignore_below"`
+		// how much of each edge is cropped before counting
+		Margin float64 `json:"margin"`
+		// the percent coverage that counts as 50% and 100% colonization
+		Half float64 `json:"half"`
+		Full float64 `json:"full"`
+	}
+)
+
+func (vs *VendorService) All(ctx context.Context) ([]types.Vendor, error) {
+	return get[[]types.Vendor](ctx, vs.c, "/vendors", nil)
+}
+
+func (vs *VendorService) Get(ctx context.Context, id types.UUID) (types.Vendor, error) {
+	return get[types.Vendor](ctx, vs.c, join("vendor", string(id)), nil)
+}
+
+func (vs *VendorService) Create(ctx context.Context, v types.Vendor) (types.Vendor, error) {
+	return send[types.Vendor](ctx, vs.c, http.MethodPost, "/vendor", v, http.StatusCreated)
+}
+
+func (vs *VendorService) Update(ctx context.Context, id types.UUID, v types.Vendor) error {
+	return call(ctx, vs.c, http.MethodPatch, join("vendor", string(id)), v, http.StatusNoContent)
+}
+
+func (vs *VendorService) Delete(ctx context.Context, id types.UUID) error {
+	return call(ctx, vs.c, http.MethodDelete, join("vendor", string(id)), nil, http.StatusNoContent)
+}
+
+func (vs *VendorService) Report(ctx context.Context, id types.UUID) (types.Entity, error) {
+	return get[types.Entity](ctx, vs.c, join("reports", "vendor", string(id)), nil)
+}
+
+func (ss *StageService) All(ctx context.Context) ([]types.Stage, error) {
+	return get[[]types.Stage](ctx, ss.c, "/stages", nil)
+}
+
+func (ss *StageService) Get(ctx context.Context, id types.UUID) (types.Stage, error) {
+	return get[types.Stage](ctx, ss.c, join("stage", string(id)), nil)
+}
+
+func (ss *StageService) Create(ctx context.Context, s types.Stage) (types.Stage, error) {
+	return send[types.Stage](ctx, ss.c, http.MethodPost, "/stage", s, http.StatusCreated)
+}
+
+func (ss *StageService) Update(ctx context.Context, id types.UUID, s types.Stage) error {
+	return call(ctx, ss.c, http.MethodPatch, join("stage", string(id)), s, http.StatusNoContent)
+}
+
+func (ss *StageService) Delete(ctx context.Context, id types.UUID) error {
+	return call(ctx, ss.c, http.MethodDelete, join("stage", string(id)), nil, http.StatusNoContent)
+}
+
+func (es *EventTypeService) All(ctx context.Context) ([]types.EventType, error) {
+	return get[[]types.EventType](ctx, es.c, "/eventtypes", nil)
+}
+
+func (es *EventTypeService) Get(ctx context.Context, id types.UUID) (types.EventType, error) {
+	return get[types.EventType](ctx, es.c, join("eventtype", string(id)), nil)
+}
+
+func (es *EventTypeService) Create(ctx context.Context, et types.EventType) (types.EventType, error) {
+	return send[types.EventType](ctx, es.c, http.MethodPost, "/eventtype", et, http.StatusCreated)
+}
+
+func (es *EventTypeService) Update(ctx context.Context, id types.UUID, et types.EventType) error {
+	return call(ctx, es.c, http.MethodPatch, join("eventtype", string(id)), et, http.StatusNoContent)
+}
+
+func (es *EventTypeService) Delete(ctx context.Context, id types.UUID) error {
+	return call(ctx, es.c, http.MethodDelete, join("eventtype", string(id)), nil, http.StatusNoContent)
+}
+
+func (es *EventTypeService) Report(ctx context.Context, id types.UUID) (types.Entity, error) {
+	return get[types.Entity](ctx, es.c, join("reports", "eventtype", string(id)), nil)
+}
+
+func (is *IngredientService) All(ctx context.Context) ([]types.Ingredient, error) {
+	return get[[]types.Ingredient](ctx, is.c, "/ingredients", nil)
+}
+
+func (is *IngredientService) Get(ctx context.Context, id types.UUID) (types.Ingredient, error) {
+	return get[types.Ingredient](ctx, is.c, join("ingredient", string(id)), nil)
+}
+
+func (is *IngredientService) Create(ctx context.Context, i types.Ingredient) (types.Ingredient, error) {
+	return send[types.Ingredient](ctx, is.c, http.MethodPost, "/ingredient", i, http.StatusCreated)
+}
+
+func (is *IngredientService) Update(ctx context.Context, id types.UUID, i types.Ingredient) error {
+	return call(ctx, is.c, http.MethodPatch, join("ingredient", string(id)), i, http.StatusNoContent)
+}
+
+func (is *IngredientService) Delete(ctx context.Context, id types.UUID) error {
+	return call(ctx, is.c, http.MethodDelete, join("ingredient", string(id)), nil, http.StatusNoContent)
+}
+
+func (ss *SubstrateService) All(ctx context.Context) ([]types.Substrate, error) {
+	return get[[]types.Substrate](ctx, ss.c, "/substrates", nil)
+}
+
+func (ss *SubstrateService) Get(ctx context.Context, id types.UUID) (types.Substrate, error) {
+	return get[types.Substrate](ctx, ss.c, join("substrate", string(id)), nil)
+}
+
+func (ss *SubstrateService) Create(ctx context.Context, s types.Substrate) (types.Substrate, error) {
+	return send[types.Substrate](ctx, ss.c, http.MethodPost, "/substrate", s, http.StatusCreated)
+}
+
+func (ss *SubstrateService) Update(ctx context.Context, id types.UUID, s types.Substrate) error {
+	return call(ctx, ss.c, http.MethodPatch, join("substrate", string(id)), s, http.StatusNoContent)
+}
+
+func (ss *SubstrateService) Delete(ctx context.Context, id types.UUID) error {
+	return call(ctx, ss.c, http.MethodDelete, join("substrate", string(id)), nil, http.StatusNoContent)
+}
+
+func (ss *SubstrateService) Report(ctx context.Context, id types.UUID) (types.Entity, error) {
+	return get[types.Entity](ctx, ss.c, join("reports", "substrate", string(id)), nil)
+}
+
+// AddIngredient is the substrate with i in it
+func (ss *SubstrateService) AddIngredient(ctx context.Context, id types.UUID, i types.Ingredient) (types.Substrate, error) {
+	return send[types.Substrate](ctx, ss.c, http.MethodPost, join("substrate", string(id), "ingredients"), i, http.StatusCreated)
+}
+
+// ChangeIngredient swaps the ingredient igID for i
+func (ss *SubstrateService) ChangeIngredient(ctx context.Context, id, igID types.UUID, i types.Ingredient) (types.Substrate, error) {
+	return send[types.Substrate](ctx, ss.c, http.MethodPatch, join("substrate", string(id), "ingredients", string(igID)), i, http.StatusOK)
+}
+
+func (ss *SubstrateService) RemoveIngredient(ctx context.Context, id, igID types.UUID) (types.Substrate, error) {
+	return send[types.Substrate](ctx, ss.c, http.MethodDelete, join("substrate", string(id), "ingredients", string(igID)), nil, http.StatusOK)
+}
+
+// Colonization is the substrate's own profile, or the default one
+func (ss *SubstrateService) Colonization(ctx context.Context, id types.UUID) (ColonizationProfile, error) {
+	return get[ColonizationProfile](ctx, ss.c, join("substrate", string(id), "colonization"), nil)
+}
+
+func (ss *SubstrateService) UpdateColonization(ctx context.Context, id types.UUID, p ColonizationProfile) (ColonizationProfile, error) {
+	return send[ColonizationProfile](ctx, ss.c, http.MethodPatch, join("substrate", string(id), "colonization"), p, http.StatusOK)
+}
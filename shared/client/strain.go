@@ -0,0 +1,76 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/jsmit257/huautla/types"
+)
+
+type StrainService struct{ c *Client }
+
+func (ss *StrainService) All(ctx context.Context) ([]types.Strain, error) {
+	return get[[]types.Strain](ctx, ss.c, "/strains", nil)
+}
+
+func (ss *StrainService) Get(ctx context.Context, id types.UUID) (types.Strain, error) {
+	return get[types.Strain](ctx, ss.c, join("strain", string(id)), nil)
+}
+
+func (ss *StrainService) Create(ctx context.Context, s types.Strain) (types.Strain, error) {
+	return send[types.Strain](ctx, ss.c, http.MethodPost, "/strain", s, http.StatusCreated)
+}
+
+func (ss *StrainService) Update(ctx context.Context, id types.UUID, s types.Strain) error {
+	return call(ctx, ss.c, http.MethodPatch, join("strain", string(id)), s, http.StatusNoContent)
+}
+
+func (ss *StrainService) Delete(ctx context.Context, id types.UUID) error {
+	return call(ctx, ss.c, http.MethodDelete, join("strain", string(id)), nil, http.StatusNoContent)
+}
+
+func (ss *StrainService) Report(ctx context.Context, id types.UUID) (types.Entity, error) {
+	return get[types.Entity](ctx, ss.c, join("reports", "strain", string(id)), nil)
+}
+
+// AttributeNames are all the names any strain's attributes have
+func (ss *StrainService) AttributeNames(ctx context.Context) ([]string, error) {
+	return get[[]string](ctx, ss.c, "/strainattributenames", nil)
+}
+
+func (ss *StrainService) AddAttribute(ctx context.Context, id types.UUID, a types.StrainAttribute) (types.StrainAttribute, error) {
+	return send[types.StrainAttribute](ctx, ss.c, http.MethodPost, join("strain", string(id), "attribute"), a, http.StatusCreated)
+}
+
+// ChangeAttribute is the strain with a in it
+func (ss *StrainService) ChangeAttribute(ctx context.Context, id types.UUID, a types.StrainAttribute) (types.Strain, error) {
+	return send[types.Strain](ctx, ss.c, http.MethodPatch, join("strain", string(id), "attribute"), a, http.StatusOK)
+}
+
+func (ss *StrainService) RemoveAttribute(ctx context.Context, id, atID types.UUID) (types.Strain, error) {
+	return send[types.Strain](ctx, ss.c, http.MethodDelete, join("strain", string(id), "attribute", string(atID)), nil, http.StatusOK)
+}
+
+// Generated is the strain that generation gID made; it's ErrNotFound if it
+// didn't make one
+func (ss *StrainService) Generated(ctx context.Context, gID types.UUID) (types.Strain, error) {
+	var result types.Strain
+	req := request{method: http.MethodGet, path: join("strain", string(gID), "generation")}
+	if res, body, err := ss.c.do(ctx, req, http.StatusOK, http.StatusNoContent); err != nil {
+		return result, err
+	} else if res.StatusCode == http.StatusNoContent {
+		return result, ErrNotFound
+	} else {
+		return result, json.Unmarshal(body, &result)
+	}
+}
+
+// SetGeneration says strain id came from generation gID
+func (ss *StrainService) SetGeneration(ctx context.Context, id, gID types.UUID) error {
+	return call(ctx, ss.c, http.MethodPatch, join("strain", string(id), "generation", string(gID)), nil, http.StatusNoContent)
+}
+
+func (ss *StrainService) ClearGeneration(ctx context.Context, id types.UUID) error {
+	return call(ctx, ss.c, http.MethodDelete, join("strain", string(id), "generation"), nil, http.StatusNoContent)
+}
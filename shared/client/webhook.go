@@ -0,0 +1,100 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/jsmit257/huautla/types"
+)
+
+type (
+	WebhookService struct{ c *Client }
+
+	Hook struct {
+		UUID types.UUID `json:"id"`
+		URL  string     `json:"url"`
+		// Secret signs every delivery; it's only ever sent back by Create
+		Secret string `json:"secret,omitempty"`
+		// Types are the events to deliver, like `generation.created`,
+		// `event.*` or `*`
+		Types []string `json:"types"`
+		// EventTypes and Severities narrow event.* down to lifecycle and
+		// generation events of the given name or severity
+		EventTypes []string  `json:"event_types,omitempty"`
+		Severities []string  `json:"severities,omitempty"`
+		Disabled   bool      `json:"disabled,omitempty"`
+		CTime      time.Time `json:"ctime"`
+	}
+
+	Attempt struct {
+		Time   time.Time `json:"time"`
+		Status int       `json:"status,omitempty"`
+		Error  string    `json:"error,omitempty"`
+	}
+
+	Delivery struct {
+		ID       string        `json:"id"`
+		Hook     types.UUID    `json:"hook_id"`
+		Event    StreamEvent   `json:"event"`
+		State    DeliveryState `json:"state"`
+		Attempts []Attempt     `json:"attempts"`
+		// Tries counts attempts since the delivery was made, or last
+		// redelivered
+		Tries int       `json:"tries"`
+		Next  time.Time `json:"next_attempt,omitempty"`
+	}
+
+	DeliveryState string
+)
+
+const (
+	Pending   DeliveryState = "pending"
+	Delivered DeliveryState = "delivered"
+	Dead      DeliveryState = "dead"
+)
+
+// All is every webhook, without their secrets
+func (ws *WebhookService) All(ctx context.Context) ([]Hook, error) {
+	return get[[]Hook](ctx, ws.c, "/webhooks", nil)
+}
+
+func (ws *WebhookService) Get(ctx context.Context, id types.UUID) (Hook, error) {
+	return get[Hook](ctx, ws.c, join("webhooks", string(id)), nil)
+}
+
+// Create is the only time the secret comes back, whether it was sent or
+// the server made one up
+func (ws *WebhookService) Create(ctx context.Context, h Hook) (Hook, error) {
+	return send[Hook](ctx, ws.c, http.MethodPost, "/webhooks", h, http.StatusCreated)
+}
+
+// Update changes the webhook with h's id
+func (ws *WebhookService) Update(ctx context.Context, h Hook) (Hook, error) {
+	return send[Hook](ctx, ws.c, http.MethodPatch, join("webhooks", string(h.UUID)), h, http.StatusOK)
+}
+
+func (ws *WebhookService) Delete(ctx context.Context, id types.UUID) error {
+	return call(ctx, ws.c, http.MethodDelete, join("webhooks", string(id)), nil, http.StatusNoContent)
+}
+
+// Deliveries is the webhook's delivery history, only those in state if
+// there is one
+func (ws *WebhookService) Deliveries(ctx context.Context, id types.UUID, state DeliveryState) ([]Delivery, error) {
+	q := url.Values{}
+	if state != "" {
+		q.Set("state", string(state))
+	}
+	return get[[]Delivery](ctx, ws.c, join("webhooks", string(id), "deliveries"), q)
+}
+
+// DeadLetters is every delivery, for any webhook, that ran out of attempts
+func (ws *WebhookService) DeadLetters(ctx context.Context) ([]Delivery, error) {
+	return get[[]Delivery](ctx, ws.c, "/webhooks/deadletters", nil)
+}
+
+// Redeliver starts the delivery over, however it went the first time
+func (ws *WebhookService) Redeliver(ctx context.Context, id types.UUID, dID string) (Delivery, error) {
+	return send[Delivery](ctx, ws.c, http.MethodPost, join("webhooks", string(id), "deliveries", dID, "redeliver"), nil, http.StatusAccepted)
+}
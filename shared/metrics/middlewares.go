@@ -17,7 +17,12 @@ func WrapContext(log *logrus.Entry) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			start := time.Now()
-			cid := types.CID(uuid.NewString())
+			// callers that already have a correlation id, like a kafka command or
+			// another service, keep it; everyone else gets a new one
+			cid := types.CID(r.Header.Get("Cid"))
+			if cid == "" {
+				cid = types.CID(uuid.NewString())
+			}
 
 			log = log.WithFields(logrus.Fields{
 				"method": r.Method,
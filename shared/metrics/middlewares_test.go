@@ -28,13 +28,19 @@ func Test_WrapContext(t *testing.T) {
 	t.Parallel()
 
 	tcs := map[string]struct {
-		mr *mockRoutes
-		sc int
+		mr  *mockRoutes
+		cid string
+		sc  int
 	}{
 		"happy_path": {
 			sc: http.StatusTeapot,
 			mr: &mockRoutes{},
 		},
+		"caller_cid": {
+			cid: "caller_cid",
+			sc:  http.StatusTeapot,
+			mr:  &mockRoutes{},
+		},
 	}
 
 	for name, tc := range tcs {
@@ -58,9 +64,15 @@ func Test_WrapContext(t *testing.T) {
 				nil,
 			)
 			r.URL.RawPath = "not-empty"
+			r.Header.Set("Cid", tc.cid)
 
 			handle.ServeHTTP(w, r)
 			require.Equal(t, tc.sc, w.Result().StatusCode)
+			if tc.cid != "" {
+				require.Equal(t, tc.cid, w.Header().Get("Cid"))
+			} else {
+				require.NotEmpty(t, w.Header().Get("Cid"))
+			}
 		})
 	}
 }
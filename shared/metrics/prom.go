@@ -15,6 +15,12 @@ const (
 	Cid     ctxkey = "cid"
 	Metrics ctxkey = "metrics"
 	Log     ctxkey = "log"
+	// whoever made the request, as far as authentication can tell; nobody,
+	// without it
+	Actor ctxkey = "actor"
+	// closed when the server starts shutting down, so requests that would
+	// otherwise never finish, like streams, know to
+	Stopping ctxkey = "stopping"
 )
 
 var (
@@ -33,6 +39,22 @@ var (
 		Help:        "Service requests tracked by ???",
 		ConstLabels: prometheus.Labels{},
 	}, []string{"url", "proto", "method", "sc"})
+
+	BackupStatus = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace:   "cffc",
+		Subsystem:   "backup",
+		Name:        "status",
+		Help:        "When backups were last attempted and last worked, how big and slow the last one was, and how many are kept",
+		ConstLabels: prometheus.Labels{},
+	}, []string{"stat"})
+
+	BackupRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   "cffc",
+		Subsystem:   "backup",
+		Name:        "runs",
+		Help:        "Backups taken, by whether they worked",
+		ConstLabels: prometheus.Labels{},
+	}, []string{"result"})
 )
 
 func NewHandler() http.HandlerFunc {
@@ -40,6 +62,8 @@ func NewHandler() http.HandlerFunc {
 
 	reg.MustRegister(DataMetrics)
 	reg.MustRegister(ServiceMetrics)
+	reg.MustRegister(BackupStatus)
+	reg.MustRegister(BackupRuns)
 
 	return promhttp.HandlerFor(reg, promhttp.HandlerOpts{Registry: reg}).ServeHTTP
 }
@@ -51,6 +75,17 @@ func GetContextCID(ctx context.Context) types.CID {
 	return types.CID(fmt.Sprintf("context has no cid attribute: %#v", ctx))
 }
 
+func GetContextActor(ctx context.Context) string {
+	result, _ := ctx.Value(Actor).(string)
+	return result
+}
+
+// GetContextStopping never closes if the server didn't say when it's stopping
+func GetContextStopping(ctx context.Context) <-chan struct{} {
+	result, _ := ctx.Value(Stopping).(<-chan struct{})
+	return result
+}
+
 func GetContextLog(ctx context.Context) *logrus.Entry {
 	if result, ok := ctx.Value(Log).(*logrus.Entry); ok {
 		return result
@@ -41,6 +41,39 @@ func Test_GetContextCID(t *testing.T) {
 	}
 }
 
+func Test_GetContextActor(t *testing.T) {
+	t.Parallel()
+	tcs := map[string]struct {
+		ctx   context.Context
+		actor string
+	}{
+		"happy_path": {
+			ctx:   context.WithValue(context.TODO(), Actor, "us-authn:0123456789abcdef"),
+			actor: "us-authn:0123456789abcdef",
+		},
+		"null_attr": {
+			ctx: context.TODO(),
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tc.actor, GetContextActor(tc.ctx))
+		})
+	}
+}
+
+func Test_GetContextStopping(t *testing.T) {
+	t.Parallel()
+
+	stopping := make(chan struct{})
+	require.Nil(t, GetContextStopping(context.TODO()))
+	require.Equal(t, (<-chan struct{})(stopping), GetContextStopping(
+		context.WithValue(context.TODO(), Stopping, (<-chan struct{})(stopping))))
+}
+
 func Test_GetContextLog(t *testing.T) {
 	t.Parallel()
 	tcs := map[string]struct {
@@ -1,11 +1,7 @@
 package test
 
 import (
-	"bytes"
-	"encoding/json"
-	"fmt"
-	"io"
-	"net/http"
+	"context"
 	"testing"
 
 	"github.com/jsmit257/huautla/types"
@@ -20,35 +16,22 @@ var eventtypes = map[string]map[string]types.EventType{
 	"Vacation":     {},
 }
 
-func init() {
-	url := fmt.Sprintf(`http://%s:%d/eventtypes`, cfg.HTTPHost, cfg.HTTPPort)
-
-	var et []types.EventType
-
-	if req, err := http.NewRequest(
-		http.MethodGet,
-		url,
-		bytes.NewReader(nil)); err != nil {
-		panic(err)
-	} else if req.AddCookie(cookie); false {
-	} else if res, err := http.DefaultClient.Do(req); err != nil {
-		panic(err)
-	} else if res.StatusCode != http.StatusOK {
-		panic(fmt.Errorf("status was not OK: %d", res.StatusCode))
-	} else if b, err := io.ReadAll(res.Body); err != nil {
-		panic(err)
-	} else if err = json.Unmarshal(b, &et); err != nil {
-		panic(err)
+func loadEventTypes() error {
+	et, err := api.EventTypes.All(context.Background())
+	if err != nil {
+		return err
 	}
 
 	for _, v := range et {
+		if _, ok := eventtypes[v.Stage.Name]; !ok {
+			eventtypes[v.Stage.Name] = map[string]types.EventType{}
+		}
 		eventtypes[v.Stage.Name][v.Name] = v
 	}
+	return nil
 }
 
-func Test_HappyLifecycleEvent(t *testing.T) {
-	urlfmt := fmt.Sprintf(`http://%s:%d/lifecycle/%%s/events`, cfg.HTTPHost, cfg.HTTPPort)
-
+func happyLifecycleEvent(t *testing.T) {
 	for lc, v := range map[int][]types.Event{
 		0: {
 			{Humidity: 12, Temperature: 77, EventType: eventtypes["Colonization"]["Innoculation"]},
@@ -67,34 +50,15 @@ func Test_HappyLifecycleEvent(t *testing.T) {
 			{Humidity: 22, Temperature: 76, EventType: eventtypes["Any"]["Clone"]},
 		},
 	} {
-		url := fmt.Sprintf(urlfmt, lifecycles[lc].UUID)
 		for _, e := range v {
-			b, err := json.Marshal(e)
-			require.Nil(t, err)
-
-			req, err := http.NewRequest(
-				http.MethodPost,
-				url,
-				bytes.NewReader(b))
-			require.Nil(t, err)
-			req.AddCookie(cookie)
-
-			res, err := http.DefaultClient.Do(req)
-			require.Nil(t, err)
-			require.Equal(t, http.StatusCreated, res.StatusCode)
-
-			b, err = io.ReadAll(res.Body)
-			require.Nil(t, err)
-
-			err = json.Unmarshal(b, &lifecycles[lc])
+			var err error
+			lifecycles[lc], err = api.Lifecycles.AddEvent(context.Background(), lifecycles[lc].UUID, e)
 			require.Nil(t, err)
 		}
 	}
 }
 
-func Test_HappyGenerationEvent(t *testing.T) {
-	urlfmt := fmt.Sprintf(`http://%s:%d/generation/%%s/events`, cfg.HTTPHost, cfg.HTTPPort)
-
+func happyGenerationEvent(t *testing.T) {
 	for g, v := range map[int][]types.Event{
 		0: {
 			{Humidity: 12, Temperature: 77, EventType: eventtypes["Gestation"]["Agar sampling"]},
@@ -106,27 +70,9 @@ func Test_HappyGenerationEvent(t *testing.T) {
 			{Humidity: 22, Temperature: 76, EventType: eventtypes["Any"]["100% colonization"]},
 		},
 	} {
-		url := fmt.Sprintf(urlfmt, generations[g].UUID)
-
 		for _, e := range v {
-			b, err := json.Marshal(e)
-			require.Nil(t, err)
-
-			req, err := http.NewRequest(
-				http.MethodPost,
-				url,
-				bytes.NewReader(b))
-			require.Nil(t, err)
-			req.AddCookie(cookie)
-
-			res, err := http.DefaultClient.Do(req)
-			require.Nil(t, err)
-			require.Equal(t, http.StatusCreated, res.StatusCode)
-
-			b, err = io.ReadAll(res.Body)
-			require.Nil(t, err)
-
-			err = json.Unmarshal(b, &generations[g])
+			var err error
+			generations[g], err = api.Generations.AddEvent(context.Background(), generations[g].UUID, e)
 			require.Nil(t, err)
 		}
 	}
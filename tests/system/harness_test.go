@@ -0,0 +1,214 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/centerforfunguscontrol/internal/data/huautla"
+	"github.com/jsmit257/centerforfunguscontrol/internal/router"
+	"github.com/jsmit257/centerforfunguscontrol/shared/client"
+	"github.com/jsmit257/huautla/types"
+)
+
+// fakeAuthn is just enough of userservice for the authn middleware and
+// login: any user can be created, any of them can log in, and they all get
+// the same cookie
+type fakeAuthn struct {
+	token string
+}
+
+func (fa fakeAuthn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost && r.URL.Path == "/user" {
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(uuid.NewString()))
+	} else if r.Method == http.MethodPost && r.URL.Path == "/auth" {
+		http.SetCookie(w, fa.cookie())
+		w.WriteHeader(http.StatusOK)
+	} else if r.Method != http.MethodGet || r.URL.Path != "/valid" {
+		w.WriteHeader(http.StatusNotFound)
+	} else if c, err := r.Cookie("us-authn"); err != nil || c.Value != fa.token {
+		w.WriteHeader(http.StatusForbidden)
+	} else {
+		// no Location, so the client hands the 302 back instead of
+		// following it
+		http.SetCookie(w, fa.cookie())
+		w.WriteHeader(http.StatusFound)
+	}
+}
+
+func (fa fakeAuthn) cookie() *http.Cookie {
+	return &http.Cookie{Name: "us-authn", Value: fa.token, Path: "/"}
+}
+
+// Test_authn is whether requests without the cookie are turned away, so
+// the other tests are really going through the middleware
+func Test_authn(t *testing.T) {
+	t.Parallel()
+
+	if cfg.AuthnHost == "" || cfg.AuthnPort == 0 {
+		t.Skip("there's no userservice")
+	}
+
+	url := fmt.Sprintf(`http://%s:%d/vendors`, cfg.HTTPHost, cfg.HTTPPort)
+
+	tcs := map[string]struct {
+		cookie *http.Cookie
+		sc     int
+	}{
+		"happy_path": {
+			cookie: cookie,
+			sc:     http.StatusOK,
+		},
+		"no_cookie": {
+			sc: http.StatusForbidden,
+		},
+		"bad_cookie": {
+			cookie: &http.Cookie{Name: "us-authn", Value: "stale"},
+			sc:     http.StatusForbidden,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req, err := http.NewRequest(http.MethodGet, url, nil)
+			require.Nil(t, err)
+			if tc.cookie != nil {
+				req.AddCookie(tc.cookie)
+			}
+
+			res, err := http.DefaultClient.Do(req)
+			require.Nil(t, err)
+			require.Equal(t, tc.sc, res.StatusCode)
+		})
+	}
+}
+
+// serve starts the same router the http ingress does, on a database that
+// only the tests use, behind a fake userservice, and points cfg at them
+// both; stop shuts it all down and cleans up
+func serve(database string) (stop func(), err error) {
+	dir, err := os.MkdirTemp("", "cffc-system-")
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.Database = database
+	cfg.Demo = false
+	cfg.SQLitePath = filepath.Join(dir, "cffc.db")
+	cfg.AlbumDir = filepath.Join(dir, "album")
+	cfg.StoreDir = filepath.Join(dir, "store")
+	cfg.AttachmentDir = filepath.Join(dir, "attachments")
+	for _, d := range []string{cfg.AlbumDir, cfg.AttachmentDir} {
+		if err = os.Mkdir(d, 0755); err != nil {
+			_ = os.RemoveAll(dir)
+			return nil, err
+		}
+	}
+
+	fa := fakeAuthn{token: uuid.NewString()}
+	authn := httptest.NewServer(fa)
+	if cfg.AuthnHost, cfg.AuthnPort, err = hostPort(authn.Listener.Addr()); err != nil {
+		authn.Close()
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	log := logger.WithField("app", "cffc-system-test")
+
+	ha, err := huautla.New(cfg, log)
+	if err != nil {
+		authn.Close()
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go ha.Run(ctx)
+
+	srv := httptest.NewServer(router.NewHuautla(cfg, ha, log))
+
+	stop = func() {
+		srv.Close()
+		cancel()
+		authn.Close()
+		_ = os.RemoveAll(dir)
+	}
+
+	var port uint16
+	if cfg.HTTPHost, port, err = hostPort(srv.Listener.Addr()); err != nil {
+		stop()
+		return nil, err
+	}
+	cfg.HTTPPort = int(port)
+
+	if err = reference(fa.cookie()); err != nil {
+		stop()
+		return nil, err
+	}
+
+	return stop, nil
+}
+
+func hostPort(addr net.Addr) (string, uint16, error) {
+	host, port, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return "", 0, err
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	return host, uint16(p), err
+}
+
+// reference is what a live database already has before the tests start: a
+// vendor and the usual ingredients
+func reference(c *http.Cookie) error {
+	api, err := client.New(fmt.Sprintf("http://%s:%d", cfg.HTTPHost, cfg.HTTPPort), client.WithCookie(c))
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if _, err = api.Vendors.Create(ctx, types.Vendor{Name: "In house", Website: "http://localhost"}); err != nil {
+		return err
+	}
+
+	for _, name := range []string{
+		"Brown rice flour",
+		"Calcium carbonate",
+		"Coco coir",
+		"Corn",
+		"Dextrose",
+		"Hardwood sawdust",
+		"Light malt extract",
+		"Oats",
+		"Peptone",
+		"Potato dextrose",
+		"Soybean hulls",
+		"Straw",
+		"Vermiculite",
+		"Wheat",
+		"Wild bird seed",
+		"Yeast extract",
+	} {
+		if _, err = api.Ingredients.Create(ctx, types.Ingredient{Name: name}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
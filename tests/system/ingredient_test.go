@@ -1,11 +1,7 @@
 package test
 
 import (
-	"bytes"
-	"encoding/json"
-	"fmt"
-	"io"
-	"net/http"
+	"context"
 	"testing"
 
 	"github.com/jsmit257/huautla/types"
@@ -14,26 +10,13 @@ import (
 
 var ingredients []types.Ingredient
 
-func init() {
-	url := fmt.Sprintf(`http://%s:%d/ingredients`, cfg.HTTPHost, cfg.HTTPPort)
-
-	if req, err := http.NewRequest(http.MethodGet, url, nil); err != nil {
-		panic(err)
-	} else if req.AddCookie(cookie); false {
-	} else if res, err := http.DefaultClient.Do(req); err != nil {
-		panic(err)
-	} else if http.StatusOK != res.StatusCode {
-		panic(fmt.Errorf("expected sc: 200, got: %d", res.StatusCode))
-	} else if b, err := io.ReadAll(res.Body); err != nil {
-		panic(err)
-	} else if err = json.Unmarshal(b, &ingredients); err != nil {
-		panic(err)
-	}
+func loadIngredients() (err error) {
+	ingredients, err = api.Ingredients.All(context.Background())
+	return err
 }
 
-func Test_HappyIngredient(t *testing.T) {
+func happyIngredient(t *testing.T) {
 	t.Skip()
-	url := fmt.Sprintf(`http://%s:%d/ingredient`, cfg.HTTPHost, cfg.HTTPPort)
 
 	for _, i := range []types.Ingredient{
 		{Name: "Vermiculite"},
@@ -54,21 +37,7 @@ func Test_HappyIngredient(t *testing.T) {
 		{Name: "Calcium phosphate"},
 		{Name: "Diammonium phosphate"},
 	} {
-		b, err := json.Marshal(i)
-		require.Nil(t, err)
-
-		req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(b))
-		require.Nil(t, err)
-		req.AddCookie(cookie)
-
-		res, err := http.DefaultClient.Do(req)
-		require.Nil(t, err)
-		require.Equal(t, http.StatusCreated, res.StatusCode)
-
-		b, err = io.ReadAll(res.Body)
-		require.Nil(t, err)
-
-		err = json.Unmarshal(b, &i)
+		i, err := api.Ingredients.Create(context.Background(), i)
 		require.Nil(t, err)
 
 		ingredients = append(ingredients, i)
@@ -1,11 +1,7 @@
 package test
 
 import (
-	"bytes"
-	"encoding/json"
-	"fmt"
-	"io"
-	"net/http"
+	"context"
 	"testing"
 
 	"github.com/jsmit257/huautla/types"
@@ -15,29 +11,13 @@ import (
 
 var lifecycles []types.Lifecycle
 
-func Test_HappyLifecycle(t *testing.T) {
-	url := fmt.Sprintf(`http://%s:%d/lifecycle`, cfg.HTTPHost, cfg.HTTPPort)
-
+func happyLifecycle(t *testing.T) {
 	for _, l := range []types.Lifecycle{
 		{Location: "1st chair, 2nd violin", Strain: strains[0], GrainSubstrate: substrates[0], BulkSubstrate: substrates[9]},
 		{Location: "cat box", Strain: strains[3], GrainSubstrate: substrates[2], BulkSubstrate: substrates[9]},
 		{Location: "6 underground", Strain: strains[5], GrainSubstrate: substrates[2], BulkSubstrate: substrates[9]},
 	} {
-		b, err := json.Marshal(l)
-		require.Nil(t, err)
-
-		req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(b))
-		require.Nil(t, err)
-		req.AddCookie(cookie)
-
-		res, err := http.DefaultClient.Do(req)
-		require.Nil(t, err)
-		require.Equal(t, http.StatusCreated, res.StatusCode)
-
-		b, err = io.ReadAll(res.Body)
-		require.Nil(t, err)
-
-		err = json.Unmarshal(b, &l)
+		l, err := api.Lifecycles.Create(context.Background(), l)
 		require.Nil(t, err)
 
 		lifecycles = append(lifecycles, l)
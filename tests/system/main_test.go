@@ -3,24 +3,113 @@ package test
 import (
 	"bytes"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"net/http"
+	"os"
+	"testing"
 	"time"
 
 	"github.com/jsmit257/centerforfunguscontrol/internal/config"
+	"github.com/jsmit257/centerforfunguscontrol/shared/client"
 	"github.com/jsmit257/userservice/shared/v1"
 )
 
 var (
 	cookie *http.Cookie = &http.Cookie{}
 
+	// api is what every test talks to the server with, once it's logged in
+	api *client.Client
+
 	cfg = config.NewConfig()
+
+	live     = flag.Bool("live", false, "test the server already running at HTTP_HOST:HTTP_PORT, instead of starting one")
+	database = flag.String("database", "memory", "the database the server that's started uses, one of memory, sqlite or huautla")
 )
 
-func init() {
+// TestMain starts a server with a fake userservice in front of it, unless
+// the tests are -live, then logs in and loads what the tests build on
+func TestMain(m *testing.M) {
+	flag.Parse()
+
+	stop := func() {}
+	if !*live {
+		var err error
+		if stop, err = serve(*database); err != nil {
+			fmt.Fprintln(os.Stderr, "server didn't start:", err)
+			os.Exit(1)
+		}
+	}
+
+	code := 1
+	if err := setup(); err != nil {
+		fmt.Fprintln(os.Stderr, "setup failed:", err)
+	} else {
+		code = m.Run()
+	}
+
+	stop()
+	os.Exit(code)
+}
+
+// setup is everything that used to happen in init, before there was a
+// server to talk to
+func setup() error {
+	var opts []client.Option
+	if err := login(); err != nil {
+		return err
+	} else if cookie.Name != "" {
+		opts = append(opts, client.WithCookie(cookie))
+	}
+
+	var err error
+	if api, err = client.New(fmt.Sprintf("http://%s:%d", cfg.HTTPHost, cfg.HTTPPort), opts...); err != nil {
+		return err
+	} else if err = loadVendors(); err != nil {
+		return err
+	} else if err = loadIngredients(); err != nil {
+		return err
+	} else if err = loadEventTypes(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Test_system runs the happy paths in the order they build on each other,
+// and stops at the first one that fails, since everything after it would
+// too
+func Test_system(t *testing.T) {
+	for _, step := range []struct {
+		name string
+		test func(*testing.T)
+	}{
+		{"Vendor", happyVendor},
+		{"Ingredient", happyIngredient},
+		{"Substrate", happySubstrate},
+		{"SubstrateIngredient", happySubstrateIngredient},
+		{"Strain", happyStrain},
+		{"StrainPhoto", happyStrainPhoto},
+		{"StrainAttribute", happyStrainAttribute},
+		{"Lifecycle", happyLifecycle},
+		{"Generation", happyGeneration},
+		{"GeneratedStrain", happyGeneratedStrain},
+		{"LifecycleEvent", happyLifecycleEvent},
+		{"GenerationEvent", happyGenerationEvent},
+		{"StrainSource", happyStrainSource},
+		{"EventSource", happyEventSource},
+	} {
+		if !t.Run(step.name, step.test) {
+			t.FailNow()
+		}
+	}
+}
+
+// login gets the cookie every request needs, if there's a userservice to
+// get it from
+func login() error {
 	if cfg.AuthnHost == "" || cfg.AuthnPort == 0 {
-		return
+		return nil
 	}
 
 	addr := shared.Email("foobar@example.com")
@@ -32,47 +121,35 @@ func init() {
 	url := fmt.Sprintf("http://%s:%d/user", cfg.AuthnHost, cfg.AuthnPort)
 	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(b))
 	if err != nil {
-		panic(err)
+		return err
 	} else if resp, err := http.DefaultClient.Do(req); err != nil {
-		panic(err)
+		return err
 	} else if resp.StatusCode != http.StatusCreated {
-		panic(fmt.Errorf("code isn't created: %d %s", resp.StatusCode, resp.Status))
+		return fmt.Errorf("code isn't created: %d %s", resp.StatusCode, resp.Status)
 	} else if id, err := io.ReadAll(resp.Body); err != nil {
-		panic(err)
+		return err
 	} else if auth.UUID = shared.UUID(id); auth.UUID == "" {
-		panic(fmt.Errorf("no userid returned"))
+		return fmt.Errorf("no userid returned")
 	}
 
-	<-time.After(time.Second)
+	if *live {
+		<-time.After(time.Second)
+	}
 
 	b, _ = json.Marshal(auth)
 	url = fmt.Sprintf("http://%s:%d/auth", cfg.AuthnHost, cfg.AuthnPort)
 	req, err = http.NewRequest(http.MethodPost, url, bytes.NewReader(b))
 	if err != nil {
-		panic(err)
+		return err
 	} else if resp, err := http.DefaultClient.Do(req); err != nil {
-		panic(err)
+		return err
 	} else if resp.StatusCode != http.StatusOK {
-		panic(fmt.Errorf("code isn't OK: %s, %d, %v", url, resp.StatusCode, auth))
-
-		// // b, _ = json.Marshal(auth)
-		// // url = fmt.Sprintf("http://%s:%d/auth", cfg.AuthnHost, cfg.AuthnPort)
-		// req, err = http.NewRequest(http.MethodPost, url, bytes.NewReader(b))
-		// if err != nil {
-		// 	panic(err)
-		// } else if resp, err := http.DefaultClient.Do(req); err != nil {
-		// 	panic(err)
-		// } else if resp.StatusCode != http.StatusOK {
-		// 	panic(fmt.Errorf("code isn't OK: %s, %d, %v", url, resp.StatusCode, auth))
-		// } else if header := resp.Header.Get("Set-Cookie"); len(header) == 0 {
-		// 	panic("no Set-Cookie header found")
-		// } else if cookie, err = http.ParseSetCookie(header); err != nil {
-		// 	panic(fmt.Errorf("cookie was unparseable %s", header))
-		// }
-
+		return fmt.Errorf("code isn't OK: %s, %d, %v", url, resp.StatusCode, auth)
 	} else if header := resp.Header.Get("Set-Cookie"); len(header) == 0 {
-		panic("no Set-Cookie header found")
+		return fmt.Errorf("no Set-Cookie header found")
 	} else if cookie, err = http.ParseSetCookie(header); err != nil {
-		panic(fmt.Errorf("cookie was unparseable %s", header))
+		return fmt.Errorf("cookie was unparseable %s", header)
 	}
+
+	return nil
 }
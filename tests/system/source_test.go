@@ -1,13 +1,10 @@
 package test
 
 import (
-	"bytes"
-	"encoding/json"
-	"fmt"
-	"io"
-	"net/http"
+	"context"
 	"testing"
 
+	"github.com/jsmit257/centerforfunguscontrol/shared/client"
 	"github.com/jsmit257/huautla/types"
 
 	"github.com/stretchr/testify/require"
@@ -15,9 +12,7 @@ import (
 
 var sources []types.Source
 
-func Test_HappyStrainSource(t *testing.T) {
-	urlfmt := fmt.Sprintf(`http://%s:%d/generation/%%s/sources/strain`, cfg.HTTPHost, cfg.HTTPPort)
-
+func happyStrainSource(t *testing.T) {
 	for k, v := range map[int][]types.Source{
 		3: {
 			{Type: "Clone", Strain: strains[0]},
@@ -31,23 +26,7 @@ func Test_HappyStrainSource(t *testing.T) {
 		},
 	} {
 		for _, s := range v {
-			url := fmt.Sprintf(urlfmt, generations[k].UUID)
-
-			b, err := json.Marshal(s)
-			require.Nil(t, err)
-
-			req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(b))
-			require.Nil(t, err)
-			req.AddCookie(cookie)
-
-			res, err := http.DefaultClient.Do(req)
-			require.Nil(t, err)
-			require.Equal(t, http.StatusCreated, res.StatusCode)
-
-			b, err = io.ReadAll(res.Body)
-			require.Nil(t, err)
-
-			err = json.Unmarshal(b, &s)
+			s, err := api.Generations.AddSource(context.Background(), generations[k].UUID, client.OriginStrain, s)
 			require.Nil(t, err)
 
 			sources = append(sources, s)
@@ -55,11 +34,7 @@ func Test_HappyStrainSource(t *testing.T) {
 	}
 }
 
-func Test_HappyEventSource(t *testing.T) {
-	urlfmt := fmt.Sprintf(`http://%s:%d/generation/%%s/sources/event`, cfg.HTTPHost, cfg.HTTPPort)
-
-	var s types.Source
-
+func happyEventSource(t *testing.T) {
 	for k, v := range map[int][]types.Event{
 		2: {
 			findEvent("Clone", "Generation", lifecycles[2].Events),
@@ -73,9 +48,7 @@ func Test_HappyEventSource(t *testing.T) {
 		},
 	} {
 		for _, e := range v {
-			url := fmt.Sprintf(urlfmt, generations[k].UUID)
-
-			b, err := json.Marshal(types.Source{
+			s, err := api.Generations.AddSource(context.Background(), generations[k].UUID, client.OriginEvent, types.Source{
 				Lifecycle: &types.Lifecycle{
 					Events: []types.Event{e},
 				},
@@ -83,21 +56,7 @@ func Test_HappyEventSource(t *testing.T) {
 					return s[0:5]
 				}(e.EventType.Name),
 			})
-			require.Nil(t, err)
-
-			req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(b))
-			require.Nil(t, err)
-			req.AddCookie(cookie)
-
-			res, err := http.DefaultClient.Do(req)
-			require.Nil(t, err)
-			require.Equal(t, http.StatusCreated, res.StatusCode, "%d, %s", k, b)
-
-			b, err = io.ReadAll(res.Body)
-			require.Nil(t, err)
-
-			err = json.Unmarshal(b, &s)
-			require.Nil(t, err)
+			require.Nil(t, err, "%d", k)
 
 			sources = append(sources, s)
 		}
@@ -1,49 +1,33 @@
 package test
 
 import (
-	"bytes"
-	"encoding/json"
-	"fmt"
-	"io"
-	"mime/multipart"
-	"net/http"
+	"context"
 	"os"
 	"testing"
 
+	"github.com/jsmit257/centerforfunguscontrol/shared/client"
 	"github.com/jsmit257/huautla/types"
 
 	"github.com/stretchr/testify/require"
 )
 
 var (
-	strains     []types.Strain
-	sampledata  bytes.Buffer
-	contentType string
+	strains []types.Strain
+	sample  client.File
 )
 
 func init() {
 	const samplefile = "../../tests/data/sample.png"
 
-	w := multipart.NewWriter(&sampledata)
-
-	if sample, err := os.Open(samplefile); err != nil {
-		panic(err)
-	} else if sample == nil {
-		panic(fmt.Errorf("sample image data is nil"))
-	} else if fw, err := w.CreateFormFile("file", samplefile); err != nil {
-		panic(err)
-	} else if _, err = io.Copy(fw, sample); err != nil {
+	data, err := os.ReadFile(samplefile)
+	if err != nil {
 		panic(err)
 	}
 
-	w.Close()
-
-	contentType = w.FormDataContentType()
+	sample = client.File{Name: samplefile, ContentType: "image/png", Data: data}
 }
 
-func Test_HappyStrain(t *testing.T) {
-	url := fmt.Sprintf(`http://%s:%d/strain`, cfg.HTTPHost, cfg.HTTPPort)
-
+func happyStrain(t *testing.T) {
 	for _, s := range []types.Strain{
 		{Name: "Morel", Species: "M.anatolica", Vendor: vendors[0]},
 		{Name: "Hens o' the Wood", Species: "G.frondosa", Vendor: vendors[2]},
@@ -54,21 +38,7 @@ func Test_HappyStrain(t *testing.T) {
 		{Name: "Chestnut", Species: "P.adiposa", Vendor: vendors[2]},
 		{Name: "Hericium", Species: "H.abietis", Vendor: vendors[0]},
 	} {
-		b, err := json.Marshal(s)
-		require.Nil(t, err)
-
-		req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(b))
-		require.Nil(t, err)
-		req.AddCookie(cookie)
-
-		res, err := http.DefaultClient.Do(req)
-		require.Nil(t, err)
-		require.Equal(t, http.StatusCreated, res.StatusCode)
-
-		b, err = io.ReadAll(res.Body)
-		require.Nil(t, err)
-
-		err = json.Unmarshal(b, &s)
+		s, err := api.Strains.Create(context.Background(), s)
 		require.Nil(t, err)
 
 		strains = append(strains, s)
@@ -76,59 +46,20 @@ func Test_HappyStrain(t *testing.T) {
 }
 
 func createPhoto(t *testing.T, id types.UUID) types.UUID {
-	url := fmt.Sprintf(`http://%s:%d/photos`, cfg.HTTPHost, cfg.HTTPPort)
-
-	req, err := http.NewRequest(
-		http.MethodPost,
-		fmt.Sprintf("%s/%s", url, id),
-		bytes.NewReader(sampledata.Bytes()))
-	require.Nil(t, err)
-	req.AddCookie(cookie)
-	req.Header.Set("Content-Type", contentType)
-
-	res, err := http.DefaultClient.Do(req)
-	require.Nil(t, err)
-	require.Equal(t, http.StatusOK, res.StatusCode, "strain: %v", id)
-
-	var b []byte
-	b, err = io.ReadAll(res.Body)
-	require.Nil(t, err)
-
-	var photos []types.Photo
-	err = json.Unmarshal(b, &photos)
-	require.Nil(t, err)
+	photos, err := api.Photos.Add(context.Background(), id, sample)
+	require.Nil(t, err, "strain: %v", id)
 	require.Equal(t, 1, len(photos))
 
 	return photos[0].UUID
 }
 
 func createNote(t *testing.T, id types.UUID, note string) {
-	url := fmt.Sprintf(`http://%s:%d/notes`, cfg.HTTPHost, cfg.HTTPPort)
-
-	b, err := json.Marshal(types.Note{Note: note})
-	require.Nil(t, err)
-
-	req, err := http.NewRequest(
-		http.MethodPost,
-		fmt.Sprintf("%s/%s", url, id),
-		bytes.NewReader(b))
-	require.Nil(t, err)
-	req.AddCookie(cookie)
-
-	res, err := http.DefaultClient.Do(req)
-	require.Nil(t, err)
-	require.Equal(t, http.StatusOK, res.StatusCode)
-
-	b, err = io.ReadAll(res.Body)
-	require.Nil(t, err)
-
-	var notes []types.Note
-	err = json.Unmarshal(b, &notes)
+	notes, err := api.Notes.Add(context.Background(), id, types.Note{Note: note})
 	require.Nil(t, err)
 	require.Equal(t, 1, len(notes))
 }
 
-func Test_HappyStrainPhoto(t *testing.T) {
+func happyStrainPhoto(t *testing.T) {
 	for _, s := range strains {
 		createNote(t, createPhoto(t, s.UUID), s.Name)
 	}
@@ -1,11 +1,7 @@
 package test
 
 import (
-	"bytes"
-	"encoding/json"
-	"fmt"
-	"io"
-	"net/http"
+	"context"
 	"testing"
 
 	"github.com/jsmit257/huautla/types"
@@ -14,9 +10,7 @@ import (
 
 var substrates []types.Substrate
 
-func Test_HappySubstrate(t *testing.T) {
-	url := fmt.Sprintf(`http://%s:%d/substrate`, cfg.HTTPHost, cfg.HTTPPort)
-
+func happySubstrate(t *testing.T) {
 	for _, s := range []types.Substrate{
 		{Name: "5-grain", Type: types.GrainType, Vendor: vendors[0]},
 		{Name: "Rye", Type: types.GrainType, Vendor: vendors[1]},
@@ -29,21 +23,7 @@ func Test_HappySubstrate(t *testing.T) {
 		{Name: "Liquid culture", Type: types.LiquidType, Vendor: vendors[3]},
 		{Name: "Horse cookies", Type: types.BulkType, Vendor: vendors[0]},
 	} {
-		b, err := json.Marshal(s)
-		require.Nil(t, err)
-
-		req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(b))
-		require.Nil(t, err)
-		req.AddCookie(cookie)
-
-		res, err := http.DefaultClient.Do(req)
-		require.Nil(t, err)
-		require.Equal(t, http.StatusCreated, res.StatusCode)
-
-		b, err = io.ReadAll(res.Body)
-		require.Nil(t, err)
-
-		err = json.Unmarshal(b, &s)
+		s, err := api.Substrates.Create(context.Background(), s)
 		require.Nil(t, err)
 
 		substrates = append(substrates, s)
@@ -1,20 +1,14 @@
 package test
 
 import (
-	"bytes"
-	"encoding/json"
-	"fmt"
-	"io"
-	"net/http"
+	"context"
 	"testing"
 
 	"github.com/jsmit257/huautla/types"
 	"github.com/stretchr/testify/require"
 )
 
-func Test_HappySubstrateIngredient(t *testing.T) {
-	urlfmt := fmt.Sprintf(`http://%s:%d/substrate/%%s/ingredients`, cfg.HTTPHost, cfg.HTTPPort)
-
+func happySubstrateIngredient(t *testing.T) {
 	for s, v := range map[int][]types.Ingredient{
 		0: {
 			ingredients[2],
@@ -35,24 +29,8 @@ func Test_HappySubstrateIngredient(t *testing.T) {
 		},
 	} {
 		for _, i := range v {
-			b, err := json.Marshal(i)
-			require.Nil(t, err)
-
-			req, err := http.NewRequest(
-				http.MethodPost,
-				fmt.Sprintf(urlfmt, substrates[s].UUID),
-				bytes.NewReader(b))
-			require.Nil(t, err)
-			req.AddCookie(cookie)
-
-			res, err := http.DefaultClient.Do(req)
-			require.Nil(t, err)
-			require.Equal(t, http.StatusCreated, res.StatusCode)
-
-			b, err = io.ReadAll(res.Body)
-			require.Nil(t, err)
-
-			err = json.Unmarshal(b, &substrates[s])
+			var err error
+			substrates[s], err = api.Substrates.AddIngredient(context.Background(), substrates[s].UUID, i)
 			require.Nil(t, err)
 		}
 	}
@@ -1,11 +1,7 @@
 package test
 
 import (
-	"bytes"
-	"encoding/json"
-	"fmt"
-	"io"
-	"net/http"
+	"context"
 	"testing"
 
 	"github.com/jsmit257/huautla/types"
@@ -15,47 +11,18 @@ import (
 
 var vendors []types.Vendor
 
-func init() {
-	if req, err := http.NewRequest(
-		http.MethodGet,
-		fmt.Sprintf(`http://%s:%d/vendors`, cfg.HTTPHost, cfg.HTTPPort),
-		nil); err != nil {
-		panic(err)
-	} else if req == nil {
-		panic("wtf?")
-	} else if req.AddCookie(cookie); false {
-	} else if res, err := http.DefaultClient.Do(req); err != nil {
-		panic(err)
-	} else if b, err := io.ReadAll(res.Body); err != nil {
-		panic(err)
-	} else if err = json.Unmarshal(b, &vendors); err != nil {
-		panic(err)
-	}
+func loadVendors() (err error) {
+	vendors, err = api.Vendors.All(context.Background())
+	return err
 }
 
-func Test_HappyVendor(t *testing.T) {
-	url := fmt.Sprintf(`http://%s:%d/vendor`, cfg.HTTPHost, cfg.HTTPPort)
-
+func happyVendor(t *testing.T) {
 	for _, v := range []types.Vendor{
 		{Name: "Fun Guys", Website: "http://www.example.com"},
 		{Name: "Nuthin but Fungus", Website: "http://www.example.com"},
 		{Name: "Mycellium Emporium", Website: "http://www.example.com"},
 	} {
-		b, err := json.Marshal(v)
-		require.Nil(t, err)
-
-		req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(b))
-		require.Nil(t, err)
-		req.AddCookie(cookie)
-
-		res, err := http.DefaultClient.Do(req)
-		require.Nil(t, err)
-		require.Equal(t, http.StatusCreated, res.StatusCode)
-
-		b, err = io.ReadAll(res.Body)
-		require.Nil(t, err)
-
-		err = json.Unmarshal(b, &v)
+		v, err := api.Vendors.Create(context.Background(), v)
 		require.Nil(t, err)
 
 		vendors = append(vendors, v)